@@ -0,0 +1,73 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// adminAuth protects the administrative endpoints, such as the lifecycle API,
+// independently of the metrics endpoint. Without a token and without
+// requiring client certificates, all requests are allowed.
+type adminAuth struct {
+	// token is the bearer token that requests must present.
+	token string
+	// requireClientCert requires requests to be made over TLS with a verified
+	// client certificate.
+	requireClientCert bool
+	logger            *slog.Logger
+}
+
+// readBearerToken reads a bearer token from a file, ignoring surrounding
+// whitespace such as a final newline.
+func readBearerToken(fileName string) (string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", fileName)
+	}
+	return token, nil
+}
+
+// protect wraps the handler of an administrative endpoint.
+func (a *adminAuth) protect(h http.Handler) http.Handler {
+	if a.token == "" && !a.requireClientCert {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.requireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			a.logger.Warn("Rejected administrative request without a verified client certificate", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			http.Error(w, "a verified client certificate is required", http.StatusForbidden)
+			return
+		}
+		if a.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+				a.logger.Warn("Rejected administrative request without a valid bearer token", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
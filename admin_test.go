@@ -0,0 +1,122 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{}}}
+
+	scenarios := []struct {
+		name              string
+		token             string
+		requireClientCert bool
+		authorization     string
+		tls               *tls.ConnectionState
+		code              int
+	}{
+		{
+			name: "no protection",
+			code: http.StatusOK,
+		},
+		{
+			name:          "valid token",
+			token:         "secret",
+			authorization: "Bearer secret",
+			code:          http.StatusOK,
+		},
+		{
+			name:  "missing token",
+			token: "secret",
+			code:  http.StatusUnauthorized,
+		},
+		{
+			name:          "wrong token",
+			token:         "secret",
+			authorization: "Bearer guess",
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:          "basic authorization",
+			token:         "secret",
+			authorization: "Basic secret",
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:              "verified client certificate",
+			requireClientCert: true,
+			tls:               verified,
+			code:              http.StatusOK,
+		},
+		{
+			name:              "unverified client certificate",
+			requireClientCert: true,
+			tls:               &tls.ConnectionState{},
+			code:              http.StatusForbidden,
+		},
+		{
+			name:              "client certificate without token",
+			token:             "secret",
+			requireClientCert: true,
+			tls:               verified,
+			code:              http.StatusUnauthorized,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			a := &adminAuth{token: s.token, requireClientCert: s.requireClientCert, logger: promslog.NewNopLogger()}
+			req := httptest.NewRequest(http.MethodPost, "/-/quit", nil)
+			if s.authorization != "" {
+				req.Header.Set("Authorization", s.authorization)
+			}
+			req.TLS = s.tls
+			rec := httptest.NewRecorder()
+			a.protect(ok).ServeHTTP(rec, req)
+			if rec.Code != s.code {
+				t.Fatalf("Expected status %d, got %d", s.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestReadBearerToken(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "token")
+	if err := os.WriteFile(fileName, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	token, err := readBearerToken(fileName)
+	if err != nil || token != "secret" {
+		t.Fatalf("Expected token \"secret\", got %q, %v", token, err)
+	}
+
+	if err := os.WriteFile(fileName, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBearerToken(fileName); err == nil {
+		t.Fatal("Expected an error for an empty token file")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+// benchTickInterval is how often the bench command sends the lines that are
+// due at the target rate.
+const benchTickInterval = 10 * time.Millisecond
+
+// benchCounters are the counters of the exporter that the bench command
+// compares before and after sending, to report what the exporter received
+// and dropped.
+var benchCounters = []string{
+	"statsd_exporter_lines_total",
+	"statsd_exporter_udp_packets_total",
+	"statsd_exporter_udp_packet_drops_total",
+}
+
+// benchConfig holds the flags of the bench command.
+type benchConfig struct {
+	cmd            *kingpin.CmdClause
+	target         *string
+	protocol       *string
+	format         *string
+	rate           *int
+	duration       *time.Duration
+	metrics        *int
+	tags           *int
+	tagValues      *int
+	types          *[]string
+	linesPerPacket *int
+	packetLength   *int
+	metricsURL     *string
+	seed           *int64
+}
+
+// addBenchCommand adds the bench command, which sends generated traffic to an
+// exporter for capacity testing.
+func addBenchCommand(app *kingpin.Application) *benchConfig {
+	cmd := app.Command("bench", "Send generated StatsD traffic to an exporter and report the achieved rate and the lines the exporter dropped.")
+	return &benchConfig{
+		cmd:            cmd,
+		target:         cmd.Flag("target", "The address of the StatsD listener to send to.").Default("localhost:9125").String(),
+		protocol:       cmd.Flag("protocol", "The protocol to send lines with.").Default("udp").Enum("udp", "tcp"),
+		format:         cmd.Flag("format", "The tagging style of the lines.").Default("dogstatsd").Enum(line.TagStyles...),
+		rate:           cmd.Flag("rate", "Lines to send per second.").Default("100000").Int(),
+		duration:       cmd.Flag("duration", "How long to send for.").Default("10s").Duration(),
+		metrics:        cmd.Flag("metrics", "Number of distinct metric names. Names are picked with a Zipf distribution, so that a few of them make up most of the traffic.").Default("100").Int(),
+		tags:           cmd.Flag("tags", "Number of tags per line.").Default("3").Int(),
+		tagValues:      cmd.Flag("tag-values", "Number of distinct values per tag. Each metric has up to tag-values^tags series.").Default("10").Int(),
+		types:          cmd.Flag("type", "StatsD type of the metrics. Can be repeated to send a mix of types, which are assigned to the metric names in turn.").Default("c", "g", "ms").Enums("c", "g", "ms", "h", "d"),
+		linesPerPacket: cmd.Flag("lines-per-packet", "Maximum number of lines batched into one packet or write.").Default("10").Int(),
+		packetLength:   cmd.Flag("packet-length", "Maximum length of a packet or write in bytes.").Default("1400").Int(),
+		metricsURL:     cmd.Flag("metrics-url", "The metrics endpoint of the exporter, scraped before and after sending to report what it received and dropped. \"\" disables it.").Default("http://localhost:9102/metrics").String(),
+		seed:           cmd.Flag("seed", "Seed of the random traffic, so that runs can be repeated.").Default("1").Int64(),
+	}
+}
+
+// benchGenerator generates lines with random names, tags and values.
+type benchGenerator struct {
+	encoder   *line.Encoder
+	rnd       *rand.Rand
+	zipf      *rand.Zipf
+	names     []string
+	types     []string
+	tagNames  []string
+	tagValues []string
+	tags      []line.Tag
+}
+
+func newBenchGenerator(c *benchConfig) (*benchGenerator, error) {
+	if *c.metrics < 1 || *c.tagValues < 1 || *c.linesPerPacket < 1 || *c.packetLength < 1 || *c.tags < 0 {
+		return nil, fmt.Errorf("--metrics, --tag-values, --lines-per-packet and --packet-length must be positive, and --tags must not be negative")
+	}
+	encoder, err := line.NewEncoder(*c.format)
+	if err != nil {
+		return nil, err
+	}
+	rnd := rand.New(rand.NewSource(*c.seed))
+	g := &benchGenerator{
+		encoder: encoder,
+		rnd:     rnd,
+		zipf:    rand.NewZipf(rnd, 1.1, 1, uint64(*c.metrics-1)),
+		tags:    make([]line.Tag, *c.tags),
+	}
+	// A name keeps its type, as the exporter reports a conflict when the
+	// type of a metric changes.
+	for i := 0; i < *c.metrics; i++ {
+		g.names = append(g.names, "bench.metric_"+strconv.Itoa(i))
+		g.types = append(g.types, (*c.types)[i%len(*c.types)])
+	}
+	for i := 0; i < *c.tags; i++ {
+		g.tagNames = append(g.tagNames, "tag"+strconv.Itoa(i))
+	}
+	for i := 0; i < *c.tagValues; i++ {
+		g.tagValues = append(g.tagValues, "value"+strconv.Itoa(i))
+	}
+	return g, nil
+}
+
+// appendLine appends a random line to b.
+func (g *benchGenerator) appendLine(b []byte) []byte {
+	i := g.zipf.Uint64()
+	for j := range g.tags {
+		g.tags[j] = line.Tag{Name: g.tagNames[j], Value: g.tagValues[g.rnd.Intn(len(g.tagValues))]}
+	}
+
+	var value float64
+	switch g.types[i] {
+	case "c":
+		value = 1
+	case "g":
+		value = float64(g.rnd.Intn(1000))
+	default:
+		// Latencies and sizes are mostly small with a long tail.
+		value = float64(int(g.rnd.ExpFloat64()*100*1000)) / 1000
+	}
+	return g.encoder.AppendLine(b, g.names[i], g.tags, value, g.types[i])
+}
+
+// benchResult is what the bench command sent.
+type benchResult struct {
+	lines, packets, errors int
+	elapsed                time.Duration
+}
+
+// send sends lines at the configured rate for the configured duration. Lines
+// are batched into packets, or writes for TCP, of up to the configured
+// number of lines and length.
+func (g *benchGenerator) send(w io.Writer, c *benchConfig) benchResult {
+	total := int(float64(*c.rate) * c.duration.Seconds())
+	packet := make([]byte, 0, *c.packetLength)
+	next := make([]byte, 0, *c.packetLength)
+	var result benchResult
+
+	flush := func(lines int) {
+		if lines == 0 {
+			return
+		}
+		if *c.protocol == "tcp" {
+			packet = append(packet, '\n')
+		}
+		if _, err := w.Write(packet); err != nil {
+			result.errors++
+		}
+		result.lines += lines
+		result.packets++
+		packet = packet[:0]
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(benchTickInterval)
+	defer ticker.Stop()
+	for sent := 0; sent < total; {
+		<-ticker.C
+		due := min(int(float64(*c.rate)*time.Since(start).Seconds()), total)
+		lines := 0
+		for ; sent < due; sent++ {
+			next = g.appendLine(next[:0])
+			if lines > 0 && (lines == *c.linesPerPacket || len(packet)+1+len(next) > *c.packetLength) {
+				flush(lines)
+				lines = 0
+			}
+			if lines > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, next...)
+			lines++
+		}
+		flush(lines)
+	}
+	result.elapsed = time.Since(start)
+	return result
+}
+
+// scrapeCounters returns the sums of the benchCounters exposed by the
+// exporter.
+func scrapeCounters(client *http.Client, url string) (map[string]float64, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping %s: unexpected status %s", url, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics from %s: %w", url, err)
+	}
+	counters := map[string]float64{}
+	for _, name := range benchCounters {
+		for _, m := range families[name].GetMetric() {
+			counters[name] += m.GetCounter().GetValue()
+		}
+	}
+	return counters, nil
+}
+
+// run runs the bench command and returns the exit code.
+func (c *benchConfig) run(out io.Writer, logger *slog.Logger) int {
+	g, err := newBenchGenerator(c)
+	if err != nil {
+		logger.Error("Invalid bench configuration", "error", err)
+		return 1
+	}
+	conn, err := net.Dial(*c.protocol, *c.target)
+	if err != nil {
+		logger.Error("Unable to connect to the exporter", "target", *c.target, "error", err)
+		return 1
+	}
+	defer conn.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	var before map[string]float64
+	if *c.metricsURL != "" {
+		if before, err = scrapeCounters(client, *c.metricsURL); err != nil {
+			logger.Warn("Unable to scrape the exporter, not reporting what it received", "error", err)
+		}
+	}
+
+	logger.Info("Sending traffic", "target", *c.target, "protocol", *c.protocol, "format", *c.format, "rate", *c.rate, "duration", *c.duration)
+	result := g.send(conn, c)
+	rate := float64(result.lines) / result.elapsed.Seconds()
+	unit := "packets"
+	if *c.protocol == "tcp" {
+		unit = "writes"
+	}
+	fmt.Fprintf(out, "sent      %d lines in %d %s in %s: %.0f lines/s (target %d lines/s), %d send errors\n",
+		result.lines, result.packets, unit, result.elapsed.Round(time.Millisecond), rate, *c.rate, result.errors)
+
+	if before == nil {
+		return 0
+	}
+	// Give the exporter time to process what is still queued.
+	time.Sleep(time.Second)
+	after, err := scrapeCounters(client, *c.metricsURL)
+	if err != nil {
+		logger.Warn("Unable to scrape the exporter, not reporting what it received", "error", err)
+		return 0
+	}
+	received := after["statsd_exporter_lines_total"] - before["statsd_exporter_lines_total"]
+	lost := float64(result.lines) - received
+	fmt.Fprintf(out, "exporter  received %.0f lines, %.0f lines lost (%.2f%%)\n", received, lost, 100*lost/float64(max(result.lines, 1)))
+	if *c.protocol == "udp" {
+		fmt.Fprintf(out, "exporter  received %.0f UDP packets, dropped %.0f from its packet queue\n",
+			after["statsd_exporter_udp_packets_total"]-before["statsd_exporter_udp_packets_total"],
+			after["statsd_exporter_udp_packet_drops_total"]-before["statsd_exporter_udp_packet_drops_total"])
+	}
+	return 0
+}
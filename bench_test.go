@@ -0,0 +1,80 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// packetRecorder records every write as a packet.
+type packetRecorder struct {
+	packets []string
+}
+
+func (r *packetRecorder) Write(p []byte) (int, error) {
+	r.packets = append(r.packets, string(p))
+	return len(p), nil
+}
+
+func TestBench(t *testing.T) {
+	app := kingpin.New("test", "")
+	c := addBenchCommand(app)
+	if _, err := app.Parse([]string{"bench", "--rate=2000", "--duration=50ms", "--metrics=5", "--tags=2", "--tag-values=3", "--lines-per-packet=4", "--packet-length=100", "--type=c", "--type=ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := newBenchGenerator(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r packetRecorder
+	result := g.send(&r, c)
+	if result.lines != 100 || result.packets != len(r.packets) || result.errors != 0 {
+		t.Fatalf("expected 100 lines in %d packets, got %+v", len(r.packets), result)
+	}
+
+	var lines []string
+	for _, p := range r.packets {
+		if len(p) > 100 {
+			t.Errorf("expected packets of at most 100 bytes, got %q", p)
+		}
+		l := strings.Split(p, "\n")
+		if len(l) > 4 {
+			t.Errorf("expected packets of at most 4 lines, got %q", p)
+		}
+		lines = append(lines, l...)
+	}
+	if len(lines) != 100 {
+		t.Fatalf("expected 100 lines, got %d", len(lines))
+	}
+	for _, l := range lines {
+		// Names keep their type, with types assigned in turn.
+		if !(strings.HasPrefix(l, "bench.metric_0:1|c|#tag0:value") || strings.HasPrefix(l, "bench.metric_1:") && strings.Contains(l, "|ms|#tag0:value") ||
+			strings.HasPrefix(l, "bench.metric_2:1|c|#") || strings.HasPrefix(l, "bench.metric_3:") && strings.Contains(l, "|ms|#") ||
+			strings.HasPrefix(l, "bench.metric_4:1|c|#")) {
+			t.Errorf("unexpected line %q", l)
+		}
+	}
+
+	// The same seed generates the same traffic.
+	g, _ = newBenchGenerator(c)
+	for _, l := range lines[:10] {
+		if got := string(g.appendLine(nil)); got != l {
+			t.Errorf("expected %q with the same seed, got %q", l, got)
+		}
+	}
+}
@@ -14,23 +14,44 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/big"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/promslog"
 
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/event"
 	"github.com/prometheus/statsd_exporter/pkg/exporter"
 	"github.com/prometheus/statsd_exporter/pkg/line"
 	"github.com/prometheus/statsd_exporter/pkg/listener"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
 )
 
 func TestHandlePacket(t *testing.T) {
@@ -140,7 +161,8 @@ func TestHandlePacket(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo",
-					OValue:      0.2,
+					OValue:      200,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -171,26 +193,7 @@ func TestHandlePacket(t *testing.T) {
 				&event.ObserverEvent{
 					OMetricName: "foo",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
+					OCount:      5,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -371,6 +374,7 @@ func TestHandlePacket(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -397,26 +401,7 @@ func TestHandlePacket(t *testing.T) {
 				&event.ObserverEvent{
 					OMetricName: "foo",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
+					OCount:      5,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -427,6 +412,7 @@ func TestHandlePacket(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "foo:bar"},
 				},
 			},
@@ -452,17 +438,20 @@ func TestHandlePacket(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo",
-					OValue:      .200,
+					OValue:      200,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo",
-					OValue:      .300,
+					OValue:      300,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      50,
+					CSampled:    true,
 					CLabels:     map[string]string{},
 				},
 				&event.GaugeEvent{
@@ -477,7 +466,8 @@ func TestHandlePacket(t *testing.T) {
 				},
 				&event.ObserverEvent{
 					OMetricName: "bar",
-					OValue:      .005,
+					OValue:      5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -485,16 +475,7 @@ func TestHandlePacket(t *testing.T) {
 			name: "timings with sampling factor",
 			in:   "foo.timing:0.5|ms|@0.1",
 			out: event.Events{
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
+				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.5, OTimer: true, OCount: 10, OLabels: map[string]string{}},
 			},
 		}, {
 			name: "bad line",
@@ -557,7 +538,8 @@ func TestHandlePacket(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo",
-					OValue:      0.2,
+					OValue:      200,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -600,7 +582,7 @@ func TestHandlePacket(t *testing.T) {
 		LinesReceived:   linesReceived,
 		EventsFlushed:   eventsFlushed,
 		SampleErrors:    *sampleErrors,
-		SamplesReceived: samplesReceived,
+		SamplesReceived: *samplesReceived,
 		TagErrors:       tagErrors,
 		TagsReceived:    tagsReceived,
 	}, &mockStatsDTCPListener{listener.StatsDTCPListener{
@@ -611,7 +593,7 @@ func TestHandlePacket(t *testing.T) {
 		LinesReceived:   linesReceived,
 		EventsFlushed:   eventsFlushed,
 		SampleErrors:    *sampleErrors,
-		SamplesReceived: samplesReceived,
+		SamplesReceived: *samplesReceived,
 		TagErrors:       tagErrors,
 		TagsReceived:    tagsReceived,
 		TCPConnections:  tcpConnections,
@@ -643,6 +625,756 @@ func TestHandlePacket(t *testing.T) {
 	}
 }
 
+func TestHandlePacketLimits(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		in             string
+		maxLineLength  int
+		maxPacketLines int
+		out            []string
+		tooLong        float64
+		excess         float64
+	}{
+		{
+			name: "no limits",
+			in:   "foo:1|c\nbarbarbar:1|c\nbaz:1|c",
+			out:  []string{"foo", "barbarbar", "baz"},
+		},
+		{
+			name:          "line too long",
+			in:            "foo:1|c\nbarbarbar:1|c\nbaz:1|c",
+			maxLineLength: 10,
+			out:           []string{"foo", "baz"},
+			tooLong:       1,
+		},
+		{
+			name:           "too many lines",
+			in:             "foo:1|c\nbarbarbar:1|c\nbaz:1|c",
+			maxPacketLines: 2,
+			out:            []string{"foo", "barbarbar"},
+			excess:         1,
+		},
+		{
+			name:           "too many lines with trailing newline",
+			in:             "foo:1|c\nbarbarbar:1|c\nbaz:1|c\n",
+			maxPacketLines: 2,
+			out:            []string{"foo", "barbarbar"},
+			excess:         1,
+		},
+		{
+			name:           "as many lines as allowed with trailing newline",
+			in:             "foo:1|c\nbarbarbar:1|c\n",
+			maxPacketLines: 2,
+			out:            []string{"foo", "barbarbar"},
+		},
+		{
+			name:           "both limits",
+			in:             "foo:1|c\nbarbarbar:1|c\nbaz:1|c\nqux:1|c",
+			maxLineLength:  10,
+			maxPacketLines: 2,
+			out:            []string{"foo"},
+			tooLong:        1,
+			excess:         2,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			lineTooLong := prometheus.NewCounter(prometheus.CounterOpts{Name: "too_long"})
+			excessLines := prometheus.NewCounter(prometheus.CounterOpts{Name: "excess"})
+			events := make(chan event.Events, 32)
+			l := &listener.StatsDUDPListener{
+				EventHandler:    &event.UnbufferedEventHandler{C: events},
+				Logger:          promslog.NewNopLogger(),
+				LineParser:      line.NewParser(),
+				UDPPackets:      udpPackets,
+				UDPPacketDrops:  udpPacketDrops,
+				LinesReceived:   linesReceived,
+				EventsFlushed:   eventsFlushed,
+				SampleErrors:    *sampleErrors,
+				SamplesReceived: *samplesReceived,
+				TagErrors:       tagErrors,
+				TagsReceived:    tagsReceived,
+				MaxLineLength:   s.maxLineLength,
+				MaxPacketLines:  s.maxPacketLines,
+				LineTooLong:     lineTooLong,
+				ExcessLines:     excessLines,
+			}
+			l.HandlePacket([]byte(s.in))
+
+			var names []string
+			for len(events) > 0 {
+				for _, e := range <-events {
+					names = append(names, e.MetricName())
+				}
+			}
+			if !reflect.DeepEqual(names, s.out) {
+				t.Fatalf("expected events %v, got %v", s.out, names)
+			}
+			if v := testutil.ToFloat64(lineTooLong); v != s.tooLong {
+				t.Fatalf("expected %v too long lines, got %v", s.tooLong, v)
+			}
+			if v := testutil.ToFloat64(excessLines); v != s.excess {
+				t.Fatalf("expected %v excess lines, got %v", s.excess, v)
+			}
+		})
+	}
+}
+
+func TestHandleTagHeaders(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		in       string
+		disabled bool
+		out      []map[string]string
+		headers  float64
+		lines    float64
+	}{
+		{
+			name:    "shared tags",
+			in:      "#env:prod,service:web\nfoo:1|c\nbar:1|c|#service:api",
+			out:     []map[string]string{{"env": "prod", "service": "web"}, {"env": "prod", "service": "api"}},
+			headers: 1,
+			lines:   2,
+		},
+		{
+			name:    "container",
+			in:      "#env:prod|c:3f2a9d\nfoo:1|c\n",
+			out:     []map[string]string{{"env": "prod", "container_id": "3f2a9d"}},
+			headers: 1,
+			lines:   1,
+		},
+		{
+			name:  "no header",
+			in:    "foo:1|c\nbar:1|c",
+			out:   []map[string]string{{}, {}},
+			lines: 2,
+		},
+		{
+			name:  "malformed header",
+			in:    "#env:prod|x:y\nfoo:1|c",
+			out:   []map[string]string{{}},
+			lines: 1,
+		},
+		{
+			name:     "disabled",
+			in:       "#env:prod\nfoo:1|c",
+			disabled: true,
+			out:      []map[string]string{{}},
+			lines:    2,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			parser := line.NewParser()
+			parser.EnableDogstatsdParsing()
+			headers := prometheus.NewCounter(prometheus.CounterOpts{Name: "headers"})
+			lines := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "lines"})
+			events := make(chan event.Events, 32)
+			l := &listener.StatsDUDPListener{
+				EventHandler:    &event.UnbufferedEventHandler{C: events},
+				Logger:          promslog.NewNopLogger(),
+				LineParser:      parser,
+				UDPPackets:      udpPackets,
+				UDPPacketDrops:  udpPacketDrops,
+				LinesReceived:   linesReceived,
+				EventsFlushed:   eventsFlushed,
+				SampleErrors:    *sampleErrors,
+				SamplesReceived: *samplesReceived,
+				TagErrors:       tagErrors,
+				TagsReceived:    tagsReceived,
+				TagHeaders: listener.TagHeaders{
+					Enabled:     !s.disabled,
+					Headers:     headers,
+					PacketLines: lines,
+				},
+			}
+			l.HandlePacket([]byte(s.in))
+
+			var labels []map[string]string
+			for len(events) > 0 {
+				for _, e := range <-events {
+					labels = append(labels, e.Labels())
+				}
+			}
+			if !reflect.DeepEqual(labels, s.out) {
+				t.Fatalf("expected labels %v, got %v", s.out, labels)
+			}
+			if v := testutil.ToFloat64(headers); v != s.headers {
+				t.Fatalf("expected %v tag headers, got %v", s.headers, v)
+			}
+			m := &dto.Metric{}
+			if err := lines.Write(m); err != nil {
+				t.Fatal(err)
+			}
+			if v := m.GetHistogram().GetSampleSum(); v != s.lines {
+				t.Fatalf("expected %v lines, got %v", s.lines, v)
+			}
+		})
+	}
+}
+
+func TestTCPPeerMetrics(t *testing.T) {
+	scenarios := []struct {
+		name  string
+		limit int
+		peer  string
+	}{
+		{
+			name:  "tracked peer",
+			limit: 1,
+			peer:  "127.0.0.1",
+		},
+		{
+			name:  "peer beyond the limit",
+			limit: 0,
+			peer:  listener.TCPPeerOther,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			newVec := func(name string) *prometheus.CounterVec {
+				return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name}, []string{"peer"})
+			}
+			lines, lineBytes, parseErrors := newVec("lines"), newVec("bytes"), newVec("parse_errors")
+			l := &mockStatsDTCPListener{listener.StatsDTCPListener{
+				EventHandler:    &event.UnbufferedEventHandler{C: make(chan event.Events, 32)},
+				Logger:          promslog.NewNopLogger(),
+				LineParser:      line.NewParser(),
+				LinesReceived:   linesReceived,
+				SampleErrors:    *sampleErrors,
+				SamplesReceived: *samplesReceived,
+				TagErrors:       tagErrors,
+				TagsReceived:    tagsReceived,
+				TCPConnections:  tcpConnections,
+				TCPErrors:       tcpErrors,
+				TCPLineTooLong:  tcpLineTooLong,
+				Peers:           listener.NewTCPPeers(s.limit, lines, lineBytes, parseErrors, newVec("slow_writes")),
+			}, promslog.NewNopLogger()}
+			l.HandlePacket([]byte("foo:1|c\nbad\n\nbar:2|g\n"))
+
+			if v := testutil.ToFloat64(lines.WithLabelValues(s.peer)); v != 4 {
+				t.Fatalf("expected 4 lines, got %v", v)
+			}
+			if v := testutil.ToFloat64(lineBytes.WithLabelValues(s.peer)); v != 17 {
+				t.Fatalf("expected 17 bytes, got %v", v)
+			}
+			if v := testutil.ToFloat64(parseErrors.WithLabelValues(s.peer)); v != 1 {
+				t.Fatalf("expected 1 parse error, got %v", v)
+			}
+		})
+	}
+}
+
+func TestHandleUnixgramFrames(t *testing.T) {
+	frame := func(payload string) []byte {
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+	}
+	scenarios := []struct {
+		name    string
+		in      []byte
+		out     []string
+		frames  float64
+		unknown float64
+	}{
+		{
+			name: "plain lines",
+			in:   []byte("foo:1|c\nbar:1|c"),
+			out:  []string{"foo", "bar"},
+		},
+		{
+			name:   "single frame",
+			in:     frame("foo:1|c\nbar:1|c"),
+			out:    []string{"foo", "bar"},
+			frames: 1,
+		},
+		{
+			name:   "several frames",
+			in:     append(frame("foo:1|c"), frame("bar:1|c")...),
+			out:    []string{"foo", "bar"},
+			frames: 2,
+		},
+		{
+			name:    "truncated frame",
+			in:      frame("foo:1|c")[:8],
+			unknown: 1,
+		},
+		{
+			name:    "unknown binary",
+			in:      []byte{0x01, 0x00, 0x02},
+			unknown: 1,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			frames := prometheus.NewCounter(prometheus.CounterOpts{Name: "frames"})
+			unknownFrames := prometheus.NewCounter(prometheus.CounterOpts{Name: "unknown_frames"})
+			events := make(chan event.Events, 32)
+			l := &listener.StatsDUnixgramListener{
+				EventHandler:    &event.UnbufferedEventHandler{C: events},
+				Logger:          promslog.NewNopLogger(),
+				LineParser:      line.NewParser(),
+				UnixgramPackets: prometheus.NewCounter(prometheus.CounterOpts{Name: "packets"}),
+				LinesReceived:   linesReceived,
+				EventsFlushed:   eventsFlushed,
+				SampleErrors:    *sampleErrors,
+				SamplesReceived: *samplesReceived,
+				TagErrors:       tagErrors,
+				TagsReceived:    tagsReceived,
+				AcceptFrames:    true,
+				Frames:          frames,
+				UnknownFrames:   unknownFrames,
+			}
+			l.HandlePacket(s.in)
+
+			var names []string
+			for len(events) > 0 {
+				for _, e := range <-events {
+					names = append(names, e.MetricName())
+				}
+			}
+			if !reflect.DeepEqual(names, s.out) {
+				t.Fatalf("expected events %v, got %v", s.out, names)
+			}
+			if v := testutil.ToFloat64(frames); v != s.frames {
+				t.Fatalf("expected %v frames, got %v", s.frames, v)
+			}
+			if v := testutil.ToFloat64(unknownFrames); v != s.unknown {
+				t.Fatalf("expected %v unknown frames, got %v", s.unknown, v)
+			}
+		})
+	}
+}
+
+func TestUnixgramPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials of Unixgram datagrams are only supported on Linux")
+	}
+	socket := filepath.Join(t.TempDir(), "statsd.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram", Name: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linesByUID := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lines_by_uid"}, []string{"uid"})
+	events := make(chan event.Events, 32)
+	l := &listener.StatsDUnixgramListener{
+		Conn:             conn,
+		EventHandler:     &event.UnbufferedEventHandler{C: events},
+		Logger:           promslog.NewNopLogger(),
+		LineParser:       line.NewParser(),
+		UnixgramPackets:  prometheus.NewCounter(prometheus.CounterOpts{Name: "packets"}),
+		LinesReceived:    linesReceived,
+		EventsFlushed:    eventsFlushed,
+		SampleErrors:     *sampleErrors,
+		SamplesReceived:  *samplesReceived,
+		TagErrors:        tagErrors,
+		TagsReceived:     tagsReceived,
+		CredentialLabels: map[string]string{"client_uid": "uid", "client_pid": "pid"},
+		LinesByUID:       linesByUID,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Listen(ctx)
+
+	client, err := net.Dial("unixgram", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// Datagrams sent before the listener asked for credentials have none, so
+	// send until one arrives with ours.
+	uid := strconv.Itoa(os.Getuid())
+	expected := map[string]string{"client_uid": uid, "client_pid": strconv.Itoa(os.Getpid())}
+	timeout := time.After(5 * time.Second)
+	for labeled := false; !labeled; {
+		if _, err := client.Write([]byte("foo:1|c")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case e := <-events:
+			labeled = reflect.DeepEqual(e[0].Labels(), expected)
+		case <-timeout:
+			t.Fatalf("expected events labeled with %v", expected)
+		}
+	}
+
+	if _, err := client.Write([]byte("foo:1|c\nbar:1|c")); err != nil {
+		t.Fatal(err)
+	}
+	for received := 0; received < 2; {
+		select {
+		case e := <-events:
+			for _, e := range e {
+				if !reflect.DeepEqual(e.Labels(), expected) {
+					t.Errorf("expected labels %v, got %v", expected, e.Labels())
+				}
+				received++
+			}
+		case <-timeout:
+			t.Fatalf("expected 2 events, got %d", received)
+		}
+	}
+	if v := testutil.ToFloat64(linesByUID.WithLabelValues(uid)); v < 3 {
+		t.Errorf("expected at least 3 lines of uid %s, got %v", uid, v)
+	}
+}
+
+// testCertificate issues a certificate for the template, signed by the parent
+// and its key, or self-signed if parent is nil.
+func testCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestTCPTLSIdentity(t *testing.T) {
+	ca, caKey := testCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	server, serverKey := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	client, clientKey := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "team_a"},
+		DNSNames:     []string{"a.example.com"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	conn, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	events := make(chan event.Events, 32)
+	handshakeErrors := prometheus.NewCounter(prometheus.CounterOpts{Name: "handshake_errors"})
+	l := &listener.StatsDTCPListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      parser,
+		LinesReceived:   linesReceived,
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: *samplesReceived,
+		TagErrors:       tagErrors,
+		TagsReceived:    tagsReceived,
+		TCPConnections:  tcpConnections,
+		TCPErrors:       tcpErrors,
+		TCPLineTooLong:  tcpLineTooLong,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		},
+		TLSHandshakeErrors: handshakeErrors,
+		IdentityLabels:     map[string]string{"client": "cn", "client_dns": "dns"},
+		IdentityPrefix:     "cn",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Listen(ctx)
+
+	dial := func(certs []tls.Certificate) (*tls.Conn, error) {
+		return tls.Dial("tcp", conn.Addr().String(), &tls.Config{RootCAs: pool, Certificates: certs})
+	}
+
+	c, err := dial([]tls.Certificate{{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The client cannot forge its identity with tags.
+	if _, err := c.Write([]byte("foo:1|c|#client:team_b\n")); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	select {
+	case e := <-events:
+		if name := e[0].MetricName(); name != "team_a.foo" {
+			t.Errorf("expected metric name team_a.foo, got %s", name)
+		}
+		expected := map[string]string{"client": "team_a", "client_dns": "a.example.com"}
+		if !reflect.DeepEqual(e[0].Labels(), expected) {
+			t.Errorf("expected labels %v, got %v", expected, e[0].Labels())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event")
+	}
+
+	// A client without a certificate fails the handshake on the server, which
+	// it only notices when reading.
+	c, err = dial(nil)
+	if err == nil {
+		c.Write([]byte("foo:1|c\n"))
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = c.Read(make([]byte, 1))
+		c.Close()
+	}
+	if err == nil {
+		t.Fatal("expected the connection without a client certificate to fail")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(handshakeErrors) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 1 handshake error, got %v", testutil.ToFloat64(handshakeErrors))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("expected no events from a client without a certificate, got %v", e)
+	default:
+	}
+}
+
+func TestSocketTuning(t *testing.T) {
+	conn, err := listener.ListenUDP(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, listener.ReusePortSupported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	granted, err := listener.SetReadBuffer(conn, 64*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if granted <= 0 {
+		t.Errorf("expected a positive read buffer size, got %d", granted)
+	}
+
+	if !listener.ReusePortSupported {
+		if _, err := listener.ListenUDP(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, true); err == nil {
+			t.Fatal("expected SO_REUSEPORT to fail where it is not supported")
+		}
+		return
+	}
+	// A second socket can share the address.
+	shared, err := listener.ListenUDP(conn.LocalAddr().(*net.UDPAddr), true)
+	if err != nil {
+		t.Fatalf("expected to share the address with SO_REUSEPORT: %v", err)
+	}
+	shared.Close()
+}
+
+func TestFirehoseListener(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.UnixMilli(1700000000123)}
+	defer func() { clock.ClockInstance = nil }()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"code"})
+	records := prometheus.NewCounter(prometheus.CounterOpts{Name: "records_total"})
+	events := make(chan event.Events, 32)
+	l := &listener.StatsDFirehoseListener{
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   linesReceived,
+		EventsFlushed:   eventsFlushed,
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: *samplesReceived,
+		TagErrors:       tagErrors,
+		TagsReceived:    tagsReceived,
+		AccessKey:       "secret",
+		Requests:        requests,
+		Records:         records,
+	}
+
+	deliver := func(body string, key string, gzipped bool) (int, map[string]any) {
+		t.Helper()
+		var buf bytes.Buffer
+		if gzipped {
+			gz := gzip.NewWriter(&buf)
+			gz.Write([]byte(body))
+			gz.Close()
+		} else {
+			buf.WriteString(body)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", &buf)
+		req.Header.Set("X-Amz-Firehose-Request-Id", "req-1")
+		req.Header.Set("X-Amz-Firehose-Access-Key", key)
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, req)
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+		}
+		if resp["requestId"] != "req-1" || resp["timestamp"] != float64(1700000000123) {
+			t.Fatalf("expected the request ID and timestamp in the response, got %v", resp)
+		}
+		return w.Code, resp
+	}
+	received := func() []string {
+		var names []string
+		for len(events) > 0 {
+			for _, e := range <-events {
+				names = append(names, e.MetricName())
+			}
+		}
+		return names
+	}
+
+	// The data of the records is base64 encoded.
+	body := `{"requestId":"req-1","timestamp":1700000000000,"records":[{"data":"Zm9vOjF8YwpiYXI6MnxnCg=="},{"data":"YmF6OjN8bXM="}]}`
+	for _, gzipped := range []bool{false, true} {
+		code, resp := deliver(body, "secret", gzipped)
+		if code != http.StatusOK {
+			t.Fatalf("expected the delivery to be acknowledged, got %d: %v", code, resp)
+		}
+		if _, ok := resp["errorMessage"]; ok {
+			t.Fatalf("expected no error message, got %v", resp)
+		}
+		if names := received(); !reflect.DeepEqual(names, []string{"foo", "bar", "baz"}) {
+			t.Fatalf("expected the lines of all records, got %v", names)
+		}
+	}
+
+	for _, c := range []struct {
+		body, key string
+		code      int
+		message   string
+	}{
+		{body: body, key: "wrong", code: http.StatusUnauthorized, message: "invalid access key"},
+		{body: `{"records":[{"data":"not base64"}]}`, key: "secret", code: http.StatusBadRequest, message: "invalid request body"},
+	} {
+		code, resp := deliver(c.body, c.key, false)
+		if code != c.code || !strings.Contains(fmt.Sprint(resp["errorMessage"]), c.message) {
+			t.Fatalf("expected %d with %q, got %d: %v", c.code, c.message, code, resp)
+		}
+		if names := received(); len(names) != 0 {
+			t.Fatalf("expected no lines of a rejected delivery, got %v", names)
+		}
+	}
+
+	if v := testutil.ToFloat64(records); v != 4 {
+		t.Fatalf("expected 4 records, got %v", v)
+	}
+	for code, expected := range map[string]float64{"200": 2, "401": 1, "400": 1} {
+		if v := testutil.ToFloat64(requests.WithLabelValues(code)); v != expected {
+			t.Errorf("expected %v requests with status %s, got %v", expected, code, v)
+		}
+	}
+}
+
+// TestListenerShutdown checks that cancelling the context stops the listeners,
+// including the connections they accepted, and the event loop.
+func TestListenerShutdown(t *testing.T) {
+	uconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tconn, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan event.Events, 32)
+	eventHandler := &event.UnbufferedEventHandler{C: events}
+	udp := &listener.StatsDUDPListener{
+		Conn:            uconn,
+		EventHandler:    eventHandler,
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UDPPackets:      udpPackets,
+		UDPPacketDrops:  udpPacketDrops,
+		LinesReceived:   linesReceived,
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: *samplesReceived,
+		TagErrors:       tagErrors,
+		TagsReceived:    tagsReceived,
+		UdpPacketQueue:  make(chan listener.UDPPacket, 1),
+	}
+	tcp := &listener.StatsDTCPListener{
+		Conn:            tconn,
+		EventHandler:    eventHandler,
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   linesReceived,
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: *samplesReceived,
+		TagErrors:       tagErrors,
+		TagsReceived:    tagsReceived,
+		TCPConnections:  tcpConnections,
+		TCPErrors:       tcpErrors,
+		TCPLineTooLong:  tcpLineTooLong,
+	}
+	ex := exporter.NewExporter(prometheus.NewRegistry(), &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() { errs <- udp.Listen(ctx) }()
+	go func() { errs <- tcp.Listen(ctx) }()
+	exporterStopped := make(chan struct{})
+	go func() {
+		ex.Listen(ctx, events)
+		close(exporterStopped)
+	}()
+
+	client, err := net.DialTCP("tcp4", nil, tconn.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("foo:1|c\n")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; testutil.ToFloat64(tcpConnections) == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Errorf("Expected listener to stop without error, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Listener did not stop")
+		}
+	}
+	select {
+	case <-exporterStopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exporter did not stop")
+	}
+
+	// The server side of the connection is closed, so reading fails before
+	// the deadline.
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
 type statsDPacketHandler interface {
 	HandlePacket(packet []byte)
 	SetEventHandler(eh event.EventHandler)
@@ -712,7 +1444,7 @@ mappings:
 	defer close(events)
 	go func() {
 		ex := exporter.NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-		ex.Listen(events)
+		ex.Listen(context.Background(), events)
 	}()
 
 	ev := event.Events{
@@ -0,0 +1,201 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// valueKind selects which value of a metric an expectation checks.
+type valueKind int
+
+const (
+	// kindValue is the value of a counter, gauge or untyped metric.
+	kindValue valueKind = iota
+	// kindCount is the sample count of a summary or histogram.
+	kindCount
+	// kindSum is the sample sum of a summary or histogram.
+	kindSum
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindCount:
+		return "count"
+	case kindSum:
+		return "sum"
+	default:
+		return "value"
+	}
+}
+
+// expectation is the value of one time series that the exporter is expected
+// to expose. A time series matches if it has all of the given labels; further
+// labels, such as those added by the exporter's configuration, are ignored.
+type expectation struct {
+	name   string
+	labels prometheus.Labels
+	kind   valueKind
+	value  float64
+}
+
+func (e expectation) String() string {
+	names := make([]string, 0, len(e.labels))
+	for name := range e.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := e.name + "{"
+	for i, name := range names {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf("%s=%q", name, e.labels[name])
+	}
+	return s + "} " + e.kind.String()
+}
+
+// check returns an error if the expected time series is missing from the
+// metric families or has a different value.
+func (e expectation) check(families map[string]*dto.MetricFamily) error {
+	family, ok := families[e.name]
+	if !ok {
+		return fmt.Errorf("%s: metric not found", e)
+	}
+	for _, m := range family.GetMetric() {
+		if !hasLabels(m, e.labels) {
+			continue
+		}
+		value, ok := metricValue(m, e.kind)
+		if !ok {
+			return fmt.Errorf("%s: metric has type %s", e, family.GetType())
+		}
+		if !approxEqual(value, e.value) {
+			return fmt.Errorf("%s: expected %v, got %v", e, e.value, value)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: no time series with matching labels", e)
+}
+
+// checkExpectations gathers the metrics once and checks all expectations
+// against them.
+func checkExpectations(g prometheus.Gatherer, expectations []expectation) ([]error, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	var errs []error
+	for _, e := range expectations {
+		if err := e.check(families); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs, nil
+}
+
+func hasLabels(m *dto.Metric, labels prometheus.Labels) bool {
+	found := 0
+	for _, pair := range m.GetLabel() {
+		if value, ok := labels[pair.GetName()]; ok {
+			if value != pair.GetValue() {
+				return false
+			}
+			found++
+		}
+	}
+	return found == len(labels)
+}
+
+func metricValue(m *dto.Metric, kind valueKind) (float64, bool) {
+	switch kind {
+	case kindCount:
+		if m.Summary != nil {
+			return float64(m.Summary.GetSampleCount()), true
+		}
+		if m.Histogram != nil {
+			return float64(m.Histogram.GetSampleCount()), true
+		}
+	case kindSum:
+		if m.Summary != nil {
+			return m.Summary.GetSampleSum(), true
+		}
+		if m.Histogram != nil {
+			return m.Histogram.GetSampleSum(), true
+		}
+	default:
+		if m.Counter != nil {
+			return m.Counter.GetValue(), true
+		}
+		if m.Gauge != nil {
+			return m.Gauge.GetValue(), true
+		}
+		if m.Untyped != nil {
+			return m.Untyped.GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+// approxEqual compares values that went through float arithmetic, such as
+// timer conversions and sums.
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// scrapeGatherer gathers the metrics of a running exporter from its metrics
+// endpoint.
+type scrapeGatherer struct {
+	client *http.Client
+	url    string
+}
+
+func (s *scrapeGatherer) Gather() ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping %s: unexpected status %s", s.url, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics from %s: %w", s.url, err)
+	}
+	mfs := make([]*dto.MetricFamily, 0, len(families))
+	for _, mf := range families {
+		mfs = append(mfs, mf)
+	}
+	return mfs, nil
+}
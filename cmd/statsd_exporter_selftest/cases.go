@@ -0,0 +1,147 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testCase is a set of lines, as a client library using one format would send
+// them, and the metrics they should result in.
+type testCase struct {
+	format       string
+	lines        []string
+	expectations []expectation
+}
+
+// tagFormat renders a metric name with tags and a sample in one of the
+// supported tagging styles.
+type tagFormat func(name string, tags [][2]string, sample string) string
+
+func joinTags(tags [][2]string, separator string) string {
+	s := ""
+	for i, tag := range tags {
+		if i > 0 {
+			s += ","
+		}
+		s += tag[0] + separator + tag[1]
+	}
+	return s
+}
+
+var tagFormats = map[string]tagFormat{
+	"statsd": func(name string, _ [][2]string, sample string) string {
+		return name + ":" + sample
+	},
+	"dogstatsd": func(name string, tags [][2]string, sample string) string {
+		return name + ":" + sample + "|#" + joinTags(tags, ":")
+	},
+	"influxdb": func(name string, tags [][2]string, sample string) string {
+		return name + "," + joinTags(tags, "=") + ":" + sample
+	},
+	"librato": func(name string, tags [][2]string, sample string) string {
+		return name + "#" + joinTags(tags, "=") + ":" + sample
+	},
+	"signalfx": func(name string, tags [][2]string, sample string) string {
+		// SignalFx tags can be anywhere in the name, as in foo.[tag=value]bar.
+		i := strings.LastIndex(name, ".") + 1
+		return name[:i] + "[" + joinTags(tags, "=") + "]" + name[i:] + ":" + sample
+	},
+}
+
+// testCases returns the test cases for the given formats. The run ID is part
+// of every metric name, so that repeated runs against the same exporter do not
+// see each other's metrics.
+func testCases(runID string, formats []string) ([]testCase, error) {
+	var cases []testCase
+	for _, format := range formats {
+		c, err := newTestCase(runID, format)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+func newTestCase(runID, format string) (testCase, error) {
+	prefix := fmt.Sprintf("selftest.%s.%s.", runID, format)
+	promPrefix := fmt.Sprintf("selftest_%s_%s_", runID, format)
+
+	tags := [][2]string{{"client", format}, {"env", "selftest"}}
+	labels := prometheus.Labels{"client": format, "env": "selftest"}
+
+	tf, ok := tagFormats[format]
+	if format == "dogstatsd_packed" {
+		tf, ok = tagFormats["dogstatsd"], true
+	}
+	if !ok {
+		return testCase{}, fmt.Errorf("unknown format %q", format)
+	}
+	if format == "statsd" {
+		labels = prometheus.Labels{}
+	}
+
+	c := testCase{format: format}
+	if format == "dogstatsd_packed" {
+		// DogStatsD clients may pack several values into one line.
+		c.lines = []string{
+			tf(prefix+"requests", tags, "1:4|c"),
+			tf(prefix+"temperature", tags, "10:+5|g"),
+			tf(prefix+"latency", tags, "200:300|ms"),
+			tf(prefix+"size", tags, "2:3|h"),
+			tf(prefix+"payload", tags, "2:3|d"),
+		}
+	} else {
+		c.lines = []string{
+			tf(prefix+"requests", tags, "1|c"),
+			tf(prefix+"requests", tags, "2|c|@0.5"),
+			tf(prefix+"temperature", tags, "10|g"),
+			tf(prefix+"temperature", tags, "+5|g"),
+			tf(prefix+"latency", tags, "200|ms"),
+			tf(prefix+"latency", tags, "300|ms"),
+			tf(prefix+"size", tags, "2|h"),
+			tf(prefix+"size", tags, "3|h"),
+		}
+		if format == "dogstatsd" {
+			c.lines = append(c.lines,
+				tf(prefix+"payload", tags, "2|d"),
+				tf(prefix+"payload", tags, "3|d"),
+			)
+		}
+	}
+
+	c.expectations = []expectation{
+		{name: promPrefix + "requests", labels: labels, kind: kindValue, value: 5},
+		{name: promPrefix + "temperature", labels: labels, kind: kindValue, value: 15},
+		// Timers are converted from milliseconds to seconds.
+		{name: promPrefix + "latency", labels: labels, kind: kindCount, value: 2},
+		{name: promPrefix + "latency", labels: labels, kind: kindSum, value: 0.5},
+		{name: promPrefix + "size", labels: labels, kind: kindCount, value: 2},
+		{name: promPrefix + "size", labels: labels, kind: kindSum, value: 5},
+	}
+	if format == "dogstatsd" || format == "dogstatsd_packed" {
+		c.expectations = append(c.expectations,
+			expectation{name: promPrefix + "payload", labels: labels, kind: kindCount, value: 2},
+			expectation{name: promPrefix + "payload", labels: labels, kind: kindSum, value: 5},
+		)
+	}
+	return c, nil
+}
+
+// allFormats are the formats that are tested by default.
+var allFormats = []string{"statsd", "dogstatsd", "dogstatsd_packed", "influxdb", "librato", "signalfx"}
@@ -0,0 +1,125 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// statsd_exporter_selftest sends lines in the formats of common StatsD client
+// libraries to a running statsd_exporter and verifies the metrics it exposes.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+	"github.com/prometheus/common/promslog/flag"
+	"github.com/prometheus/common/version"
+)
+
+// send writes the lines of a test case to the exporter. All lines go into one
+// packet or write, so that they arrive in order.
+func send(protocol, address string, lines []string) error {
+	conn, err := net.Dial(protocol, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	return err
+}
+
+// verify checks the expectations of all test cases until they are met or the
+// timeout expires. It returns the remaining errors per format.
+func verify(g prometheus.Gatherer, cases []testCase, timeout, interval time.Duration, logger *slog.Logger) map[string][]error {
+	deadline := time.Now().Add(timeout)
+	for {
+		failures := map[string][]error{}
+		for _, c := range cases {
+			errs, err := checkExpectations(g, c.expectations)
+			if err != nil {
+				errs = []error{err}
+			}
+			if len(errs) > 0 {
+				failures[c.format] = errs
+			}
+		}
+		if len(failures) == 0 || time.Now().After(deadline) {
+			return failures
+		}
+		logger.Debug("Expectations not met yet, retrying", "failing_formats", len(failures))
+		time.Sleep(interval)
+	}
+}
+
+func main() {
+	var (
+		statsdAddress = kingpin.Flag("statsd.address", "The address of the exporter's StatsD listener.").Default("localhost:9125").String()
+		protocol      = kingpin.Flag("statsd.protocol", "The protocol to send lines with. Valid options are \"udp\" and \"tcp\".").Default("udp").Enum("udp", "tcp")
+		metricsURL    = kingpin.Flag("metrics.url", "The URL of the exporter's metrics endpoint.").Default("http://localhost:9102/metrics").String()
+		formats       = kingpin.Flag("format", "Client format to test. Can be repeated. Valid options are \""+strings.Join(allFormats, "\", \"")+"\". Defaults to all of them.").Enums(allFormats...)
+		timeout       = kingpin.Flag("timeout", "How long to wait for the metrics to show up.").Default("10s").Duration()
+		runID         = kingpin.Flag("run-id", "Identifier that is part of all metric names. Defaults to a value derived from the current time.").Default("").String()
+	)
+
+	promslogConfig := &promslog.Config{}
+	flag.AddFlags(kingpin.CommandLine, promslogConfig)
+	kingpin.Version(version.Print("statsd_exporter_selftest"))
+	kingpin.HelpFlag.Short('h')
+	kingpin.Parse()
+	logger := promslog.New(promslogConfig)
+
+	if *runID == "" {
+		*runID = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	if len(*formats) == 0 {
+		*formats = allFormats
+	}
+
+	cases, err := testCases(*runID, *formats)
+	if err != nil {
+		logger.Error("Invalid test cases", "error", err)
+		os.Exit(1)
+	}
+
+	for _, c := range cases {
+		logger.Debug("Sending lines", "format", c.format, "lines", strings.Join(c.lines, "\n"))
+		if err := send(*protocol, *statsdAddress, c.lines); err != nil {
+			logger.Error("Error sending lines", "format", c.format, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	g := &scrapeGatherer{client: &http.Client{Timeout: 5 * time.Second}, url: *metricsURL}
+	failures := verify(g, cases, *timeout, 250*time.Millisecond, logger)
+
+	for _, c := range cases {
+		errs, failed := failures[c.format]
+		if !failed {
+			fmt.Printf("ok   %s\n", c.format)
+			continue
+		}
+		fmt.Printf("FAIL %s\n", c.format)
+		for _, err := range errs {
+			fmt.Printf("     %v\n", err)
+		}
+	}
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+}
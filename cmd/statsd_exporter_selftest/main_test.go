@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+// runExporter feeds the lines of the test cases through the line parser and
+// exporter, and returns the registry with the resulting metrics.
+func runExporter(t *testing.T, cases []testCase) *prometheus.Registry {
+	t.Helper()
+
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	parser.EnableLibratoParsing()
+	parser.EnableSignalFXParsing()
+
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "counter"})
+	counterVec := func(label string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "counter"}, []string{label})
+	}
+	sampleErrors, samplesReceived := counterVec("reason"), counterVec("type")
+
+	reg := prometheus.NewRegistry()
+	ex := exporter.NewExporter(reg, &mapper.MetricMapper{}, promslog.NewNopLogger(),
+		counterVec("action"), counter, counterVec("type"), counterVec("type"), counterVec("type"),
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "gauge"}, []string{"type"}))
+
+	events := make(chan event.Events)
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(context.Background(), events)
+		close(done)
+	}()
+	for _, c := range cases {
+		for _, l := range c.lines {
+			events <- parser.LineToEvents(l, *sampleErrors, *samplesReceived, counter, counter, promslog.NewNopLogger())
+		}
+	}
+	close(events)
+	<-done
+	return reg
+}
+
+func TestTestCases(t *testing.T) {
+	cases, err := testCases("test", allFormats)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reg := runExporter(t, cases)
+
+	for _, c := range cases {
+		errs, err := checkExpectations(reg, c.expectations)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, err := range errs {
+			t.Errorf("%s: %v", c.format, err)
+		}
+	}
+}
+
+func TestTestCasesUnknownFormat(t *testing.T) {
+	if _, err := testCases("test", []string{"carbon"}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	cases, _ := testCases("test", []string{"dogstatsd"})
+	reg := runExporter(t, cases)
+
+	cases[0].expectations = []expectation{
+		{name: "selftest_test_dogstatsd_requests", labels: prometheus.Labels{"client": "dogstatsd"}, kind: kindValue, value: 4},
+		{name: "selftest_test_dogstatsd_requests", labels: prometheus.Labels{"client": "librato"}, kind: kindValue, value: 5},
+		{name: "selftest_test_dogstatsd_requests", kind: kindCount, value: 5},
+		{name: "selftest_test_missing", kind: kindValue, value: 5},
+	}
+	failures := verify(reg, cases, 0, 0, promslog.NewNopLogger())
+	errs := failures["dogstatsd"]
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 failed expectations, got %v", errs)
+	}
+	for i, want := range []string{"expected 4, got 5", "no time series with matching labels", "metric has type COUNTER", "metric not found"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("expected error %q to contain %q", errs[i], want)
+		}
+	}
+}
+
+func TestScrapeGatherer(t *testing.T) {
+	cases, _ := testCases("test", allFormats)
+	reg := runExporter(t, cases)
+
+	server := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	g := &scrapeGatherer{client: &http.Client{Timeout: 5 * time.Second}, url: server.URL}
+	failures := verify(g, cases, 0, 0, promslog.NewNopLogger())
+	for format, errs := range failures {
+		t.Errorf("%s: %v", format, errs)
+	}
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	g.url = notFound.URL
+	if _, err := g.Gather(); err == nil {
+		t.Error("expected error for a failed scrape")
+	}
+}
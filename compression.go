@@ -0,0 +1,183 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The content encodings the metrics endpoint can compress responses with.
+const (
+	encodingIdentity = "identity"
+	encodingGzip     = "gzip"
+	encodingZstd     = "zstd"
+)
+
+var (
+	gzipWriters = sync.Pool{New: func() any {
+		return gzip.NewWriter(nil)
+	}}
+	zstdWriters = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// parseEncodings parses a comma-separated list of content encodings, in order
+// of preference.
+func parseEncodings(s string) ([]string, error) {
+	var encodings []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		switch e {
+		case "":
+			continue
+		case encodingGzip, encodingZstd:
+			encodings = append(encodings, e)
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q, must be %s or %s", e, encodingGzip, encodingZstd)
+		}
+	}
+	return encodings, nil
+}
+
+// negotiateEncoding returns the first of the offered encodings that the
+// Accept-Encoding header accepts, or identity.
+func negotiateEncoding(acceptEncoding string, offered []string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		accepted[name] = q > 0
+	}
+	for _, e := range offered {
+		if ok, listed := accepted[e]; ok || (!listed && accepted["*"]) {
+			return e
+		}
+	}
+	return encodingIdentity
+}
+
+// metricsEncodingHandler compresses the responses of the metrics endpoint
+// with the content encoding negotiated with the client, as they are encoded,
+// instead of buffering the whole payload. Writes are collected into chunks of
+// chunkSize before they are sent, so that large payloads take fewer writes.
+type metricsEncodingHandler struct {
+	next      http.Handler
+	encodings []string
+	chunkSize int
+	// sizes observes the size of the responses as sent, and durations the
+	// time from the first encoded byte until the response is complete, by
+	// content encoding.
+	sizes     *prometheus.HistogramVec
+	durations *prometheus.HistogramVec
+}
+
+func newMetricsEncodingHandler(next http.Handler, encodings []string, chunkSize int, sizes, durations *prometheus.HistogramVec) *metricsEncodingHandler {
+	return &metricsEncodingHandler{
+		next:      next,
+		encodings: slices.Clone(encodings),
+		chunkSize: chunkSize,
+		sizes:     sizes,
+		durations: durations,
+	}
+}
+
+func (h *metricsEncodingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), h.encodings)
+	// The wrapped handler must not compress on its own.
+	r = r.Clone(r.Context())
+	r.Header.Del("Accept-Encoding")
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if encoding != encodingIdentity {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	counter := &countingWriter{w: w}
+	chunks := bufio.NewWriterSize(counter, h.chunkSize)
+	var out io.Writer = chunks
+	var compressor io.WriteCloser
+	switch encoding {
+	case encodingGzip:
+		gz := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(gz)
+		gz.Reset(chunks)
+		compressor, out = gz, gz
+	case encodingZstd:
+		zw := zstdWriters.Get().(*zstd.Encoder)
+		defer zstdWriters.Put(zw)
+		zw.Reset(chunks)
+		compressor, out = zw, zw
+	}
+
+	ew := &encodingWriter{ResponseWriter: w, out: out}
+	h.next.ServeHTTP(ew, r)
+	if compressor != nil {
+		compressor.Close()
+	}
+	chunks.Flush()
+
+	if ew.started.IsZero() {
+		// Nothing was encoded.
+		return
+	}
+	h.sizes.WithLabelValues(encoding).Observe(float64(counter.n))
+	h.durations.WithLabelValues(encoding).Observe(time.Since(ew.started).Seconds())
+}
+
+// encodingWriter passes the body written by the metrics handler on to the
+// compressor, and records when the first byte was written.
+type encodingWriter struct {
+	http.ResponseWriter
+	out     io.Writer
+	started time.Time
+}
+
+func (w *encodingWriter) Write(p []byte) (int, error) {
+	if w.started.IsZero() {
+		w.started = time.Now()
+	}
+	return w.out.Write(p)
+}
+
+// countingWriter counts the bytes written to a writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
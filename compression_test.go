@@ -0,0 +1,129 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{encodingZstd, encodingGzip}
+	for acceptEncoding, expected := range map[string]string{
+		"":                      encodingIdentity,
+		"gzip":                  encodingGzip,
+		"gzip, zstd":            encodingZstd,
+		"zstd;q=0, gzip;q=0.5":  encodingGzip,
+		"*":                     encodingZstd,
+		"*, zstd;q=0":           encodingGzip,
+		"br, deflate":           encodingIdentity,
+		"GZIP":                  encodingGzip,
+		"identity, gzip;q=0.1x": encodingGzip,
+	} {
+		if encoding := negotiateEncoding(acceptEncoding, offered); encoding != expected {
+			t.Errorf("%q: expected %s, got %s", acceptEncoding, expected, encoding)
+		}
+	}
+	if encoding := negotiateEncoding("gzip, zstd", nil); encoding != encodingIdentity {
+		t.Errorf("expected no compression without offered encodings, got %s", encoding)
+	}
+}
+
+func TestParseEncodings(t *testing.T) {
+	encodings, err := parseEncodings(" gzip, zstd ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(encodings) != "[gzip zstd]" {
+		t.Errorf("expected gzip and zstd, got %v", encodings)
+	}
+	if encodings, err := parseEncodings(""); err != nil || len(encodings) != 0 {
+		t.Errorf("expected no encodings, got %v, %v", encodings, err)
+	}
+	if _, err := parseEncodings("gzip,br"); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+func TestMetricsEncodingHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo_total", Help: "Foo."}, []string{"bar"})
+	reg.MustRegister(counter)
+	for i := 0; i < 1000; i++ {
+		counter.WithLabelValues(fmt.Sprint(i)).Inc()
+	}
+
+	sizes := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "sizes"}, []string{"encoding"})
+	durations := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "durations"}, []string{"encoding"})
+	h := newMetricsEncodingHandler(newMetricsHandler(prometheus.NewRegistry(), reg, nil, false, promslog.NewNopLogger()), []string{encodingZstd, encodingGzip}, 4096, sizes, durations)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		encodingIdentity: func(r io.Reader) (io.Reader, error) { return r, nil },
+		encodingGzip:     func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		encodingZstd:     func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	var identitySize int
+	for _, s := range []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{"", encodingIdentity},
+		{"gzip", encodingGzip},
+		{"gzip, zstd", encodingZstd},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept-Encoding", s.acceptEncoding)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", s.encoding, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != s.encoding && (got != "" || s.encoding != encodingIdentity) {
+			t.Errorf("%s: unexpected content encoding %q", s.encoding, got)
+		}
+		size := rec.Body.Len()
+		r, err := decoders[s.encoding](rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", s.encoding, err)
+		}
+		if !strings.Contains(string(body), `foo_total{bar="999"} 1`) {
+			t.Errorf("%s: expected all series, got:\n%s", s.encoding, body)
+		}
+
+		if s.encoding == encodingIdentity {
+			identitySize = size
+		} else if size >= identitySize {
+			t.Errorf("%s: expected the response to be compressed, got %d bytes for %d", s.encoding, size, identitySize)
+		}
+	}
+	for name, h := range map[string]*prometheus.HistogramVec{"sizes": sizes, "durations": durations} {
+		if n := testutil.CollectAndCount(h); n != 3 {
+			t.Errorf("expected %s for 3 encodings, got %d", name, n)
+		}
+	}
+}
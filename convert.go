@@ -0,0 +1,406 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+// Formats the convert command reads.
+const (
+	convertTelegrafTemplate = "telegraf-template"
+	convertDatadog          = "datadog"
+)
+
+// convertConfig holds the flags of the convert command.
+type convertConfig struct {
+	cmd  *kingpin.CmdClause
+	from *string
+	file *string
+}
+
+// addConvertCommand adds the convert command, which translates the mapping
+// configurations of other tools into a mapping configuration.
+func addConvertCommand(app *kingpin.Application) *convertConfig {
+	cmd := app.Command("convert", "Convert Telegraf graphite templates or Datadog dogstatsd_mapper_profiles into a mapping configuration, written to standard output.")
+	return &convertConfig{
+		cmd:  cmd,
+		from: cmd.Flag("from", "The format of the file to convert.").Required().Enum(convertTelegrafTemplate, convertDatadog),
+		file: cmd.Arg("file", "The file to convert: Telegraf templates, one per line or as the templates setting of a Telegraf configuration, or a Datadog Agent configuration with dogstatsd_mapper_profiles.").Required().String(),
+	}
+}
+
+// convertedMapping is a mapping as written by the convert command.
+type convertedMapping struct {
+	Match     string            `yaml:"match"`
+	MatchType string            `yaml:"match_type,omitempty"`
+	Name      string            `yaml:"name"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+func (c *convertConfig) run(out io.Writer, logger *slog.Logger) int {
+	data, err := os.ReadFile(*c.file)
+	if err != nil {
+		logger.Error("Unable to read the file to convert", "file", *c.file, "error", err)
+		return 1
+	}
+
+	var mappings []convertedMapping
+	var warnings []string
+	switch *c.from {
+	case convertTelegrafTemplate:
+		mappings, warnings, err = convertTelegrafTemplates(data)
+	case convertDatadog:
+		mappings, warnings, err = convertDatadogProfiles(data)
+	}
+	if err != nil {
+		logger.Error("Unable to convert", "file", *c.file, "error", err)
+		return 1
+	}
+	for _, w := range warnings {
+		logger.Warn("Not converted", "reason", w)
+	}
+
+	config, err := marshalConvertedMappings(mappings)
+	if err != nil {
+		logger.Error("Unable to convert", "file", *c.file, "error", err)
+		return 1
+	}
+	fmt.Fprintf(out, "# Converted from %s %s.\n", *c.from, *c.file)
+	out.Write(config)
+	return 0
+}
+
+// marshalConvertedMappings returns the mapping configuration, after checking
+// that the exporter accepts it.
+func marshalConvertedMappings(mappings []convertedMapping) ([]byte, error) {
+	config, err := yaml.Marshal(struct {
+		Mappings []convertedMapping `yaml:"mappings"`
+	}{mappings})
+	if err != nil {
+		return nil, err
+	}
+	m := &mapper.MetricMapper{}
+	if err := m.InitFromYAMLString(string(config)); err != nil {
+		return nil, fmt.Errorf("the converted configuration is invalid: %w", err)
+	}
+	return config, nil
+}
+
+var (
+	// telegrafTemplatesRE finds the templates setting of a Telegraf
+	// configuration, and telegrafStringRE the strings in it.
+	telegrafTemplatesRE = regexp.MustCompile(`(?s)\btemplates\s*=\s*\[(.*?)\]`)
+	telegrafStringRE    = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'[^']*'`)
+)
+
+// convertTelegrafTemplates converts Telegraf graphite templates, given either
+// as the templates setting of a Telegraf configuration or one per line.
+func convertTelegrafTemplates(data []byte) ([]convertedMapping, []string, error) {
+	var templates []string
+	if m := telegrafTemplatesRE.FindSubmatch(data); m != nil {
+		for _, q := range telegrafStringRE.FindAll(m[1], -1) {
+			t := string(q[1 : len(q)-1])
+			if q[0] == '"' {
+				var err error
+				if t, err = strconv.Unquote(string(q)); err != nil {
+					return nil, nil, fmt.Errorf("invalid template %s: %w", q, err)
+				}
+			}
+			templates = append(templates, t)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if l := strings.TrimSpace(scanner.Text()); l != "" && l[0] != '#' {
+				templates = append(templates, l)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var parsed []*telegrafTemplate
+	var warnings []string
+	for _, t := range templates {
+		tt, err := parseTelegrafTemplate(t)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("template %q: %v", t, err))
+			continue
+		}
+		parsed = append(parsed, tt)
+	}
+	// Telegraf applies the template with the most specific filter, while
+	// the exporter applies the first matching mapping.
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return parsed[i].moreSpecific(parsed[j])
+	})
+
+	mappings := make([]convertedMapping, 0, len(parsed))
+	for _, t := range parsed {
+		mappings = append(mappings, t.mapping())
+	}
+	return mappings, warnings, nil
+}
+
+// telegrafTemplate is a Telegraf graphite template: an optional filter of
+// the metrics it applies to, the roles of the parts of their names, and
+// optional static tags.
+type telegrafTemplate struct {
+	filter []string
+	parts  []string
+	tags   map[string]string
+}
+
+func parseTelegrafTemplate(s string) (*telegrafTemplate, error) {
+	fields := strings.Fields(s)
+	t := &telegrafTemplate{}
+	switch {
+	case len(fields) == 3:
+		t.filter, t.parts = strings.Split(fields[0], "."), strings.Split(fields[1], ".")
+		t.tags = map[string]string{}
+		if err := t.parseTags(fields[2]); err != nil {
+			return nil, err
+		}
+	case len(fields) == 2 && strings.Contains(fields[1], "="):
+		t.parts = strings.Split(fields[0], ".")
+		t.tags = map[string]string{}
+		if err := t.parseTags(fields[1]); err != nil {
+			return nil, err
+		}
+	case len(fields) == 2:
+		t.filter, t.parts = strings.Split(fields[0], "."), strings.Split(fields[1], ".")
+	case len(fields) == 1:
+		t.parts = strings.Split(fields[0], ".")
+	default:
+		return nil, fmt.Errorf("expected an optional filter, a template and optional tags")
+	}
+	for i, p := range t.parts {
+		if strings.HasSuffix(p, "*") && i != len(t.parts)-1 {
+			return nil, fmt.Errorf("%s is only supported in the last part", p)
+		}
+	}
+	return t, nil
+}
+
+func (t *telegrafTemplate) parseTags(s string) error {
+	for _, tag := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		t.tags[convertLabelName(k)] = v
+	}
+	return nil
+}
+
+// specificity returns how specific the filter is for a part of the name: an
+// exact part is more specific than a pattern, which is more specific than no
+// filter.
+func (t *telegrafTemplate) specificity(i int) int {
+	switch {
+	case i >= len(t.filter):
+		return 0
+	case strings.Contains(t.filter[i], "*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (t *telegrafTemplate) moreSpecific(o *telegrafTemplate) bool {
+	for i := 0; i < max(len(t.filter), len(o.filter)); i++ {
+		if a, b := t.specificity(i), o.specificity(i); a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+// mapping returns a regex mapping that matches the names the template
+// applies to, with one group per part of the name. Like Telegraf, it matches
+// names with more parts than the template and ignores those parts, unless
+// the last part of the template is greedy.
+func (t *telegrafTemplate) mapping() convertedMapping {
+	var re strings.Builder
+	re.WriteString("^")
+	var measurement, field []string
+	tags := map[string][]string{}
+	greedy := false
+	for i := 0; i < max(len(t.filter), len(t.parts)); i++ {
+		if i > 0 {
+			re.WriteString(`\.`)
+		}
+		part := ""
+		if i < len(t.parts) {
+			part = t.parts[i]
+		}
+		if strings.HasSuffix(part, "*") {
+			greedy = true
+			re.WriteString("(.+)")
+		} else if i < len(t.filter) && t.filter[i] != "*" {
+			re.WriteString("(" + strings.ReplaceAll(regexp.QuoteMeta(t.filter[i]), `\*`, `[^.]*`) + ")")
+		} else {
+			re.WriteString(`([^.]+)`)
+		}
+		group := fmt.Sprintf("${%d}", i+1)
+		switch strings.TrimSuffix(part, "*") {
+		case "":
+		case "measurement":
+			measurement = append(measurement, group)
+		case "field":
+			field = append(field, group)
+		default:
+			name := convertLabelName(strings.TrimSuffix(part, "*"))
+			tags[name] = append(tags[name], group)
+		}
+	}
+	if !greedy {
+		re.WriteString(`(?:\..*)?`)
+	}
+	re.WriteString("$")
+
+	name := strings.Join(measurement, "_")
+	if name == "" {
+		// Without a measurement, Telegraf uses the whole name.
+		name = "${0}"
+	}
+	if len(field) > 0 {
+		name += "_" + strings.Join(field, "_")
+	}
+	m := convertedMapping{Match: re.String(), MatchType: string(mapper.MatchTypeRegex), Name: name}
+	if len(tags)+len(t.tags) > 0 {
+		m.Labels = map[string]string{}
+		for k, v := range t.tags {
+			m.Labels[k] = v
+		}
+		for k, v := range tags {
+			m.Labels[k] = strings.Join(v, ".")
+		}
+	}
+	return m
+}
+
+// datadogConfig is the part of a Datadog Agent configuration with the
+// DogStatsD mapper profiles.
+type datadogConfig struct {
+	Profiles []struct {
+		Name     string `yaml:"name"`
+		Prefix   string `yaml:"prefix"`
+		Mappings []struct {
+			Match     string            `yaml:"match"`
+			MatchType string            `yaml:"match_type"`
+			Name      string            `yaml:"name"`
+			Tags      map[string]string `yaml:"tags"`
+		} `yaml:"mappings"`
+	} `yaml:"dogstatsd_mapper_profiles"`
+}
+
+// datadogReferenceRE finds the references to groups in Datadog names and
+// tags, which are made explicit for regex mappings.
+var datadogReferenceRE = regexp.MustCompile(`\$(\d+)`)
+
+// convertDatadogProfiles converts the dogstatsd_mapper_profiles of a Datadog
+// Agent configuration. Wildcard matches become glob mappings where possible,
+// and regex mappings otherwise.
+func convertDatadogProfiles(data []byte) ([]convertedMapping, []string, error) {
+	var config datadogConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, err
+	}
+	if len(config.Profiles) == 0 {
+		return nil, nil, fmt.Errorf("no dogstatsd_mapper_profiles found")
+	}
+
+	var mappings []convertedMapping
+	var warnings []string
+	for _, p := range config.Profiles {
+		for _, dm := range p.Mappings {
+			m := convertedMapping{
+				Match: dm.Match,
+				Name:  convertMetricName(datadogReferenceRE.ReplaceAllString(dm.Name, "$${$1}")),
+			}
+			switch dm.MatchType {
+			case "", "wildcard":
+				if !convertGlobRE.MatchString(dm.Match) {
+					// Stars within a part are not supported by globs.
+					m.Match = "^" + strings.ReplaceAll(regexp.QuoteMeta(dm.Match), `\*`, `([^.]+)`) + "$"
+					m.MatchType = string(mapper.MatchTypeRegex)
+				}
+			case "regex":
+				m.Match = "^" + strings.TrimSuffix(strings.TrimPrefix(dm.Match, "^"), "$") + "$"
+				m.MatchType = string(mapper.MatchTypeRegex)
+			default:
+				warnings = append(warnings, fmt.Sprintf("profile %s: mapping %s has unknown match_type %q", p.Name, dm.Match, dm.MatchType))
+				continue
+			}
+			if len(dm.Tags) > 0 {
+				m.Labels = map[string]string{}
+				for k, v := range dm.Tags {
+					m.Labels[convertLabelName(k)] = datadogReferenceRE.ReplaceAllString(v, "$${$1}")
+				}
+			}
+			mappings = append(mappings, m)
+		}
+	}
+	return mappings, warnings, nil
+}
+
+// convertGlobRE matches the wildcard matches that are valid glob matches.
+var convertGlobRE = regexp.MustCompile(`^(\*|[a-zA-Z_][a-zA-Z0-9_\-]*)(\.\*|\.[a-zA-Z0-9_][a-zA-Z0-9_\-]*)*$`)
+
+var (
+	convertInvalidNameRE  = regexp.MustCompile(`\$\{\d+\}|[^a-zA-Z0-9_$]+|\$`)
+	convertInvalidLabelRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// convertMetricName replaces the characters of a name template that are not
+// valid in metric names, such as dots, with underscores.
+func convertMetricName(name string) string {
+	name = convertInvalidNameRE.ReplaceAllStringFunc(name, func(s string) string {
+		if strings.HasPrefix(s, "${") {
+			return s
+		}
+		return "_"
+	})
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// convertLabelName replaces the characters that are not valid in label names
+// with underscores.
+func convertLabelName(name string) string {
+	name = convertInvalidLabelRE.ReplaceAllString(name, "_")
+	if len(name) < 2 || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
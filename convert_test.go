@@ -0,0 +1,135 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+type convertScenario struct {
+	statsdName string
+	name       string
+	labels     prometheus.Labels
+}
+
+// checkConverted loads the converted mappings and checks how they map the
+// scenarios.
+func checkConverted(t *testing.T, mappings []convertedMapping, scenarios []convertScenario) {
+	t.Helper()
+	config, err := marshalConvertedMappings(mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mapper.MetricMapper{}
+	if err := m.InitFromYAMLString(string(config)); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range scenarios {
+		mapping, labels, present := m.GetMapping(s.statsdName, mapper.MetricTypeCounter)
+		if !present {
+			t.Errorf("%s: not mapped by\n%s", s.statsdName, config)
+			continue
+		}
+		if name := m.EscapeMetricName(mapping.Name); name != s.name || !reflect.DeepEqual(labels, s.labels) {
+			t.Errorf("%s: expected %s%v, got %s%v with\n%s", s.statsdName, s.name, s.labels, name, labels, config)
+		}
+	}
+}
+
+func TestConvertTelegrafTemplates(t *testing.T) {
+	config := `
+[[inputs.statsd]]
+  templates = [
+    "cpu.* measurement.host.field",
+    "*.app env.service.resource.measurement",
+    "stats.* .host.measurement* region=us-west",
+    'measurement.measurement.field',
+    "measurement.bad*.field",
+  ]
+`
+	mappings, warnings, err := convertTelegrafTemplates([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected a warning for the unsupported template, got %v", warnings)
+	}
+	checkConverted(t, mappings, []convertScenario{
+		{"cpu.web1.idle", "cpu_idle", prometheus.Labels{"host": "web1"}},
+		{"prod.app.checkout.requests", "requests", prometheus.Labels{"env": "prod", "service": "app", "resource": "checkout"}},
+		{"stats.web1.disk.used.bytes", "disk_used_bytes", prometheus.Labels{"host": "web1", "region": "us-west"}},
+		{"mem.free.bytes.extra", "mem_free_bytes", prometheus.Labels{}},
+	})
+
+	// Templates can also be given one per line.
+	mappings, _, err = convertTelegrafTemplates([]byte("# servers\nservers.* .host.measurement.field\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, mappings, []convertScenario{
+		{"servers.web1.cpu.idle", "cpu_idle", prometheus.Labels{"host": "web1"}},
+	})
+}
+
+func TestConvertDatadogProfiles(t *testing.T) {
+	config := `
+api_key: secret
+dogstatsd_mapper_profiles:
+  - name: airflow
+    prefix: "airflow."
+    mappings:
+      - match: "airflow.job.duration_sec.*.*"
+        name: "airflow.job.duration"
+        tags:
+          job_type: "$1"
+          job_name: "$2"
+      - match: "airflow.*_start"
+        name: "airflow.job.start"
+        tags:
+          job-name: "$1"
+  - name: custom
+    prefix: "custom_metric."
+    mappings:
+      - match: 'custom_metric\.process\.([\w_]+)\.(.+)'
+        match_type: regex
+        name: "custom_metric.process"
+        tags:
+          tag_key_1: "$1"
+          tag_key_2: "$2"
+      - match: "custom_metric.other"
+        match_type: exact
+        name: "custom_metric.other"
+`
+	mappings, warnings, err := convertDatadogProfiles([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected a warning for the unknown match type, got %v", warnings)
+	}
+	checkConverted(t, mappings, []convertScenario{
+		{"airflow.job.duration_sec.local.my_job", "airflow_job_duration", prometheus.Labels{"job_type": "local", "job_name": "my_job"}},
+		{"airflow.scheduler_start", "airflow_job_start", prometheus.Labels{"job_name": "scheduler"}},
+		{"custom_metric.process.value_1.value.with.dots", "custom_metric_process", prometheus.Labels{"tag_key_1": "value_1", "tag_key_2": "value.with.dots"}},
+	})
+
+	if _, _, err := convertDatadogProfiles([]byte("api_key: secret\n")); err == nil {
+		t.Error("Expected an error for a configuration without profiles")
+	}
+}
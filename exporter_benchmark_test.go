@@ -14,33 +14,38 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/promslog"
 
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/event"
 	"github.com/prometheus/statsd_exporter/pkg/exporter"
 	"github.com/prometheus/statsd_exporter/pkg/line"
 	"github.com/prometheus/statsd_exporter/pkg/listener"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
 )
 
+// udpBenchmarkInput is a grab bag of mixed formats, valid and invalid.
+var udpBenchmarkInput = []string{
+	"foo1:2|c",
+	"foo2:3|g",
+	"foo3:200|ms",
+	"foo4:100|c|#tag1:bar,tag2:baz",
+	"foo5:100|c|#tag1:bar,#tag2:baz",
+	"foo6:100|c|#09digits:0,tag.with.dots:1",
+	"foo10:100|c|@0.1|#tag1:bar,#tag2:baz",
+	"foo11:100|c|@0.1|#tag1:foo:bar",
+	"foo.[foo=bar,dim=val]test:1|g",
+	"foo15:200|ms:300|ms:5|c|@0.1:6|g\nfoo15a:1|c:5|ms",
+	"some_very_useful_metrics_with_quite_a_log_name:13|c",
+}
+
 func benchmarkUDPListener(times int, b *testing.B) {
-	input := []string{
-		"foo1:2|c",
-		"foo2:3|g",
-		"foo3:200|ms",
-		"foo4:100|c|#tag1:bar,tag2:baz",
-		"foo5:100|c|#tag1:bar,#tag2:baz",
-		"foo6:100|c|#09digits:0,tag.with.dots:1",
-		"foo10:100|c|@0.1|#tag1:bar,#tag2:baz",
-		"foo11:100|c|@0.1|#tag1:foo:bar",
-		"foo.[foo=bar,dim=val]test:1|g",
-		"foo15:200|ms:300|ms:5|c|@0.1:6|g\nfoo15a:1|c:5|ms",
-		"some_very_useful_metrics_with_quite_a_log_name:13|c",
-	}
+	input := udpBenchmarkInput
 	bytesInput := make([]string, len(input)*times)
 	logger := promslog.NewNopLogger()
 	for run := 0; run < times; run++ {
@@ -64,7 +69,7 @@ func benchmarkUDPListener(times int, b *testing.B) {
 
 		// there are more events than input lines, need bigger buffer
 		events := make(chan event.Events, len(bytesInput)*times*2)
-		udpChan := make(chan []byte, len(bytesInput)*times*2)
+		udpChan := make(chan listener.UDPPacket, len(bytesInput)*times*2)
 
 		l := listener.StatsDUDPListener{
 			EventHandler:    &event.UnbufferedEventHandler{C: events},
@@ -72,7 +77,7 @@ func benchmarkUDPListener(times int, b *testing.B) {
 			LineParser:      parser,
 			UDPPackets:      udpPackets,
 			LinesReceived:   linesReceived,
-			SamplesReceived: samplesReceived,
+			SamplesReceived: *samplesReceived,
 			TagsReceived:    tagsReceived,
 			UdpPacketQueue:  udpChan,
 		}
@@ -98,6 +103,47 @@ func BenchmarkUDPListener50(b *testing.B) {
 	benchmarkUDPListener(50, b)
 }
 
+// BenchmarkUDPListenerQueue sends packets with many lines, as clients that
+// batch lines do, through the packet queue.
+func BenchmarkUDPListenerQueue(b *testing.B) {
+	packet := []byte(strings.Join(udpBenchmarkInput, "\n"))
+	lines := strings.Count(string(packet), "\n") + 1
+
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	parser.EnableLibratoParsing()
+	parser.EnableSignalFXParsing()
+
+	events := make(chan event.Events, lines)
+	l := listener.StatsDUDPListener{
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      parser,
+		UDPPackets:      udpPackets,
+		UDPPacketDrops:  udpPacketDrops,
+		LinesReceived:   linesReceived,
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: *samplesReceived,
+		TagErrors:       tagErrors,
+		TagsReceived:    tagsReceived,
+		UdpPacketQueue:  make(chan listener.UDPPacket, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.ProcessUdpPacketQueue(ctx)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		l.EnqueueUdpPacket(packet, len(packet))
+		for i := 0; i < lines; i++ {
+			<-events
+		}
+	}
+}
+
 func BenchmarkExporterListener(b *testing.B) {
 	events := event.Events{
 		&event.CounterEvent{ // simple counter
@@ -195,6 +241,6 @@ mappings:
 			close(ec)
 		}()
 
-		ex.Listen(ec)
+		ex.Listen(context.Background(), ec)
 	}
 }
@@ -0,0 +1,195 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileFlag names the file of flag values that --config.file loads.
+const configFileFlag = "config.file"
+
+// flagGroups are the groups that --help shows the flags in, each with the
+// prefixes of the names of its flags. The other flags are shown last.
+var flagGroups = []struct {
+	title    string
+	prefixes []string
+}{
+	{"StatsD", []string{"statsd."}},
+	{"Web", []string{"web."}},
+	{"Configuration", []string{"config.", "check-config", "wait-for-config", "restore-snapshot", "one-shot"}},
+	{"Logging and tracing", []string{"log.", "trace-metric"}},
+	{"Health", []string{"health.", "memory-guard."}},
+	{"High availability", []string{"ha."}},
+	{"InfluxDB and Kafka", []string{"influxdb.", "kafka."}},
+	{"Debugging", []string{"debug."}},
+}
+
+// flagGroup is a group of flags as shown by the usage template.
+type flagGroup struct {
+	Title string
+	Flags []*kingpin.FlagModel
+}
+
+// groupFlags sorts the flags that are not hidden into the flagGroups, keeping
+// their order within each group and leaving out empty groups.
+func groupFlags(flags []*kingpin.FlagModel) []flagGroup {
+	groups := make([]flagGroup, len(flagGroups)+1)
+	for i, g := range flagGroups {
+		groups[i].Title = g.title
+	}
+	groups[len(flagGroups)].Title = "Other"
+flags:
+	for _, f := range flags {
+		if f.Hidden {
+			continue
+		}
+		for i, g := range flagGroups {
+			for _, prefix := range g.prefixes {
+				if strings.HasPrefix(f.Name, prefix) {
+					groups[i].Flags = append(groups[i].Flags, f)
+					continue flags
+				}
+			}
+		}
+		groups[len(flagGroups)].Flags = append(groups[len(flagGroups)].Flags, f)
+	}
+	return slices.DeleteFunc(groups, func(g flagGroup) bool { return len(g.Flags) == 0 })
+}
+
+// groupedUsageTemplate is the default usage template of kingpin, with the
+// flags shown in groups.
+var groupedUsageTemplate = strings.Replace(kingpin.DefaultUsageTemplate,
+	`{{if .Context.Flags -}}
+Flags:
+{{.Context.Flags|FlagsToTwoColumns|FormatTwoColumns}}
+{{end -}}`,
+	`{{range .Context.Flags|GroupFlags -}}
+{{.Title}} flags:
+{{.Flags|FlagsToTwoColumns|FormatTwoColumns}}
+{{end -}}`, 1)
+
+// useGroupedUsage makes --help show the flags of an application in groups.
+func useGroupedUsage(app *kingpin.Application) {
+	app.UsageFuncs(map[string]any{"GroupFlags": groupFlags})
+	app.UsageTemplate(groupedUsageTemplate)
+}
+
+// addConfigFileFlag adds --config.file, which loads flag values from a YAML
+// file whose keys are the names of the flags.
+func addConfigFileFlag(app *kingpin.Application) {
+	app.Flag(configFileFlag, "YAML file with the values of flags, keyed by flag name without the leading dashes. Lists set repeatable flags. Flags given on the command line take precedence.").String()
+}
+
+// argsWithConfigFile returns the command line arguments preceded by the flags
+// of the file given with --config.file, leaving out those that are given on
+// the command line.
+func argsWithConfigFile(app *kingpin.Application, args []string) ([]string, error) {
+	fileName := ""
+	given := map[string]bool{}
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, ok := strings.CutPrefix(arg, "--")
+		if !ok {
+			continue
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		if name == configFileFlag {
+			switch {
+			case hasValue:
+				fileName = value
+			case i+1 < len(args):
+				fileName = args[i+1]
+			}
+		}
+		given[name] = true
+		given[strings.TrimPrefix(name, "no-")] = true
+	}
+	if fileName == "" {
+		return args, nil
+	}
+
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := yaml.UnmarshalStrict(content, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fileArgs []string
+	for _, name := range names {
+		flag := app.GetFlag(name)
+		if flag == nil || name == configFileFlag {
+			return nil, fmt.Errorf("%s: unknown flag %q", fileName, name)
+		}
+		if given[name] {
+			continue
+		}
+		list, ok := values[name].([]any)
+		if !ok {
+			list = []any{values[name]}
+		}
+		for _, v := range list {
+			arg, err := flagArg(name, flag.Model().IsBoolFlag(), v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fileName, err)
+			}
+			fileArgs = append(fileArgs, arg)
+		}
+	}
+	return append(fileArgs, args...), nil
+}
+
+// flagArg returns the command line argument that sets a flag to a value of
+// the config file.
+func flagArg(name string, isBool bool, value any) (string, error) {
+	if isBool {
+		b, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("flag %q must be true or false, got %v", name, value)
+		}
+		if !b {
+			return "--no-" + name, nil
+		}
+		return "--" + name, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return "--" + name + "=" + v, nil
+	case int:
+		return "--" + name + "=" + strconv.Itoa(v), nil
+	case float64:
+		return "--" + name + "=" + strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return "--" + name + "=" + strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("flag %q must be a string, number or list, got %v", name, value)
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+func TestArgsWithConfigFile(t *testing.T) {
+	app := kingpin.New("test", "")
+	addConfigFileFlag(app)
+	listenUDP := app.Flag("statsd.listen-udp", "").Default(":9125").String()
+	threshold := app.Flag("statsd.event-flush-threshold", "").Default("1000").Int()
+	aggregation := app.Flag("statsd.event-aggregation", "").Default("true").Bool()
+	addresses := app.Flag("web.listen-address", "").Strings()
+
+	fileName := filepath.Join(t.TempDir(), "flags.yml")
+	config := `
+statsd.listen-udp: ":8125"
+statsd.event-flush-threshold: 500
+statsd.event-aggregation: false
+web.listen-address: [":9102", ":9103"]
+`
+	if err := os.WriteFile(fileName, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := argsWithConfigFile(app, []string{"--config.file", fileName, "--statsd.event-flush-threshold=200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if *listenUDP != ":8125" {
+		t.Errorf("expected the UDP address of the file, got %q", *listenUDP)
+	}
+	if *threshold != 200 {
+		t.Errorf("expected the command line to take precedence, got a threshold of %d", *threshold)
+	}
+	if *aggregation {
+		t.Error("expected event aggregation to be disabled by the file")
+	}
+	if expected := []string{":9102", ":9103"}; !reflect.DeepEqual(*addresses, expected) {
+		t.Errorf("expected the addresses %v, got %v", expected, *addresses)
+	}
+
+	if err := os.WriteFile(fileName, []byte("statsd.listen-udpp: \":8125\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := argsWithConfigFile(app, []string{"--config.file=" + fileName}); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+}
+
+func TestGroupFlags(t *testing.T) {
+	app := kingpin.New("test", "")
+	app.Flag("target", "").String()
+	app.Flag("web.listen-address", "").String()
+	app.Flag("statsd.listen-udp", "").String()
+	app.Flag("statsd.listen-tcp", "").String()
+
+	var got [][]string
+	for _, g := range groupFlags(app.Model().Flags) {
+		names := []string{g.Title}
+		for _, f := range g.Flags {
+			names = append(names, f.Name)
+		}
+		got = append(got, names)
+	}
+	expected := [][]string{
+		{"StatsD", "statsd.listen-udp", "statsd.listen-tcp"},
+		{"Web", "web.listen-address"},
+		{"Other", "help", "target"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected the groups %v, got %v", expected, got)
+	}
+}
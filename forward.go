@@ -0,0 +1,62 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/relay"
+)
+
+// forwarder periodically sends the series that the mappings of the exporters
+// select for forwarding to another StatsD server, so that exporters can be
+// chained into aggregation tiers. The lines are batched by a relay.
+type forwarder struct {
+	exporters []*exporter.Exporter
+	relay     *relay.Relay
+	// ha, if set, limits forwarding to the leader of an HA pair.
+	ha *haCoordinator
+}
+
+// newForwarder creates a forwarder for the main exporter and the exporters
+// of the tenants.
+func newForwarder(r *relay.Relay, main *exporter.Exporter, tenants []*tenant) *forwarder {
+	f := &forwarder{exporters: []*exporter.Exporter{main}, relay: r}
+	for _, t := range tenants {
+		f.exporters = append(f.exporters, t.exporter)
+	}
+	return f
+}
+
+// forward sends the lines of all exporters once.
+func (f *forwarder) forward() {
+	if !f.ha.isLeader() {
+		return
+	}
+	for _, e := range f.exporters {
+		for _, l := range e.ForwardLines() {
+			f.relay.RelayLine(l)
+		}
+	}
+}
+
+// run forwards at the given interval. It never returns.
+func (f *forwarder) run(interval time.Duration) {
+	ticker := clock.NewTicker(interval)
+	for range ticker.C {
+		f.forward()
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// gaugeHistogramsAsGauges returns a gatherer that replaces the gauge histogram
+// families gathered from g with gauges named like the samples of the gauge
+// histogram in OpenMetrics: `_bucket` with an `le` label, `_gcount` and
+// `_gsum`. This is for formats that have no gauge histogram type, and keeps
+// the series names the same in all formats.
+func gaugeHistogramsAsGauges(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		result := make([]*dto.MetricFamily, 0, len(mfs))
+		for _, mf := range mfs {
+			if mf.GetType() != dto.MetricType_GAUGE_HISTOGRAM {
+				result = append(result, mf)
+				continue
+			}
+			result = append(result, splitGaugeHistogram(mf)...)
+		}
+		return result, err
+	})
+}
+
+func splitGaugeHistogram(mf *dto.MetricFamily) []*dto.MetricFamily {
+	family := func(suffix string) *dto.MetricFamily {
+		name := mf.GetName() + suffix
+		return &dto.MetricFamily{Name: &name, Help: mf.Help, Type: dto.MetricType_GAUGE.Enum()}
+	}
+	gauge := func(labels []*dto.LabelPair, value float64) *dto.Metric {
+		return &dto.Metric{Label: labels, Gauge: &dto.Gauge{Value: &value}}
+	}
+	withLe := func(labels []*dto.LabelPair, upperBound float64) []*dto.LabelPair {
+		name, value := model.BucketLabel, formatUpperBound(upperBound)
+		return append(append([]*dto.LabelPair(nil), labels...), &dto.LabelPair{Name: &name, Value: &value})
+	}
+
+	bucket, gcount, gsum := family("_bucket"), family("_gcount"), family("_gsum")
+	for _, m := range mf.GetMetric() {
+		h := m.GetHistogram()
+		for _, b := range h.GetBucket() {
+			bucket.Metric = append(bucket.Metric, gauge(withLe(m.Label, b.GetUpperBound()), float64(b.GetCumulativeCount())))
+		}
+		bucket.Metric = append(bucket.Metric, gauge(withLe(m.Label, math.Inf(1)), float64(h.GetSampleCount())))
+		gcount.Metric = append(gcount.Metric, gauge(m.Label, float64(h.GetSampleCount())))
+		gsum.Metric = append(gsum.Metric, gauge(m.Label, h.GetSampleSum()))
+	}
+	return []*dto.MetricFamily{bucket, gcount, gsum}
+}
+
+// formatUpperBound formats a bucket bound like the `le` label of histograms in
+// the text format.
+func formatUpperBound(upperBound float64) string {
+	if math.IsInf(upperBound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(upperBound, 'g', -1, 64)
+}
+
+// writeOpenMetricsGaugeHistogram writes a gauge histogram family in the
+// OpenMetrics format. expfmt only supports histograms, whose samples differ
+// from those of gauge histograms in the type and the names of the count and
+// sum samples, so the family is encoded as a histogram and then rewritten.
+func writeOpenMetricsGaugeHistogram(w io.Writer, mf *dto.MetricFamily) error {
+	histogram := &dto.MetricFamily{
+		Name:   mf.Name,
+		Help:   mf.Help,
+		Type:   dto.MetricType_HISTOGRAM.Enum(),
+		Unit:   mf.Unit,
+		Metric: mf.Metric,
+	}
+	var buf bytes.Buffer
+	if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, histogram); err != nil {
+		return err
+	}
+
+	name := mf.GetName()
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, line := range lines {
+		switch {
+		case line == "# TYPE "+name+" histogram\n":
+			lines[i] = "# TYPE " + name + " gaugehistogram\n"
+		case isSample(line, name+"_count"):
+			lines[i] = name + "_gcount" + line[len(name+"_count"):]
+		case isSample(line, name+"_sum"):
+			lines[i] = name + "_gsum" + line[len(name+"_sum"):]
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
+
+// isSample reports whether the line is a sample of the given metric name.
+func isSample(line, name string) bool {
+	return strings.HasPrefix(line, name) && len(line) > len(name) && (line[len(name)] == ' ' || line[len(name)] == '{')
+}
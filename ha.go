@@ -0,0 +1,246 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// haLeaderService is the gRPC health service that reports whether the
+// exporter is the leader of an HA pair.
+const haLeaderService = "statsd_exporter.leader"
+
+// haLease decides which exporter of an HA pair is the leader.
+type haLease interface {
+	// acquire tries to take or keep the leadership, and reports whether
+	// this exporter holds it.
+	acquire() (bool, error)
+}
+
+// haCoordinator keeps track of whether this exporter is the leader of an HA
+// pair. Both exporters of a pair receive the same traffic, but only the
+// leader exposes and forwards the converted metrics, so that they are not
+// counted twice. As the standby keeps processing all events, its metrics are
+// complete when it takes over.
+type haCoordinator struct {
+	lease    haLease
+	interval time.Duration
+	leader   atomic.Bool
+	// grpc, if set, is updated with the leadership of the exporter.
+	grpc        *health.Server
+	leaderGauge prometheus.Gauge
+	leaseErrors prometheus.Counter
+	logger      *slog.Logger
+}
+
+func newHACoordinator(lease haLease, interval time.Duration, grpc *health.Server, leaderGauge prometheus.Gauge, leaseErrors prometheus.Counter, logger *slog.Logger) *haCoordinator {
+	return &haCoordinator{
+		lease:       lease,
+		interval:    interval,
+		grpc:        grpc,
+		leaderGauge: leaderGauge,
+		leaseErrors: leaseErrors,
+		logger:      logger,
+	}
+}
+
+// isLeader reports whether this exporter is the leader. A nil coordinator
+// is always the leader, as there is no pair.
+func (c *haCoordinator) isLeader() bool {
+	return c == nil || c.leader.Load()
+}
+
+// update tries to acquire the lease once. An exporter that cannot tell
+// whether it holds the lease steps down, so that a broken lease does not
+// lead to double counting.
+func (c *haCoordinator) update() {
+	leader, err := c.lease.acquire()
+	if err != nil {
+		c.leaseErrors.Inc()
+		c.logger.Warn("Unable to acquire the HA lease", "error", err)
+		leader = false
+	}
+	if was := c.leader.Swap(leader); was != leader {
+		if leader {
+			c.logger.Info("Became the HA leader, exposing metrics")
+		} else {
+			c.logger.Info("Became the HA standby, no longer exposing metrics")
+		}
+	}
+	if leader {
+		c.leaderGauge.Set(1)
+	} else {
+		c.leaderGauge.Set(0)
+	}
+	if c.grpc != nil {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if leader {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		c.grpc.SetServingStatus(haLeaderService, status)
+	}
+}
+
+// run updates the leadership at the interval of the coordinator. It never
+// returns.
+func (c *haCoordinator) run() {
+	ticker := clock.NewTicker(c.interval)
+	for range ticker.C {
+		c.update()
+	}
+}
+
+// gatherer returns a gatherer that gathers from g while this exporter is the
+// leader, and nothing otherwise.
+func (c *haCoordinator) gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	if c == nil {
+		return g
+	}
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		if !c.isLeader() {
+			return nil, nil
+		}
+		return g.Gather()
+	})
+}
+
+// ServeHTTP answers with 200 if this exporter is the leader, and with 503
+// otherwise, so that it can be used to select the leader or as the peer URL
+// of a static lease.
+func (c *haCoordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		return
+	}
+	if !c.isLeader() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "Statsd Exporter is the HA standby.\n")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Statsd Exporter is the HA leader.\n")
+}
+
+// fileLease is a lease held in a file on storage shared by the exporters of
+// a pair. The holder renews it before it expires; once it has expired, the
+// other exporter may take it.
+type fileLease struct {
+	path     string
+	id       string
+	duration time.Duration
+}
+
+// fileLeaseRecord is the content of a lease file.
+type fileLeaseRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+func (l *fileLease) acquire() (bool, error) {
+	record, err := l.read()
+	if err != nil {
+		return false, err
+	}
+	now := clock.Now()
+	if record.Holder != l.id && record.Expires.After(now) {
+		return false, nil
+	}
+	if err := l.write(fileLeaseRecord{Holder: l.id, Expires: now.Add(l.duration)}); err != nil {
+		return false, err
+	}
+	// Both exporters may have found the lease expired and written it. This
+	// is not a compare-and-swap: each may still read its own write back and
+	// consider itself the leader. The one whose write was replaced notices
+	// at its next renewal and steps down, so both lead for at most a third
+	// of the lease duration.
+	record, err = l.read()
+	if err != nil {
+		return false, err
+	}
+	return record.Holder == l.id, nil
+}
+
+// read reads the lease file. A missing file is an expired lease.
+func (l *fileLease) read() (fileLeaseRecord, error) {
+	var record fileLeaseRecord
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return record, nil
+	}
+	if err != nil {
+		return record, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("invalid lease file %s: %w", l.path, err)
+	}
+	return record, nil
+}
+
+// write replaces the lease file atomically, so that the other exporter never
+// reads a partially written lease.
+func (l *fileLease) write(record fileLeaseRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+// staticLease gives the leadership to the primary exporter of a pair. The
+// standby only takes over while the primary does not answer its peer URL
+// with 200.
+type staticLease struct {
+	primary bool
+	peerURL string
+	client  *http.Client
+}
+
+func (l *staticLease) acquire() (bool, error) {
+	if l.primary {
+		return true, nil
+	}
+	resp, err := l.client.Get(l.peerURL)
+	if err != nil {
+		return true, nil
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusOK, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+func newTestHACoordinator(lease haLease) *haCoordinator {
+	return newHACoordinator(lease, time.Second, nil, prometheus.NewGauge(prometheus.GaugeOpts{Name: "leader"}), prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}), promslog.NewNopLogger())
+}
+
+func TestFileLease(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	path := filepath.Join(t.TempDir(), "lease")
+	a := newTestHACoordinator(&fileLease{path: path, id: "a", duration: 15 * time.Second})
+	b := newTestHACoordinator(&fileLease{path: path, id: "b", duration: 15 * time.Second})
+
+	check := func(aLeader, bLeader bool) {
+		t.Helper()
+		if a.isLeader() != aLeader || b.isLeader() != bLeader {
+			t.Fatalf("At %v, expected leaders a=%v b=%v, got a=%v b=%v", clock.Now(), aLeader, bLeader, a.isLeader(), b.isLeader())
+		}
+	}
+
+	a.update()
+	b.update()
+	check(true, false)
+
+	// The holder renews the lease before it expires.
+	clock.ClockInstance.Instant = time.Unix(10, 0)
+	a.update()
+	b.update()
+	check(true, false)
+
+	// Once a stops renewing the lease, b takes over after it expired.
+	clock.ClockInstance.Instant = time.Unix(20, 0)
+	b.update()
+	check(true, false)
+	clock.ClockInstance.Instant = time.Unix(26, 0)
+	b.update()
+	a.update()
+	check(false, true)
+
+	// A broken lease file makes the exporter step down.
+	if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b.update()
+	check(false, false)
+	if v := testutil.ToFloat64(b.leaseErrors); v != 1 {
+		t.Errorf("Expected 1 lease error, got %v", v)
+	}
+}
+
+func TestStaticLease(t *testing.T) {
+	primaryUp := true
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !primaryUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer peer.Close()
+
+	primary := newTestHACoordinator(&staticLease{primary: true})
+	standby := newTestHACoordinator(&staticLease{peerURL: peer.URL, client: peer.Client()})
+
+	primary.update()
+	standby.update()
+	if !primary.isLeader() || standby.isLeader() {
+		t.Fatalf("Expected the primary to be the leader, got primary=%v standby=%v", primary.isLeader(), standby.isLeader())
+	}
+
+	primaryUp = false
+	standby.update()
+	if !standby.isLeader() {
+		t.Fatalf("Expected the standby to take over while the primary is down")
+	}
+}
+
+func TestHAGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "converted_total"})
+	reg.MustRegister(c)
+	c.Inc()
+
+	leader := true
+	ha := newTestHACoordinator(haLeaseFunc(func() (bool, error) { return leader, nil }))
+	g := ha.gatherer(reg)
+
+	for _, l := range []bool{true, false} {
+		leader = l
+		ha.update()
+		mfs, err := g.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[bool]int{true: 1, false: 0}[l]; len(mfs) != expected {
+			t.Errorf("As leader=%v, expected %d families, got %d", l, expected, len(mfs))
+		}
+		w := httptest.NewRecorder()
+		ha.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/leader", nil))
+		if expected := map[bool]int{true: http.StatusOK, false: http.StatusServiceUnavailable}[l]; w.Code != expected {
+			t.Errorf("As leader=%v, expected status %d, got %d", l, expected, w.Code)
+		}
+	}
+
+	// Without HA, everything is exposed.
+	var none *haCoordinator
+	if mfs, err := none.gatherer(reg).Gather(); err != nil || len(mfs) != 1 {
+		t.Errorf("Expected 1 family without HA, got %d, %v", len(mfs), err)
+	}
+}
+
+type haLeaseFunc func() (bool, error)
+
+func (f haLeaseFunc) acquire() (bool, error) { return f() }
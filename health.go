@@ -0,0 +1,207 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+// healthMonitor checks that the StatsD pipeline works: the listeners are
+// running, the event loops of the exporters make progress and their event
+// queues do not stay full. The result is served on /-/healthy and, if
+// enabled, through the gRPC health checking service, so that probes can
+// restart an exporter that is wedged rather than merely running.
+type healthMonitor struct {
+	// eventLoopTimeout is how long an event loop may take to get to the
+	// next batch of events before it is considered stuck.
+	eventLoopTimeout time.Duration
+	// saturationTimeout is how long an event queue may stay full before the
+	// exporter is considered unhealthy. If it is not positive, full queues
+	// are not checked.
+	saturationTimeout time.Duration
+	// grpc, if set, is updated with the serving status of the exporter.
+	grpc   *health.Server
+	logger *slog.Logger
+
+	loops []*healthEventLoop
+
+	mtx       sync.Mutex
+	listeners map[string]bool
+	problems  []string
+}
+
+// healthEventLoop is an exporter and the queue its event loop reads from.
+type healthEventLoop struct {
+	name     string
+	exporter *exporter.Exporter
+	queue    chan event.Events
+	// saturatedSince is when the queue was first found full, or the zero
+	// time if it was not full on the last check.
+	saturatedSince time.Time
+}
+
+func newHealthMonitor(eventLoopTimeout, saturationTimeout time.Duration, healthServer *health.Server, logger *slog.Logger) *healthMonitor {
+	return &healthMonitor{
+		eventLoopTimeout:  eventLoopTimeout,
+		saturationTimeout: saturationTimeout,
+		grpc:              healthServer,
+		logger:            logger,
+		listeners:         map[string]bool{},
+	}
+}
+
+// addEventLoop adds the event loop of an exporter to the checks. It must be
+// called before run.
+func (h *healthMonitor) addEventLoop(name string, e *exporter.Exporter, queue chan event.Events) {
+	h.loops = append(h.loops, &healthEventLoop{name: name, exporter: e, queue: queue})
+}
+
+// runListener runs the Listen function of a listener and records when it
+// stops. A listener that stops because the context is cancelled is not
+// reported as failed.
+func (h *healthMonitor) runListener(ctx context.Context, name string, listen func(context.Context) error) {
+	h.mtx.Lock()
+	h.listeners[name] = true
+	h.mtx.Unlock()
+
+	err := listen(ctx)
+
+	h.mtx.Lock()
+	h.listeners[name] = false
+	h.mtx.Unlock()
+	if ctx.Err() == nil {
+		h.logger.Error("Listener stopped", "listener", name, "error", err)
+	}
+}
+
+// run checks the pipeline at the given interval. It never returns.
+func (h *healthMonitor) run(interval time.Duration) {
+	ticker := clock.NewTicker(interval)
+	for range ticker.C {
+		h.check()
+	}
+}
+
+// check checks the pipeline and updates the health status.
+func (h *healthMonitor) check() {
+	var problems []string
+
+	h.mtx.Lock()
+	for name, running := range h.listeners {
+		if !running {
+			problems = append(problems, fmt.Sprintf("listener %s stopped", name))
+		}
+	}
+	h.mtx.Unlock()
+	sort.Strings(problems)
+
+	for _, l := range h.loops {
+		if !l.exporter.Responsive(h.eventLoopTimeout) {
+			problems = append(problems, fmt.Sprintf("event loop %s is not making progress", l.name))
+		}
+		if h.saturationTimeout <= 0 {
+			continue
+		}
+		if len(l.queue) < cap(l.queue) {
+			l.saturatedSince = time.Time{}
+			continue
+		}
+		now := clock.Now()
+		if l.saturatedSince.IsZero() {
+			l.saturatedSince = now
+		}
+		if saturated := now.Sub(l.saturatedSince); saturated >= h.saturationTimeout {
+			problems = append(problems, fmt.Sprintf("event queue %s has been full for %s", l.name, saturated.Round(time.Second)))
+		}
+	}
+
+	h.mtx.Lock()
+	wasHealthy := len(h.problems) == 0
+	h.problems = problems
+	h.mtx.Unlock()
+
+	if healthy := len(problems) == 0; healthy != wasHealthy {
+		if healthy {
+			h.logger.Info("Pipeline is healthy again")
+		} else {
+			h.logger.Error("Pipeline is unhealthy", "problems", strings.Join(problems, "; "))
+		}
+	}
+	if h.grpc != nil {
+		status := healthpb.HealthCheckResponse_SERVING
+		if len(problems) != 0 {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		h.grpc.SetServingStatus("", status)
+	}
+}
+
+// status returns the problems found by the last check. The pipeline is
+// considered healthy until it is first checked.
+func (h *healthMonitor) status() []string {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.problems
+}
+
+// ServeHTTP answers liveness probes with 200 if the pipeline is healthy, and
+// with 503 and the problems found otherwise.
+func (h *healthMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		return
+	}
+	h.logger.Debug("Received health check")
+	if problems := h.status(); len(problems) != 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "Statsd Exporter is not Healthy: %s.\n", strings.Join(problems, "; "))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Statsd Exporter is Healthy.\n")
+}
+
+// startGRPCHealth serves the gRPC health checking service on the given
+// address.
+func startGRPCHealth(address string, healthServer *health.Server, logger *slog.Logger) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Error("failed to listen for gRPC health checks", "address", address, "error", err)
+		os.Exit(1)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	logger.Info("Serving gRPC health checks", "address", address)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			logger.Error("Error serving gRPC health checks", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+func newHealthTestExporter() *exporter.Exporter {
+	e := exporter.NewExporter(prometheus.NewRegistry(), &mapper.MetricMapper{}, promslog.NewNopLogger(), nil, nil, nil, nil, nil, nil)
+	e.Sweep = exporter.SweepScrape
+	return e
+}
+
+func checkHealth(t *testing.T, h *healthMonitor, expected string) {
+	t.Helper()
+	h.check()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/healthy", nil))
+	body := w.Body.String()
+	if expected == "" {
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected healthy, got %d: %s", w.Code, body)
+		}
+		return
+	}
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(body, expected) {
+		t.Fatalf("expected unhealthy with %q, got %d: %s", expected, w.Code, body)
+	}
+}
+
+func TestHealthMonitor(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	grpcHealth := health.NewServer()
+	h := newHealthMonitor(10*time.Millisecond, time.Minute, grpcHealth, promslog.NewNopLogger())
+
+	e := newHealthTestExporter()
+	events := make(chan event.Events, 1)
+	h.addEventLoop("default", e, events)
+	checkHealth(t, h, "event loop default is not making progress")
+
+	grpcStatus := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := grpcHealth.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+	if status := grpcStatus(); status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected the gRPC health status to follow, got %v", status)
+	}
+
+	go e.Listen(context.Background(), events)
+	defer close(events)
+	h.eventLoopTimeout = time.Second
+	checkHealth(t, h, "")
+	if status := grpcStatus(); status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected the gRPC health status to follow, got %v", status)
+	}
+
+	// A queue that stays full makes the exporter unhealthy after the timeout.
+	l := h.loops[0]
+	l.queue = make(chan event.Events, 1)
+	l.queue <- event.Events{}
+	checkHealth(t, h, "")
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(time.Minute)
+	checkHealth(t, h, "event queue default has been full for 1m0s")
+	<-l.queue
+	checkHealth(t, h, "")
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		h.runListener(context.Background(), "udp :9125", func(context.Context) error {
+			<-stop
+			return errors.New("read failed")
+		})
+		close(stopped)
+	}()
+	checkHealth(t, h, "")
+	close(stop)
+	<-stopped
+	checkHealth(t, h, "listener udp :9125 stopped")
+}
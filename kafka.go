@@ -0,0 +1,250 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+// kafkaBatchSize is the maximum number of messages written to Kafka at once.
+const kafkaBatchSize = 1000
+
+// kafkaWriter writes messages to a Kafka topic, as kafka.Writer does.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaEncoder encodes a mapped event received at the given time.
+type kafkaEncoder func(e exporter.MappedEvent, received time.Time) ([]byte, error)
+
+// errKafkaNonFinite is returned for events whose value an encoding cannot
+// represent.
+var errKafkaNonFinite = errors.New("NaN and infinite values cannot be encoded")
+
+// kafkaSink writes the events of the mappings with the kafka output to a
+// Kafka topic, keyed by metric name. Events are encoded when they are sent,
+// and written in batches in the background. When the queue is full, events
+// are dropped rather than holding up the exporter.
+type kafkaSink struct {
+	writer  kafkaWriter
+	encode  kafkaEncoder
+	queue   chan kafka.Message
+	sent    prometheus.Counter
+	dropped *prometheus.CounterVec
+	logger  *slog.Logger
+}
+
+func newKafkaSink(w kafkaWriter, encode kafkaEncoder, queueSize int, sent prometheus.Counter, dropped *prometheus.CounterVec, logger *slog.Logger) *kafkaSink {
+	return &kafkaSink{
+		writer:  w,
+		encode:  encode,
+		queue:   make(chan kafka.Message, queueSize),
+		sent:    sent,
+		dropped: dropped,
+		logger:  logger,
+	}
+}
+
+// kafkaEncoders are the encodings of --kafka.encoding.
+var kafkaEncoders = map[string]kafkaEncoder{
+	"json":     encodeKafkaJSON,
+	"protobuf": encodeKafkaProtobuf,
+}
+
+func (s *kafkaSink) Send(e exporter.MappedEvent) {
+	value, err := s.encode(e, clock.Now())
+	if err != nil {
+		reason := "encoding_failed"
+		if errors.Is(err, errKafkaNonFinite) {
+			reason = "non_finite_value"
+		}
+		s.logger.Debug("Failed to encode event for Kafka", "metric", e.Name, "error", err)
+		s.dropped.WithLabelValues(reason).Inc()
+		return
+	}
+	msg := kafka.Message{Key: []byte(e.Name), Value: value}
+	select {
+	case s.queue <- msg:
+	default:
+		s.dropped.WithLabelValues("queue_full").Inc()
+	}
+}
+
+// run writes the queued messages until the context is cancelled.
+func (s *kafkaSink) run(ctx context.Context) {
+	defer s.writer.Close()
+	batch := make([]kafka.Message, 0, kafkaBatchSize)
+	for {
+		select {
+		case msg := <-s.queue:
+			batch = append(batch[:0], msg)
+		drain:
+			for len(batch) < kafkaBatchSize {
+				select {
+				case msg := <-s.queue:
+					batch = append(batch, msg)
+				default:
+					break drain
+				}
+			}
+			if err := s.writer.WriteMessages(ctx, batch...); err != nil {
+				s.logger.Warn("Failed to write events to Kafka", "events", len(batch), "error", err)
+				s.dropped.WithLabelValues("write_failed").Add(float64(len(batch)))
+				continue
+			}
+			s.sent.Add(float64(len(batch)))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// kafkaJSONEvent is the JSON encoding of an event.
+type kafkaJSONEvent struct {
+	Name        string            `json:"name"`
+	StatsDName  string            `json:"statsd_name"`
+	Type        mapper.MetricType `json:"type"`
+	Value       float64           `json:"value"`
+	Relative    bool              `json:"relative,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Match       string            `json:"match,omitempty"`
+	TimestampMs int64             `json:"timestamp_ms"`
+	Count       int               `json:"count,omitempty"`
+}
+
+func encodeKafkaJSON(e exporter.MappedEvent, received time.Time) ([]byte, error) {
+	// JSON has no representation for NaN and infinities.
+	if math.IsNaN(e.Value) || math.IsInf(e.Value, 0) {
+		return nil, errKafkaNonFinite
+	}
+	return json.Marshal(kafkaJSONEvent{
+		Name:        e.Name,
+		StatsDName:  e.Event.MetricName(),
+		Type:        e.Event.MetricType(),
+		Value:       e.Value,
+		Relative:    e.Relative,
+		Labels:      e.Labels,
+		Match:       e.Match,
+		TimestampMs: received.UnixMilli(),
+		Count:       observationCount(e),
+	})
+}
+
+// Protobuf field numbers and event types of the message
+//
+//	message Event {
+//	  enum Type {
+//	    COUNTER = 0;
+//	    GAUGE = 1;
+//	    OBSERVER = 2;
+//	  }
+//	  string name = 1;
+//	  string statsd_name = 2;
+//	  Type type = 3;
+//	  double value = 4;
+//	  bool relative = 5;
+//	  map<string, string> labels = 6;
+//	  string match = 7;
+//	  int64 timestamp_ms = 8;
+//	  uint64 count = 9;
+//	}
+const (
+	kafkaFieldName protowire.Number = iota + 1
+	kafkaFieldStatsDName
+	kafkaFieldType
+	kafkaFieldValue
+	kafkaFieldRelative
+	kafkaFieldLabels
+	kafkaFieldMatch
+	kafkaFieldTimestampMs
+	kafkaFieldCount
+)
+
+var kafkaProtobufTypes = map[mapper.MetricType]uint64{
+	mapper.MetricTypeCounter:  0,
+	mapper.MetricTypeGauge:    1,
+	mapper.MetricTypeObserver: 2,
+}
+
+func encodeKafkaProtobuf(e exporter.MappedEvent, received time.Time) ([]byte, error) {
+	var b []byte
+	appendString := func(num protowire.Number, v string) {
+		if v != "" {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		}
+	}
+
+	appendString(kafkaFieldName, e.Name)
+	appendString(kafkaFieldStatsDName, e.Event.MetricName())
+	if t := kafkaProtobufTypes[e.Event.MetricType()]; t != 0 {
+		b = protowire.AppendTag(b, kafkaFieldType, protowire.VarintType)
+		b = protowire.AppendVarint(b, t)
+	}
+	b = protowire.AppendTag(b, kafkaFieldValue, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(e.Value))
+	if e.Relative {
+		b = protowire.AppendTag(b, kafkaFieldRelative, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	// Map entries are messages with the key in field 1 and the value in
+	// field 2. They are sorted, so that equal events are encoded equally.
+	names := make([]string, 0, len(e.Labels))
+	for name := range e.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, name)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, e.Labels[name])
+		b = protowire.AppendTag(b, kafkaFieldLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	appendString(kafkaFieldMatch, e.Match)
+	b = protowire.AppendTag(b, kafkaFieldTimestampMs, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(received.UnixMilli()))
+	if count := observationCount(e); count > 0 {
+		b = protowire.AppendTag(b, kafkaFieldCount, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(count))
+	}
+	return b, nil
+}
+
+// observationCount returns the number of observations an observer event
+// stands for, and 0 for other events.
+func observationCount(e exporter.MappedEvent) int {
+	if o, ok := e.Event.(*event.ObserverEvent); ok {
+		return o.Count()
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+type kafkaRecorder struct {
+	written chan []kafka.Message
+}
+
+func (r *kafkaRecorder) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.written <- msgs
+	return nil
+}
+
+func (r *kafkaRecorder) Close() error { return nil }
+
+var testMappedEvent = exporter.MappedEvent{
+	Event:    &event.GaugeEvent{GMetricName: "app.queue", GValue: -2, GRelative: true},
+	Name:     "queue",
+	Labels:   prometheus.Labels{"host": "a", "env": "prod"},
+	Value:    -2,
+	Relative: true,
+	Match:    "app.*",
+}
+
+func TestKafkaSink(t *testing.T) {
+	w := &kafkaRecorder{written: make(chan []kafka.Message, 1)}
+	sent := prometheus.NewCounter(prometheus.CounterOpts{Name: "sent"})
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{"reason"})
+	s := newKafkaSink(w, encodeKafkaJSON, 2, sent, dropped, promslog.NewNopLogger())
+
+	for i := 0; i < 3; i++ {
+		s.Send(testMappedEvent)
+	}
+	if v := testutil.ToFloat64(dropped.WithLabelValues("queue_full")); v != 1 {
+		t.Fatalf("Expected 1 event dropped from the full queue, got %v", v)
+	}
+	nan := testMappedEvent
+	nan.Value = math.NaN()
+	s.Send(nan)
+	if v := testutil.ToFloat64(dropped.WithLabelValues("non_finite_value")); v != 1 {
+		t.Fatalf("Expected 1 NaN event dropped, got %v", v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.run(ctx)
+	select {
+	case msgs := <-w.written:
+		if len(msgs) != 2 || string(msgs[0].Key) != "queue" {
+			t.Fatalf("Expected a batch of 2 messages keyed by metric name, got %v", msgs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No messages written")
+	}
+}
+
+func TestEncodeKafkaJSON(t *testing.T) {
+	received := time.UnixMilli(1700000000123)
+	data, err := encodeKafkaJSON(testMappedEvent, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"name":         "queue",
+		"statsd_name":  "app.queue",
+		"type":         "gauge",
+		"value":        -2.0,
+		"relative":     true,
+		"labels":       map[string]any{"host": "a", "env": "prod"},
+		"match":        "app.*",
+		"timestamp_ms": 1700000000123.0,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for k, v := range expected {
+		gotJSON, _ := json.Marshal(got[k])
+		expectedJSON, _ := json.Marshal(v)
+		if string(gotJSON) != string(expectedJSON) {
+			t.Errorf("Expected %s to be %s, got %s", k, expectedJSON, gotJSON)
+		}
+	}
+}
+
+func TestEncodeKafkaProtobuf(t *testing.T) {
+	received := time.UnixMilli(1700000000123)
+	b, err := encodeKafkaProtobuf(testMappedEvent, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[protowire.Number]string{}
+	labels := map[string]string{}
+	var eventType, timestamp uint64
+	var value float64
+	var relative bool
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("Invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if num == kafkaFieldLabels {
+				key, m := protowire.ConsumeBytes(v[1:])
+				val, _ := protowire.ConsumeBytes(v[1+m+1:])
+				labels[string(key)] = string(val)
+			} else {
+				fields[num] = string(v)
+			}
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case kafkaFieldType:
+				eventType = v
+			case kafkaFieldRelative:
+				relative = v == 1
+			case kafkaFieldTimestampMs:
+				timestamp = v
+			}
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			value = math.Float64frombits(v)
+			b = b[n:]
+		default:
+			t.Fatalf("Unexpected wire type %v of field %d", typ, num)
+		}
+	}
+
+	if fields[kafkaFieldName] != "queue" || fields[kafkaFieldStatsDName] != "app.queue" || fields[kafkaFieldMatch] != "app.*" {
+		t.Errorf("Unexpected string fields %v", fields)
+	}
+	if eventType != 1 || value != -2 || !relative || timestamp != 1700000000123 {
+		t.Errorf("Unexpected type %d, value %v, relative %v or timestamp %d", eventType, value, relative, timestamp)
+	}
+	if len(labels) != 2 || labels["host"] != "a" || labels["env"] != "prod" {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+}
@@ -55,7 +55,7 @@ func benchmarkLinesToEvents(times int, b *testing.B, input []string) {
 	for n := 0; n < b.N; n++ {
 		for i := 0; i < times; i++ {
 			for _, l := range input {
-				parser.LineToEvents(l, *sampleErrors, samplesReceived, tagErrors, tagsReceived, nopLogger)
+				parser.LineToEvents(l, *sampleErrors, *samplesReceived, tagErrors, tagsReceived, nopLogger)
 			}
 		}
 	}
@@ -98,7 +98,7 @@ func BenchmarkLineFormats(b *testing.B) {
 			// always report allocations since this is a hot path
 			b.ReportAllocs()
 			for n := 0; n < b.N; n++ {
-				parser.LineToEvents(l, *sampleErrors, samplesReceived, tagErrors, tagsReceived, nopLogger)
+				parser.LineToEvents(l, *sampleErrors, *samplesReceived, tagErrors, tagsReceived, nopLogger)
 			}
 		})
 	}
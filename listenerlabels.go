@@ -0,0 +1,111 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/statsd_exporter/pkg/line"
+	"github.com/prometheus/statsd_exporter/pkg/listener"
+)
+
+// listenerNames are the listeners that --statsd.listener-label can label.
+var listenerNames = []string{"udp", "tcp", "unixgram", "pipe", "stdin", "firehose", "influxdb-udp", "influxdb-tcp"}
+
+// listenerLabels are the labels added to the events received on each
+// listener, by listener name.
+type listenerLabels map[string]map[string]string
+
+// parseListenerLabels parses values of --statsd.listener-label of the form
+// <listener>:<label>=<value>.
+func parseListenerLabels(flags []string) (listenerLabels, error) {
+	ll := listenerLabels{}
+	for _, f := range flags {
+		listenerName, label, ok := strings.Cut(f, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid listener label %q, expected <listener>:<label>=<value>", f)
+		}
+		if !slices.Contains(listenerNames, listenerName) {
+			return nil, fmt.Errorf("invalid listener label %q: unknown listener %q, must be one of %s", f, listenerName, strings.Join(listenerNames, ", "))
+		}
+		name, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid listener label %q, expected <listener>:<label>=<value>", f)
+		}
+		if err := validateListenerLabel(name, value); err != nil {
+			return nil, fmt.Errorf("invalid listener label %q: %w", f, err)
+		}
+		if ll[listenerName] == nil {
+			ll[listenerName] = map[string]string{}
+		}
+		if _, ok := ll[listenerName][name]; ok {
+			return nil, fmt.Errorf("label %q is set more than once for listener %q", name, listenerName)
+		}
+		ll[listenerName][name] = value
+	}
+	return ll, nil
+}
+
+func validateListenerLabel(name, value string) error {
+	if !model.LabelName(name).IsValidLegacy() {
+		return fmt.Errorf("invalid label name %q", name)
+	}
+	if value == "" {
+		return fmt.Errorf("empty value for label %q", name)
+	}
+	return nil
+}
+
+// parser returns the format for the lines of a listener, which labels their
+// events if labels are configured for it.
+func (ll listenerLabels) parser(listenerName string, f line.Format) line.Format {
+	return labeledParser(f, ll[listenerName])
+}
+
+// labeledParser returns a format that adds the labels to the events parsed by
+// f, or f itself if there are no labels.
+func labeledParser(f line.Format, labels map[string]string) line.Format {
+	if len(labels) == 0 {
+		return f
+	}
+	return line.NewLabeler(f, labels)
+}
+
+// parseCredentialLabels parses values of --statsd.unixgram-credential-label of
+// the form <label>=<credential>, where credential is one of
+// listener.CredentialFields.
+func parseCredentialLabels(flags []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, f := range flags {
+		name, field, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid credential label %q, expected <label>=<credential>", f)
+		}
+		if !model.LabelName(name).IsValidLegacy() {
+			return nil, fmt.Errorf("invalid credential label %q: invalid label name %q", f, name)
+		}
+		if !slices.Contains(listener.CredentialFields, field) {
+			return nil, fmt.Errorf("invalid credential label %q: unknown credential %q, must be one of %s", f, field, strings.Join(listener.CredentialFields, ", "))
+		}
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Errorf("credential label %q is set more than once", name)
+		}
+		labels[name] = field
+	}
+	return labels, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseListenerLabels(t *testing.T) {
+	scenarios := []struct {
+		name  string
+		flags []string
+		out   listenerLabels
+		err   string
+	}{
+		{
+			name: "none",
+			out:  listenerLabels{},
+		},
+		{
+			name:  "labels",
+			flags: []string{"udp:transport=udp", "udp:listener=internal", "tcp:transport=tcp", "influxdb-tcp:format=influx=v1"},
+			out: listenerLabels{
+				"udp":          {"transport": "udp", "listener": "internal"},
+				"tcp":          {"transport": "tcp"},
+				"influxdb-tcp": {"format": "influx=v1"},
+			},
+		},
+		{
+			name:  "no listener",
+			flags: []string{"transport=udp"},
+			err:   "expected <listener>:<label>=<value>",
+		},
+		{
+			name:  "unknown listener",
+			flags: []string{"sctp:transport=sctp"},
+			err:   "unknown listener",
+		},
+		{
+			name:  "no value",
+			flags: []string{"udp:transport"},
+			err:   "expected <listener>:<label>=<value>",
+		},
+		{
+			name:  "empty value",
+			flags: []string{"udp:transport="},
+			err:   "empty value",
+		},
+		{
+			name:  "invalid label name",
+			flags: []string{"udp:trans-port=udp"},
+			err:   "invalid label name",
+		},
+		{
+			name:  "duplicate label",
+			flags: []string{"udp:transport=udp", "udp:transport=tcp"},
+			err:   "more than once",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			out, err := parseListenerLabels(s.flags)
+			if s.err != "" {
+				if err == nil || !strings.Contains(err.Error(), s.err) {
+					t.Fatalf("expected error containing %q, got %v", s.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(out, s.out) {
+				t.Fatalf("expected %v, got %v", s.out, out)
+			}
+		})
+	}
+}
+
+func TestParseCredentialLabels(t *testing.T) {
+	scenarios := []struct {
+		name  string
+		flags []string
+		out   map[string]string
+		err   string
+	}{
+		{
+			name:  "labels",
+			flags: []string{"client_uid=uid", "client_pid=pid"},
+			out:   map[string]string{"client_uid": "uid", "client_pid": "pid"},
+		},
+		{
+			name:  "no credential",
+			flags: []string{"client_uid"},
+			err:   "expected <label>=<credential>",
+		},
+		{
+			name:  "unknown credential",
+			flags: []string{"client_user=user"},
+			err:   "unknown credential",
+		},
+		{
+			name:  "invalid label name",
+			flags: []string{"client-uid=uid"},
+			err:   "invalid label name",
+		},
+		{
+			name:  "duplicate label",
+			flags: []string{"client=uid", "client=gid"},
+			err:   "more than once",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			out, err := parseCredentialLabels(s.flags)
+			if s.err != "" {
+				if err == nil || !strings.Contains(err.Error(), s.err) {
+					t.Fatalf("expected error containing %q, got %v", s.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(out, s.out) {
+				t.Fatalf("expected %v, got %v", s.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/common/promslog"
+)
+
+// logSubsystems are the parts of the exporter whose log level can be set
+// with --log.level.<subsystem>, overriding --log.level.
+var logSubsystems = []string{"listener", "exporter", "mapper", "relay"}
+
+// addSubsystemLogFlags adds the --log.level.<subsystem> flags. The values
+// are empty for subsystems that use the global level.
+func addSubsystemLogFlags(app *kingpin.Application) map[string]*string {
+	levels := map[string]*string{}
+	for _, s := range logSubsystems {
+		levels[s] = app.Flag("log.level."+s, "Only log messages of the "+s+" subsystem with the given severity or above, instead of --log.level. One of: [debug, info, warn, error]").Enum("debug", "info", "warn", "error")
+	}
+	return levels
+}
+
+// loggers creates the global logger and the loggers of the subsystems. All
+// of them share one handler, which is created with the lowest level in use,
+// and filter records by their own level in front of it.
+type loggers struct {
+	handler slog.Handler
+	global  slog.Level
+	levels  map[string]slog.Level
+}
+
+func newLoggers(config *promslog.Config, subsystemLevels map[string]*string) (*loggers, error) {
+	l := &loggers{levels: map[string]slog.Level{}}
+	if err := l.global.UnmarshalText([]byte(config.Level.String())); err != nil {
+		return nil, err
+	}
+	lowest := l.global
+	for s, level := range subsystemLevels {
+		if *level == "" {
+			continue
+		}
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(*level)); err != nil {
+			return nil, err
+		}
+		l.levels[s] = lvl
+		lowest = min(lowest, lvl)
+	}
+
+	handlerConfig := *config
+	if lowest < l.global {
+		handlerConfig.Level = &promslog.AllowedLevel{}
+		if err := handlerConfig.Level.Set(lowest.String()); err != nil {
+			return nil, err
+		}
+	}
+	l.handler = promslog.New(&handlerConfig).Handler()
+	return l, nil
+}
+
+// logger returns the global logger.
+func (l *loggers) logger() *slog.Logger {
+	return slog.New(&levelHandler{Handler: l.handler, level: l.global})
+}
+
+// subsystem returns the logger of a subsystem. Its records carry the name of
+// the subsystem.
+func (l *loggers) subsystem(name string) *slog.Logger {
+	level, ok := l.levels[name]
+	if !ok {
+		level = l.global
+	}
+	return slog.New(&levelHandler{Handler: l.handler, level: level}).With("subsystem", name)
+}
+
+// levelHandler drops the records below its level before passing the others
+// on to the wrapped handler.
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
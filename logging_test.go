@@ -0,0 +1,58 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestSubsystemLogLevels(t *testing.T) {
+	var buf bytes.Buffer
+	config := &promslog.Config{Level: &promslog.AllowedLevel{}, Writer: &buf}
+	if err := config.Level.Set("info"); err != nil {
+		t.Fatal(err)
+	}
+	debug, warn, unset := "debug", "warn", ""
+	logs, err := newLoggers(config, map[string]*string{"listener": &debug, "relay": &warn, "exporter": &unset})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs.logger().Debug("global debug")
+	logs.logger().Info("global info")
+	logs.subsystem("listener").With("listener", "udp").Debug("listener debug")
+	logs.subsystem("exporter").Debug("exporter debug")
+	logs.subsystem("exporter").Info("exporter info")
+	logs.subsystem("relay").Info("relay info")
+	logs.subsystem("relay").Warn("relay warn")
+
+	out := buf.String()
+	for _, msg := range []string{"global info", "listener debug", "exporter info", "relay warn"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("expected %q to be logged, got:\n%s", msg, out)
+		}
+	}
+	for _, msg := range []string{"global debug", "exporter debug", "relay info"} {
+		if strings.Contains(out, msg) {
+			t.Errorf("expected %q not to be logged, got:\n%s", msg, out)
+		}
+	}
+	if !strings.Contains(out, "subsystem=listener listener=udp") {
+		t.Errorf("expected the records of a subsystem to carry its name, got:\n%s", out)
+	}
+}
@@ -15,6 +15,10 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -22,27 +26,39 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc/health"
 
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/mappertest"
 	"github.com/prometheus/statsd_exporter/pkg/address"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/event"
 	"github.com/prometheus/statsd_exporter/pkg/exporter"
 	"github.com/prometheus/statsd_exporter/pkg/line"
 	"github.com/prometheus/statsd_exporter/pkg/listener"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/mappercache"
 	"github.com/prometheus/statsd_exporter/pkg/mappercache/lru"
 	"github.com/prometheus/statsd_exporter/pkg/mappercache/randomreplacement"
+	"github.com/prometheus/statsd_exporter/pkg/registry"
 	"github.com/prometheus/statsd_exporter/pkg/relay"
 )
 
@@ -60,6 +76,61 @@ var (
 			Help: "Number of times events were flushed to exporter",
 		},
 	)
+	eventsAggregated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_events_aggregated_total",
+			Help: "The number of counter and gauge events merged with an earlier event of the same series in the event queue.",
+		},
+	)
+	eventFlushThresholdGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_event_queue_flush_threshold",
+			Help: "Number of events held in the event queue before it is flushed, as tuned by adaptive flushing, by tenant. The main event queue has no tenant.",
+		},
+		[]string{tenantLabel},
+	)
+	eventFlushIntervalGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_event_queue_flush_interval_seconds",
+			Help: "Maximum time between flushes of the event queue, as tuned by adaptive flushing, by tenant. The main event queue has no tenant.",
+		},
+		[]string{tenantLabel},
+	)
+	eventsMapped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_events_mapped_total",
+			Help: "The total number of StatsD events matched by each configured mapping.",
+		},
+		[]string{"mapping_name"},
+	)
+	labelCollisions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_label_collisions_total",
+			Help: "The total number of events with a tag that collides with a label set by their mapping.",
+		},
+		[]string{"mapping_name", "label"},
+	)
+	emptyMetricNames = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_empty_metric_names_total",
+			Help: "The total number of events dropped because their mapping generated an empty metric name, by the match of the mapping.",
+		},
+		[]string{"match"},
+	)
+	labelSchemaMismatches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_label_schema_mismatches_total",
+			Help: "The total number of events whose labels do not match the label schema of their mapping, by whether they were normalized or rejected.",
+		},
+		[]string{"mapping_name", "action"},
+	)
+	typeConflictsResolved = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_type_conflicts_resolved_total",
+			Help: "The total number of events whose metric was registered with another type, by how the conflict was resolved.",
+		},
+		[]string{"outcome"},
+	)
 	eventsUnmapped = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_unmapped_total",
@@ -77,6 +148,55 @@ var (
 			Help: "The total number of dropped StatsD packets which received over UDP.",
 		},
 	)
+	udpLineTooLong = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_udp_too_long_lines_total",
+			Help: "The number of UDP lines discarded due to being too long.",
+		},
+	)
+	udpExcessLines = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_udp_excess_lines_total",
+			Help: "The number of UDP lines discarded due to exceeding the maximum number of lines per packet.",
+		},
+	)
+	udpRejectedPackets = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_udp_rejected_packets_total",
+			Help: "The number of UDP packets dropped because their source is not in --statsd.allowed-sources.",
+		},
+	)
+	tagHeaders = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tag_headers_total",
+			Help: "The total number of datagrams starting with a DogStatsD tag header shared by their lines.",
+		},
+		[]string{"proto"},
+	)
+	datagramLines = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "statsd_exporter_datagram_lines",
+			Help:    "The number of lines batched in each StatsD datagram, not counting tag headers.",
+			Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+		},
+		[]string{"proto"},
+	)
+	metricsResponseSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "statsd_exporter_metrics_response_size_bytes",
+			Help:    "The size of the responses of the metrics endpoint as sent, by content encoding.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 11),
+		},
+		[]string{"encoding"},
+	)
+	metricsEncodeDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "statsd_exporter_metrics_encode_duration_seconds",
+			Help:    "The time taken to encode, compress and send the responses of the metrics endpoint once the metrics are gathered, by content encoding.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"encoding"},
+	)
 	tcpConnections = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_tcp_connections_total",
@@ -95,6 +215,133 @@ var (
 			Help: "The number of lines discarded due to being too long.",
 		},
 	)
+	tcpBackpressure = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_backpressure_pauses_total",
+			Help: "The number of times reading from a TCP connection was paused because the event queue was above the high-water mark.",
+		},
+	)
+	tcpSlowClients = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_slow_clients_total",
+			Help: "The number of times a TCP client did not accept the backpressure message within --statsd.tcp-slow-client-timeout.",
+		},
+	)
+	tcpPeerLines = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_peer_lines_total",
+			Help: "The number of lines received over TCP, by peer address.",
+		},
+		[]string{"peer"},
+	)
+	tcpPeerBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_peer_bytes_total",
+			Help: "The number of bytes of lines received over TCP, without line breaks, by peer address.",
+		},
+		[]string{"peer"},
+	)
+	tcpPeerParseErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_peer_parse_errors_total",
+			Help: "The number of non-empty lines received over TCP that yielded no events, by peer address.",
+		},
+		[]string{"peer"},
+	)
+	tcpPeerSlowWrites = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_peer_slow_writes_total",
+			Help: "The number of times a TCP client did not accept the backpressure message within --statsd.tcp-slow-client-timeout, by peer address.",
+		},
+		[]string{"peer"},
+	)
+	tcpRejectedConnections = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_rejected_connections_total",
+			Help: "The number of TCP connections closed because their source is not in --statsd.allowed-sources.",
+		},
+	)
+	tcpTLSHandshakeErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_tls_handshake_errors_total",
+			Help: "The number of TCP connections closed because the TLS handshake failed or the client certificate lacks the identity to label or prefix metrics with.",
+		},
+	)
+	pipeConnections = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_pipe_connections_total",
+			Help: "The total number of named pipe connections handled.",
+		},
+	)
+	pipeErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_pipe_connection_errors_total",
+			Help: "The number of errors encountered reading from a named pipe.",
+		},
+	)
+	firehoseRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_firehose_requests_total",
+			Help: "The total number of Firehose delivery requests received, by the HTTP status code of the response.",
+		},
+		[]string{"code"},
+	)
+	firehoseRecords = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_firehose_records_total",
+			Help: "The total number of records received in Firehose delivery requests.",
+		},
+	)
+	kafkaEventsSent = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_kafka_events_sent_total",
+			Help: "The total number of events written to Kafka.",
+		},
+	)
+	kafkaEventsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_kafka_events_dropped_total",
+			Help: "The total number of events not written to Kafka, by reason.",
+		},
+		[]string{"reason"},
+	)
+	pipeLineTooLong = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_pipe_too_long_lines_total",
+			Help: "The number of named pipe lines discarded due to being too long.",
+		},
+	)
+	unixgramLineTooLong = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unixgram_too_long_lines_total",
+			Help: "The number of Unixgram lines discarded due to being too long.",
+		},
+	)
+	unixgramExcessLines = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unixgram_excess_lines_total",
+			Help: "The number of Unixgram lines discarded due to exceeding the maximum number of lines per datagram.",
+		},
+	)
+	unixgramFrames = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unixgram_frames_total",
+			Help: "The total number of length-prefixed frames received over Unixgram.",
+		},
+	)
+	unixgramUnknownFrames = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unixgram_unknown_frames_total",
+			Help: "The total number of binary Unixgram datagrams discarded because they are not made up of length-prefixed frames.",
+		},
+	)
+	unixgramLinesByUIDTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unixgram_lines_by_uid_total",
+			Help: "The number of lines received over Unixgram, by user ID of the sending process.",
+		},
+		[]string{"uid"},
+	)
 	unixgramPackets = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_unixgram_packets_total",
@@ -107,11 +354,12 @@ var (
 			Help: "The total number of StatsD lines received.",
 		},
 	)
-	samplesReceived = promauto.NewCounter(
+	samplesReceived = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_samples_total",
 			Help: "The total number of StatsD samples received.",
 		},
+		[]string{"type"},
 	)
 	sampleErrors = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -139,10 +387,38 @@ var (
 		},
 		[]string{"outcome"},
 	)
+	configHash = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_config_hash",
+			Help: "Hash of the loaded mapping configuration file, by tenant. The main configuration has no tenant.",
+		},
+		[]string{tenantLabel},
+	)
+	configLastReloadSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful load of the mapping configuration, by tenant. The main configuration has no tenant.",
+		},
+		[]string{tenantLabel},
+	)
 	mappingsCount = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "statsd_exporter_loaded_mappings",
 		Help: "The current number of configured metric mappings.",
 	})
+	routeMappings = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_mapping_route_mappings",
+			Help: "The current number of metric mappings per mapping route.",
+		},
+		[]string{"route"},
+	)
+	routeLookups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_mapping_route_lookups_total",
+			Help: "The total number of metric mapping lookups per mapping route, by whether a mapping matched.",
+		},
+		[]string{"route", "result"},
+	)
 	conflictingEventStats = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_conflict_total",
@@ -157,6 +433,79 @@ var (
 		},
 		[]string{"reason"},
 	)
+	nonFiniteValuesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_non_finite_values_total",
+			Help: "The total number of samples with NaN or infinite values, by reason and the action taken.",
+		},
+		[]string{"reason", "action"},
+	)
+	coalescedGaugeUpdates = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_gauge_updates_coalesced_total",
+			Help: "The total number of gauge updates that were merged with an earlier update of the same series within --statsd.gauge-coalesce-window.",
+		},
+	)
+	metricFirstReceived = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_metric_first_received_timestamp_seconds",
+			Help: "Unix time at which a metric whose mapping sets track_received was first received.",
+		},
+		[]string{"name"},
+	)
+	metricLastReceived = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_metric_last_received_timestamp_seconds",
+			Help: "Unix time at which a metric whose mapping sets track_received was last received.",
+		},
+		[]string{"name"},
+	)
+	haLeader = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_ha_leader",
+			Help: "Whether this exporter is the leader of its HA pair and exposes the converted metrics.",
+		},
+	)
+	overloadProfilesCaptured = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_overload_profiles_total",
+			Help: "The total number of CPU profiles captured because an event queue was overloaded.",
+		},
+	)
+	haLeaseErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_ha_lease_errors_total",
+			Help: "The total number of failed attempts to acquire the HA lease.",
+		},
+	)
+	tagDialectDetections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tag_dialect_detections_total",
+			Help: "The total number of sources whose tag dialect was detected, by dialect.",
+		},
+		[]string{"dialect"},
+	)
+	linesFilteredTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_lines_filtered_total",
+			Help: "The total number of lines dropped before parsing by --statsd.drop-line-prefix and --statsd.drop-line-regex, by the type and filter that matched.",
+		},
+		[]string{"type", "filter"},
+	)
+	sourceLookupErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_source_lookup_errors_total",
+			Help: "The total number of failed lookups of the details of sources for statsd_source_info, by resolver.",
+		},
+		[]string{"resolver"},
+	)
+	tagDialectLines = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tag_dialect_lines_total",
+			Help: "The total number of lines parsed with the tag dialect detected for their source, by dialect. Lines of sources whose dialect is not known yet are counted as \"unknown\".",
+		},
+		[]string{"dialect"},
+	)
 	eventsActions = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_actions_total",
@@ -171,18 +520,66 @@ var (
 		},
 		[]string{"type"},
 	)
+	memoryGuardEvictions = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_memory_guard_evictions_total",
+			Help: "The number of times time series were evicted because the heap was above --memory-guard.max-heap.",
+		},
+	)
+	memoryGuardEvictedSeries = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_memory_guard_evicted_series_total",
+			Help: "The number of time series evicted because the heap was above --memory-guard.max-heap.",
+		},
+	)
+	expiredSeries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_expired_series_total",
+			Help: "The number of time series removed because their TTL elapsed, by the name template of their mapping.",
+		},
+		[]string{"mapping"},
+	)
 )
 
-func serveHTTP(mux http.Handler, listenAddress string, logger *slog.Logger) {
-	logger.Error(http.ListenAndServe(listenAddress, mux).Error())
+// mergeableEvents returns whether the event queue may merge an event with
+// others of its series. Counters are not merged while the mapping
+// configuration has absolute counters, whose values are totals.
+func mergeableEvents(m *mapper.MetricMapper) func(event.Event) bool {
+	return func(e event.Event) bool {
+		return e.MetricType() != mapper.MetricTypeCounter || !m.HasAbsoluteCounters()
+	}
+}
+
+// setReadBuffer sets the read buffer of a UDP or Unixgram socket, and warns if
+// the operating system granted less than the requested size.
+func setReadBuffer(c listener.BufferedConn, size int, logger *slog.Logger) error {
+	granted, err := listener.SetReadBuffer(c, size)
+	if err != nil {
+		return err
+	}
+	if granted < size {
+		logger.Warn("The operating system granted a smaller read buffer than requested, raise its limit (net.core.rmem_max on Linux, kern.ipc.maxsockbuf on the BSDs)", "requested", size, "granted", granted)
+	}
+	return nil
+}
+
+func serveHTTP(mux http.Handler, toolkitFlags *web.FlagConfig, logger *slog.Logger) {
+	server := &http.Server{Handler: mux}
+	if err := web.ListenAndServe(server, toolkitFlags, logger); err != nil {
+		logger.Error("Error starting HTTP server", "error", err)
+	}
 	os.Exit(1)
 }
 
-func sighupConfigReloader(fileName string, mapper *mapper.MetricMapper, logger *slog.Logger) {
+func sighupConfigReloader(fileName string, mapper *mapper.MetricMapper, tenants []*tenant, logger *slog.Logger) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGHUP)
 
 	for s := range signals {
+		for _, t := range tenants {
+			t.reloadConfig(logger)
+		}
+
 		if fileName == "" {
 			logger.Warn("Received signal but no mapping config to reload", "signal", s)
 			continue
@@ -190,19 +587,62 @@ func sighupConfigReloader(fileName string, mapper *mapper.MetricMapper, logger *
 
 		logger.Info("Received signal, attempting reload", "signal", s)
 
-		reloadConfig(fileName, mapper, logger)
+		reloadConfig(fileName, mapper, "", logger)
 	}
 }
 
-func reloadConfig(fileName string, mapper *mapper.MetricMapper, logger *slog.Logger) {
+// reloadConfig loads the mapping configuration file into the mapper. If the
+// configuration cannot be loaded or the mapper's Validate refuses it, the
+// previous configuration stays in effect, and reloading can be retried.
+func reloadConfig(fileName string, mapper *mapper.MetricMapper, tenantName string, logger *slog.Logger) error {
 	err := mapper.InitFromFile(fileName)
 	if err != nil {
-		logger.Info("Error reloading config", "error", err)
+		logger.Error("Error reloading config, keeping the previous config", "error", err)
 		configLoads.WithLabelValues("failure").Inc()
-	} else {
-		logger.Info("Config reloaded successfully")
-		configLoads.WithLabelValues("success").Inc()
+		recordConfigLoad(fileName, tenantName, nil, err)
+		return err
+	}
+	logger.Info("Config reloaded successfully")
+	configLoads.WithLabelValues("success").Inc()
+	configLoaded(fileName, tenantName)
+	return nil
+}
+
+// mapperFiles returns a function that returns the mapping configuration file
+// and the mapping files of the routes loaded from it.
+func mapperFiles(fileName string, mapper *mapper.MetricMapper) func() []string {
+	return func() []string {
+		return append([]string{fileName}, mapper.RouteFiles()...)
+	}
+}
+
+// configLoaded updates the hash, the reload timestamp and the status of a
+// mapping configuration file that was loaded successfully.
+func configLoaded(fileName string, tenantName string) {
+	configLastReloadSuccess.WithLabelValues(tenantName).Set(float64(clock.Now().UnixNano()) / 1e9)
+	var checksum []byte
+	if sum, err := fileChecksum(fileName); err == nil {
+		// 48 bits of the checksum fit into the mantissa of a float64.
+		configHash.WithLabelValues(tenantName).Set(float64(binary.BigEndian.Uint64(sum[:8]) >> 16))
+		checksum = sum[:]
+	}
+	recordConfigLoad(fileName, tenantName, checksum, nil)
+}
+
+// restoreFromSnapshot restores the series of a snapshot file taken from
+// /-/snapshot into the exporter before it starts processing events.
+func restoreFromSnapshot(e *exporter.Exporter, fileName string, logger *slog.Logger) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	restored, err := e.RestoreSnapshot(f)
+	if err != nil {
+		return err
 	}
+	logger.Info("Restored snapshot", "file", fileName, "series", restored)
+	return nil
 }
 
 func dumpFSM(mapper *mapper.MetricMapper, dumpFilename string, logger *slog.Logger) error {
@@ -230,6 +670,10 @@ func getCache(cacheSize int, cacheType string, registerer prometheus.Registerer)
 			cache, err = lru.NewMetricMapperLRUCache(registerer, cacheSize)
 		case "random":
 			cache, err = randomreplacement.NewMetricMapperRRCache(registerer, cacheSize)
+		case "sharded":
+			cache, err = mappercache.NewMetricMapperShardedCache(registerer, cacheSize, 0)
+		case "adaptive":
+			cache, err = mappercache.NewMetricMapperAdaptiveCache(registerer, 0, cacheSize)
 		default:
 			err = fmt.Errorf("unsupported cache type %q", cacheType)
 		}
@@ -242,41 +686,217 @@ func getCache(cacheSize int, cacheType string, registerer prometheus.Registerer)
 	return cache, nil
 }
 
+// protectedMetrics returns a function reporting whether a metric name fully
+// matches one of the regular expressions.
+func protectedMetrics(patterns []string) (func(string) bool, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	groups := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid protected metrics regexp %q: %w", p, err)
+		}
+		groups = append(groups, "(?:"+p+")")
+	}
+	re := regexp.MustCompile("^(?:" + strings.Join(groups, "|") + ")$")
+	return re.MatchString, nil
+}
+
+// seriesExpired returns the expiry callback of the registries, which counts
+// expired time series by mapping and optionally logs them.
+func seriesExpired(logExpired bool, logger *slog.Logger) func(registry.ExpiredSeries) {
+	return func(s registry.ExpiredSeries) {
+		expiredSeries.WithLabelValues(s.Mapping).Inc()
+		if logExpired {
+			logger.Info("Time series expired", "metric", s.MetricName, "labels", s.Labels, "mapping", s.Mapping, "last_active", s.LastActive)
+		}
+	}
+}
+
+// helpConflict returns the OnHelpConflict function of a registry, which warns
+// about metrics whose series are created with differing help texts.
+func helpConflict(logger *slog.Logger) func(registry.HelpConflict) {
+	return func(c registry.HelpConflict) {
+		logger.Warn("Series created with another help text than its metric has, keeping the help text of the metric", "metric", c.MetricName, "help", c.Help, "conflicting_help", c.ConflictingHelp, "match", c.Match)
+	}
+}
+
 func main() {
 	var (
-		listenAddress        = kingpin.Flag("web.listen-address", "The address on which to expose the web interface and generated Prometheus metrics.").Default(":9102").String()
+		toolkitFlags         = kingpinflag.AddFlags(kingpin.CommandLine, ":9102")
 		enableLifecycle      = kingpin.Flag("web.enable-lifecycle", "Enable shutdown and reload via HTTP request.").Default("false").Bool()
+		adminTokenFile       = kingpin.Flag("web.admin.bearer-token-file", "File containing a bearer token that requests to the lifecycle API must present in the Authorization header. The metrics endpoint stays open.").Default("").String()
+		adminClientCert      = kingpin.Flag("web.admin.require-client-cert", "Require requests to the lifecycle API to present a client certificate verified by the client_ca_file of --web.config.file. The metrics endpoint stays open.").Default("false").Bool()
+		enableOpenMetrics    = kingpin.Flag("web.enable-openmetrics", "Expose metrics in the OpenMetrics format, including created timestamps, to scrapers that request it.").Default("false").Bool()
+		scrapeCacheMaxAge    = kingpin.Flag("web.scrape-cache-max-age", "If positive, serve scrapes from a cache of the gathered metrics that is refreshed in the background at half this age, so that scrapes do not wait for event processing. Scrapes never return metrics gathered longer ago than this.").Default("0s").Duration()
 		metricsEndpoint      = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+		metricsCompression   = kingpin.Flag("web.metrics-compression", "Comma-separated content encodings to compress the responses of the metrics endpoint with, in order of preference, out of zstd and gzip. \"\" disables compression.").Default("zstd,gzip").String()
+		metricsChunkSize     = kingpin.Flag("web.metrics-chunk-size", "Size of the chunks the responses of the metrics endpoint are sent in, e.g. \"64KB\".").Default("32KB").Bytes()
+		grpcHealthAddress    = kingpin.Flag("web.grpc-health-address", "Address on which to serve the gRPC health checking service, reporting the same status as /-/healthy. \"\" disables it.").Default("").String()
+		healthInterval       = kingpin.Flag("health.check-interval", "How often to check the health of the listeners, event loops and event queues reported on /-/healthy.").Default("5s").Duration()
+		healthLoopTimeout    = kingpin.Flag("health.event-loop-timeout", "How long an event loop may take to get to the next batch of events before the exporter is reported unhealthy.").Default("10s").Duration()
+		healthQueueTimeout   = kingpin.Flag("health.queue-saturation-timeout", "How long an event queue may stay full before the exporter is reported unhealthy. 0 disables the check.").Default("30s").Duration()
 		statsdListenUDP      = kingpin.Flag("statsd.listen-udp", "The UDP address on which to receive statsd metric lines. \"\" disables it.").Default(":9125").String()
 		statsdListenTCP      = kingpin.Flag("statsd.listen-tcp", "The TCP address on which to receive statsd metric lines. \"\" disables it.").Default(":9125").String()
 		statsdListenUnixgram = kingpin.Flag("statsd.listen-unixgram", "The Unixgram socket path to receive statsd metric lines in datagram. \"\" disables it.").Default("").String()
+		unixgramCredLabels   = kingpin.Flag("statsd.unixgram-credential-label", "Label to add to the metrics received over Unixgram with a credential of the sending process, as <label>=<credential>, e.g. client_uid=uid. Credentials are uid, gid and pid. Can be repeated. Only supported on Linux.").Strings()
+		unixgramLinesByUID   = kingpin.Flag("statsd.unixgram-lines-by-uid", "Count the lines received over Unixgram by user ID of the sending process in statsd_exporter_unixgram_lines_by_uid_total. Only supported on Linux.").Default("false").Bool()
+		unixgramAcceptFrames = kingpin.Flag("statsd.unixgram-accept-frames", "Decode Unixgram datagrams of length-prefixed frames, as sent by DogStatsD clients, alongside plain StatsD lines. Other binary datagrams are discarded.").Default("false").Bool()
+		statsdListenFirehose = kingpin.Flag("statsd.listen-firehose", "The HTTP address on which to receive statsd lines in Amazon Data Firehose HTTP endpoint deliveries. \"\" disables it.").Default("").String()
+		firehoseKeyFile      = kingpin.Flag("statsd.firehose.access-key-file", "File containing the access key that Firehose deliveries must present. Deliveries are accepted without a key if not set.").Default("").String()
+		influxListenUDP      = kingpin.Flag("influxdb.listen-udp", "The UDP address on which to receive InfluxDB line protocol. \"\" disables it.").Default("").String()
+		influxListenTCP      = kingpin.Flag("influxdb.listen-tcp", "The TCP address on which to receive InfluxDB line protocol. \"\" disables it.").Default("").String()
+		influxConfigFile     = kingpin.Flag("influxdb.config", "YAML file selecting, by measurement, whether the fields of InfluxDB line protocol are converted to gauges, counters or observations. Fields are gauges by default.").Default("").String()
+		firehoseHighWater    = kingpin.Flag("statsd.firehose.high-water-mark", "Number of event batches waiting to be processed (out of --statsd.event-queue-size) at which Firehose deliveries are rejected, so that Firehose retries them later. 0 disables it.").Default("0").Int()
+		statsdListenStdin    = kingpin.Flag("statsd.listen-stdin", "Read statsd metric lines from standard input until its end.").Default("false").Bool()
+		oneShot              = kingpin.Flag("one-shot", "Process the lines of --statsd.listen-stdin, print the resulting metrics in the text exposition format, and exit. No other listeners and no web server are started.").Default("false").Bool()
+		statsdListenPipe     = kingpin.Flag("statsd.listen-pipe", "The Windows named pipe (e.g. \\\\.\\pipe\\statsd) on which to receive statsd metric lines. Only supported on Windows. \"\" disables it.").Default("").String()
 		// not using Int here because flag displays default in decimal, 0755 will show as 493
 		statsdUnixSocketMode = kingpin.Flag("statsd.unixsocket-mode", "The permission mode of the unix socket.").Default("755").String()
 		mappingConfig        = kingpin.Flag("statsd.mapping-config", "Metric mapping configuration file name.").String()
-		readBuffer           = kingpin.Flag("statsd.read-buffer", "Size (in bytes) of the operating system's transmit read buffer associated with the UDP or Unixgram connection. Please make sure the kernel parameters net.core.rmem_max is set to a value greater than the value specified.").Int()
+		mappingExpandEnv     = kingpin.Flag("statsd.mapping-config-expand-env", "Replace ${VAR} references in metric names and label values of the mapping configuration with the value of the environment variable VAR. Write $${ for a literal ${.").Default("false").Bool()
+		mappingConfigWatch   = kingpin.Flag("statsd.mapping-config-watch", "Reload mapping configuration files automatically when their content or the content of the mapping files of their routes changes.").Default("false").Bool()
+		mappingWatchDebounce = kingpin.Flag("statsd.mapping-config-watch-debounce", "How long watched mapping configuration files must stay unchanged before they are reloaded.").Default("1s").Duration()
+		logExpiredSeries     = kingpin.Flag("statsd.log-expired-series", "Log every time series that is removed because its TTL elapsed.").Default("false").Bool()
+		ttlSweep             = kingpin.Flag("statsd.ttl-sweep", "When to remove time series whose TTL has elapsed: \"ticker\" checks every second, \"scrape\" checks before each scrape so that expired series are never exposed, \"both\" does both.").Default(string(exporter.SweepTicker)).Enum(string(exporter.SweepTicker), string(exporter.SweepScrape), string(exporter.SweepBoth))
+		tenantsConfigFile    = kingpin.Flag("statsd.tenants-config", "Tenants configuration file name. Each tenant has its own listeners or metric name prefix, mapping configuration and metrics, exposed on the metrics path with ?tenant=<name>.").String()
+		readBuffer           = kingpin.Flag("statsd.read-buffer", "Size (in bytes) of the operating system's transmit read buffer associated with the UDP or Unixgram connection. The operating system limits the size, through net.core.rmem_max on Linux and kern.ipc.maxsockbuf on the BSDs; a warning is logged if it grants less than the value specified.").Int()
 		cacheSize            = kingpin.Flag("statsd.cache-size", "Maximum size of your metric mapping cache. Relies on least recently used replacement policy if max size is reached.").Default("1000").Int()
-		cacheType            = kingpin.Flag("statsd.cache-type", "Metric mapping cache type. Valid options are \"lru\" and \"random\"").Default("lru").Enum("lru", "random")
+		cacheType            = kingpin.Flag("statsd.cache-type", "Metric mapping cache type. Valid options are \"lru\", \"random\", \"sharded\" and \"adaptive\"").Default("lru").Enum("lru", "random", "sharded", "adaptive")
 		eventQueueSize       = kingpin.Flag("statsd.event-queue-size", "Size of internal queue for processing events.").Default("10000").Uint()
 		eventFlushThreshold  = kingpin.Flag("statsd.event-flush-threshold", "Number of events to hold in queue before flushing.").Default("1000").Int()
 		eventFlushInterval   = kingpin.Flag("statsd.event-flush-interval", "Maximum time between event queue flushes.").Default("200ms").Duration()
+		eventFlushAdaptive   = kingpin.Flag("statsd.event-flush-adaptive", "Tune the event flush threshold and interval to the load: flush earlier while the exporter falls behind, and batch bigger while it is idle. They start at --statsd.event-flush-threshold and --statsd.event-flush-interval.").Default("false").Bool()
+		flushMinThreshold    = kingpin.Flag("statsd.event-flush-adaptive.min-threshold", "Lowest event flush threshold that adaptive flushing can choose.").Default("100").Int()
+		flushMaxThreshold    = kingpin.Flag("statsd.event-flush-adaptive.max-threshold", "Highest event flush threshold that adaptive flushing can choose.").Default("10000").Int()
+		flushMinInterval     = kingpin.Flag("statsd.event-flush-adaptive.min-interval", "Shortest event flush interval that adaptive flushing can choose.").Default("20ms").Duration()
+		flushMaxInterval     = kingpin.Flag("statsd.event-flush-adaptive.max-interval", "Longest event flush interval that adaptive flushing can choose.").Default("1s").Duration()
+		eventAggregation     = kingpin.Flag("statsd.event-aggregation", "Merge counter and gauge events of the same series in the event queue before they are flushed, summing counters and keeping the last gauge value. Use --no-statsd.event-aggregation to disable it.").Default("true").Bool()
+		gaugeCoalesceWindow  = kingpin.Flag("statsd.gauge-coalesce-window", "Hold back gauge updates for up to this long and only apply the last value of each series, plus the relative changes received after it. 0 applies every update right away.").Default("0").Duration()
 		dumpFSMPath          = kingpin.Flag("debug.dump-fsm", "The path to dump internal FSM generated for glob matching as Dot file.").Default("").String()
+		syntheticSeries      = kingpin.Flag("debug.synthetic-series", "Create this many synthetic series of mixed types at startup, to measure scrape duration and memory use at a target cardinality.").Default("0").Hidden().Int()
+		overloadProfiles     = kingpin.Flag("debug.overload-profiles", "Number of CPU profiles captured while an event queue is overloaded to keep and serve on /debug/overload-profiles. 0 disables capturing them.").Default("0").Int()
+		overloadThreshold    = kingpin.Flag("debug.overload-profile-threshold", "Fraction of the capacity of an event queue in use from which it is considered overloaded.").Default("0.9").Float64()
+		overloadAfter        = kingpin.Flag("debug.overload-profile-after", "How long an event queue has to stay overloaded before a CPU profile is captured.").Default("10s").Duration()
+		overloadDuration     = kingpin.Flag("debug.overload-profile-duration", "How long to capture each overload CPU profile for.").Default("10s").Duration()
+		tailClients          = kingpin.Flag("debug.tail-clients", "Number of clients that can stream the parsed events from "+tailPath+" at the same time. 0 disables the endpoint.").Default("0").Int()
+		tailRate             = kingpin.Flag("debug.tail-rate", "Maximum number of events per second streamed to each client of "+tailPath+".").Default("100").Int()
 		checkConfig          = kingpin.Flag("check-config", "Check configuration and exit.").Default("false").Bool()
+		checkConfigStrict    = kingpin.Flag("check-config.strict", "Make --check-config fail if loading the mapping configuration produced warnings.").Default("false").Bool()
+		mappingTests         = kingpin.Flag("check-config.mapping-tests", "File of test cases that --check-config runs against the mapping configuration, each expecting a line to produce certain metrics.").Default("").String()
+		waitForConfig        = kingpin.Flag("wait-for-config", "Serve HTTP while starting up, but report not ready on /-/ready until the mapping configuration is loaded and the listeners are bound.").Default("false").Bool()
+		warmup               = kingpin.Flag("wait-for-config.warmup", "Additional time to wait after startup before reporting ready with --wait-for-config.").Default("0s").Duration()
+		restoreSnapshot      = kingpin.Flag("restore-snapshot", "File with a snapshot taken from /-/snapshot, to restore the converted counters and gauges from at startup.").Default("").String()
+		dryRun               = kingpin.Flag("statsd.dry-run", "Process all traffic and record the exporter's own metrics, but do not expose any metrics converted from StatsD.").Default("false").Bool()
+		haLeaseType          = kingpin.Flag("ha.lease", "How the leader of an HA pair of exporters receiving the same traffic is chosen. Only the leader exposes and forwards converted metrics. \"file\" uses a lease file on shared storage, \"static\" makes the exporter with --ha.static.primary the leader while it is up. \"none\" disables HA.").Default("none").Enum("none", "file", "static")
+		haID                 = kingpin.Flag("ha.id", "Identity of this exporter in the lease file. Defaults to the host name.").Default("").String()
+		haLeaseFile          = kingpin.Flag("ha.lease-file", "Path of the lease file with --ha.lease=file, on storage shared by both exporters.").Default("").String()
+		haLeaseDuration      = kingpin.Flag("ha.lease-duration", "How long the lease is held without being renewed. It is renewed, or its leadership checked, every third of this duration.").Default("15s").Duration()
+		haStaticPrimary      = kingpin.Flag("ha.static.primary", "With --ha.lease=static, make this exporter the primary, which is always the leader.").Default("false").Bool()
+		haStaticPeerURL      = kingpin.Flag("ha.static.peer-url", "With --ha.lease=static, URL of the primary that the standby checks, e.g. http://primary:9102/-/leader. The standby becomes the leader while it does not answer with 200.").Default("").String()
 		dogstatsdTagsEnabled = kingpin.Flag("statsd.parse-dogstatsd-tags", "Parse DogStatsd style tags. Enabled by default.").Default("true").Bool()
 		influxdbTagsEnabled  = kingpin.Flag("statsd.parse-influxdb-tags", "Parse InfluxDB style tags. Enabled by default.").Default("true").Bool()
 		libratoTagsEnabled   = kingpin.Flag("statsd.parse-librato-tags", "Parse Librato style tags. Enabled by default.").Default("true").Bool()
 		signalFXTagsEnabled  = kingpin.Flag("statsd.parse-signalfx-tags", "Parse SignalFX style tags. Enabled by default.").Default("true").Bool()
+		detectTagDialect     = kingpin.Flag("statsd.detect-tag-dialect", "Detect the tag dialect of each UDP source and TCP connection from its first tagged line, among the dialects enabled with --statsd.parse-*-tags, and only parse its lines with that dialect. Requires --statsd.line-format=statsd.").Default("false").Bool()
+		gaugeLiteralNegative = kingpin.Flag("statsd.gauge-literal-negative", "Take gauge values with a minus sign, such as -5|g, for absolute negative values instead of decrements. Mappings can enable this with gauge_literal_negative.").Default("false").Bool()
+		nonFiniteValues      = kingpin.Flag("statsd.non-finite-values", "How to handle samples with NaN or infinite values: \"propagate\" passes them on, except NaN observations, which are always dropped; \"drop\" discards them; \"clamp\" replaces infinite values with the largest finite value of the same sign and discards NaN values.").Default(string(line.NonFinitePropagate)).Enum(line.NonFinitePolicies()...)
+		lineFormat           = kingpin.Flag("statsd.line-format", "Format of received lines. Formats other than \"statsd\" are provided by custom builds.").Default(line.DefaultFormat).Enum(line.Formats()...)
+		traceMetric          = kingpin.Flag("trace-metric", "Log how lines for StatsD metrics matching this glob are processed, from the raw line to the updated series. \"*\" matches any sequence of characters.").Default("").String()
+		traceMetricRate      = kingpin.Flag("trace-metric.rate", "Maximum number of lines traced per second.").Default("10").Int()
+		memoryGuardMaxHeap   = kingpin.Flag("memory-guard.max-heap", "Live heap size above which the least recently updated time series are evicted, e.g. \"512MB\". 0 disables the memory guard.").Default("0").Bytes()
+		memoryGuardFraction  = kingpin.Flag("memory-guard.evict-fraction", "Fraction of the unprotected time series to evict each time the heap is found above --memory-guard.max-heap.").Default("0.1").Float64()
+		memoryGuardInterval  = kingpin.Flag("memory-guard.interval", "How often to check the heap size.").Default("5s").Duration()
+		dropLinePrefixes     = kingpin.Flag("statsd.drop-line-prefix", "Drop received lines that start with this prefix before parsing them. Can be repeated.").Strings()
+		dropLineRegexes      = kingpin.Flag("statsd.drop-line-regex", "Drop received lines that match this regular expression before parsing them. Can be repeated.").Strings()
+		memoryGuardProtect   = kingpin.Flag("memory-guard.protect", "Regular expression of metric names whose time series are never evicted. Can be repeated.").Strings()
 		relayAddr            = kingpin.Flag("statsd.relay.address", "The UDP relay target address (host:port)").String()
 		relayPacketLen       = kingpin.Flag("statsd.relay.packet-length", "Maximum relay output packet length to avoid fragmentation").Default("1400").Uint()
+		relayCompression     = kingpin.Flag("statsd.relay.compression", "Compression for relayed lines. \"zstd\" sends compressed batches over TCP and requires a receiver accepting zstd. Valid options are \"none\" and \"zstd\"").Default("none").Enum("none", "zstd")
+		relayPrefix          = kingpin.Flag("statsd.relay.prefix", "Prefix to prepend to the metric name of every relayed line.").Default("").String()
+		relayTags            = kingpin.Flag("statsd.relay.tags", "Comma-separated DogStatsD tags to add to every relayed line, e.g. \"via:statsd_exporter,host:myhost\".").Default("").String()
+		relayRawPackets      = kingpin.Flag("statsd.relay.raw-packets", "Relay the packets received over UDP and Unixgram verbatim, preserving their batching, instead of relaying their lines. Lines received over TCP are still relayed line by line. Cannot be combined with --statsd.relay.compression, --statsd.relay.prefix or --statsd.relay.tags.").Default("false").Bool()
+		relayHeartbeat       = kingpin.Flag("statsd.relay.heartbeat-interval", "Interval at which a heartbeat counter, tagged with the host name as instance, is relayed so that the relay target can tell that the exporter is alive. 0 disables heartbeats.").Default("0").Duration()
+		relayHeartbeatMetric = kingpin.Flag("statsd.relay.heartbeat-metric", "Metric name of the relayed heartbeat counter.").Default("statsd_exporter.heartbeat").String()
+		forwardAddr          = kingpin.Flag("statsd.forward.address", "The UDP address (host:port) to send the counters and gauges of mappings with \"forward: true\" to as StatsD lines, to chain exporters.").String()
+		forwardInterval      = kingpin.Flag("statsd.forward.interval", "Interval at which series are forwarded to --statsd.forward.address.").Default("10s").Duration()
+		kafkaBrokers         = kingpin.Flag("kafka.brokers", "Comma-separated list of Kafka brokers (host:port) to write the events of mappings with the kafka output to. \"\" disables the Kafka output.").Default("").String()
+		kafkaTopic           = kingpin.Flag("kafka.topic", "Kafka topic to write events to.").Default("statsd_events").String()
+		kafkaEncoding        = kingpin.Flag("kafka.encoding", "Encoding of the events written to Kafka.").Default("json").Enum("json", "protobuf")
+		kafkaQueueSize       = kingpin.Flag("kafka.queue-size", "Number of events waiting to be written to Kafka, beyond which further events are dropped.").Default("10000").Int()
+		forwardPacketLen     = kingpin.Flag("statsd.forward.packet-length", "Maximum forward output packet length to avoid fragmentation").Default("1400").Uint()
+		tcpAcceptZstd        = kingpin.Flag("statsd.tcp-accept-zstd", "Transparently decompress TCP connections that send a Zstandard stream, as produced by a relay with zstd compression.").Default("false").Bool()
+		tcpHighWaterMark     = kingpin.Flag("statsd.tcp-high-water-mark", "Number of event batches waiting to be processed (out of --statsd.event-queue-size) at which reading from TCP connections is paused until the exporter catches up. 0 disables it.").Default("0").Int()
+		tcpBackpressureLine  = kingpin.Flag("statsd.tcp-backpressure-message", "Line to send to a TCP client when reading from its connection is paused. \"\" sends nothing.").Default("").String()
+		tcpSlowClientTimeout = kingpin.Flag("statsd.tcp-slow-client-timeout", "Time a TCP client may take to accept the backpressure message before it is logged and counted as slow. If 0, the message is dropped after a second without counting the client as slow.").Default("0").Duration()
+		tcpDisconnectSlow    = kingpin.Flag("statsd.tcp-disconnect-slow-clients", "Close the connections of TCP clients that are slow according to --statsd.tcp-slow-client-timeout.").Default("false").Bool()
+		tcpPeerMetricsLimit  = kingpin.Flag("statsd.tcp-peer-metrics-limit", "Number of TCP peer addresses to count lines, bytes and parse errors of separately. Further peers are counted as \"other\". 0 disables per-peer metrics.").Default("0").Int()
+		tcpTLSCertFile       = kingpin.Flag("statsd.tcp-tls-cert-file", "Certificate file to accept StatsD TCP connections with TLS. \"\" disables TLS.").Default("").String()
+		tcpTLSKeyFile        = kingpin.Flag("statsd.tcp-tls-key-file", "Key file of --statsd.tcp-tls-cert-file.").Default("").String()
+		tcpTLSClientCAFile   = kingpin.Flag("statsd.tcp-tls-client-ca-file", "CA certificates to verify the certificates of StatsD TCP clients with. Clients without a valid certificate are rejected. \"\" does not ask for client certificates.").Default("").String()
+		tcpTLSIdentityLabels = kingpin.Flag("statsd.tcp-tls-identity-label", "Label to add to the metrics received over TCP with a field of the client certificate, as <label>=<field>, e.g. client=cn. Fields are cn, dns, uri, email and ip. Can be repeated. Requires --statsd.tcp-tls-client-ca-file.").Strings()
+		tcpTLSIdentityPrefix = kingpin.Flag("statsd.tcp-tls-identity-prefix", "Field of the client certificate to prefix the names of the metrics received on the shared StatsD TCP listener with, followed by a dot, e.g. cn to route them to the tenant of that prefix. \"\" disables it. Requires --statsd.tcp-tls-client-ca-file.").Default("").Enum(append([]string{""}, listener.IdentityFields...)...)
+		conflictLogSize      = kingpin.Flag("statsd.conflict-log-size", "Number of distinct conflicting metrics to keep details of, exposed at /api/v1/conflicts. 0 disables it.").Default("100").Int()
+		maxLineLength        = kingpin.Flag("statsd.max-line-length", "Maximum length in bytes of a line received over UDP or Unixgram. Longer lines are discarded. 0 disables the limit.").Default("0").Int()
+		maxPacketLines       = kingpin.Flag("statsd.max-lines-per-packet", "Maximum number of lines processed per UDP packet or Unixgram datagram. The rest of the packet is discarded. 0 disables the limit.").Default("0").Int()
+		listenerLabelFlags   = kingpin.Flag("statsd.listener-label", "Label to add to the metrics received on a listener, as <listener>:<label>=<value>, e.g. udp:transport=udp. Listeners are udp, tcp, unixgram, pipe, stdin, firehose, influxdb-udp and influxdb-tcp. Can be repeated.").Strings()
+		sourceLabel          = kingpin.Flag("statsd.source-label", "Label under which to add the IP address of the source of UDP and TCP lines to their metrics, e.g. client_ip. Also exposes statsd_source_info for every source. \"\" disables it.").Default("").String()
+		sourceReverseDNS     = kingpin.Flag("statsd.source-info.reverse-dns", "Look up the host name of sources for statsd_source_info with reverse DNS.").Default("false").Bool()
+		sourceKubernetes     = kingpin.Flag("statsd.source-info.kubernetes", "Look up the pod of sources for statsd_source_info with the Kubernetes API, using the service account of the exporter's pod.").Default("false").Bool()
+		sourceInfoTTL        = kingpin.Flag("statsd.source-info.ttl", "How long the details of a source are cached before they are looked up again, and how long a source is exposed in statsd_source_info after its last line.").Default("10m").Duration()
+		allowedSources       = kingpin.Flag("statsd.allowed-sources", "Comma-separated list of networks in CIDR notation, e.g. \"10.0.0.0/8,192.168.1.0/24\", that UDP packets and TCP connections are accepted from. Traffic from other sources is dropped. Accepts all sources if empty.").Default("").String()
 		udpPacketQueueSize   = kingpin.Flag("statsd.udp-packet-queue-size", "Size of internal queue for processing UDP packets.").Default("10000").Int()
+		udpKernelDrops       = kingpin.Flag("statsd.udp-kernel-drops", "Count the packets for the UDP listeners that the kernel drops because their receive buffers are full with an eBPF program, as statsd_exporter_udp_kernel_drops_total. Needs Linux, a build with the ebpf tag, and CAP_BPF and CAP_PERFMON.").Default("false").Bool()
+		udpReusePort         = kingpin.Flag("statsd.udp-reuseport", "Open the UDP listeners with SO_REUSEPORT, so that several exporters can receive on the same address. Supported on Linux and the BSDs.").Default("false").Bool()
 	)
 
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
+	subsystemLogLevels := addSubsystemLogFlags(kingpin.CommandLine)
+	addConfigFileFlag(kingpin.CommandLine)
+	kingpin.Command("serve", "Run the exporter. This is the default command.").Default()
+	bench := addBenchCommand(kingpin.CommandLine)
+	convert := addConvertCommand(kingpin.CommandLine)
 	kingpin.Version(version.Print("statsd_exporter"))
 	kingpin.CommandLine.UsageWriter(os.Stdout)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
-	logger := promslog.New(promslogConfig)
+	useGroupedUsage(kingpin.CommandLine)
+	args, err := argsWithConfigFile(kingpin.CommandLine, os.Args[1:])
+	if err != nil {
+		kingpin.Fatalf("error loading %s: %s", configFileFlag, err)
+	}
+	command := kingpin.MustParse(kingpin.CommandLine.Parse(args))
+	logs, err := newLoggers(promslogConfig, subsystemLogLevels)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid log level:", err)
+		os.Exit(1)
+	}
+	logger := logs.logger()
+	if command == bench.cmd.FullCommand() {
+		os.Exit(bench.run(os.Stdout, logger))
+	}
+	if command == convert.cmd.FullCommand() {
+		os.Exit(convert.run(os.Stdout, logger))
+	}
+	listenerLogger := logs.subsystem("listener")
+	exporterLogger := logs.subsystem("exporter")
+	mapperLogger := logs.subsystem("mapper")
+	relayLogger := logs.subsystem("relay")
+	if *oneShot {
+		if !*statsdListenStdin {
+			logger.Error("--one-shot requires --statsd.listen-stdin")
+			os.Exit(1)
+		}
+		if *tenantsConfigFile != "" {
+			logger.Error("--one-shot does not support --statsd.tenants-config")
+			os.Exit(1)
+		}
+		// Only standard input is read, and the metrics are printed rather
+		// than served.
+		*statsdListenUDP, *statsdListenTCP, *statsdListenUnixgram, *statsdListenPipe, *statsdListenFirehose, *influxListenUDP, *influxListenTCP = "", "", "", "", "", "", ""
+	}
+	serviceStop := startService(logger)
 	prometheus.MustRegister(versioncollector.NewCollector("statsd_exporter"))
 
 	parser := line.NewParser()
@@ -292,111 +912,548 @@ func main() {
 	if *signalFXTagsEnabled {
 		parser.EnableSignalFXParsing()
 	}
-
-	logger.Info("Starting StatsD -> Prometheus Exporter", "version", version.Info())
-	logger.Info("Build context", "context", version.BuildContext())
-
-	events := make(chan event.Events, *eventQueueSize)
-	defer close(events)
-	eventQueue := event.NewEventQueue(events, *eventFlushThreshold, *eventFlushInterval, eventsFlushed)
-
-	thisMapper := &mapper.MetricMapper{Registerer: prometheus.DefaultRegisterer, MappingsCount: mappingsCount, Logger: logger}
-
-	cache, err := getCache(*cacheSize, *cacheType, thisMapper.Registerer)
+	parser.NonFinite = line.NonFinitePolicy(*nonFiniteValues)
+	parser.NonFiniteValues = nonFiniteValuesTotal
+	parser.GaugeLiteralNegative = *gaugeLiteralNegative
+	lineParser, err := line.NewFormat(*lineFormat, parser)
 	if err != nil {
-		logger.Error("Unable to setup metric mapper cache", "error", err)
+		logger.Error("Unable to create line parser", "error", err)
 		os.Exit(1)
 	}
-	thisMapper.UseCache(cache)
-
-	if *mappingConfig != "" {
-		err := thisMapper.InitFromFile(*mappingConfig)
-		if err != nil {
-			logger.Error("error loading config", "error", err)
+	var dialectDetector *line.DialectDetector
+	if *detectTagDialect {
+		if *lineFormat != line.DefaultFormat {
+			logger.Error("--statsd.detect-tag-dialect requires --statsd.line-format=" + line.DefaultFormat)
 			os.Exit(1)
 		}
-		if *dumpFSMPath != "" {
-			err := dumpFSM(thisMapper, *dumpFSMPath, logger)
-			if err != nil {
-				logger.Error("error dumping FSM", "error", err)
-				// Failure to dump the FSM is an error (the user asked for it and it
-				// didn't happen) but not fatal (the exporter is fully functional
-				// afterwards).
-			}
-		}
-	}
-
-	exporter := exporter.NewExporter(prometheus.DefaultRegisterer, thisMapper, logger, eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-
-	if *checkConfig {
-		logger.Info("Configuration check successful, exiting")
-		return
+		dialectDetector = line.NewDialectDetector(parser)
+		dialectDetector.Detections = tagDialectDetections
+		dialectDetector.Lines = tagDialectLines
+		lineParser = dialectDetector
 	}
-
-	var relayTarget *relay.Relay
-	if *relayAddr != "" {
-		var err error
-		relayTarget, err = relay.NewRelay(logger, *relayAddr, *relayPacketLen)
+	var tracer *metricTracer
+	if *traceMetric != "" {
+		tracer, err = newMetricTracer(*traceMetric, *traceMetricRate, logger)
 		if err != nil {
-			logger.Error("Unable to create relay", "err", err)
+			logger.Error("Unable to set up tracing", "error", err)
 			os.Exit(1)
 		}
+		lineParser = &tracingParser{Format: lineParser, tracer: tracer}
 	}
-
-	logger.Info("Accepting StatsD Traffic", "udp", *statsdListenUDP, "tcp", *statsdListenTCP, "unixgram", *statsdListenUnixgram)
-	logger.Info("Accepting Prometheus Requests", "addr", *listenAddress)
-
-	if *statsdListenUDP == "" && *statsdListenTCP == "" && *statsdListenUnixgram == "" {
-		logger.Error("At least one of UDP/TCP/Unixgram listeners must be specified.")
-		os.Exit(1)
+	var tail *eventTail
+	if *tailClients > 0 {
+		if *tailRate <= 0 {
+			logger.Error("--debug.tail-rate must be greater than 0")
+			os.Exit(1)
+		}
+		tail = newEventTail(*tailClients, *tailRate)
+		lineParser = &tailingParser{Format: lineParser, tail: tail}
 	}
-
-	if *statsdListenUDP != "" {
-		udpListenAddr, err := address.UDPAddrFromString(*statsdListenUDP)
+	if len(*dropLinePrefixes) > 0 || len(*dropLineRegexes) > 0 {
+		lineParser, err = line.NewFilter(lineParser, *dropLinePrefixes, *dropLineRegexes, linesFilteredTotal)
 		if err != nil {
-			logger.Error("invalid UDP listen address", "address", *statsdListenUDP, "error", err)
+			logger.Error("Unable to set up line filters", "error", err)
 			os.Exit(1)
 		}
-		uconn, err := net.ListenUDP("udp", udpListenAddr)
+	}
+	metricsEncodings, err := parseEncodings(*metricsCompression)
+	if err != nil {
+		logger.Error("Invalid --web.metrics-compression", "error", err)
+		os.Exit(1)
+	}
+	if *metricsChunkSize <= 0 {
+		logger.Error("--web.metrics-chunk-size must be positive")
+		os.Exit(1)
+	}
+
+	var sourceInfoCollector *sourceInfo
+	listenerLabels, err := parseListenerLabels(*listenerLabelFlags)
+	if err != nil {
+		logger.Error("Invalid --statsd.listener-label", "error", err)
+		os.Exit(1)
+	}
+
+	if *sourceLabel != "" {
+		if !model.LabelName(*sourceLabel).IsValidLegacy() || slices.Contains(sourceInfoLabels, *sourceLabel) {
+			logger.Error("Invalid --statsd.source-label", "label", *sourceLabel)
+			os.Exit(1)
+		}
+		var resolvers []sourceResolver
+		if *sourceReverseDNS {
+			resolvers = append(resolvers, &reverseDNSResolver{resolver: net.DefaultResolver})
+		}
+		if *sourceKubernetes {
+			k8s, err := newInClusterKubernetesResolver()
+			if err != nil {
+				logger.Error("Unable to set up Kubernetes lookups of sources", "error", err)
+				os.Exit(1)
+			}
+			resolvers = append(resolvers, k8s)
+		}
+		sourceInfoCollector = newSourceInfo(*sourceLabel, *sourceInfoTTL, resolvers, sourceLookupErrors, logger.With("subsystem", "source_info"))
+		lineParser = &sourceLabeler{Format: lineParser, label: *sourceLabel, info: sourceInfoCollector}
+	}
+	sources, err := listener.ParseAllowedSources(*allowedSources)
+	if err != nil {
+		logger.Error("Invalid --statsd.allowed-sources", "error", err)
+		os.Exit(1)
+	}
+	tcpTLS, err := tcpTLSConfig(*tcpTLSCertFile, *tcpTLSKeyFile, *tcpTLSClientCAFile)
+	if err != nil {
+		logger.Error("Invalid TLS configuration of the TCP listener", "error", err)
+		os.Exit(1)
+	}
+	tcpIdentityLabels, err := parseIdentityLabels(*tcpTLSIdentityLabels)
+	if err != nil {
+		logger.Error("Invalid --statsd.tcp-tls-identity-label", "error", err)
+		os.Exit(1)
+	}
+	if (len(tcpIdentityLabels) > 0 || *tcpTLSIdentityPrefix != "") && *tcpTLSClientCAFile == "" {
+		logger.Error("--statsd.tcp-tls-identity-label and --statsd.tcp-tls-identity-prefix require --statsd.tcp-tls-client-ca-file")
+		os.Exit(1)
+	}
+
+	admin := &adminAuth{requireClientCert: *adminClientCert, logger: logger}
+	if *adminTokenFile != "" {
+		admin.token, err = readBearerToken(*adminTokenFile)
+		if err != nil {
+			logger.Error("Invalid --web.admin.bearer-token-file", "error", err)
+			os.Exit(1)
+		}
+	}
+	if *adminClientCert && *toolkitFlags.WebConfigFile == "" {
+		logger.Error("--web.admin.require-client-cert requires TLS with client certificates configured in --web.config.file")
+		os.Exit(1)
+	}
+
+	var memoryGuard *exporter.MemoryGuard
+	if *memoryGuardMaxHeap > 0 {
+		if *memoryGuardFraction <= 0 || *memoryGuardFraction > 1 {
+			logger.Error("--memory-guard.evict-fraction must be greater than 0 and at most 1", "evict_fraction", *memoryGuardFraction)
+			os.Exit(1)
+		}
+		protected, err := protectedMetrics(*memoryGuardProtect)
+		if err != nil {
+			logger.Error("Unable to set up memory guard", "error", err)
+			os.Exit(1)
+		}
+		memoryGuard = &exporter.MemoryGuard{
+			MaxHeap:       uint64(*memoryGuardMaxHeap),
+			EvictFraction: *memoryGuardFraction,
+			Interval:      *memoryGuardInterval,
+			Protected:     protected,
+			Evictions:     memoryGuardEvictions,
+			EvictedSeries: memoryGuardEvictedSeries,
+		}
+	}
+
+	logger.Info("Starting StatsD -> Prometheus Exporter", "version", version.Info())
+	logger.Info("Build context", "context", version.BuildContext())
+
+	var grpcHealth *health.Server
+	if *grpcHealthAddress != "" && !*checkConfig {
+		grpcHealth = health.NewServer()
+		startGRPCHealth(*grpcHealthAddress, grpcHealth, logger)
+	}
+	healthMon := newHealthMonitor(*healthLoopTimeout, *healthQueueTimeout, grpcHealth, logger)
+
+	var ha *haCoordinator
+	switch *haLeaseType {
+	case "file":
+		if *haLeaseFile == "" {
+			logger.Error("--ha.lease=file requires --ha.lease-file")
+			os.Exit(1)
+		}
+		id := *haID
+		if id == "" {
+			if id, err = os.Hostname(); err != nil {
+				logger.Error("Unable to get hostname for the HA identity", "error", err)
+				os.Exit(1)
+			}
+		}
+		ha = newHACoordinator(&fileLease{path: *haLeaseFile, id: id, duration: *haLeaseDuration}, *haLeaseDuration/3, grpcHealth, haLeader, haLeaseErrors, logger)
+	case "static":
+		if !*haStaticPrimary && *haStaticPeerURL == "" {
+			logger.Error("--ha.lease=static requires --ha.static.peer-url on the standby")
+			os.Exit(1)
+		}
+		client := &http.Client{Timeout: *haLeaseDuration / 3}
+		ha = newHACoordinator(&staticLease{primary: *haStaticPrimary, peerURL: *haStaticPeerURL, client: client}, *haLeaseDuration/3, grpcHealth, haLeader, haLeaseErrors, logger)
+	}
+
+	var ready atomic.Bool
+	mux := http.DefaultServeMux
+	mux.Handle("/-/healthy", healthMon)
+
+	mux.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			logger.Debug("Received ready check")
+			if !ready.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "Statsd Exporter is not Ready.\n")
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "Statsd Exporter is Ready.\n")
+		}
+	})
+
+	// With --wait-for-config, probes are answered while the (possibly large)
+	// mapping configuration is loaded. The remaining handlers are added once
+	// they are set up.
+	if *waitForConfig && !*checkConfig && !*oneShot {
+		go serveHTTP(mux, toolkitFlags, logger)
+	}
+
+	events := make(chan event.Events, *eventQueueSize)
+	defer close(events)
+	eventQueue := event.NewEventQueue(events, *eventFlushThreshold, *eventFlushInterval, eventsFlushed)
+
+	thisMapper := &mapper.MetricMapper{Registerer: prometheus.DefaultRegisterer, MappingsCount: mappingsCount, Logger: mapperLogger, ExpandEnv: *mappingExpandEnv}
+
+	cache, err := getCache(*cacheSize, *cacheType, thisMapper.Registerer)
+	if err != nil {
+		logger.Error("Unable to setup metric mapper cache", "error", err)
+		os.Exit(1)
+	}
+	thisMapper.UseCache(cache)
+	thisMapper.RouteMappings = routeMappings
+	thisMapper.RouteLookups = routeLookups
+	thisMapper.NewRouteCache = func() (mapper.MetricMapperCache, error) {
+		return getCache(*cacheSize, *cacheType, nil)
+	}
+
+	if *eventAggregation {
+		eventQueue.Aggregate(mergeableEvents(thisMapper), eventsAggregated)
+	}
+	adaptiveFlush := func(tenant string) event.AdaptiveFlush {
+		return event.AdaptiveFlush{
+			MinThreshold: *flushMinThreshold,
+			MaxThreshold: *flushMaxThreshold,
+			MinInterval:  *flushMinInterval,
+			MaxInterval:  *flushMaxInterval,
+			Threshold:    eventFlushThresholdGauge.WithLabelValues(tenant),
+			Interval:     eventFlushIntervalGauge.WithLabelValues(tenant),
+		}
+	}
+	if *eventFlushAdaptive {
+		eventQueue.Adapt(adaptiveFlush(""))
+	}
+
+	if *mappingConfig != "" {
+		err := thisMapper.InitFromFile(*mappingConfig)
+		if err != nil {
+			logger.Error("error loading config", "error", err)
+			os.Exit(1)
+		}
+		configLoaded(*mappingConfig, "")
+		if *dumpFSMPath != "" {
+			err := dumpFSM(thisMapper, *dumpFSMPath, logger)
+			if err != nil {
+				logger.Error("error dumping FSM", "error", err)
+				// Failure to dump the FSM is an error (the user asked for it and it
+				// didn't happen) but not fatal (the exporter is fully functional
+				// afterwards).
+			}
+		}
+	}
+
+	var tenants []*tenant
+	if *tenantsConfigFile != "" {
+		configs, err := loadTenantsConfig(*tenantsConfigFile)
+		if err != nil {
+			logger.Error("error loading tenants config", "error", err)
+			os.Exit(1)
+		}
+		for _, cfg := range configs {
+			t, err := newTenant(cfg, *mappingExpandEnv, *cacheSize, *cacheType, *eventQueueSize, *eventFlushThreshold, *eventFlushInterval, eventsFlushed, mapperLogger)
+			if err != nil {
+				logger.Error("error setting up tenant", "error", err)
+				os.Exit(1)
+			}
+			if *eventAggregation {
+				t.queue.Aggregate(mergeableEvents(t.mapper), eventsAggregated)
+			}
+			if *eventFlushAdaptive {
+				t.queue.Adapt(adaptiveFlush(t.config.Name))
+			}
+			tenants = append(tenants, t)
+		}
+	}
+	eventHandler := newTenantRouter(eventQueue, tenants)
+
+	// In dry-run mode, converted metrics go into a private registry that is
+	// never exposed, so only the exporter's own telemetry is served.
+	var dataRegisterer prometheus.Registerer = prometheus.DefaultRegisterer
+	if *dryRun {
+		logger.Info("Running in dry-run mode, converted metrics will not be exposed")
+		dataRegisterer = prometheus.NewRegistry()
+	}
+	// In an HA pair, converted metrics go into a registry that is only
+	// exposed while this exporter is the leader.
+	var haData prometheus.Gatherer
+	if ha != nil && !*dryRun {
+		haRegistry := prometheus.NewRegistry()
+		dataRegisterer = haRegistry
+		haData = ha.gatherer(haRegistry)
+	}
+	// In one-shot mode, converted metrics go into a registry of their own, so
+	// that only they are printed.
+	var oneShotRegistry *prometheus.Registry
+	if *oneShot {
+		oneShotRegistry = prometheus.NewRegistry()
+		dataRegisterer = oneShotRegistry
+	}
+	if sourceInfoCollector != nil {
+		dataRegisterer.MustRegister(sourceInfoCollector)
+	}
+
+	var conflictLog *exporter.ConflictLog
+	if *conflictLogSize > 0 {
+		conflictLog = exporter.NewConflictLog(*conflictLogSize)
+	}
+
+	sweepStrategy := exporter.SweepStrategy(*ttlSweep)
+	sweepOnScrape := sweepStrategy == exporter.SweepScrape || sweepStrategy == exporter.SweepBoth
+	for _, t := range tenants {
+		t.exporter = exporter.NewExporter(t.registry, t.mapper, exporterLogger.With(tenantLabel, t.config.Name), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		t.exporter.EventsMapped = eventsMapped
+		t.exporter.Conflicts = conflictLog
+		t.exporter.LabelCollisions = labelCollisions
+		t.exporter.LabelSchemaMismatches = labelSchemaMismatches
+		t.exporter.TypeConflictsResolved = typeConflictsResolved
+		t.exporter.EmptyMetricNames = emptyMetricNames
+		t.exporter.ExtraLabels = prometheus.Labels{tenantLabel: t.config.Name}
+		t.exporter.Sweep = sweepStrategy
+		if tracer != nil {
+			t.exporter.Trace = tracer.exporterTrace
+		}
+		t.exporter.MemoryGuard = memoryGuard
+		t.exporter.GaugeCoalesceWindow = *gaugeCoalesceWindow
+		t.exporter.CoalescedGaugeUpdates = coalescedGaugeUpdates
+		t.exporter.FirstReceived = metricFirstReceived
+		t.exporter.LastReceived = metricLastReceived
+		t.mapper.Validate = t.exporter.CheckMappings
+		if r, ok := t.exporter.Registry.(*registry.Registry); ok {
+			r.OnExpire = seriesExpired(*logExpiredSeries, logger.With(tenantLabel, t.config.Name))
+			r.OnHelpConflict = helpConflict(logger.With(tenantLabel, t.config.Name))
+		}
+	}
+
+	var sinks map[mapper.Output]exporter.EventSink
+	var kafkaOutput *kafkaSink
+	if *kafkaBrokers != "" {
+		writer := &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(*kafkaBrokers, ",")...),
+			Topic:        *kafkaTopic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    kafkaBatchSize,
+			BatchTimeout: 10 * time.Millisecond,
+			RequiredAcks: kafka.RequireOne,
+		}
+		kafkaOutput = newKafkaSink(writer, kafkaEncoders[*kafkaEncoding], *kafkaQueueSize, kafkaEventsSent, kafkaEventsDropped, logger.With("output", "kafka"))
+		sinks = map[mapper.Output]exporter.EventSink{mapper.OutputKafka: kafkaOutput}
+	}
+	for _, t := range tenants {
+		t.exporter.Sinks = sinks
+	}
+
+	// Metrics of mappings that select a named registry are served below the
+	// metrics endpoint, unless converted metrics go into a registry of their
+	// own.
+	var named *namedRegistries
+	if !*dryRun && !*oneShot && !strings.HasSuffix(*metricsEndpoint, "/") {
+		named = newNamedRegistries(*metricsEndpoint+"/", thisMapper)
+	}
+
+	exporter := exporter.NewExporter(dataRegisterer, thisMapper, exporterLogger, eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	exporter.EventsMapped = eventsMapped
+	exporter.Conflicts = conflictLog
+	exporter.LabelCollisions = labelCollisions
+	exporter.LabelSchemaMismatches = labelSchemaMismatches
+	exporter.TypeConflictsResolved = typeConflictsResolved
+	exporter.EmptyMetricNames = emptyMetricNames
+	exporter.Sweep = sweepStrategy
+	if tracer != nil {
+		exporter.Trace = tracer.exporterTrace
+	}
+	exporter.MemoryGuard = memoryGuard
+	exporter.GaugeCoalesceWindow = *gaugeCoalesceWindow
+	exporter.CoalescedGaugeUpdates = coalescedGaugeUpdates
+	exporter.FirstReceived = metricFirstReceived
+	exporter.LastReceived = metricLastReceived
+	exporter.Sinks = sinks
+	if r, ok := exporter.Registry.(*registry.Registry); ok {
+		r.OnExpire = seriesExpired(*logExpiredSeries, logger)
+		r.OnHelpConflict = helpConflict(logger)
+		if named != nil {
+			r.Registerers = named.registerer
+		}
+	}
+	// Reloads that would change the type of existing metrics are refused.
+	thisMapper.Validate = exporter.CheckMappings
+
+	if *checkConfig {
+		if err := web.Validate(*toolkitFlags.WebConfigFile); err != nil {
+			logger.Error("error loading web config", "error", err)
+			os.Exit(1)
+		}
+		if warnings := thisMapper.Warnings(); len(warnings) > 0 && *checkConfigStrict {
+			logger.Error("Configuration check found problems in the mapping config", "warnings", len(warnings))
+			os.Exit(1)
+		}
+		for _, t := range tenants {
+			if warnings := t.mapper.Warnings(); len(warnings) > 0 && *checkConfigStrict {
+				logger.Error("Configuration check found problems in the mapping config", "tenant", t.config.Name, "warnings", len(warnings))
+				os.Exit(1)
+			}
+		}
+		if *mappingTests != "" {
+			tests, err := mappertest.LoadTestFile(*mappingTests)
+			if err != nil {
+				logger.Error("Unable to load mapping tests", "error", err)
+				os.Exit(1)
+			}
+			m := mappertest.New(thisMapper)
+			m.Parser = lineParser
+			failures, err := m.Run(tests.Tests)
+			if err != nil {
+				logger.Error("Unable to run mapping tests", "error", err)
+				os.Exit(1)
+			}
+			for _, f := range failures {
+				logger.Error("Mapping test failed", "failure", f)
+			}
+			if len(failures) > 0 {
+				logger.Error("Configuration check found failing mapping tests", "failed", len(failures), "tests", len(tests.Tests))
+				os.Exit(1)
+			}
+			logger.Info("Mapping tests passed", "tests", len(tests.Tests))
+		}
+		logger.Info("Configuration check successful, exiting")
+		return
+	}
+
+	// Relay targets can be changed at runtime through the lifecycle API, so
+	// the set of targets exists even if no target is configured.
+	var relayTargets *relay.Targets
+	var lineRelay listener.Relayer
+	if *relayAddr != "" || *enableLifecycle {
+		var relayOpts []relay.Option
+		if *relayCompression == "zstd" {
+			relayOpts = append(relayOpts, relay.WithZstdCompression())
+		}
+		if *relayPrefix != "" {
+			relayOpts = append(relayOpts, relay.WithPrefix(*relayPrefix))
+		}
+		if *relayTags != "" {
+			relayOpts = append(relayOpts, relay.WithTags(*relayTags))
+		}
+		if *relayRawPackets {
+			relayOpts = append(relayOpts, relay.WithRawPackets())
+		}
+		if *relayHeartbeat > 0 {
+			hostname, err := os.Hostname()
+			if err != nil {
+				logger.Warn("Unable to get the host name, relaying heartbeats without an instance tag", "err", err)
+			}
+			relayOpts = append(relayOpts, relay.WithHeartbeat(*relayHeartbeat, *relayHeartbeatMetric, hostname))
+		}
+		relayTargets = relay.NewTargets(relayLogger, *relayPacketLen, relayOpts...)
+		if *relayAddr != "" {
+			if err := relayTargets.Add(*relayAddr); err != nil {
+				logger.Error("Unable to create relay", "err", err)
+				os.Exit(1)
+			}
+		}
+		lineRelay = relayTargets
+	}
+
+	logger.Info("Accepting StatsD Traffic", "udp", *statsdListenUDP, "tcp", *statsdListenTCP, "unixgram", *statsdListenUnixgram, "pipe", *statsdListenPipe, "stdin", *statsdListenStdin, "firehose", *statsdListenFirehose, "influxdb_udp", *influxListenUDP, "influxdb_tcp", *influxListenTCP)
+
+	if *statsdListenUDP == "" && *statsdListenTCP == "" && *statsdListenUnixgram == "" && *statsdListenPipe == "" && !*statsdListenStdin && *statsdListenFirehose == "" && *influxListenUDP == "" && *influxListenTCP == "" {
+		logger.Error("At least one of UDP/TCP/Unixgram/named pipe/stdin/Firehose/InfluxDB listeners must be specified.")
+		os.Exit(1)
+	}
+
+	// Cancelling the context stops the listeners and event loops.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// udpListeners are the UDP listeners by local port, whose kernel drops
+	// are counted with --statsd.udp-kernel-drops.
+	udpListeners := map[uint16]udpListener{}
+
+	// startUDPListener and startTCPListener start a listener for the lines
+	// of proto, such as "udp" for StatsD or "influxdb-udp" for InfluxDB line
+	// protocol.
+	startUDPListener := func(proto, addr string, parser listener.Parser, relay listener.Relayer, eventHandler event.EventHandler) {
+		udpListenAddr, err := address.UDPAddrFromString(addr)
+		if err != nil {
+			logger.Error("invalid UDP listen address", "address", addr, "error", err)
+			os.Exit(1)
+		}
+		uconn, err := listener.ListenUDP(udpListenAddr, *udpReusePort)
 		if err != nil {
 			logger.Error("failed to start UDP listener", "error", err)
 			os.Exit(1)
 		}
 
 		if *readBuffer != 0 {
-			err = uconn.SetReadBuffer(*readBuffer)
-			if err != nil {
+			if err := setReadBuffer(uconn, *readBuffer, logger.With("listener", proto, "address", addr)); err != nil {
 				logger.Error("error setting UDP read buffer", "error", err)
 				os.Exit(1)
 			}
 		}
 
-		udpPacketQueue := make(chan []byte, *udpPacketQueueSize)
+		udpListeners[uint16(uconn.LocalAddr().(*net.UDPAddr).Port)] = udpListener{proto: proto, address: addr}
+		udpPacketQueue := make(chan listener.UDPPacket, *udpPacketQueueSize)
 
 		ul := &listener.StatsDUDPListener{
 			Conn:            uconn,
-			EventHandler:    eventQueue,
-			Logger:          logger,
+			EventHandler:    eventHandler,
+			Logger:          listenerLogger.With("listener", proto, "address", addr),
 			LineParser:      parser,
 			UDPPackets:      udpPackets,
 			UDPPacketDrops:  udpPacketDrops,
 			LinesReceived:   linesReceived,
 			EventsFlushed:   eventsFlushed,
-			Relay:           relayTarget,
+			Relay:           relay,
 			SampleErrors:    *sampleErrors,
-			SamplesReceived: samplesReceived,
+			SamplesReceived: *samplesReceived,
 			TagErrors:       tagErrors,
 			TagsReceived:    tagsReceived,
 			UdpPacketQueue:  udpPacketQueue,
+			MaxLineLength:   *maxLineLength,
+			MaxPacketLines:  *maxPacketLines,
+			LineTooLong:     udpLineTooLong,
+			ExcessLines:     udpExcessLines,
+			AllowedSources:  sources,
+			RejectedPackets: udpRejectedPackets,
+		}
+		if proto == "udp" {
+			ul.TagHeaders = listener.TagHeaders{
+				Enabled:     *dogstatsdTagsEnabled,
+				Headers:     tagHeaders.WithLabelValues(proto),
+				PacketLines: datagramLines.WithLabelValues(proto),
+			}
 		}
 
-		go ul.Listen()
+		go healthMon.runListener(ctx, proto+" "+addr, ul.Listen)
 	}
 
-	if *statsdListenTCP != "" {
-		tcpListenAddr, err := address.TCPAddrFromString(*statsdListenTCP)
+	var tcpPeers *listener.TCPPeers
+	if *tcpPeerMetricsLimit > 0 {
+		tcpPeers = listener.NewTCPPeers(*tcpPeerMetricsLimit, tcpPeerLines, tcpPeerBytes, tcpPeerParseErrors, tcpPeerSlowWrites)
+	}
+
+	// startTCPListener starts a TCP listener. With tlsConfig, clients connect
+	// with TLS and their metrics are labelled with their identity, and
+	// prefixed with the identityPrefix field of their certificate if set.
+	startTCPListener := func(proto, addr string, parser listener.Parser, relay listener.Relayer, eventHandler event.EventHandler, tlsConfig *tls.Config, identityPrefix string) *net.TCPListener {
+		tcpListenAddr, err := address.TCPAddrFromString(addr)
 		if err != nil {
-			logger.Error("invalid TCP listen address", "address", *statsdListenUDP, "error", err)
+			logger.Error("invalid TCP listen address", "address", addr, "error", err)
 			os.Exit(1)
 		}
 		tconn, err := net.ListenTCP("tcp", tcpListenAddr)
@@ -404,26 +1461,99 @@ func main() {
 			logger.Error("failed to start TCP listener", "err", err)
 			os.Exit(1)
 		}
-		defer tconn.Close()
 
 		tl := &listener.StatsDTCPListener{
-			Conn:            tconn,
-			EventHandler:    eventQueue,
-			Logger:          logger,
-			LineParser:      parser,
-			LinesReceived:   linesReceived,
-			EventsFlushed:   eventsFlushed,
-			Relay:           relayTarget,
-			SampleErrors:    *sampleErrors,
-			SamplesReceived: samplesReceived,
-			TagErrors:       tagErrors,
-			TagsReceived:    tagsReceived,
-			TCPConnections:  tcpConnections,
-			TCPErrors:       tcpErrors,
-			TCPLineTooLong:  tcpLineTooLong,
+			Conn:                  tconn,
+			EventHandler:          eventHandler,
+			Logger:                listenerLogger.With("listener", proto, "address", addr),
+			LineParser:            parser,
+			LinesReceived:         linesReceived,
+			EventsFlushed:         eventsFlushed,
+			Relay:                 relay,
+			SampleErrors:          *sampleErrors,
+			SamplesReceived:       *samplesReceived,
+			TagErrors:             tagErrors,
+			TagsReceived:          tagsReceived,
+			TCPConnections:        tcpConnections,
+			TCPErrors:             tcpErrors,
+			TCPLineTooLong:        tcpLineTooLong,
+			AcceptZstd:            *tcpAcceptZstd,
+			HighWaterMark:         *tcpHighWaterMark,
+			BackpressureLine:      *tcpBackpressureLine,
+			TCPBackpressure:       tcpBackpressure,
+			SlowClientTimeout:     *tcpSlowClientTimeout,
+			DisconnectSlowClients: *tcpDisconnectSlow,
+			TCPSlowClients:        tcpSlowClients,
+			Peers:                 tcpPeers,
+			AllowedSources:        sources,
+			RejectedConnections:   tcpRejectedConnections,
+		}
+		if tlsConfig != nil {
+			tl.TLSConfig = tlsConfig
+			tl.TLSHandshakeErrors = tcpTLSHandshakeErrors
+			tl.IdentityLabels = tcpIdentityLabels
+			tl.IdentityPrefix = identityPrefix
 		}
 
-		go tl.Listen()
+		go healthMon.runListener(ctx, proto+" "+addr, tl.Listen)
+		return tconn
+	}
+
+	if *statsdListenUDP != "" {
+		startUDPListener("udp", *statsdListenUDP, listenerLabels.parser("udp", lineParser), lineRelay, eventHandler)
+	}
+
+	if *statsdListenTCP != "" {
+		tconn := startTCPListener("tcp", *statsdListenTCP, listenerLabels.parser("tcp", lineParser), lineRelay, eventHandler, tcpTLS, *tcpTLSIdentityPrefix)
+		defer tconn.Close()
+	}
+
+	for _, t := range tenants {
+		logger.Info("Accepting StatsD Traffic for tenant", "tenant", t.config.Name, "udp", t.config.ListenUDP, "tcp", t.config.ListenTCP, "prefix", t.config.Prefix)
+		if t.config.ListenUDP != "" {
+			startUDPListener("udp", t.config.ListenUDP, labeledParser(lineParser, t.config.Labels), lineRelay, t.queue)
+		}
+		if t.config.ListenTCP != "" {
+			tconn := startTCPListener("tcp", t.config.ListenTCP, labeledParser(lineParser, t.config.Labels), lineRelay, t.queue, tcpTLS, "")
+			defer tconn.Close()
+		}
+	}
+
+	if *influxListenUDP != "" || *influxListenTCP != "" {
+		var influxConfig line.InfluxConfig
+		if *influxConfigFile != "" {
+			influxConfig, err = line.LoadInfluxConfig(*influxConfigFile)
+			if err != nil {
+				logger.Error("Error loading InfluxDB configuration", "error", err)
+				os.Exit(1)
+			}
+		}
+		influxParser, err := line.NewInfluxParser(influxConfig)
+		if err != nil {
+			logger.Error("Invalid InfluxDB configuration", "file", *influxConfigFile, "error", err)
+			os.Exit(1)
+		}
+		// InfluxDB lines are not StatsD lines, so they are not relayed.
+		if *influxListenUDP != "" {
+			startUDPListener("influxdb-udp", *influxListenUDP, listenerLabels.parser("influxdb-udp", influxParser), nil, eventHandler)
+		}
+		if *influxListenTCP != "" {
+			tconn := startTCPListener("influxdb-tcp", *influxListenTCP, listenerLabels.parser("influxdb-tcp", influxParser), nil, eventHandler, nil, "")
+			defer tconn.Close()
+		}
+	}
+
+	if *udpKernelDrops && len(udpListeners) > 0 {
+		ports := make([]uint16, 0, len(udpListeners))
+		for port := range udpListeners {
+			ports = append(ports, port)
+		}
+		if monitor, err := newUDPDropMonitor(ports); err != nil {
+			logger.Warn("Not counting kernel UDP drops", "error", err)
+		} else {
+			defer monitor.Close()
+			prometheus.MustRegister(newUDPDropsCollector(monitor, udpListeners, logger))
+		}
 	}
 
 	if *statsdListenUnixgram != "" {
@@ -444,8 +1574,7 @@ func main() {
 		defer uxgconn.Close()
 
 		if *readBuffer != 0 {
-			err = uxgconn.SetReadBuffer(*readBuffer)
-			if err != nil {
+			if err := setReadBuffer(uxgconn, *readBuffer, logger.With("listener", "unixgram", "address", *statsdListenUnixgram)); err != nil {
 				logger.Error("error setting Unixgram read buffer", "error", err)
 				os.Exit(1)
 			}
@@ -453,20 +1582,40 @@ func main() {
 
 		ul := &listener.StatsDUnixgramListener{
 			Conn:            uxgconn,
-			EventHandler:    eventQueue,
-			Logger:          logger,
-			LineParser:      parser,
+			EventHandler:    eventHandler,
+			Logger:          listenerLogger.With("listener", "unixgram", "address", *statsdListenUnixgram),
+			LineParser:      listenerLabels.parser("unixgram", lineParser),
 			UnixgramPackets: unixgramPackets,
 			LinesReceived:   linesReceived,
 			EventsFlushed:   eventsFlushed,
-			Relay:           relayTarget,
+			Relay:           lineRelay,
 			SampleErrors:    *sampleErrors,
-			SamplesReceived: samplesReceived,
+			SamplesReceived: *samplesReceived,
 			TagErrors:       tagErrors,
 			TagsReceived:    tagsReceived,
+			MaxLineLength:   *maxLineLength,
+			MaxPacketLines:  *maxPacketLines,
+			LineTooLong:     unixgramLineTooLong,
+			ExcessLines:     unixgramExcessLines,
+			AcceptFrames:    *unixgramAcceptFrames,
+			Frames:          unixgramFrames,
+			UnknownFrames:   unixgramUnknownFrames,
+			TagHeaders: listener.TagHeaders{
+				Enabled:     *dogstatsdTagsEnabled,
+				Headers:     tagHeaders.WithLabelValues("unixgram"),
+				PacketLines: datagramLines.WithLabelValues("unixgram"),
+			},
+		}
+		ul.CredentialLabels, err = parseCredentialLabels(*unixgramCredLabels)
+		if err != nil {
+			logger.Error("Invalid --statsd.unixgram-credential-label", "error", err)
+			os.Exit(1)
+		}
+		if *unixgramLinesByUID {
+			ul.LinesByUID = unixgramLinesByUIDTotal
 		}
 
-		go ul.Listen()
+		go healthMon.runListener(ctx, "unixgram "+*statsdListenUnixgram, ul.Listen)
 
 		// if it's an abstract unix domain socket, it won't exist on fs
 		// so we can't chmod it either
@@ -486,8 +1635,136 @@ func main() {
 		}
 	}
 
-	mux := http.DefaultServeMux
-	mux.Handle(*metricsEndpoint, promhttp.Handler())
+	if *statsdListenPipe != "" {
+		pl := &listener.StatsDNamedPipeListener{
+			Path:            *statsdListenPipe,
+			EventHandler:    eventHandler,
+			Logger:          listenerLogger.With("listener", "pipe", "address", *statsdListenPipe),
+			LineParser:      listenerLabels.parser("pipe", lineParser),
+			LinesReceived:   linesReceived,
+			EventsFlushed:   eventsFlushed,
+			Relay:           lineRelay,
+			SampleErrors:    *sampleErrors,
+			SamplesReceived: *samplesReceived,
+			TagErrors:       tagErrors,
+			TagsReceived:    tagsReceived,
+			PipeConnections: pipeConnections,
+			PipeErrors:      pipeErrors,
+			PipeLineTooLong: pipeLineTooLong,
+		}
+
+		go healthMon.runListener(ctx, "pipe "+*statsdListenPipe, pl.Listen)
+	}
+
+	// Standard input ends when the producer is done, so the stdin listener
+	// is not watched by the health monitor.
+	stdinDone := make(chan struct{})
+	if *statsdListenStdin {
+		sl := &listener.StatsDStdinListener{
+			Reader:          os.Stdin,
+			EventHandler:    eventHandler,
+			Logger:          listenerLogger.With("listener", "stdin"),
+			LineParser:      listenerLabels.parser("stdin", lineParser),
+			LinesReceived:   linesReceived,
+			EventsFlushed:   eventsFlushed,
+			Relay:           lineRelay,
+			SampleErrors:    *sampleErrors,
+			SamplesReceived: *samplesReceived,
+			TagErrors:       tagErrors,
+			TagsReceived:    tagsReceived,
+		}
+		go func() {
+			defer close(stdinDone)
+			if err := sl.Listen(ctx); err != nil {
+				logger.Error("Unable to read standard input", "error", err)
+				if *oneShot {
+					os.Exit(1)
+				}
+				return
+			}
+			logger.Info("Reached the end of standard input")
+		}()
+	}
+
+	if *statsdListenFirehose != "" {
+		fl := &listener.StatsDFirehoseListener{
+			EventHandler:    eventHandler,
+			Logger:          listenerLogger.With("listener", "firehose", "address", *statsdListenFirehose),
+			LineParser:      listenerLabels.parser("firehose", lineParser),
+			LinesReceived:   linesReceived,
+			EventsFlushed:   eventsFlushed,
+			Relay:           lineRelay,
+			SampleErrors:    *sampleErrors,
+			SamplesReceived: *samplesReceived,
+			TagErrors:       tagErrors,
+			TagsReceived:    tagsReceived,
+			HighWaterMark:   *firehoseHighWater,
+			Requests:        firehoseRequests,
+			Records:         firehoseRecords,
+		}
+		if *firehoseKeyFile != "" {
+			fl.AccessKey, err = readBearerToken(*firehoseKeyFile)
+			if err != nil {
+				logger.Error("Unable to read the Firehose access key", "error", err)
+				os.Exit(1)
+			}
+		}
+		fconn, err := net.Listen("tcp", *statsdListenFirehose)
+		if err != nil {
+			logger.Error("failed to start Firehose listener", "error", err)
+			os.Exit(1)
+		}
+		defer fconn.Close()
+		server := &http.Server{Handler: fl, ReadHeaderTimeout: 10 * time.Second}
+		go healthMon.runListener(ctx, "firehose "+*statsdListenFirehose, func(ctx context.Context) error {
+			stop := context.AfterFunc(ctx, func() { server.Close() })
+			defer stop()
+			if err := server.Serve(fconn); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		})
+	}
+
+	// Like the default registry, tenant registries are not exposed in dry-run
+	// mode.
+	var tenantMetrics map[string]prometheus.Gatherer
+	if !*dryRun {
+		tenantMetrics = tenantGatherers(tenants, sweepOnScrape)
+		for name, g := range tenantMetrics {
+			tenantMetrics[name] = ha.gatherer(g)
+		}
+	}
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if haData != nil {
+		gatherer = prometheus.Gatherers{gatherer, haData}
+	}
+	if sweepOnScrape {
+		gatherer = exporter.SweepingGatherer(gatherer)
+	}
+	gatherer = exporter.GaugeHistogramGatherer(gatherer)
+	if *scrapeCacheMaxAge > 0 {
+		sc := newScrapeCache(gatherer, *scrapeCacheMaxAge)
+		go sc.run(ctx)
+		gatherer = sc
+		for name, g := range tenantMetrics {
+			sc := newScrapeCache(g, *scrapeCacheMaxAge)
+			go sc.run(ctx)
+			tenantMetrics[name] = sc
+		}
+	}
+	metricsHandler := newMetricsHandler(prometheus.DefaultRegisterer, gatherer, tenantMetrics, *enableOpenMetrics, logger)
+	mux.Handle(*metricsEndpoint, newMetricsEncodingHandler(metricsHandler, metricsEncodings, int(*metricsChunkSize), metricsResponseSize, metricsEncodeDuration))
+	if named != nil {
+		named.handler = func(g prometheus.Gatherer) http.Handler {
+			g = ha.gatherer(g)
+			if sweepOnScrape {
+				g = exporter.SweepingGatherer(g)
+			}
+			return gathererHandler(exporter.GaugeHistogramGatherer(g), *enableOpenMetrics, logger)
+		}
+		mux.Handle(named.prefix, newMetricsEncodingHandler(named, metricsEncodings, int(*metricsChunkSize), metricsResponseSize, metricsEncodeDuration))
+	}
 	if *metricsEndpoint != "/" && *metricsEndpoint != "" {
 		landingConfig := web.LandingConfig{
 			Name:        "StatsD Exporter",
@@ -498,6 +1775,31 @@ func main() {
 					Address: *metricsEndpoint,
 					Text:    "Metrics",
 				},
+				{
+					Address:     "/-/healthy",
+					Text:        "Health",
+					Description: "whether the listeners, event loops and event queues are healthy",
+				},
+				{
+					Address:     "/-/ready",
+					Text:        "Readiness",
+					Description: "whether the exporter is ready to receive traffic",
+				},
+				{
+					Address:     buildInfoPath,
+					Text:        "Build information",
+					Description: "version, revision and Go version the exporter was built with",
+				},
+				{
+					Address:     configStatusPath,
+					Text:        "Configuration status",
+					Description: "file, hash and last reload of the mapping configurations",
+				},
+				{
+					Address:     "/api/v1/metadata",
+					Text:        "Metadata",
+					Description: "type, help and mappings of the converted metrics",
+				},
 			},
 		}
 		landingPage, err := web.NewLandingPage(landingConfig)
@@ -508,48 +1810,153 @@ func main() {
 		mux.Handle("/", landingPage)
 	}
 
+	if conflictLog != nil {
+		mux.Handle("/api/v1/conflicts", conflictLog)
+	}
+	mux.HandleFunc(buildInfoPath, buildInfoHandler)
+	mux.HandleFunc(configStatusPath, configStatusHandler)
+	mux.Handle("/api/v1/metadata", tenantMetadataHandler(exporter, tenants))
+	mux.Handle("/api/v1/histogram_buckets", tenantLearnedBucketsHandler(exporter, tenants))
+	mux.Handle("/api/v1/memory", tenantMemoryUsageHandler(exporter, tenants))
+	if dialectDetector != nil {
+		mux.Handle("/api/v1/dialects", dialectDetector)
+	}
+
 	quitChan := make(chan struct{}, 1)
 
 	if *enableLifecycle {
-		mux.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		mux.Handle("/-/reload", admin.protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodPut || r.Method == http.MethodPost {
-				fmt.Fprintf(w, "Requesting reload")
+				var errs []error
+				for _, t := range tenants {
+					if err := t.reloadConfig(logger); err != nil {
+						errs = append(errs, fmt.Errorf("tenant %s: %w", t.config.Name, err))
+					}
+				}
 				if *mappingConfig == "" {
 					logger.Warn("Received lifecycle api reload but no mapping config to reload")
+				} else {
+					logger.Info("Received lifecycle api reload, attempting reload")
+					if err := reloadConfig(*mappingConfig, thisMapper, "", logger); err != nil {
+						errs = append(errs, err)
+					}
+				}
+				if err := errors.Join(errs...); err != nil {
+					http.Error(w, fmt.Sprintf("Failed to reload config, the previous config stays in effect: %s", err), http.StatusInternalServerError)
 					return
 				}
-				logger.Info("Received lifecycle api reload, attempting reload")
-				reloadConfig(*mappingConfig, thisMapper, logger)
+				fmt.Fprintf(w, "Config reloaded")
 			}
-		})
-		mux.HandleFunc("/-/quit", func(w http.ResponseWriter, r *http.Request) {
+		})))
+		mux.Handle("/-/quit", admin.protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodPut || r.Method == http.MethodPost {
 				fmt.Fprintf(w, "Requesting termination... Goodbye!")
 				quitChan <- struct{}{}
 			}
-		})
+		})))
+		mux.Handle("/-/relay", admin.protect(relayTargets))
+	}
+	mux.Handle("/-/snapshot", admin.protect(exporter.SnapshotHandler()))
+	if ha != nil {
+		mux.Handle("/-/leader", ha)
 	}
 
-	mux.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			logger.Debug("Received health check")
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprintf(w, "Statsd Exporter is Healthy.\n")
-		}
-	})
+	if !*waitForConfig && !*oneShot {
+		go serveHTTP(mux, toolkitFlags, logger)
+	}
 
-	mux.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			logger.Debug("Received ready check")
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprintf(w, "Statsd Exporter is Ready.\n")
+	go sighupConfigReloader(*mappingConfig, thisMapper, tenants, logger)
+	if *mappingConfigWatch {
+		if *mappingConfig != "" {
+			w := newConfigWatcher(mapperFiles(*mappingConfig, thisMapper), func() { reloadConfig(*mappingConfig, thisMapper, "", logger) }, logger)
+			go w.watch(*mappingWatchDebounce)
 		}
-	})
+		for _, t := range tenants {
+			if t.config.MappingConfig != "" {
+				w := newConfigWatcher(mapperFiles(t.config.MappingConfig, t.mapper), func() { t.reloadConfig(logger) }, logger)
+				go w.watch(*mappingWatchDebounce)
+			}
+		}
+	}
+	if *restoreSnapshot != "" {
+		if err := restoreFromSnapshot(exporter, *restoreSnapshot, logger); err != nil {
+			logger.Error("Error restoring snapshot", "file", *restoreSnapshot, "error", err)
+			os.Exit(1)
+		}
+	}
+	if kafkaOutput != nil {
+		go kafkaOutput.run(ctx)
+	}
+	exporterDone := make(chan struct{})
+	go func() {
+		defer close(exporterDone)
+		exporter.Listen(ctx, events)
+	}()
+	if *syntheticSeries > 0 {
+		logger.Info("Creating synthetic series", "series", *syntheticSeries)
+		fillSynthetic(*syntheticSeries, func(e event.Events) { events <- e })
+	}
+	healthMon.addEventLoop("default", exporter, events)
+	for _, t := range tenants {
+		go t.exporter.Listen(ctx, t.events)
+		healthMon.addEventLoop("tenant "+t.config.Name, t.exporter, t.events)
+	}
+	go healthMon.run(*healthInterval)
+	if *overloadProfiles > 0 {
+		if *overloadThreshold <= 0 || *overloadThreshold > 1 {
+			logger.Error("--debug.overload-profile-threshold must be greater than 0 and at most 1")
+			os.Exit(1)
+		}
+		profiler := newOverloadProfiler(*overloadThreshold, *overloadAfter, *overloadDuration, *overloadProfiles, overloadProfilesCaptured, logger)
+		profiler.addQueue("default", events)
+		for _, t := range tenants {
+			profiler.addQueue("tenant "+t.config.Name, t.events)
+		}
+		mux.Handle(overloadProfilesPath, admin.protect(profiler))
+		mux.Handle(overloadProfilesPath+"/", admin.protect(profiler))
+		go profiler.run(time.Second)
+	}
+	if tail != nil {
+		mux.Handle(tailPath, admin.protect(tail))
+	}
+	if ha != nil {
+		ha.update()
+		go ha.run()
+	}
+	if *forwardAddr != "" {
+		forwardRelay, err := relay.NewRelay(relayLogger, *forwardAddr, *forwardPacketLen)
+		if err != nil {
+			logger.Error("Unable to create forwarder", "err", err)
+			os.Exit(1)
+		}
+		f := newForwarder(forwardRelay, exporter, tenants)
+		f.ha = ha
+		go f.run(*forwardInterval)
+	}
 
-	go serveHTTP(mux, *listenAddress, logger)
+	if *waitForConfig && *warmup > 0 {
+		logger.Info("Waiting for warmup before reporting ready", "warmup", *warmup)
+		time.AfterFunc(*warmup, func() {
+			ready.Store(true)
+			logger.Info("Ready")
+		})
+	} else {
+		ready.Store(true)
+	}
 
-	go sighupConfigReloader(*mappingConfig, thisMapper, logger)
-	go exporter.Listen(events)
+	if *oneShot {
+		<-stdinDone
+		eventQueue.Flush()
+		drainEventLoop(exporter, events)
+		// Stopping the event loop applies coalesced gauge updates.
+		cancel()
+		<-exporterDone
+		if err := printExposition(os.Stdout, oneShotRegistry); err != nil {
+			logger.Error("Unable to print the metrics", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
@@ -560,5 +1967,8 @@ func main() {
 		logger.Info("Received os signal, exiting", "signal", sig.String())
 	case <-quitChan:
 		logger.Info("Received lifecycle api quit, exiting")
+	case <-serviceStop:
+		logger.Info("Received Windows service stop request, exiting")
 	}
+	cancel()
 }
@@ -18,8 +18,11 @@ import "fmt"
 type ActionType string
 
 const (
-	ActionTypeMap     ActionType = "map"
-	ActionTypeDrop    ActionType = "drop"
+	ActionTypeMap  ActionType = "map"
+	ActionTypeDrop ActionType = "drop"
+	// ActionTypeSample maps a random fraction of the matching events, given
+	// by the sample_ratio of the mapping, and drops the rest.
+	ActionTypeSample  ActionType = "sample"
 	ActionTypeDefault ActionType = ""
 )
 
@@ -33,6 +36,8 @@ func (t *ActionType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	switch ActionType(v) {
 	case ActionTypeDrop:
 		*t = ActionTypeDrop
+	case ActionTypeSample:
+		*t = ActionTypeSample
 	case ActionTypeMap, ActionTypeDefault:
 		*t = ActionTypeMap
 	default:
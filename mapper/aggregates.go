@@ -0,0 +1,70 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultAggregates are the gauges that aggregated gauges export if a
+// mapping does not select others.
+var DefaultAggregates = []string{"min", "max", "avg", "count"}
+
+// The aggregates of aggregated gauges besides percentiles, which are given as
+// NNpercentile, such as 95percentile, like in the Datadog agent.
+const (
+	AggregateMin    = "min"
+	AggregateMax    = "max"
+	AggregateAvg    = "avg"
+	AggregateSum    = "sum"
+	AggregateCount  = "count"
+	AggregateMedian = "median"
+)
+
+// AggregateQuantile returns the quantile of an aggregate that is a median or
+// percentile, and false for all others.
+func AggregateQuantile(aggregate string) (float64, bool) {
+	if aggregate == AggregateMedian {
+		return 0.5, true
+	}
+	p, ok := strings.CutSuffix(aggregate, "percentile")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(p)
+	if err != nil || n < 1 || n > 99 || strconv.Itoa(n) != p {
+		return 0, false
+	}
+	return float64(n) / 100, true
+}
+
+// validateAggregates checks the aggregates selected by a mapping.
+func validateAggregates(aggregates []string) error {
+	for i, a := range aggregates {
+		switch a {
+		case AggregateMin, AggregateMax, AggregateAvg, AggregateSum, AggregateCount:
+		default:
+			if _, ok := AggregateQuantile(a); !ok {
+				return fmt.Errorf("invalid aggregate %q, must be one of min, max, avg, sum, count, median or NNpercentile", a)
+			}
+		}
+		if slices.Contains(aggregates[:i], a) {
+			return fmt.Errorf("aggregate %s is listed twice", a)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultAutoBucketsWarmup is how long the observations of a histogram
+	// are learned from if no warmup is configured.
+	DefaultAutoBucketsWarmup = 10 * time.Minute
+	// DefaultAutoBucketsCount is the number of buckets learned if no count
+	// is configured.
+	DefaultAutoBucketsCount = 10
+)
+
+// AutoBucketsOptions make the exporter learn the buckets of a histogram from
+// the observations made during a warmup period, starting with the first
+// observation. During the warmup, the configured or default buckets are used.
+type AutoBucketsOptions struct {
+	Warmup time.Duration `yaml:"warmup"`
+	// Count is the number of buckets, not counting the +Inf bucket. Fewer
+	// buckets are used if several of them would have the same boundary.
+	Count int `yaml:"count"`
+}
+
+// init validates the options and fills in the defaults.
+func (o *AutoBucketsOptions) init() error {
+	if o.Warmup < 0 {
+		return fmt.Errorf("auto_buckets warmup must not be negative")
+	}
+	if o.Warmup == 0 {
+		o.Warmup = DefaultAutoBucketsWarmup
+	}
+	if o.Count < 0 {
+		return fmt.Errorf("auto_buckets count must not be negative")
+	}
+	if o.Count == 0 {
+		o.Count = DefaultAutoBucketsCount
+	}
+	return nil
+}
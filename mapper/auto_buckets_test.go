@@ -0,0 +1,130 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoBucketsConfig(t *testing.T) {
+	m := &MetricMapper{}
+	err := m.InitFromYAMLString(`
+defaults:
+  observer_type: histogram
+  histogram_options:
+    auto_buckets:
+      count: 5
+mappings:
+- match: inherited.*
+  name: inherited
+- match: explicit.*
+  name: explicit
+  histogram_options:
+    auto_buckets:
+      warmup: 30s
+- match: hand_picked.*
+  name: hand_picked
+  histogram_options:
+    buckets: [1, 2, 3]
+- match: summary.*
+  name: summary
+  observer_type: summary
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inherited := m.Mappings[0].HistogramOptions.AutoBuckets
+	if inherited == nil || inherited.Warmup != DefaultAutoBucketsWarmup || inherited.Count != 5 {
+		t.Errorf("expected the default auto_buckets to be inherited, got %+v", inherited)
+	}
+	explicit := m.Mappings[1].HistogramOptions.AutoBuckets
+	if explicit == nil || explicit.Warmup != 30*time.Second || explicit.Count != DefaultAutoBucketsCount {
+		t.Errorf("expected explicit auto_buckets with the default count, got %+v", explicit)
+	}
+	if handPicked := m.Mappings[2].HistogramOptions.AutoBuckets; handPicked != nil {
+		t.Errorf("expected no auto_buckets with explicit buckets, got %+v", handPicked)
+	}
+	if m.Mappings[3].HistogramOptions != nil {
+		t.Errorf("expected no histogram options for a summary, got %+v", m.Mappings[3].HistogramOptions)
+	}
+}
+
+func TestAutoBucketsConfigErrors(t *testing.T) {
+	scenarios := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "summary",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  histogram_options:
+    auto_buckets: {}
+`,
+			err: "auto_buckets can only be used with histograms in test.*",
+		},
+		{
+			name: "gauge histogram",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  observer_type: gaugehistogram
+  histogram_options:
+    auto_buckets: {}
+`,
+			err: "auto_buckets can only be used with histograms in test.*",
+		},
+		{
+			name: "negative warmup",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  observer_type: histogram
+  histogram_options:
+    auto_buckets:
+      warmup: -1m
+`,
+			err: "auto_buckets warmup must not be negative",
+		},
+		{
+			name: "negative default count",
+			config: `
+defaults:
+  histogram_options:
+    auto_buckets:
+      count: -1
+mappings: []
+`,
+			err: "auto_buckets count must not be negative",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			m := &MetricMapper{}
+			err := m.InitFromYAMLString(s.config)
+			if err == nil || !strings.Contains(err.Error(), s.err) {
+				t.Fatalf("expected error containing %q, got %v", s.err, err)
+			}
+		})
+	}
+}
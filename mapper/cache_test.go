@@ -0,0 +1,55 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "sync"
+
+// testCache is a minimal MetricMapperCache that evicts an arbitrary entry
+// when it is full. The cache implementations of the exporter live outside of
+// this module and are tested with the mapper there.
+type testCache struct {
+	size int
+
+	mtx     sync.Mutex
+	entries map[string]interface{}
+}
+
+func newTestCache(size int) *testCache {
+	return &testCache{size: size, entries: map[string]interface{}{}}
+}
+
+func (c *testCache) Get(metricKey string) (interface{}, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	result, ok := c.entries[metricKey]
+	return result, ok
+}
+
+func (c *testCache) Add(metricKey string, result interface{}) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.entries[metricKey]; !ok && len(c.entries) >= c.size {
+		for key := range c.entries {
+			delete(c.entries, key)
+			break
+		}
+	}
+	c.entries[metricKey] = result
+}
+
+func (c *testCache) Reset() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.entries = map[string]interface{}{}
+}
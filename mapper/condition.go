@@ -0,0 +1,337 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/statsd_exporter/mapper/fsm"
+)
+
+// Condition is a boolean expression over the captures of a match. It
+// supports comparing captures (`$1`, `${2}`) and double quoted string
+// literals with `==`, `!=`, `=~` and `!~`, and combining comparisons with
+// `&&`, `||`, `!` and parentheses. Regular expressions are fully anchored.
+//
+//	$3 == "ok" || ($3 =~ "2.." && $1 != "internal")
+type Condition struct {
+	expr string
+	root conditionNode
+}
+
+// ConditionalLabelValue is a label value that applies when its condition
+// holds. A value without a condition always applies.
+type ConditionalLabelValue struct {
+	When  *Condition `yaml:"when"`
+	Value string     `yaml:"value"`
+}
+
+// ParseCondition parses a condition expression.
+func ParseCondition(expr string) (*Condition, error) {
+	p := &conditionParser{input: expr}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("invalid condition %q: unexpected %q", expr, p.tokens[p.pos].text)
+	}
+	return &Condition{expr: expr, root: root}, nil
+}
+
+func (c *Condition) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var expr string
+	if err := unmarshal(&expr); err != nil {
+		return err
+	}
+	parsed, err := ParseCondition(expr)
+	if err != nil {
+		return err
+	}
+	*c = *parsed
+	return nil
+}
+
+func (c *Condition) String() string {
+	return c.expr
+}
+
+// Eval reports whether the condition holds for the given captures, where
+// captures[0] holds the value of `$1`.
+func (c *Condition) Eval(captures []string) bool {
+	return c.root.eval(captures)
+}
+
+// applyConditions evaluates drop_when and conditional_labels of a matched
+// mapping against the captures of the match.
+func (m *MetricMapping) applyConditions(captures []string, labels map[string]string) {
+	if m.DropWhen != nil && m.DropWhen.Eval(captures) {
+		m.Action = ActionTypeDrop
+	}
+	for label, values := range m.ConditionalLabels {
+		for _, v := range values {
+			if v.When == nil || v.When.Eval(captures) {
+				labels[label] = fsm.NewTemplateFormatter(v.Value, len(captures)).Format(captures)
+				break
+			}
+		}
+	}
+}
+
+type conditionNode interface {
+	eval(captures []string) bool
+}
+
+type conditionOr struct{ left, right conditionNode }
+
+func (n conditionOr) eval(captures []string) bool {
+	return n.left.eval(captures) || n.right.eval(captures)
+}
+
+type conditionAnd struct{ left, right conditionNode }
+
+func (n conditionAnd) eval(captures []string) bool {
+	return n.left.eval(captures) && n.right.eval(captures)
+}
+
+type conditionNot struct{ node conditionNode }
+
+func (n conditionNot) eval(captures []string) bool {
+	return !n.node.eval(captures)
+}
+
+// conditionOperand is either a capture reference or a literal.
+type conditionOperand struct {
+	capture int
+	literal string
+}
+
+func (o conditionOperand) value(captures []string) string {
+	if o.capture == 0 {
+		return o.literal
+	}
+	if o.capture > len(captures) {
+		return ""
+	}
+	return captures[o.capture-1]
+}
+
+type conditionCompare struct {
+	left, right conditionOperand
+	op          string
+	regex       *regexp.Regexp
+}
+
+func (n conditionCompare) eval(captures []string) bool {
+	left := n.left.value(captures)
+	switch n.op {
+	case "==":
+		return left == n.right.value(captures)
+	case "!=":
+		return left != n.right.value(captures)
+	case "=~":
+		return n.regex.MatchString(left)
+	default: // "!~"
+		return !n.regex.MatchString(left)
+	}
+}
+
+type conditionTokenKind int
+
+const (
+	tokenOperator conditionTokenKind = iota
+	tokenCapture
+	tokenString
+)
+
+type conditionToken struct {
+	kind conditionTokenKind
+	text string
+}
+
+type conditionParser struct {
+	input  string
+	tokens []conditionToken
+	pos    int
+}
+
+var (
+	conditionOperators = []string{"&&", "||", "==", "!=", "=~", "!~", "!", "(", ")"}
+	conditionCaptureRE = regexp.MustCompile(`^\$(?:([0-9]+)|\{([0-9]+)\})`)
+)
+
+func (p *conditionParser) tokenize() error {
+	s := p.input
+	for len(s) > 0 {
+		r := rune(s[0])
+		switch {
+		case unicode.IsSpace(r):
+			s = s[1:]
+		case r == '"':
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return fmt.Errorf("invalid condition %q: unterminated string", p.input)
+			}
+			lit, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return fmt.Errorf("invalid condition %q: %v", p.input, err)
+			}
+			p.tokens = append(p.tokens, conditionToken{tokenString, lit})
+			s = s[end+1:]
+		case r == '$':
+			m := conditionCaptureRE.FindStringSubmatch(s)
+			if m == nil {
+				return fmt.Errorf("invalid condition %q: invalid capture reference", p.input)
+			}
+			p.tokens = append(p.tokens, conditionToken{tokenCapture, m[1] + m[2]})
+			s = s[len(m[0]):]
+		default:
+			found := false
+			for _, op := range conditionOperators {
+				if strings.HasPrefix(s, op) {
+					p.tokens = append(p.tokens, conditionToken{tokenOperator, op})
+					s = s[len(op):]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("invalid condition %q: unexpected character %q", p.input, r)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *conditionParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == op
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = conditionOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = conditionAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseUnary() (conditionNode, error) {
+	switch {
+	case p.peek("!"):
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return conditionNot{node}, nil
+	case p.peek("("):
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("invalid condition %q: missing closing parenthesis", p.input)
+		}
+		p.pos++
+		return node, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *conditionParser) parseCompare() (conditionNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenOperator {
+		return nil, fmt.Errorf("invalid condition %q: expected comparison operator", p.input)
+	}
+	n := conditionCompare{left: left, op: p.tokens[p.pos].text}
+	switch n.op {
+	case "==", "!=", "=~", "!~":
+	default:
+		return nil, fmt.Errorf("invalid condition %q: expected comparison operator, got %q", p.input, n.op)
+	}
+	p.pos++
+	if n.right, err = p.parseOperand(); err != nil {
+		return nil, err
+	}
+	if n.op == "=~" || n.op == "!~" {
+		if n.right.capture != 0 {
+			return nil, fmt.Errorf("invalid condition %q: regular expression must be a string literal", p.input)
+		}
+		if n.regex, err = regexp.Compile("^(?:" + n.right.literal + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %v", p.input, err)
+		}
+	}
+	return n, nil
+}
+
+func (p *conditionParser) parseOperand() (conditionOperand, error) {
+	if p.pos >= len(p.tokens) {
+		return conditionOperand{}, fmt.Errorf("invalid condition %q: unexpected end of expression", p.input)
+	}
+	t := p.tokens[p.pos]
+	switch t.kind {
+	case tokenCapture:
+		idx, err := strconv.Atoi(t.text)
+		if err != nil || idx < 1 {
+			return conditionOperand{}, fmt.Errorf("invalid condition %q: invalid capture reference $%s", p.input, t.text)
+		}
+		p.pos++
+		return conditionOperand{capture: idx}, nil
+	case tokenString:
+		p.pos++
+		return conditionOperand{literal: t.text}, nil
+	}
+	return conditionOperand{}, fmt.Errorf("invalid condition %q: unexpected %q", p.input, t.text)
+}
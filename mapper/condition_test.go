@@ -0,0 +1,146 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "testing"
+
+func TestConditionEval(t *testing.T) {
+	captures := []string{"web", "get", "ok"}
+	scenarios := map[string]bool{
+		`$3 == "ok"`:                  true,
+		`${3} != "ok"`:                false,
+		`$1 =~ "w.b"`:                 true,
+		`$1 =~ "w"`:                   false,
+		`$2 !~ "post|put"`:            true,
+		`$1 == "web" && $2 == "post"`: false,
+		`$1 == "web" || $2 == "post"`: true,
+		`!($1 == "web")`:              false,
+		`$4 == ""`:                    true,
+		`"a \"quoted\" string" == "a \"quoted\" string"`: true,
+		`$2 == "post" || $3 == "ok" && $1 == "api"`:      false,
+	}
+
+	for expr, want := range scenarios {
+		c, err := ParseCondition(expr)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %v", expr, err)
+		}
+		if got := c.Eval(captures); got != want {
+			t.Errorf("expected `%s` to evaluate to %v, got %v", expr, want, got)
+		}
+	}
+}
+
+func TestConditionParseErrors(t *testing.T) {
+	scenarios := []string{
+		``,
+		`$1`,
+		`$1 ==`,
+		`$1 = "a"`,
+		`$0 == "a"`,
+		`$1 == "a`,
+		`($1 == "a"`,
+		`$1 == "a")`,
+		`$1 =~ $2`,
+		`$1 =~ "("`,
+		`foo == "a"`,
+	}
+
+	for _, expr := range scenarios {
+		if _, err := ParseCondition(expr); err == nil {
+			t.Errorf("expected error parsing `%s`", expr)
+		}
+	}
+}
+
+func TestConditionalMappings(t *testing.T) {
+	config := `
+mappings:
+- match: api.*.*
+  name: api_requests_total
+  labels:
+    endpoint: $1
+  conditional_labels:
+    outcome:
+    - when: '$2 == "ok"'
+      value: success
+    - value: failure
+  drop_when: '$1 =~ "internal_.*"'
+- match: (.*)\.jobs\.(.*)
+  match_type: regex
+  name: jobs_total
+  conditional_labels:
+    kind:
+    - when: '$2 =~ "cron_.*"'
+      value: scheduled_$1
+  drop_when: '$1 == "test"'
+`
+	for _, cacheType := range []string{"none", "lru"} {
+		mapper := newTestMapperWithCache(cacheType, 10)
+		if err := mapper.InitFromYAMLString(config); err != nil {
+			t.Fatalf("config load error: %s", err)
+		}
+
+		scenarios := []struct {
+			metric string
+			labels map[string]string
+			drop   bool
+		}{
+			{metric: "api.users.ok", labels: map[string]string{"endpoint": "users", "outcome": "success"}},
+			{metric: "api.users.error", labels: map[string]string{"endpoint": "users", "outcome": "failure"}},
+			{metric: "api.internal_health.ok", labels: map[string]string{"endpoint": "internal_health", "outcome": "success"}, drop: true},
+			{metric: "prod.jobs.cron_backup", labels: map[string]string{"kind": "scheduled_prod"}},
+			{metric: "prod.jobs.adhoc", labels: map[string]string{}},
+			{metric: "test.jobs.adhoc", labels: map[string]string{}, drop: true},
+		}
+
+		for _, s := range scenarios {
+			// Look up twice to exercise the cache.
+			for i := 0; i < 2; i++ {
+				m, labels, present := mapper.GetMapping(s.metric, MetricTypeCounter)
+				if !present {
+					t.Fatalf("%s: expected %s to match", cacheType, s.metric)
+				}
+				if got := m.Action == ActionTypeDrop; got != s.drop {
+					t.Errorf("%s: expected drop %v for %s, got %v", cacheType, s.drop, s.metric, got)
+				}
+				if len(labels) != len(s.labels) {
+					t.Errorf("%s: expected labels %v for %s, got %v", cacheType, s.labels, s.metric, labels)
+				}
+				for k, v := range s.labels {
+					if labels[k] != v {
+						t.Errorf("%s: expected label %s=%q for %s, got %q", cacheType, k, v, s.metric, labels[k])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestConditionalLabelConflict(t *testing.T) {
+	config := `
+mappings:
+- match: api.*.*
+  name: api_requests_total
+  labels:
+    outcome: $2
+  conditional_labels:
+    outcome:
+    - value: failure
+`
+	mapper := MetricMapper{}
+	if err := mapper.InitFromYAMLString(config); err == nil {
+		t.Fatal("expected error for label set in both labels and conditional_labels")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "fmt"
+
+// CounterMode selects how the values of StatsD counters are applied.
+type CounterMode string
+
+const (
+	// CounterModeIncrement adds each value to the counter.
+	CounterModeIncrement CounterMode = "increment"
+	// CounterModeAbsolute takes each value for the cumulative total of the
+	// emitter, and increases the counter by the difference to the previous
+	// total. A total lower than the previous one is taken for a reset of
+	// the emitter.
+	CounterModeAbsolute CounterMode = "absolute"
+	CounterModeDefault  CounterMode = ""
+)
+
+func (m *CounterMode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch CounterMode(v) {
+	case CounterModeIncrement, CounterModeDefault:
+		*m = CounterModeIncrement
+	case CounterModeAbsolute:
+		*m = CounterModeAbsolute
+	default:
+		return fmt.Errorf("invalid counter_mode %q", v)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mapper maps StatsD metric names to Prometheus metric names, labels
+// and metric options, as configured in the mapping configuration of the
+// StatsD exporter.
+//
+// The package is a Go module of its own, github.com/prometheus/statsd_exporter/mapper,
+// versioned with tags of the form mapper/vX.Y.Z independently of the
+// exporter. Its exported API follows semantic versioning. Caches are
+// plugged in through the MetricMapperCache interface, so that the cache
+// implementations of the exporter can change without affecting users of
+// this module.
+package mapper
@@ -0,0 +1,65 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// envVarRE matches references to environment variables. Their names cannot
+// start with a digit, so captures like ${1} are left alone. $${ is an escape
+// for a literal ${, as needed for named regex capture groups.
+var envVarRE = regexp.MustCompile(`\$\$\{|\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in s with the value of the environment
+// variable VAR, as returned by lookup. It is an error to reference a variable
+// that is not set.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var err error
+	expanded := envVarRE.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		name := ref[2 : len(ref)-1]
+		value, ok := lookup(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// expandEnv replaces environment variable references in the metric name and
+// label values of the mapping.
+func (m *MetricMapping) expandEnv(lookup func(string) (string, bool)) error {
+	var err error
+	if m.Name, err = expandEnv(m.Name, lookup); err != nil {
+		return err
+	}
+	for label, value := range m.Labels {
+		if m.Labels[label], err = expandEnv(value, lookup); err != nil {
+			return err
+		}
+	}
+	for label, values := range m.ConditionalLabels {
+		for i := range values {
+			if values[i].Value, err = expandEnv(values[i].Value, lookup); err != nil {
+				return fmt.Errorf("label %s: %w", label, err)
+			}
+		}
+	}
+	return nil
+}
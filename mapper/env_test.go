@@ -0,0 +1,117 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"REGION": "eu-west-1", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	scenarios := []struct {
+		in  string
+		out string
+		err bool
+	}{
+		{in: "${REGION}", out: "eu-west-1"},
+		{in: "${1}_${REGION}_$2", out: "${1}_eu-west-1_$2"},
+		{in: "x${EMPTY}y", out: "xy"},
+		{in: "$${name}", out: "${name}"},
+		{in: "$REGION", out: "$REGION"},
+		{in: "${MISSING}", err: true},
+	}
+
+	for _, s := range scenarios {
+		out, err := expandEnv(s.in, lookup)
+		if s.err {
+			if err == nil {
+				t.Errorf("expected error expanding %q", s.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error expanding %q: %v", s.in, err)
+		}
+		if out != s.out {
+			t.Errorf("expected %q to expand to %q, got %q", s.in, s.out, out)
+		}
+	}
+}
+
+func TestMapperExpandEnv(t *testing.T) {
+	t.Setenv("STATSD_TEST_ENV", "prod")
+	t.Setenv("STATSD_TEST_PREFIX", "app")
+
+	config := `
+mappings:
+- match: test.*.requests
+  name: "${STATSD_TEST_PREFIX}_${1}_requests_total"
+  labels:
+    env: "${STATSD_TEST_ENV}"
+    client: "$1"
+- match: 'regex\.(?P<client>\w+)\.requests'
+  match_type: regex
+  name: "regex_requests_total"
+  labels:
+    env: "${STATSD_TEST_ENV}"
+    client: "$${client}"
+`
+
+	m := &MetricMapper{ExpandEnv: true}
+	if err := m.InitFromYAMLString(config); err != nil {
+		t.Fatalf("config load error: %v", err)
+	}
+
+	for metric, want := range map[string]struct {
+		name   string
+		labels prometheus.Labels
+	}{
+		"test.foo.requests":  {name: "app_foo_requests_total", labels: prometheus.Labels{"env": "prod", "client": "foo"}},
+		"regex.foo.requests": {name: "regex_requests_total", labels: prometheus.Labels{"env": "prod", "client": "foo"}},
+	} {
+		mapping, labels, present := m.GetMapping(metric, MetricTypeCounter)
+		if !present {
+			t.Fatalf("%s: expected a mapping", metric)
+		}
+		if mapping.Name != want.name {
+			t.Errorf("%s: expected name %q, got %q", metric, want.name, mapping.Name)
+		}
+		for k, v := range want.labels {
+			if labels[k] != v {
+				t.Errorf("%s: expected label %s=%q, got %q", metric, k, v, labels[k])
+			}
+		}
+	}
+
+	// Without the option, references are kept as they are and fail validation
+	// of the metric name.
+	if err := (&MetricMapper{}).InitFromYAMLString(config); err == nil {
+		t.Fatalf("expected unexpanded environment variable in metric name to be rejected")
+	}
+
+	if err := (&MetricMapper{ExpandEnv: true}).InitFromYAMLString(`
+mappings:
+- match: test.*
+  name: "${STATSD_TEST_UNSET}"
+`); err == nil {
+		t.Fatalf("expected reference to an unset environment variable to be rejected")
+	}
+}
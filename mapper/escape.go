@@ -21,6 +21,27 @@ import (
 // EscapeMetricName replaces invalid characters in the metric name with "_"
 // Valid characters are a-z, A-Z, 0-9, and _
 func EscapeMetricName(metricName string) string {
+	return Escaper{}.Escape(metricName)
+}
+
+// Escaper replaces the invalid characters in metric names. The zero value
+// escapes like EscapeMetricName.
+type Escaper struct {
+	// Char replaces invalid characters, and is prepended to names that start
+	// with a digit. It is '_' if zero.
+	Char byte
+	// DisableDashCollapsing replaces every dash in a run of dashes, instead
+	// of the run as a whole.
+	DisableDashCollapsing bool
+}
+
+// Escape replaces the invalid characters in the metric name.
+func (e Escaper) Escape(metricName string) string {
+	char := e.Char
+	if char == 0 {
+		char = '_'
+	}
+
 	metricLen := len(metricName)
 	if metricLen == 0 {
 		return ""
@@ -33,7 +54,7 @@ func EscapeMetricName(metricName string) string {
 	if metricName[0] >= '0' && metricName[0] <= '9' {
 		escaped = true
 		sb.Grow(metricLen + 1)
-		sb.WriteByte('_')
+		sb.WriteByte(char)
 	}
 
 	// This is an character replacement method optimized for this limited
@@ -52,7 +73,7 @@ func EscapeMetricName(metricName string) string {
 		} else {
 			// Double-dashes are allowed if there is a corresponding mapping.
 			// For consistency, double-dashes should also be allowed in the default case.
-			if c == '-' && prevChar == '-' {
+			if c == '-' && prevChar == '-' && !e.DisableDashCollapsing {
 				offset = i + utf8.RuneLen(c)
 				continue
 			}
@@ -66,7 +87,7 @@ func EscapeMetricName(metricName string) string {
 			}
 			sb.WriteString(metricName[offset:i])
 			offset = i + utf8.RuneLen(c)
-			sb.WriteByte('_')
+			sb.WriteByte(char)
 		}
 
 		prevChar = c
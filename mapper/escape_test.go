@@ -36,6 +36,23 @@ func TestEscapeMetricName(t *testing.T) {
 	}
 }
 
+func TestEscaper(t *testing.T) {
+	e := Escaper{Char: ':', DisableDashCollapsing: true}
+	scenarios := map[string]string{
+		"clean":                  "clean",
+		"0starts_with_digit":     ":0starts_with_digit",
+		"with--doubledash":       "with::doubledash",
+		"with---multiple-dashes": "with:::multiple:dashes",
+		"with.dot":               "with:dot",
+	}
+
+	for in, want := range scenarios {
+		if got := e.Escape(in); want != got {
+			t.Errorf("expected `%s` to be escaped to `%s`, got `%s`", in, want, got)
+		}
+	}
+}
+
 func BenchmarkEscapeMetricName(b *testing.B) {
 	scenarios := []string{
 		"clean",
@@ -1,4 +1,4 @@
-// Copyright 2013 The Prometheus Authors
+// Copyright 2026 The Prometheus Authors
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
 // You may obtain a copy of the License at
@@ -15,27 +15,31 @@ package mapper
 
 import "fmt"
 
-type ObserverType string
+// ExpireOnType selects which activity resets the TTL of a time series.
+type ExpireOnType string
 
 const (
-	ObserverTypeHistogram ObserverType = "histogram"
-	ObserverTypeSummary   ObserverType = "summary"
-	ObserverTypeDefault   ObserverType = ""
+	// ExpireOnNoReceive expires a time series that has not received any
+	// events for the TTL.
+	ExpireOnNoReceive ExpireOnType = "no_receive"
+	// ExpireOnNoChange expires a time series whose value has not changed for
+	// the TTL, even if events keep arriving.
+	ExpireOnNoChange ExpireOnType = "no_change"
+	ExpireOnDefault  ExpireOnType = ""
 )
 
-func (t *ObserverType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+func (t *ExpireOnType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var v string
+
 	if err := unmarshal(&v); err != nil {
 		return err
 	}
 
-	switch ObserverType(v) {
-	case ObserverTypeHistogram:
-		*t = ObserverTypeHistogram
-	case ObserverTypeSummary, ObserverTypeDefault:
-		*t = ObserverTypeSummary
+	switch ExpireOnType(v) {
+	case ExpireOnNoReceive, ExpireOnNoChange, ExpireOnDefault:
+		*t = ExpireOnType(v)
 	default:
-		return fmt.Errorf("invalid observer type '%s'", v)
+		return fmt.Errorf("invalid expire_on type %q", v)
 	}
 	return nil
 }
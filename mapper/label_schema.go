@@ -0,0 +1,121 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LabelSchemaAction selects what happens to events whose labels do not match
+// the label schema of their mapping.
+type LabelSchemaAction string
+
+const (
+	// LabelSchemaNormalize adds missing labels with an empty value and
+	// removes extra labels.
+	LabelSchemaNormalize LabelSchemaAction = "normalize"
+	// LabelSchemaReject drops the event.
+	LabelSchemaReject  LabelSchemaAction = "reject"
+	LabelSchemaDefault LabelSchemaAction = ""
+)
+
+func (a *LabelSchemaAction) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch LabelSchemaAction(v) {
+	case LabelSchemaNormalize, LabelSchemaReject, LabelSchemaDefault:
+		*a = LabelSchemaAction(v)
+	default:
+		return fmt.Errorf("invalid on_mismatch action %q", v)
+	}
+	return nil
+}
+
+// LabelSchema is the exact set of labels the metrics of a mapping have, so
+// that tags sent by some clients but not others do not give the same metric
+// several label sets.
+type LabelSchema struct {
+	Labels     []string          `yaml:"labels"`
+	OnMismatch LabelSchemaAction `yaml:"on_mismatch"`
+	labels     map[string]struct{}
+}
+
+// init validates the schema against the labels set by the mapping.
+func (s *LabelSchema) init(mapping *MetricMapping) error {
+	if s.OnMismatch == LabelSchemaDefault {
+		s.OnMismatch = LabelSchemaNormalize
+	}
+
+	s.labels = make(map[string]struct{}, len(s.Labels))
+	for _, label := range s.Labels {
+		if !labelNameRE.MatchString(label) {
+			return fmt.Errorf("invalid label key in label_schema: %s", label)
+		}
+		if strings.HasPrefix(label, "__") {
+			return fmt.Errorf("label key %s is reserved", label)
+		}
+		if _, ok := s.labels[label]; ok {
+			return fmt.Errorf("label %s is listed twice in label_schema", label)
+		}
+		s.labels[label] = struct{}{}
+	}
+
+	for label := range mapping.Labels {
+		if _, ok := s.labels[label]; !ok {
+			return fmt.Errorf("label %s is set by the mapping but missing from its label_schema", label)
+		}
+	}
+	for label := range mapping.ConditionalLabels {
+		if _, ok := s.labels[label]; !ok {
+			return fmt.Errorf("label %s is set by the mapping but missing from its label_schema", label)
+		}
+	}
+	return nil
+}
+
+// Conform reports whether the labels match the schema exactly. If they do not
+// and the schema normalizes labels, they are changed to match it.
+func (s *LabelSchema) Conform(labels prometheus.Labels) bool {
+	matched := len(labels) == len(s.labels)
+	if matched {
+		for label := range labels {
+			if _, ok := s.labels[label]; !ok {
+				matched = false
+				break
+			}
+		}
+	}
+	if matched || s.OnMismatch != LabelSchemaNormalize {
+		return matched
+	}
+
+	for label := range labels {
+		if _, ok := s.labels[label]; !ok {
+			delete(labels, label)
+		}
+	}
+	for label := range s.labels {
+		if _, ok := labels[label]; !ok {
+			labels[label] = ""
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLabelSchemaConfig(t *testing.T) {
+	scenarios := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+mappings:
+- match: test.*.*
+  name: test
+  labels:
+    service: $1
+  conditional_labels:
+    outcome:
+    - value: ok
+  label_schema:
+    labels: [service, outcome, region]
+    on_mismatch: reject
+`,
+		},
+		{
+			name: "mapping label missing from schema",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  labels:
+    service: $1
+  label_schema:
+    labels: [region]
+`,
+			err: "label service is set by the mapping but missing from its label_schema",
+		},
+		{
+			name: "invalid label",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  label_schema:
+    labels: [not-a-label]
+`,
+			err: "invalid label key in label_schema: not-a-label",
+		},
+		{
+			name: "duplicate label",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  label_schema:
+    labels: [region, region]
+`,
+			err: "label region is listed twice in label_schema",
+		},
+		{
+			name: "invalid action",
+			config: `
+mappings:
+- match: test.*
+  name: test
+  label_schema:
+    labels: [region]
+    on_mismatch: ignore
+`,
+			err: `invalid on_mismatch action "ignore"`,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			m := &MetricMapper{}
+			err := m.InitFromYAMLString(s.config)
+			if s.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), s.err) {
+				t.Fatalf("expected error containing %q, got %v", s.err, err)
+			}
+		})
+	}
+}
+
+func TestLabelSchemaConform(t *testing.T) {
+	m := &MetricMapper{}
+	err := m.InitFromYAMLString(`
+mappings:
+- match: normalized.*
+  name: normalized
+  label_schema:
+    labels: [service, region]
+- match: rejected.*
+  name: rejected
+  label_schema:
+    labels: [service, region]
+    on_mismatch: reject
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalize, reject := m.Mappings[0].LabelSchema, m.Mappings[1].LabelSchema
+	if normalize.OnMismatch != LabelSchemaNormalize {
+		t.Fatalf("expected normalize to be the default, got %q", normalize.OnMismatch)
+	}
+
+	labels := prometheus.Labels{"service": "api", "region": "eu"}
+	if !normalize.Conform(labels) || !reject.Conform(labels) {
+		t.Errorf("expected matching labels to conform")
+	}
+
+	labels = prometheus.Labels{"service": "api", "host": "web01"}
+	if reject.Conform(labels) {
+		t.Errorf("expected mismatching labels not to conform")
+	}
+	if _, ok := labels["host"]; !ok {
+		t.Errorf("expected a rejecting schema to leave the labels unchanged, got %v", labels)
+	}
+
+	if normalize.Conform(labels) {
+		t.Errorf("expected mismatching labels not to conform")
+	}
+	if expected := (prometheus.Labels{"service": "api", "region": ""}); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected normalized labels %v, got %v", expected, labels)
+	}
+}
@@ -0,0 +1,871 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/statsd_exporter/mapper/fsm"
+)
+
+var (
+	// The first segment of a match cannot start with a number
+	statsdMetricRE = `[a-zA-Z_]([a-zA-Z0-9_\-])*`
+	// The subsequent segments of a match can start with a number
+	// See https://github.com/prometheus/statsd_exporter/issues/328
+	statsdMetricSubsequentRE = `[a-zA-Z0-9_]([a-zA-Z0-9_\-])*`
+	templateReplaceRE        = `(\$\{?\d+\}?)`
+
+	metricLineRE = regexp.MustCompile(`^(\*|` + statsdMetricRE + `)(\.\*|\.` + statsdMetricSubsequentRE + `)*$`)
+	metricNameRE = regexp.MustCompile(`^([a-zA-Z_]|` + templateReplaceRE + `)([a-zA-Z0-9_]|` + templateReplaceRE + `)*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]+$`)
+	// helpCaptureRE matches the references to captures in help texts.
+	helpCaptureRE = regexp.MustCompile(`\$\{?(\d+)\}?`)
+)
+
+// MinSampleObservations is the smallest fraction of observer events that
+// sample_observations can keep. It bounds the weight of a kept event.
+const MinSampleObservations = 0.001
+
+type MetricMapper struct {
+	Registerer prometheus.Registerer
+	Defaults   MapperConfigDefaults `yaml:"defaults"`
+	Mappings   []MetricMapping      `yaml:"mappings"`
+	FSM        *fsm.FSM
+	doFSM      bool
+	doRegex    bool
+	// doRegexFull is whether a mapping matches the name and tags of metrics.
+	doRegexFull bool
+	cache       MetricMapperCache
+	mutex       sync.RWMutex
+
+	MappingsCount prometheus.Gauge
+
+	Logger *slog.Logger
+
+	// ExpandEnv enables ${VAR} references to environment variables in metric
+	// names and label values.
+	ExpandEnv bool
+
+	// Routes dispatch metrics by name prefix to the mappings of separate
+	// configuration files.
+	Routes []MappingRoute `yaml:"routes"`
+	// NewRouteCache, if set, creates the cache of each route's mapper.
+	NewRouteCache func() (MetricMapperCache, error)
+	// RouteLookups counts the lookups per route, by whether a mapping matched.
+	RouteLookups *prometheus.CounterVec
+	// RouteMappings is the number of mappings per route.
+	RouteMappings *prometheus.GaugeVec
+
+	// Rewrites are applied in order to the name and labels of every event
+	// after mapping.
+	Rewrites []RewriteRule `yaml:"rewrites"`
+
+	// Validate, if set, is called with a newly loaded configuration before
+	// it replaces the current one. If it returns an error, loading fails and
+	// the current configuration stays in effect.
+	Validate func(*MetricMapper) error
+
+	// warnings found while loading the configuration.
+	warnings []string
+	// escaper escapes metric names as configured in the defaults.
+	escaper Escaper
+	// absoluteCounters is whether a mapping of the configuration or of its
+	// routes takes counter values as cumulative totals.
+	absoluteCounters bool
+
+	routes       []*route
+	defaultRoute *route
+	// isRoute is set on the mappers of routes, which cannot have routes of
+	// their own.
+	isRoute bool
+}
+
+type SummaryOptions struct {
+	Quantiles  []MetricObjective `yaml:"quantiles"`
+	MaxAge     time.Duration     `yaml:"max_age"`
+	AgeBuckets uint32            `yaml:"age_buckets"`
+	BufCap     uint32            `yaml:"buf_cap"`
+}
+
+type HistogramOptions struct {
+	Buckets                     []float64 `yaml:"buckets"`
+	NativeHistogramBucketFactor float64   `yaml:"native_histogram_bucket_factor"`
+	NativeHistogramMaxBuckets   uint32    `yaml:"native_histogram_max_buckets"`
+	// AutoBuckets, if set, replaces the buckets with buckets learned from
+	// the observations.
+	AutoBuckets *AutoBucketsOptions `yaml:"auto_buckets"`
+}
+
+type MetricObjective struct {
+	Quantile float64 `yaml:"quantile"`
+	Error    float64 `yaml:"error"`
+}
+
+var defaultQuantiles = []MetricObjective{
+	{Quantile: 0.5, Error: 0.05},
+	{Quantile: 0.9, Error: 0.01},
+	{Quantile: 0.99, Error: 0.001},
+}
+
+func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
+	return m.InitFromYAMLStringContext(context.Background(), fileContents)
+}
+
+// InitFromYAMLStringContext is like InitFromYAMLString, but stops loading
+// the configuration with the error of the context when it is done. Compiling
+// a large configuration can take a while. The previous configuration stays
+// in effect if loading is stopped.
+func (m *MetricMapper) InitFromYAMLStringContext(ctx context.Context, fileContents string) error {
+	return m.initFromYAMLString(ctx, fileContents, "")
+}
+
+// initFromYAMLString loads the configuration. The mapping configuration files
+// of routes are relative to dir.
+func (m *MetricMapper) initFromYAMLString(ctx context.Context, fileContents string, dir string) error {
+	var n MetricMapper
+
+	if err := yaml.Unmarshal([]byte(fileContents), &n); err != nil {
+		return err
+	}
+	if m.isRoute && len(n.Routes) > 0 {
+		return fmt.Errorf("the mapping configuration of a route cannot have routes")
+	}
+	if m.isRoute && len(n.Rewrites) > 0 {
+		return fmt.Errorf("the mapping configuration of a route cannot have rewrites")
+	}
+	for i := range n.Rewrites {
+		if err := n.Rewrites[i].init(); err != nil {
+			return err
+		}
+	}
+
+	if len(n.Defaults.HistogramOptions.Buckets) == 0 {
+		n.Defaults.HistogramOptions.Buckets = prometheus.DefBuckets
+	}
+	if n.Defaults.HistogramOptions.NativeHistogramBucketFactor == 0 {
+		n.Defaults.HistogramOptions.NativeHistogramBucketFactor = 1.1
+	}
+	if n.Defaults.HistogramOptions.NativeHistogramMaxBuckets <= 0 {
+		n.Defaults.HistogramOptions.NativeHistogramMaxBuckets = 256
+	}
+
+	if len(n.Defaults.SummaryOptions.Quantiles) == 0 {
+		n.Defaults.SummaryOptions.Quantiles = defaultQuantiles
+	}
+
+	if n.Defaults.MatchType == MatchTypeDefault {
+		n.Defaults.MatchType = MatchTypeGlob
+	}
+
+	if err := n.Defaults.initTypeConflicts(); err != nil {
+		return err
+	}
+
+	if n.Defaults.AggregationWindow < 0 {
+		return fmt.Errorf("aggregation_window must not be negative")
+	}
+	if n.Defaults.AggregationWindow == 0 {
+		n.Defaults.AggregationWindow = DefaultAggregationWindow
+	}
+
+	if n.Defaults.HistogramOptions.AutoBuckets != nil {
+		if err := n.Defaults.HistogramOptions.AutoBuckets.init(); err != nil {
+			return err
+		}
+	}
+
+	// Only characters that are valid in metric names, and not at their
+	// start, can replace invalid ones.
+	switch n.Defaults.EscapeChar {
+	case "", "_", ":":
+	default:
+		return fmt.Errorf("escape_char must be \"_\" or \":\", not %q", n.Defaults.EscapeChar)
+	}
+
+	remainingMappingsCount := len(n.Mappings)
+
+	n.FSM = fsm.NewFSM([]string{string(MetricTypeCounter), string(MetricTypeGauge), string(MetricTypeObserver)},
+		remainingMappingsCount, n.Defaults.GlobDisableOrdering)
+
+	for i := range n.Mappings {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		remainingMappingsCount--
+
+		currentMapping := &n.Mappings[i]
+
+		if m.ExpandEnv {
+			if err := currentMapping.expandEnv(os.LookupEnv); err != nil {
+				return fmt.Errorf("mapping %s: %w", currentMapping.Match, err)
+			}
+		}
+
+		// check that label is correct
+		for k := range currentMapping.Labels {
+			if !labelNameRE.MatchString(k) {
+				return fmt.Errorf("invalid label key: %s", k)
+			}
+		}
+		for k := range currentMapping.ConditionalLabels {
+			if !labelNameRE.MatchString(k) {
+				return fmt.Errorf("invalid label key: %s", k)
+			}
+			if _, ok := currentMapping.Labels[k]; ok {
+				return fmt.Errorf("label %s is set in both labels and conditional_labels", k)
+			}
+		}
+
+		if currentMapping.LabelSchema != nil {
+			if err := currentMapping.LabelSchema.init(currentMapping); err != nil {
+				return fmt.Errorf("mapping %s: %w", currentMapping.Match, err)
+			}
+		}
+
+		if currentMapping.Schedule != nil {
+			if err := currentMapping.Schedule.init(); err != nil {
+				return fmt.Errorf("mapping %s: %w", currentMapping.Match, err)
+			}
+		}
+
+		if currentMapping.Registry != "" && !routeNameRE.MatchString(currentMapping.Registry) {
+			return fmt.Errorf("mapping %s: invalid registry name %q, must match %s", currentMapping.Match, currentMapping.Registry, routeNameRE)
+		}
+
+		if currentMapping.Name == "" {
+			return fmt.Errorf("line %d: metric mapping didn't set a metric name", i)
+		}
+
+		if !metricNameRE.MatchString(currentMapping.Name) {
+			return fmt.Errorf("metric name '%s' doesn't match regex '%s'", currentMapping.Name, metricNameRE)
+		}
+		currentMapping.nameTemplate = currentMapping.Name
+		currentMapping.helpTemplated = helpCaptureRE.MatchString(currentMapping.HelpText)
+
+		if rate := currentMapping.SampleObservations; rate != 0 && (rate < MinSampleObservations || rate > 1) {
+			return fmt.Errorf("sample_observations must be 0 or between %v and 1 in %s", MinSampleObservations, currentMapping.Match)
+		}
+
+		if currentMapping.MatchType == "" {
+			currentMapping.MatchType = n.Defaults.MatchType
+		}
+
+		if currentMapping.Action == "" {
+			currentMapping.Action = ActionTypeMap
+		}
+
+		if currentMapping.CounterMode == CounterModeDefault {
+			currentMapping.CounterMode = CounterModeIncrement
+		}
+
+		if currentMapping.Action == ActionTypeSample {
+			if currentMapping.SampleRatio <= 0 || currentMapping.SampleRatio > 1 {
+				return fmt.Errorf("sample_ratio must be greater than 0 and at most 1 in %s", currentMapping.Match)
+			}
+		} else if currentMapping.SampleRatio != 0 {
+			return fmt.Errorf("sample_ratio can only be used with the sample action in %s", currentMapping.Match)
+		}
+
+		if currentMapping.MatchType == MatchTypeGlob {
+			n.doFSM = true
+			if !metricLineRE.MatchString(currentMapping.Match) {
+				return fmt.Errorf("invalid match: %s", currentMapping.Match)
+			}
+
+			captureCount := n.FSM.AddState(currentMapping.Match, string(currentMapping.MatchMetricType),
+				remainingMappingsCount, currentMapping)
+
+			currentMapping.nameFormatter = fsm.NewTemplateFormatter(currentMapping.Name, captureCount)
+
+			labelKeys := make([]string, len(currentMapping.Labels))
+			labelFormatters := make([]*fsm.TemplateFormatter, len(currentMapping.Labels))
+			labelIndex := 0
+			for label, valueExpr := range currentMapping.Labels {
+				labelKeys[labelIndex] = label
+				labelFormatters[labelIndex] = fsm.NewTemplateFormatter(valueExpr, captureCount)
+				labelIndex++
+			}
+			currentMapping.labelFormatters = labelFormatters
+			currentMapping.labelKeys = labelKeys
+		} else {
+			if regex, err := regexp.Compile(currentMapping.Match); err != nil {
+				return fmt.Errorf("invalid regex %s in mapping: %v", currentMapping.Match, err)
+			} else {
+				currentMapping.regex = regex
+				currentMapping.regexLiterals = requiredLiterals(currentMapping.Match)
+			}
+			n.doRegex = true
+			if currentMapping.MatchType == MatchTypeRegexFull {
+				n.doRegexFull = true
+			}
+		}
+
+		if currentMapping.ObserverType == "" {
+			currentMapping.ObserverType = n.Defaults.ObserverType
+		}
+
+		if currentMapping.LegacyQuantiles != nil &&
+			(currentMapping.SummaryOptions == nil || currentMapping.SummaryOptions.Quantiles != nil) {
+			m.Logger.Warn("using the top level quantiles is deprecated.  Please use quantiles in the summary_options hierarchy")
+		}
+
+		if currentMapping.LegacyBuckets != nil &&
+			(currentMapping.HistogramOptions == nil || currentMapping.HistogramOptions.Buckets != nil) {
+			m.Logger.Warn("using the top level buckets is deprecated.  Please use buckets in the histogram_options hierarchy")
+		}
+
+		if currentMapping.SummaryOptions != nil &&
+			currentMapping.LegacyQuantiles != nil &&
+			currentMapping.SummaryOptions.Quantiles != nil {
+			return fmt.Errorf("cannot use quantiles in both the top level and summary options at the same time in %s", currentMapping.Match)
+		}
+
+		if currentMapping.HistogramOptions != nil &&
+			currentMapping.LegacyBuckets != nil &&
+			currentMapping.HistogramOptions.Buckets != nil {
+			return fmt.Errorf("cannot use buckets in both the top level and histogram options at the same time in %s", currentMapping.Match)
+		}
+
+		if err := setObserverOptions(currentMapping, &n.Defaults); err != nil {
+			return err
+		}
+
+		if n.Defaults.HonorLabels {
+			currentMapping.HonorLabels = true
+		}
+
+		n.warnings = append(n.warnings, internalLabelWarnings(currentMapping)...)
+		n.warnings = append(n.warnings, reservedLabelWarnings(currentMapping)...)
+		n.warnings = append(n.warnings, emptyNameWarnings(currentMapping)...)
+
+		if currentMapping.Ttl == 0 && n.Defaults.Ttl > 0 {
+			currentMapping.Ttl = n.Defaults.Ttl
+		}
+
+		if currentMapping.ExpireOn == ExpireOnDefault {
+			currentMapping.ExpireOn = n.Defaults.ExpireOn
+		}
+
+		if currentMapping.TimerUnit == TimerUnitDefault {
+			currentMapping.TimerUnit = n.Defaults.TimerUnit
+		}
+
+		if currentMapping.SampledCounters == SampledCountersDefault {
+			currentMapping.SampledCounters = n.Defaults.SampledCounters
+		}
+
+		if currentMapping.AggregationWindow < 0 {
+			return fmt.Errorf("aggregation_window must not be negative in %s", currentMapping.Match)
+		}
+		if currentMapping.AggregationWindow == 0 {
+			currentMapping.AggregationWindow = n.Defaults.AggregationWindow
+		}
+
+		if currentMapping.RateWindow < 0 {
+			return fmt.Errorf("rate_window must not be negative in %s", currentMapping.Match)
+		}
+
+		if err := initAdditionalObservers(currentMapping, &n.Defaults); err != nil {
+			return err
+		}
+		for _, observer := range currentMapping.AdditionalObservers {
+			n.warnings = append(n.warnings, reservedLabelWarnings(observer.mapping)...)
+		}
+	}
+
+	routes, err := m.loadRoutes(ctx, n.Routes, dir)
+	if err != nil {
+		return err
+	}
+	for i := range n.Mappings {
+		if n.Mappings[i].CounterMode == CounterModeAbsolute {
+			n.absoluteCounters = true
+		}
+	}
+	for _, r := range routes {
+		if r.mapper.HasAbsoluteCounters() {
+			n.absoluteCounters = true
+		}
+	}
+	if m.Validate != nil {
+		n.routes = routes
+		if err := m.Validate(&n); err != nil {
+			return err
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Logger == nil {
+		m.Logger = promslog.NewNopLogger()
+	}
+
+	for _, w := range n.warnings {
+		m.Logger.Warn(w)
+	}
+
+	m.Defaults = n.Defaults
+	m.escaper = Escaper{DisableDashCollapsing: n.Defaults.EscapeDisableDashCollapsing}
+	if n.Defaults.EscapeChar != "" {
+		m.escaper.Char = n.Defaults.EscapeChar[0]
+	}
+	m.Mappings = n.Mappings
+	m.Routes = n.Routes
+	m.Rewrites = n.Rewrites
+	m.warnings = n.warnings
+	m.absoluteCounters = n.absoluteCounters
+	m.routes = routes
+	m.defaultRoute = m.newRoute(MappingRoute{Name: DefaultRouteName}, nil)
+
+	// Reset the cache since this function can be used to reload config
+	if m.cache != nil {
+		m.cache.Reset()
+	}
+
+	if n.doFSM {
+		var mappings []string
+		for _, mapping := range n.Mappings {
+			if mapping.MatchType == MatchTypeGlob {
+				mappings = append(mappings, mapping.Match)
+			}
+		}
+		n.FSM.BacktrackingNeeded = fsm.TestIfNeedBacktracking(mappings, n.FSM.OrderingDisabled, m.Logger)
+
+		m.FSM = n.FSM
+		m.doRegex = n.doRegex
+	}
+	m.doFSM = n.doFSM
+	m.doRegexFull = n.doRegexFull
+
+	if m.MappingsCount != nil {
+		m.MappingsCount.Set(float64(len(n.Mappings)))
+	}
+	if m.RouteMappings != nil {
+		m.RouteMappings.Reset()
+		m.RouteMappings.WithLabelValues(DefaultRouteName).Set(float64(len(n.Mappings)))
+		for _, r := range routes {
+			m.RouteMappings.WithLabelValues(r.Name).Set(float64(len(r.mapper.Mappings)))
+		}
+	}
+
+	return nil
+}
+
+// setObserverOptions validates the histogram and summary options of the
+// mapping for its observer type, and fills in the defaults.
+func setObserverOptions(mapping *MetricMapping, defaults *MapperConfigDefaults) error {
+	if mapping.ObserverType == ObserverTypeHistogram || mapping.ObserverType == ObserverTypeGaugeHistogram {
+		if mapping.SummaryOptions != nil {
+			return fmt.Errorf("cannot use %s observer and summary options at the same time", mapping.ObserverType)
+		}
+		if mapping.HistogramOptions == nil {
+			mapping.HistogramOptions = &HistogramOptions{}
+		}
+		if len(mapping.LegacyBuckets) != 0 {
+			mapping.HistogramOptions.Buckets = mapping.LegacyBuckets
+		}
+		if len(mapping.HistogramOptions.Buckets) == 0 {
+			// Hand-picked buckets are not replaced by learned ones.
+			if mapping.HistogramOptions.AutoBuckets == nil && defaults.HistogramOptions.AutoBuckets != nil {
+				autoBuckets := *defaults.HistogramOptions.AutoBuckets
+				mapping.HistogramOptions.AutoBuckets = &autoBuckets
+			}
+			mapping.HistogramOptions.Buckets = defaults.HistogramOptions.Buckets
+		}
+	}
+
+	if mapping.HistogramOptions != nil && mapping.HistogramOptions.AutoBuckets != nil {
+		if mapping.ObserverType != ObserverTypeHistogram {
+			return fmt.Errorf("auto_buckets can only be used with histograms in %s", mapping.Match)
+		}
+		if err := mapping.HistogramOptions.AutoBuckets.init(); err != nil {
+			return err
+		}
+	}
+
+	if len(mapping.Aggregates) > 0 {
+		if mapping.ObserverType != ObserverTypeAggregatedGauges {
+			return fmt.Errorf("aggregates can only be used with %s in %s", ObserverTypeAggregatedGauges, mapping.Match)
+		}
+		if err := validateAggregates(mapping.Aggregates); err != nil {
+			return fmt.Errorf("%w in %s", err, mapping.Match)
+		}
+	}
+
+	if (mapping.ObserverType == ObserverTypeAggregatedGauges || mapping.ObserverType == ObserverTypeSumAndCount) &&
+		(mapping.HistogramOptions != nil || mapping.SummaryOptions != nil) {
+		return fmt.Errorf("cannot use %s observer and histogram or summary options at the same time", mapping.ObserverType)
+	}
+
+	if mapping.ObserverType == ObserverTypeSummary {
+		if mapping.HistogramOptions != nil {
+			return fmt.Errorf("cannot use summary observer and histogram options at the same time")
+		}
+		if mapping.SummaryOptions == nil {
+			mapping.SummaryOptions = &SummaryOptions{}
+		}
+		if len(mapping.LegacyQuantiles) != 0 {
+			mapping.SummaryOptions.Quantiles = mapping.LegacyQuantiles
+		}
+		if len(mapping.SummaryOptions.Quantiles) == 0 {
+			mapping.SummaryOptions.Quantiles = defaults.SummaryOptions.Quantiles
+		}
+		if mapping.SummaryOptions.MaxAge == 0 {
+			mapping.SummaryOptions.MaxAge = defaults.SummaryOptions.MaxAge
+		}
+		if mapping.SummaryOptions.AgeBuckets == 0 {
+			mapping.SummaryOptions.AgeBuckets = defaults.SummaryOptions.AgeBuckets
+		}
+		if mapping.SummaryOptions.BufCap == 0 {
+			mapping.SummaryOptions.BufCap = defaults.SummaryOptions.BufCap
+		}
+	}
+	return nil
+}
+
+// Warnings returns the problems found while loading the current
+// configuration that do not prevent it from being used, including those of
+// the configurations of routes.
+func (m *MetricMapper) Warnings() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	warnings := m.warnings
+	for _, r := range m.routes {
+		for _, w := range r.mapper.Warnings() {
+			warnings = append(warnings, fmt.Sprintf("route %s: %s", r.Name, w))
+		}
+	}
+	return warnings
+}
+
+// HasAbsoluteCounters reports whether a mapping of the configuration or of
+// the configurations of its routes has counter_mode absolute. The values of
+// counters of such a mapping are totals, which must not be added up.
+func (m *MetricMapper) HasAbsoluteCounters() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.absoluteCounters
+}
+
+// AllMappings returns the mappings of the configuration, followed by those of
+// the configurations of its routes.
+func (m *MetricMapper) AllMappings() []*MetricMapping {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	mappings := make([]*MetricMapping, 0, len(m.Mappings))
+	for i := range m.Mappings {
+		mappings = append(mappings, &m.Mappings[i])
+	}
+	for _, r := range m.routes {
+		mappings = append(mappings, r.mapper.AllMappings()...)
+	}
+	return mappings
+}
+
+// internalLabelWarnings reports mapping labels whose names start with `__`,
+// which are reserved for internal use. Events for such a mapping cannot be
+// recorded.
+func internalLabelWarnings(mapping *MetricMapping) []string {
+	var names []string
+	for k := range mapping.Labels {
+		names = append(names, k)
+	}
+	for k := range mapping.ConditionalLabels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var warnings []string
+	for _, k := range names {
+		if strings.HasPrefix(k, "__") {
+			warnings = append(warnings, fmt.Sprintf("mapping %s sets label %q which is reserved for internal use", mapping.Match, k))
+		}
+	}
+	return warnings
+}
+
+// reservedLabelWarnings reports mapping labels that collide with the labels
+// histograms and summaries use for their buckets and quantiles. Observations
+// for such a mapping cannot be recorded.
+func reservedLabelWarnings(mapping *MetricMapping) []string {
+	if mapping.MatchMetricType != "" && mapping.MatchMetricType != MetricTypeObserver {
+		return nil
+	}
+	reserved := "quantile"
+	if mapping.ObserverType == ObserverTypeHistogram {
+		reserved = "le"
+	}
+	var warnings []string
+	if _, ok := mapping.Labels[reserved]; ok {
+		warnings = append(warnings, fmt.Sprintf("mapping %s sets label %q which is reserved for %s observations", mapping.Match, reserved, observerTypeName(mapping.ObserverType)))
+	}
+	if _, ok := mapping.ConditionalLabels[reserved]; ok {
+		warnings = append(warnings, fmt.Sprintf("mapping %s sets conditional label %q which is reserved for %s observations", mapping.Match, reserved, observerTypeName(mapping.ObserverType)))
+	}
+	return warnings
+}
+
+// emptyNameWarnings reports a mapping whose name template generates an empty
+// metric name if its captures are empty, as a glob `*` or an optional regex
+// group can match nothing. Events for such a metric cannot be recorded.
+func emptyNameWarnings(mapping *MetricMapping) []string {
+	if mapping.Action == ActionTypeDrop {
+		return nil
+	}
+	var name string
+	switch {
+	case mapping.nameFormatter != nil:
+		name = mapping.nameFormatter.Format(make([]string, strings.Count(mapping.Match, "*")))
+	case mapping.regex != nil:
+		matches := make([]int, 2*(mapping.regex.NumSubexp()+1))
+		for i := 2; i < len(matches); i++ {
+			matches[i] = -1
+		}
+		name = string(mapping.regex.ExpandString(nil, mapping.Name, "", matches))
+	default:
+		return nil
+	}
+	if name != "" {
+		return nil
+	}
+	return []string{fmt.Sprintf("mapping %s generates an empty metric name from name %q if its captures are empty", mapping.Match, mapping.Name)}
+}
+
+func observerTypeName(t ObserverType) string {
+	if t == ObserverTypeHistogram {
+		return "histogram"
+	}
+	return "summary"
+}
+
+func (m *MetricMapper) InitFromFile(fileName string) error {
+	return m.InitFromFileContext(context.Background(), fileName)
+}
+
+// InitFromFileContext is like InitFromFile, but stops loading the
+// configuration with the error of the context when it is done.
+func (m *MetricMapper) InitFromFileContext(ctx context.Context, fileName string) error {
+	mappingStr, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	return m.initFromYAMLString(ctx, string(mappingStr), filepath.Dir(fileName))
+}
+
+// UseCache tells the mapper to use a cache that implements the MetricMapperCache interface.
+// This cache MUST be thread-safe!
+// EscapeMetricName replaces the invalid characters in a metric name as
+// configured in the defaults. The defaults of routes do not apply.
+func (m *MetricMapper) EscapeMetricName(metricName string) string {
+	m.mutex.RLock()
+	e := m.escaper
+	m.mutex.RUnlock()
+	return e.Escape(metricName)
+}
+
+func (m *MetricMapper) UseCache(cache MetricMapperCache) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.cache = cache
+}
+
+func (m *MetricMapper) GetMapping(statsdMetric string, statsdMetricType MetricType) (*MetricMapping, prometheus.Labels, bool) {
+	return m.GetMappingWithTags(statsdMetric, statsdMetricType, nil)
+}
+
+// GetMappingWithTags is like GetMapping, but also matches the tags of the
+// metric against the mappings of the regex_full match type.
+func (m *MetricMapper) GetMappingWithTags(statsdMetric string, statsdMetricType MetricType, tags prometheus.Labels) (*MetricMapping, prometheus.Labels, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r := m.defaultRoute
+	for _, candidate := range m.routes {
+		if strings.HasPrefix(statsdMetric, candidate.Prefix) {
+			r = candidate
+			break
+		}
+	}
+	if r != nil && r.mapper != nil {
+		mapping, labels, present := r.mapper.GetMappingWithTags(statsdMetric, statsdMetricType, tags)
+		r.count(present)
+		return mapping, labels, present
+	}
+
+	mapping, labels, present := m.getMapping(statsdMetric, statsdMetricType, tags)
+	if r != nil {
+		r.count(present)
+	}
+	return mapping, labels, present
+}
+
+// getMapping looks up the mapping among the mappings of this configuration,
+// not those of routes.
+func (m *MetricMapper) getMapping(statsdMetric string, statsdMetricType MetricType, tags prometheus.Labels) (*MetricMapping, prometheus.Labels, bool) {
+	// With mappings that match tags, the result depends on them, so the
+	// cache is keyed by the full line.
+	line := statsdMetric
+	if m.doRegexFull {
+		line = FullLine(statsdMetric, tags)
+	}
+
+	// only use a cache if one is present
+	if m.cache != nil {
+		result, cached := m.cache.Get(formatKey(line, statsdMetricType))
+		if cached {
+			r := result.(MetricMapperCacheResult)
+			return r.Mapping, r.Labels, r.Matched
+		}
+	}
+
+	// glob matching
+	if m.doFSM {
+		finalState, captures := m.FSM.GetMapping(statsdMetric, string(statsdMetricType))
+		if finalState != nil && finalState.Result != nil {
+			v := finalState.Result.(*MetricMapping)
+			result := copyMetricMapping(v)
+			result.Name = result.nameFormatter.Format(captures)
+			if result.helpTemplated {
+				result.HelpText = expandHelp(result.HelpText, captures)
+			}
+
+			labels := prometheus.Labels{}
+			for index, formatter := range result.labelFormatters {
+				labels[result.labelKeys[index]] = formatter.Format(captures)
+			}
+			result.applyConditions(captures, labels)
+
+			r := MetricMapperCacheResult{
+				Mapping: result,
+				Matched: true,
+				Labels:  labels,
+			}
+			// add match to cache
+			if m.cache != nil {
+				m.cache.Add(formatKey(line, statsdMetricType), r)
+			}
+
+			return result, labels, true
+		} else if !m.doRegex {
+			// if there's no regex match type, return immediately
+			// Add miss to cache
+			if m.cache != nil {
+				m.cache.Add(formatKey(line, statsdMetricType), MetricMapperCacheResult{})
+			}
+			return nil, nil, false
+		}
+	}
+
+	// regex matching
+	for i := range m.Mappings {
+		// if a rule don't have regex matching type, the regex field is unset
+		if m.Mappings[i].regex == nil {
+			continue
+		}
+		if mt := m.Mappings[i].MatchMetricType; mt != "" && mt != statsdMetricType {
+			continue
+		}
+		subject := statsdMetric
+		if m.Mappings[i].MatchType == MatchTypeRegexFull {
+			subject = line
+		}
+		if !m.Mappings[i].containsLiterals(subject) {
+			continue
+		}
+		matches := m.Mappings[i].regex.FindStringSubmatchIndex(subject)
+		if len(matches) == 0 {
+			continue
+		}
+
+		mapping := copyMetricMapping(&m.Mappings[i])
+		mapping.Name = string(mapping.regex.ExpandString(
+			[]byte{},
+			mapping.Name,
+			subject,
+			matches,
+		))
+
+		labels := prometheus.Labels{}
+		for label, valueExpr := range mapping.Labels {
+			value := mapping.regex.ExpandString([]byte{}, valueExpr, subject, matches)
+			labels[label] = string(value)
+		}
+		if mapping.DropWhen != nil || len(mapping.ConditionalLabels) > 0 || mapping.helpTemplated {
+			captures := make([]string, len(matches)/2-1)
+			for j := range captures {
+				if start := matches[2*j+2]; start >= 0 {
+					captures[j] = subject[start:matches[2*j+3]]
+				}
+			}
+			mapping.applyConditions(captures, labels)
+			if mapping.helpTemplated {
+				mapping.HelpText = expandHelp(mapping.HelpText, captures)
+			}
+		}
+
+		r := MetricMapperCacheResult{
+			Mapping: mapping,
+			Matched: true,
+			Labels:  labels,
+		}
+		// Add Match to cache
+		if m.cache != nil {
+			m.cache.Add(formatKey(line, statsdMetricType), r)
+		}
+
+		return mapping, labels, true
+	}
+
+	// Add Miss to cache
+	if m.cache != nil {
+		m.cache.Add(formatKey(line, statsdMetricType), MetricMapperCacheResult{})
+	}
+	return nil, nil, false
+}
+
+// expandHelp replaces the references to captures in a help text, such as $1
+// or ${1}, with the captures. References to captures that do not exist are
+// removed.
+func expandHelp(help string, captures []string) string {
+	return helpCaptureRE.ReplaceAllStringFunc(help, func(ref string) string {
+		i, _ := strconv.Atoi(strings.Trim(ref, "${}"))
+		if i < 1 || i > len(captures) {
+			return ""
+		}
+		return captures[i-1]
+	})
+}
+
+// make a shallow copy so that we do not overwrite name
+// as multiple names can be matched by same mapping
+func copyMetricMapping(in *MetricMapping) *MetricMapping {
+	out := *in
+	return &out
+}
@@ -19,9 +19,6 @@ import (
 	"testing"
 
 	"github.com/prometheus/common/promslog"
-
-	"github.com/prometheus/statsd_exporter/pkg/mappercache/lru"
-	"github.com/prometheus/statsd_exporter/pkg/mappercache/randomreplacement"
 )
 
 var (
@@ -38,6 +35,13 @@ var (
     name: "$1"
 `
 
+	ruleTemplateInfixMatchRegex = `
+- match: (.*)\.service%d\.(.*)
+  name: "service_single"
+  labels:
+    name: "$2"
+`
+
 	ruleTemplateMultipleMatchGlob = `
 - match: metric%d.*.*.*.*.*.*.*.*.*.*.*.*
   name: "metric_multi"
@@ -588,7 +592,7 @@ mappings:` + duplicateRules(100, ruleTemplateSingleMatchGlob)
 		"metric100.a",
 	}
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
@@ -639,19 +643,10 @@ mappings:` + duplicateRules(10, ruleTemplateSingleMatchRegex)
 		"metric5.a",
 	}
 
-	for _, cacheType := range []string{"lru", "random"} {
-		mapper := MetricMapper{}
-		var cache MetricMapperCache
-		switch cacheType {
-		case "lru":
-			cache, _ = lru.NewMetricMapperLRUCache(mapper.Registerer, 1000)
-		case "random":
-			cache, _ = randomreplacement.NewMetricMapperRRCache(mapper.Registerer, 1000)
-		}
-		mapper.UseCache(cache)
+	for _, cacheType := range []string{"cached"} {
+		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
-			mapper := MetricMapper{}
 			err := mapper.InitFromYAMLString(config)
 			if err != nil {
 				b.Fatalf("Config load error: %s %s", config, err)
@@ -695,7 +690,7 @@ mappings:` + duplicateRules(100, ruleTemplateSingleMatchGlob)
 		"metric100.a",
 	}
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
@@ -804,6 +799,75 @@ mappings:` + duplicateRules(100, ruleTemplateSingleMatchRegex)
 	}
 }
 
+func BenchmarkRegex100RulesNoMatch(b *testing.B) {
+	config := `---
+defaults:
+  match_type: regex
+mappings:` + duplicateRules(100, ruleTemplateSingleMatchRegex)
+	mappings := []string{
+		"other.a",
+	}
+
+	mapper := MetricMapper{}
+	err := mapper.InitFromYAMLString(config)
+	if err != nil {
+		b.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	b.ResetTimer()
+	for j := 0; j < b.N; j++ {
+		for _, metric := range mappings {
+			mapper.GetMapping(metric, MetricTypeCounter)
+		}
+	}
+}
+
+func BenchmarkRegex100InfixRulesWorst(b *testing.B) {
+	config := `---
+defaults:
+  match_type: regex
+mappings:` + duplicateRules(100, ruleTemplateInfixMatchRegex)
+	mappings := []string{
+		"app.service99.requests",
+	}
+
+	mapper := MetricMapper{}
+	err := mapper.InitFromYAMLString(config)
+	if err != nil {
+		b.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	b.ResetTimer()
+	for j := 0; j < b.N; j++ {
+		for _, metric := range mappings {
+			mapper.GetMapping(metric, MetricTypeCounter)
+		}
+	}
+}
+
+func BenchmarkRegex100InfixRulesNoMatch(b *testing.B) {
+	config := `---
+defaults:
+  match_type: regex
+mappings:` + duplicateRules(100, ruleTemplateInfixMatchRegex)
+	mappings := []string{
+		"app.other.requests",
+	}
+
+	mapper := MetricMapper{}
+	err := mapper.InitFromYAMLString(config)
+	if err != nil {
+		b.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	b.ResetTimer()
+	for j := 0; j < b.N; j++ {
+		for _, metric := range mappings {
+			mapper.GetMapping(metric, MetricTypeCounter)
+		}
+	}
+}
+
 func BenchmarkGlob100RulesMultipleCaptures(b *testing.B) {
 	config := `---
 mappings:` + duplicateRules(100, ruleTemplateMultipleMatchGlob)
@@ -832,7 +896,7 @@ mappings:` + duplicateRules(100, ruleTemplateMultipleMatchGlob)
 		"metric50.a.b.c.d.e.f.g.h.i.j.k.l",
 	}
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
@@ -906,7 +970,7 @@ mappings:` + duplicateRules(100, ruleTemplateMultipleMatchRegex)
 		"metric100.a.b.c.d.e.f.g.h.i.j.k.l",
 	}
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
@@ -939,7 +1003,7 @@ mappings:` + duplicateRules(101, ruleTemplateSingleMatchGlob)
 
 	mappings := duplicateMetrics(100, "metric100")
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
@@ -968,7 +1032,7 @@ mappings:` + duplicateRules(100, ruleTemplateSingleMatchGlob)
 
 	mappings := duplicateMetrics(100, "metric100")
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 1000)
 
 		b.Run(cacheType, func(b *testing.B) {
@@ -1004,7 +1068,7 @@ mappings:` + duplicateRules(100, ruleTemplateSingleMatchGlob)
 		mappings[i], mappings[j] = mappings[j], mappings[i]
 	})
 
-	for _, cacheType := range []string{"lru", "random"} {
+	for _, cacheType := range []string{"cached"} {
 		mapper := newTestMapperWithCache(cacheType, 50)
 		b.Run(cacheType, func(b *testing.B) {
 			err := mapper.InitFromYAMLString(config)
@@ -59,7 +59,10 @@ type MetricMapperCacheResult struct {
 	Labels  prometheus.Labels
 }
 
-// MetricMapperCache MUST be thread-safe and should be instrumented with CacheMetrics
+// MetricMapperCache is the interface through which caches are plugged into a
+// MetricMapper with UseCache. The mapper stores MetricMapperCacheResult values
+// under keys of its choosing. Implementations MUST be thread-safe and should
+// be instrumented with CacheMetrics.
 type MetricMapperCache interface {
 	// Get a cached result
 	Get(metricKey string) (interface{}, bool)
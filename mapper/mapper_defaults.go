@@ -0,0 +1,114 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "time"
+
+type MapperConfigDefaults struct {
+	ObserverType        ObserverType     `yaml:"observer_type"`
+	MatchType           MatchType        `yaml:"match_type"`
+	GlobDisableOrdering bool             `yaml:"glob_disable_ordering"`
+	HonorLabels         bool             `yaml:"honor_labels"`
+	Ttl                 time.Duration    `yaml:"ttl"`
+	ExpireOn            ExpireOnType     `yaml:"expire_on"`
+	SummaryOptions      SummaryOptions   `yaml:"summary_options"`
+	HistogramOptions    HistogramOptions `yaml:"histogram_options"`
+	AggregationWindow   time.Duration    `yaml:"aggregation_window"`
+	TimerUnit           TimerUnit        `yaml:"timer_unit"`
+	SampledCounters     SampledCounters  `yaml:"sampled_counters"`
+	// UnmappedObserverType and UnmappedTtl, if set, are used for metrics
+	// that match no mapping instead of ObserverType and Ttl.
+	UnmappedObserverType ObserverType  `yaml:"unmapped_observer_type"`
+	UnmappedTtl          time.Duration `yaml:"unmapped_ttl"`
+	// EscapeChar and EscapeDisableDashCollapsing configure how invalid
+	// characters in metric names are escaped. See Escaper.
+	EscapeChar                  string `yaml:"escape_char"`
+	EscapeDisableDashCollapsing bool   `yaml:"escape_disable_dash_collapsing"`
+	// TypeConflicts selects what happens to events whose metric is already
+	// registered with another type, and TypePriority ranks the types, highest
+	// first, for the type_priority policy.
+	TypeConflicts TypeConflictPolicy `yaml:"type_conflicts"`
+	TypePriority  []MetricType       `yaml:"type_priority"`
+}
+
+// mapperConfigDefaultsAlias is used to unmarshal the yaml config into mapperConfigDefaults and allows deprecated fields
+type mapperConfigDefaultsAlias struct {
+	ObserverType         ObserverType      `yaml:"observer_type"`
+	TimerType            ObserverType      `yaml:"timer_type,omitempty"` // DEPRECATED - field only present to preserve backwards compatibility in configs
+	Buckets              []float64         `yaml:"buckets"`              // DEPRECATED - field only present to preserve backwards compatibility in configs
+	Quantiles            []MetricObjective `yaml:"quantiles"`            // DEPRECATED - field only present to preserve backwards compatibility in configs
+	MatchType            MatchType         `yaml:"match_type"`
+	GlobDisableOrdering  bool              `yaml:"glob_disable_ordering"`
+	HonorLabels          bool              `yaml:"honor_labels"`
+	Ttl                  time.Duration     `yaml:"ttl"`
+	ExpireOn             ExpireOnType      `yaml:"expire_on"`
+	SummaryOptions       SummaryOptions    `yaml:"summary_options"`
+	HistogramOptions     HistogramOptions  `yaml:"histogram_options"`
+	AggregationWindow    time.Duration     `yaml:"aggregation_window"`
+	TimerUnit            TimerUnit         `yaml:"timer_unit"`
+	SampledCounters      SampledCounters   `yaml:"sampled_counters"`
+	UnmappedObserverType ObserverType      `yaml:"unmapped_observer_type"`
+	UnmappedTtl          time.Duration     `yaml:"unmapped_ttl"`
+
+	EscapeChar                  string `yaml:"escape_char"`
+	EscapeDisableDashCollapsing bool   `yaml:"escape_disable_dash_collapsing"`
+
+	TypeConflicts TypeConflictPolicy `yaml:"type_conflicts"`
+	TypePriority  []MetricType       `yaml:"type_priority"`
+}
+
+// UnmarshalYAML is a custom unmarshal function to allow use of deprecated config keys
+// observer_type will override timer_type
+func (d *MapperConfigDefaults) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp mapperConfigDefaultsAlias
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+
+	// Copy defaults
+	d.ObserverType = tmp.ObserverType
+	d.MatchType = tmp.MatchType
+	d.GlobDisableOrdering = tmp.GlobDisableOrdering
+	d.HonorLabels = tmp.HonorLabels
+	d.Ttl = tmp.Ttl
+	d.ExpireOn = tmp.ExpireOn
+	d.SummaryOptions = tmp.SummaryOptions
+	d.HistogramOptions = tmp.HistogramOptions
+	d.AggregationWindow = tmp.AggregationWindow
+	d.TimerUnit = tmp.TimerUnit
+	d.SampledCounters = tmp.SampledCounters
+	d.UnmappedObserverType = tmp.UnmappedObserverType
+	d.UnmappedTtl = tmp.UnmappedTtl
+	d.EscapeChar = tmp.EscapeChar
+	d.EscapeDisableDashCollapsing = tmp.EscapeDisableDashCollapsing
+	d.TypeConflicts = tmp.TypeConflicts
+	d.TypePriority = tmp.TypePriority
+
+	// Use deprecated TimerType if necessary
+	if tmp.ObserverType == "" {
+		d.ObserverType = tmp.TimerType
+	}
+
+	// Use deprecated quantiles if necessary
+	if len(tmp.SummaryOptions.Quantiles) == 0 && len(tmp.Quantiles) > 0 {
+		d.SummaryOptions = SummaryOptions{Quantiles: tmp.Quantiles}
+	}
+
+	// Use deprecated buckets if necessary
+	if len(tmp.HistogramOptions.Buckets) == 0 && len(tmp.Buckets) > 0 {
+		d.HistogramOptions = HistogramOptions{Buckets: tmp.Buckets}
+	}
+
+	return nil
+}
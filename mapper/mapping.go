@@ -0,0 +1,185 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either xpress or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper/fsm"
+)
+
+type MetricMapping struct {
+	Match            string `yaml:"match"`
+	Name             string `yaml:"name"`
+	nameTemplate     string
+	nameFormatter    *fsm.TemplateFormatter
+	regex            *regexp.Regexp
+	regexLiterals    []string
+	Labels           prometheus.Labels `yaml:"labels"`
+	HonorLabels      bool              `yaml:"honor_labels"`
+	labelKeys        []string
+	labelFormatters  []*fsm.TemplateFormatter
+	ObserverType     ObserverType      `yaml:"observer_type"`
+	TimerType        ObserverType      `yaml:"timer_type,omitempty"` // DEPRECATED - field only present to preserve backwards compatibility in configs. Always empty
+	LegacyBuckets    []float64         `yaml:"buckets"`
+	LegacyQuantiles  []MetricObjective `yaml:"quantiles"`
+	MatchType        MatchType         `yaml:"match_type"`
+	HelpText         string            `yaml:"help"`
+	helpTemplated    bool
+	Action           ActionType        `yaml:"action"`
+	MatchMetricType  MetricType        `yaml:"match_metric_type"`
+	Ttl              time.Duration     `yaml:"ttl"`
+	ExpireOn         ExpireOnType      `yaml:"expire_on"`
+	SummaryOptions   *SummaryOptions   `yaml:"summary_options"`
+	HistogramOptions *HistogramOptions `yaml:"histogram_options"`
+	Scale            MaybeFloat64      `yaml:"scale"`
+	// ConditionalLabels sets each label to the first value whose condition
+	// holds for the captures of the match.
+	ConditionalLabels map[string][]ConditionalLabelValue `yaml:"conditional_labels"`
+	// SampleObservations is the fraction of observer events to keep. 0 keeps
+	// all of them.
+	SampleObservations float64 `yaml:"sample_observations"`
+	// SampleRatio is the fraction of matching events that the sample action
+	// keeps.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	// DropWhen turns the action into drop if the condition holds.
+	DropWhen *Condition `yaml:"drop_when"`
+	// AggregationWindow is the window over which aggregated gauges and gauge
+	// histograms are computed.
+	AggregationWindow time.Duration `yaml:"aggregation_window"`
+	// TimerUnit is the unit in which StatsD timers are observed.
+	TimerUnit TimerUnit `yaml:"timer_unit"`
+	// Aggregates are the gauges exported by aggregated gauges, the
+	// DefaultAggregates if empty.
+	Aggregates []string `yaml:"aggregates"`
+	// AdditionalObservers record the observations in further metrics, besides
+	// the one of ObserverType.
+	AdditionalObservers []AdditionalObserver `yaml:"additional_observers"`
+	// LabelSchema, if set, is the exact set of labels of the mapped metrics.
+	LabelSchema *LabelSchema `yaml:"label_schema"`
+	// CounterMode selects how the values of counters are applied.
+	CounterMode CounterMode `yaml:"counter_mode"`
+	// SampledCounters selects how the increments of sampled counters are
+	// rounded.
+	SampledCounters SampledCounters `yaml:"sampled_counters"`
+	// Forward selects the counters and gauges of the mapping to be sent on
+	// as StatsD lines by an exporter that forwards to another one.
+	Forward bool `yaml:"forward"`
+	// TrackReceived exposes when the metrics of the mapping were first and
+	// last received.
+	TrackReceived bool `yaml:"track_received"`
+	// RateWindow, if set, additionally exports the per-second rate of the
+	// counters of the mapping over this sliding window as a gauge.
+	RateWindow time.Duration `yaml:"rate_window"`
+	// GaugeLiteralNegative takes gauge values with a minus sign for absolute
+	// negative values instead of decrements.
+	GaugeLiteralNegative bool `yaml:"gauge_literal_negative"`
+	// Outputs are the destinations of the events of the mapping. It defaults
+	// to Prometheus alone.
+	Outputs []Output `yaml:"outputs"`
+	// Schedule, if set, restricts the events of the mapping to windows of
+	// time.
+	Schedule *Schedule `yaml:"schedule"`
+	// EnforceType, if set, is the only type of events the metrics of the
+	// mapping accept. Events of other types are suppressed.
+	EnforceType MetricType `yaml:"enforce_type"`
+	// Registry, if set, names the registry the metrics of the mapping are
+	// exposed in, instead of the default one.
+	Registry string `yaml:"registry"`
+}
+
+// NameTemplate returns the metric name as configured, before any captures from
+// the match were substituted.
+func (m *MetricMapping) NameTemplate() string {
+	return m.nameTemplate
+}
+
+// UnmarshalYAML is a custom unmarshal function to allow use of deprecated config keys
+// observer_type will override timer_type
+func (m *MetricMapping) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type MetricMappingAlias MetricMapping
+	var tmp MetricMappingAlias
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+
+	// Copy defaults
+	m.Match = tmp.Match
+	m.Name = tmp.Name
+	m.Labels = tmp.Labels
+	m.HonorLabels = tmp.HonorLabels
+	m.ObserverType = tmp.ObserverType
+	m.LegacyBuckets = tmp.LegacyBuckets
+	m.LegacyQuantiles = tmp.LegacyQuantiles
+	m.MatchType = tmp.MatchType
+	m.HelpText = tmp.HelpText
+	m.Action = tmp.Action
+	m.MatchMetricType = tmp.MatchMetricType
+	m.Ttl = tmp.Ttl
+	m.ExpireOn = tmp.ExpireOn
+	m.SummaryOptions = tmp.SummaryOptions
+	m.HistogramOptions = tmp.HistogramOptions
+	m.Aggregates = tmp.Aggregates
+	m.Scale = tmp.Scale
+	m.ConditionalLabels = tmp.ConditionalLabels
+	m.DropWhen = tmp.DropWhen
+	m.SampleObservations = tmp.SampleObservations
+	m.SampleRatio = tmp.SampleRatio
+	m.AggregationWindow = tmp.AggregationWindow
+	m.TimerUnit = tmp.TimerUnit
+	m.AdditionalObservers = tmp.AdditionalObservers
+	m.LabelSchema = tmp.LabelSchema
+	m.CounterMode = tmp.CounterMode
+	m.SampledCounters = tmp.SampledCounters
+	m.Forward = tmp.Forward
+	m.TrackReceived = tmp.TrackReceived
+	m.RateWindow = tmp.RateWindow
+	m.GaugeLiteralNegative = tmp.GaugeLiteralNegative
+	m.Outputs = tmp.Outputs
+	m.Schedule = tmp.Schedule
+	m.EnforceType = tmp.EnforceType
+	m.Registry = tmp.Registry
+
+	// Use deprecated TimerType if necessary
+	if tmp.ObserverType == "" {
+		m.ObserverType = tmp.TimerType
+	}
+
+	return nil
+}
+
+type MaybeFloat64 struct {
+	Set bool
+	Val float64
+}
+
+func (m *MaybeFloat64) MarshalYAML() (interface{}, error) {
+	if m.Set {
+		return m.Val, nil
+	}
+	return nil, nil
+}
+
+func (m *MaybeFloat64) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp float64
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+	m.Val = tmp
+	m.Set = true
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type MatchType string
+
+const (
+	MatchTypeGlob  MatchType = "glob"
+	MatchTypeRegex MatchType = "regex"
+	// MatchTypeRegexFull matches a regex against the metric name followed by
+	// its tags, as formatted by FullLine.
+	MatchTypeRegexFull MatchType = "regex_full"
+	MatchTypeDefault   MatchType = ""
+)
+
+func (t *MatchType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch MatchType(v) {
+	case MatchTypeRegex:
+		*t = MatchTypeRegex
+	case MatchTypeRegexFull:
+		*t = MatchTypeRegexFull
+	case MatchTypeGlob, MatchTypeDefault:
+		*t = MatchTypeGlob
+	default:
+		return fmt.Errorf("invalid match type %q", v)
+	}
+	return nil
+}
+
+// FullLine returns the string that mappings of the regex_full match type are
+// matched against: the metric name followed by its tags in the order of their
+// names, as in name;tag1=value1;tag2=value2. It is the same for every dialect
+// the tags were sent in.
+func FullLine(name string, tags prometheus.Labels) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(';')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type ObserverType string
+
+const (
+	ObserverTypeHistogram ObserverType = "histogram"
+	ObserverTypeSummary   ObserverType = "summary"
+	// ObserverTypeAggregatedGauges exports the minimum, maximum, average and
+	// number of the observations of each aggregation window as gauges, like
+	// the flushes of the original statsd.
+	ObserverTypeAggregatedGauges ObserverType = "aggregated_gauges"
+	// ObserverTypeGaugeHistogram exports the distribution of the observations
+	// of each aggregation window as an OpenMetrics gauge histogram.
+	ObserverTypeGaugeHistogram ObserverType = "gaugehistogram"
+	// ObserverTypeSumAndCount exports only the sum and the number of the
+	// observations, as a summary without quantiles.
+	ObserverTypeSumAndCount ObserverType = "sum_and_count"
+	ObserverTypeDefault     ObserverType = ""
+)
+
+// DefaultAggregationWindow is the aggregation window of aggregated gauges and
+// gauge histograms if none is configured. It matches the default flush
+// interval of statsd.
+const DefaultAggregationWindow = 10 * time.Second
+
+func (t *ObserverType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch ObserverType(v) {
+	case ObserverTypeHistogram:
+		*t = ObserverTypeHistogram
+	case ObserverTypeAggregatedGauges:
+		*t = ObserverTypeAggregatedGauges
+	case ObserverTypeGaugeHistogram:
+		*t = ObserverTypeGaugeHistogram
+	case ObserverTypeSumAndCount:
+		*t = ObserverTypeSumAndCount
+	case ObserverTypeSummary, ObserverTypeDefault:
+		*t = ObserverTypeSummary
+	default:
+		return fmt.Errorf("invalid observer type '%s'", v)
+	}
+	return nil
+}
+
+// AdditionalObserver records the observations of a mapping in a further
+// metric, named like the metric of the mapping with a suffix. This exports,
+// for example, both a histogram and a summary of the same timer.
+type AdditionalObserver struct {
+	ObserverType     ObserverType      `yaml:"observer_type"`
+	NameSuffix       string            `yaml:"name_suffix"`
+	SummaryOptions   *SummaryOptions   `yaml:"summary_options"`
+	HistogramOptions *HistogramOptions `yaml:"histogram_options"`
+	Aggregates       []string          `yaml:"aggregates"`
+
+	mapping *MetricMapping
+}
+
+// Mapping returns the mapping that the metric of the observer is created
+// with: the mapping the observer belongs to, with the observer type and
+// options of the observer.
+func (o *AdditionalObserver) Mapping() *MetricMapping {
+	return o.mapping
+}
+
+var nameSuffixRE = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// reservedNameSuffixes are the suffixes of the samples of histograms,
+// summaries and gauge histograms. Metrics named with them would collide with
+// these samples.
+var reservedNameSuffixes = map[string]bool{
+	"_bucket": true,
+	"_count":  true,
+	"_sum":    true,
+	"_gcount": true,
+	"_gsum":   true,
+}
+
+// initAdditionalObservers validates the additional observers of a mapping and
+// derives their mappings. The mapping must be complete.
+func initAdditionalObservers(mapping *MetricMapping, defaults *MapperConfigDefaults) error {
+	suffixes := map[string]bool{}
+	for i := range mapping.AdditionalObservers {
+		observer := &mapping.AdditionalObservers[i]
+		if !nameSuffixRE.MatchString(observer.NameSuffix) {
+			return fmt.Errorf("invalid name_suffix '%s' of additional observer in %s", observer.NameSuffix, mapping.Match)
+		}
+		if reservedNameSuffixes[observer.NameSuffix] {
+			return fmt.Errorf("name_suffix %s of additional observer in %s is reserved", observer.NameSuffix, mapping.Match)
+		}
+		if suffixes[observer.NameSuffix] {
+			return fmt.Errorf("name_suffix %s is used by more than one additional observer in %s", observer.NameSuffix, mapping.Match)
+		}
+		suffixes[observer.NameSuffix] = true
+
+		if observer.ObserverType == ObserverTypeDefault {
+			observer.ObserverType = defaults.ObserverType
+		}
+		m := *mapping
+		m.Name += observer.NameSuffix
+		m.nameTemplate += observer.NameSuffix
+		m.ObserverType = observer.ObserverType
+		m.SummaryOptions = observer.SummaryOptions
+		m.HistogramOptions = observer.HistogramOptions
+		m.Aggregates = observer.Aggregates
+		m.LegacyBuckets = nil
+		m.LegacyQuantiles = nil
+		m.AdditionalObservers = nil
+		if err := setObserverOptions(&m, defaults); err != nil {
+			return fmt.Errorf("additional observer %s in %s: %w", observer.NameSuffix, mapping.Match, err)
+		}
+		observer.mapping = &m
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "fmt"
+
+// Output is a destination of the events of a mapping.
+type Output string
+
+const (
+	// OutputPrometheus records the events in the metrics of the exporter.
+	OutputPrometheus Output = "prometheus"
+	// OutputKafka writes the events to Kafka.
+	OutputKafka Output = "kafka"
+)
+
+func (o *Output) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch Output(v) {
+	case OutputPrometheus, OutputKafka:
+		*o = Output(v)
+	default:
+		return fmt.Errorf("invalid output %q", v)
+	}
+	return nil
+}
+
+// HasOutput returns whether the events of the mapping go to the output. The
+// events of mappings without outputs only go to Prometheus.
+func (m *MetricMapping) HasOutput(o Output) bool {
+	if len(m.Outputs) == 0 {
+		return o == OutputPrometheus
+	}
+	for _, output := range m.Outputs {
+		if output == o {
+			return true
+		}
+	}
+	return false
+}
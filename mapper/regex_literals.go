@@ -0,0 +1,61 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// requiredLiterals returns literal strings that every match of the regular
+// expression contains. A metric name that lacks any of them cannot match, so
+// they are checked before running the comparatively expensive regex.
+func requiredLiterals(expr string) []string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return collectRequiredLiterals(re.Simplify(), nil)
+}
+
+func collectRequiredLiterals(re *syntax.Regexp, literals []string) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			literals = append(literals, string(re.Rune))
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			literals = collectRequiredLiterals(sub, literals)
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		literals = collectRequiredLiterals(re.Sub[0], literals)
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			literals = collectRequiredLiterals(re.Sub[0], literals)
+		}
+	}
+	return literals
+}
+
+// containsLiterals reports whether the metric name contains all literals
+// required by the mapping's regex.
+func (m *MetricMapping) containsLiterals(statsdMetric string) bool {
+	for _, literal := range m.regexLiterals {
+		if !strings.Contains(statsdMetric, literal) {
+			return false
+		}
+	}
+	return true
+}
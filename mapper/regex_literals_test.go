@@ -0,0 +1,40 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	scenarios := map[string][]string{
+		`metric5\.([^.]*)`:        {"metric5."},
+		`(.*)\.service1\.(.*)`:    {".service1."},
+		`^app\.(foo|bar)\.(\w+)$`: {"app.", "."},
+		`(?i)app\.requests`:       nil,
+		`app(\.requests)?`:        {"app"},
+		`(app\.)+requests`:        {"app.", "requests"},
+		`(app\.){2,}requests`:     {"app.", "app.", "requests"},
+		`(app\.){0,2}requests`:    {"requests"},
+		`.*`:                      nil,
+		`(`:                       nil,
+	}
+
+	for expr, want := range scenarios {
+		if got := requiredLiterals(expr); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected required literals of `%s` to be %q, got %q", expr, want, got)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type RewriteAction string
+
+const (
+	// RewriteActionRename replaces a metric name that matches the regex with
+	// the replacement.
+	RewriteActionRename RewriteAction = "rename"
+	// RewriteActionRenameLabel moves the value of the source label to the
+	// target label.
+	RewriteActionRenameLabel RewriteAction = "rename_label"
+	// RewriteActionReplaceLabel sets the target label to the replacement if
+	// the value of the source label matches the regex.
+	RewriteActionReplaceLabel RewriteAction = "replace_label"
+	// RewriteActionDropLabel removes the source label.
+	RewriteActionDropLabel RewriteAction = "drop_label"
+	// RewriteActionReplace sets the target label to the replacement if the
+	// values of the source labels, joined by the separator, match the regex,
+	// like the replace action of Prometheus relabeling.
+	RewriteActionReplace RewriteAction = "replace"
+	// RewriteActionLabelDrop removes the labels whose names match the regex.
+	RewriteActionLabelDrop RewriteAction = "labeldrop"
+	// RewriteActionLabelKeep removes the labels whose names do not match the
+	// regex.
+	RewriteActionLabelKeep RewriteAction = "labelkeep"
+)
+
+// defaultRewriteSeparator joins the values of the source labels of a replace
+// rewrite, as in Prometheus relabeling.
+const defaultRewriteSeparator = ";"
+
+// RewriteRule is a simple fix-up of the name or labels of a metric, applied
+// to every event after mapping, whether a mapping matched it or not. Rules
+// are applied in order, so that each sees the result of the previous ones.
+// Regular expressions are anchored at both ends, and the replacement can
+// refer to their groups as $1, ${1} or ${name}.
+type RewriteRule struct {
+	Action      RewriteAction `yaml:"action"`
+	Regex       string        `yaml:"regex"`
+	Replacement string        `yaml:"replacement"`
+	SourceLabel string        `yaml:"source_label"`
+	TargetLabel string        `yaml:"target_label"`
+	// SourceLabels and Separator are the input of the replace action.
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+
+	regex *regexp.Regexp
+}
+
+// init validates the rule and compiles its regex.
+func (r *RewriteRule) init() error {
+	switch r.Action {
+	case RewriteActionRename:
+		if r.Regex == "" {
+			return fmt.Errorf("the %s rewrite needs a regex", r.Action)
+		}
+	case RewriteActionRenameLabel, RewriteActionReplaceLabel:
+		if !labelNameRE.MatchString(r.SourceLabel) {
+			return fmt.Errorf("invalid source_label %q in %s rewrite", r.SourceLabel, r.Action)
+		}
+		if !labelNameRE.MatchString(r.TargetLabel) {
+			return fmt.Errorf("invalid target_label %q in %s rewrite", r.TargetLabel, r.Action)
+		}
+	case RewriteActionDropLabel:
+		if !labelNameRE.MatchString(r.SourceLabel) {
+			return fmt.Errorf("invalid source_label %q in %s rewrite", r.SourceLabel, r.Action)
+		}
+	case RewriteActionReplace:
+		for _, label := range r.SourceLabels {
+			if !labelNameRE.MatchString(label) {
+				return fmt.Errorf("invalid source_labels entry %q in %s rewrite", label, r.Action)
+			}
+		}
+		if !labelNameRE.MatchString(r.TargetLabel) {
+			return fmt.Errorf("invalid target_label %q in %s rewrite", r.TargetLabel, r.Action)
+		}
+		if r.Separator == "" {
+			r.Separator = defaultRewriteSeparator
+		}
+		if r.Regex == "" {
+			r.Regex = "(.*)"
+		}
+		if r.Replacement == "" {
+			r.Replacement = "$1"
+		}
+	case RewriteActionLabelDrop, RewriteActionLabelKeep:
+		if r.Regex == "" {
+			return fmt.Errorf("the %s rewrite needs a regex", r.Action)
+		}
+	default:
+		return fmt.Errorf("invalid rewrite action %q", r.Action)
+	}
+	if r.Regex == "" {
+		r.Regex = ".*"
+	}
+	regex, err := regexp.Compile("^(?:" + r.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %s in %s rewrite: %w", r.Regex, r.Action, err)
+	}
+	r.regex = regex
+	return nil
+}
+
+// Rewrite applies the rule to a metric. It returns the new name of the
+// metric, and changes the labels in place.
+func (r *RewriteRule) Rewrite(name string, labels prometheus.Labels) string {
+	switch r.Action {
+	case RewriteActionRename:
+		if m := r.regex.FindStringSubmatchIndex(name); m != nil {
+			return string(r.regex.ExpandString(nil, r.Replacement, name, m))
+		}
+	case RewriteActionRenameLabel:
+		if value, ok := labels[r.SourceLabel]; ok {
+			delete(labels, r.SourceLabel)
+			labels[r.TargetLabel] = value
+		}
+	case RewriteActionReplaceLabel:
+		value := labels[r.SourceLabel]
+		if m := r.regex.FindStringSubmatchIndex(value); m != nil {
+			if replaced := string(r.regex.ExpandString(nil, r.Replacement, value, m)); replaced != "" {
+				labels[r.TargetLabel] = replaced
+			} else {
+				delete(labels, r.TargetLabel)
+			}
+		}
+	case RewriteActionDropLabel:
+		delete(labels, r.SourceLabel)
+	case RewriteActionReplace:
+		values := make([]string, len(r.SourceLabels))
+		for i, label := range r.SourceLabels {
+			values[i] = labels[label]
+		}
+		value := strings.Join(values, r.Separator)
+		if m := r.regex.FindStringSubmatchIndex(value); m != nil {
+			if replaced := string(r.regex.ExpandString(nil, r.Replacement, value, m)); replaced != "" {
+				labels[r.TargetLabel] = replaced
+			} else {
+				delete(labels, r.TargetLabel)
+			}
+		}
+	case RewriteActionLabelDrop, RewriteActionLabelKeep:
+		for label := range labels {
+			if r.regex.MatchString(label) == (r.Action == RewriteActionLabelDrop) {
+				delete(labels, label)
+			}
+		}
+	}
+	return name
+}
+
+// Rewrite applies the rewrite rules of the configuration to a metric. It
+// returns the new name of the metric, and changes the labels in place.
+func (m *MetricMapper) Rewrite(name string, labels prometheus.Labels) string {
+	m.mutex.RLock()
+	rules := m.Rewrites
+	m.mutex.RUnlock()
+	for i := range rules {
+		name = rules[i].Rewrite(name, labels)
+	}
+	return name
+}
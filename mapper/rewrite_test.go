@@ -0,0 +1,162 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRewrite(t *testing.T) {
+	m := &MetricMapper{}
+	err := m.InitFromYAMLString(`
+rewrites:
+- action: rename
+  regex: myapp_(.*)
+  replacement: $1
+- action: rename
+  regex: (.*)_milliseconds
+  replacement: ${1}_ms
+- action: rename_label
+  source_label: host
+  target_label: instance
+- action: replace_label
+  source_label: instance
+  regex: (.*)\.example\.com
+  target_label: domain
+  replacement: example.com
+- action: drop_label
+  source_label: debug
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name, outName     string
+		labels, outLabels prometheus.Labels
+	}{
+		{
+			name:      "myapp_request_milliseconds",
+			labels:    prometheus.Labels{"host": "web1.example.com", "debug": "1"},
+			outName:   "request_ms",
+			outLabels: prometheus.Labels{"instance": "web1.example.com", "domain": "example.com"},
+		},
+		{
+			name:      "other_myapp_requests",
+			labels:    prometheus.Labels{"host": "localhost"},
+			outName:   "other_myapp_requests",
+			outLabels: prometheus.Labels{"instance": "localhost"},
+		},
+		{
+			name:      "requests",
+			labels:    prometheus.Labels{},
+			outName:   "requests",
+			outLabels: prometheus.Labels{},
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			if name := m.Rewrite(s.name, s.labels); name != s.outName {
+				t.Errorf("expected name %s, got %s", s.outName, name)
+			}
+			if !reflect.DeepEqual(s.labels, s.outLabels) {
+				t.Errorf("expected labels %v, got %v", s.outLabels, s.labels)
+			}
+		})
+	}
+}
+
+func TestRewriteRelabel(t *testing.T) {
+	m := &MetricMapper{}
+	err := m.InitFromYAMLString(`
+rewrites:
+- action: replace
+  source_labels: [service, env]
+  target_label: job
+- action: replace
+  source_labels: [host]
+  regex: ([^.]+)\..*
+  target_label: node
+- action: labeldrop
+  regex: tmp_.*
+- action: labelkeep
+  regex: job|node|host
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name              string
+		labels, outLabels prometheus.Labels
+	}{
+		{
+			name:      "all labels",
+			labels:    prometheus.Labels{"service": "api", "env": "prod", "host": "web1.example.com", "tmp_id": "1", "pid": "42"},
+			outLabels: prometheus.Labels{"job": "api;prod", "node": "web1", "host": "web1.example.com"},
+		},
+		{
+			name:      "missing source labels",
+			labels:    prometheus.Labels{"host": "localhost"},
+			outLabels: prometheus.Labels{"job": ";", "host": "localhost"},
+		},
+		{
+			name:      "no labels",
+			labels:    prometheus.Labels{},
+			outLabels: prometheus.Labels{"job": ";"},
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			if name := m.Rewrite("requests", s.labels); name != "requests" {
+				t.Errorf("expected name requests, got %s", name)
+			}
+			if !reflect.DeepEqual(s.labels, s.outLabels) {
+				t.Errorf("expected labels %v, got %v", s.outLabels, s.labels)
+			}
+		})
+	}
+}
+
+func TestRewriteErrors(t *testing.T) {
+	for config, expected := range map[string]string{
+		"rewrites:\n- action: rename\n  replacement: foo\n":                                   "needs a regex",
+		"rewrites:\n- action: rename\n  regex: (\n":                                           "invalid regex",
+		"rewrites:\n- action: rename_label\n  source_label: host\n":                           "invalid target_label",
+		"rewrites:\n- action: replace_label\n  source_label: a-b\n  target_label: instance\n": "invalid source_label",
+		"rewrites:\n- action: drop_label\n":                                                   "invalid source_label",
+		"rewrites:\n- action: relabel\n":                                                      "invalid rewrite action",
+		"rewrites:\n- action: labeldrop\n":                                                    "needs a regex",
+		"rewrites:\n- action: labelkeep\n  regex: (\n":                                        "invalid regex",
+		"rewrites:\n- action: replace\n  source_labels: [a-b]\n  target_label: instance\n":    "invalid source_labels entry",
+		"rewrites:\n- action: replace\n  source_labels: [host]\n":                             "invalid target_label",
+	} {
+		m := &MetricMapper{}
+		if err := m.InitFromYAMLString(config); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing %q, got %v", config, expected, err)
+		}
+	}
+
+	dir := t.TempDir()
+	writeConfigFile(t, dir, "route.yml", "rewrites:\n- action: drop_label\n  source_label: debug\n")
+	main := writeConfigFile(t, dir, "main.yml", "routes:\n- name: route\n  prefix: route.\n  mapping_config: route.yml\n")
+	m := &MetricMapper{}
+	if err := m.InitFromFile(main); err == nil || !strings.Contains(err.Error(), "cannot have rewrites") {
+		t.Errorf("expected error for rewrites of a route, got %v", err)
+	}
+}
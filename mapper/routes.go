@@ -0,0 +1,144 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+)
+
+// DefaultRouteName is the route name under which lookups of metrics that no
+// route's prefix matches are counted.
+const DefaultRouteName = "default"
+
+var routeNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// MappingRoute dispatches the metrics whose names start with Prefix to the
+// mappings of a separate configuration file, which are the only mappings
+// looked up for them. Each route has its own mapper, with its own defaults,
+// FSM and cache, so that large configurations owned by different teams can be
+// split up and each lookup only searches the mappings of one of them.
+type MappingRoute struct {
+	Name   string `yaml:"name"`
+	Prefix string `yaml:"prefix"`
+	// MappingConfig is the mapping configuration file of the route, relative
+	// to the directory of the configuration file that has the route.
+	MappingConfig string `yaml:"mapping_config"`
+}
+
+type route struct {
+	MappingRoute
+	// fileName is the resolved path of MappingConfig.
+	fileName string
+	// mapper is nil for the default route.
+	mapper             *MetricMapper
+	matched, unmatched prometheus.Counter
+}
+
+func (m *MetricMapper) newRoute(config MappingRoute, mapper *MetricMapper) *route {
+	r := &route{MappingRoute: config, mapper: mapper}
+	if m.RouteLookups != nil {
+		r.matched = m.RouteLookups.WithLabelValues(config.Name, "matched")
+		r.unmatched = m.RouteLookups.WithLabelValues(config.Name, "unmatched")
+	}
+	return r
+}
+
+// count accounts for a lookup through the route.
+func (r *route) count(matched bool) {
+	if matched && r.matched != nil {
+		r.matched.Inc()
+	} else if !matched && r.unmatched != nil {
+		r.unmatched.Inc()
+	}
+}
+
+// loadRoutes loads the mapping configurations of the routes. The routes are
+// ordered by decreasing prefix length, so that the longest matching prefix
+// wins.
+func (m *MetricMapper) loadRoutes(ctx context.Context, configs []MappingRoute, dir string) ([]*route, error) {
+	logger := m.Logger
+	if logger == nil {
+		logger = promslog.NewNopLogger()
+	}
+
+	names := map[string]struct{}{}
+	prefixes := map[string]struct{}{}
+	var routes []*route
+	for _, config := range configs {
+		if !routeNameRE.MatchString(config.Name) {
+			return nil, fmt.Errorf("invalid route name %q, must match %s", config.Name, routeNameRE)
+		}
+		if config.Name == DefaultRouteName {
+			return nil, fmt.Errorf("route name %q is reserved", config.Name)
+		}
+		if _, ok := names[config.Name]; ok {
+			return nil, fmt.Errorf("duplicate route %q", config.Name)
+		}
+		names[config.Name] = struct{}{}
+		if config.Prefix == "" {
+			return nil, fmt.Errorf("route %q needs a prefix", config.Name)
+		}
+		if _, ok := prefixes[config.Prefix]; ok {
+			return nil, fmt.Errorf("prefix %q is used by more than one route", config.Prefix)
+		}
+		prefixes[config.Prefix] = struct{}{}
+		if config.MappingConfig == "" {
+			return nil, fmt.Errorf("route %q needs a mapping_config", config.Name)
+		}
+
+		fileName := config.MappingConfig
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(dir, fileName)
+		}
+		routeMapper := &MetricMapper{Logger: logger.With("route", config.Name), ExpandEnv: m.ExpandEnv, isRoute: true}
+		if m.NewRouteCache != nil {
+			cache, err := m.NewRouteCache()
+			if err != nil {
+				return nil, fmt.Errorf("route %q: %w", config.Name, err)
+			}
+			routeMapper.UseCache(cache)
+		}
+		if err := routeMapper.InitFromFileContext(ctx, fileName); err != nil {
+			return nil, fmt.Errorf("route %q: %w", config.Name, err)
+		}
+		logger.Info("Loaded mapping route", "route", config.Name, "prefix", config.Prefix, "mappings", len(routeMapper.Mappings))
+		r := m.newRoute(config, routeMapper)
+		r.fileName = fileName
+		routes = append(routes, r)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].Prefix) > len(routes[j].Prefix)
+	})
+	return routes, nil
+}
+
+// RouteFiles returns the mapping configuration files of the routes of the
+// loaded configuration, which are loaded again with it.
+func (m *MetricMapper) RouteFiles() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var files []string
+	for _, r := range m.routes {
+		files = append(files, r.fileName)
+	}
+	return files
+}
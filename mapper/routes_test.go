@@ -0,0 +1,238 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	fileName := filepath.Join(dir, name)
+	if err := os.WriteFile(fileName, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestRoutes(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, dir, "team_a.yml", `
+defaults:
+  observer_type: histogram
+mappings:
+- match: team_a.*.requests
+  name: team_a_requests_total
+  labels:
+    service: $1
+`)
+	writeConfigFile(t, dir, "team_a_db.yml", `
+mappings:
+- match: team_a.db.*
+  name: team_a_db_${1}
+`)
+	main := writeConfigFile(t, dir, "main.yml", `
+mappings:
+- match: team_a.*.requests
+  name: shadowed
+- match: other.*
+  name: other_${1}
+routes:
+- name: team_a
+  prefix: team_a.
+  mapping_config: team_a.yml
+- name: team_a_db
+  prefix: team_a.db.
+  mapping_config: team_a_db.yml
+`)
+
+	lookups := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lookups"}, []string{"route", "result"})
+	mappings := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "mappings"}, []string{"route"})
+	m := &MetricMapper{
+		RouteLookups:  lookups,
+		RouteMappings: mappings,
+		NewRouteCache: func() (MetricMapperCache, error) {
+			return newTestCache(10), nil
+		},
+	}
+	if err := m.InitFromFile(main); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+
+	scenarios := []struct {
+		metric  string
+		name    string
+		present bool
+	}{
+		{metric: "team_a.web.requests", name: "team_a_requests_total", present: true},
+		// The longest prefix wins.
+		{metric: "team_a.db.queries", name: "team_a_db_queries", present: true},
+		{metric: "team_a.web.errors"},
+		{metric: "other.thing", name: "other_thing", present: true},
+		{metric: "unmapped.thing"},
+	}
+	for _, s := range scenarios {
+		// Look up twice, the second time from the cache.
+		for i := 0; i < 2; i++ {
+			mapping, _, present := m.GetMapping(s.metric, MetricTypeObserver)
+			if present != s.present {
+				t.Fatalf("%s: expected present %v, got %v", s.metric, s.present, present)
+			}
+			if present && mapping.Name != s.name {
+				t.Fatalf("%s: expected name %s, got %s", s.metric, s.name, mapping.Name)
+			}
+		}
+	}
+
+	mapping, _, _ := m.GetMapping("team_a.web.requests", MetricTypeObserver)
+	if mapping.ObserverType != ObserverTypeHistogram {
+		t.Fatalf("Expected the defaults of the route to apply, got observer type %q", mapping.ObserverType)
+	}
+
+	for _, c := range []struct {
+		route, result string
+		count         float64
+	}{
+		{"team_a", "matched", 3},
+		{"team_a", "unmatched", 2},
+		{"team_a_db", "matched", 2},
+		{DefaultRouteName, "matched", 2},
+		{DefaultRouteName, "unmatched", 2},
+	} {
+		if got := testutil.ToFloat64(lookups.WithLabelValues(c.route, c.result)); got != c.count {
+			t.Fatalf("Expected %v %s lookups for route %s, got %v", c.count, c.result, c.route, got)
+		}
+	}
+	for route, count := range map[string]float64{"team_a": 1, "team_a_db": 1, DefaultRouteName: 2} {
+		if got := testutil.ToFloat64(mappings.WithLabelValues(route)); got != count {
+			t.Fatalf("Expected %v mappings for route %s, got %v", count, route, got)
+		}
+	}
+
+	files := m.RouteFiles()
+	expected := []string{filepath.Join(dir, "team_a_db.yml"), filepath.Join(dir, "team_a.yml")}
+	if len(files) != len(expected) || files[0] != expected[0] || files[1] != expected[1] {
+		t.Fatalf("Expected route files %v, got %v", expected, files)
+	}
+}
+
+func TestRoutesConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, dir, "route.yml", `
+mappings:
+- match: a.*
+  name: a
+`)
+	writeConfigFile(t, dir, "nested.yml", `
+routes:
+- name: nested
+  prefix: b.
+  mapping_config: route.yml
+`)
+
+	scenarios := []struct {
+		name   string
+		config string
+	}{
+		{
+			name: "reserved name",
+			config: `routes:
+- name: default
+  prefix: a.
+  mapping_config: route.yml`,
+		},
+		{
+			name: "missing prefix",
+			config: `routes:
+- name: a
+  mapping_config: route.yml`,
+		},
+		{
+			name: "duplicate prefix",
+			config: `routes:
+- name: a
+  prefix: a.
+  mapping_config: route.yml
+- name: b
+  prefix: a.
+  mapping_config: route.yml`,
+		},
+		{
+			name: "missing file",
+			config: `routes:
+- name: a
+  prefix: a.
+  mapping_config: missing.yml`,
+		},
+		{
+			name: "nested routes",
+			config: `routes:
+- name: a
+  prefix: a.
+  mapping_config: nested.yml`,
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			fileName := writeConfigFile(t, dir, "main.yml", s.config)
+			m := &MetricMapper{}
+			if err := m.InitFromFile(fileName); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
+
+func TestHasAbsoluteCounters(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, dir, "totals.yml", `
+mappings:
+- match: totals.*
+  name: totals_${1}_total
+  counter_mode: absolute
+`)
+	main := writeConfigFile(t, dir, "main.yml", `
+mappings:
+- match: other.*
+  name: other_${1}_total
+routes:
+- name: totals
+  prefix: totals.
+  mapping_config: totals.yml
+`)
+	plain := writeConfigFile(t, dir, "plain.yml", `
+mappings:
+- match: other.*
+  name: other_${1}_total
+`)
+
+	m := &MetricMapper{}
+	if err := m.InitFromFile(main); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+	if !m.HasAbsoluteCounters() {
+		t.Error("expected absolute counters in a route to be reported")
+	}
+	if err := m.InitFromFile(plain); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+	if m.HasAbsoluteCounters() {
+		t.Error("expected no absolute counters after reloading")
+	}
+}
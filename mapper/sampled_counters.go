@@ -0,0 +1,50 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "fmt"
+
+// SampledCounters selects how counters that were sampled, such as foo:1|c|@0.3,
+// are incremented by their value divided by the sample rate.
+type SampledCounters string
+
+const (
+	// SampledCountersExact adds the fractional increment, 3.33... for the
+	// example, as is.
+	SampledCountersExact SampledCounters = "exact"
+	// SampledCountersAccumulate adds the whole part of the increment and
+	// carries its fractional part over to the next sampled increment of the
+	// series, so that the counter only takes whole values.
+	SampledCountersAccumulate SampledCounters = "accumulate"
+	// SampledCountersStochastic rounds the increment up with a probability of
+	// its fractional part, and down otherwise, which is unbiased on average.
+	SampledCountersStochastic SampledCounters = "stochastic"
+	SampledCountersDefault    SampledCounters = ""
+)
+
+func (s *SampledCounters) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch SampledCounters(v) {
+	case SampledCountersExact, SampledCountersAccumulate, SampledCountersStochastic, SampledCountersDefault:
+		*s = SampledCounters(v)
+	default:
+		return fmt.Errorf("invalid sampled_counters %q", v)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// scheduleDays are the names of the days of the week in schedule windows,
+// indexed by time.Weekday.
+var scheduleDays = [7]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Schedule restricts the events of a mapping to windows of time, such as the
+// hours in which a batch job runs. Events outside of all windows are dropped.
+type Schedule struct {
+	Windows []ScheduleWindow `yaml:"windows"`
+	// Timezone is the IANA name of the time zone of the windows. It
+	// defaults to UTC.
+	Timezone string `yaml:"timezone"`
+	// Expire removes the metrics of the mapping when its windows end, instead
+	// of exposing their last values until the next window.
+	Expire bool `yaml:"expire"`
+
+	location *time.Location
+}
+
+// ScheduleWindow is a daily window of time, from Start up to End given as
+// HH:MM. A window whose end is not after its start continues into the next
+// day. Days restricts the window to days of the week on which it starts, all
+// of them by default.
+type ScheduleWindow struct {
+	Days  []string `yaml:"days"`
+	Start string   `yaml:"start"`
+	End   string   `yaml:"end"`
+
+	days       [7]bool
+	start, end time.Duration
+}
+
+// init validates the schedule and parses its windows.
+func (s *Schedule) init() error {
+	if len(s.Windows) == 0 {
+		return errors.New("schedule needs at least one window")
+	}
+	s.location = time.UTC
+	if s.Timezone != "" {
+		location, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid schedule timezone %q: %w", s.Timezone, err)
+		}
+		s.location = location
+	}
+	for i := range s.Windows {
+		if err := s.Windows[i].init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ScheduleWindow) init() error {
+	var err error
+	if w.start, err = parseTimeOfDay(w.Start); err != nil {
+		return fmt.Errorf("invalid schedule window start: %w", err)
+	}
+	if w.end, err = parseTimeOfDay(w.End); err != nil {
+		return fmt.Errorf("invalid schedule window end: %w", err)
+	}
+	if w.start == w.end {
+		return fmt.Errorf("schedule window from %s to %s is empty", w.Start, w.End)
+	}
+	if len(w.Days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+	for _, day := range w.Days {
+		found := false
+		for i, name := range scheduleDays {
+			if day == name {
+				w.days[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid schedule day %q, must be one of %v", day, scheduleDays)
+		}
+	}
+	return nil
+}
+
+// parseTimeOfDay parses a time of day given as HH:MM, from 00:00 to 24:00.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	var hours, minutes int
+	if n, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("%q is not of the form HH:MM", s)
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if hours < 0 || minutes < 0 || minutes > 59 || d > 24*time.Hour {
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	}
+	return d, nil
+}
+
+// Active reports whether a time falls into one of the windows of the
+// schedule.
+func (s *Schedule) Active(t time.Time) bool {
+	t = t.In(s.location)
+	year, month, day := t.Date()
+	sinceMidnight := t.Sub(time.Date(year, month, day, 0, 0, 0, 0, s.location))
+	weekday := t.Weekday()
+	yesterday := (weekday + 6) % 7
+	for _, w := range s.Windows {
+		if w.start < w.end {
+			if w.days[weekday] && sinceMidnight >= w.start && sinceMidnight < w.end {
+				return true
+			}
+			continue
+		}
+		if (w.days[weekday] && sinceMidnight >= w.start) || (w.days[yesterday] && sinceMidnight < w.end) {
+			return true
+		}
+	}
+	return false
+}
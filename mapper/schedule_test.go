@@ -0,0 +1,95 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	m := &MetricMapper{}
+	err := m.InitFromYAMLString(`
+mappings:
+- match: backup.*
+  name: backup_$1
+  schedule:
+    windows:
+    - start: "01:00"
+      end: "03:00"
+    - days: [sat]
+      start: "22:00"
+      end: "06:00"
+- match: berlin.*
+  name: berlin_$1
+  schedule:
+    timezone: Europe/Berlin
+    windows:
+    - start: "12:00"
+      end: "24:00"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup := m.Mappings[0].Schedule
+	berlin := m.Mappings[1].Schedule
+
+	// 2026-10-17 is a Saturday.
+	for _, s := range []struct {
+		schedule *Schedule
+		time     string
+		active   bool
+	}{
+		{backup, "2026-10-15T00:59:59Z", false},
+		{backup, "2026-10-15T01:00:00Z", true},
+		{backup, "2026-10-15T02:59:59Z", true},
+		{backup, "2026-10-15T03:00:00Z", false},
+		{backup, "2026-10-16T23:00:00Z", false},
+		{backup, "2026-10-17T23:00:00Z", true},
+		{backup, "2026-10-18T05:00:00Z", true},
+		{backup, "2026-10-18T06:00:00Z", false},
+		{backup, "2026-10-19T05:00:00Z", false},
+		{berlin, "2026-10-17T10:30:00Z", true},
+		{berlin, "2026-10-17T09:30:00Z", false},
+		{berlin, "2026-10-17T21:59:59Z", true},
+		{berlin, "2026-10-17T22:00:00Z", false},
+	} {
+		at, err := time.Parse(time.RFC3339, s.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active := s.schedule.Active(at); active != s.active {
+			t.Errorf("%s in %s: expected active %t, got %t", s.time, s.schedule.Timezone, s.active, active)
+		}
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	for schedule, expected := range map[string]string{
+		"{}": "at least one window",
+		"{timezone: Mars/Olympus, windows: [{start: '01:00', end: '02:00'}]}": "invalid schedule timezone",
+		"{windows: [{start: '1:00', end: '02:00'}]}":                          "not of the form HH:MM",
+		"{windows: [{start: '01:00', end: '24:01'}]}":                         "not a time of day",
+		"{windows: [{start: '01:60', end: '02:00'}]}":                         "not a time of day",
+		"{windows: [{start: '01:00', end: '01:00'}]}":                         "is empty",
+		"{windows: [{days: [monday], start: '01:00', end: '02:00'}]}":         "invalid schedule day",
+	} {
+		m := &MetricMapper{}
+		config := "mappings:\n- match: a.*\n  name: a\n  schedule: " + schedule + "\n"
+		if err := m.InitFromYAMLString(config); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing %q, got %v", schedule, expected, err)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2013 The Prometheus Authors
+// Copyright 2026 The Prometheus Authors
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
 // You may obtain a copy of the License at
@@ -15,27 +15,29 @@ package mapper
 
 import "fmt"
 
-type MatchType string
+// TimerUnit selects the unit in which StatsD timers are observed.
+type TimerUnit string
 
 const (
-	MatchTypeGlob    MatchType = "glob"
-	MatchTypeRegex   MatchType = "regex"
-	MatchTypeDefault MatchType = ""
+	// TimerUnitSeconds converts timers from milliseconds to seconds.
+	TimerUnitSeconds TimerUnit = "s"
+	// TimerUnitMilliseconds observes timers in milliseconds, as sent.
+	TimerUnitMilliseconds TimerUnit = "ms"
+	TimerUnitDefault      TimerUnit = ""
 )
 
-func (t *MatchType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+func (t *TimerUnit) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var v string
+
 	if err := unmarshal(&v); err != nil {
 		return err
 	}
 
-	switch MatchType(v) {
-	case MatchTypeRegex:
-		*t = MatchTypeRegex
-	case MatchTypeGlob, MatchTypeDefault:
-		*t = MatchTypeGlob
+	switch TimerUnit(v) {
+	case TimerUnitSeconds, TimerUnitMilliseconds, TimerUnitDefault:
+		*t = TimerUnit(v)
 	default:
-		return fmt.Errorf("invalid match type %q", v)
+		return fmt.Errorf("invalid timer_unit %q", v)
 	}
 	return nil
 }
@@ -0,0 +1,88 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TypeConflictPolicy selects what happens to events whose metric is already
+// registered with another type, such as a gauge event for a counter.
+type TypeConflictPolicy string
+
+const (
+	// TypeConflictsReport counts and logs every such event as a conflict.
+	TypeConflictsReport TypeConflictPolicy = "report"
+	// TypeConflictsFirstWins keeps the type the metric was registered with
+	// and suppresses events of other types.
+	TypeConflictsFirstWins TypeConflictPolicy = "first_wins"
+	// TypeConflictsTypePriority replaces the metric when an event of a type
+	// of higher priority arrives, and suppresses events of lower priority.
+	TypeConflictsTypePriority TypeConflictPolicy = "type_priority"
+	TypeConflictsDefault      TypeConflictPolicy = ""
+)
+
+// defaultTypePriority is the priority of types, highest first, if none is
+// configured.
+var defaultTypePriority = []MetricType{MetricTypeCounter, MetricTypeGauge, MetricTypeObserver}
+
+func (p *TypeConflictPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch TypeConflictPolicy(v) {
+	case TypeConflictsReport, TypeConflictsFirstWins, TypeConflictsTypePriority, TypeConflictsDefault:
+		*p = TypeConflictPolicy(v)
+	default:
+		return fmt.Errorf("invalid type_conflicts policy %q", v)
+	}
+	return nil
+}
+
+// initTypeConflicts validates the type conflict settings of the defaults.
+func (d *MapperConfigDefaults) initTypeConflicts() error {
+	if d.TypeConflicts == TypeConflictsDefault {
+		d.TypeConflicts = TypeConflictsReport
+	}
+	if len(d.TypePriority) == 0 {
+		d.TypePriority = defaultTypePriority
+		return nil
+	}
+	if d.TypeConflicts != TypeConflictsTypePriority {
+		return fmt.Errorf("type_priority can only be used with type_conflicts %s", TypeConflictsTypePriority)
+	}
+	for i, t := range d.TypePriority {
+		if slices.Contains(d.TypePriority[:i], t) {
+			return fmt.Errorf("type %s is listed twice in type_priority", t)
+		}
+	}
+	return nil
+}
+
+// TypeOutranks reports whether events of type a take precedence over a metric
+// of type b under the type_priority policy. Types that are not listed in
+// type_priority rank below all listed ones.
+func (d *MapperConfigDefaults) TypeOutranks(a, b MetricType) bool {
+	rank := func(t MetricType) int {
+		if i := slices.Index(d.TypePriority, t); i >= 0 {
+			return i
+		}
+		return len(d.TypePriority)
+	}
+	return rank(a) < rank(b)
+}
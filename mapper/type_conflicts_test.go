@@ -0,0 +1,70 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTypeOutranks(t *testing.T) {
+	m := &MetricMapper{}
+	err := m.InitFromYAMLString(`
+defaults:
+  type_conflicts: type_priority
+  type_priority: [gauge, counter]
+mappings: []
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct {
+		a, b     MetricType
+		outranks bool
+	}{
+		{MetricTypeGauge, MetricTypeCounter, true},
+		{MetricTypeCounter, MetricTypeGauge, false},
+		{MetricTypeCounter, MetricTypeObserver, true},
+		{MetricTypeObserver, MetricTypeGauge, false},
+		{MetricTypeObserver, MetricTypeObserver, false},
+	} {
+		if outranks := m.Defaults.TypeOutranks(s.a, s.b); outranks != s.outranks {
+			t.Errorf("expected %s outranks %s to be %t", s.a, s.b, s.outranks)
+		}
+	}
+
+	// Without a configuration, conflicts are reported.
+	m = &MetricMapper{}
+	if err := m.InitFromYAMLString("mappings: []"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Defaults.TypeConflicts != TypeConflictsReport {
+		t.Errorf("expected type conflicts to be reported, got %s", m.Defaults.TypeConflicts)
+	}
+}
+
+func TestTypeConflictsErrors(t *testing.T) {
+	for config, expected := range map[string]string{
+		"defaults:\n  type_conflicts: last_wins\n":                                      "invalid type_conflicts policy",
+		"defaults:\n  type_priority: [counter, gauge]\n":                                "can only be used with type_conflicts type_priority",
+		"defaults:\n  type_conflicts: type_priority\n  type_priority: [gauge, gauge]\n": "listed twice",
+		"defaults:\n  type_conflicts: type_priority\n  type_priority: [set]\n":          "invalid metric type",
+		"mappings:\n- match: a.*\n  name: a\n  enforce_type: set\n":                     "invalid metric type",
+	} {
+		m := &MetricMapper{}
+		if err := m.InitFromYAMLString(config); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing %q, got %v", config, expected, err)
+		}
+	}
+}
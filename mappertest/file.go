@@ -0,0 +1,95 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappertest
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TestFile is a file of mapping tests, as in:
+//
+//	tests:
+//	- line: "api.login.duration:12|ms"
+//	  metrics:
+//	  - name: api_duration_seconds
+//	    labels:
+//	      endpoint: login
+//	- line: "debug.noise:1|c"
+//	  metrics: []
+type TestFile struct {
+	Tests []TestCase `yaml:"tests"`
+}
+
+// TestCase expects a line to produce exactly the given series. A test case
+// without metrics expects the line to produce none.
+type TestCase struct {
+	Name    string   `yaml:"name"`
+	Line    string   `yaml:"line"`
+	Metrics []Series `yaml:"metrics"`
+}
+
+// UnmarshalYAML reads the name and labels of an expected series.
+func (s *Series) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp struct {
+		Name   string            `yaml:"name"`
+		Labels map[string]string `yaml:"labels"`
+	}
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+	if tmp.Name == "" {
+		return fmt.Errorf("expected metric without name")
+	}
+	s.Name = tmp.Name
+	s.Labels = tmp.Labels
+	return nil
+}
+
+// LoadTestFile reads a file of mapping tests.
+func LoadTestFile(path string) (*TestFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f TestFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid mapping test file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Run runs the test cases, and returns a description of each failed one.
+func (m *Mapper) Run(tests []TestCase) ([]string, error) {
+	var failures []string
+	for i, tc := range tests {
+		name := tc.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d %q", i+1, tc.Line)
+		}
+		series, err := m.Series(tc.Line)
+		if err != nil {
+			return nil, fmt.Errorf("test %s: %w", name, err)
+		}
+		expected := append([]Series(nil), tc.Metrics...)
+		sort.Slice(expected, func(i, j int) bool { return expected[i].String() < expected[j].String() })
+		if formatSeries(series) != formatSeries(expected) {
+			failures = append(failures, fmt.Sprintf("test %s: expected %s, got %s", name, formatSeries(expected), formatSeries(series)))
+		}
+	}
+	return failures, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mappertest tests mapping configurations. It runs StatsD lines
+// through the parser and exporter of the statsd_exporter, so that tests can
+// check which metrics a line produces with a mapping configuration:
+//
+//	func TestMappings(t *testing.T) {
+//		m := mappertest.FromFile(t, "statsd_mapping.yml")
+//		m.AssertMetric(t, "api.login.duration:12|ms", "api_duration_seconds", map[string]string{"endpoint": "login"})
+//		m.AssertNoMetrics(t, "debug.noise:1|c")
+//	}
+package mappertest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+	"github.com/prometheus/statsd_exporter/pkg/listener"
+)
+
+// Series is a time series produced by processing lines.
+type Series struct {
+	Name   string
+	Labels map[string]string
+}
+
+func (s Series) String() string {
+	return s.Name + fmt.Sprint(s.Labels)
+}
+
+// Mapper processes lines with a mapping configuration.
+type Mapper struct {
+	Mapper *mapper.MetricMapper
+	// Parser parses the lines. If it is nil, lines are parsed with all tag
+	// dialects enabled, as by the exporter by default.
+	Parser listener.Parser
+}
+
+// New returns a Mapper for a loaded mapping configuration.
+func New(m *mapper.MetricMapper) *Mapper {
+	return &Mapper{Mapper: m}
+}
+
+// FromYAML loads a mapping configuration, failing the test if it is invalid.
+func FromYAML(t testing.TB, config string) *Mapper {
+	t.Helper()
+	m := &mapper.MetricMapper{}
+	if err := m.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Invalid mapping configuration: %v", err)
+	}
+	return New(m)
+}
+
+// FromFile loads a mapping configuration file, failing the test if it is
+// invalid.
+func FromFile(t testing.TB, path string) *Mapper {
+	t.Helper()
+	m := &mapper.MetricMapper{}
+	if err := m.InitFromFile(path); err != nil {
+		t.Fatalf("Invalid mapping configuration %s: %v", path, err)
+	}
+	return New(m)
+}
+
+// Series returns the time series that the lines produce, ordered by name and
+// labels. Every call starts from an empty registry.
+func (m *Mapper) Series(lines ...string) ([]Series, error) {
+	logger := promslog.NewNopLogger()
+	parser := m.Parser
+	if parser == nil {
+		p := line.NewParser()
+		p.EnableDogstatsdParsing()
+		p.EnableInfluxdbParsing()
+		p.EnableLibratoParsing()
+		p.EnableSignalFXParsing()
+		parser = p
+	}
+
+	var events event.Events
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sample_errors"}, []string{"reason"})
+	samplesReceived := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "samples"}, []string{"type"})
+	tagErrors := prometheus.NewCounter(prometheus.CounterOpts{Name: "tag_errors"})
+	tagsReceived := prometheus.NewCounter(prometheus.CounterOpts{Name: "tags"})
+	for _, l := range lines {
+		events = append(events, parser.LineToEvents(l, *sampleErrors, *samplesReceived, tagErrors, tagsReceived, logger)...)
+	}
+
+	reg := prometheus.NewRegistry()
+	e := newExporter(reg, m.Mapper, logger)
+	c := make(chan event.Events, 1)
+	c <- events
+	close(c)
+	e.Listen(context.Background(), c)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	var series []Series
+	for _, mf := range mfs {
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			series = append(series, Series{Name: mf.GetName(), Labels: labels})
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].String() < series[j].String() })
+	return series, nil
+}
+
+// newExporter returns an exporter that records converted metrics in reg. Its
+// own metrics are not registered anywhere.
+func newExporter(reg prometheus.Registerer, m *mapper.MetricMapper, logger *slog.Logger) *exporter.Exporter {
+	e := exporter.NewExporter(reg, m, logger,
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "events_actions"}, []string{"action"}),
+		prometheus.NewCounter(prometheus.CounterOpts{Name: "events_unmapped"}),
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "events_error"}, []string{"reason"}),
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "events"}, []string{"type"}),
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "events_conflict"}, []string{"type", "metric_name"}),
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "metrics"}, []string{"type"}),
+	)
+	// Stale metrics are only removed on scrape, so that no ticker is started.
+	e.Sweep = exporter.SweepScrape
+	return e
+}
+
+// AssertMetric checks that a line produces a series of the given name with
+// exactly the given labels.
+func (m *Mapper) AssertMetric(t testing.TB, line, name string, labels map[string]string) {
+	t.Helper()
+	series, err := m.Series(line)
+	if err != nil {
+		t.Fatalf("Processing %q failed: %v", line, err)
+	}
+	want := Series{Name: name, Labels: labels}
+	for _, s := range series {
+		if s.String() == want.String() {
+			return
+		}
+	}
+	t.Errorf("Expected %q to produce %v, got %v", line, want, series)
+}
+
+// AssertNoMetrics checks that a line produces no series, for example because
+// it is dropped.
+func (m *Mapper) AssertNoMetrics(t testing.TB, line string) {
+	t.Helper()
+	series, err := m.Series(line)
+	if err != nil {
+		t.Fatalf("Processing %q failed: %v", line, err)
+	}
+	if len(series) > 0 {
+		t.Errorf("Expected %q to produce no metrics, got %v", line, series)
+	}
+}
+
+// formatSeries formats series for failure messages.
+func formatSeries(series []Series) string {
+	if len(series) == 0 {
+		return "no metrics"
+	}
+	s := make([]string, len(series))
+	for i, x := range series {
+		s[i] = x.String()
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappertest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+mappings:
+- match: api.*.duration
+  name: api_duration_seconds
+  labels:
+    endpoint: $1
+- match: debug.*
+  name: dropped
+  action: drop
+`
+
+func TestAssertions(t *testing.T) {
+	m := FromYAML(t, testConfig)
+	m.AssertMetric(t, "api.login.duration:12|ms", "api_duration_seconds", map[string]string{"endpoint": "login"})
+	m.AssertMetric(t, "api.login.duration:12|ms|#region:eu", "api_duration_seconds", map[string]string{"endpoint": "login", "region": "eu"})
+	m.AssertMetric(t, "unmapped.counter:1|c", "unmapped_counter", nil)
+	m.AssertNoMetrics(t, "debug.noise:1|c")
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tests.yml")
+	err := os.WriteFile(path, []byte(`
+tests:
+- line: "api.login.duration:12|ms"
+  metrics:
+  - name: api_duration_seconds
+    labels:
+      endpoint: login
+- name: dropped
+  line: "debug.noise:1|c"
+- name: wrong label
+  line: "api.logout.duration:12|ms"
+  metrics:
+  - name: api_duration_seconds
+    labels:
+      endpoint: login
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := LoadTestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures, err := FromYAML(t, testConfig).Run(tests.Tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || !strings.HasPrefix(failures[0], "test wrong label: ") {
+		t.Fatalf("Expected only the wrong label test to fail, got %v", failures)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+	"github.com/prometheus/statsd_exporter/pkg/listener"
+)
+
+func TestOneShot(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	ex := exporter.NewExporter(reg, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events, 100)
+	eq := event.NewEventQueue(events, 2, time.Hour, eventsFlushed)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ex.Listen(ctx, events)
+
+	sl := &listener.StatsDStdinListener{
+		Reader:          strings.NewReader("foo:1|c\nfoo:2|c\nbar:5|g\nfoo:3|c\n"),
+		EventHandler:    eq,
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   linesReceived,
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: *samplesReceived,
+		TagErrors:       tagErrors,
+		TagsReceived:    tagsReceived,
+	}
+	if err := sl.Listen(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eq.Flush()
+	drainEventLoop(ex, events)
+
+	var buf bytes.Buffer
+	if err := printExposition(&buf, reg); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"\nbar 5\n", "\nfoo 6\n"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in the exposition, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestStdinLineTooLong(t *testing.T) {
+	sl := &listener.StatsDStdinListener{
+		Reader:        strings.NewReader(strings.Repeat("x", 10000) + ":1|c\n"),
+		EventHandler:  &event.UnbufferedEventHandler{C: make(chan event.Events, 1)},
+		Logger:        promslog.NewNopLogger(),
+		LineParser:    line.NewParser(),
+		LinesReceived: linesReceived,
+	}
+	if err := sl.Listen(context.Background()); err == nil || !strings.Contains(err.Error(), "line too long") {
+		t.Fatalf("expected a line too long error, got %v", err)
+	}
+}
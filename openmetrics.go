@@ -0,0 +1,130 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// openMetricsHandler serves metrics in the OpenMetrics format including
+// `_created` samples and gauge histograms, which promhttp cannot produce. Counters, histograms and
+// summaries are created when their series is first seen, or seen again after
+// it expired, so the created timestamp is the start of the series. Requests
+// for other formats are passed on to promhttp.
+type openMetricsHandler struct {
+	gatherer prometheus.Gatherer
+	fallback http.Handler
+	logger   *slog.Logger
+}
+
+// newMetricsHandler serves the metrics of gatherer, or those of a tenant if
+// one is selected with the `tenant` query parameter.
+func newMetricsHandler(reg prometheus.Registerer, gatherer prometheus.Gatherer, tenants map[string]prometheus.Gatherer, enableOpenMetrics bool, logger *slog.Logger) http.Handler {
+	h := gathererHandler(gatherer, enableOpenMetrics, logger)
+	if len(tenants) > 0 {
+		th := &tenantHandler{fallback: h, tenants: make(map[string]http.Handler, len(tenants))}
+		for name, g := range tenants {
+			th.tenants[name] = gathererHandler(g, enableOpenMetrics, logger)
+		}
+		h = th
+	}
+	return promhttp.InstrumentMetricHandler(reg, h)
+}
+
+// gathererHandler serves the metrics of gatherer, or a shard of them if one
+// is selected with the `shard` query parameter.
+func gathererHandler(gatherer prometheus.Gatherer, enableOpenMetrics bool, logger *slog.Logger) http.Handler {
+	return &shardHandler{
+		gatherer:          gatherer,
+		fallback:          formatHandler(gatherer, enableOpenMetrics, logger),
+		enableOpenMetrics: enableOpenMetrics,
+		logger:            logger,
+	}
+}
+
+// formatHandler serves the metrics of gatherer in the format negotiated with
+// the client.
+func formatHandler(gatherer prometheus.Gatherer, enableOpenMetrics bool, logger *slog.Logger) http.Handler {
+	var h http.Handler = promhttp.HandlerFor(gaugeHistogramsAsGauges(gatherer), promhttp.HandlerOpts{})
+	if enableOpenMetrics {
+		h = &openMetricsHandler{gatherer: gatherer, fallback: h, logger: logger}
+	}
+	return h
+}
+
+type tenantHandler struct {
+	fallback http.Handler
+	tenants  map[string]http.Handler
+}
+
+func (h *tenantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get(tenantLabel)
+	if name == "" {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+	th, ok := h.tenants[name]
+	if !ok {
+		http.Error(w, "unknown tenant "+strconv.Quote(name), http.StatusNotFound)
+		return
+	}
+	th.ServeHTTP(w, r)
+}
+
+func (h *openMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	if format.FormatType() != expfmt.TypeOpenMetrics {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	mfs, err := h.gatherer.Gather()
+	if err != nil {
+		h.logger.Error("Error gathering metrics", "error", err)
+		http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Responses are compressed by the metricsEncodingHandler.
+	w.Header().Set("Content-Type", string(format))
+	var out io.Writer = w
+
+	enc := expfmt.NewEncoder(out, format, expfmt.WithCreatedLines())
+	for _, mf := range mfs {
+		if mf.GetType() == dto.MetricType_GAUGE_HISTOGRAM {
+			if err := writeOpenMetricsGaugeHistogram(out, mf); err != nil {
+				h.logger.Error("Error encoding metric family", "error", err)
+				return
+			}
+			continue
+		}
+		if err := enc.Encode(mf); err != nil {
+			h.logger.Error("Error encoding metric family", "error", err)
+			return
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		if err := closer.Close(); err != nil {
+			h.logger.Error("Error closing encoder", "error", err)
+		}
+	}
+}
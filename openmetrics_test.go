@@ -0,0 +1,146 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestOpenMetricsCreatedSamples(t *testing.T) {
+	const openMetricsAccept = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5"
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo_total", Help: "Foo."}, []string{"bar"})
+	reg.MustRegister(counter)
+	counter.WithLabelValues("baz").Inc()
+
+	scenarios := []struct {
+		name              string
+		enableOpenMetrics bool
+		accept            string
+		contentType       string
+		created           bool
+	}{
+		{name: "openmetrics requested", enableOpenMetrics: true, accept: openMetricsAccept, contentType: "application/openmetrics-text", created: true},
+		{name: "text requested", enableOpenMetrics: true, accept: "text/plain", contentType: "text/plain"},
+		{name: "openmetrics disabled", enableOpenMetrics: false, accept: openMetricsAccept, contentType: "text/plain"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			h := newMetricsHandler(prometheus.NewRegistry(), reg, nil, s.enableOpenMetrics, promslog.NewNopLogger())
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", s.accept)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, s.contentType) {
+				t.Fatalf("expected content type %s, got %s", s.contentType, ct)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, `foo_total{bar="baz"} 1`) {
+				t.Fatalf("expected counter sample in body:\n%s", body)
+			}
+			if got := strings.Contains(body, `foo_created{bar="baz"}`); got != s.created {
+				t.Fatalf("expected created sample %v, got body:\n%s", s.created, body)
+			}
+		})
+	}
+}
+
+func TestGaugeHistogramExposition(t *testing.T) {
+	const openMetricsAccept = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5"
+
+	reg := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "queue_depth", Help: "Queue depth.", Buckets: []float64{1, 10}}, []string{"queue"})
+	reg.MustRegister(histogram)
+	for _, v := range []float64{1, 5, 30} {
+		histogram.WithLabelValues("sum_count").Observe(v)
+	}
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "workers", Help: "Workers."})
+	reg.MustRegister(gauge)
+	gauge.Set(2)
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := reg.Gather()
+		for _, mf := range mfs {
+			if mf.GetType() == dto.MetricType_HISTOGRAM {
+				mf.Type = dto.MetricType_GAUGE_HISTOGRAM.Enum()
+			}
+		}
+		return mfs, err
+	})
+
+	scenarios := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{
+			name:   "openmetrics",
+			accept: openMetricsAccept,
+			expected: `# HELP queue_depth Queue depth.
+# TYPE queue_depth gaugehistogram
+queue_depth_bucket{queue="sum_count",le="1.0"} 1
+queue_depth_bucket{queue="sum_count",le="10.0"} 2
+queue_depth_bucket{queue="sum_count",le="+Inf"} 3
+queue_depth_gsum{queue="sum_count"} 36.0
+queue_depth_gcount{queue="sum_count"} 3
+# HELP workers Workers.
+# TYPE workers gauge
+workers 2.0
+# EOF
+`,
+		},
+		{
+			name:   "text",
+			accept: "text/plain",
+			expected: `# HELP queue_depth_bucket Queue depth.
+# TYPE queue_depth_bucket gauge
+queue_depth_bucket{queue="sum_count",le="1"} 1
+queue_depth_bucket{queue="sum_count",le="10"} 2
+queue_depth_bucket{queue="sum_count",le="+Inf"} 3
+# HELP queue_depth_gcount Queue depth.
+# TYPE queue_depth_gcount gauge
+queue_depth_gcount{queue="sum_count"} 3
+# HELP queue_depth_gsum Queue depth.
+# TYPE queue_depth_gsum gauge
+queue_depth_gsum{queue="sum_count"} 36
+# HELP workers Workers.
+# TYPE workers gauge
+workers 2
+`,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			h := newMetricsHandler(prometheus.NewRegistry(), gatherer, nil, true, promslog.NewNopLogger())
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", s.accept)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if body := rec.Body.String(); body != s.expected {
+				t.Fatalf("expected body:\n%s\ngot:\n%s", s.expected, body)
+			}
+		})
+	}
+}
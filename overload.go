@@ -0,0 +1,207 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// overloadProfilesPath is where the captured profiles are served.
+const overloadProfilesPath = "/debug/overload-profiles"
+
+// overloadProfiler captures a CPU profile when an event queue stays filled
+// beyond a threshold, so that the cause of a transient overload can be
+// investigated after the fact. It keeps the last profiles in memory and
+// serves them on overloadProfilesPath.
+type overloadProfiler struct {
+	// threshold is the fraction of the capacity of a queue at which it is
+	// considered overloaded.
+	threshold float64
+	// after is how long a queue has to stay overloaded before a profile is
+	// captured.
+	after time.Duration
+	// duration is how long each profile runs.
+	duration time.Duration
+	// keep is the number of profiles kept.
+	keep     int
+	captured prometheus.Counter
+	logger   *slog.Logger
+
+	queues []*overloadQueue
+
+	mtx       sync.Mutex
+	capturing bool
+	profiles  []*overloadProfile
+	nextID    int
+}
+
+// overloadQueue is an event queue watched for overload.
+type overloadQueue struct {
+	name  string
+	queue chan event.Events
+	// overSince is when the queue was first found over the threshold, or the
+	// zero time if it was not on the last check.
+	overSince time.Time
+}
+
+// overloadProfile is a captured CPU profile.
+type overloadProfile struct {
+	id    int
+	queue string
+	// fill is the fraction of the capacity of the queue in use when the
+	// profile was started.
+	fill     float64
+	start    time.Time
+	duration time.Duration
+	data     []byte
+}
+
+func newOverloadProfiler(threshold float64, after, duration time.Duration, keep int, captured prometheus.Counter, logger *slog.Logger) *overloadProfiler {
+	return &overloadProfiler{
+		threshold: threshold,
+		after:     after,
+		duration:  duration,
+		keep:      keep,
+		captured:  captured,
+		logger:    logger,
+	}
+}
+
+// addQueue adds an event queue to watch. It must be called before run.
+func (p *overloadProfiler) addQueue(name string, queue chan event.Events) {
+	p.queues = append(p.queues, &overloadQueue{name: name, queue: queue})
+}
+
+// run checks the queues at the given interval. It never returns.
+func (p *overloadProfiler) run(interval time.Duration) {
+	ticker := clock.NewTicker(interval)
+	for range ticker.C {
+		p.check()
+	}
+}
+
+// check starts capturing a profile if a queue has been overloaded for long
+// enough and no profile is being captured. A queue has to stay overloaded
+// for as long again before it causes another profile.
+func (p *overloadProfiler) check() {
+	now := clock.Now()
+	for _, q := range p.queues {
+		fill := float64(len(q.queue)) / float64(cap(q.queue))
+		if fill < p.threshold {
+			q.overSince = time.Time{}
+			continue
+		}
+		if q.overSince.IsZero() {
+			q.overSince = now
+		}
+		if now.Sub(q.overSince) < p.after {
+			continue
+		}
+		p.mtx.Lock()
+		capturing := p.capturing
+		p.capturing = true
+		p.mtx.Unlock()
+		if capturing {
+			continue
+		}
+		q.overSince = time.Time{}
+		go p.capture(q.name, fill, now)
+	}
+}
+
+// capture captures a CPU profile starting at the given time and keeps it.
+func (p *overloadProfiler) capture(queue string, fill float64, start time.Time) {
+	defer func() {
+		p.mtx.Lock()
+		p.capturing = false
+		p.mtx.Unlock()
+	}()
+
+	p.logger.Warn("Event queue overloaded, capturing a CPU profile", "queue", queue, "fill", fill, "duration", p.duration)
+	var buf bytes.Buffer
+	// Fails if a profile is already running, such as one requested on
+	// /debug/pprof/profile.
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		p.logger.Warn("Unable to capture a CPU profile of the overload", "queue", queue, "error", err)
+		return
+	}
+	time.Sleep(p.duration)
+	pprof.StopCPUProfile()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.nextID++
+	p.profiles = append(p.profiles, &overloadProfile{
+		id:       p.nextID,
+		queue:    queue,
+		fill:     fill,
+		start:    start,
+		duration: p.duration,
+		data:     buf.Bytes(),
+	})
+	if len(p.profiles) > p.keep {
+		p.profiles = p.profiles[len(p.profiles)-p.keep:]
+	}
+	p.captured.Inc()
+}
+
+// ServeHTTP lists the captured profiles on overloadProfilesPath, and serves
+// each of them in the format of /debug/pprof/profile below it.
+func (p *overloadProfiler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mtx.Lock()
+	profiles := p.profiles
+	p.mtx.Unlock()
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, overloadProfilesPath), "/")
+	if id == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(profiles) == 0 {
+			fmt.Fprintln(w, "No overload profiles captured.")
+			return
+		}
+		for i := len(profiles) - 1; i >= 0; i-- {
+			profile := profiles[i]
+			fmt.Fprintf(w, "%s/%d\tstart=%s\tduration=%s\tqueue=%q\tfill=%.2f\tbytes=%d\n", overloadProfilesPath, profile.id, profile.start.UTC().Format(time.RFC3339), profile.duration, profile.queue, profile.fill, len(profile.data))
+		}
+		return
+	}
+
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, profile := range profiles {
+		if profile.id == n {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"overload-%d.pb.gz\"", profile.id))
+			w.Write(profile.data)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
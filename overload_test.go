@@ -0,0 +1,97 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+func TestOverloadProfiler(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	captured := prometheus.NewCounter(prometheus.CounterOpts{Name: "captured"})
+	p := newOverloadProfiler(0.5, time.Minute, 10*time.Millisecond, 2, captured, promslog.NewNopLogger())
+	queue := make(chan event.Events, 4)
+	p.addQueue("default", queue)
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+	// overload fills the queue beyond the threshold for long enough to
+	// capture a profile, and waits for it.
+	overload := func(expected float64) {
+		t.Helper()
+		queue <- event.Events{}
+		queue <- event.Events{}
+		p.check()
+		clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(time.Minute)
+		p.check()
+		for i := 0; testutil.ToFloat64(captured) < expected && i < 500; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if v := testutil.ToFloat64(captured); v != expected {
+			t.Fatalf("expected %v profiles, got %v", expected, v)
+		}
+		<-queue
+		<-queue
+	}
+
+	if _, body := get(overloadProfilesPath); !strings.Contains(body, "No overload profiles") {
+		t.Fatalf("expected no profiles, got %q", body)
+	}
+
+	// A queue below the threshold does not cause a profile.
+	queue <- event.Events{}
+	p.check()
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(time.Hour)
+	p.check()
+	<-queue
+	if v := testutil.ToFloat64(captured); v != 0 {
+		t.Fatalf("expected no profile, got %v", v)
+	}
+
+	overload(1)
+	_, body := get(overloadProfilesPath)
+	if !strings.Contains(body, overloadProfilesPath+"/1\t") || !strings.Contains(body, `queue="default"`) || !strings.Contains(body, "fill=0.50") {
+		t.Fatalf("expected the profile to be listed, got %q", body)
+	}
+	if code, body := get(overloadProfilesPath + "/1"); code != http.StatusOK || len(body) == 0 {
+		t.Fatalf("expected the profile, got %d with %d bytes", code, len(body))
+	}
+
+	// Only the last profiles are kept.
+	overload(2)
+	overload(3)
+	if code, _ := get(overloadProfilesPath + "/1"); code != http.StatusNotFound {
+		t.Fatalf("expected the first profile to be gone, got %d", code)
+	}
+	_, body = get(overloadProfilesPath)
+	if strings.Count(body, overloadProfilesPath) != 2 || !strings.HasPrefix(body, overloadProfilesPath+"/3\t") {
+		t.Fatalf("expected the last 2 profiles, newest first, got %q", body)
+	}
+}
@@ -31,6 +31,15 @@ func Now() time.Time {
 	return ClockInstance.Instant
 }
 
+// ResetTicker changes the period of a ticker made by NewTicker. Mocked
+// tickers are left as they are.
+func ResetTicker(t *time.Ticker, d time.Duration) {
+	if ClockInstance != nil && ClockInstance.TickerCh != nil && t.C == (<-chan time.Time)(ClockInstance.TickerCh) {
+		return
+	}
+	t.Reset(d)
+}
+
 func NewTicker(d time.Duration) *time.Ticker {
 	if ClockInstance == nil || ClockInstance.TickerCh == nil {
 		return time.NewTicker(d)
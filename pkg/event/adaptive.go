@@ -0,0 +1,87 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// AdaptiveFlush bounds the flush threshold and interval of an event queue
+// that tunes them to the load.
+type AdaptiveFlush struct {
+	MinThreshold, MaxThreshold int
+	MinInterval, MaxInterval   time.Duration
+	// Threshold and Interval are set to the threshold and to the interval in
+	// seconds that are in use.
+	Threshold prometheus.Gauge
+	Interval  prometheus.Gauge
+}
+
+// The occupancy of the channel of flushed batches from which the exporter is
+// considered to fall behind, and the fraction of the flush interval that a
+// flush may wait for the exporter before it is.
+const (
+	adaptivePressureOccupancy = 0.5
+	adaptivePressureLatency   = 0.25
+)
+
+// Adapt makes the queue tune its flush threshold and interval after each
+// flush, within the bounds of a. While the exporter falls behind, that is
+// flushed batches fill up the channel or take long to be picked up, both are
+// halved, so that events are flushed earlier and in smaller batches. While
+// the channel is empty and flushes do not wait, both grow by a quarter, so
+// that events are batched bigger.
+func (eq *EventQueue) Adapt(a AdaptiveFlush) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.adaptive = &a
+	eq.setFlushParameters(eq.flushThreshold, eq.flushInterval)
+}
+
+// adapt tunes the flush parameters to the occupancy of the channel before a
+// flush and the time the flush waited for the exporter.
+func (eq *EventQueue) adapt(occupancy float64, latency time.Duration) {
+	switch {
+	case occupancy >= adaptivePressureOccupancy || latency >= time.Duration(adaptivePressureLatency*float64(eq.flushInterval)):
+		eq.setFlushParameters(eq.flushThreshold/2, eq.flushInterval/2)
+	case occupancy == 0:
+		eq.setFlushParameters(eq.flushThreshold+eq.flushThreshold/4, eq.flushInterval+eq.flushInterval/4)
+	}
+}
+
+// setFlushParameters sets the flush threshold and interval within the bounds
+// of the adaptive flushing.
+func (eq *EventQueue) setFlushParameters(threshold int, interval time.Duration) {
+	a := eq.adaptive
+	threshold = min(max(threshold, a.MinThreshold, 1), a.MaxThreshold)
+	interval = min(max(interval, a.MinInterval, time.Millisecond), a.MaxInterval)
+	if interval != eq.flushInterval {
+		clock.ResetTicker(eq.flushTicker, interval)
+	}
+	eq.flushThreshold = threshold
+	eq.flushInterval = interval
+	a.Threshold.Set(float64(threshold))
+	a.Interval.Set(interval.Seconds())
+}
+
+// FlushParameters returns the flush threshold and interval in use.
+func (eq *EventQueue) FlushParameters() (int, time.Duration) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	return eq.flushThreshold, eq.flushInterval
+}
@@ -14,12 +14,15 @@
 package event
 
 import (
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
 )
 
 type Event interface {
@@ -32,7 +35,10 @@ type Event interface {
 type CounterEvent struct {
 	CMetricName string
 	CValue      float64
-	CLabels     map[string]string
+	// CSampled marks a counter whose value was divided by its sample rate,
+	// such as 3.33... for foo:1|c|@0.3.
+	CSampled bool
+	CLabels  map[string]string
 }
 
 func (c *CounterEvent) MetricName() string            { return c.CMetricName }
@@ -55,12 +61,31 @@ func (g *GaugeEvent) MetricType() mapper.MetricType { return mapper.MetricTypeGa
 type ObserverEvent struct {
 	OMetricName string
 	OValue      float64
-	OLabels     map[string]string
+	// OTimer marks a StatsD timer, whose OValue is in milliseconds. Value
+	// converts it to seconds.
+	OTimer bool
+	// OCount is the number of identical observations the event stands for,
+	// such as 10 for a timer sampled with @0.1. 0 means 1.
+	OCount  int
+	OLabels map[string]string
 }
 
-func (o *ObserverEvent) MetricName() string            { return o.OMetricName }
-func (o *ObserverEvent) Value() float64                { return o.OValue }
-func (o *ObserverEvent) Labels() map[string]string     { return o.OLabels }
+func (o *ObserverEvent) MetricName() string { return o.OMetricName }
+func (o *ObserverEvent) Value() float64 {
+	if o.OTimer {
+		return o.OValue / 1000 // prometheus presumes seconds, statsd millisecond
+	}
+	return o.OValue
+}
+func (o *ObserverEvent) Labels() map[string]string { return o.OLabels }
+
+// Count returns the number of observations the event stands for.
+func (o *ObserverEvent) Count() int {
+	if o.OCount < 1 {
+		return 1
+	}
+	return o.OCount
+}
 func (o *ObserverEvent) MetricType() mapper.MetricType { return mapper.MetricTypeObserver }
 
 type Events []Event
@@ -73,12 +98,29 @@ type EventQueue struct {
 	flushThreshold int
 	flushInterval  time.Duration
 	eventsFlushed  prometheus.Counter
+
+	// mergeable, if set, selects the events that are merged with a pending
+	// event of the same series; see Aggregate.
+	mergeable  func(Event) bool
+	aggregated prometheus.Counter
+	// series is the index in q of the pending event of each series.
+	series map[string]int
+
+	// adaptive, if set, bounds the flush threshold and interval that are
+	// tuned to the load; see Adapt.
+	adaptive *AdaptiveFlush
 }
 
 type EventHandler interface {
 	Queue(event Events)
 }
 
+// BacklogReporter is implemented by event handlers that can report how many
+// flushed batches of events are waiting to be processed by the exporter.
+type BacklogReporter interface {
+	Backlog() int
+}
+
 func NewEventQueue(c chan Events, flushThreshold int, flushInterval time.Duration, eventsFlushed prometheus.Counter) *EventQueue {
 	ticker := clock.NewTicker(flushInterval)
 	eq := &EventQueue{
@@ -98,11 +140,33 @@ func NewEventQueue(c chan Events, flushThreshold int, flushInterval time.Duratio
 	return eq
 }
 
+// Aggregate makes the queue merge the counter and gauge events of the same
+// series, that is with the same metric name and labels, until the next flush.
+// The values of counter events are added up. An absolute gauge event replaces
+// the pending one, and the change of a relative gauge event is added to it.
+// Only events for which mergeable returns true are merged, and each merged
+// event is counted in aggregated. Negative counter values, negative relative
+// gauge values, and NaN and infinite values are never merged, so that the
+// exporter handles them as usual: with gauge_literal_negative, a gauge value
+// with a minus sign may set the gauge rather than decrement it, which only
+// the mapping tells. Sampled and unsampled counter events are not merged
+// with each other, so that sampled increments are still rounded.
+func (eq *EventQueue) Aggregate(mergeable func(Event) bool, aggregated prometheus.Counter) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.mergeable = mergeable
+	eq.aggregated = aggregated
+	eq.series = map[string]int{}
+}
+
 func (eq *EventQueue) Queue(events Events) {
 	eq.m.Lock()
 	defer eq.m.Unlock()
 
 	for _, e := range events {
+		if eq.merge(e) {
+			continue
+		}
 		eq.q = append(eq.q, e)
 		if len(eq.q) >= eq.flushThreshold {
 			eq.FlushUnlocked()
@@ -117,11 +181,107 @@ func (eq *EventQueue) Flush() {
 }
 
 func (eq *EventQueue) FlushUnlocked() {
-	eq.C <- eq.q
+	if eq.adaptive != nil {
+		var occupancy float64
+		if cap(eq.C) > 0 {
+			occupancy = float64(len(eq.C)) / float64(cap(eq.C))
+		}
+		start := time.Now()
+		eq.C <- eq.q
+		eq.adapt(occupancy, time.Since(start))
+	} else {
+		eq.C <- eq.q
+	}
 	eq.q = make([]Event, 0, cap(eq.q))
+	clear(eq.series)
 	eq.eventsFlushed.Inc()
 }
 
+// merge merges an event into the pending event of its series. It returns
+// false if the event has to be queued, in which case it becomes the pending
+// event of its series if it can be merged with later ones.
+func (eq *EventQueue) merge(e Event) bool {
+	if eq.mergeable == nil {
+		return false
+	}
+	switch e.(type) {
+	case *CounterEvent, *GaugeEvent:
+	default:
+		return false
+	}
+	key := seriesKey(e)
+	v := e.Value()
+	if math.IsNaN(v) || math.IsInf(v, 0) || (v < 0 && !isAbsoluteGauge(e)) || !eq.mergeable(e) {
+		// Later events of the series must not be merged into the pending
+		// one, as they would be applied before this one.
+		delete(eq.series, key)
+		return false
+	}
+
+	i, ok := eq.series[key]
+	if !ok {
+		eq.series[key] = len(eq.q)
+		return false
+	}
+	// The pending event is copied rather than changed, as the caller may
+	// still hold on to it.
+	switch ev := e.(type) {
+	case *CounterEvent:
+		merged := *eq.q[i].(*CounterEvent)
+		merged.CValue += ev.CValue
+		eq.q[i] = &merged
+	case *GaugeEvent:
+		if !ev.GRelative {
+			eq.q[i] = ev
+			break
+		}
+		merged := *eq.q[i].(*GaugeEvent)
+		merged.GValue += ev.GValue
+		eq.q[i] = &merged
+	}
+	eq.aggregated.Inc()
+	return true
+}
+
+// isAbsoluteGauge returns whether an event sets a gauge.
+func isAbsoluteGauge(e Event) bool {
+	ev, ok := e.(*GaugeEvent)
+	return ok && !ev.GRelative
+}
+
+// seriesKey identifies the series of an event by its type, metric name and
+// labels, and for counters whether they were sampled. The separators cannot
+// occur in valid UTF-8.
+func seriesKey(e Event) string {
+	labels := e.Labels()
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(string(e.MetricType()))
+	if ev, ok := e.(*CounterEvent); ok && ev.CSampled {
+		sb.WriteString("/sampled")
+	}
+	sb.WriteByte(0xff)
+	sb.WriteString(e.MetricName())
+	for _, name := range names {
+		sb.WriteByte(0xff)
+		sb.WriteString(name)
+		sb.WriteByte(0xfe)
+		sb.WriteString(labels[name])
+	}
+	return sb.String()
+}
+
+// Backlog returns the number of flushed batches that the exporter has not
+// picked up yet.
+func (eq *EventQueue) Backlog() int {
+	return len(eq.C)
+}
+
 func (eq *EventQueue) Len() int {
 	eq.m.Lock()
 	defer eq.m.Unlock()
@@ -14,10 +14,14 @@
 package event
 
 import (
+	"math"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 )
 
@@ -85,3 +89,234 @@ func TestEventIntervalFlush(t *testing.T) {
 		t.Fatal("Expected 10 events in the event channel, but got", len(events))
 	}
 }
+
+func TestEventQueueBacklog(t *testing.T) {
+	c := make(chan Events, 100)
+	eq := NewEventQueue(c, 5, time.Second, eventsFlushed)
+	eq.Queue(make(Events, 12))
+
+	if eq.Backlog() != 2 {
+		t.Fatalf("Expected 2 batches waiting, but got %v", eq.Backlog())
+	}
+	<-c
+	if eq.Backlog() != 1 {
+		t.Fatalf("Expected 1 batch waiting, but got %v", eq.Backlog())
+	}
+}
+
+func TestEventQueueAdaptiveFlush(t *testing.T) {
+	clock.ClockInstance = nil
+
+	c := make(chan Events, 4)
+	eq := NewEventQueue(c, 4, time.Second, eventsFlushed)
+	threshold := prometheus.NewGauge(prometheus.GaugeOpts{Name: "threshold"})
+	interval := prometheus.NewGauge(prometheus.GaugeOpts{Name: "interval"})
+	eq.Adapt(AdaptiveFlush{
+		MinThreshold: 2,
+		MaxThreshold: 16,
+		MinInterval:  100 * time.Millisecond,
+		MaxInterval:  2 * time.Second,
+		Threshold:    threshold,
+		Interval:     interval,
+	})
+
+	scenarios := []struct {
+		name      string
+		events    int
+		threshold int
+		interval  time.Duration
+	}{
+		{
+			// The channel was empty, so the batches grow.
+			name:      "idle",
+			events:    4,
+			threshold: 5,
+			interval:  1250 * time.Millisecond,
+		},
+		{
+			// A quarter of the channel is in use.
+			name:      "busy",
+			events:    5,
+			threshold: 5,
+			interval:  1250 * time.Millisecond,
+		},
+		{
+			// Half of the channel is in use, so the batches shrink.
+			name:      "under pressure",
+			events:    5,
+			threshold: 2,
+			interval:  625 * time.Millisecond,
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			eq.Queue(make(Events, s.events))
+			gotThreshold, gotInterval := eq.FlushParameters()
+			if gotThreshold != s.threshold || gotInterval != s.interval {
+				t.Fatalf("Expected a threshold of %d and an interval of %v, got %d and %v", s.threshold, s.interval, gotThreshold, gotInterval)
+			}
+			if v := testutil.ToFloat64(threshold); v != float64(s.threshold) {
+				t.Errorf("Expected the threshold gauge to be %d, got %v", s.threshold, v)
+			}
+			if v := testutil.ToFloat64(interval); v != s.interval.Seconds() {
+				t.Errorf("Expected the interval gauge to be %v, got %v", s.interval.Seconds(), v)
+			}
+		})
+	}
+}
+
+func TestEventAggregation(t *testing.T) {
+	labels := func(service string) map[string]string { return map[string]string{"service": service} }
+	scenarios := []struct {
+		name       string
+		mergeable  func(Event) bool
+		in         Events
+		out        Events
+		aggregated float64
+	}{
+		{
+			name: "counters",
+			in: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1, CLabels: labels("a")},
+				&CounterEvent{CMetricName: "foo", CValue: 2, CLabels: labels("b")},
+				&CounterEvent{CMetricName: "foo", CValue: 3, CLabels: labels("a")},
+			},
+			out: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 4, CLabels: labels("a")},
+				&CounterEvent{CMetricName: "foo", CValue: 2, CLabels: labels("b")},
+			},
+			aggregated: 1,
+		},
+		{
+			name: "gauges",
+			in: Events{
+				&GaugeEvent{GMetricName: "foo", GValue: 5, GRelative: true},
+				&GaugeEvent{GMetricName: "foo", GValue: 1, GRelative: true},
+				&GaugeEvent{GMetricName: "bar", GValue: 10},
+				&GaugeEvent{GMetricName: "bar", GValue: 20},
+				&GaugeEvent{GMetricName: "bar", GValue: 3, GRelative: true},
+			},
+			out: Events{
+				&GaugeEvent{GMetricName: "foo", GValue: 6, GRelative: true},
+				&GaugeEvent{GMetricName: "bar", GValue: 23},
+			},
+			aggregated: 3,
+		},
+		{
+			// With gauge_literal_negative, -5 sets the gauge, which only
+			// the exporter can tell from the mapping.
+			name: "negative relative gauges",
+			in: Events{
+				&GaugeEvent{GMetricName: "foo", GValue: 10},
+				&GaugeEvent{GMetricName: "foo", GValue: -5, GRelative: true},
+				&GaugeEvent{GMetricName: "foo", GValue: 2, GRelative: true},
+				&GaugeEvent{GMetricName: "foo", GValue: 1, GRelative: true},
+			},
+			out: Events{
+				&GaugeEvent{GMetricName: "foo", GValue: 10},
+				&GaugeEvent{GMetricName: "foo", GValue: -5, GRelative: true},
+				&GaugeEvent{GMetricName: "foo", GValue: 3, GRelative: true},
+			},
+			aggregated: 1,
+		},
+		{
+			name: "sampled counters",
+			in: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&CounterEvent{CMetricName: "foo", CValue: 2.5, CSampled: true},
+				&CounterEvent{CMetricName: "foo", CValue: 1, CSampled: true},
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+			},
+			out: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 2},
+				&CounterEvent{CMetricName: "foo", CValue: 3.5, CSampled: true},
+			},
+			aggregated: 2,
+		},
+		{
+			name: "types are kept apart",
+			in: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&GaugeEvent{GMetricName: "foo", GValue: 1},
+				&ObserverEvent{OMetricName: "foo", OValue: 1},
+				&ObserverEvent{OMetricName: "foo", OValue: 1},
+			},
+			out: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&GaugeEvent{GMetricName: "foo", GValue: 1},
+				&ObserverEvent{OMetricName: "foo", OValue: 1},
+				&ObserverEvent{OMetricName: "foo", OValue: 1},
+			},
+		},
+		{
+			name: "negative and infinite values",
+			in: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&CounterEvent{CMetricName: "foo", CValue: -1},
+				&CounterEvent{CMetricName: "foo", CValue: math.Inf(1)},
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+			},
+			out: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&CounterEvent{CMetricName: "foo", CValue: -1},
+				&CounterEvent{CMetricName: "foo", CValue: math.Inf(1)},
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+			},
+		},
+		{
+			name:      "not mergeable",
+			mergeable: func(e Event) bool { return e.MetricType() != mapper.MetricTypeCounter },
+			in: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&CounterEvent{CMetricName: "foo", CValue: 2},
+			},
+			out: Events{
+				&CounterEvent{CMetricName: "foo", CValue: 1},
+				&CounterEvent{CMetricName: "foo", CValue: 2},
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			c := make(chan Events, 10)
+			eq := NewEventQueue(c, 100, time.Hour, eventsFlushed)
+			aggregated := prometheus.NewCounter(prometheus.CounterOpts{Name: "aggregated"})
+			mergeable := s.mergeable
+			if mergeable == nil {
+				mergeable = func(Event) bool { return true }
+			}
+			eq.Aggregate(mergeable, aggregated)
+			first := s.in[0].Value()
+
+			eq.Queue(s.in)
+			eq.Flush()
+			if out := <-c; !reflect.DeepEqual(out, s.out) {
+				t.Errorf("expected %v, got %v", s.out, out)
+			}
+			if v := testutil.ToFloat64(aggregated); v != s.aggregated {
+				t.Errorf("expected %v aggregated events, got %v", s.aggregated, v)
+			}
+			if v := s.in[0].Value(); v != first {
+				t.Errorf("expected the queued events to stay unchanged, got value %v instead of %v", v, first)
+			}
+			// Series start over after a flush.
+			eq.Queue(s.in[:1])
+			eq.Flush()
+			if out := <-c; len(out) != 1 {
+				t.Errorf("expected 1 event after the flush, got %v", out)
+			}
+		})
+	}
+}
+
+func TestObserverEventValue(t *testing.T) {
+	timer := &ObserverEvent{OMetricName: "foo", OValue: 350, OTimer: true}
+	if timer.Value() != 0.35 {
+		t.Errorf("expected timer value in seconds, got %v", timer.Value())
+	}
+	histogram := &ObserverEvent{OMetricName: "foo", OValue: 350}
+	if histogram.Value() != 350 {
+		t.Errorf("expected histogram value as is, got %v", histogram.Value())
+	}
+}
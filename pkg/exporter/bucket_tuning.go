@@ -0,0 +1,235 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beorn7/perks/quantile"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// MetricResetter is implemented by registries that can drop a metric with all
+// its series, so that it is created anew with other options.
+type MetricResetter interface {
+	ResetMetric(metricName string)
+}
+
+// LearnedBuckets describes the buckets learned for a histogram with
+// auto_buckets.
+type LearnedBuckets struct {
+	MetricName string `json:"metricName"`
+	Match      string `json:"match"`
+	Name       string `json:"name"`
+	// State is "learning" during the warmup, when the buckets are
+	// provisional, and "tuned" once they are in use.
+	State        string    `json:"state"`
+	Observations uint64    `json:"observations"`
+	Buckets      []float64 `json:"buckets"`
+}
+
+// bucketTuner learns the buckets of one histogram.
+type bucketTuner struct {
+	match, name  string
+	started      time.Time
+	options      mapper.AutoBucketsOptions
+	stream       *quantile.Stream
+	observations uint64
+	// buckets are set once the warmup is over.
+	buckets []float64
+	// tuned is source with the learned buckets.
+	source, tuned *mapper.MetricMapping
+}
+
+// bucketTuning holds the tuners of all histograms with auto_buckets. It is
+// used from Listen and read by the HTTP handler.
+type bucketTuning struct {
+	mtx    sync.Mutex
+	tuners map[string]*bucketTuner
+}
+
+// autoBuckets returns the mapping to create the histogram with. During the
+// warmup, the observation is recorded and the mapping is returned as is.
+// Afterwards, a copy of the mapping with the learned buckets is returned.
+func (b *Exporter) autoBuckets(metricName string, mapping *mapper.MetricMapping, value float64) *mapper.MetricMapping {
+	options := b.Mapper.Defaults.HistogramOptions.AutoBuckets
+	if mapping.HistogramOptions != nil {
+		options = mapping.HistogramOptions.AutoBuckets
+	}
+	if options == nil {
+		return mapping
+	}
+
+	b.tuning.mtx.Lock()
+	defer b.tuning.mtx.Unlock()
+
+	t, ok := b.tuning.tuners[metricName]
+	if !ok {
+		if b.tuning.tuners == nil {
+			b.tuning.tuners = map[string]*bucketTuner{}
+		}
+		t = &bucketTuner{
+			match:   mapping.Match,
+			name:    mapping.NameTemplate(),
+			started: clock.Now(),
+			options: *options,
+			stream:  quantile.NewTargeted(bucketTargets(options.Count)),
+		}
+		b.tuning.tuners[metricName] = t
+	}
+
+	if t.buckets == nil {
+		// Infinite values would make infinite buckets.
+		if !math.IsInf(value, 0) {
+			t.stream.Insert(value)
+			t.observations++
+		}
+		if clock.Now().Sub(t.started) < t.options.Warmup || t.stream.Count() == 0 {
+			return mapping
+		}
+
+		t.buckets = learnBuckets(t.stream, t.options.Count)
+		t.stream = nil
+		b.Logger.Info("Learned histogram buckets", "metric", metricName, "match", t.match, "observations", t.observations, "buckets", t.buckets)
+		if resetter, ok := b.Registry.(MetricResetter); ok {
+			resetter.ResetMetric(metricName)
+		}
+	}
+
+	if t.source != mapping {
+		tuned := *mapping
+		histogramOptions := mapper.HistogramOptions{}
+		if mapping.HistogramOptions != nil {
+			histogramOptions = *mapping.HistogramOptions
+		}
+		histogramOptions.Buckets = t.buckets
+		tuned.HistogramOptions = &histogramOptions
+		t.source, t.tuned = mapping, &tuned
+	}
+	return t.tuned
+}
+
+// bucketQuantiles returns the quantiles that become bucket boundaries: count
+// evenly spaced quantiles, the last of them moved to the 99th percentile so
+// that the tail is resolved.
+func bucketQuantiles(count int) []float64 {
+	quantiles := make([]float64, 0, count)
+	for i := 1; i < count; i++ {
+		q := float64(i) / float64(count)
+		if q >= 0.99 {
+			break
+		}
+		quantiles = append(quantiles, q)
+	}
+	return append(quantiles, 0.99)
+}
+
+// bucketTargets returns the targets of the sketch, with an error of a tenth
+// of the distance to the next bucket for the tail quantiles.
+func bucketTargets(count int) map[float64]float64 {
+	targets := map[float64]float64{}
+	for _, q := range bucketQuantiles(count) {
+		targets[q] = math.Min(0.01, (1-q)/10)
+	}
+	return targets
+}
+
+// learnBuckets returns the bucket boundaries for the observations in the
+// stream, rounded up to two significant digits.
+func learnBuckets(stream *quantile.Stream, count int) []float64 {
+	buckets := make([]float64, 0, count)
+	for _, q := range bucketQuantiles(count) {
+		buckets = append(buckets, roundUpSignificant(stream.Query(q), 2))
+	}
+	sort.Float64s(buckets)
+
+	unique := buckets[:0]
+	for i, bucket := range buckets {
+		if i == 0 || bucket != buckets[i-1] {
+			unique = append(unique, bucket)
+		}
+	}
+	return unique
+}
+
+// roundUpSignificant rounds v up to the given number of significant digits,
+// so that a bucket still holds the observations at its boundary.
+func roundUpSignificant(v float64, digits int) float64 {
+	if v == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return v
+	}
+	scale := math.Pow(10, float64(digits)-math.Ceil(math.Log10(math.Abs(v))))
+	// The epsilon keeps values that are already round from being rounded up
+	// because of the imprecision of the scaling.
+	scaled := math.Ceil(v*scale - 1e-9)
+	// Formatting with the precision of the result drops the noise of the
+	// division, such as in 0.30000000000000004.
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(scaled/scale, 'g', digits, 64), 64)
+	return rounded
+}
+
+// LearnedBuckets returns the buckets of the histograms with auto_buckets,
+// ordered by metric name. For histograms that are still warming up, the
+// buckets that would be learned from the observations so far are returned.
+func (b *Exporter) LearnedBuckets() []LearnedBuckets {
+	b.tuning.mtx.Lock()
+	defer b.tuning.mtx.Unlock()
+
+	learned := make([]LearnedBuckets, 0, len(b.tuning.tuners))
+	for metricName, t := range b.tuning.tuners {
+		l := LearnedBuckets{
+			MetricName:   metricName,
+			Match:        t.match,
+			Name:         t.name,
+			State:        "tuned",
+			Observations: t.observations,
+			Buckets:      t.buckets,
+		}
+		if t.buckets == nil {
+			l.State = "learning"
+			l.Buckets = []float64{}
+			if t.stream.Count() > 0 {
+				l.Buckets = learnBuckets(t.stream, t.options.Count)
+			}
+		}
+		learned = append(learned, l)
+	}
+	sort.Slice(learned, func(i, j int) bool {
+		return learned[i].MetricName < learned[j].MetricName
+	})
+	return learned
+}
+
+// LearnedBucketsHandler returns the buckets of the histograms with
+// auto_buckets as JSON, in the response format of the Prometheus HTTP API.
+func (b *Exporter) LearnedBucketsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Status string           `json:"status"`
+			Data   []LearnedBuckets `json:"data"`
+		}{
+			Status: "success",
+			Data:   b.LearnedBuckets(),
+		})
+	})
+}
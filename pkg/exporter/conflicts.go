@@ -0,0 +1,108 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// Conflict describes events that could not be recorded because the metric
+// they map to is already registered with a different type or label set.
+type Conflict struct {
+	MetricName string    `json:"metricName"`
+	EventType  string    `json:"eventType"`
+	StatsdName string    `json:"statsdName"`
+	Error      string    `json:"error"`
+	Count      uint64    `json:"count"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+type conflictKey struct {
+	metricName, eventType, statsdName string
+}
+
+// ConflictLog keeps a bounded record of conflicting events. Once full, the
+// oldest entry is evicted to make room for a new one. It is safe for
+// concurrent use.
+type ConflictLog struct {
+	mtx     sync.Mutex
+	size    int
+	entries map[conflictKey]*Conflict
+	order   []conflictKey
+}
+
+func NewConflictLog(size int) *ConflictLog {
+	return &ConflictLog{
+		size:    size,
+		entries: make(map[conflictKey]*Conflict, size),
+	}
+}
+
+// Record adds a conflicting event to the log.
+func (l *ConflictLog) Record(metricName, eventType, statsdName string, err error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := clock.Now()
+	key := conflictKey{metricName, eventType, statsdName}
+	c, ok := l.entries[key]
+	if !ok {
+		if len(l.order) >= l.size {
+			delete(l.entries, l.order[0])
+			l.order = l.order[1:]
+		}
+		c = &Conflict{
+			MetricName: metricName,
+			EventType:  eventType,
+			StatsdName: statsdName,
+			FirstSeen:  now,
+		}
+		l.entries[key] = c
+		l.order = append(l.order, key)
+	}
+	c.Error = err.Error()
+	c.Count++
+	c.LastSeen = now
+}
+
+// Conflicts returns a copy of the recorded conflicts, oldest first.
+func (l *ConflictLog) Conflicts() []Conflict {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	conflicts := make([]Conflict, 0, len(l.order))
+	for _, key := range l.order {
+		conflicts = append(conflicts, *l.entries[key])
+	}
+	return conflicts
+}
+
+// ServeHTTP returns the recorded conflicts as JSON, in the response format of
+// the Prometheus HTTP API.
+func (l *ConflictLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Status string     `json:"status"`
+		Data   []Conflict `json:"data"`
+	}{
+		Status: "success",
+		Data:   l.Conflicts(),
+	})
+}
@@ -14,15 +14,18 @@
 package exporter
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
-	"os"
+	"math"
+	"math/rand"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/event"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/registry"
 )
 
@@ -31,39 +34,175 @@ const (
 	regErrF     = "Failed to update metric"
 )
 
+// randFloat64 is used to sample observations. It is a variable so that tests
+// can make sampling deterministic.
+var randFloat64 = rand.Float64
+
 type Registry interface {
 	GetCounter(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Counter, error)
 	GetGauge(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Gauge, error)
 	GetHistogram(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
 	GetSummary(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
+	GetAggregatedGauges(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
+	GetGaugeHistogram(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
+	GetSumAndCount(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
 	RemoveStaleMetrics()
 }
 
+// AbsoluteCounterGetter is implemented by registries that support counters
+// fed with cumulative totals, for mappings with the absolute counter mode.
+type AbsoluteCounterGetter interface {
+	GetAbsoluteCounter(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (*registry.AbsoluteCounter, error)
+}
+
+// RateGetter is implemented by registries that support rate gauges of
+// counters, for mappings with a rate window.
+type RateGetter interface {
+	GetRate(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (*registry.Rate, error)
+}
+
+// ReceiveTracker is implemented by registries that record when metrics were
+// first and last received, for mappings that track it.
+type ReceiveTracker interface {
+	TrackReceived(metricName string) registry.Received
+}
+
 type Exporter struct {
-	Mapper                *mapper.MetricMapper
-	Registry              Registry
-	Logger                *slog.Logger
-	EventsActions         *prometheus.CounterVec
-	EventsUnmapped        prometheus.Counter
+	Mapper         *mapper.MetricMapper
+	Registry       Registry
+	Logger         *slog.Logger
+	EventsActions  *prometheus.CounterVec
+	EventsUnmapped prometheus.Counter
+	// EventsMapped, if set, counts events per configured mapping, labelled
+	// with the mapping's name template.
+	EventsMapped          *prometheus.CounterVec
 	ErrorEventStats       *prometheus.CounterVec
 	EventStats            *prometheus.CounterVec
 	ConflictingEventStats *prometheus.CounterVec
 	MetricsCount          *prometheus.GaugeVec
+	// LabelCollisions, if set, counts events with a tag whose value differs
+	// from a label set by their mapping, by mapping name template and label.
+	LabelCollisions *prometheus.CounterVec
+	// EmptyMetricNames, if set, counts the events dropped because their
+	// mapping generated an empty metric name, by the match of the mapping.
+	EmptyMetricNames *prometheus.CounterVec
+	// LabelSchemaMismatches, if set, counts events whose labels do not match
+	// the label schema of their mapping, by mapping name template and whether
+	// the labels were "normalized" or the event "rejected".
+	LabelSchemaMismatches *prometheus.CounterVec
+	// Conflicts, if set, records the details of conflicting events.
+	Conflicts *ConflictLog
+	// ExtraLabels are added to every metric, overriding tags and mapping
+	// labels of the same name.
+	ExtraLabels prometheus.Labels
+	// Sweep selects when expired time series are removed. The default is
+	// SweepTicker.
+	Sweep SweepStrategy
+	// Trace, if set, returns a logger for events whose processing should be
+	// traced, and nil for all others.
+	Trace func(event.Event) *slog.Logger
+	// MemoryGuard, if set, evicts time series when the heap grows too large.
+	// The registry must implement SeriesEvicter.
+	MemoryGuard *MemoryGuard
+	// GaugeCoalesceWindow, if positive, holds back gauge updates for up to
+	// this long, so that only the last value of each series within the
+	// window is applied, with the relative changes received after it.
+	GaugeCoalesceWindow time.Duration
+	// FirstReceived and LastReceived, if set, are the Unix times at which
+	// the metrics of mappings with track_received were first and last
+	// received, by metric name. The registry must implement ReceiveTracker.
+	FirstReceived *prometheus.GaugeVec
+	LastReceived  *prometheus.GaugeVec
+	// CoalescedGaugeUpdates, if set, counts the gauge updates that were
+	// merged with an earlier update of the same series in the window.
+	CoalescedGaugeUpdates prometheus.Counter
+	// Sinks receive the events of the mappings that output to them, by
+	// output. Events for an output without a sink are counted as errors.
+	Sinks map[mapper.Output]EventSink
+	// Rewriters change the names and labels of events after mapping and the
+	// rewrite rules of the mapping configuration.
+	Rewriters []Rewriter
+	// TypeConflictsResolved, if set, counts the events whose metric was
+	// registered with another type and that were resolved by the type
+	// conflict policy or the enforced type of their mapping, by outcome.
+	TypeConflictsResolved *prometheus.CounterVec
+
+	sweepRequests       chan chan struct{}
+	pingRequests        chan chan struct{}
+	metadataRequests    chan chan []registry.MetricMetadata
+	memoryUsageRequests chan chan *registry.MemoryReport
+	forwardRequests     chan chan []string
+	snapshotRequests    chan chan snapshotReply
+	mappingChecks       chan mappingCheck
+	tuning              bucketTuning
+	pendingGauges       map[string]*pendingGauge
+	stopped             chan struct{}
+	// tracer is the trace logger for the event being handled, if any.
+	tracer *slog.Logger
+	// nextEvictionGC is the garbage collection cycle that has to complete
+	// before the memory guard evicts series again.
+	nextEvictionGC uint64
 }
 
 // Listen handles all events sent to the given channel sequentially. It
-// terminates when the channel is closed.
-func (b *Exporter) Listen(e <-chan event.Events) {
-	removeStaleMetricsTicker := clock.NewTicker(time.Second)
+// terminates when the channel is closed or the context is cancelled.
+func (b *Exporter) Listen(ctx context.Context, e <-chan event.Events) {
+	if b.stopped != nil {
+		defer close(b.stopped)
+	}
+
+	var removeStaleMetricsC <-chan time.Time
+	if b.Sweep != SweepScrape {
+		removeStaleMetricsTicker := clock.NewTicker(time.Second)
+		defer removeStaleMetricsTicker.Stop()
+		removeStaleMetricsC = removeStaleMetricsTicker.C
+	}
+
+	var flushGaugesC <-chan time.Time
+	if b.GaugeCoalesceWindow > 0 {
+		flushGaugesTicker := clock.NewTicker(b.GaugeCoalesceWindow)
+		defer flushGaugesTicker.Stop()
+		flushGaugesC = flushGaugesTicker.C
+	}
+
+	var checkMemoryC <-chan time.Time
+	if b.MemoryGuard != nil {
+		checkMemoryTicker := clock.NewTicker(b.MemoryGuard.Interval)
+		defer checkMemoryTicker.Stop()
+		checkMemoryC = checkMemoryTicker.C
+	}
 
 	for {
 		select {
-		case <-removeStaleMetricsTicker.C:
+		case <-removeStaleMetricsC:
 			b.Registry.RemoveStaleMetrics()
+		case done := <-b.sweepRequests:
+			b.Registry.RemoveStaleMetrics()
+			close(done)
+		case done := <-b.pingRequests:
+			close(done)
+		case reply := <-b.metadataRequests:
+			reply <- b.metadata()
+		case reply := <-b.memoryUsageRequests:
+			reply <- b.memoryUsage()
+		case reply := <-b.forwardRequests:
+			reply <- b.forwardLines()
+		case reply := <-b.snapshotRequests:
+			reply <- b.snapshot()
+		case check := <-b.mappingChecks:
+			check.reply <- b.checkMappings(check.mapper)
+		case <-checkMemoryC:
+			b.checkMemory()
+		case <-flushGaugesC:
+			b.flushGauges()
+		case <-ctx.Done():
+			b.Logger.Debug("Context is cancelled. Break out of Exporter.Listener.")
+			b.flushGauges()
+			return
 		case events, ok := <-e:
 			if !ok {
 				b.Logger.Debug("Channel is closed. Break out of Exporter.Listener.")
-				removeStaleMetricsTicker.Stop()
+				b.flushGauges()
 				return
 			}
 			for _, event := range events {
@@ -75,16 +214,38 @@ func (b *Exporter) Listen(e <-chan event.Events) {
 
 // handleEvent processes a single Event according to the configured mapping.
 func (b *Exporter) handleEvent(thisEvent event.Event) {
-	mapping, labels, present := b.Mapper.GetMapping(thisEvent.MetricName(), thisEvent.MetricType())
+	if b.Trace != nil {
+		b.tracer = b.Trace(thisEvent)
+		defer func() { b.tracer = nil }()
+	}
+
+	prometheusLabels := thisEvent.Labels()
+	mapping, labels, present := b.Mapper.GetMappingWithTags(thisEvent.MetricName(), thisEvent.MetricType(), prometheusLabels)
 	if mapping == nil {
-		mapping = &mapper.MetricMapping{}
-		if b.Mapper.Defaults.Ttl != 0 {
+		mapping = &mapper.MetricMapping{ObserverType: b.Mapper.Defaults.UnmappedObserverType}
+		if b.Mapper.Defaults.UnmappedTtl != 0 {
+			mapping.Ttl = b.Mapper.Defaults.UnmappedTtl
+		} else if b.Mapper.Defaults.Ttl != 0 {
 			mapping.Ttl = b.Mapper.Defaults.Ttl
 		}
+		mapping.ExpireOn = b.Mapper.Defaults.ExpireOn
+		mapping.TimerUnit = b.Mapper.Defaults.TimerUnit
+		mapping.SampledCounters = b.Mapper.Defaults.SampledCounters
 	}
 
 	if mapping.Action == mapper.ActionTypeDrop {
 		b.EventsActions.WithLabelValues("drop").Inc()
+		b.trace("dropped", "match", mapping.Match)
+		return
+	}
+	if mapping.Action == mapper.ActionTypeSample && randFloat64() >= mapping.SampleRatio {
+		b.EventsActions.WithLabelValues("sampled_out").Inc()
+		b.trace("sampled_out", "match", mapping.Match)
+		return
+	}
+	if mapping.Schedule != nil && !mapping.Schedule.Active(clock.Now()) {
+		b.EventsActions.WithLabelValues("outside_schedule").Inc()
+		b.trace("outside_schedule", "match", mapping.Match)
 		return
 	}
 
@@ -95,31 +256,80 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 		help = mapping.HelpText
 	}
 
-	prometheusLabels := thisEvent.Labels()
 	if present {
 		if mapping.Name == "" {
 			b.Logger.Debug("The mapping generates an empty metric name", "metric_name", thisEvent.MetricName(), "match", mapping.Match)
 			b.ErrorEventStats.WithLabelValues("empty_metric_name").Inc()
+			if b.EmptyMetricNames != nil {
+				b.EmptyMetricNames.WithLabelValues(mapping.Match).Inc()
+			}
+			b.trace("empty_metric_name", "match", mapping.Match)
 			return
 		}
-		metricName = mapper.EscapeMetricName(mapping.Name)
+		metricName = b.Mapper.EscapeMetricName(mapping.Name)
+		if b.EventsMapped != nil {
+			b.EventsMapped.WithLabelValues(mapping.NameTemplate()).Inc()
+		}
 		for label, value := range labels {
-			if _, ok := prometheusLabels[label]; mapping.HonorLabels && ok {
-				continue
+			if tagValue, ok := prometheusLabels[label]; ok && tagValue != value {
+				b.Logger.Debug("Mapping label collides with a tag", "metric_name", thisEvent.MetricName(), "label", label, "honor_labels", mapping.HonorLabels)
+				if b.LabelCollisions != nil {
+					b.LabelCollisions.WithLabelValues(mapping.NameTemplate(), label).Inc()
+				}
+				if mapping.HonorLabels {
+					continue
+				}
 			}
 
 			prometheusLabels[label] = value
 		}
+		if schema := mapping.LabelSchema; schema != nil && !schema.Conform(prometheusLabels) {
+			if schema.OnMismatch == mapper.LabelSchemaReject {
+				b.Logger.Debug("Labels do not match the label schema", "metric_name", thisEvent.MetricName(), "labels", prometheusLabels)
+				b.labelSchemaMismatch(mapping, "rejected")
+				b.ErrorEventStats.WithLabelValues("label_schema_mismatch").Inc()
+				b.trace("label_schema_mismatch", "labels", prometheusLabels)
+				return
+			}
+			b.labelSchemaMismatch(mapping, "normalized")
+		}
 		b.EventsActions.WithLabelValues(string(mapping.Action)).Inc()
+		if mapping.TrackReceived {
+			b.trackReceived(metricName)
+		}
 	} else {
 		b.EventsUnmapped.Inc()
-		metricName = mapper.EscapeMetricName(thisEvent.MetricName())
+		metricName = b.Mapper.EscapeMetricName(thisEvent.MetricName())
+	}
+	if metricName = b.rewrite(metricName, prometheusLabels); metricName == "" {
+		b.Logger.Debug("A rewrite generates an empty metric name", "metric_name", thisEvent.MetricName())
+		b.ErrorEventStats.WithLabelValues("empty_metric_name").Inc()
+		b.trace("empty_metric_name", "match", mapping.Match)
+		return
+	}
+	for label, value := range b.ExtraLabels {
+		prometheusLabels[label] = value
 	}
 
 	eventValue := thisEvent.Value()
+	if ev, ok := thisEvent.(*event.ObserverEvent); ok && ev.OTimer && mapping.TimerUnit == mapper.TimerUnitMilliseconds {
+		eventValue = ev.OValue
+	}
 	if mapping.Scale.Set {
 		eventValue *= mapping.Scale.Val
 	}
+	b.trace("mapped", "mapped", present, "match", mapping.Match, "name", metricName, "labels", prometheusLabels, "value", eventValue)
+
+	if len(mapping.Outputs) > 0 {
+		b.sendToSinks(thisEvent, mapping, metricName, prometheusLabels, eventValue)
+		if !mapping.HasOutput(mapper.OutputPrometheus) {
+			return
+		}
+	}
+
+	if b.resolveTypeConflict(mapping, metricName, thisEvent) {
+		return
+	}
 
 	switch ev := thisEvent.(type) {
 	case *event.CounterEvent:
@@ -128,23 +338,55 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 		if eventValue < 0.0 {
 			b.Logger.Debug("counter must be non-negative value", "metric", metricName, "event_value", eventValue)
 			b.ErrorEventStats.WithLabelValues("illegal_negative_counter").Inc()
+			b.trace("illegal_negative_counter")
+			return
+		}
+
+		if mapping.CounterMode == mapper.CounterModeAbsolute {
+			getter, ok := b.Registry.(AbsoluteCounterGetter)
+			if !ok {
+				b.Logger.Debug("The registry does not support absolute counters", "metric", metricName)
+				b.ErrorEventStats.WithLabelValues("absolute_counter_unsupported").Inc()
+				b.trace("absolute_counter_unsupported")
+				return
+			}
+			counter, err := getter.GetAbsoluteCounter(metricName, prometheusLabels, help, mapping, b.MetricsCount)
+			if err == nil {
+				increase := counter.SetTotal(eventValue)
+				b.EventStats.WithLabelValues("counter").Inc()
+				b.addRate(metricName, prometheusLabels, help, mapping, increase)
+			} else {
+				b.Logger.Debug(regErrF, "metric", metricName, "error", err)
+				b.conflict("counter", metricName, thisEvent, err)
+			}
 			return
 		}
 
 		counter, err := b.Registry.GetCounter(metricName, prometheusLabels, help, mapping, b.MetricsCount)
 		if err == nil {
+			if ev.CSampled {
+				eventValue = b.sampledIncrement(mapping, metricName, prometheusLabels, eventValue)
+			}
 			counter.Add(eventValue)
 			b.EventStats.WithLabelValues("counter").Inc()
+			b.addRate(metricName, prometheusLabels, help, mapping, eventValue)
 		} else {
 			b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-			b.ConflictingEventStats.WithLabelValues("counter", metricName).Inc()
+			b.conflict("counter", metricName, thisEvent, err)
 		}
 
 	case *event.GaugeEvent:
+		relative := gaugeRelative(ev, mapping)
+		if b.GaugeCoalesceWindow > 0 {
+			b.coalesceGauge(metricName, prometheusLabels, help, mapping, thisEvent, eventValue, relative)
+			b.EventStats.WithLabelValues("gauge").Inc()
+			b.trace("coalesced")
+			return
+		}
 		gauge, err := b.Registry.GetGauge(metricName, prometheusLabels, help, mapping, b.MetricsCount)
 
 		if err == nil {
-			if ev.GRelative {
+			if relative {
 				gauge.Add(eventValue)
 			} else {
 				gauge.Set(eventValue)
@@ -152,7 +394,7 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 			b.EventStats.WithLabelValues("gauge").Inc()
 		} else {
 			b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-			b.ConflictingEventStats.WithLabelValues("gauge", metricName).Inc()
+			b.conflict("gauge", metricName, thisEvent, err)
 		}
 
 	case *event.ObserverEvent:
@@ -164,30 +406,44 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 			t = b.Mapper.Defaults.ObserverType
 		}
 
-		switch t {
-		case mapper.ObserverTypeHistogram:
-			histogram, err := b.Registry.GetHistogram(metricName, prometheusLabels, help, mapping, b.MetricsCount)
-			if err == nil {
-				histogram.Observe(eventValue)
-				b.EventStats.WithLabelValues("observer").Inc()
-			} else {
-				b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-				b.ConflictingEventStats.WithLabelValues("observer", metricName).Inc()
-			}
+		// NaN observations would make the sum and quantiles NaN for good.
+		if math.IsNaN(eventValue) {
+			b.Logger.Debug("NaN observation", "metric", metricName)
+			b.ErrorEventStats.WithLabelValues("nan_observation").Inc()
+			b.trace("nan_observation")
+			return
+		}
 
-		case mapper.ObserverTypeDefault, mapper.ObserverTypeSummary:
-			summary, err := b.Registry.GetSummary(metricName, prometheusLabels, help, mapping, b.MetricsCount)
-			if err == nil {
-				summary.Observe(eventValue)
-				b.EventStats.WithLabelValues("observer").Inc()
-			} else {
-				b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-				b.ConflictingEventStats.WithLabelValues("observer", metricName).Inc()
+		// With sampling, only a fraction of observations is kept. Histograms
+		// and aggregated gauges count each kept observation 1/fraction times,
+		// on average, so that their count and sum stay unbiased.
+		weight := 1
+		if rate := mapping.SampleObservations; rate > 0 && rate < 1 {
+			if randFloat64() >= rate {
+				b.EventStats.WithLabelValues("observer_sampled_out").Inc()
+				b.trace("sampled_out")
+				return
 			}
+			w := 1 / rate
+			weight = int(w)
+			if randFloat64() < w-float64(weight) {
+				weight++
+			}
+		}
 
-		default:
-			b.Logger.Error("unknown observer type", "type", t)
-			os.Exit(1)
+		count := ev.Count()
+		if err := b.observe(t, metricName, prometheusLabels, help, mapping, eventValue, count, weight); err == nil {
+			b.EventStats.WithLabelValues("observer").Inc()
+		} else {
+			b.Logger.Debug(regErrF, "metric", metricName, "error", err)
+			b.conflict("observer", metricName, thisEvent, err)
+		}
+		for _, observer := range mapping.AdditionalObservers {
+			name := metricName + observer.NameSuffix
+			if err := b.observe(observer.ObserverType, name, prometheusLabels, help, observer.Mapping(), eventValue, count, weight); err != nil {
+				b.Logger.Debug(regErrF, "metric", name, "error", err)
+				b.conflict("observer", name, thisEvent, err)
+			}
 		}
 
 	default:
@@ -196,6 +452,108 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 	}
 }
 
+// addRate adds the increment of a counter to its rate gauge, if the mapping
+// has a rate window.
+func (b *Exporter) addRate(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, increment float64) {
+	if mapping.RateWindow <= 0 {
+		return
+	}
+	getter, ok := b.Registry.(RateGetter)
+	if !ok {
+		b.Logger.Debug("The registry does not support rate gauges", "metric", metricName)
+		b.ErrorEventStats.WithLabelValues("rate_unsupported").Inc()
+		return
+	}
+	rate, err := getter.GetRate(metricName, labels, help, mapping, b.MetricsCount)
+	if err != nil {
+		b.Logger.Debug(regErrF, "metric", metricName, "error", err)
+		b.ErrorEventStats.WithLabelValues("rate_conflict").Inc()
+		return
+	}
+	rate.Add(increment)
+}
+
+// trackReceived exposes when a metric was first and last received.
+func (b *Exporter) trackReceived(metricName string) {
+	t, ok := b.Registry.(ReceiveTracker)
+	if !ok || b.FirstReceived == nil || b.LastReceived == nil {
+		return
+	}
+	received := t.TrackReceived(metricName)
+	b.FirstReceived.WithLabelValues(metricName).Set(float64(received.First.UnixNano()) / 1e9)
+	b.LastReceived.WithLabelValues(metricName).Set(float64(received.Last.UnixNano()) / 1e9)
+}
+
+// observe records an observation of an event standing for count observations
+// in the metric of the given observer type. The observations are recorded
+// weight times more, except in summaries, whose quantiles are not affected by
+// sampling.
+func (b *Exporter) observe(t mapper.ObserverType, metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, value float64, count, weight int) error {
+	var observer prometheus.Observer
+	var err error
+	switch t {
+	case mapper.ObserverTypeHistogram:
+		mapping = b.autoBuckets(metricName, mapping, value)
+		observer, err = b.Registry.GetHistogram(metricName, labels, help, mapping, b.MetricsCount)
+	case mapper.ObserverTypeAggregatedGauges:
+		observer, err = b.Registry.GetAggregatedGauges(metricName, labels, help, mapping, b.MetricsCount)
+	case mapper.ObserverTypeGaugeHistogram:
+		observer, err = b.Registry.GetGaugeHistogram(metricName, labels, help, mapping, b.MetricsCount)
+	case mapper.ObserverTypeSumAndCount:
+		observer, err = b.Registry.GetSumAndCount(metricName, labels, help, mapping, b.MetricsCount)
+	case mapper.ObserverTypeDefault, mapper.ObserverTypeSummary:
+		observer, err = b.Registry.GetSummary(metricName, labels, help, mapping, b.MetricsCount)
+		weight = 1
+	default:
+		return fmt.Errorf("unknown observer type %q", t)
+	}
+	if err != nil {
+		return err
+	}
+	if o, ok := observer.(multiObserver); ok {
+		o.ObserveMany(value, count*weight)
+		return nil
+	}
+	// The observers of the client library record one observation per call.
+	// Mappings with sampling get observers that record the weight at once,
+	// but the metric may have been created before sampling was configured.
+	for i := 0; i < count; i++ {
+		observer.Observe(value)
+	}
+	return nil
+}
+
+// multiObserver is implemented by observers that can record the same
+// observation several times at once.
+type multiObserver interface {
+	ObserveMany(value float64, n int)
+}
+
+// labelSchemaMismatch accounts for an event whose labels do not match the
+// label schema of its mapping.
+func (b *Exporter) labelSchemaMismatch(mapping *mapper.MetricMapping, action string) {
+	if b.LabelSchemaMismatches != nil {
+		b.LabelSchemaMismatches.WithLabelValues(mapping.NameTemplate(), action).Inc()
+	}
+}
+
+// conflict accounts for an event that could not be recorded because its
+// metric is already registered with a different type or label set.
+func (b *Exporter) conflict(eventType, metricName string, thisEvent event.Event, err error) {
+	b.ConflictingEventStats.WithLabelValues(eventType, metricName).Inc()
+	b.trace("conflict", "name", metricName, "error", err)
+	if b.Conflicts != nil {
+		b.Conflicts.Record(metricName, eventType, thisEvent.MetricName(), err)
+	}
+}
+
+// trace logs a stage of processing the current event, if it is traced.
+func (b *Exporter) trace(stage string, args ...any) {
+	if b.tracer != nil {
+		b.tracer.Info("Trace", append([]any{"stage", stage}, args...)...)
+	}
+}
+
 func NewExporter(reg prometheus.Registerer, mapper *mapper.MetricMapper, logger *slog.Logger, eventsActions *prometheus.CounterVec, eventsUnmapped prometheus.Counter, errorEventStats *prometheus.CounterVec, eventStats *prometheus.CounterVec, conflictingEventStats *prometheus.CounterVec, metricsCount *prometheus.GaugeVec) *Exporter {
 	return &Exporter{
 		Mapper:                mapper,
@@ -207,5 +565,13 @@ func NewExporter(reg prometheus.Registerer, mapper *mapper.MetricMapper, logger
 		EventStats:            eventStats,
 		ConflictingEventStats: conflictingEventStats,
 		MetricsCount:          metricsCount,
+		sweepRequests:         make(chan chan struct{}),
+		pingRequests:          make(chan chan struct{}),
+		metadataRequests:      make(chan chan []registry.MetricMetadata),
+		memoryUsageRequests:   make(chan chan *registry.MemoryReport),
+		forwardRequests:       make(chan chan []string),
+		snapshotRequests:      make(chan chan snapshotReply),
+		mappingChecks:         make(chan mappingCheck),
+		stopped:               make(chan struct{}),
 	}
 }
@@ -14,21 +14,32 @@
 package exporter
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/promslog"
 
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/event"
 	"github.com/prometheus/statsd_exporter/pkg/line"
 	"github.com/prometheus/statsd_exporter/pkg/listener"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
 	"github.com/prometheus/statsd_exporter/pkg/registry"
 )
 
@@ -87,11 +98,12 @@ var (
 			Help: "The total number of StatsD lines received.",
 		},
 	)
-	samplesReceived = prometheus.NewCounter(
+	samplesReceived = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_samples_total",
 			Help: "The total number of StatsD samples received.",
 		},
+		[]string{"type"},
 	)
 	sampleErrors = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -174,7 +186,7 @@ func TestNegativeCounter(t *testing.T) {
 	testMapper := mapper.MetricMapper{}
 
 	ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-	ex.Listen(events)
+	ex.Listen(context.Background(), events)
 
 	updated := getTelemetryCounterValue(errorCounter)
 	if updated-prev != 1 {
@@ -255,7 +267,7 @@ mappings:
 	}
 
 	ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-	ex.Listen(events)
+	ex.Listen(context.Background(), events)
 
 	metrics, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
@@ -318,7 +330,7 @@ mappings:
 	}
 
 	ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-	ex.Listen(events)
+	ex.Listen(context.Background(), events)
 
 	metrics, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
@@ -368,7 +380,7 @@ mappings:
 	}
 
 	ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-	ex.Listen(events)
+	ex.Listen(context.Background(), events)
 
 	metrics, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
@@ -380,6 +392,68 @@ mappings:
 	}
 }
 
+func TestLabelCollisions(t *testing.T) {
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			&event.CounterEvent{
+				CMetricName: "collide.counter",
+				CValue:      1,
+				CLabels:     map[string]string{"some_label": "bar"},
+			},
+			&event.CounterEvent{
+				CMetricName: "collide.counter",
+				CValue:      1,
+				CLabels:     map[string]string{"some_label": "foo"},
+			},
+		}
+		close(events)
+	}()
+
+	config := `
+defaults:
+  honor_labels: true
+mappings:
+  - match: collide.*
+    name: collided_$1
+    labels:
+      some_label: foo
+`
+	testMapper := &mapper.MetricMapper{
+		Logger: promslog.NewNopLogger(),
+	}
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	labelCollisions := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_label_collisions_total",
+			Help: "The total number of events with a tag that collides with a label set by their mapping.",
+		},
+		[]string{"mapping_name", "label"},
+	)
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.LabelCollisions = labelCollisions
+	ex.Listen(context.Background(), events)
+
+	// Only the first event has a tag value that differs from the mapping.
+	if v := testutil.ToFloat64(labelCollisions.WithLabelValues("collided_$1", "some_label")); v != 1 {
+		t.Fatalf("Expected 1 label collision, got %f", v)
+	}
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	// honor_labels from the defaults lets the tag win.
+	if getFloat64(metrics, "collided_counter", map[string]string{"some_label": "bar"}) == nil {
+		t.Fatalf("Could not find metrics for collided_counter with the tag value")
+	}
+}
+
 // TestConflictingMetrics validates that the exporter will not register metrics
 // of different types that have overlapping names.
 func TestConflictingMetrics(t *testing.T) {
@@ -649,7 +723,7 @@ mappings:
 			}()
 			reg := prometheus.NewRegistry()
 			ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-			ex.Listen(events)
+			ex.Listen(context.Background(), events)
 
 			metrics, err := reg.Gather()
 			if err != nil {
@@ -675,6 +749,53 @@ mappings:
 // TestEmptyStringMetric validates when a metric name ends up
 // being the empty string after applying the match replacements
 // tha we don't panic the Exporter Listener.
+func TestConflictLog(t *testing.T) {
+	conflicts := NewConflictLog(2)
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(prometheus.NewRegistry(), &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Conflicts = conflicts
+		ex.Listen(context.Background(), events)
+	}()
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "a", CValue: 1},
+		&event.GaugeEvent{GMetricName: "a", GValue: 1},
+		&event.GaugeEvent{GMetricName: "a", GValue: 2},
+		&event.CounterEvent{CMetricName: "b", CValue: 1},
+		&event.GaugeEvent{GMetricName: "b", GValue: 1},
+		&event.CounterEvent{CMetricName: "c", CValue: 1},
+		&event.ObserverEvent{OMetricName: "c", OValue: 1},
+	}
+	events <- event.Events{}
+	close(events)
+
+	// The log holds two entries, so the conflict on "a" was evicted.
+	got := conflicts.Conflicts()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %d: %v", len(got), got)
+	}
+	if got[0].MetricName != "b" || got[0].EventType != "gauge" || got[0].Count != 1 {
+		t.Errorf("Unexpected first conflict: %+v", got[0])
+	}
+	if got[1].MetricName != "c" || got[1].EventType != "observer" || got[1].Error == "" {
+		t.Errorf("Unexpected second conflict: %+v", got[1])
+	}
+
+	rec := httptest.NewRecorder()
+	conflicts.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/conflicts", nil))
+	var resp struct {
+		Status string     `json:"status"`
+		Data   []Conflict `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Unable to decode response: %v", err)
+	}
+	if resp.Status != "success" || len(resp.Data) != 2 || resp.Data[0].StatsdName != "b" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
 func TestEmptyStringMetric(t *testing.T) {
 	events := make(chan event.Events)
 	go func() {
@@ -703,13 +824,18 @@ mappings:
 	errorCounter := errorEventStats.WithLabelValues("empty_metric_name")
 	prev := getTelemetryCounterValue(errorCounter)
 
+	emptyNames := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "empty_names"}, []string{"match"})
 	ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-	ex.Listen(events)
+	ex.EmptyMetricNames = emptyNames
+	ex.Listen(context.Background(), events)
 
 	updated := getTelemetryCounterValue(errorCounter)
 	if updated-prev != 1 {
 		t.Fatal("Empty metric name error event not counted")
 	}
+	if v := testutil.ToFloat64(emptyNames.WithLabelValues(".*_bar")); v != 1 {
+		t.Fatalf("Expected the empty metric name to be counted for the mapping, got %v", v)
+	}
 }
 
 // TestInvalidUtf8InDatadogTagValue validates robustness of exporter listener
@@ -744,7 +870,7 @@ func TestInvalidUtf8InDatadogTagValue(t *testing.T) {
 			LinesReceived:   linesReceived,
 			EventsFlushed:   eventsFlushed,
 			SampleErrors:    *sampleErrors,
-			SamplesReceived: samplesReceived,
+			SamplesReceived: *samplesReceived,
 			TagErrors:       tagErrors,
 			TagsReceived:    tagsReceived,
 		}, &mockStatsDTCPListener{listener.StatsDTCPListener{
@@ -755,7 +881,7 @@ func TestInvalidUtf8InDatadogTagValue(t *testing.T) {
 			LinesReceived:   linesReceived,
 			EventsFlushed:   eventsFlushed,
 			SampleErrors:    *sampleErrors,
-			SamplesReceived: samplesReceived,
+			SamplesReceived: *samplesReceived,
 			TagErrors:       tagErrors,
 			TagsReceived:    tagsReceived,
 			TCPConnections:  tcpConnections,
@@ -771,7 +897,7 @@ func TestInvalidUtf8InDatadogTagValue(t *testing.T) {
 	testMapper := mapper.MetricMapper{}
 
 	ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-	ex.Listen(events)
+	ex.Listen(context.Background(), events)
 }
 
 // In the case of someone starting the statsd exporter with no mapping file specified
@@ -784,7 +910,7 @@ func TestSummaryWithQuantilesEmptyMapping(t *testing.T) {
 		testMapper := mapper.MetricMapper{}
 
 		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-		ex.Listen(events)
+		ex.Listen(context.Background(), events)
 	}()
 
 	name := "default_foo"
@@ -828,7 +954,7 @@ func TestHistogramUnits(t *testing.T) {
 		testMapper := mapper.MetricMapper{}
 		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
 		ex.Mapper.Defaults.ObserverType = mapper.ObserverTypeHistogram
-		ex.Listen(events)
+		ex.Listen(context.Background(), events)
 	}()
 
 	// Synchronously send a statsd event to wait for handleEvent execution.
@@ -857,13 +983,426 @@ func TestHistogramUnits(t *testing.T) {
 		t.Fatalf("Received unexpected value for histogram observation %f != .300", *value)
 	}
 }
+func TestSampleObservations(t *testing.T) {
+	config := `
+mappings:
+- match: hot.timer
+  name: hot_timer
+  observer_type: histogram
+  sample_observations: 0.25
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	// Keep the first and the last of four observations.
+	randValues := []float64{0.1, 0, 0.5, 0.9, 0.2, 0}
+	defer func(f func() float64) { randFloat64 = f }(randFloat64)
+	randFloat64 = func() float64 {
+		v := randValues[0]
+		randValues = randValues[1:]
+		return v
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	var ev event.Events
+	for i := 0; i < 3; i++ {
+		ev = append(ev, &event.ObserverEvent{OMetricName: "hot.timer", OValue: 2})
+	}
+	// The last event stands for three observations.
+	ev = append(ev, &event.ObserverEvent{OMetricName: "hot.timer", OValue: 2, OCount: 3})
+	events <- ev
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Expected one metric family, got %d", len(metrics))
+	}
+	h := metrics[0].GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 16 || h.GetSampleSum() != 32 {
+		t.Fatalf("Expected a corrected count of 16 and sum of 32, got %d and %f", h.GetSampleCount(), h.GetSampleSum())
+	}
+	for _, b := range h.GetBucket() {
+		if expected := map[bool]uint64{true: 16, false: 0}[b.GetUpperBound() >= 2]; b.GetCumulativeCount() != expected {
+			t.Fatalf("Expected %d observations up to %v, got %d", expected, b.GetUpperBound(), b.GetCumulativeCount())
+		}
+	}
+}
+
+func TestSampledCounters(t *testing.T) {
+	config := `
+defaults:
+  sampled_counters: accumulate
+mappings:
+- match: exact.*
+  name: exact_$1
+  sampled_counters: exact
+- match: stochastic.*
+  name: stochastic_$1
+  sampled_counters: stochastic
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	// Round the first two stochastic increments down and the third up.
+	randValues := []float64{0.5, 0.9, 0.1}
+	defer func(f func() float64) { randFloat64 = f }(randFloat64)
+	randFloat64 = func() float64 {
+		v := randValues[0]
+		randValues = randValues[1:]
+		return v
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	// Each event stands for foo:1|c|@0.3.
+	for i := 0; i < 3; i++ {
+		for _, name := range []string{"exact.requests", "stochastic.requests", "unmapped_requests"} {
+			ex.handleEvent(&event.CounterEvent{CMetricName: name, CValue: 1 / 0.3, CSampled: true, CLabels: map[string]string{}})
+		}
+	}
+	// Unsampled increments are added as they are.
+	ex.handleEvent(&event.CounterEvent{CMetricName: "unmapped_requests", CValue: 0.5, CLabels: map[string]string{}})
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	for name, expected := range map[string]float64{
+		"exact_requests":      3 / 0.3,
+		"stochastic_requests": 10,
+		"unmapped_requests":   10.5,
+	} {
+		if v := getFloat64(metrics, name, prometheus.Labels{}); v == nil || math.Abs(*v-expected) > 1e-9 {
+			t.Errorf("expected %s to be %v, got %v", name, expected, v)
+		}
+	}
+}
+
+func TestObservationCount(t *testing.T) {
+	config := `
+mappings:
+- match: hist.timer
+  name: hist_timer
+  observer_type: histogram
+- match: summ.timer
+  name: summ_timer
+  observer_type: summary
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	for _, name := range []string{"hist.timer", "summ.timer"} {
+		ex.handleEvent(&event.ObserverEvent{OMetricName: name, OValue: 0.5, OCount: 10, OLabels: map[string]string{}})
+		ex.handleEvent(&event.ObserverEvent{OMetricName: name, OValue: 1, OLabels: map[string]string{}})
+	}
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("Expected two metric families, got %d", len(metrics))
+	}
+	for _, mf := range metrics {
+		m := mf.GetMetric()[0]
+		count, sum := m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+		if mf.GetName() == "summ_timer" {
+			count, sum = m.GetSummary().GetSampleCount(), m.GetSummary().GetSampleSum()
+		}
+		if count != 11 || sum != 6 {
+			t.Errorf("Expected %s to have a count of 11 and sum of 6, got %d and %f", mf.GetName(), count, sum)
+		}
+	}
+}
+
+func TestSampleAction(t *testing.T) {
+	config := `
+mappings:
+- match: debug.*
+  name: debug_total
+  action: sample
+  sample_ratio: 0.25
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	// Keep the second and the last of four events.
+	randValues := []float64{0.5, 0.1, 0.25, 0}
+	defer func(f func() float64) { randFloat64 = f }(randFloat64)
+	randFloat64 = func() float64 {
+		v := randValues[0]
+		randValues = randValues[1:]
+		return v
+	}
+
+	sampledOut := eventsActions.WithLabelValues("sampled_out")
+	sampledOutBefore := testutil.ToFloat64(sampledOut)
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	var ev event.Events
+	for i := 0; i < 4; i++ {
+		ev = append(ev, &event.CounterEvent{CMetricName: "debug.foo", CValue: 1})
+	}
+	events <- ev
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if value := getFloat64(metrics, "debug_total", prometheus.Labels{}); value == nil || *value != 2 {
+		t.Fatalf("Expected two of four events to be kept, got %v", value)
+	}
+	if v := testutil.ToFloat64(sampledOut) - sampledOutBefore; v != 2 {
+		t.Fatalf("Expected two sampled out events to be counted, got %v", v)
+	}
+}
+
+func TestAbsoluteCounter(t *testing.T) {
+	config := `
+mappings:
+- match: requests.*
+  name: requests_total
+  counter_mode: absolute
+  labels:
+    host: $1
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	for _, scenario := range []struct {
+		total    float64
+		expected float64
+	}{
+		{total: 10, expected: 10},
+		{total: 15, expected: 15},
+		{total: 15, expected: 15},
+		// The emitter restarted.
+		{total: 3, expected: 18},
+		{total: 8, expected: 23},
+	} {
+		events <- event.Events{&event.CounterEvent{CMetricName: "requests.a", CValue: scenario.total, CLabels: map[string]string{}}}
+		events <- event.Events{}
+
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		if value := getFloat64(metrics, "requests_total", prometheus.Labels{"host": "a"}); value == nil || *value != scenario.expected {
+			t.Fatalf("Expected %v after a total of %v, got %v", scenario.expected, scenario.total, value)
+		}
+	}
+
+	// Each series has its own total.
+	events <- event.Events{&event.CounterEvent{CMetricName: "requests.b", CValue: 4, CLabels: map[string]string{}}}
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if value := getFloat64(metrics, "requests_total", prometheus.Labels{"host": "b"}); value == nil || *value != 4 {
+		t.Fatalf("Expected 4 for another series, got %v", value)
+	}
+}
+
+func TestForwardLines(t *testing.T) {
+	config := `
+mappings:
+- match: requests.*
+  name: requests_total
+  forward: true
+  labels:
+    host: $1
+- match: temperature.*
+  name: temperature
+  forward: true
+  labels:
+    room: $1
+- match: latency
+  name: latency
+  forward: true
+- match: local.*
+  name: local_total
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	go ex.Listen(context.Background(), events)
+	defer close(events)
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "requests.b", CValue: 2, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "requests.a", CValue: 3, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "local.a", CValue: 1, CLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "temperature.kitchen", GValue: 21.5, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "temperature.freezer", GValue: -18, GLabels: map[string]string{}},
+		&event.ObserverEvent{OMetricName: "latency", OValue: 0.1, OLabels: map[string]string{}},
+	}
+	expected := []string{
+		"requests_total:3|c|#host:a",
+		"requests_total:2|c|#host:b",
+		"temperature:0|g|#room:freezer",
+		"temperature:-18|g|#room:freezer",
+		"temperature:21.5|g|#room:kitchen",
+	}
+	if lines := ex.ForwardLines(); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Expected %q, got %q", expected, lines)
+	}
+
+	// Counters are forwarded as their increase since the last time.
+	events <- event.Events{&event.CounterEvent{CMetricName: "requests.a", CValue: 4, CLabels: map[string]string{}}}
+	expected = []string{
+		"requests_total:4|c|#host:a",
+		"requests_total:0|c|#host:b",
+		"temperature:0|g|#room:freezer",
+		"temperature:-18|g|#room:freezer",
+		"temperature:21.5|g|#room:kitchen",
+	}
+	if lines := ex.ForwardLines(); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestUnmappedDefaults(t *testing.T) {
+	config := `
+defaults:
+  ttl: 1m
+  unmapped_observer_type: histogram
+  unmapped_ttl: 5m
+mappings:
+- match: mapped.*
+  name: mapped_seconds
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	go ex.Listen(context.Background(), events)
+	events <- event.Events{
+		&event.ObserverEvent{OMetricName: "mapped.a", OValue: 0.1, OLabels: map[string]string{}},
+		&event.ObserverEvent{OMetricName: "unmapped", OValue: 0.1, OLabels: map[string]string{}},
+	}
+	close(events)
+	<-ex.stopped
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	types := map[string]dto.MetricType{}
+	for _, mf := range metrics {
+		types[mf.GetName()] = mf.GetType()
+	}
+	if types["mapped_seconds"] != dto.MetricType_SUMMARY {
+		t.Errorf("Expected the mapped timer to stay a summary, got %v", types["mapped_seconds"])
+	}
+	if types["unmapped"] != dto.MetricType_HISTOGRAM {
+		t.Errorf("Expected the unmapped timer to become a histogram, got %v", types["unmapped"])
+	}
+
+	r := ex.Registry.(*registry.Registry)
+	for name, ttl := range map[string]time.Duration{"mapped_seconds": time.Minute, "unmapped": 5 * time.Minute} {
+		for _, rm := range r.Metrics[name].Metrics {
+			if rm.TTL != ttl {
+				t.Errorf("Expected a TTL of %v for %s, got %v", ttl, name, rm.TTL)
+			}
+		}
+	}
+}
+
+func TestEscapeConfiguration(t *testing.T) {
+	config := `
+defaults:
+  escape_char: ":"
+  escape_disable_dash_collapsing: true
+mappings:
+- match: mapped.*
+  name: mapped_${1}
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	go ex.Listen(context.Background(), events)
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "web.api--v2.hits", CValue: 1, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "mapped.a-b", CValue: 1, CLabels: map[string]string{}},
+	}
+	close(events)
+	<-ex.stopped
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	names := map[string]bool{}
+	for _, mf := range metrics {
+		names[mf.GetName()] = true
+	}
+	for _, name := range []string{"web:api::v2:hits", "mapped_a:b"} {
+		if !names[name] {
+			t.Errorf("Expected metric %s, got %v", name, names)
+		}
+	}
+}
+
 func TestCounterIncrement(t *testing.T) {
 	// Start exporter with a synchronous channel
 	events := make(chan event.Events)
 	go func() {
 		testMapper := mapper.MetricMapper{}
 		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-		ex.Listen(events)
+		ex.Listen(context.Background(), events)
 	}()
 
 	// Synchronously send a statsd event to wait for handleEvent execution.
@@ -910,7 +1449,7 @@ func TestGaugeIncrementDecrement(t *testing.T) {
 	go func() {
 		testMapper := mapper.MetricMapper{}
 		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-		ex.Listen(events)
+		ex.Listen(context.Background(), events)
 	}()
 
 	// Synchronously send a statsd event to wait for handleEvent execution.
@@ -955,36 +1494,197 @@ func TestGaugeIncrementDecrement(t *testing.T) {
 	}
 }
 
-func TestScaledMapping(t *testing.T) {
-	events := make(chan event.Events)
-	testMapper := mapper.MetricMapper{}
-	config := `mappings:
-- match: foo.processed_kilobytes
-  name: processed_bytes
-  scale: 1024
-  labels:
-    service: foo`
-	err := testMapper.InitFromYAMLString(config)
-	if err != nil {
+func TestGaugeLiteralNegative(t *testing.T) {
+	config := `
+mappings:
+- match: test.temperature
+  name: temperature
+  gauge_literal_negative: true
+- match: test.queue
+  name: queue
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
 		t.Fatalf("Config load error: %s %s", config, err)
 	}
 
-	// Start exporter with a synchronous channel
-	go func() {
-		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-		ex.Listen(events)
-	}()
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	for _, name := range []string{"test.temperature", "test.queue"} {
+		ex.handleEvent(&event.GaugeEvent{GMetricName: name, GValue: 10, GLabels: map[string]string{}})
+		ex.handleEvent(&event.GaugeEvent{GMetricName: name, GValue: -5, GRelative: true, GLabels: map[string]string{}})
+		ex.handleEvent(&event.GaugeEvent{GMetricName: name, GValue: 2, GRelative: true, GLabels: map[string]string{}})
+	}
 
-	// Synchronously send a statsd event to wait for handleEvent execution.
-	// Then close events channel to stop a listener.
-	statsdName := "foo.processed_kilobytes"
-	statsdLabels := map[string]string{}
-	promName := "processed_bytes"
-	promLabels := map[string]string{"service": "foo"}
-	c := event.Events{
-		&event.CounterEvent{
-			CMetricName: statsdName,
-			CValue:      100,
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	for name, expected := range map[string]float64{"temperature": -3, "queue": 7} {
+		if v := getFloat64(metrics, name, prometheus.Labels{}); v == nil || *v != expected {
+			t.Errorf("Expected %s to be %v, got %v", name, expected, v)
+		}
+	}
+}
+
+type sinkRecorder []MappedEvent
+
+func (r *sinkRecorder) Send(e MappedEvent) {
+	*r = append(*r, e)
+}
+
+func TestOutputs(t *testing.T) {
+	config := `
+mappings:
+- match: test.archived
+  name: archived
+  outputs: [kafka]
+- match: test.both
+  name: both
+  outputs: [prometheus, kafka]
+- match: test.default
+  name: default
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	sink := &sinkRecorder{}
+	ex.Sinks = map[mapper.Output]EventSink{mapper.OutputKafka: sink}
+	for _, name := range []string{"test.archived", "test.both", "test.default"} {
+		ex.handleEvent(&event.GaugeEvent{GMetricName: name, GValue: 3, GRelative: true, GLabels: map[string]string{"host": "a"}})
+	}
+
+	if len(*sink) != 2 {
+		t.Fatalf("Expected 2 events in the sink, got %d", len(*sink))
+	}
+	for i, name := range []string{"archived", "both"} {
+		e := (*sink)[i]
+		if e.Name != name || e.Value != 3 || !e.Relative || e.Labels["host"] != "a" || e.Match != "test."+name {
+			t.Errorf("Unexpected event in the sink: %+v", e)
+		}
+	}
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	labels := prometheus.Labels{"host": "a"}
+	if v := getFloat64(metrics, "archived", labels); v != nil {
+		t.Errorf("Expected archived to only go to the sink, got %v", *v)
+	}
+	for _, name := range []string{"both", "default"} {
+		if v := getFloat64(metrics, name, labels); v == nil || *v != 3 {
+			t.Errorf("Expected %s to be 3, got %v", name, v)
+		}
+	}
+}
+
+func TestEventsMapped(t *testing.T) {
+	config := `
+mappings:
+- match: test.*.requests
+  name: "${1}_requests_total"
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	eventsMapped := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_events_mapped_total",
+			Help: "The total number of StatsD events matched by each configured mapping.",
+		},
+		[]string{"mapping_name"},
+	)
+
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.EventsMapped = eventsMapped
+		ex.Listen(context.Background(), events)
+	}()
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "test.foo.requests", CValue: 1},
+		&event.CounterEvent{CMetricName: "test.bar.requests", CValue: 1},
+		&event.CounterEvent{CMetricName: "unmapped", CValue: 1},
+	}
+	events <- event.Events{}
+	close(events)
+
+	if n := testutil.CollectAndCount(eventsMapped); n != 1 {
+		t.Fatalf("Expected a single mapping_name series, got %d", n)
+	}
+	if v := testutil.ToFloat64(eventsMapped.WithLabelValues("${1}_requests_total")); v != 2 {
+		t.Fatalf("Expected 2 mapped events, got %f", v)
+	}
+}
+
+func TestExtraLabels(t *testing.T) {
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(""); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.ExtraLabels = prometheus.Labels{"tenant": "a"}
+		ex.Listen(context.Background(), events)
+	}()
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "foo", CValue: 1, CLabels: map[string]string{"tenant": "b", "bar": "baz"}},
+	}
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	labels := map[string]string{"tenant": "a", "bar": "baz"}
+	if value := getFloat64(metrics, "foo", labels); value == nil || *value != 1 {
+		t.Fatalf("Expected foo with labels %v to be 1, got %v", labels, value)
+	}
+}
+
+func TestScaledMapping(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: foo.processed_kilobytes
+  name: processed_bytes
+  scale: 1024
+  labels:
+    service: foo`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	// Start exporter with a synchronous channel
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	// Synchronously send a statsd event to wait for handleEvent execution.
+	// Then close events channel to stop a listener.
+	statsdName := "foo.processed_kilobytes"
+	statsdLabels := map[string]string{}
+	promName := "processed_bytes"
+	promLabels := map[string]string{"service": "foo"}
+	c := event.Events{
+		&event.CounterEvent{
+			CMetricName: statsdName,
+			CValue:      100,
 			CLabels:     statsdLabels,
 		},
 		&event.CounterEvent{
@@ -1012,6 +1712,55 @@ func TestScaledMapping(t *testing.T) {
 	}
 }
 
+func TestTimerUnit(t *testing.T) {
+	config := `defaults:
+  timer_unit: ms
+  observer_type: histogram
+mappings:
+- match: test.ms
+  name: latency_milliseconds
+- match: test.s
+  name: latency_seconds
+  timer_unit: s
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	events <- event.Events{
+		&event.ObserverEvent{OMetricName: "test.ms", OValue: 350, OTimer: true},
+		&event.ObserverEvent{OMetricName: "test.s", OValue: 350, OTimer: true},
+		&event.ObserverEvent{OMetricName: "test.unmapped", OValue: 350, OTimer: true},
+		// Histogram events are never converted.
+		&event.ObserverEvent{OMetricName: "test.s", OValue: 2},
+	}
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	for name, expected := range map[string]float64{
+		"latency_milliseconds": 350,
+		"latency_seconds":      0.35 + 2,
+		"test_unmapped":        350,
+	} {
+		value := getFloat64(metrics, name, prometheus.Labels{})
+		if value == nil || *value != expected {
+			t.Errorf("Expected sum of %s to be %v, got %v", name, expected, value)
+		}
+	}
+}
+
 type statsDPacketHandler interface {
 	HandlePacket(packet []byte)
 	SetEventHandler(eh event.EventHandler)
@@ -1053,6 +1802,89 @@ func (ml *mockStatsDTCPListener) HandlePacket(packet []byte) {
 	ml.HandleConn(sc)
 }
 
+func TestAllowedSources(t *testing.T) {
+	sources, err := listener.ParseAllowedSources("10.0.0.0/8, 192.168.1.7,::1/128")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for addr, allowed := range map[string]bool{
+		"10.1.2.3":        true,
+		"::ffff:10.1.2.3": true,
+		"192.168.1.7":     true,
+		"192.168.1.8":     false,
+		"::1":             true,
+		"127.0.0.1":       false,
+	} {
+		if got := sources.Allows(netip.MustParseAddr(addr)); got != allowed {
+			t.Errorf("Expected %s allowed to be %v, got %v", addr, allowed, got)
+		}
+	}
+	if all, _ := listener.ParseAllowedSources(""); !all.Allows(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("Expected an empty list to allow all sources")
+	}
+	for _, bad := range []string{"10.0.0.0/33", "foo", "10.0.0.1/8/8"} {
+		if _, err := listener.ParseAllowedSources(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+
+	// The tests connect from 127.0.0.1, which is not allowed.
+	events := make(chan event.Events, 1)
+	rejected := prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"})
+	tcp := &mockStatsDTCPListener{listener.StatsDTCPListener{
+		EventHandler:        &event.UnbufferedEventHandler{C: events},
+		Logger:              promslog.NewNopLogger(),
+		LineParser:          line.NewParser(),
+		TCPConnections:      tcpConnections,
+		AllowedSources:      sources,
+		RejectedConnections: rejected,
+	}, promslog.NewNopLogger()}
+	tcp.HandlePacket([]byte("foo:1|c\n"))
+	if got := testutil.ToFloat64(rejected); got != 1 {
+		t.Errorf("Expected 1 rejected TCP connection, got %v", got)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	defer conn.Close()
+	rejected = prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"})
+	udp := &listener.StatsDUDPListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UDPPackets:      udpPackets,
+		UdpPacketQueue:  make(chan listener.UDPPacket, 1),
+		AllowedSources:  sources,
+		RejectedPackets: rejected,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go udp.Listen(ctx)
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Cannot dial: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("foo:1|c\n")); err != nil {
+		t.Fatalf("Cannot send: %v", err)
+	}
+	for i := 0; testutil.ToFloat64(rejected) == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(rejected); got != 1 {
+		t.Errorf("Expected 1 rejected UDP packet, got %v", got)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("Expected no events, got %v", e)
+	default:
+	}
+}
+
 // TestTtlExpiration validates expiration of time series.
 // foobar metric without mapping should expire with default ttl of 1s
 // bazqux metric should expire with ttl of 2s
@@ -1081,7 +1913,7 @@ mappings:
 	defer close(events)
 	go func() {
 		ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
-		ex.Listen(events)
+		ex.Listen(context.Background(), events)
 	}()
 
 	ev := event.Events{
@@ -1167,174 +1999,1857 @@ mappings:
 	}
 }
 
-func TestHashLabelNames(t *testing.T) {
-	r := registry.NewRegistry(prometheus.DefaultRegisterer, nil)
-	// Validate value hash changes and name has doesn't when just the value changes.
-	hash1, _ := r.HashLabels(map[string]string{
-		"label": "value1",
-	})
-	hash2, _ := r.HashLabels(map[string]string{
-		"label": "value2",
-	})
-	if hash1.Names != hash2.Names {
-		t.Fatal("Hash of label names should match, but doesn't")
-	}
-	if hash1.Values == hash2.Values {
-		t.Fatal("Hash of label names shouldn't match, but do")
+// TestTtlExpireOnNoChange validates that a time series with expire_on
+// no_change expires while it keeps receiving the same value, and that one
+// with the default receive based expiry does not.
+func TestTtlExpireOnNoChange(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
 	}
 
-	// Validate value and name hashes change when the name changes.
-	hash1, _ = r.HashLabels(map[string]string{
-		"label1": "value",
-	})
-	hash2, _ = r.HashLabels(map[string]string{
-		"label2": "value",
-	})
-	if hash1.Names == hash2.Names {
-		t.Fatal("Hash of label names shouldn't match, but do")
-	}
-	if hash1.Values == hash2.Values {
-		t.Fatal("Hash of label names shouldn't match, but do")
+	config := `
+mappings:
+- match: stale.*
+  name: stale
+  ttl: 2s
+  expire_on: no_change
+- match: live.*
+  name: live
+  ttl: 2s
+`
+	testMapper := &mapper.MetricMapper{}
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
 	}
-}
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
 
-// getFloat64 search for metric by name in array of MetricFamily and then search a value by labels.
-// Method returns a value or nil if metric is not found.
-func getFloat64(metrics []*dto.MetricFamily, name string, labels prometheus.Labels) *float64 {
-	var metricFamily *dto.MetricFamily
-	for _, m := range metrics {
-		if *m.Name == name {
-			metricFamily = m
-			break
-		}
-	}
-	if metricFamily == nil {
-		return nil
+	ev := event.Events{
+		&event.GaugeEvent{GMetricName: "stale.main", GValue: 5},
+		&event.GaugeEvent{GMetricName: "live.main", GValue: 5},
 	}
 
-	var metric *dto.Metric
-	labelStr := fmt.Sprintf("%v", labels)
-	for _, m := range metricFamily.Metric {
-		l := labelPairsAsLabels(m.GetLabel())
-		ls := fmt.Sprintf("%v", l)
-		if labelStr == ls {
-			metric = m
-			break
-		}
-	}
-	if metric == nil {
-		return nil
+	// Keep sending the same values while time passes.
+	for _, instant := range []time.Time{time.Unix(0, 0), time.Unix(1, 500), time.Unix(2, 500)} {
+		clock.ClockInstance.Instant = instant
+		events <- ev
+		clock.ClockInstance.TickerCh <- instant
+		events <- event.Events{}
 	}
 
-	var value float64
-	if metric.Gauge != nil {
-		value = metric.Gauge.GetValue()
-		return &value
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal("Gather should not fail")
 	}
-	if metric.Counter != nil {
-		value = metric.Counter.GetValue()
-		return &value
+	if getFloat64(metrics, "stale", prometheus.Labels{}) != nil {
+		t.Fatalf("Gauge `stale` should be expired")
 	}
-	if metric.Histogram != nil {
-		value = metric.Histogram.GetSampleSum()
-		return &value
+	if getFloat64(metrics, "live", prometheus.Labels{}) == nil {
+		t.Fatalf("Gauge `live` should be gathered")
 	}
-	if metric.Summary != nil {
-		value = metric.Summary.GetSampleSum()
-		return &value
+}
+
+// TestTtlExpireOnNoChangeBetweenSweeps validates that a change counts even if
+// the value is back to what it was at the previous sweep.
+func TestTtlExpireOnNoChangeBetweenSweeps(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
 	}
-	if metric.Untyped != nil {
-		value = metric.Untyped.GetValue()
-		return &value
+	defer func() { clock.ClockInstance = nil }()
+
+	config := `
+mappings:
+- match: flapping.*
+  name: flapping
+  ttl: 2s
+  expire_on: no_change
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
 	}
-	panic(fmt.Errorf("collected a non-gauge/counter/histogram/summary/untyped metric: %s", metric))
-}
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
 
-func labelPairsAsLabels(pairs []*dto.LabelPair) (labels prometheus.Labels) {
-	labels = prometheus.Labels{}
-	for _, pair := range pairs {
-		if pair.Name == nil {
-			continue
-		}
-		value := ""
-		if pair.Value != nil {
-			value = *pair.Value
+	steps := []struct {
+		instant time.Time
+		values  []float64
+	}{
+		{instant: time.Unix(0, 0), values: []float64{5}},
+		// Changed and back before the next sweep.
+		{instant: time.Unix(1, 500), values: []float64{6, 5}},
+		{instant: time.Unix(2, 500), values: []float64{5}},
+	}
+	for _, step := range steps {
+		clock.ClockInstance.Instant = step.instant
+		var ev event.Events
+		for _, v := range step.values {
+			ev = append(ev, &event.GaugeEvent{GMetricName: "flapping.main", GValue: v})
 		}
-		labels[*pair.Name] = value
+		events <- ev
+		clock.ClockInstance.TickerCh <- step.instant
+		events <- event.Events{}
 	}
-	return
-}
 
-func getTelemetryCounterValue(counter prometheus.Counter) float64 {
-	var metric dto.Metric
-	err := counter.Write(&metric)
+	metrics, err := reg.Gather()
 	if err != nil {
-		return 0.0
+		t.Fatal("Gather should not fail")
+	}
+	if getFloat64(metrics, "flapping", prometheus.Labels{}) == nil {
+		t.Fatalf("Gauge `flapping` changed less than the TTL ago and should be gathered")
+	}
+}
+
+// TestSchedule validates that events outside of the windows of a schedule
+// are dropped, and that the metrics of an expiring schedule are removed when
+// its window ends.
+func TestSchedule(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
+	}
+	defer func() { clock.ClockInstance = nil }()
+
+	config := `
+mappings:
+- match: batch.*
+  name: batch_$1
+  schedule:
+    expire: true
+    windows:
+    - start: "01:00"
+      end: "02:00"
+- match: report.*
+  name: report_$1
+  schedule:
+    windows:
+    - start: "01:00"
+      end: "02:00"
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	send := func(at time.Time, value float64) {
+		clock.ClockInstance.Instant = at
+		events <- event.Events{
+			&event.CounterEvent{CMetricName: "batch.rows", CValue: value, CLabels: map[string]string{}},
+			&event.CounterEvent{CMetricName: "report.pages", CValue: value, CLabels: map[string]string{}},
+		}
+		events <- event.Events{}
+	}
+	check := func(step string, batch, report *float64) {
+		t.Helper()
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		for name, expected := range map[string]*float64{"batch_rows": batch, "report_pages": report} {
+			v := getFloat64(metrics, name, prometheus.Labels{})
+			if (v == nil) != (expected == nil) || (v != nil && *v != *expected) {
+				t.Errorf("%s: expected %s %v, got %v", step, name, expected, v)
+			}
+		}
+	}
+	value := func(v float64) *float64 { return &v }
+
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	send(day.Add(30*time.Minute), 1)
+	check("before the window", nil, nil)
+
+	send(day.Add(90*time.Minute), 2)
+	check("in the window", value(2), value(2))
+
+	send(day.Add(150*time.Minute), 3)
+	check("after the window", value(2), value(2))
+
+	clock.ClockInstance.TickerCh <- day.Add(150 * time.Minute)
+	events <- event.Events{}
+	check("after the window is swept", nil, value(2))
+}
+
+func TestTypeConflicts(t *testing.T) {
+	for _, s := range []struct {
+		policy   string
+		expected map[string]dto.MetricType
+		outcomes map[string]float64
+	}{
+		{
+			policy: "first_wins",
+			expected: map[string]dto.MetricType{
+				"mixed_a":  dto.MetricType_GAUGE,
+				"strict_b": dto.MetricType_GAUGE,
+			},
+			outcomes: map[string]float64{"suppressed": 1, "enforced": 1, "replaced": 1},
+		},
+		{
+			policy: "type_priority",
+			expected: map[string]dto.MetricType{
+				"mixed_a":  dto.MetricType_COUNTER,
+				"strict_b": dto.MetricType_GAUGE,
+			},
+			outcomes: map[string]float64{"suppressed": 1, "enforced": 1, "replaced": 2},
+		},
+	} {
+		t.Run(s.policy, func(t *testing.T) {
+			config := `
+defaults:
+  type_conflicts: ` + s.policy + `
+mappings:
+- match: mixed.*
+  name: mixed_$1
+- match: strict.*
+  name: strict_$1
+  enforce_type: gauge
+`
+			testMapper := &mapper.MetricMapper{}
+			if err := testMapper.InitFromYAMLString(config); err != nil {
+				t.Fatalf("Config load error: %s", err)
+			}
+
+			resolved := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "resolved"}, []string{"outcome"})
+			conflicts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "conflicts"}, []string{"type", "metric_name"})
+			reg := prometheus.NewRegistry()
+			ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflicts, metricsCount)
+			ex.TypeConflictsResolved = resolved
+			// The strict metric is registered with another type before its
+			// mapping enforces the type, as after a configuration reload.
+			if _, err := ex.Registry.GetCounter("strict_b", prometheus.Labels{}, "", &mapper.MetricMapping{}, metricsCount); err != nil {
+				t.Fatal(err)
+			}
+
+			events := make(chan event.Events, 1)
+			events <- event.Events{
+				&event.GaugeEvent{GMetricName: "mixed.a", GValue: 5, GLabels: map[string]string{}},
+				&event.CounterEvent{CMetricName: "mixed.a", CValue: 2, CLabels: map[string]string{}},
+				&event.GaugeEvent{GMetricName: "mixed.a", GValue: 6, GLabels: map[string]string{}},
+				&event.GaugeEvent{GMetricName: "strict.b", GValue: 3, GLabels: map[string]string{}},
+				&event.CounterEvent{CMetricName: "strict.b", CValue: 1, CLabels: map[string]string{}},
+			}
+			close(events)
+			ex.Listen(context.Background(), events)
+
+			metrics, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Cannot gather from registry: %v", err)
+			}
+			for name, expected := range s.expected {
+				var found *dto.MetricFamily
+				for _, m := range metrics {
+					if m.GetName() == name && len(m.GetMetric()) > 0 {
+						found = m
+					}
+				}
+				if found == nil || found.GetType() != expected {
+					t.Errorf("expected %s to be a %s, got %v", name, expected, found)
+				}
+			}
+			for outcome, expected := range s.outcomes {
+				if v := testutil.ToFloat64(resolved.WithLabelValues(outcome)); v != expected {
+					t.Errorf("expected %v %s events, got %v", expected, outcome, v)
+				}
+			}
+			if n := testutil.CollectAndCount(conflicts); n != 0 {
+				t.Errorf("expected no conflicts, got %d", n)
+			}
+		})
+	}
+}
+
+func TestSweepOnScrape(t *testing.T) {
+	// The ticker never fires, only scrapes remove expired series.
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+
+	config := `
+defaults:
+  ttl: 1s
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Sweep = SweepScrape
+	gatherer := ex.SweepingGatherer(reg)
+
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	events <- event.Events{&event.GaugeEvent{GMetricName: "foo", GValue: 1}}
+	events <- event.Events{}
+
+	metrics, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if getFloat64(metrics, "foo", prometheus.Labels{}) == nil {
+		t.Fatalf("Gauge `foo` should be gathered before its TTL elapsed")
+	}
+
+	clock.ClockInstance.Instant = time.Unix(2, 0)
+	metrics, err = gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if getFloat64(metrics, "foo", prometheus.Labels{}) != nil {
+		t.Fatalf("Gauge `foo` should be expired at scrape time")
+	}
+}
+
+// TestSweepOnScrapeConcurrentEvents scrapes while events are being applied.
+// Run with -race to detect unsynchronized access to the registry.
+func TestSweepOnScrapeConcurrentEvents(t *testing.T) {
+	clock.ClockInstance = nil
+
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString("defaults:\n  ttl: 1h\n"); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Sweep = SweepBoth
+	gatherer := ex.SweepingGatherer(reg)
+
+	events := make(chan event.Events)
+	go ex.Listen(context.Background(), events)
+
+	const n = 1000
+	scrapes := make(chan struct{})
+	go func() {
+		defer close(scrapes)
+		for i := 0; i < 50; i++ {
+			if _, err := gatherer.Gather(); err != nil {
+				t.Errorf("Cannot gather from registry: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < n; i++ {
+		events <- event.Events{&event.CounterEvent{CMetricName: "foo", CValue: 1, CLabels: map[string]string{}}}
+	}
+	<-scrapes
+	events <- event.Events{}
+	close(events)
+
+	// Once Listen has stopped, sweeping must not block.
+	metrics, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if value := getFloat64(metrics, "foo", prometheus.Labels{}); value == nil || *value != n {
+		t.Fatalf("Expected counter `foo` to be %d, got %v", n, value)
+	}
+}
+
+func TestAggregatedGauges(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+
+	config := `
+mappings:
+- match: test.latency
+  name: latency
+  observer_type: aggregated_gauges
+  aggregation_window: 10s
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	events <- event.Events{
+		&event.ObserverEvent{OMetricName: "test.latency", OValue: 1},
+		&event.ObserverEvent{OMetricName: "test.latency", OValue: 5},
+		&event.ObserverEvent{OMetricName: "test.latency", OValue: 3},
+	}
+	events <- event.Events{}
+
+	scenarios := []struct {
+		instant  time.Time
+		expected map[string]float64
+	}{
+		{
+			// The first window is not complete yet.
+			instant:  time.Unix(5, 0),
+			expected: map[string]float64{"latency_count": 0},
+		},
+		{
+			instant:  time.Unix(12, 0),
+			expected: map[string]float64{"latency_min": 1, "latency_max": 5, "latency_avg": 3, "latency_count": 3},
+		},
+		{
+			// The second window was empty.
+			instant:  time.Unix(25, 0),
+			expected: map[string]float64{"latency_count": 0},
+		},
+	}
+
+	for _, s := range scenarios {
+		clock.ClockInstance.Instant = s.instant
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		for _, name := range []string{"latency_min", "latency_max", "latency_avg", "latency_count"} {
+			value := getFloat64(metrics, name, prometheus.Labels{})
+			expected, ok := s.expected[name]
+			if !ok {
+				if value != nil {
+					t.Fatalf("At %v, expected no %s, got %v", s.instant, name, *value)
+				}
+				continue
+			}
+			if value == nil || *value != expected {
+				t.Fatalf("At %v, expected %s to be %v, got %v", s.instant, name, expected, value)
+			}
+		}
+	}
+}
+
+func TestAggregatedGaugesAggregates(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+
+	config := `
+mappings:
+- match: test.latency
+  name: latency
+  observer_type: aggregated_gauges
+  aggregation_window: 10s
+  aggregates: [max, median, avg, count, 95percentile, sum]
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(context.Background(), events)
+	}()
+
+	// Observe 1 to 20 out of order.
+	var evs event.Events
+	for i := 20; i > 0; i-- {
+		evs = append(evs, &event.ObserverEvent{OMetricName: "test.latency", OValue: float64(i)})
+	}
+	events <- evs
+	events <- event.Events{}
+
+	clock.ClockInstance.Instant = time.Unix(12, 0)
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	expected := map[string]float64{
+		"latency_max":          20,
+		"latency_median":       10,
+		"latency_avg":          10.5,
+		"latency_count":        20,
+		"latency_95percentile": 19,
+		"latency_sum":          210,
+	}
+	for name, e := range expected {
+		value := getFloat64(metrics, name, prometheus.Labels{})
+		if value == nil || *value != e {
+			t.Fatalf("Expected %s to be %v, got %v", name, e, value)
+		}
+	}
+	if value := getFloat64(metrics, "latency_min", prometheus.Labels{}); value != nil {
+		t.Fatalf("Expected no latency_min, got %v", *value)
+	}
+}
+
+func TestGaugeHistogram(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+
+	config := `
+mappings:
+- match: test.queue_depth
+  name: queue_depth
+  observer_type: gaugehistogram
+  aggregation_window: 10s
+  histogram_options:
+    buckets: [1, 10]
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	gatherer := ex.GaugeHistogramGatherer(reg)
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	events <- event.Events{
+		&event.ObserverEvent{OMetricName: "test.queue_depth", OValue: 1},
+		&event.ObserverEvent{OMetricName: "test.queue_depth", OValue: 5},
+		&event.ObserverEvent{OMetricName: "test.queue_depth", OValue: 30},
+	}
+	events <- event.Events{}
+
+	scenarios := []struct {
+		instant time.Time
+		count   uint64
+		sum     float64
+		buckets []uint64
+	}{
+		{
+			// The first window is not complete yet.
+			instant: time.Unix(5, 0),
+			buckets: []uint64{0, 0},
+		},
+		{
+			instant: time.Unix(12, 0),
+			count:   3,
+			sum:     36,
+			buckets: []uint64{1, 2},
+		},
+		{
+			// The second window was empty, so the buckets go down again.
+			instant: time.Unix(25, 0),
+			buckets: []uint64{0, 0},
+		},
+	}
+
+	for _, s := range scenarios {
+		clock.ClockInstance.Instant = s.instant
+		metrics, err := gatherer.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		if len(metrics) != 1 || metrics[0].GetType() != dto.MetricType_GAUGE_HISTOGRAM {
+			t.Fatalf("At %v, expected one gauge histogram family, got %v", s.instant, metrics)
+		}
+		h := metrics[0].GetMetric()[0].GetHistogram()
+		if h.GetSampleCount() != s.count || h.GetSampleSum() != s.sum {
+			t.Fatalf("At %v, expected count %d and sum %v, got %d and %v", s.instant, s.count, s.sum, h.GetSampleCount(), h.GetSampleSum())
+		}
+		if len(h.GetBucket()) != len(s.buckets) {
+			t.Fatalf("At %v, expected %d buckets, got %d", s.instant, len(s.buckets), len(h.GetBucket()))
+		}
+		for i, b := range h.GetBucket() {
+			if b.GetCumulativeCount() != s.buckets[i] {
+				t.Fatalf("At %v, expected bucket %v to be %d, got %d", s.instant, b.GetUpperBound(), s.buckets[i], b.GetCumulativeCount())
+			}
+		}
+	}
+}
+
+func TestSumAndCount(t *testing.T) {
+	config := `
+mappings:
+- match: test.latency
+  name: latency_seconds
+  observer_type: sum_and_count
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			&event.ObserverEvent{OMetricName: "test.latency", OValue: 1},
+			&event.ObserverEvent{OMetricName: "test.latency", OValue: 3},
+		}
+		close(events)
+	}()
+	ex.Listen(context.Background(), events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].GetType() != dto.MetricType_SUMMARY {
+		t.Fatalf("Expected one summary family, got %v", metrics)
+	}
+	s := metrics[0].GetMetric()[0].GetSummary()
+	if s.GetSampleCount() != 2 || s.GetSampleSum() != 4 {
+		t.Errorf("Expected count 2 and sum 4, got %d and %v", s.GetSampleCount(), s.GetSampleSum())
+	}
+	if len(s.GetQuantile()) != 0 {
+		t.Errorf("Expected no quantiles, got %v", s.GetQuantile())
+	}
+}
+
+func TestMemoryGuard(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+	var liveHeap, gcCycles uint64
+	defer func(f func() (uint64, uint64)) { readHeapStats = f }(readHeapStats)
+	readHeapStats = func() (uint64, uint64) { return liveHeap, gcCycles }
+
+	evictions := prometheus.NewCounter(prometheus.CounterOpts{Name: "evictions"})
+	evictedSeries := prometheus.NewCounter(prometheus.CounterOpts{Name: "evicted_series"})
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	// Only the memory guard uses the ticker.
+	ex.Sweep = SweepScrape
+	ex.MemoryGuard = &MemoryGuard{
+		MaxHeap:       1000,
+		EvictFraction: 0.5,
+		Protected:     func(name string) bool { return name == "protected" },
+		Evictions:     evictions,
+		EvictedSeries: evictedSeries,
+	}
+
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	for i, name := range []string{"protected", "old", "older", "newest"} {
+		clock.ClockInstance.Instant = time.Unix(int64(i), 0)
+		events <- event.Events{&event.GaugeEvent{GMetricName: name, GValue: 1}}
+		events <- event.Events{}
+	}
+	// Refresh "older" so that it is the most recently updated series.
+	clock.ClockInstance.Instant = time.Unix(4, 0)
+	events <- event.Events{&event.GaugeEvent{GMetricName: "older", GValue: 2}}
+	events <- event.Events{}
+
+	check := func() {
+		clock.ClockInstance.TickerCh <- clock.Now()
+		// Wait for the check to complete.
+		events <- event.Events{}
+	}
+	expectSeries := func(want []string) {
+		t.Helper()
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		var got []string
+		for _, mf := range metrics {
+			got = append(got, mf.GetName())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("Expected series %v, got %v", want, got)
+		}
+	}
+
+	liveHeap, gcCycles = 1000, 1
+	check()
+	expectSeries([]string{"newest", "old", "older", "protected"})
+
+	// Half of the three unprotected series, rounded up, are evicted.
+	liveHeap = 2000
+	check()
+	expectSeries([]string{"older", "protected"})
+
+	// Nothing more is evicted until garbage has been collected.
+	check()
+	expectSeries([]string{"older", "protected"})
+
+	gcCycles = 2
+	check()
+	expectSeries([]string{"protected"})
+
+	gcCycles = 3
+	check()
+	expectSeries([]string{"protected"})
+
+	if got := testutil.ToFloat64(evictions); got != 2 {
+		t.Errorf("Expected 2 evictions, got %v", got)
+	}
+	if got := testutil.ToFloat64(evictedSeries); got != 3 {
+		t.Errorf("Expected 3 evicted series, got %v", got)
+	}
+}
+
+func TestHashLabelNames(t *testing.T) {
+	r := registry.NewRegistry(prometheus.DefaultRegisterer, nil)
+	// Validate value hash changes and name has doesn't when just the value changes.
+	hash1, _ := r.HashLabels(map[string]string{
+		"label": "value1",
+	})
+	hash2, _ := r.HashLabels(map[string]string{
+		"label": "value2",
+	})
+	if hash1.Names != hash2.Names {
+		t.Fatal("Hash of label names should match, but doesn't")
+	}
+	if hash1.Values == hash2.Values {
+		t.Fatal("Hash of label names shouldn't match, but do")
+	}
+
+	// Validate value and name hashes change when the name changes.
+	hash1, _ = r.HashLabels(map[string]string{
+		"label1": "value",
+	})
+	hash2, _ = r.HashLabels(map[string]string{
+		"label2": "value",
+	})
+	if hash1.Names == hash2.Names {
+		t.Fatal("Hash of label names shouldn't match, but do")
+	}
+	if hash1.Values == hash2.Values {
+		t.Fatal("Hash of label names shouldn't match, but do")
+	}
+}
+
+// getFloat64 search for metric by name in array of MetricFamily and then search a value by labels.
+// Method returns a value or nil if metric is not found.
+func getFloat64(metrics []*dto.MetricFamily, name string, labels prometheus.Labels) *float64 {
+	var metricFamily *dto.MetricFamily
+	for _, m := range metrics {
+		if *m.Name == name {
+			metricFamily = m
+			break
+		}
+	}
+	if metricFamily == nil {
+		return nil
+	}
+
+	var metric *dto.Metric
+	labelStr := fmt.Sprintf("%v", labels)
+	for _, m := range metricFamily.Metric {
+		l := labelPairsAsLabels(m.GetLabel())
+		ls := fmt.Sprintf("%v", l)
+		if labelStr == ls {
+			metric = m
+			break
+		}
+	}
+	if metric == nil {
+		return nil
+	}
+
+	var value float64
+	if metric.Gauge != nil {
+		value = metric.Gauge.GetValue()
+		return &value
+	}
+	if metric.Counter != nil {
+		value = metric.Counter.GetValue()
+		return &value
+	}
+	if metric.Histogram != nil {
+		value = metric.Histogram.GetSampleSum()
+		return &value
+	}
+	if metric.Summary != nil {
+		value = metric.Summary.GetSampleSum()
+		return &value
+	}
+	if metric.Untyped != nil {
+		value = metric.Untyped.GetValue()
+		return &value
+	}
+	panic(fmt.Errorf("collected a non-gauge/counter/histogram/summary/untyped metric: %s", metric))
+}
+
+func labelPairsAsLabels(pairs []*dto.LabelPair) (labels prometheus.Labels) {
+	labels = prometheus.Labels{}
+	for _, pair := range pairs {
+		if pair.Name == nil {
+			continue
+		}
+		value := ""
+		if pair.Value != nil {
+			value = *pair.Value
+		}
+		labels[*pair.Name] = value
+	}
+	return
+}
+
+func getTelemetryCounterValue(counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	err := counter.Write(&metric)
+	if err != nil {
+		return 0.0
 	}
 	return metric.Counter.GetValue()
 }
 
-func BenchmarkParseDogStatsDTags(b *testing.B) {
-	scenarios := map[string]string{
-		"1 tag w/hash":         "#test:tag",
-		"1 tag w/o hash":       "test:tag",
-		"2 tags, mixed hashes": "tag1:test,#tag2:test",
-		"3 long tags":          "tag1:reallylongtagthisisreallylong,tag2:anotherreallylongtag,tag3:thisisyetanotherextraordinarilylongtag",
-		"a-z tags":             "a:0,b:1,c:2,d:3,e:4,f:5,g:6,h:7,i:8,j:9,k:0,l:1,m:2,n:3,o:4,p:5,q:6,r:7,s:8,t:9,u:0,v:1,w:2,x:3,y:4,z:5",
+func BenchmarkParseDogStatsDTags(b *testing.B) {
+	scenarios := map[string]string{
+		"1 tag w/hash":         "#test:tag",
+		"1 tag w/o hash":       "test:tag",
+		"2 tags, mixed hashes": "tag1:test,#tag2:test",
+		"3 long tags":          "tag1:reallylongtagthisisreallylong,tag2:anotherreallylongtag,tag3:thisisyetanotherextraordinarilylongtag",
+		"a-z tags":             "a:0,b:1,c:2,d:3,e:4,f:5,g:6,h:7,i:8,j:9,k:0,l:1,m:2,n:3,o:4,p:5,q:6,r:7,s:8,t:9,u:0,v:1,w:2,x:3,y:4,z:5",
+	}
+
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+
+	b.ResetTimer()
+
+	for name, tags := range scenarios {
+		b.Run(name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				labels := map[string]string{}
+				parser.ParseDogStatsDTags(tags, labels, tagErrors, promslog.NewNopLogger())
+			}
+		})
+	}
+}
+
+func BenchmarkHashNameAndLabels(b *testing.B) {
+	scenarios := []struct {
+		name   string
+		metric string
+		labels map[string]string
+	}{
+		{
+			name:   "no labels",
+			labels: map[string]string{},
+		}, {
+			name: "one label",
+			labels: map[string]string{
+				"label": "value",
+			},
+		}, {
+			name: "many labels",
+			labels: map[string]string{
+				"label0": "value",
+				"label1": "value",
+				"label2": "value",
+				"label3": "value",
+				"label4": "value",
+				"label5": "value",
+				"label6": "value",
+				"label7": "value",
+				"label8": "value",
+				"label9": "value",
+			},
+		},
+	}
+
+	r := registry.NewRegistry(prometheus.DefaultRegisterer, nil)
+	for _, s := range scenarios {
+		b.Run(s.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				r.HashLabels(s.labels)
+			}
+		})
+	}
+}
+
+func TestAdditionalObservers(t *testing.T) {
+	config := `
+mappings:
+- match: test.request_duration
+  name: request_duration_seconds
+  observer_type: histogram
+  histogram_options:
+    buckets: [0.1, 1]
+  additional_observers:
+  - observer_type: summary
+    name_suffix: _summary
+    summary_options:
+      quantiles:
+      - quantile: 0.5
+        error: 0.05
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	events <- event.Events{
+		&event.ObserverEvent{OMetricName: "test.request_duration", OValue: 0.05},
+		&event.ObserverEvent{OMetricName: "test.request_duration", OValue: 0.5},
+	}
+	events <- event.Events{}
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("Expected a histogram and a summary, got %v", metrics)
+	}
+	for _, mf := range metrics {
+		switch mf.GetName() {
+		case "request_duration_seconds":
+			h := mf.GetMetric()[0].GetHistogram()
+			if h.GetSampleCount() != 2 || h.GetBucket()[0].GetCumulativeCount() != 1 {
+				t.Fatalf("Unexpected histogram %v", h)
+			}
+		case "request_duration_seconds_summary":
+			s := mf.GetMetric()[0].GetSummary()
+			if s.GetSampleCount() != 2 || len(s.GetQuantile()) != 1 {
+				t.Fatalf("Unexpected summary %v", s)
+			}
+		default:
+			t.Fatalf("Unexpected metric %s", mf.GetName())
+		}
+	}
+}
+
+func TestExpiryNotifications(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+
+	config := `
+mappings:
+- match: test.workers.*
+  name: workers
+  ttl: 10s
+  labels:
+    pool: $1
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	var expired []registry.ExpiredSeries
+	ex.Registry.(*registry.Registry).OnExpire = func(s registry.ExpiredSeries) {
+		expired = append(expired, s)
+	}
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	events <- event.Events{
+		&event.GaugeEvent{GMetricName: "test.workers.a", GValue: 1, GLabels: map[string]string{}},
+		// Unmapped metrics do not expire without a default TTL.
+		&event.GaugeEvent{GMetricName: "unmapped", GValue: 1, GLabels: map[string]string{}},
+	}
+	events <- event.Events{}
+	clock.ClockInstance.Instant = time.Unix(5, 0)
+	events <- event.Events{&event.GaugeEvent{GMetricName: "test.workers.b", GValue: 1, GLabels: map[string]string{}}}
+	events <- event.Events{}
+
+	sweep := func(instant time.Time) {
+		clock.ClockInstance.Instant = instant
+		clock.ClockInstance.TickerCh <- instant
+		// Wait for the sweep to complete.
+		events <- event.Events{}
+	}
+
+	sweep(time.Unix(9, 0))
+	if len(expired) != 0 {
+		t.Fatalf("Expected no series to expire yet, got %v", expired)
+	}
+
+	sweep(time.Unix(11, 0))
+	if len(expired) != 1 {
+		t.Fatalf("Expected one expired series, got %v", expired)
+	}
+	s := expired[0]
+	if s.MetricName != "workers" || s.Labels["pool"] != "a" || s.Mapping != "workers" || s.MetricType != metrics.GaugeMetricType || !s.LastActive.Equal(time.Unix(0, 0)) {
+		t.Fatalf("Unexpected expired series %+v", s)
+	}
+
+	sweep(time.Unix(16, 0))
+	if len(expired) != 2 || expired[1].Labels["pool"] != "b" {
+		t.Fatalf("Expected the second series to expire, got %v", expired)
+	}
+}
+
+// TestNaNObservation checks that NaN observations are dropped rather than
+// making the sum of the histogram NaN.
+func TestNaNObservation(t *testing.T) {
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			&event.ObserverEvent{OMetricName: "nan_histogram", OValue: 1, OLabels: map[string]string{}},
+			&event.ObserverEvent{OMetricName: "nan_histogram", OValue: math.NaN(), OLabels: map[string]string{}},
+		}
+		close(events)
+	}()
+
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString("defaults:\n  observer_type: histogram\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	errorCounter := errorEventStats.WithLabelValues("nan_observation")
+	prev := getTelemetryCounterValue(errorCounter)
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(context.Background(), events)
+
+	if updated := getTelemetryCounterValue(errorCounter); updated-prev != 1 {
+		t.Fatal("NaN observation not counted")
+	}
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range metrics {
+		if m.GetName() != "nan_histogram" {
+			continue
+		}
+		h := m.GetMetric()[0].GetHistogram()
+		if h.GetSampleCount() != 1 || h.GetSampleSum() != 1 {
+			t.Fatalf("Expected only the finite observation, got count %d and sum %v", h.GetSampleCount(), h.GetSampleSum())
+		}
+		return
+	}
+	t.Fatal("Histogram nan_histogram not found")
+}
+
+func TestMetadata(t *testing.T) {
+	config := `
+mappings:
+- match: test.dispatcher.*.*
+  name: dispatcher_events_total
+  help: Events handled by the dispatcher.
+  labels:
+    processor: $1
+    action: $2
+- match: test\.latency\.(\w+)
+  match_type: regex
+  name: latency
+  observer_type: aggregated_gauges
+  labels:
+    route: $1
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "test.dispatcher.foo.send", CValue: 1, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "test.dispatcher.bar.receive", CValue: 1, CLabels: map[string]string{"region": "eu"}},
+		&event.ObserverEvent{OMetricName: "test.latency.home", OValue: 1, OLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "unmapped", GValue: 1, GLabels: map[string]string{}},
+	}
+
+	rec := httptest.NewRecorder()
+	ex.MetadataHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil))
+	var resp struct {
+		Status string                    `json:"status"`
+		Data   []registry.MetricMetadata `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := []registry.MappingOrigin{{Match: "test.dispatcher.*.*", Name: "dispatcher_events_total"}}
+	latency := []registry.MappingOrigin{{Match: `test\.latency\.(\w+)`, Name: "latency"}}
+	expected := []registry.MetricMetadata{
+		{MetricName: "dispatcher_events_total", Type: "counter", Help: "Events handled by the dispatcher.", Labels: []string{"action", "processor", "region"}, Mappings: dispatcher},
+		{MetricName: "latency_avg", Type: "gauge", Help: "Metric autogenerated by statsd_exporter.", Labels: []string{"route"}, Mappings: latency},
+		{MetricName: "latency_count", Type: "gauge", Help: "Metric autogenerated by statsd_exporter.", Labels: []string{"route"}, Mappings: latency},
+		{MetricName: "latency_max", Type: "gauge", Help: "Metric autogenerated by statsd_exporter.", Labels: []string{"route"}, Mappings: latency},
+		{MetricName: "latency_min", Type: "gauge", Help: "Metric autogenerated by statsd_exporter.", Labels: []string{"route"}, Mappings: latency},
+		{MetricName: "unmapped", Type: "gauge", Help: "Metric autogenerated by statsd_exporter.", Labels: []string{}, Mappings: []registry.MappingOrigin{}},
+	}
+	if resp.Status != "success" || !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, resp)
+	}
+}
+
+// TestMemoryUsage validates that the memory usage estimates add up by metric,
+// mapping and type, grow with labels and buckets, and shrink when series
+// expire.
+func TestMemoryUsage(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	config := `
+mappings:
+- match: test.requests.*
+  name: requests_total
+  labels:
+    route: $1
+- match: test.latency
+  name: latency_seconds
+  observer_type: histogram
+  histogram_options:
+    buckets: [0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10]
+- match: test.temporary
+  name: temporary
+  ttl: 1s
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "test.requests.home", CValue: 1, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "test.requests.search", CValue: 1, CLabels: map[string]string{}},
+		&event.ObserverEvent{OMetricName: "test.latency", OValue: 0.1, OLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "test.temporary", GValue: 1, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "unmapped", GValue: 1, GLabels: map[string]string{}},
+	}
+
+	rec := httptest.NewRecorder()
+	ex.MemoryUsageHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/memory", nil))
+	var resp struct {
+		Status string                `json:"status"`
+		Data   registry.MemoryReport `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	report := resp.Data
+	if resp.Status != "success" || report.Total.Series != 5 {
+		t.Fatalf("Expected 5 series, got %+v", resp)
+	}
+
+	usage := map[string]registry.MemoryUsage{}
+	metricsTotal, typesTotal, mappingsTotal := 0, 0, 0
+	for _, m := range report.Metrics {
+		usage[m.MetricName] = m.MemoryUsage
+		metricsTotal += m.Bytes
+	}
+	for _, bytes := range report.Types {
+		typesTotal += bytes
+	}
+	for _, m := range report.Mappings {
+		mappingsTotal += m.Bytes
+	}
+	if metricsTotal != report.Total.Bytes || typesTotal != report.Total.Bytes || mappingsTotal != report.Total.Bytes {
+		t.Fatalf("Expected usage to add up to %d, got %d by metric, %d by type and %d by mapping", report.Total.Bytes, metricsTotal, typesTotal, mappingsTotal)
+	}
+	if report.Metrics[0].MetricName != "requests_total" {
+		t.Errorf("Expected the metric with the most series first, got %+v", report.Metrics)
+	}
+	if usage["latency_seconds"].Bytes <= usage["requests_total"].Bytes/2 {
+		t.Errorf("Expected a histogram series to use more than a counter series, got %+v", usage)
+	}
+	if usage["requests_total"].Series != 2 || usage["requests_total"].Bytes <= usage["unmapped"].Bytes {
+		t.Errorf("Expected two labelled series to use more than one unlabelled, got %+v", usage)
+	}
+	if usage["unmapped"].Bytes != usage["temporary"].Bytes {
+		t.Errorf("Expected unlabelled gauges to use the same memory, got %+v", usage)
+	}
+
+	clock.ClockInstance.Instant = time.Unix(2, 0)
+	ex.SweepStale()
+	after := ex.MemoryUsage()
+	if after.Total.Series != 4 || after.Total.Bytes != report.Total.Bytes-usage["temporary"].Bytes {
+		t.Errorf("Expected the expired series to be subtracted, got %+v", after.Total)
+	}
+}
+
+func TestLabelSchema(t *testing.T) {
+	config := `
+mappings:
+- match: test.normalized.*
+  name: normalized_total
+  labels:
+    service: $1
+  label_schema:
+    labels: [service, region]
+- match: test.rejected.*
+  name: rejected_total
+  labels:
+    service: $1
+  label_schema:
+    labels: [service, region]
+    on_mismatch: reject
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	mismatches := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "mismatches"}, []string{"mapping_name", "action"})
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.LabelSchemaMismatches = mismatches
+
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			&event.CounterEvent{CMetricName: "test.normalized.api", CValue: 1, CLabels: map[string]string{"region": "eu"}},
+			&event.CounterEvent{CMetricName: "test.normalized.api", CValue: 1, CLabels: map[string]string{"host": "web01"}},
+			&event.CounterEvent{CMetricName: "test.rejected.api", CValue: 1, CLabels: map[string]string{"region": "eu"}},
+			&event.CounterEvent{CMetricName: "test.rejected.api", CValue: 1, CLabels: map[string]string{"host": "web01"}},
+		}
+		close(events)
+	}()
+	ex.Listen(context.Background(), events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, labels := range []prometheus.Labels{
+		{"service": "api", "region": "eu"},
+		{"service": "api", "region": ""},
+	} {
+		if v := getFloat64(metrics, "normalized_total", labels); v == nil || *v != 1 {
+			t.Errorf("Expected normalized_total%v to be 1, got %v", labels, v)
+		}
+	}
+	if v := getFloat64(metrics, "rejected_total", prometheus.Labels{"service": "api", "region": "eu"}); v == nil || *v != 1 {
+		t.Errorf("Expected the conforming rejected_total event to be recorded, got %v", v)
+	}
+	if v := getFloat64(metrics, "rejected_total", prometheus.Labels{"service": "api", "host": "web01"}); v != nil {
+		t.Errorf("Expected the mismatching rejected_total event to be dropped, got %v", *v)
+	}
+
+	if v := testutil.ToFloat64(mismatches.WithLabelValues("normalized_total", "normalized")); v != 1 {
+		t.Errorf("Expected 1 normalized event, got %v", v)
+	}
+	if v := testutil.ToFloat64(mismatches.WithLabelValues("rejected_total", "rejected")); v != 1 {
+		t.Errorf("Expected 1 rejected event, got %v", v)
+	}
+}
+
+func TestAutoBuckets(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
 	}
 
-	parser := line.NewParser()
-	parser.EnableDogstatsdParsing()
+	config := `
+mappings:
+- match: test.latency
+  name: latency
+  observer_type: histogram
+  histogram_options:
+    auto_buckets:
+      warmup: 1m
+      count: 4
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
 
-	b.ResetTimer()
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	defer close(events)
+	go ex.Listen(context.Background(), events)
 
-	for name, tags := range scenarios {
-		b.Run(name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				labels := map[string]string{}
-				parser.ParseDogStatsDTags(tags, labels, tagErrors, promslog.NewNopLogger())
+	var warmup event.Events
+	for i := 1; i <= 100; i++ {
+		warmup = append(warmup, &event.ObserverEvent{OMetricName: "test.latency", OValue: float64(i)})
+	}
+	warmup = append(warmup, &event.ObserverEvent{OMetricName: "test.latency", OValue: math.Inf(1)})
+	events <- warmup
+	events <- event.Events{}
+
+	histogram := func() *dto.Histogram {
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		for _, m := range metrics {
+			if m.GetName() == "latency" {
+				return m.Metric[0].GetHistogram()
 			}
-		})
+		}
+		t.Fatalf("latency not found")
+		return nil
+	}
+	if h := histogram(); h.GetSampleCount() != 101 || len(h.Bucket) != len(prometheus.DefBuckets) {
+		t.Fatalf("Expected 101 observations in the default buckets during the warmup, got %v", h)
+	}
+
+	learned := ex.LearnedBuckets()
+	if len(learned) != 1 || learned[0].State != "learning" || learned[0].Observations != 100 {
+		t.Fatalf("Expected the histogram to be learning from 100 observations, got %+v", learned)
+	}
+
+	clock.ClockInstance.Instant = time.Unix(60, 0)
+	events <- event.Events{&event.ObserverEvent{OMetricName: "test.latency", OValue: 42}}
+	events <- event.Events{}
+
+	expected := LearnedBuckets{
+		MetricName:   "latency",
+		Match:        "test.latency",
+		Name:         "latency",
+		State:        "tuned",
+		Observations: 101,
+		Buckets:      []float64{26, 50, 75, 99},
+	}
+	if learned := ex.LearnedBuckets(); len(learned) != 1 || !reflect.DeepEqual(learned[0], expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, learned)
+	}
+
+	// The histogram is created anew with the learned buckets.
+	h := histogram()
+	if h.GetSampleCount() != 1 || h.GetSampleSum() != 42 {
+		t.Fatalf("Expected only the observation after the warmup, got %v", h)
+	}
+	var bounds []float64
+	for _, b := range h.Bucket {
+		bounds = append(bounds, b.GetUpperBound())
+	}
+	if !reflect.DeepEqual(bounds, expected.Buckets) {
+		t.Fatalf("Expected buckets %v, got %v", expected.Buckets, bounds)
 	}
 }
 
-func BenchmarkHashNameAndLabels(b *testing.B) {
+func TestRoundUpSignificant(t *testing.T) {
+	for v, expected := range map[float64]float64{
+		0:      0,
+		0.3:    0.3,
+		0.1234: 0.13,
+		99:     99,
+		99.1:   100,
+		123:    130,
+		-123:   -120,
+		4e-7:   4e-7,
+	} {
+		if rounded := roundUpSignificant(v, 2); rounded != expected {
+			t.Errorf("Expected %v to round up to %v, got %v", v, expected, rounded)
+		}
+	}
+}
+
+func TestGaugeCoalescing(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+
+	reg := prometheus.NewRegistry()
+	coalesced := prometheus.NewCounter(prometheus.CounterOpts{Name: "coalesced"})
+	ex := NewExporter(reg, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.GaugeCoalesceWindow = time.Second
+	ex.CoalescedGaugeUpdates = coalesced
+	// Only the coalescing window uses the ticker.
+	ex.Sweep = SweepScrape
+
+	events := make(chan event.Events)
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(context.Background(), events)
+		close(done)
+	}()
+
+	events <- event.Events{
+		&event.GaugeEvent{GMetricName: "absolute", GValue: 1, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "absolute", GValue: 5, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "absolute", GValue: 2, GRelative: true, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: "relative", GValue: -3, GRelative: true, GLabels: map[string]string{"a": "b"}},
+		&event.GaugeEvent{GMetricName: "relative", GValue: -1, GRelative: true, GLabels: map[string]string{"a": "b"}},
+		&event.GaugeEvent{GMetricName: "relative", GValue: 10, GLabels: map[string]string{"a": "c"}},
+	}
+	events <- event.Events{}
+
+	check := func(expected map[string]map[string]float64) {
+		t.Helper()
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		n := 0
+		for _, m := range metrics {
+			n += len(m.Metric)
+		}
+		if expectedN := len(expected["absolute"]) + len(expected["relative"]); n != expectedN {
+			t.Fatalf("Expected %d series, got %d", expectedN, n)
+		}
+		for name, series := range expected {
+			for label, want := range series {
+				labels := prometheus.Labels{}
+				if label != "" {
+					labels["a"] = label
+				}
+				if got := getFloat64(metrics, name, labels); got == nil || *got != want {
+					t.Errorf("Expected %s%v to be %v, got %v", name, labels, want, got)
+				}
+			}
+		}
+	}
+	check(nil)
+
+	clock.ClockInstance.TickerCh <- time.Unix(1, 0)
+	events <- event.Events{}
+	check(map[string]map[string]float64{
+		"absolute": {"": 7},
+		"relative": {"b": -4, "c": 10},
+	})
+	if v := testutil.ToFloat64(coalesced); v != 3 {
+		t.Errorf("Expected 3 coalesced updates, got %v", v)
+	}
+
+	// Relative changes apply to the current value, and pending updates are
+	// applied when the exporter stops.
+	events <- event.Events{
+		&event.GaugeEvent{GMetricName: "relative", GValue: 5, GRelative: true, GLabels: map[string]string{"a": "b"}},
+	}
+	close(events)
+	<-done
+	check(map[string]map[string]float64{
+		"absolute": {"": 7},
+		"relative": {"b": 1, "c": 10},
+	})
+}
+
+func TestTrackReceived(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(10, 0),
+	}
+	defer func() { clock.ClockInstance = nil }()
+
+	config := `
+mappings:
+- match: test.tracked
+  name: tracked_total
+  track_received: true
+- match: test.untracked
+  name: untracked_total
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	first := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "first"}, []string{"name"})
+	last := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "last"}, []string{"name"})
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.FirstReceived = first
+	ex.LastReceived = last
+
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.tracked", CValue: 1, CLabels: map[string]string{}})
+	clock.ClockInstance.Instant = time.Unix(20, 500*int64(time.Millisecond))
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.tracked", CValue: 1, CLabels: map[string]string{}})
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.untracked", CValue: 1, CLabels: map[string]string{}})
+
+	if v := testutil.ToFloat64(first.WithLabelValues("tracked_total")); v != 10 {
+		t.Errorf("Expected tracked_total to be first received at 10, got %v", v)
+	}
+	if v := testutil.ToFloat64(last.WithLabelValues("tracked_total")); v != 20.5 {
+		t.Errorf("Expected tracked_total to be last received at 20.5, got %v", v)
+	}
+	if n := testutil.CollectAndCount(last); n != 1 {
+		t.Errorf("Expected only the tracked metric to be tracked, got %d series", n)
+	}
+}
+
+func TestRateWindow(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+	defer func() { clock.ClockInstance = nil }()
+
+	config := `
+mappings:
+- match: test.requests
+  name: requests_total
+  rate_window: 10s
+- match: test.legacy
+  name: legacy_total
+  counter_mode: absolute
+  rate_window: 10s
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.requests", CValue: 5, CLabels: map[string]string{}})
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.legacy", CValue: 100, CLabels: map[string]string{}})
+	clock.ClockInstance.Instant = time.Unix(3, 0)
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.requests", CValue: 5, CLabels: map[string]string{}})
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.legacy", CValue: 120, CLabels: map[string]string{}})
+
+	for _, s := range []struct {
+		instant          time.Time
+		requests, legacy float64
+	}{
+		{instant: time.Unix(5, 0), requests: 1, legacy: 12},
+		// The increments of the first second left the window.
+		{instant: time.Unix(10, 500*int64(time.Millisecond)), requests: 0.5, legacy: 2},
+		{instant: time.Unix(14, 0), requests: 0, legacy: 0},
+	} {
+		clock.ClockInstance.Instant = s.instant
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from registry: %v", err)
+		}
+		if v := getFloat64(metrics, "requests_total_rate", prometheus.Labels{}); v == nil || *v != s.requests {
+			t.Errorf("At %v, expected requests_total_rate to be %v, got %v", s.instant, s.requests, v)
+		}
+		if v := getFloat64(metrics, "legacy_total_rate", prometheus.Labels{}); v == nil || *v != s.legacy {
+			t.Errorf("At %v, expected legacy_total_rate to be %v, got %v", s.instant, s.legacy, v)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: make(chan time.Time),
+		Instant:  time.Unix(0, 0),
+	}
+	defer func() { clock.ClockInstance = nil }()
+
+	config := `
+mappings:
+- match: test.requests
+  name: requests_total
+  labels:
+    code: "200"
+- match: test.legacy
+  name: legacy_total
+  counter_mode: absolute
+- match: test.temperature
+  name: temperature
+  ttl: 10s
+- match: test.short
+  name: short
+  ttl: 1s
+- match: test.duration
+  name: duration_seconds
+  observer_type: histogram
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.requests", CValue: 5, CLabels: map[string]string{}})
+	ex.handleEvent(&event.CounterEvent{CMetricName: "test.legacy", CValue: 100, CLabels: map[string]string{}})
+	ex.handleEvent(&event.GaugeEvent{GMetricName: "test.temperature", GValue: -3.5, GLabels: map[string]string{}})
+	ex.handleEvent(&event.GaugeEvent{GMetricName: "test.short", GValue: 1, GLabels: map[string]string{}})
+	ex.handleEvent(&event.ObserverEvent{OMetricName: "test.duration", OValue: 1, OLabels: map[string]string{}})
+	snapshot := ex.snapshot()
+	if snapshot.err != nil {
+		t.Fatal(snapshot.err)
+	}
+
+	// The exporter restarts a few seconds later.
+	clock.ClockInstance.Instant = time.Unix(3, 0)
+	reg := prometheus.NewRegistry()
+	restored := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	n, err := restored.RestoreSnapshot(bytes.NewReader(snapshot.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 restored series, got %d", n)
+	}
+	restored.handleEvent(&event.CounterEvent{CMetricName: "test.legacy", CValue: 120, CLabels: map[string]string{}})
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	for name, expected := range map[string]float64{"requests_total": 5, "legacy_total": 120, "temperature": -3.5} {
+		labels := prometheus.Labels{}
+		if name == "requests_total" {
+			labels["code"] = "200"
+		}
+		if v := getFloat64(metrics, name, labels); v == nil || *v != expected {
+			t.Errorf("Expected %s to be %v, got %v", name, expected, v)
+		}
+	}
+	for _, name := range []string{"short", "duration_seconds"} {
+		if v := getFloat64(metrics, name, prometheus.Labels{}); v != nil {
+			t.Errorf("Expected %s not to be restored, got %v", name, *v)
+		}
+	}
+
+	// The restored series keep their TTL deadline.
+	clock.ClockInstance.Instant = time.Unix(11, 0)
+	restored.Registry.RemoveStaleMetrics()
+	metrics, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if v := getFloat64(metrics, "temperature", prometheus.Labels{}); v != nil {
+		t.Errorf("Expected temperature to expire 10s after it was last updated, got %v", *v)
+	}
+
+	if _, err := restored.RestoreSnapshot(strings.NewReader("garbage")); err == nil {
+		t.Error("Expected an error restoring an invalid snapshot")
+	}
+}
+
+func TestCheckMappings(t *testing.T) {
+	config := `
+mappings:
+- match: api.*.timer
+  name: api_${1}_duration_seconds
+  observer_type: summary
+- match: jobs.done
+  name: jobs_done_total
+  match_metric_type: counter
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+	ex := NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	testMapper.Validate = ex.checkMappings
+	ex.handleEvent(&event.ObserverEvent{OMetricName: "api.users.timer", OValue: 0.5, OLabels: map[string]string{}})
+	ex.handleEvent(&event.CounterEvent{CMetricName: "jobs.done", CValue: 1, CLabels: map[string]string{}})
+
 	scenarios := []struct {
 		name   string
-		metric string
-		labels map[string]string
+		config string
+		err    string
 	}{
 		{
-			name:   "no labels",
-			labels: map[string]string{},
-		}, {
-			name: "one label",
-			labels: map[string]string{
-				"label": "value",
-			},
-		}, {
-			name: "many labels",
-			labels: map[string]string{
-				"label0": "value",
-				"label1": "value",
-				"label2": "value",
-				"label3": "value",
-				"label4": "value",
-				"label5": "value",
-				"label6": "value",
-				"label7": "value",
-				"label8": "value",
-				"label9": "value",
-			},
+			name: "unchanged types",
+			config: `
+mappings:
+- match: api.*.timer
+  name: api_${1}_duration_seconds
+- match: jobs.done
+  name: jobs_done_total
+`,
+		},
+		{
+			name: "summary turned into histogram",
+			config: `
+mappings:
+- match: api.*.timer
+  name: api_${1}_duration_seconds
+  observer_type: histogram
+`,
+			err: "mapping api.*.timer would turn the existing summary api_users_duration_seconds into a counter or gauge or histogram",
+		},
+		{
+			name: "counter name reused for a gauge",
+			config: `
+mappings:
+- match: jobs.*.running
+  name: jobs_done_total
+  match_metric_type: gauge
+`,
+			err: "mapping jobs.*.running would turn the existing counter jobs_done_total into a gauge",
+		},
+		{
+			name: "additional observer with the name of a counter",
+			config: `
+mappings:
+- match: jobs.duration
+  name: jobs_done
+  match_metric_type: observer
+  additional_observers:
+  - observer_type: histogram
+    name_suffix: _total
+`,
+			err: "mapping jobs.duration would turn the existing counter jobs_done_total into a histogram",
+		},
+		{
+			name: "dropped",
+			config: `
+mappings:
+- match: api.*.timer
+  name: api_${1}_duration_seconds
+  observer_type: histogram
+  action: drop
+`,
 		},
 	}
 
-	r := registry.NewRegistry(prometheus.DefaultRegisterer, nil)
 	for _, s := range scenarios {
-		b.Run(s.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				r.HashLabels(s.labels)
+		t.Run(s.name, func(t *testing.T) {
+			err := testMapper.InitFromYAMLString(s.config)
+			if s.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != s.err {
+				t.Fatalf("expected error %q, got %v", s.err, err)
+			}
+			// The previous configuration stays in effect.
+			if _, _, ok := testMapper.GetMapping("jobs.done", mapper.MetricTypeCounter); !ok {
+				t.Fatal("expected the previous configuration to stay in effect")
 			}
 		})
 	}
 }
+
+// suffixRewriter is a Rewriter of an embedder.
+type suffixRewriter string
+
+func (s suffixRewriter) Rewrite(name string, labels prometheus.Labels) string {
+	if strings.HasPrefix(name, "drop_") {
+		return ""
+	}
+	return name + string(s)
+}
+
+func TestRewrite(t *testing.T) {
+	config := `
+mappings:
+  - match: legacy.app.*
+    name: legacy_app_requests
+    labels:
+      host: $1
+rewrites:
+  - action: rename
+    regex: legacy_(.*)
+    replacement: $1
+  - action: rename_label
+    source_label: host
+    target_label: instance
+  - action: drop_label
+    source_label: debug
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	events := make(chan event.Events)
+	go func() {
+		ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Rewriters = []Rewriter{suffixRewriter("_total")}
+		ex.Listen(context.Background(), events)
+	}()
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "legacy.app.web1", CValue: 1, CLabels: map[string]string{"debug": "1"}},
+		&event.CounterEvent{CMetricName: "legacy_unmapped", CValue: 2, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "drop_me", CValue: 3, CLabels: map[string]string{}},
+	}
+	events <- event.Events{}
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if v := getFloat64(metrics, "app_requests_total", prometheus.Labels{"instance": "web1"}); v == nil || *v != 1 {
+		t.Errorf("expected app_requests_total{instance=\"web1\"} 1, got %v", v)
+	}
+	if v := getFloat64(metrics, "unmapped_total", prometheus.Labels{}); v == nil || *v != 2 {
+		t.Errorf("expected unmapped_total 2, got %v", v)
+	}
+	for _, m := range metrics {
+		if strings.Contains(m.GetName(), "drop_me") || strings.HasPrefix(m.GetName(), "legacy") {
+			t.Errorf("unexpected metric %s", m.GetName())
+		}
+	}
+}
+
+func TestHelpConflicts(t *testing.T) {
+	config := `
+mappings:
+  - match: api.*.*
+    name: requests_total
+    help: "Requests to the ${1} backend"
+    labels:
+      backend: $1
+      method: $2
+  - match: web.*
+    name: requests_total
+    help: "Requests to the ${1} backend"
+    labels:
+      backend: $1
+`
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	var conflicts []registry.HelpConflict
+	ex.Registry.(*registry.Registry).OnHelpConflict = func(c registry.HelpConflict) {
+		conflicts = append(conflicts, c)
+	}
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			&event.CounterEvent{CMetricName: "api.db.get", CValue: 1, CLabels: map[string]string{}},
+			&event.CounterEvent{CMetricName: "web.cache", CValue: 1, CLabels: map[string]string{}},
+			&event.CounterEvent{CMetricName: "web.queue", CValue: 1, CLabels: map[string]string{}},
+		}
+		close(events)
+	}()
+	ex.Listen(context.Background(), events)
+
+	// The series with differing help texts are exposed with the help text of
+	// the first, instead of failing the scrape.
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from registry: %v", err)
+	}
+	if len(metrics) != 1 || len(metrics[0].GetMetric()) != 3 || metrics[0].GetHelp() != "Requests to the db backend" {
+		t.Fatalf("expected 3 series with the first help text, got %v", metrics)
+	}
+	expected := []registry.HelpConflict{{
+		MetricName:      "requests_total",
+		Help:            "Requests to the db backend",
+		ConflictingHelp: "Requests to the cache backend",
+		Match:           "web.*",
+	}}
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Fatalf("expected conflicts %v, got %v", expected, conflicts)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+// Forwarder is implemented by registries that can serialize the series
+// selected for forwarding as StatsD lines.
+type Forwarder interface {
+	ForwardLines() []string
+}
+
+// ForwardLines returns the series selected for forwarding by their mapping as
+// StatsD lines. Counters are returned as their increase since the previous
+// call. Like Metadata, it hands the request over to Listen, and returns
+// nothing if Listen is not running or the registry does not implement
+// Forwarder.
+func (b *Exporter) ForwardLines() []string {
+	if b.forwardRequests == nil {
+		return nil
+	}
+	reply := make(chan []string, 1)
+	select {
+	case b.forwardRequests <- reply:
+		return <-reply
+	case <-b.stopped:
+		return nil
+	}
+}
+
+// forwardLines is called from Listen.
+func (b *Exporter) forwardLines() []string {
+	forwarder, ok := b.Registry.(Forwarder)
+	if !ok {
+		return nil
+	}
+	return forwarder.ForwardLines()
+}
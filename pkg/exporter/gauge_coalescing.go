@@ -0,0 +1,106 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// pendingGauge is the update of a gauge series that is held back until the
+// end of the coalescing window.
+type pendingGauge struct {
+	metricName string
+	labels     prometheus.Labels
+	help       string
+	mapping    *mapper.MetricMapping
+	// event is the last event for the series, for reporting conflicts.
+	event event.Event
+	// set is whether an absolute value was received in the window. If so,
+	// value is the last absolute value plus the relative changes received
+	// after it, and otherwise the sum of the relative changes.
+	set   bool
+	value float64
+}
+
+// coalesceGauge records a gauge update to be applied at the end of the
+// window, merging it with the pending update of the same series.
+func (b *Exporter) coalesceGauge(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, thisEvent event.Event, value float64, relative bool) {
+	key := gaugeKey(metricName, labels)
+	pending, ok := b.pendingGauges[key]
+	if !ok {
+		if b.pendingGauges == nil {
+			b.pendingGauges = map[string]*pendingGauge{}
+		}
+		pending = &pendingGauge{}
+		b.pendingGauges[key] = pending
+	} else if b.CoalescedGaugeUpdates != nil {
+		b.CoalescedGaugeUpdates.Inc()
+	}
+
+	pending.metricName = metricName
+	pending.labels = labels
+	pending.help = help
+	pending.mapping = mapping
+	pending.event = thisEvent
+	if relative {
+		pending.value += value
+	} else {
+		pending.set = true
+		pending.value = value
+	}
+}
+
+// flushGauges applies the pending gauge updates.
+func (b *Exporter) flushGauges() {
+	for key, pending := range b.pendingGauges {
+		delete(b.pendingGauges, key)
+		gauge, err := b.Registry.GetGauge(pending.metricName, pending.labels, pending.help, pending.mapping, b.MetricsCount)
+		if err != nil {
+			b.Logger.Debug(regErrF, "metric", pending.metricName, "error", err)
+			b.conflict("gauge", pending.metricName, pending.event, err)
+			continue
+		}
+		if pending.set {
+			gauge.Set(pending.value)
+		} else {
+			gauge.Add(pending.value)
+		}
+	}
+}
+
+// gaugeKey identifies a series by its metric name and labels. The separators
+// cannot occur in valid UTF-8.
+func gaugeKey(metricName string, labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(metricName)
+	for _, name := range names {
+		sb.WriteByte(0xff)
+		sb.WriteString(name)
+		sb.WriteByte(0xfe)
+		sb.WriteString(labels[name])
+	}
+	return sb.String()
+}
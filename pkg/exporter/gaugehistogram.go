@@ -0,0 +1,39 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// GaugeHistogramGatherer returns a gatherer that marks the metric families of
+// gauge histograms gathered from g with the GAUGE_HISTOGRAM type, which the
+// client library cannot set by itself. The expfmt encoders do not support
+// this type; families of this type have to be encoded by the caller.
+func (b *Exporter) GaugeHistogramGatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	r, ok := b.Registry.(interface{ IsGaugeHistogram(string) bool })
+	if !ok {
+		return g
+	}
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		for _, mf := range mfs {
+			if mf.GetType() == dto.MetricType_HISTOGRAM && r.IsGaugeHistogram(mf.GetName()) {
+				mf.Type = dto.MetricType_GAUGE_HISTOGRAM.Enum()
+			}
+		}
+		return mfs, err
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"time"
+)
+
+// Responsive reports whether Listen answers a request within the timeout.
+// Listen answers between batches of events, so it is not responsive if it is
+// not running or if handling a batch is stuck.
+func (b *Exporter) Responsive(timeout time.Duration) bool {
+	if b.pingRequests == nil {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	done := make(chan struct{})
+	select {
+	case b.pingRequests <- done:
+		return true
+	case <-b.stopped:
+		return false
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+// MappingChecker is implemented by registries that can check a mapping
+// configuration against the types of the metrics they hold.
+type MappingChecker interface {
+	CheckMappings(m *mapper.MetricMapper) error
+}
+
+type mappingCheck struct {
+	mapper *mapper.MetricMapper
+	reply  chan error
+}
+
+// CheckMappings reports the metrics whose type the mapping configuration
+// would change, so that a reload can refuse it. Like Metadata, it hands the
+// request over to Listen. It reports nothing if Listen is not running or the
+// registry does not implement MappingChecker.
+func (b *Exporter) CheckMappings(m *mapper.MetricMapper) error {
+	if b.mappingChecks == nil {
+		return nil
+	}
+	check := mappingCheck{mapper: m, reply: make(chan error, 1)}
+	select {
+	case b.mappingChecks <- check:
+		return <-check.reply
+	case <-b.stopped:
+		return nil
+	}
+}
+
+// checkMappings is called from Listen.
+func (b *Exporter) checkMappings(m *mapper.MetricMapper) error {
+	checker, ok := b.Registry.(MappingChecker)
+	if !ok {
+		return nil
+	}
+	return checker.CheckMappings(m)
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"runtime/metrics"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SeriesEvicter is implemented by registries that can drop time series to
+// reduce memory usage.
+type SeriesEvicter interface {
+	EvictLeastRecentlyUpdated(fraction float64, protected func(metricName string) bool) int
+}
+
+// MemoryGuard evicts the least recently updated time series while the live
+// heap is above a limit, so that the exporter sheds series instead of being
+// killed for running out of memory. A guard can be shared by several
+// exporters; each of them evicts from its own registry.
+type MemoryGuard struct {
+	// MaxHeap is the live heap size in bytes above which series are evicted.
+	MaxHeap uint64
+	// EvictFraction is the fraction of the unprotected series that is evicted
+	// each time the heap is found above MaxHeap.
+	EvictFraction float64
+	// Interval is the time between two checks of the heap size.
+	Interval time.Duration
+	// Protected, if set, reports whether the series of a metric must never be
+	// evicted.
+	Protected func(metricName string) bool
+	// Evictions, if set, counts the times series were evicted.
+	Evictions prometheus.Counter
+	// EvictedSeries, if set, counts the evicted series.
+	EvictedSeries prometheus.Counter
+}
+
+// readHeapStats returns the live heap size as of the last garbage collection,
+// and the number of completed collections. It is a variable so that tests can
+// simulate memory pressure.
+var readHeapStats = func() (liveHeap uint64, gcCycles uint64) {
+	samples := []metrics.Sample{
+		{Name: "/gc/heap/live:bytes"},
+		{Name: "/gc/cycles/total:gc-cycles"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64(), samples[1].Value.Uint64()
+}
+
+// checkMemory evicts series if the heap is above the limit. Evicted series
+// only free memory once the garbage collector has run, so after an eviction
+// no further series are evicted until the next collection has completed.
+func (b *Exporter) checkMemory() {
+	g := b.MemoryGuard
+	liveHeap, gcCycles := readHeapStats()
+	if liveHeap <= g.MaxHeap || gcCycles < b.nextEvictionGC {
+		return
+	}
+	evicter, ok := b.Registry.(SeriesEvicter)
+	if !ok {
+		return
+	}
+
+	n := evicter.EvictLeastRecentlyUpdated(g.EvictFraction, g.Protected)
+	if n == 0 {
+		b.Logger.Warn("Heap is above the limit but there are no series to evict", "live_heap", liveHeap, "max_heap", g.MaxHeap)
+		return
+	}
+	b.nextEvictionGC = gcCycles + 1
+	b.Logger.Warn("Heap is above the limit, evicted least recently updated series", "live_heap", liveHeap, "max_heap", g.MaxHeap, "evicted", n)
+	if g.Evictions != nil {
+		g.Evictions.Inc()
+	}
+	if g.EvictedSeries != nil {
+		g.EvictedSeries.Add(float64(n))
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/statsd_exporter/pkg/registry"
+)
+
+// MemoryUsageEstimator is implemented by registries that estimate the memory
+// used by their series.
+type MemoryUsageEstimator interface {
+	MemoryUsage() registry.MemoryReport
+}
+
+// MemoryUsage returns the estimated memory usage of the current series. Like
+// Metadata, it hands the request over to Listen, and returns nil if Listen is
+// not running or the registry does not implement MemoryUsageEstimator.
+func (b *Exporter) MemoryUsage() *registry.MemoryReport {
+	if b.memoryUsageRequests == nil {
+		return nil
+	}
+	reply := make(chan *registry.MemoryReport, 1)
+	select {
+	case b.memoryUsageRequests <- reply:
+		return <-reply
+	case <-b.stopped:
+		return nil
+	}
+}
+
+// memoryUsage is called from Listen.
+func (b *Exporter) memoryUsage() *registry.MemoryReport {
+	estimator, ok := b.Registry.(MemoryUsageEstimator)
+	if !ok {
+		return nil
+	}
+	report := estimator.MemoryUsage()
+	return &report
+}
+
+// MemoryUsageHandler returns the estimated memory usage of the current series
+// as JSON, in the response format of the Prometheus HTTP API.
+func (b *Exporter) MemoryUsageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := b.MemoryUsage()
+		if report == nil {
+			http.Error(w, "memory usage estimation is not available", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Status string                 `json:"status"`
+			Data   *registry.MemoryReport `json:"data"`
+		}{
+			Status: "success",
+			Data:   report,
+		})
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/statsd_exporter/pkg/registry"
+)
+
+// MetadataLister is implemented by registries that can describe the metric
+// families they expose.
+type MetadataLister interface {
+	Metadata() []registry.MetricMetadata
+}
+
+// Metadata returns the metadata of the metric families currently exposed.
+// Like SweepStale, it hands the request over to Listen, and returns nothing
+// if Listen is not running or the registry does not implement
+// MetadataLister.
+func (b *Exporter) Metadata() []registry.MetricMetadata {
+	if b.metadataRequests == nil {
+		return nil
+	}
+	reply := make(chan []registry.MetricMetadata, 1)
+	select {
+	case b.metadataRequests <- reply:
+		return <-reply
+	case <-b.stopped:
+		return nil
+	}
+}
+
+// metadata is called from Listen.
+func (b *Exporter) metadata() []registry.MetricMetadata {
+	lister, ok := b.Registry.(MetadataLister)
+	if !ok {
+		return nil
+	}
+	return lister.Metadata()
+}
+
+// MetadataHandler returns the metadata of the exposed metric families as
+// JSON, in the response format of the Prometheus HTTP API.
+func (b *Exporter) MetadataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata := b.Metadata()
+		if metadata == nil {
+			metadata = []registry.MetricMetadata{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Status string                    `json:"status"`
+			Data   []registry.MetricMetadata `json:"data"`
+		}{
+			Status: "success",
+			Data:   metadata,
+		})
+	})
+}
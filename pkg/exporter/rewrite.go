@@ -0,0 +1,43 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Rewriter changes the name and labels of events after mapping, before they
+// reach the registry and the sinks. Rewrite returns the new metric name, which
+// is escaped like mapped names, and may change the labels in place. An empty
+// name drops the event. Rewrite is called from Listen and must not block.
+//
+// The rewrite rules of the mapping configuration are applied before the
+// Rewriters of the exporter, and *mapper.RewriteRule implements Rewriter, so
+// that embedders can combine the built-in rules with their own.
+type Rewriter interface {
+	Rewrite(name string, labels prometheus.Labels) string
+}
+
+// rewrite applies the rewrite rules of the mapping configuration and the
+// Rewriters of the exporter to a metric.
+func (b *Exporter) rewrite(name string, labels prometheus.Labels) string {
+	rewritten := b.Mapper.Rewrite(name, labels)
+	for _, r := range b.Rewriters {
+		rewritten = r.Rewrite(rewritten, labels)
+	}
+	if rewritten == name || rewritten == "" {
+		return rewritten
+	}
+	return b.Mapper.EscapeMetricName(rewritten)
+}
@@ -0,0 +1,48 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+// SampledCarrier is implemented by registries that carry the fractional parts
+// of sampled counter increments over per series, for mappings that accumulate
+// them.
+type SampledCarrier interface {
+	CarrySampled(metricName string, labels prometheus.Labels, value float64) float64
+}
+
+// sampledIncrement rounds the increment of a sampled counter as selected by
+// its mapping. The series must exist, so that the registry can carry the
+// remainder over.
+func (b *Exporter) sampledIncrement(mapping *mapper.MetricMapping, metricName string, labels prometheus.Labels, value float64) float64 {
+	switch mapping.SampledCounters {
+	case mapper.SampledCountersAccumulate:
+		if carrier, ok := b.Registry.(SampledCarrier); ok {
+			return carrier.CarrySampled(metricName, labels, value)
+		}
+	case mapper.SampledCountersStochastic:
+		whole := math.Floor(value)
+		if randFloat64() < value-whole {
+			whole++
+		}
+		return whole
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// MappedEvent is an event with the metric name, labels and value that its
+// mapping produced.
+type MappedEvent struct {
+	Event  event.Event
+	Name   string
+	Labels prometheus.Labels
+	Value  float64
+	// Relative is whether a gauge event changes the gauge rather than
+	// setting it.
+	Relative bool
+	// Match is the match of the mapping, empty for unmapped events.
+	Match string
+}
+
+// EventSink receives the events of the mappings that output to it. Send is
+// called from Listen and must not block. The labels belong to the exporter
+// and must not be kept after Send returns.
+type EventSink interface {
+	Send(e MappedEvent)
+}
+
+// sendToSinks passes an event to the sinks of the outputs of its mapping
+// other than Prometheus.
+func (b *Exporter) sendToSinks(thisEvent event.Event, mapping *mapper.MetricMapping, metricName string, labels prometheus.Labels, value float64) {
+	for _, output := range mapping.Outputs {
+		if output == mapper.OutputPrometheus {
+			continue
+		}
+		sink, ok := b.Sinks[output]
+		if !ok {
+			b.ErrorEventStats.WithLabelValues("output_unavailable").Inc()
+			b.trace("output_unavailable", "output", output)
+			continue
+		}
+		e := MappedEvent{
+			Event:  thisEvent,
+			Name:   metricName,
+			Labels: labels,
+			Value:  value,
+			Match:  mapping.Match,
+		}
+		if ev, ok := thisEvent.(*event.GaugeEvent); ok {
+			e.Relative = gaugeRelative(ev, mapping)
+		}
+		sink.Send(e)
+		b.trace("sent", "output", output)
+	}
+}
+
+// gaugeRelative returns whether a gauge event changes the gauge rather than
+// setting it.
+func gaugeRelative(ev *event.GaugeEvent, mapping *mapper.MetricMapping) bool {
+	// With gauge_literal_negative, the minus sign is part of the value, not
+	// a decrement.
+	return ev.GRelative && !(mapping.GaugeLiteralNegative && ev.GValue < 0)
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Snapshotter is implemented by registries that can write their series to a
+// snapshot and restore them from it.
+type Snapshotter interface {
+	WriteSnapshot(w io.Writer) error
+	RestoreSnapshot(r io.Reader, metricsCount *prometheus.GaugeVec) (int, error)
+}
+
+var (
+	errNotListening         = errors.New("the exporter is not running")
+	errSnapshotsUnsupported = errors.New("the registry does not support snapshots")
+)
+
+type snapshotReply struct {
+	data []byte
+	err  error
+}
+
+// Snapshot returns a binary snapshot of the series of the registry. Like
+// Metadata, it hands the request over to Listen, so that the snapshot is
+// consistent.
+func (b *Exporter) Snapshot() ([]byte, error) {
+	if b.snapshotRequests == nil {
+		return nil, errNotListening
+	}
+	reply := make(chan snapshotReply, 1)
+	select {
+	case b.snapshotRequests <- reply:
+		r := <-reply
+		return r.data, r.err
+	case <-b.stopped:
+		return nil, errNotListening
+	}
+}
+
+// snapshot is called from Listen.
+func (b *Exporter) snapshot() snapshotReply {
+	snapshotter, ok := b.Registry.(Snapshotter)
+	if !ok {
+		return snapshotReply{err: errSnapshotsUnsupported}
+	}
+	var buf bytes.Buffer
+	if err := snapshotter.WriteSnapshot(&buf); err != nil {
+		return snapshotReply{err: err}
+	}
+	return snapshotReply{data: buf.Bytes()}
+}
+
+// RestoreSnapshot restores the series of a snapshot taken with Snapshot, and
+// returns how many were restored. It must be called before Listen.
+func (b *Exporter) RestoreSnapshot(r io.Reader) (int, error) {
+	snapshotter, ok := b.Registry.(Snapshotter)
+	if !ok {
+		return 0, errSnapshotsUnsupported
+	}
+	return snapshotter.RestoreSnapshot(r, b.MetricsCount)
+}
+
+// SnapshotHandler serves a binary snapshot of the series of the registry, to
+// be restored by a restarted exporter.
+func (b *Exporter) SnapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := b.Snapshot()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	})
+}
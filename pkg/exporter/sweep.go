@@ -0,0 +1,58 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// SweepStrategy selects when expired time series are removed.
+type SweepStrategy string
+
+const (
+	// SweepTicker removes expired time series every second.
+	SweepTicker SweepStrategy = "ticker"
+	// SweepScrape removes expired time series before each scrape of a
+	// gatherer returned by SweepingGatherer.
+	SweepScrape SweepStrategy = "scrape"
+	// SweepBoth combines SweepTicker and SweepScrape.
+	SweepBoth SweepStrategy = "both"
+)
+
+// SweepStale removes expired time series and returns once they are gone. The
+// registry is only accessed from Listen, so the removal is handed over to it
+// and interleaves with event processing instead of racing with it. If Listen
+// is not running, SweepStale returns without doing anything.
+func (b *Exporter) SweepStale() {
+	if b.sweepRequests == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case b.sweepRequests <- done:
+		<-done
+	case <-b.stopped:
+	}
+}
+
+// SweepingGatherer returns a gatherer that removes expired time series before
+// gathering from g, so that a scrape never returns series whose TTL elapsed
+// since the last sweep.
+func (b *Exporter) SweepingGatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		b.SweepStale()
+		return g.Gather()
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// TypeConflictResolver is implemented by registries that can look up the type
+// of events a metric is fed by, and remove a metric so that it can be
+// registered with another type.
+type TypeConflictResolver interface {
+	MetricEventType(metricName string) (mapper.MetricType, bool)
+	RemoveMetric(metricName string)
+}
+
+// resolveTypeConflict applies the enforced type of the mapping and the type
+// conflict policy to an event, and reports whether the event is suppressed.
+// Events of the enforced type, and under the type_priority policy events of a
+// type of higher priority, replace a metric of another type. Conflicts that
+// are not resolved are left to the registry to report.
+func (b *Exporter) resolveTypeConflict(mapping *mapper.MetricMapping, metricName string, thisEvent event.Event) bool {
+	eventType := thisEvent.MetricType()
+	if mapping.EnforceType != "" && eventType != mapping.EnforceType {
+		b.Logger.Debug("Event type differs from the enforced type", "metric", metricName, "type", eventType, "enforce_type", mapping.EnforceType)
+		b.typeConflictResolved("enforced", metricName, eventType)
+		return true
+	}
+
+	resolver, ok := b.Registry.(TypeConflictResolver)
+	if !ok {
+		return false
+	}
+	existingType, ok := resolver.MetricEventType(metricName)
+	if !ok || existingType == eventType {
+		return false
+	}
+
+	replace := false
+	switch {
+	case mapping.EnforceType != "":
+		replace = true
+	case b.Mapper.Defaults.TypeConflicts == mapper.TypeConflictsFirstWins:
+	case b.Mapper.Defaults.TypeConflicts == mapper.TypeConflictsTypePriority:
+		replace = b.Mapper.Defaults.TypeOutranks(eventType, existingType)
+	default:
+		return false
+	}
+
+	if !replace {
+		b.Logger.Debug("Metric is registered with another type", "metric", metricName, "type", eventType, "registered_type", existingType)
+		b.typeConflictResolved("suppressed", metricName, eventType)
+		return true
+	}
+	b.Logger.Debug("Replacing metric of another type", "metric", metricName, "type", eventType, "registered_type", existingType)
+	resolver.RemoveMetric(metricName)
+	b.typeConflictResolved("replaced", metricName, eventType)
+	return false
+}
+
+func (b *Exporter) typeConflictResolved(outcome, metricName string, eventType mapper.MetricType) {
+	if b.TypeConflictsResolved != nil {
+		b.TypeConflictsResolved.WithLabelValues(outcome).Inc()
+	}
+	b.trace("type_conflict", "outcome", outcome, "name", metricName, "type", eventType)
+}
@@ -0,0 +1,293 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// Dialect is a style of tags in StatsD lines.
+type Dialect string
+
+const (
+	DialectDogStatsD Dialect = "dogstatsd"
+	DialectInfluxDB  Dialect = "influxdb"
+	DialectLibrato   Dialect = "librato"
+	DialectSignalFX  Dialect = "signalfx"
+	// DialectUnknown is the dialect of sources that have not sent a line
+	// with tags of exactly one dialect yet.
+	DialectUnknown Dialect = "unknown"
+)
+
+// DefaultMaxDialectSources is the number of sources whose dialect a
+// DialectDetector remembers if no maximum is set.
+const DefaultMaxDialectSources = 10000
+
+// SourceFormat is implemented by formats that parse the lines of each source
+// differently. Listeners that know the source of lines use the format
+// returned by ForSource for them.
+type SourceFormat interface {
+	Format
+	ForSource(source netip.AddrPort) Format
+}
+
+// SourceDialect describes the dialect detected for a source.
+type SourceDialect struct {
+	Source   string    `json:"source"`
+	Dialect  Dialect   `json:"dialect"`
+	Lines    uint64    `json:"lines"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// DialectDetector parses StatsD lines with the tag dialect of their source
+// instead of all enabled dialects. The dialect of a source is locked in with
+// the first line that has tags of exactly one of the dialects enabled in the
+// parser it was created with. Until then, lines are parsed with all enabled
+// dialects. Once the dialect is known, lines that look like they use another
+// one are parsed without tags, instead of failing as mixed tagging styles
+// or having part of their name taken for tags.
+type DialectDetector struct {
+	// MaxSources is the number of sources whose dialect is remembered. When
+	// lines arrive from more sources, the half of the sources that have not
+	// been seen for the longest time are forgotten. If it is not positive,
+	// DefaultMaxDialectSources is used.
+	MaxSources int
+	// Detections, if set, counts the sources whose dialect was detected, by
+	// dialect.
+	Detections *prometheus.CounterVec
+	// Lines, if set, counts the lines from sources, by the dialect they were
+	// parsed with.
+	Lines *prometheus.CounterVec
+
+	parser   *Parser
+	dialects map[Dialect]*Parser
+
+	mtx     sync.Mutex
+	sources map[netip.AddrPort]*dialectSource
+}
+
+// NewDialectDetector creates a detector that chooses among the dialects
+// enabled in the parser. The parser must not be changed afterwards.
+func NewDialectDetector(parser *Parser) *DialectDetector {
+	d := &DialectDetector{
+		parser:   parser,
+		dialects: map[Dialect]*Parser{},
+		sources:  map[netip.AddrPort]*dialectSource{},
+	}
+	for dialect, enabled := range map[Dialect]bool{
+		DialectDogStatsD: parser.DogstatsdTagsEnabled,
+		DialectInfluxDB:  parser.InfluxdbTagsEnabled,
+		DialectLibrato:   parser.LibratoTagsEnabled,
+		DialectSignalFX:  parser.SignalFXTagsEnabled,
+	} {
+		if !enabled {
+			continue
+		}
+		p := *parser
+		p.DogstatsdTagsEnabled = dialect == DialectDogStatsD
+		p.InfluxdbTagsEnabled = dialect == DialectInfluxDB
+		p.LibratoTagsEnabled = dialect == DialectLibrato
+		p.SignalFXTagsEnabled = dialect == DialectSignalFX
+		d.dialects[dialect] = &p
+	}
+	return d
+}
+
+// LineToEvents parses a line of an unknown source with all enabled dialects.
+func (d *DialectDetector) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	return d.parser.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+}
+
+// ForSource returns the format for the lines of a source. If the source is
+// not valid, lines are parsed with all enabled dialects.
+func (d *DialectDetector) ForSource(source netip.AddrPort) Format {
+	if !source.IsValid() {
+		return d.parser
+	}
+	source = netip.AddrPortFrom(source.Addr().Unmap(), source.Port())
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if s, ok := d.sources[source]; ok {
+		return s
+	}
+	maxSources := d.MaxSources
+	if maxSources <= 0 {
+		maxSources = DefaultMaxDialectSources
+	}
+	if len(d.sources) >= maxSources {
+		d.forgetOldest()
+	}
+	s := &dialectSource{detector: d, source: source}
+	s.state.Store(&dialectState{dialect: DialectUnknown, parser: d.parser, lines: d.linesCounter(DialectUnknown)})
+	s.lastSeen.Store(clock.Now().UnixNano())
+	d.sources[source] = s
+	return s
+}
+
+// forgetOldest removes the half of the sources that have not been seen for the
+// longest time. The lock must be held.
+func (d *DialectDetector) forgetOldest() {
+	sources := make([]*dialectSource, 0, len(d.sources))
+	for _, s := range d.sources {
+		sources = append(sources, s)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].lastSeen.Load() < sources[j].lastSeen.Load()
+	})
+	for _, s := range sources[:len(sources)-len(sources)/2] {
+		delete(d.sources, s.source)
+	}
+}
+
+func (d *DialectDetector) linesCounter(dialect Dialect) prometheus.Counter {
+	if d.Lines == nil {
+		return nil
+	}
+	return d.Lines.WithLabelValues(string(dialect))
+}
+
+// Sources returns the sources that are remembered, ordered by address.
+func (d *DialectDetector) Sources() []SourceDialect {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	sources := make([]SourceDialect, 0, len(d.sources))
+	for _, s := range d.sources {
+		sources = append(sources, SourceDialect{
+			Source:   s.source.String(),
+			Dialect:  s.state.Load().dialect,
+			Lines:    s.lineCount.Load(),
+			LastSeen: time.Unix(0, s.lastSeen.Load()).UTC(),
+		})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Source < sources[j].Source
+	})
+	return sources
+}
+
+// detectDialect returns the dialect of the tags in a line, if the line has tags
+// of exactly one of the dialects the parser has enabled.
+func (d *DialectDetector) detectDialect(line string) (Dialect, bool) {
+	name, body, _ := strings.Cut(line, ":")
+
+	var found Dialect
+	n := 0
+	if d.parser.SignalFXTagsEnabled {
+		start, end := strings.IndexByte(name, '['), strings.IndexByte(name, ']')
+		if start != -1 && end > start {
+			found, n = DialectSignalFX, n+1
+			name = name[:start] + name[end+1:]
+		}
+	}
+	if d.parser.InfluxdbTagsEnabled {
+		if _, tags, ok := strings.Cut(name, ","); ok && strings.Contains(tags, "=") {
+			found, n = DialectInfluxDB, n+1
+		}
+	}
+	if d.parser.LibratoTagsEnabled {
+		if _, tags, ok := strings.Cut(name, "#"); ok && strings.Contains(tags, "=") {
+			found, n = DialectLibrato, n+1
+		}
+	}
+	if d.parser.DogstatsdTagsEnabled && strings.Contains(body, "|#") {
+		found, n = DialectDogStatsD, n+1
+	}
+	if n != 1 {
+		return "", false
+	}
+	return found, true
+}
+
+// dialectSource is the format for the lines of one source.
+type dialectSource struct {
+	detector  *DialectDetector
+	source    netip.AddrPort
+	state     atomic.Pointer[dialectState]
+	lastSeen  atomic.Int64
+	lineCount atomic.Uint64
+}
+
+// dialectState is how the lines of a source are parsed and counted.
+type dialectState struct {
+	dialect Dialect
+	parser  *Parser
+	lines   prometheus.Counter
+}
+
+func (s *dialectSource) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	state := s.state.Load()
+	if line == "" {
+		return state.parser.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	}
+	s.lastSeen.Store(clock.Now().UnixNano())
+	s.lineCount.Add(1)
+
+	if state.dialect == DialectUnknown {
+		state = s.detect(state, line, logger)
+	}
+	if state.lines != nil {
+		state.lines.Inc()
+	}
+	return state.parser.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+}
+
+// detect looks for the dialect in a line of a source whose dialect is not
+// known yet, and returns the state to parse the line with.
+func (s *dialectSource) detect(state *dialectState, line string, logger *slog.Logger) *dialectState {
+	d := s.detector
+	dialect, ok := d.detectDialect(line)
+	if !ok {
+		return state
+	}
+
+	detected := &dialectState{dialect: dialect, parser: d.dialects[dialect], lines: d.linesCounter(dialect)}
+	// Several goroutines may handle lines of the same UDP source.
+	if !s.state.CompareAndSwap(state, detected) {
+		return s.state.Load()
+	}
+	logger.Debug("Detected tag dialect", "source", s.source, "dialect", dialect)
+	if d.Detections != nil {
+		d.Detections.WithLabelValues(string(dialect)).Inc()
+	}
+	return detected
+}
+
+// ServeHTTP returns the remembered sources and their dialects as JSON, in the
+// response format of the Prometheus HTTP API.
+func (d *DialectDetector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Status string          `json:"status"`
+		Data   []SourceDialect `json:"data"`
+	}{
+		Status: "success",
+		Data:   d.Sources(),
+	})
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+func newTestDialectDetector() *DialectDetector {
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	p.EnableInfluxdbParsing()
+	p.EnableLibratoParsing()
+	p.EnableSignalFXParsing()
+	return NewDialectDetector(p)
+}
+
+func TestDetectDialect(t *testing.T) {
+	d := newTestDialectDetector()
+	for line, expected := range map[string]Dialect{
+		"foo:1|c|#tag:value":           DialectDogStatsD,
+		"foo,tag=value:1|c":            DialectInfluxDB,
+		"foo#tag=value:1|c":            DialectLibrato,
+		"foo[tag=value]:1|c":           DialectSignalFX,
+		"foo[a=b,c=d]:1|c":             DialectSignalFX,
+		"foo:1|c":                      "",
+		"foo,bar:1|c":                  "",
+		"foo,tag=value:1|c|#tag:other": "",
+	} {
+		dialect, ok := d.detectDialect(line)
+		if dialect != expected || ok != (expected != "") {
+			t.Errorf("%s: expected %q, got %q (%v)", line, expected, dialect, ok)
+		}
+	}
+
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	d = NewDialectDetector(p)
+	if dialect, ok := d.detectDialect("foo,tag=value:1|c|#tag:other"); !ok || dialect != DialectDogStatsD {
+		t.Errorf("expected only enabled dialects to be detected, got %q (%v)", dialect, ok)
+	}
+}
+
+func TestDialectDetector(t *testing.T) {
+	d := newTestDialectDetector()
+	d.Detections = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "detections_total"}, []string{"dialect"})
+	d.Lines = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lines_total"}, []string{"dialect"})
+
+	parse := func(f Format, line string) event.Events {
+		return f.LineToEvents(line, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	}
+	counter := func(name string, labels map[string]string) event.Events {
+		return event.Events{&event.CounterEvent{CMetricName: name, CValue: 1, CLabels: labels}}
+	}
+
+	dogstatsd := netip.MustParseAddrPort("10.0.0.1:8125")
+	influxdb := netip.MustParseAddrPort("[::ffff:10.0.0.2]:8125")
+
+	f := d.ForSource(dogstatsd)
+	if events := parse(f, "foo:1|c"); !reflect.DeepEqual(events, counter("foo", map[string]string{})) {
+		t.Fatalf("unexpected events before detection: %v", events)
+	}
+	if events := parse(f, "foo:1|c|#tag:value"); !reflect.DeepEqual(events, counter("foo", map[string]string{"tag": "value"})) {
+		t.Fatalf("unexpected events of the detecting line: %v", events)
+	}
+	// Once the dialect is known, other styles are no longer parsed.
+	if events := parse(d.ForSource(dogstatsd), "foo,tag=value:1|c|#other:value"); !reflect.DeepEqual(events, counter("foo,tag=value", map[string]string{"other": "value"})) {
+		t.Fatalf("unexpected events after detection: %v", events)
+	}
+
+	f = d.ForSource(influxdb)
+	if events := parse(f, "foo,tag=value:1|c"); !reflect.DeepEqual(events, counter("foo", map[string]string{"tag": "value"})) {
+		t.Fatalf("unexpected events of the detecting line: %v", events)
+	}
+	if events := parse(f, "foo:1|c|#tag:value"); len(events) != 1 || len(events[0].Labels()) != 0 {
+		t.Fatalf("expected DogStatsD tags not to be parsed after detecting InfluxDB, got %v", events)
+	}
+
+	// Lines of unknown sources are parsed with all dialects.
+	if events := parse(d, "foo#tag=value:1|c"); !reflect.DeepEqual(events, counter("foo", map[string]string{"tag": "value"})) {
+		t.Fatalf("unexpected events of an unknown source: %v", events)
+	}
+	if f := d.ForSource(netip.AddrPort{}); f != Format(d.parser) {
+		t.Fatalf("expected lines without source to be parsed with all dialects")
+	}
+
+	sources := d.Sources()
+	if len(sources) != 2 ||
+		sources[0].Source != "10.0.0.1:8125" || sources[0].Dialect != DialectDogStatsD || sources[0].Lines != 3 ||
+		sources[1].Source != "10.0.0.2:8125" || sources[1].Dialect != DialectInfluxDB || sources[1].Lines != 2 {
+		t.Fatalf("unexpected sources: %+v", sources)
+	}
+
+	for dialect, expected := range map[string]float64{"dogstatsd": 1, "influxdb": 1} {
+		if v := testutil.ToFloat64(d.Detections.WithLabelValues(dialect)); v != expected {
+			t.Errorf("expected %v %s detections, got %v", expected, dialect, v)
+		}
+	}
+	for dialect, expected := range map[string]float64{"unknown": 1, "dogstatsd": 2, "influxdb": 2} {
+		if v := testutil.ToFloat64(d.Lines.WithLabelValues(dialect)); v != expected {
+			t.Errorf("expected %v %s lines, got %v", expected, dialect, v)
+		}
+	}
+}
+
+func TestDialectDetectorMaxSources(t *testing.T) {
+	d := newTestDialectDetector()
+	d.MaxSources = 4
+
+	addr := netip.MustParseAddr("10.0.0.1")
+	for port := uint16(1); port <= 4; port++ {
+		f := d.ForSource(netip.AddrPortFrom(addr, port))
+		f.LineToEvents("foo:1|c", *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	}
+	// Keep the first source fresh.
+	d.sources[netip.AddrPortFrom(addr, 1)].lastSeen.Add(1e9)
+
+	d.ForSource(netip.AddrPortFrom(addr, 5))
+	if len(d.sources) != 3 {
+		t.Fatalf("expected half of the sources to be forgotten, %d remain", len(d.sources))
+	}
+	if _, ok := d.sources[netip.AddrPortFrom(addr, 1)]; !ok {
+		t.Fatalf("expected the most recently seen source to be remembered")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TagStyles are the tagging styles lines can be encoded in. They are named
+// like the tagging extensions of the parser. Lines in the "statsd" style have
+// no tags.
+var TagStyles = []string{"statsd", "dogstatsd", "influxdb", "librato", "signalfx"}
+
+// Tag is a tag of an encoded line.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Encoder writes lines in one tagging style, as the clients using that style
+// send them. It is the reverse of the Parser with the tagging extension of
+// the style enabled.
+type Encoder struct {
+	style string
+}
+
+// NewEncoder creates an encoder for one of the TagStyles.
+func NewEncoder(style string) (*Encoder, error) {
+	for _, s := range TagStyles {
+		if s == style {
+			return &Encoder{style: style}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown tag style %q", style)
+}
+
+// AppendLine appends the line of a sample, without a trailing newline, to b
+// and returns the extended buffer. statType is the StatsD type of the sample,
+// such as "c" or "ms".
+func (e *Encoder) AppendLine(b []byte, name string, tags []Tag, value float64, statType string) []byte {
+	if len(tags) == 0 || e.style == "statsd" {
+		return appendSample(append(b, name...), value, statType)
+	}
+
+	switch e.style {
+	case "dogstatsd":
+		b = appendSample(append(b, name...), value, statType)
+		b = append(b, "|#"...)
+		return appendTags(b, tags, ':')
+	case "influxdb":
+		b = appendTags(append(append(b, name...), ','), tags, '=')
+	case "librato":
+		b = appendTags(append(append(b, name...), '#'), tags, '=')
+	case "signalfx":
+		// SignalFx tags can be anywhere in the name, and are usually in
+		// front of its last component, as in foo.[tag=value]bar.
+		i := strings.LastIndexByte(name, '.') + 1
+		b = append(append(b, name[:i]...), '[')
+		b = append(appendTags(b, tags, '='), ']')
+		b = append(b, name[i:]...)
+	}
+	return appendSample(b, value, statType)
+}
+
+func appendSample(b []byte, value float64, statType string) []byte {
+	b = strconv.AppendFloat(append(b, ':'), value, 'g', -1, 64)
+	return append(append(b, '|'), statType...)
+}
+
+func appendTags(b []byte, tags []Tag, separator byte) []byte {
+	for i, tag := range tags {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(append(append(b, tag.Name...), separator), tag.Value...)
+	}
+	return b
+}
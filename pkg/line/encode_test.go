@@ -0,0 +1,67 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+func TestEncoder(t *testing.T) {
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	p.EnableInfluxdbParsing()
+	p.EnableLibratoParsing()
+	p.EnableSignalFXParsing()
+
+	tags := []Tag{{"env", "prod"}, {"host", "a"}}
+	expected := map[string]string{
+		"statsd":    "app.requests:2.5|ms",
+		"dogstatsd": "app.requests:2.5|ms|#env:prod,host:a",
+		"influxdb":  "app.requests,env=prod,host=a:2.5|ms",
+		"librato":   "app.requests#env=prod,host=a:2.5|ms",
+		"signalfx":  "app.[env=prod,host=a]requests:2.5|ms",
+	}
+	for _, style := range TagStyles {
+		e, err := NewEncoder(style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := string(e.AppendLine(nil, "app.requests", tags, 2.5, "ms"))
+		if line != expected[style] {
+			t.Errorf("%s: expected %q, got %q", style, expected[style], line)
+		}
+
+		// The parser reads back what the encoder wrote.
+		labels := map[string]string{"env": "prod", "host": "a"}
+		if style == "statsd" {
+			labels = map[string]string{}
+		}
+		events := p.LineToEvents(line, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		want := event.Events{&event.ObserverEvent{OMetricName: "app.requests", OValue: 2.5, OTimer: true, OLabels: labels}}
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("%s: expected %v, got %v", style, want, events)
+		}
+	}
+
+	e, _ := NewEncoder("dogstatsd")
+	if line := string(e.AppendLine([]byte("x:1|c\n"), "foo", nil, -1, "g")); line != "x:1|c\nfoo:-1|g" {
+		t.Errorf("expected the line to be appended without tags, got %q", line)
+	}
+	if _, err := NewEncoder("graphite"); err == nil {
+		t.Error("expected an unknown style to fail")
+	}
+}
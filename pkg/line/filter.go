@@ -0,0 +1,112 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// Filter drops lines that start with one of its prefixes or match one of its
+// regular expressions, and passes the others on to the wrapped Format.
+// Dropping unwanted lines here saves the cost of parsing them, which a drop
+// action in the mapping would still pay.
+type Filter struct {
+	Format
+	rules *filterRules
+}
+
+type filterRules struct {
+	prefixes        []string
+	prefixDrops     []prometheus.Counter
+	expressions     []*regexp.Regexp
+	expressionDrops []prometheus.Counter
+}
+
+// NewFilter creates a filter in front of the given format. If dropped is not
+// nil, the dropped lines are counted in it, by the type ("prefix" or "regex")
+// and the filter that matched.
+func NewFilter(f Format, prefixes, expressions []string, dropped *prometheus.CounterVec) (*Filter, error) {
+	rules := &filterRules{}
+	counter := func(filterType, filter string) prometheus.Counter {
+		if dropped == nil {
+			return nil
+		}
+		return dropped.WithLabelValues(filterType, filter)
+	}
+	for _, p := range prefixes {
+		if p == "" {
+			return nil, fmt.Errorf("line filter prefix must not be empty")
+		}
+		rules.prefixes = append(rules.prefixes, p)
+		rules.prefixDrops = append(rules.prefixDrops, counter("prefix", p))
+	}
+	for _, e := range expressions {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line filter regex %q: %w", e, err)
+		}
+		rules.expressions = append(rules.expressions, re)
+		rules.expressionDrops = append(rules.expressionDrops, counter("regex", e))
+	}
+	return &Filter{Format: f, rules: rules}, nil
+}
+
+// LineToEvents parses the line with the wrapped format unless it is dropped.
+func (f *Filter) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	if f.rules.drop(line) {
+		logger.Debug("Line dropped by filter", "line", line)
+		return nil
+	}
+	return f.Format.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+}
+
+// ForSource filters the lines of a source, if the wrapped format parses the
+// lines of each source differently.
+func (f *Filter) ForSource(source netip.AddrPort) Format {
+	sf, ok := f.Format.(SourceFormat)
+	if !ok {
+		return f
+	}
+	return &Filter{Format: sf.ForSource(source), rules: f.rules}
+}
+
+// drop reports whether a line matches a rule, and counts it if so. Prefixes
+// are checked first, as they are cheaper.
+func (r *filterRules) drop(line string) bool {
+	for i, p := range r.prefixes {
+		if strings.HasPrefix(line, p) {
+			if c := r.prefixDrops[i]; c != nil {
+				c.Inc()
+			}
+			return true
+		}
+	}
+	for i, re := range r.expressions {
+		if re.MatchString(line) {
+			if c := r.expressionDrops[i]; c != nil {
+				c.Inc()
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFilter(t *testing.T) {
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped_total"}, []string{"type", "filter"})
+	f, err := NewFilter(NewParser(), []string{"firehose.", "debug."}, []string{`^[^:]*\.tmp:`}, dropped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for line, keep := range map[string]bool{
+		"foo:1|c":              true,
+		"firehose.bar:1|c":     false,
+		"debug.x:1|g":          false,
+		"foo.tmp:1|c":          false,
+		"foo:1|c|#path:/x.tmp": true,
+		"foo.firehose.bar:1|c": true,
+	} {
+		events := f.LineToEvents(line, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if kept := len(events) != 0; kept != keep {
+			t.Errorf("%s: expected kept to be %v, got %v", line, keep, kept)
+		}
+	}
+
+	for _, c := range []struct {
+		filterType, filter string
+		expected           float64
+	}{
+		{"prefix", "firehose.", 1},
+		{"prefix", "debug.", 1},
+		{"regex", `^[^:]*\.tmp:`, 1},
+	} {
+		if v := testutil.ToFloat64(dropped.WithLabelValues(c.filterType, c.filter)); v != c.expected {
+			t.Errorf("expected %v lines dropped by %s %q, got %v", c.expected, c.filterType, c.filter, v)
+		}
+	}
+
+	// Filters stay in front of formats that parse each source differently.
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	f, err = NewFilter(NewDialectDetector(p), []string{"firehose."}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := f.ForSource(netip.MustParseAddrPort("10.0.0.1:8125"))
+	if events := sf.LineToEvents("firehose.bar:1|c", *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger); len(events) != 0 {
+		t.Errorf("expected the line of a source to be dropped, got %v", events)
+	}
+
+	if _, err := NewFilter(NewParser(), nil, []string{"("}, nil); err == nil {
+		t.Error("expected an invalid regex to fail")
+	}
+	if _, err := NewFilter(NewParser(), []string{""}, nil, nil); err == nil {
+		t.Error("expected an empty prefix to fail")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// DefaultFormat is the name of the built-in StatsD line format.
+const DefaultFormat = "statsd"
+
+// Format converts a single line into events. *Parser is the implementation
+// of the built-in StatsD format.
+type Format interface {
+	LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events
+}
+
+// FormatFactory creates a Format. It receives the configured StatsD parser,
+// so that custom formats that only change the framing of lines can delegate
+// to it.
+type FormatFactory func(statsd *Parser) Format
+
+var (
+	formatsMtx sync.RWMutex
+	formats    = map[string]FormatFactory{
+		DefaultFormat: func(statsd *Parser) Format { return statsd },
+	}
+)
+
+// RegisterFormat makes a line format available under the given name. It is
+// intended to be called from the init function of the package implementing
+// the format, and panics if the name is already registered.
+func RegisterFormat(name string, factory FormatFactory) {
+	formatsMtx.Lock()
+	defer formatsMtx.Unlock()
+
+	if _, ok := formats[name]; ok {
+		panic(fmt.Sprintf("line format %q is already registered", name))
+	}
+	formats[name] = factory
+}
+
+// Formats returns the names of all registered line formats, sorted.
+func Formats() []string {
+	formatsMtx.RLock()
+	defer formatsMtx.RUnlock()
+
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFormat creates the line format registered under the given name.
+func NewFormat(name string, statsd *Parser) (Format, error) {
+	formatsMtx.RLock()
+	factory, ok := formats[name]
+	formatsMtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown line format %q", name)
+	}
+	return factory(statsd), nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// prefixedFormat is a sample custom format. Every line starts with a source
+// name and a colon, which is turned into a label before the rest of the line
+// is parsed as StatsD.
+type prefixedFormat struct {
+	statsd *Parser
+}
+
+func (f *prefixedFormat) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	source, rest, ok := strings.Cut(line, ":")
+	if !ok {
+		sampleErrors.WithLabelValues("malformed_line").Inc()
+		return nil
+	}
+	events := f.statsd.LineToEvents(rest, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	for _, e := range events {
+		e.Labels()["source"] = source
+	}
+	return events
+}
+
+func init() {
+	RegisterFormat("test-prefixed", func(statsd *Parser) Format {
+		return &prefixedFormat{statsd: statsd}
+	})
+}
+
+func TestCustomFormat(t *testing.T) {
+	if !reflect.DeepEqual(Formats(), []string{"statsd", "test-prefixed"}) {
+		t.Fatalf("unexpected formats: %v", Formats())
+	}
+
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	f, err := NewFormat("test-prefixed", p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := f.LineToEvents("web01:foo:1|c|#tag:value", *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	expected := event.Events{
+		&event.CounterEvent{
+			CMetricName: "foo",
+			CValue:      1,
+			CLabels:     map[string]string{"source": "web01", "tag": "value"},
+		},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, events)
+	}
+
+	if f, err := NewFormat(DefaultFormat, p); err != nil || f != Format(p) {
+		t.Fatalf("expected the statsd format to be the parser itself, got %v, %v", f, err)
+	}
+	if _, err := NewFormat("unknown", p); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestRegisterFormatTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a format twice to panic")
+		}
+	}()
+	RegisterFormat(DefaultFormat, func(statsd *Parser) Format { return statsd })
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// ContainerIDLabel is the label that the container ID of a tag header is
+// added as.
+const ContainerIDLabel = "container_id"
+
+// IsTagHeader reports whether the first line of a datagram is a tag header.
+// Some DogStatsD clients batch the lines of one container into a datagram
+// that starts with the tags they share, such as
+//
+//	#env:prod,service:web|c:3f2a9d
+//	requests:1|c
+//	latency:32|ms|#endpoint:login
+//
+// where the optional c field is the ID of the container the lines come from.
+// StatsD metric names cannot start with '#'.
+func IsTagHeader(line string) bool {
+	return strings.HasPrefix(line, "#")
+}
+
+// ParseTagHeader parses a tag header into the labels it sets.
+func ParseTagHeader(header string, tagErrors prometheus.Counter, logger *slog.Logger) (map[string]string, error) {
+	tags, fields, _ := strings.Cut(header, "|")
+	labels := map[string]string{}
+	if tags != "#" {
+		parseDogStatsDTags(tags[1:], labels, tagErrors, logger)
+	}
+	for fields != "" {
+		var field string
+		field, fields, _ = strings.Cut(fields, "|")
+		containerID, ok := strings.CutPrefix(field, "c:")
+		if !ok || containerID == "" {
+			return nil, fmt.Errorf("invalid field %q in tag header", field)
+		}
+		labels[ContainerIDLabel] = containerID
+	}
+	if len(labels) == 0 {
+		return nil, errors.New("empty tag header")
+	}
+	return labels, nil
+}
+
+// SharedTags adds the labels of a tag header to the events of the lines
+// parsed by the wrapped Format. Unlike those of a Labeler, the labels do not
+// replace the tags of a line, which are more specific.
+type SharedTags struct {
+	Format
+	labels map[string]string
+}
+
+// NewSharedTags creates a format that adds the shared labels in front of the
+// given format.
+func NewSharedTags(f Format, labels map[string]string) *SharedTags {
+	return &SharedTags{Format: f, labels: labels}
+}
+
+// LineToEvents parses the line with the wrapped format and adds the shared
+// labels to its events.
+func (s *SharedTags) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := s.Format.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	for _, e := range events {
+		labels := e.Labels()
+		if labels == nil {
+			continue
+		}
+		for name, value := range s.labels {
+			if _, ok := labels[name]; !ok {
+				labels[name] = value
+			}
+		}
+	}
+	return events
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTagHeader(t *testing.T) {
+	scenarios := []struct {
+		header string
+		out    map[string]string
+		err    bool
+	}{
+		{header: "#env:prod,service:web", out: map[string]string{"env": "prod", "service": "web"}},
+		{header: "#env:prod|c:3f2a9d", out: map[string]string{"env": "prod", "container_id": "3f2a9d"}},
+		{header: "#|c:3f2a9d", out: map[string]string{"container_id": "3f2a9d"}},
+		{header: "#env:prod|c:", err: true},
+		{header: "#env:prod|T1700000000", err: true},
+		{header: "#", err: true},
+	}
+	for _, s := range scenarios {
+		labels, err := ParseTagHeader(s.header, nopTagErrors, nopLogger)
+		if s.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", s.header, labels)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", s.header, err)
+		} else if !reflect.DeepEqual(labels, s.out) {
+			t.Errorf("%s: expected labels %v, got %v", s.header, s.out, labels)
+		}
+	}
+}
+
+func TestSharedTags(t *testing.T) {
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	// Listener labels win over line tags, which win over shared tags.
+	f := NewSharedTags(NewLabeler(p, map[string]string{"transport": "udp"}), map[string]string{"env": "prod", "service": "web", "transport": "x"})
+
+	events := f.LineToEvents("foo:1|c|#service:api", *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %v", events)
+	}
+	if expected := map[string]string{"env": "prod", "service": "api", "transport": "udp"}; !reflect.DeepEqual(events[0].Labels(), expected) {
+		t.Errorf("expected labels %v, got %v", expected, events[0].Labels())
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// InfluxFieldType is the kind of StatsD event that the fields of InfluxDB line
+// protocol are converted to.
+type InfluxFieldType string
+
+const (
+	InfluxFieldGauge    InfluxFieldType = "gauge"
+	InfluxFieldCounter  InfluxFieldType = "counter"
+	InfluxFieldObserver InfluxFieldType = "observer"
+)
+
+// statType returns the StatsD type of events for fields of the type.
+func (t InfluxFieldType) statType() string {
+	switch t {
+	case InfluxFieldCounter:
+		return "c"
+	case InfluxFieldObserver:
+		return "h"
+	}
+	return "g"
+}
+
+func (t InfluxFieldType) valid() bool {
+	switch t {
+	case InfluxFieldGauge, InfluxFieldCounter, InfluxFieldObserver:
+		return true
+	}
+	return false
+}
+
+// InfluxConfig selects the types of the fields of InfluxDB line protocol, as
+// in:
+//
+//	default_type: gauge
+//	measurements:
+//	- measurement: http_requests
+//	  type: counter
+//	  fields:
+//	    duration_seconds: observer
+type InfluxConfig struct {
+	// DefaultType is the type of the fields of measurements that are not
+	// configured. It defaults to gauge.
+	DefaultType  InfluxFieldType     `yaml:"default_type"`
+	Measurements []InfluxMeasurement `yaml:"measurements"`
+}
+
+// InfluxMeasurement sets the type of the fields of a measurement. Fields
+// listed in Fields have their own type.
+type InfluxMeasurement struct {
+	Measurement string                     `yaml:"measurement"`
+	Type        InfluxFieldType            `yaml:"type"`
+	Fields      map[string]InfluxFieldType `yaml:"fields"`
+}
+
+// LoadInfluxConfig reads the field types of InfluxDB line protocol from a
+// YAML file.
+func LoadInfluxConfig(path string) (InfluxConfig, error) {
+	var config InfluxConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("invalid InfluxDB configuration %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// InfluxParser converts lines of InfluxDB line protocol into events. Every
+// numeric or boolean field of a line becomes an event named after the
+// measurement and the field, separated by a dot, with the tags of the line as
+// labels. A field named "value" is named after the measurement alone. String
+// fields and timestamps are ignored.
+type InfluxParser struct {
+	defaultType  InfluxFieldType
+	measurements map[string]InfluxMeasurement
+}
+
+// NewInfluxParser returns a parser for InfluxDB line protocol with the given
+// field types.
+func NewInfluxParser(config InfluxConfig) (*InfluxParser, error) {
+	p := &InfluxParser{
+		defaultType:  config.DefaultType,
+		measurements: make(map[string]InfluxMeasurement, len(config.Measurements)),
+	}
+	if p.defaultType == "" {
+		p.defaultType = InfluxFieldGauge
+	}
+	if !p.defaultType.valid() {
+		return nil, fmt.Errorf("invalid default field type %q", config.DefaultType)
+	}
+	for _, m := range config.Measurements {
+		if m.Measurement == "" {
+			return nil, fmt.Errorf("measurement without name")
+		}
+		if _, ok := p.measurements[m.Measurement]; ok {
+			return nil, fmt.Errorf("measurement %s is configured more than once", m.Measurement)
+		}
+		if m.Type == "" {
+			m.Type = p.defaultType
+		}
+		if !m.Type.valid() {
+			return nil, fmt.Errorf("invalid field type %q for measurement %s", m.Type, m.Measurement)
+		}
+		for field, t := range m.Fields {
+			if !t.valid() {
+				return nil, fmt.Errorf("invalid field type %q for field %s of measurement %s", t, field, m.Measurement)
+			}
+		}
+		p.measurements[m.Measurement] = m
+	}
+	return p, nil
+}
+
+// fieldType returns the type of a field of a measurement.
+func (p *InfluxParser) fieldType(measurement, field string) InfluxFieldType {
+	m, ok := p.measurements[measurement]
+	if !ok {
+		return p.defaultType
+	}
+	if t, ok := m.Fields[field]; ok {
+		return t
+	}
+	return m.Type
+}
+
+func (p *InfluxParser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := event.Events{}
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return events
+	}
+	if !utf8.ValidString(line) {
+		sampleErrors.WithLabelValues("malformed_line").Inc()
+		logger.Debug("bad line", "line", line)
+		return events
+	}
+
+	sections := influxSplit(line, ' ')
+	if len(sections) < 2 || len(sections) > 3 || sections[0] == "" {
+		sampleErrors.WithLabelValues("malformed_line").Inc()
+		logger.Debug("bad line: expected measurement, fields and an optional timestamp", "line", line)
+		return events
+	}
+	if len(sections) == 3 {
+		if _, err := strconv.ParseInt(sections[2], 10, 64); err != nil {
+			sampleErrors.WithLabelValues("malformed_line").Inc()
+			logger.Debug("bad line: invalid timestamp", "line", line)
+			return events
+		}
+	}
+
+	key := influxSplit(sections[0], ',')
+	measurement := influxUnescape(key[0])
+	if measurement == "" {
+		sampleErrors.WithLabelValues("malformed_line").Inc()
+		logger.Debug("bad line: empty measurement", "line", line)
+		return events
+	}
+	labels := map[string]string{}
+	for _, tag := range key[1:] {
+		parts := influxSplit(tag, '=')
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			tagErrors.Inc()
+			logger.Debug("Malformed tag", "tag", tag, "line", line)
+			continue
+		}
+		tagsReceived.Inc()
+		labels[influxUnescape(parts[0])] = influxUnescape(parts[1])
+	}
+
+	for _, field := range influxSplit(sections[1], ',') {
+		parts := influxSplit(field, '=')
+		if len(parts) != 2 || parts[0] == "" {
+			sampleErrors.WithLabelValues("malformed_component").Inc()
+			logger.Debug("bad line: malformed field", "field", field, "line", line)
+			continue
+		}
+		name := influxUnescape(parts[0])
+		value, ok, err := parseInfluxValue(parts[1])
+		if err != nil {
+			sampleErrors.WithLabelValues("malformed_value").Inc()
+			logger.Debug("bad line: invalid field value", "field", field, "line", line, "error", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		metric := measurement
+		if name != "value" {
+			metric += "." + name
+		}
+		statType := p.fieldType(measurement, name).statType()
+		samplesReceived.WithLabelValues(statType).Inc()
+		// Each event gets its own labels, as the exporter may modify them.
+		eventLabels := make(map[string]string, len(labels))
+		for k, v := range labels {
+			eventLabels[k] = v
+		}
+		ev, err := buildEvent(statType, metric, value, false, eventLabels)
+		if err != nil {
+			sampleErrors.WithLabelValues("illegal_event").Inc()
+			logger.Debug("Error building event", "line", line, "error", err)
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// parseInfluxValue parses a field value. It returns false for string values,
+// which have no numeric representation.
+func parseInfluxValue(v string) (float64, bool, error) {
+	if v == "" {
+		return 0, false, fmt.Errorf("empty value")
+	}
+	if v[0] == '"' {
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return 0, false, fmt.Errorf("unterminated string %s", v)
+		}
+		return 0, false, nil
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+	switch v[len(v)-1] {
+	case 'i':
+		i, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		return float64(i), err == nil, err
+	case 'u':
+		u, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		return float64(u), err == nil, err
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil, err
+}
+
+// influxSplit splits s at the occurrences of sep that are neither escaped
+// with a backslash nor inside a quoted string value.
+func influxSplit(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"' && (quoted || i == 0 || s[i-1] == '='):
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// influxUnescapeReplacer removes the backslashes of escaped characters.
+var influxUnescapeReplacer = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\"`, `"`, `\\`, `\`)
+
+func influxUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return influxUnescapeReplacer.Replace(s)
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+func TestInfluxLineProtocol(t *testing.T) {
+	parser, err := NewInfluxParser(InfluxConfig{
+		Measurements: []InfluxMeasurement{
+			{
+				Measurement: "http",
+				Type:        InfluxFieldCounter,
+				Fields:      map[string]InfluxFieldType{"duration": InfluxFieldObserver},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name string
+		in   string
+		out  event.Events
+	}{
+		{
+			name: "gauge by default",
+			in:   "cpu,host=a,region=eu usage_idle=92.5,usage_user=3i 1700000000000000000",
+			out: event.Events{
+				&event.GaugeEvent{GMetricName: "cpu.usage_idle", GValue: 92.5, GLabels: map[string]string{"host": "a", "region": "eu"}},
+				&event.GaugeEvent{GMetricName: "cpu.usage_user", GValue: 3, GLabels: map[string]string{"host": "a", "region": "eu"}},
+			},
+		},
+		{
+			name: "configured measurement",
+			in:   "http,code=200 requests=1u,duration=0.25",
+			out: event.Events{
+				&event.CounterEvent{CMetricName: "http.requests", CValue: 1, CLabels: map[string]string{"code": "200"}},
+				&event.ObserverEvent{OMetricName: "http.duration", OValue: 0.25, OLabels: map[string]string{"code": "200"}},
+			},
+		},
+		{
+			name: "value field and booleans",
+			in:   "up value=t,ok=false",
+			out: event.Events{
+				&event.GaugeEvent{GMetricName: "up", GValue: 1, GLabels: map[string]string{}},
+				&event.GaugeEvent{GMetricName: "up.ok", GValue: 0, GLabels: map[string]string{}},
+			},
+		},
+		{
+			name: "escapes and strings",
+			in:   `disk\ io,path=C:\\,label=a\,b\=c msg="a, b=c",reads=4`,
+			out: event.Events{
+				&event.GaugeEvent{GMetricName: "disk io.reads", GValue: 4, GLabels: map[string]string{"path": `C:\`, "label": "a,b=c"}},
+			},
+		},
+		{name: "comment", in: "# comment", out: event.Events{}},
+		{name: "no fields", in: "cpu,host=a", out: event.Events{}},
+		{name: "invalid timestamp", in: "cpu value=1 yesterday", out: event.Events{}},
+		{
+			name: "invalid field value",
+			in:   "cpu a=x,b=2",
+			out: event.Events{
+				&event.GaugeEvent{GMetricName: "cpu.b", GValue: 2, GLabels: map[string]string{}},
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			events := parser.LineToEvents(s.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if !reflect.DeepEqual(events, s.out) {
+				t.Fatalf("Expected %#v, got %#v", s.out, events)
+			}
+		})
+	}
+}
+
+func TestInfluxConfigValidation(t *testing.T) {
+	for _, config := range []InfluxConfig{
+		{DefaultType: "timer"},
+		{Measurements: []InfluxMeasurement{{Type: InfluxFieldGauge}}},
+		{Measurements: []InfluxMeasurement{{Measurement: "a"}, {Measurement: "a"}}},
+		{Measurements: []InfluxMeasurement{{Measurement: "a", Fields: map[string]InfluxFieldType{"b": "set"}}}},
+	} {
+		if _, err := NewInfluxParser(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
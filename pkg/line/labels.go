@@ -0,0 +1,62 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"log/slog"
+	"net/netip"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// Labeler adds fixed labels to the events of the lines parsed by the wrapped
+// Format, such as the transport or the name of the listener the lines were
+// received on. The labels replace tags of the same name, so that clients
+// cannot forge them.
+type Labeler struct {
+	Format
+	labels map[string]string
+}
+
+// NewLabeler creates a labeler in front of the given format.
+func NewLabeler(f Format, labels map[string]string) *Labeler {
+	return &Labeler{Format: f, labels: labels}
+}
+
+// LineToEvents parses the line with the wrapped format and labels its events.
+func (l *Labeler) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := l.Format.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	for _, e := range events {
+		labels := e.Labels()
+		if labels == nil {
+			continue
+		}
+		for name, value := range l.labels {
+			labels[name] = value
+		}
+	}
+	return events
+}
+
+// ForSource labels the events of a source, if the wrapped format parses the
+// lines of each source differently.
+func (l *Labeler) ForSource(source netip.AddrPort) Format {
+	sf, ok := l.Format.(SourceFormat)
+	if !ok {
+		return l
+	}
+	return &Labeler{Format: sf.ForSource(source), labels: l.labels}
+}
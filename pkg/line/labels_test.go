@@ -0,0 +1,52 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestLabeler(t *testing.T) {
+	p := NewParser()
+	p.EnableDogstatsdParsing()
+	l := NewLabeler(p, map[string]string{"transport": "udp", "listener": "internal"})
+
+	for line, expected := range map[string]map[string]string{
+		"foo:1|c":                   {"transport": "udp", "listener": "internal"},
+		"foo:1|c|#env:prod":         {"transport": "udp", "listener": "internal", "env": "prod"},
+		"foo:1|c|#listener:forged":  {"transport": "udp", "listener": "internal"},
+		"foo:1|c|@0.5|#transport:x": {"transport": "udp", "listener": "internal"},
+	} {
+		events := l.LineToEvents(line, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 {
+			t.Fatalf("%s: expected 1 event, got %v", line, events)
+		}
+		if labels := events[0].Labels(); !reflect.DeepEqual(labels, expected) {
+			t.Errorf("%s: expected labels %v, got %v", line, expected, labels)
+		}
+	}
+
+	// Labelers stay in front of formats that parse each source differently.
+	l = NewLabeler(NewDialectDetector(p), map[string]string{"transport": "tcp"})
+	sf := l.ForSource(netip.MustParseAddrPort("10.0.0.1:8125"))
+	events := sf.LineToEvents("foo:1|c|#env:prod", *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %v", events)
+	}
+	if expected := map[string]string{"transport": "tcp", "env": "prod"}; !reflect.DeepEqual(events[0].Labels(), expected) {
+		t.Errorf("expected labels %v, got %v", expected, events[0].Labels())
+	}
+}
@@ -16,14 +16,15 @@ package line
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"strconv"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/event"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
 )
 
 // Parser is a struct to hold configuration for parsing behavior
@@ -32,6 +33,16 @@ type Parser struct {
 	InfluxdbTagsEnabled  bool
 	LibratoTagsEnabled   bool
 	SignalFXTagsEnabled  bool
+	// NonFinite is how NaN and infinite values are handled. The zero value
+	// is NonFinitePropagate.
+	NonFinite NonFinitePolicy
+	// NonFiniteValues, if set, counts NaN and infinite values by reason and
+	// the action taken.
+	NonFiniteValues *prometheus.CounterVec
+	// GaugeLiteralNegative takes gauge values with a minus sign for absolute
+	// negative values instead of decrements. A plus sign still makes a
+	// gauge value an increment.
+	GaugeLiteralNegative bool
 }
 
 // NewParser returns a new line parser
@@ -78,7 +89,8 @@ func buildEvent(statType, metric string, value float64, relative bool, labels ma
 	case "ms":
 		return &event.ObserverEvent{
 			OMetricName: metric,
-			OValue:      float64(value) / 1000, // prometheus presumes seconds, statsd millisecond
+			OValue:      float64(value),
+			OTimer:      true,
 			OLabels:     labels,
 		}, nil
 	case "h", "d":
@@ -149,21 +161,25 @@ func trimLeftHash(s string) string {
 
 func (p *Parser) ParseDogStatsDTags(component string, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
 	if p.DogstatsdTagsEnabled {
-		lastTagEndIndex := 0
-		for i, c := range component {
-			if c == ',' {
-				tag := component[lastTagEndIndex:i]
-				lastTagEndIndex = i + 1
-				parseTag(component, trimLeftHash(tag), ':', labels, tagErrors, logger)
-			}
-		}
+		parseDogStatsDTags(component, labels, tagErrors, logger)
+	}
+}
 
-		// If we're not off the end of the string, add the last tag
-		if lastTagEndIndex < len(component) {
-			tag := component[lastTagEndIndex:]
+func parseDogStatsDTags(component string, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
+	lastTagEndIndex := 0
+	for i, c := range component {
+		if c == ',' {
+			tag := component[lastTagEndIndex:i]
+			lastTagEndIndex = i + 1
 			parseTag(component, trimLeftHash(tag), ':', labels, tagErrors, logger)
 		}
 	}
+
+	// If we're not off the end of the string, add the last tag
+	if lastTagEndIndex < len(component) {
+		tag := component[lastTagEndIndex:]
+		parseTag(component, trimLeftHash(tag), ':', labels, tagErrors, logger)
+	}
 }
 
 func (p *Parser) parseNameAndTags(name string, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) string {
@@ -201,22 +217,32 @@ func (p *Parser) parseNameAndTags(name string, labels map[string]string, tagErro
 	return name
 }
 
-func (p *Parser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+// sampleType returns the statsd type of a sample for instrumentation. Anything
+// unexpected is reported as "unknown" to keep the label values bounded.
+func sampleType(statType string) string {
+	switch statType {
+	case "c", "g", "ms", "h", "d", "s":
+		return statType
+	}
+	return "unknown"
+}
+
+func (p *Parser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
 	events := event.Events{}
 	if line == "" {
 		return events
 	}
 
-	elements := strings.SplitN(line, ":", 2)
-	if len(elements) < 2 || len(elements[0]) == 0 || !utf8.ValidString(line) {
+	name, body, found := strings.Cut(line, ":")
+	if !found || len(name) == 0 || !utf8.ValidString(line) {
 		sampleErrors.WithLabelValues("malformed_line").Inc()
 		logger.Debug("bad line", "line", line)
 		return events
 	}
 
 	labels := map[string]string{}
-	metric := p.parseNameAndTags(elements[0], labels, tagErrors, logger)
-	usingDogStatsDTags := strings.Contains(elements[1], "|#")
+	metric := p.parseNameAndTags(name, labels, tagErrors, logger)
+	usingDogStatsDTags := strings.Contains(body, "|#")
 	if usingDogStatsDTags && len(labels) > 0 {
 		// using DogStatsD tags
 
@@ -227,30 +253,33 @@ func (p *Parser) LineToEvents(line string, sampleErrors prometheus.CounterVec, s
 	}
 
 	var samples []string
-	lineParts := strings.SplitN(elements[1], "|", 3)
-	if len(lineParts) < 2 {
+	values, suffix, found := strings.Cut(body, "|")
+	if !found {
 		sampleErrors.WithLabelValues("not_enough_parts_after_colon").Inc()
 		logger.Debug("bad line: not enough '|'-delimited parts after first ':'", "line", line)
 		return events
 	}
-	if strings.Contains(lineParts[0], ":") {
+	packed := strings.Contains(values, ":")
+	if packed {
 		// handle DogStatsD extended aggregation
 		isValidAggType := false
-		switch lineParts[1] {
+		statType, _, _ := strings.Cut(suffix, "|")
+		switch statType {
 		case
 			"ms", // timer
 			"h",  // histogram
-			"d":  // distribution
+			"d",  // distribution
+			"c",  // counter
+			"g":  // gauge
 			isValidAggType = true
 		}
 
 		if isValidAggType {
-			aggValues := strings.Split(lineParts[0], ":")
+			aggValues := strings.Split(values, ":")
 			aggLines := make([]string, len(aggValues))
-			_, aggLineSuffix, _ := strings.Cut(elements[1], "|")
 
 			for i, aggValue := range aggValues {
-				aggLines[i] = strings.Join([]string{aggValue, aggLineSuffix}, "|")
+				aggLines[i] = aggValue + "|" + suffix
 			}
 			samples = aggLines
 		} else {
@@ -258,27 +287,33 @@ func (p *Parser) LineToEvents(line string, sampleErrors prometheus.CounterVec, s
 			logger.Debug("bad line: invalid extended aggregate type", "line", line)
 			return events
 		}
-	} else if usingDogStatsDTags {
-		// disable multi-metrics
-		samples = elements[1:]
+	} else if usingDogStatsDTags || !strings.Contains(body, ":") {
+		// DogStatsD tags disable multi-metrics
+		samples = []string{body}
 	} else {
-		samples = strings.Split(elements[1], ":")
+		samples = strings.Split(body, ":")
 	}
 
 samples:
 	for _, sample := range samples {
-		samplesReceived.Inc()
-		components := strings.Split(sample, "|")
-		if len(components) < 2 || len(components) > 4 {
+		var parts [4]string
+		n := splitInto(parts[:], sample, '|')
+		components := parts[:min(n, len(parts))]
+		statType := ""
+		if len(components) >= 2 {
+			statType = components[1]
+		}
+		samplesReceived.WithLabelValues(sampleType(statType)).Inc()
+		if n < 2 || n > len(parts) {
 			sampleErrors.WithLabelValues("malformed_component").Inc()
 			logger.Debug("bad component", "line", line)
 			continue
 		}
-		valueStr, statType := components[0], components[1]
+		valueStr := components[0]
 
 		var relative = false
 		if strings.Index(valueStr, "+") == 0 || strings.Index(valueStr, "-") == 0 {
-			relative = true
+			relative = !(p.GaugeLiteralNegative && statType == "g" && valueStr[0] == '-')
 		}
 
 		value, err := strconv.ParseFloat(valueStr, 64)
@@ -288,7 +323,10 @@ samples:
 			continue
 		}
 
-		multiplyEvents := 1
+		// A sampled observation stands for 1/rate observations, which are
+		// carried as the count of a single event.
+		observations := 1
+		sampled := false
 		if len(components) >= 3 {
 			for _, component := range components[2:] {
 				if len(component) == 0 {
@@ -315,8 +353,9 @@ samples:
 						continue
 					} else if statType == "c" {
 						value /= samplingFactor
+						sampled = samplingFactor != 1
 					} else if statType == "ms" || statType == "h" || statType == "d" {
-						multiplyEvents = int(1 / samplingFactor)
+						observations = int(1 / samplingFactor)
 					}
 				case '#':
 					p.ParseDogStatsDTags(component[1:], labels, tagErrors, logger)
@@ -328,19 +367,94 @@ samples:
 			}
 		}
 
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			var keep bool
+			if value, keep = p.nonFiniteValue(value, statType, logger, line); !keep {
+				continue
+			}
+		}
+
 		if len(labels) > 0 {
 			tagsReceived.Inc()
 		}
 
-		for i := 0; i < multiplyEvents; i++ {
-			event, err := buildEvent(statType, metric, value, relative, labels)
-			if err != nil {
-				logger.Debug("Error building event", "line", line, "error", err)
-				sampleErrors.WithLabelValues("illegal_event").Inc()
-				continue
-			}
-			events = append(events, event)
+		ev, err := buildEvent(statType, metric, value, relative, labels)
+		if err != nil {
+			logger.Debug("Error building event", "line", line, "error", err)
+			sampleErrors.WithLabelValues("illegal_event").Inc()
+			continue
+		}
+		if o, ok := ev.(*event.ObserverEvent); ok && observations > 1 {
+			o.OCount = observations
+		}
+		if c, ok := ev.(*event.CounterEvent); ok {
+			c.CSampled = sampled
 		}
+		events = append(events, ev)
+	}
+	if packed {
+		return combinePackedEvents(events)
 	}
 	return events
 }
+
+// splitInto splits s around sep like strings.Split, but into the given parts
+// instead of a newly allocated slice. It returns the number of substrings,
+// which may be more than fit into parts.
+func splitInto(parts []string, s string, sep byte) int {
+	n := 0
+	for {
+		i := strings.IndexByte(s, sep)
+		if n < len(parts) {
+			if i < 0 {
+				parts[n] = s
+			} else {
+				parts[n] = s[:i]
+			}
+		}
+		n++
+		if i < 0 {
+			return n
+		}
+		s = s[i+1:]
+	}
+}
+
+// combinePackedEvents turns the events from the packed values of a counter or
+// gauge line, such as `foo:1:2:3|c`, into a single event. Counter values are
+// summed. A gauge ends up where setting or adjusting it with each value in
+// turn would leave it, so the last absolute value wins. Other events are
+// returned unchanged.
+func combinePackedEvents(events event.Events) event.Events {
+	if len(events) < 2 {
+		return events
+	}
+
+	switch first := events[0].(type) {
+	case *event.CounterEvent:
+		combined := *first
+		combined.CValue = 0
+		for _, e := range events {
+			combined.CValue += e.Value()
+			if c, ok := e.(*event.CounterEvent); ok && c.CSampled {
+				combined.CSampled = true
+			}
+		}
+		return event.Events{&combined}
+	case *event.GaugeEvent:
+		combined := *first
+		combined.GValue = 0
+		combined.GRelative = true
+		for _, e := range events {
+			if g, ok := e.(*event.GaugeEvent); ok && !g.GRelative {
+				combined.GValue = g.GValue
+				combined.GRelative = false
+			} else {
+				combined.GValue += e.Value()
+			}
+		}
+		return event.Events{&combined}
+	default:
+		return events
+	}
+}
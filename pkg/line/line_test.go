@@ -24,11 +24,12 @@ import (
 )
 
 var (
-	nopSamplesReceived = prometheus.NewCounter(
+	nopSamplesReceived = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_samples_total",
 			Help: "The total number of StatsD samples received.",
 		},
+		[]string{"type"},
 	)
 	nopSampleErrors = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -106,7 +107,8 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo",
-					OValue:      0.2,
+					OValue:      200,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -137,26 +139,7 @@ func TestLineToEvents(t *testing.T) {
 				&event.ObserverEvent{
 					OMetricName: "foo",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
+					OCount:      5,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -337,6 +320,7 @@ func TestLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -363,26 +347,7 @@ func TestLineToEvents(t *testing.T) {
 				&event.ObserverEvent{
 					OMetricName: "foo",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
+					OCount:      5,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -393,6 +358,7 @@ func TestLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "foo:bar"},
 				},
 			},
@@ -408,32 +374,38 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -443,32 +415,38 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -478,62 +456,44 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -543,62 +503,44 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -673,20 +615,83 @@ func TestLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
+		"datadog counter with packed values": {
 			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo_counter",
+					CValue:      23130.51,
+					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				},
+			},
+		},
+		"datadog counter with packed values and sampling": {
+			in: "foo_counter:1:2:3|c|@0.5",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo_counter",
+					CValue:      12,
+					CSampled:    true,
+					CLabels:     map[string]string{},
+				},
+			},
 		},
-		"datadog gauge with invalid extended aggregation values": {
+		"datadog gauge with packed values": {
 			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.GaugeEvent{
+					GMetricName: "foo_gauge",
+					GValue:      0.01,
+					GLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				},
+			},
+		},
+		"datadog gauge with packed absolute and relative values": {
+			in: "foo_gauge:+1:5:+2:-1|g",
+			out: event.Events{
+				&event.GaugeEvent{
+					GMetricName: "foo_gauge",
+					GValue:      6,
+					GLabels:     map[string]string{},
+				},
+			},
+		},
+		"datadog gauge with packed relative values": {
+			in: "foo_gauge:+1:+2:-4|g",
+			out: event.Events{
+				&event.GaugeEvent{
+					GMetricName: "foo_gauge",
+					GValue:      -1,
+					GRelative:   true,
+					GLabels:     map[string]string{},
+				},
+			},
+		},
+		"datadog set with invalid extended aggregation values": {
+			in: "foo_set:1:2|s",
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with Datadog style packed values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo.test",
+					CValue:      23130.51,
+					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				},
+			},
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with Datadog style packed values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo.test",
+					CValue:      23130.51,
+					CLabels:     map[string]string{},
+				},
+			},
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|ms",
@@ -702,52 +707,9 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      10,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -798,7 +760,8 @@ func TestLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo",
-					OValue:      0.2,
+					OValue:      200,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -839,7 +802,7 @@ func TestLineToEvents(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
 
 			for j, expected := range testCase.out {
 				if !reflect.DeepEqual(&expected, &events[j]) {
@@ -850,6 +813,23 @@ func TestLineToEvents(t *testing.T) {
 	}
 }
 
+func TestGaugeLiteralNegative(t *testing.T) {
+	parser := NewParser()
+	parser.GaugeLiteralNegative = true
+
+	for in, expected := range map[string]event.Event{
+		"temperature:-5|g": &event.GaugeEvent{GMetricName: "temperature", GValue: -5, GLabels: map[string]string{}},
+		"temperature:+5|g": &event.GaugeEvent{GMetricName: "temperature", GValue: 5, GRelative: true, GLabels: map[string]string{}},
+		"temperature:5|g":  &event.GaugeEvent{GMetricName: "temperature", GValue: 5, GLabels: map[string]string{}},
+		"requests:-5|c":    &event.CounterEvent{CMetricName: "requests", CValue: -5, CLabels: map[string]string{}},
+	} {
+		events := parser.LineToEvents(in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 || !reflect.DeepEqual(events[0], expected) {
+			t.Errorf("Expected %q to produce %#v, got %#v", in, expected, events)
+		}
+	}
+}
+
 func TestDisableParsingLineToEvents(t *testing.T) {
 	type testCase struct {
 		in  string
@@ -1033,6 +1013,7 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{},
 				},
 			},
@@ -1042,32 +1023,38 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -1077,32 +1064,38 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -1112,62 +1105,44 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -1177,62 +1152,44 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -1307,19 +1264,19 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
+		"datadog counter with packed values": {
 			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
 		},
-		"datadog gauge with invalid extended aggregation values": {
+		"datadog gauge with packed values": {
 			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with Datadog style packed values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with Datadog style packed values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
@@ -1353,6 +1310,7 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{},
 				},
 			},
@@ -1369,7 +1327,7 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
 
 			for j, expected := range testCase.out {
 				if !reflect.DeepEqual(&expected, &events[j]) {
@@ -1563,6 +1521,7 @@ func TestDisableParsingDogstatsdLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{},
 				},
 			},
@@ -1572,197 +1531,173 @@ func TestDisableParsingDogstatsdLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
 		},
-		"datadog timings with extended aggregation values and sampling but without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
+		"datadog timings with extended aggregation values without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
+			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
 		},
-		"datadog timings with extended aggregation values, sampling, and tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
+		"datadog timings with extended aggregation values and sampling but without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      0.01,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
+			},
+		},
+		"datadog timings with extended aggregation values, sampling, and tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
+			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -1837,19 +1772,19 @@ func TestDisableParsingDogstatsdLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
+		"datadog counter with packed values": {
 			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
 		},
-		"datadog gauge with invalid extended aggregation values": {
+		"datadog gauge with packed values": {
 			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with Datadog style packed values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with Datadog style packed values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
@@ -1883,6 +1818,7 @@ func TestDisableParsingDogstatsdLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{},
 				},
 			},
@@ -1902,7 +1838,7 @@ func TestDisableParsingDogstatsdLineToEvents(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
 
 			for j, expected := range testCase.out {
 				if !reflect.DeepEqual(&expected, &events[j]) {
@@ -2096,6 +2032,7 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2105,32 +2042,38 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2140,32 +2083,38 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -2175,62 +2124,44 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -2240,62 +2171,44 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2370,19 +2283,19 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
+		"datadog counter with packed values": {
 			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
 		},
-		"datadog gauge with invalid extended aggregation values": {
+		"datadog gauge with packed values": {
 			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with Datadog style packed values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with Datadog style packed values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
@@ -2416,6 +2329,7 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "foo:bar"},
 				},
 			},
@@ -2435,7 +2349,7 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
 
 			for j, expected := range testCase.out {
 				if !reflect.DeepEqual(&expected, &events[j]) {
@@ -2629,6 +2543,7 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2638,32 +2553,38 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2673,32 +2594,38 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -2708,62 +2635,44 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -2773,62 +2682,44 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2903,19 +2794,19 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
+		"datadog counter with packed values": {
 			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
 		},
-		"datadog gauge with invalid extended aggregation values": {
+		"datadog gauge with packed values": {
 			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with Datadog style packed values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with Datadog style packed values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
@@ -2949,6 +2840,7 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "foo:bar"},
 				},
 			},
@@ -2968,7 +2860,7 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
 
 			for j, expected := range testCase.out {
 				if !reflect.DeepEqual(&expected, &events[j]) {
@@ -3162,6 +3054,7 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -3171,32 +3064,38 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -3206,32 +3105,38 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
+					OValue:      0.5,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      10,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValue:      20000,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValue:      0.01,
+					OTimer:      true,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -3241,62 +3146,44 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{},
 				},
 			},
@@ -3306,62 +3193,44 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      0.5,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.120,
+					OValue:      120,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      3000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      3,
+					OValue:      10,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.01,
+					OValue:      20000,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 				&event.ObserverEvent{
 					OMetricName: "foo_timing",
 					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OTimer:      true,
+					OCount:      2,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -3436,19 +3305,19 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
+		"datadog counter with packed values": {
 			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
 		},
-		"datadog gauge with invalid extended aggregation values": {
+		"datadog gauge with packed values": {
 			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with Datadog style packed values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with Datadog style packed values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
@@ -3482,6 +3351,7 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 				&event.CounterEvent{
 					CMetricName: "foo",
 					CValue:      1000,
+					CSampled:    true,
 					CLabels:     map[string]string{"tag1": "foo:bar"},
 				},
 			},
@@ -3501,7 +3371,7 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
 
 			for j, expected := range testCase.out {
 				if !reflect.DeepEqual(&expected, &events[j]) {
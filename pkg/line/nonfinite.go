@@ -0,0 +1,70 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"log/slog"
+	"math"
+)
+
+// NonFinitePolicy is how the parser handles samples whose value is NaN or
+// infinite, such as `foo:NaN|g` or `foo:+Inf|c`.
+type NonFinitePolicy string
+
+const (
+	// NonFinitePropagate passes NaN and infinite values on as they are,
+	// except NaN observations, which would corrupt histograms and summaries
+	// and are always dropped. It is the default.
+	NonFinitePropagate NonFinitePolicy = "propagate"
+	// NonFiniteDrop discards samples with NaN or infinite values.
+	NonFiniteDrop NonFinitePolicy = "drop"
+	// NonFiniteClamp replaces infinite values with the largest finite value
+	// of the same sign, and discards samples with NaN values.
+	NonFiniteClamp NonFinitePolicy = "clamp"
+)
+
+// NonFinitePolicies returns the names of all policies.
+func NonFinitePolicies() []string {
+	return []string{string(NonFinitePropagate), string(NonFiniteDrop), string(NonFiniteClamp)}
+}
+
+// nonFiniteValue applies the policy to a NaN or infinite value. It returns
+// the value to use and whether to keep the sample.
+func (p *Parser) nonFiniteValue(value float64, statType string, logger *slog.Logger, line string) (float64, bool) {
+	reason := "inf"
+	if math.IsNaN(value) {
+		reason = "nan"
+	}
+
+	action := "propagated"
+	switch {
+	case p.NonFinite == NonFiniteDrop:
+		action = "dropped"
+	case reason == "nan" && (p.NonFinite == NonFiniteClamp || isObserverType(statType)):
+		action = "dropped"
+	case p.NonFinite == NonFiniteClamp:
+		action = "clamped"
+		value = math.Copysign(math.MaxFloat64, value)
+	}
+
+	logger.Debug("Non-finite value", "reason", reason, "action", action, "line", line)
+	if p.NonFiniteValues != nil {
+		p.NonFiniteValues.WithLabelValues(reason, action).Inc()
+	}
+	return value, action != "dropped"
+}
+
+func isObserverType(statType string) bool {
+	return statType == "ms" || statType == "h" || statType == "d"
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNonFiniteValues(t *testing.T) {
+	scenarios := []struct {
+		policy NonFinitePolicy
+		in     string
+		// values are the values of the resulting events; an empty list means
+		// the sample was dropped.
+		values []float64
+		reason string
+		action string
+	}{
+		{policy: NonFinitePropagate, in: "foo:NaN|g", values: []float64{math.NaN()}, reason: "nan", action: "propagated"},
+		{policy: NonFinitePropagate, in: "foo:+Inf|c", values: []float64{math.Inf(1)}, reason: "inf", action: "propagated"},
+		{policy: NonFinitePropagate, in: "foo:-Inf|ms", values: []float64{math.Inf(-1) / 1000}, reason: "inf", action: "propagated"},
+		{policy: NonFinitePropagate, in: "foo:NaN|ms", reason: "nan", action: "dropped"},
+		{policy: NonFinitePropagate, in: "foo:NaN|h", reason: "nan", action: "dropped"},
+		{policy: NonFiniteDrop, in: "foo:NaN|g", reason: "nan", action: "dropped"},
+		{policy: NonFiniteDrop, in: "foo:Inf|c", reason: "inf", action: "dropped"},
+		{policy: NonFiniteClamp, in: "foo:NaN|c", reason: "nan", action: "dropped"},
+		{policy: NonFiniteClamp, in: "foo:+Inf|g", values: []float64{math.MaxFloat64}, reason: "inf", action: "clamped"},
+		{policy: NonFiniteClamp, in: "foo:-Inf|h", values: []float64{-math.MaxFloat64}, reason: "inf", action: "clamped"},
+		{policy: NonFiniteClamp, in: "foo:1|g", values: []float64{1}},
+	}
+
+	for _, s := range scenarios {
+		t.Run(string(s.policy)+" "+s.in, func(t *testing.T) {
+			nonFiniteValues := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "non_finite_values_total"}, []string{"reason", "action"})
+			parser := NewParser()
+			parser.NonFinite = s.policy
+			parser.NonFiniteValues = nonFiniteValues
+
+			events := parser.LineToEvents(s.in, *nopSampleErrors, *nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if len(events) != len(s.values) {
+				t.Fatalf("Expected %d events, got %d", len(s.values), len(events))
+			}
+			for i, e := range events {
+				got, want := e.Value(), s.values[i]
+				if math.IsNaN(want) && math.IsNaN(got) {
+					continue
+				}
+				if got != want {
+					t.Fatalf("Expected value %v, got %v", want, got)
+				}
+			}
+
+			if s.reason == "" {
+				if n := testutil.CollectAndCount(nonFiniteValues); n != 0 {
+					t.Fatalf("Expected no non-finite values to be counted, got %d series", n)
+				}
+				return
+			}
+			if n := testutil.ToFloat64(nonFiniteValues.WithLabelValues(s.reason, s.action)); n != 1 {
+				t.Fatalf("Expected 1 %s value to be %s, got %v", s.reason, s.action, n)
+			}
+		})
+	}
+}
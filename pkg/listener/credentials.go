@@ -0,0 +1,42 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"strconv"
+)
+
+// CredentialFields are the fields of PeerCredentials that can be added to
+// the events of a Unixgram listener as labels.
+var CredentialFields = []string{"uid", "gid", "pid"}
+
+// PeerCredentials are the credentials of the process that sent a datagram,
+// as reported by the kernel.
+type PeerCredentials struct {
+	PID      int32
+	UID, GID uint32
+}
+
+// Field returns the value of one of the CredentialFields.
+func (c *PeerCredentials) Field(name string) string {
+	switch name {
+	case "uid":
+		return strconv.FormatUint(uint64(c.UID), 10)
+	case "gid":
+		return strconv.FormatUint(uint64(c.GID), 10)
+	case "pid":
+		return strconv.FormatInt(int64(c.PID), 10)
+	}
+	return ""
+}
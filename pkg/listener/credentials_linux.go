@@ -0,0 +1,61 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package listener
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// credentialsOOBSize is the size of the control message carrying the
+// credentials of a datagram.
+var credentialsOOBSize = unix.CmsgSpace(unix.SizeofUcred)
+
+// enablePeerCredentials makes the kernel attach the credentials of the
+// sending process to every datagram received on the connection.
+func enablePeerCredentials(c *net.UnixConn) error {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PASSCRED, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// readWithCredentials reads a datagram and the credentials of its sender. The
+// credentials are nil if the datagram carries none.
+func readWithCredentials(c *net.UnixConn, buf, oob []byte) (int, *PeerCredentials, error) {
+	n, oobn, _, _, err := c.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return n, nil, err
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return n, nil, nil
+	}
+	for i := range msgs {
+		if ucred, err := unix.ParseUnixCredentials(&msgs[i]); err == nil {
+			return n, &PeerCredentials{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, nil
+		}
+	}
+	return n, nil, nil
+}
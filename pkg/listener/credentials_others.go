@@ -0,0 +1,32 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package listener
+
+import (
+	"errors"
+	"net"
+)
+
+const credentialsOOBSize = 0
+
+func enablePeerCredentials(c *net.UnixConn) error {
+	return errors.New("peer credentials of Unixgram datagrams are only supported on Linux")
+}
+
+func readWithCredentials(c *net.UnixConn, buf, oob []byte) (int, *PeerCredentials, error) {
+	n, _, err := c.ReadFromUnix(buf)
+	return n, nil, err
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// MaxFirehoseRequestSize is the largest request body, after decompression,
+// that the Firehose listener accepts. Firehose buffers at most 64 MiB per
+// request.
+const MaxFirehoseRequestSize = 64 << 20
+
+// StatsDFirehoseListener receives statsd lines in the records of Amazon Data
+// Firehose HTTP endpoint deliveries, as sent by Firehose streams and Lambda
+// extensions. The data of every record holds newline separated lines.
+//
+// A request is acknowledged only once all its lines have been queued. A
+// request is checked and decoded completely before any of its lines are
+// queued, so if it is rejected, none of its records have been processed and
+// Firehose can deliver all of them again.
+type StatsDFirehoseListener struct {
+	EventHandler    event.EventHandler
+	Logger          *slog.Logger
+	LineParser      Parser
+	LinesReceived   prometheus.Counter
+	EventsFlushed   prometheus.Counter
+	Relay           Relayer
+	SampleErrors    prometheus.CounterVec
+	SamplesReceived prometheus.CounterVec
+	TagErrors       prometheus.Counter
+	TagsReceived    prometheus.Counter
+	// AccessKey, if set, must be sent by Firehose in the
+	// X-Amz-Firehose-Access-Key header.
+	AccessKey string
+	// HighWaterMark, if positive, rejects requests while the event handler
+	// reports at least this many batches waiting to be processed, so that
+	// Firehose retries them later.
+	HighWaterMark int
+	// Requests counts the requests by the HTTP status code of the response.
+	Requests *prometheus.CounterVec
+	Records  prometheus.Counter
+}
+
+// firehoseRequest is the body of a Firehose HTTP endpoint delivery.
+type firehoseRequest struct {
+	RequestID string `json:"requestId"`
+	Timestamp int64  `json:"timestamp"`
+	Records   []struct {
+		Data []byte `json:"data"`
+	} `json:"records"`
+}
+
+// firehoseResponse is the response Firehose expects. ErrorMessage is only set
+// if the request failed.
+type firehoseResponse struct {
+	RequestID    string `json:"requestId"`
+	Timestamp    int64  `json:"timestamp"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+func (l *StatsDFirehoseListener) SetEventHandler(eh event.EventHandler) {
+	l.EventHandler = eh
+}
+
+func (l *StatsDFirehoseListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Amz-Firehose-Request-Id")
+	logger := l.Logger.With("peer", r.RemoteAddr, "request_id", requestID)
+
+	code, err := l.handleRequest(r, &requestID, logger)
+	if err != nil {
+		logger.Debug("Rejecting Firehose request", "status", code, "error", err)
+	}
+	if l.Requests != nil {
+		l.Requests.WithLabelValues(strconv.Itoa(code)).Inc()
+	}
+
+	resp := firehoseResponse{RequestID: requestID, Timestamp: clock.Now().UnixMilli()}
+	if err != nil {
+		resp.ErrorMessage = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleRequest queues the lines of a request. It returns the HTTP status
+// code of the response, and the reason if the request is rejected. The
+// request ID is taken from the body if the header did not have it.
+func (l *StatsDFirehoseListener) handleRequest(r *http.Request, requestID *string, logger *slog.Logger) (int, error) {
+	if r.Method != http.MethodPost {
+		return http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	if l.AccessKey != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Amz-Firehose-Access-Key")), []byte(l.AccessKey)) != 1 {
+		return http.StatusUnauthorized, fmt.Errorf("invalid access key")
+	}
+	if l.HighWaterMark > 0 {
+		if b, ok := l.EventHandler.(event.BacklogReporter); ok && b.Backlog() >= l.HighWaterMark {
+			return http.StatusServiceUnavailable, fmt.Errorf("event queue is above the high-water mark")
+		}
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+	var req firehoseRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, io.NopCloser(body), MaxFirehoseRequestSize)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes", tooLarge.Limit)
+		}
+		return http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err)
+	}
+	if *requestID == "" {
+		*requestID = req.RequestID
+	}
+
+	debug := logger.Enabled(context.Background(), slog.LevelDebug)
+	for _, record := range req.Records {
+		if l.Records != nil {
+			l.Records.Inc()
+		}
+		relayLines := relayPacket(l.Relay, record.Data)
+		scanPacket(record.Data, 0, 0, func(line string) {
+			if len(line) == 0 {
+				return
+			}
+			if debug {
+				logger.Debug("Incoming line", "proto", "firehose", "line", line)
+			}
+			l.LinesReceived.Inc()
+			if relayLines {
+				l.Relay.RelayLine(line)
+			}
+			l.EventHandler.Queue(l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, logger))
+		})
+	}
+	return http.StatusOK, nil
+}
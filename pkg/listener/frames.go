@@ -0,0 +1,49 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// frameHeaderLength is the length of the little-endian payload length that
+// precedes every frame of the DogStatsD Unix socket protocol.
+const frameHeaderLength = 4
+
+// isBinary reports whether a datagram is not plain StatsD text. Text lines
+// never contain NUL bytes, while the header of every frame shorter than
+// 16 MiB does.
+func isBinary(packet []byte) bool {
+	return bytes.IndexByte(packet, 0) >= 0
+}
+
+// decodeFrames splits a datagram into the payloads of its length-prefixed
+// frames. It reports false if the frames do not add up to the datagram.
+func decodeFrames(packet []byte) ([][]byte, bool) {
+	var payloads [][]byte
+	for len(packet) > 0 {
+		if len(packet) < frameHeaderLength {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(packet)
+		packet = packet[frameHeaderLength:]
+		if uint64(n) > uint64(len(packet)) {
+			return nil, false
+		}
+		payloads = append(payloads, packet[:n])
+		packet = packet[n:]
+	}
+	return payloads, true
+}
@@ -15,20 +15,68 @@ package listener
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"net/netip"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/prometheus/statsd_exporter/pkg/event"
-	"github.com/prometheus/statsd_exporter/pkg/relay"
+	"github.com/prometheus/statsd_exporter/pkg/line"
 )
 
+// backpressurePollInterval is how often a paused TCP connection checks
+// whether the event queue has drained below the high-water mark.
+const backpressurePollInterval = 10 * time.Millisecond
+
+// backpressureWriteTimeout bounds writing the backpressure line to a client if
+// no slow client timeout is set. The line is dropped if it is not accepted in
+// time, so that a client that does not read cannot block its connection.
+const backpressureWriteTimeout = time.Second
+
+// tlsHandshakeTimeout bounds the time a TCP client may take to complete the
+// TLS handshake.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// zstdMagic is the frame header that starts every Zstandard stream.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
 type Parser interface {
-	LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events
+	LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events
+}
+
+// Relayer forwards the received lines or packets to other StatsD servers. It
+// is implemented by relay.Relay and relay.Targets.
+type Relayer interface {
+	RelaysPackets() bool
+	RelayPacket(packet []byte)
+	RelayLine(line string)
+}
+
+// sourceParser returns the parser for the lines of a source, which differs
+// from p if p implements line.SourceFormat.
+func sourceParser(p Parser, source netip.AddrPort) Parser {
+	if sf, ok := p.(line.SourceFormat); ok {
+		return sf.ForSource(source)
+	}
+	return p
+}
+
+// UDPPacket is a packet waiting in the packet queue of a UDP listener.
+type UDPPacket struct {
+	Data []byte
+	// Source is the address the packet was received from, if known.
+	Source netip.AddrPort
 }
 
 type StatsDUDPListener struct {
@@ -40,66 +88,119 @@ type StatsDUDPListener struct {
 	UDPPacketDrops  prometheus.Counter
 	LinesReceived   prometheus.Counter
 	EventsFlushed   prometheus.Counter
-	Relay           *relay.Relay
+	Relay           Relayer
 	SampleErrors    prometheus.CounterVec
-	SamplesReceived prometheus.Counter
+	SamplesReceived prometheus.CounterVec
 	TagErrors       prometheus.Counter
 	TagsReceived    prometheus.Counter
-	UdpPacketQueue  chan []byte
+	UdpPacketQueue  chan UDPPacket
+	// MaxLineLength and MaxPacketLines, if positive, limit the length of
+	// lines and the number of lines per packet. Lines beyond the limits are
+	// discarded without being parsed.
+	MaxLineLength  int
+	MaxPacketLines int
+	LineTooLong    prometheus.Counter
+	ExcessLines    prometheus.Counter
+	// AllowedSources, if not empty, limits the addresses packets are accepted
+	// from. Other packets are dropped without being parsed or relayed.
+	AllowedSources  AllowedSources
+	RejectedPackets prometheus.Counter
+	// TagHeaders configures the handling of DogStatsD tag headers.
+	TagHeaders TagHeaders
 }
 
 func (l *StatsDUDPListener) SetEventHandler(eh event.EventHandler) {
 	l.EventHandler = eh
 }
 
-func (l *StatsDUDPListener) Listen() {
+// Listen reads packets from the connection until the context is cancelled,
+// which closes the connection, or reading fails. It only returns an error in
+// the latter case.
+func (l *StatsDUDPListener) Listen(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() { l.Conn.Close() })
+	defer stop()
+
 	buf := make([]byte, 65535)
-	go l.ProcessUdpPacketQueue()
+	go l.ProcessUdpPacketQueue(ctx)
 	for {
-		n, _, err := l.Conn.ReadFromUDP(buf)
+		n, addr, err := l.Conn.ReadFromUDPAddrPort(buf)
 		if err != nil {
-			// https://github.com/golang/go/issues/4373
-			// ignore net: errClosing error as it will occur during shutdown
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
+			if errors.Is(err, net.ErrClosed) {
+				return nil
 			}
-			l.Logger.Error("error reading from UDP connection", "err", err)
-			return
+			return fmt.Errorf("error reading from UDP connection: %w", err)
+		}
+		if !l.AllowedSources.Allows(addr.Addr()) {
+			l.RejectedPackets.Inc()
+			l.Logger.Debug("Dropping packet from source that is not allowed", "peer", addr)
+			continue
 		}
 
-		l.EnqueueUdpPacket(buf, n)
+		l.enqueueUdpPacket(buf, n, addr)
 	}
 }
 
 func (l *StatsDUDPListener) EnqueueUdpPacket(packet []byte, n int) {
+	l.enqueueUdpPacket(packet, n, netip.AddrPort{})
+}
+
+func (l *StatsDUDPListener) enqueueUdpPacket(packet []byte, n int, source netip.AddrPort) {
 	l.UDPPackets.Inc()
-	packetCopy := make([]byte, n)
+	packetCopy := getPacketBuffer(n)
 	copy(packetCopy, packet)
 	select {
-	case l.UdpPacketQueue <- packetCopy:
+	case l.UdpPacketQueue <- UDPPacket{Data: packetCopy, Source: source}:
 		// do nothing
 	default:
+		putPacketBuffer(packetCopy)
 		l.UDPPacketDrops.Inc()
 	}
 }
 
-func (l *StatsDUDPListener) ProcessUdpPacketQueue() {
+// ProcessUdpPacketQueue handles the packets in the packet queue until the
+// context is cancelled.
+func (l *StatsDUDPListener) ProcessUdpPacketQueue(ctx context.Context) {
 	for {
-		packet := <-l.UdpPacketQueue
-		l.HandlePacket(packet)
+		select {
+		case packet := <-l.UdpPacketQueue:
+			l.handlePacket(packet.Data, sourceParser(l.LineParser, packet.Source))
+			putPacketBuffer(packet.Data)
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
 func (l *StatsDUDPListener) HandlePacket(packet []byte) {
-	lines := strings.Split(string(packet), "\n")
-	for _, line := range lines {
-		l.Logger.Debug("Incoming line", "proto", "udp", "line", line)
+	l.handlePacket(packet, l.LineParser)
+}
+
+func (l *StatsDUDPListener) handlePacket(packet []byte, parser Parser) {
+	relayLines := relayPacket(l.Relay, packet)
+	packet, parser = l.TagHeaders.split(packet, parser, l.SampleErrors, l.TagErrors, l.Logger)
+	lines := 0
+	tooLong, excess := scanPacket(packet, l.MaxLineLength, l.MaxPacketLines, func(line string) {
+		if l.Logger.Enabled(context.Background(), slog.LevelDebug) {
+			l.Logger.Debug("Incoming line", "proto", "udp", "line", line)
+		}
+		if len(line) > 0 {
+			lines++
+		}
 		l.LinesReceived.Inc()
-		if l.Relay != nil && len(line) > 0 {
+		if relayLines && len(line) > 0 {
 			l.Relay.RelayLine(line)
 		}
-		l.EventHandler.Queue(l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+		l.EventHandler.Queue(parser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+	})
+	if tooLong > 0 {
+		l.LineTooLong.Add(float64(tooLong))
+		l.Logger.Debug("Discarded lines that are too long", "proto", "udp", "lines", tooLong)
 	}
+	if excess > 0 {
+		l.ExcessLines.Add(float64(excess))
+		l.Logger.Debug("Discarded lines beyond the maximum per packet", "proto", "udp", "lines", excess)
+	}
+	l.TagHeaders.observeLines(lines)
 }
 
 type StatsDTCPListener struct {
@@ -109,63 +210,266 @@ type StatsDTCPListener struct {
 	LineParser      Parser
 	LinesReceived   prometheus.Counter
 	EventsFlushed   prometheus.Counter
-	Relay           *relay.Relay
+	Relay           Relayer
 	SampleErrors    prometheus.CounterVec
-	SamplesReceived prometheus.Counter
+	SamplesReceived prometheus.CounterVec
 	TagErrors       prometheus.Counter
 	TagsReceived    prometheus.Counter
 	TCPConnections  prometheus.Counter
 	TCPErrors       prometheus.Counter
 	TCPLineTooLong  prometheus.Counter
+	// AcceptZstd enables transparent decompression of connections that
+	// start with a Zstandard frame, as sent by a compressing relay.
+	AcceptZstd bool
+	// HighWaterMark, if positive, pauses reading from connections while the
+	// event handler reports at least this many batches waiting to be
+	// processed. The kernel buffers fill up and TCP flow control slows down
+	// the clients.
+	HighWaterMark int
+	// BackpressureLine, if set, is written to a client when reading from
+	// its connection is paused.
+	BackpressureLine string
+	TCPBackpressure  prometheus.Counter
+	// SlowClientTimeout, if positive, bounds the time writing
+	// BackpressureLine to a client may take. Clients that do not read
+	// their connection are logged and counted as slow, and disconnected if
+	// DisconnectSlowClients is set. Otherwise, the line is dropped if it is
+	// not accepted within a second.
+	SlowClientTimeout     time.Duration
+	DisconnectSlowClients bool
+	TCPSlowClients        prometheus.Counter
+	// Peers, if set, counts lines, bytes and parse errors by peer.
+	Peers *TCPPeers
+	// AllowedSources, if not empty, limits the addresses connections are
+	// accepted from. Other connections are closed right away.
+	AllowedSources      AllowedSources
+	RejectedConnections prometheus.Counter
+	// TLSConfig, if set, makes clients connect with TLS. Connections that
+	// fail the handshake are closed and counted in TLSHandshakeErrors.
+	TLSConfig          *tls.Config
+	TLSHandshakeErrors prometheus.Counter
+	// IdentityLabels, if not empty, labels the events of every connection
+	// with fields of the verified client certificate. The keys are the label
+	// names, and the values the IdentityFields they are set to.
+	IdentityLabels map[string]string
+	// IdentityPrefix, if set, is one of the IdentityFields. Its value in the
+	// client certificate, followed by a dot, is prepended to the names of all
+	// metrics received on the connection. Connections whose certificate does
+	// not have the field are closed.
+	IdentityPrefix string
 }
 
 func (l *StatsDTCPListener) SetEventHandler(eh event.EventHandler) {
 	l.EventHandler = eh
 }
 
-func (l *StatsDTCPListener) Listen() {
+// Listen accepts connections until the context is cancelled, which closes
+// the listener and all connections, or accepting fails. It only returns an
+// error in the latter case.
+func (l *StatsDTCPListener) Listen(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() { l.Conn.Close() })
+	defer stop()
+
 	for {
 		c, err := l.Conn.AcceptTCP()
 		if err != nil {
-			// https://github.com/golang/go/issues/4373
-			// ignore net: errClosing error as it will occur during shutdown
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
+			if errors.Is(err, net.ErrClosed) {
+				return nil
 			}
-			l.Logger.Error("AcceptTCP failed", "error", err)
-			os.Exit(1)
+			return fmt.Errorf("AcceptTCP failed: %w", err)
 		}
-		go l.HandleConn(c)
+		go l.handleConn(ctx, c)
 	}
 }
 
 func (l *StatsDTCPListener) HandleConn(c *net.TCPConn) {
-	defer c.Close()
+	l.handleConn(context.Background(), c)
+}
+
+// handleConn reads lines from a connection until the client closes it or the
+// context is cancelled.
+func (l *StatsDTCPListener) handleConn(ctx context.Context, tc *net.TCPConn) {
+	// Closing the TCP connection rather than a TLS connection on top of it
+	// does not block on sending an alert to a client that does not read.
+	defer tc.Close()
+	stop := context.AfterFunc(ctx, func() { tc.Close() })
+	defer stop()
 
+	var c net.Conn = tc
+
+	logger := l.Logger.With("peer", c.RemoteAddr())
+	if !l.AllowedSources.allowsAddr(c.RemoteAddr()) {
+		l.RejectedConnections.Inc()
+		logger.Debug("Closing connection from source that is not allowed")
+		return
+	}
 	l.TCPConnections.Inc()
+	peer := l.Peers.connect(c.RemoteAddr())
+	defer peer.close()
+
+	parser := l.LineParser
+	if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		parser = sourceParser(parser, addr.AddrPort())
+	}
+
+	if l.TLSConfig != nil {
+		tlsConn, ok := l.handshake(ctx, tc, logger)
+		if !ok {
+			return
+		}
+		c = tlsConn
+		if parser, ok = l.identityParser(parser, tlsConn.ConnectionState(), logger); !ok {
+			return
+		}
+	}
 
 	r := bufio.NewReader(c)
+	if l.AcceptZstd {
+		if magic, err := r.Peek(len(zstdMagic)); err == nil && bytes.Equal(magic, zstdMagic) {
+			zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				l.TCPErrors.Inc()
+				logger.Debug("Unable to create zstd decoder", "error", err)
+				return
+			}
+			defer zr.Close()
+			logger.Debug("Reading zstd compressed stream")
+			r = bufio.NewReader(zr)
+		}
+	}
 	for {
+		if !l.waitForCapacity(ctx, c, peer, logger) {
+			return
+		}
 		line, isPrefix, err := r.ReadLine()
 		if err != nil {
 			if err != io.EOF {
 				l.TCPErrors.Inc()
-				l.Logger.Debug("Read failed", "addr", c.RemoteAddr(), "error", err)
+				logger.Debug("Read failed", "error", err)
 			}
 			break
 		}
-		l.Logger.Debug("Incoming line", "proto", "tcp", "line", string(line))
+		logger.Debug("Incoming line", "proto", "tcp", "line", string(line))
 		if isPrefix {
 			l.TCPLineTooLong.Inc()
-			l.Logger.Debug("Read failed: line too long", "addr", c.RemoteAddr())
+			logger.Debug("Read failed: line too long")
 			break
 		}
 		l.LinesReceived.Inc()
 		if l.Relay != nil && len(line) > 0 {
 			l.Relay.RelayLine(string(line))
 		}
-		l.EventHandler.Queue(l.LineParser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+		events := parser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, logger)
+		peer.line(len(line), len(events))
+		l.EventHandler.Queue(events)
+	}
+}
+
+// handshake completes the TLS handshake of a connection. It returns false if
+// the handshake fails.
+func (l *StatsDTCPListener) handshake(ctx context.Context, c *net.TCPConn, logger *slog.Logger) (*tls.Conn, bool) {
+	tlsConn := tls.Server(c, l.TLSConfig)
+	ctx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		l.TLSHandshakeErrors.Inc()
+		logger.Debug("TLS handshake failed", "error", err)
+		return nil, false
+	}
+	return tlsConn, true
+}
+
+// identityParser returns the parser for the lines of a TLS connection, which
+// labels and prefixes their events with the identity of the client if
+// configured. It returns false if the connection has to be closed because the
+// client certificate does not have the field of IdentityPrefix.
+func (l *StatsDTCPListener) identityParser(parser Parser, state tls.ConnectionState, logger *slog.Logger) (Parser, bool) {
+	if len(l.IdentityLabels) == 0 && l.IdentityPrefix == "" {
+		return parser, true
+	}
+	if len(state.PeerCertificates) == 0 {
+		l.TLSHandshakeErrors.Inc()
+		logger.Debug("Closing TLS connection without a client certificate")
+		return nil, false
+	}
+	cert := state.PeerCertificates[0]
+	if len(l.IdentityLabels) > 0 {
+		labels := make(map[string]string, len(l.IdentityLabels))
+		for label, field := range l.IdentityLabels {
+			labels[label] = identityField(cert, field)
+		}
+		parser = line.NewLabeler(parser, labels)
+	}
+	if l.IdentityPrefix != "" {
+		prefix := identityField(cert, l.IdentityPrefix)
+		if prefix == "" {
+			l.TLSHandshakeErrors.Inc()
+			logger.Debug("Closing TLS connection whose client certificate has no identity", "field", l.IdentityPrefix)
+			return nil, false
+		}
+		parser = &prefixParser{Parser: parser, prefix: prefix + "."}
 	}
+	return parser, true
+}
+
+// waitForCapacity blocks while the event handler is above the high-water mark.
+// It returns false if the context is cancelled while waiting, or if the client
+// is too slow to accept the backpressure line and slow clients are
+// disconnected.
+func (l *StatsDTCPListener) waitForCapacity(ctx context.Context, c net.Conn, peer *peerCounters, logger *slog.Logger) bool {
+	if l.HighWaterMark <= 0 {
+		return true
+	}
+	b, ok := l.EventHandler.(event.BacklogReporter)
+	if !ok || b.Backlog() < l.HighWaterMark {
+		return true
+	}
+
+	l.TCPBackpressure.Inc()
+	logger.Debug("Event queue above high-water mark, pausing connection", "backlog", b.Backlog())
+	if l.BackpressureLine != "" && !l.writeBackpressureLine(c, peer, logger) {
+		return false
+	}
+	for b.Backlog() >= l.HighWaterMark {
+		select {
+		case <-time.After(backpressurePollInterval):
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// writeBackpressureLine notifies a client that reading from its connection is
+// paused. It returns false if the client is too slow to accept the line and
+// slow clients are disconnected.
+func (l *StatsDTCPListener) writeBackpressureLine(c net.Conn, peer *peerCounters, logger *slog.Logger) bool {
+	timeout := l.SlowClientTimeout
+	if timeout <= 0 {
+		timeout = backpressureWriteTimeout
+	}
+	c.SetWriteDeadline(time.Now().Add(timeout))
+	defer c.SetWriteDeadline(time.Time{})
+	_, err := c.Write([]byte(l.BackpressureLine + "\n"))
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		logger.Debug("Unable to notify client of backpressure", "error", err)
+		return true
+	}
+	if l.SlowClientTimeout <= 0 {
+		logger.Debug("Client does not read the backpressure line, dropping it", "timeout", timeout)
+		return true
+	}
+	l.TCPSlowClients.Inc()
+	peer.slowWrite()
+	if l.DisconnectSlowClients {
+		logger.Warn("Disconnecting slow client that does not read the backpressure line", "timeout", l.SlowClientTimeout)
+		return false
+	}
+	logger.Warn("Slow client does not read the backpressure line", "timeout", l.SlowClientTimeout)
+	return true
 }
 
 type StatsDUnixgramListener struct {
@@ -176,43 +480,209 @@ type StatsDUnixgramListener struct {
 	UnixgramPackets prometheus.Counter
 	LinesReceived   prometheus.Counter
 	EventsFlushed   prometheus.Counter
-	Relay           *relay.Relay
+	Relay           Relayer
 	SampleErrors    prometheus.CounterVec
-	SamplesReceived prometheus.Counter
+	SamplesReceived prometheus.CounterVec
 	TagErrors       prometheus.Counter
 	TagsReceived    prometheus.Counter
+	// MaxLineLength and MaxPacketLines, if positive, limit the length of
+	// lines and the number of lines per datagram. Lines beyond the limits
+	// are discarded without being parsed.
+	MaxLineLength  int
+	MaxPacketLines int
+	LineTooLong    prometheus.Counter
+	ExcessLines    prometheus.Counter
+	// AcceptFrames enables decoding datagrams of length-prefixed frames, as
+	// sent by DogStatsD clients, alongside plain StatsD text. Binary
+	// datagrams that are not made up of frames are dropped.
+	AcceptFrames  bool
+	Frames        prometheus.Counter
+	UnknownFrames prometheus.Counter
+	// CredentialLabels, if not empty, labels the events of every datagram
+	// with the credentials of the process that sent it. The keys are label
+	// names, and the values the CredentialFields they are set to.
+	// LinesByUID, if set, counts the lines received by the user ID of the
+	// sending process. Both are only supported on Linux.
+	CredentialLabels map[string]string
+	LinesByUID       *prometheus.CounterVec
+	// TagHeaders configures the handling of DogStatsD tag headers, which
+	// can start every datagram or frame.
+	TagHeaders TagHeaders
 }
 
 func (l *StatsDUnixgramListener) SetEventHandler(eh event.EventHandler) {
 	l.EventHandler = eh
 }
 
-func (l *StatsDUnixgramListener) Listen() {
+// Listen reads datagrams from the connection until the context is cancelled,
+// which closes the connection, or reading fails. It only returns an error in
+// the latter case.
+func (l *StatsDUnixgramListener) Listen(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() { l.Conn.Close() })
+	defer stop()
+
+	withCredentials := len(l.CredentialLabels) > 0 || l.LinesByUID != nil
+	var oob []byte
+	if withCredentials {
+		if err := enablePeerCredentials(l.Conn); err != nil {
+			return fmt.Errorf("unable to receive peer credentials on unixgram connection: %w", err)
+		}
+		oob = make([]byte, credentialsOOBSize)
+	}
+
 	buf := make([]byte, 65535)
 	for {
-		n, _, err := l.Conn.ReadFromUnix(buf)
+		var (
+			n     int
+			creds *PeerCredentials
+			err   error
+		)
+		if withCredentials {
+			n, creds, err = readWithCredentials(l.Conn, buf, oob)
+		} else {
+			n, _, err = l.Conn.ReadFromUnix(buf)
+		}
 		if err != nil {
-			// https://github.com/golang/go/issues/4373
-			// ignore net: errClosing error as it will occur during shutdown
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
+			if errors.Is(err, net.ErrClosed) {
+				return nil
 			}
-			l.Logger.Error("error reading from unixgram connection", "err", err)
-			os.Exit(1)
+			return fmt.Errorf("error reading from unixgram connection: %w", err)
 		}
-		l.HandlePacket(buf[:n])
+		l.handlePacket(buf[:n], creds)
 	}
 }
 
 func (l *StatsDUnixgramListener) HandlePacket(packet []byte) {
+	l.handlePacket(packet, nil)
+}
+
+// handlePacket handles a datagram sent by a process with the given
+// credentials, if known.
+func (l *StatsDUnixgramListener) handlePacket(packet []byte, creds *PeerCredentials) {
 	l.UnixgramPackets.Inc()
-	lines := strings.Split(string(packet), "\n")
-	for _, line := range lines {
-		l.Logger.Debug("Incoming line", "proto", "unixgram", "line", line)
+	parser := l.LineParser
+	var uidLines prometheus.Counter
+	if creds != nil {
+		if len(l.CredentialLabels) > 0 {
+			labels := make(map[string]string, len(l.CredentialLabels))
+			for label, field := range l.CredentialLabels {
+				labels[label] = creds.Field(field)
+			}
+			parser = line.NewLabeler(parser, labels)
+		}
+		if l.LinesByUID != nil {
+			uidLines = l.LinesByUID.WithLabelValues(creds.Field("uid"))
+		}
+	}
+
+	if l.AcceptFrames && isBinary(packet) {
+		payloads, ok := decodeFrames(packet)
+		if !ok {
+			l.UnknownFrames.Inc()
+			l.Logger.Debug("Dropping binary datagram that is not made up of frames", "proto", "unixgram", "length", len(packet))
+			return
+		}
+		for _, payload := range payloads {
+			l.Frames.Inc()
+			l.handleLines(payload, parser, uidLines)
+		}
+		return
+	}
+	l.handleLines(packet, parser, uidLines)
+}
+
+// handleLines handles the StatsD lines of a datagram or frame. If uidLines is
+// not nil, the lines are also counted in it.
+func (l *StatsDUnixgramListener) handleLines(packet []byte, parser Parser, uidLines prometheus.Counter) {
+	relayLines := relayPacket(l.Relay, packet)
+	packet, parser = l.TagHeaders.split(packet, parser, l.SampleErrors, l.TagErrors, l.Logger)
+	lines := 0
+	tooLong, excess := scanPacket(packet, l.MaxLineLength, l.MaxPacketLines, func(line string) {
+		if l.Logger.Enabled(context.Background(), slog.LevelDebug) {
+			l.Logger.Debug("Incoming line", "proto", "unixgram", "line", line)
+		}
+		if len(line) > 0 {
+			lines++
+		}
 		l.LinesReceived.Inc()
-		if l.Relay != nil && len(line) > 0 {
+		if uidLines != nil {
+			uidLines.Inc()
+		}
+		if relayLines && len(line) > 0 {
 			l.Relay.RelayLine(line)
 		}
-		l.EventHandler.Queue(l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+		l.EventHandler.Queue(parser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+	})
+	if tooLong > 0 {
+		l.LineTooLong.Add(float64(tooLong))
+		l.Logger.Debug("Discarded lines that are too long", "proto", "unixgram", "lines", tooLong)
+	}
+	if excess > 0 {
+		l.ExcessLines.Add(float64(excess))
+		l.Logger.Debug("Discarded lines beyond the maximum per packet", "proto", "unixgram", "lines", excess)
+	}
+	l.TagHeaders.observeLines(lines)
+}
+
+// scanPacket calls handle for each line of a datagram. If maxLength is
+// positive, longer lines are left out. If maxLines is positive, only that many
+// lines are looked at and the rest of the packet is discarded. It returns the
+// number of lines left out for either reason.
+//
+// The lines are scanned on the bytes of the packet. Only the part that is
+// looked at is converted to a string, once, and the lines are substrings of
+// it, so that the events parsed from them do not refer to the packet buffer
+// and no string is allocated per line.
+func scanPacket(packet []byte, maxLength, maxLines int, handle func(line string)) (tooLong, excess int) {
+	end := len(packet)
+	if maxLines > 0 {
+		offset := 0
+		for n := 0; n < maxLines; n++ {
+			i := bytes.IndexByte(packet[offset:], '\n')
+			if i < 0 {
+				offset = -1
+				break
+			}
+			offset += i + 1
+		}
+		if offset >= 0 {
+			// The newline ending the last line looked at starts the excess
+			// lines. A newline ending the packet does not start another one.
+			end = offset - 1
+			if rest := packet[offset:]; len(rest) > 0 {
+				excess = bytes.Count(rest, []byte{'\n'}) + 1
+				if rest[len(rest)-1] == '\n' {
+					excess--
+				}
+			}
+		}
+	}
+
+	rest := string(packet[:end])
+	for {
+		line, next, found := strings.Cut(rest, "\n")
+		if maxLength > 0 && len(line) > maxLength {
+			tooLong++
+		} else {
+			handle(line)
+		}
+		if !found {
+			return tooLong, excess
+		}
+		rest = next
+	}
+}
+
+// relayPacket relays a received packet as a whole, if the relay forwards raw
+// packets. It reports whether the lines of the packet need to be relayed
+// instead.
+func relayPacket(r Relayer, packet []byte) bool {
+	if r == nil {
+		return false
+	}
+	if r.RelaysPackets() {
+		r.RelayPacket(packet)
+		return false
 	}
+	return true
 }
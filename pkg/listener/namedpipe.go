@@ -0,0 +1,86 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// StatsDNamedPipeListener receives newline separated statsd lines on a
+// Windows named pipe. Every client connection is served by its own pipe
+// instance. On other platforms Listen fails.
+type StatsDNamedPipeListener struct {
+	Path            string
+	EventHandler    event.EventHandler
+	Logger          *slog.Logger
+	LineParser      Parser
+	LinesReceived   prometheus.Counter
+	EventsFlushed   prometheus.Counter
+	Relay           Relayer
+	SampleErrors    prometheus.CounterVec
+	SamplesReceived prometheus.CounterVec
+	TagErrors       prometheus.Counter
+	TagsReceived    prometheus.Counter
+	PipeConnections prometheus.Counter
+	PipeErrors      prometheus.Counter
+	PipeLineTooLong prometheus.Counter
+}
+
+func (l *StatsDNamedPipeListener) SetEventHandler(eh event.EventHandler) {
+	l.EventHandler = eh
+}
+
+func (l *StatsDNamedPipeListener) HandleConn(c io.ReadCloser) {
+	l.handleConn(context.Background(), c)
+}
+
+// handleConn reads lines from a pipe connection until the client closes it or
+// the context is cancelled.
+func (l *StatsDNamedPipeListener) handleConn(ctx context.Context, c io.ReadCloser) {
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+
+	l.PipeConnections.Inc()
+
+	r := bufio.NewReader(c)
+	for {
+		line, isPrefix, err := r.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				l.PipeErrors.Inc()
+				l.Logger.Debug("Read failed", "pipe", l.Path, "error", err)
+			}
+			break
+		}
+		l.Logger.Debug("Incoming line", "proto", "pipe", "line", string(line))
+		if isPrefix {
+			l.PipeLineTooLong.Inc()
+			l.Logger.Debug("Read failed: line too long", "pipe", l.Path)
+			break
+		}
+		l.LinesReceived.Inc()
+		if l.Relay != nil && len(line) > 0 {
+			l.Relay.RelayLine(string(line))
+		}
+		l.EventHandler.Queue(l.LineParser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package listener
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+const pipeBufferSize = 65536
+
+// Listen accepts clients of the named pipe until the context is cancelled,
+// which closes all connections, or creating a pipe instance fails. It only
+// returns an error in the latter case.
+func (l *StatsDNamedPipeListener) Listen(ctx context.Context) error {
+	path, err := windows.UTF16PtrFromString(l.Path)
+	if err != nil {
+		return fmt.Errorf("invalid named pipe path %q: %w", l.Path, err)
+	}
+	// ConnectNamedPipe cannot be interrupted, so a client connects to the
+	// waiting pipe instance when the context is cancelled.
+	stop := context.AfterFunc(ctx, func() {
+		if f, err := os.OpenFile(l.Path, os.O_WRONLY, 0); err == nil {
+			f.Close()
+		}
+	})
+	defer stop()
+
+	for ctx.Err() == nil {
+		h, err := windows.CreateNamedPipe(
+			path,
+			windows.PIPE_ACCESS_INBOUND,
+			windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT,
+			windows.PIPE_UNLIMITED_INSTANCES,
+			0,
+			pipeBufferSize,
+			0,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("CreateNamedPipe failed for %q: %w", l.Path, err)
+		}
+		// Blocks until a client opens this pipe instance. A client that
+		// connected between CreateNamedPipe and ConnectNamedPipe is reported
+		// as ERROR_PIPE_CONNECTED and is ready to be read from.
+		err = windows.ConnectNamedPipe(h, nil)
+		if ctx.Err() != nil {
+			windows.CloseHandle(h)
+			return nil
+		}
+		if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			l.PipeErrors.Inc()
+			l.Logger.Debug("ConnectNamedPipe failed", "pipe", l.Path, "error", err)
+			windows.CloseHandle(h)
+			continue
+		}
+		go l.handleConn(ctx, os.NewFile(uintptr(h), l.Path))
+	}
+	return nil
+}
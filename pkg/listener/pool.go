@@ -0,0 +1,39 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import "sync"
+
+// packetBuffers holds the buffers of UDP packets waiting in the packet queue,
+// so that they are reused once the packet has been handled. The buffers grow
+// to the size of the largest packets received, rather than to the size of the
+// read buffer, so that a long queue does not hold on to much memory.
+var packetBuffers = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// getPacketBuffer returns a buffer of length n from the pool.
+func getPacketBuffer(n int) []byte {
+	buf := packetBuffers.Get().(*[]byte)
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+	return (*buf)[:n]
+}
+
+// putPacketBuffer returns a buffer to the pool. The buffer must not be used
+// afterwards.
+func putPacketBuffer(b []byte) {
+	packetBuffers.Put(&b)
+}
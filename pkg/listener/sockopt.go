@@ -0,0 +1,67 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// BufferedConn is a socket whose read buffer can be set, such as a
+// *net.UDPConn or *net.UnixConn.
+type BufferedConn interface {
+	SetReadBuffer(bytes int) error
+	SyscallConn() (syscall.RawConn, error)
+}
+
+// SetReadBuffer asks the operating system for a read buffer of the given size
+// for a UDP or Unixgram socket, and returns the size it granted. Operating
+// systems differ in how they handle sizes beyond their limit: Linux silently
+// caps them, and the BSDs reject them, in which case the largest accepted
+// power-of-two fraction of the size is used. Where the granted size cannot be
+// read back, the requested size is returned.
+func SetReadBuffer(c BufferedConn, size int) (int, error) {
+	return setReadBuffer(c, size)
+}
+
+// ListenUDP listens on a UDP address like net.ListenUDP. With reusePort, the
+// socket is opened with SO_REUSEPORT, so that several processes can receive
+// on the same address, as during a rolling restart. It fails on platforms
+// where ReusePortSupported is false.
+func ListenUDP(addr *net.UDPAddr, reusePort bool) (*net.UDPConn, error) {
+	if !reusePort {
+		return net.ListenUDP("udp", addr)
+	}
+	if !ReusePortSupported {
+		return nil, fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+	}
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = setReusePort(fd)
+			}); err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	pc, err := lc.ListenPacket(context.Background(), "udp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	return pc.(*net.UDPConn), nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package listener
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReusePortSupported reports whether ListenUDP supports SO_REUSEPORT.
+const ReusePortSupported = true
+
+// minReadBuffer is the smallest read buffer setReadBuffer falls back to.
+const minReadBuffer = 4096
+
+// setReadBuffer sets SO_RCVBUF. Sizes beyond kern.ipc.maxsockbuf fail with
+// ENOBUFS rather than being capped, so the size is halved until one is
+// accepted.
+func setReadBuffer(c BufferedConn, size int) (int, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		granted int
+		sockErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		for n := size; ; n /= 2 {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, n)
+			if sockErr == nil {
+				granted = n
+				if actual, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF); err == nil {
+					granted = actual
+				}
+				return
+			}
+			if !errors.Is(sockErr, unix.ENOBUFS) || n/2 < minReadBuffer {
+				return
+			}
+		}
+	}); err != nil {
+		return 0, err
+	}
+	return granted, os.NewSyscallError("setsockopt", sockErr)
+}
+
+func setReusePort(fd uintptr) error {
+	return os.NewSyscallError("setsockopt", unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, reusePortOption, 1))
+}
@@ -0,0 +1,21 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build freebsd
+
+package listener
+
+import "golang.org/x/sys/unix"
+
+// reusePortOption is SO_REUSEPORT_LB, which unlike SO_REUSEPORT spreads the
+// datagrams among the sockets sharing the address.
+const reusePortOption = unix.SO_REUSEPORT_LB
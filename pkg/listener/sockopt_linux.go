@@ -0,0 +1,66 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build linux
+
+package listener
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReusePortSupported reports whether ListenUDP supports SO_REUSEPORT.
+const ReusePortSupported = true
+
+// setReadBuffer sets SO_RCVBUF, which the kernel silently caps at
+// net.core.rmem_max, and falls back to SO_RCVBUFFORCE, which only privileged
+// processes may set, if the cap applies.
+func setReadBuffer(c BufferedConn, size int) (int, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		granted int
+		sockErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		if sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, size); sockErr != nil {
+			return
+		}
+		granted = readBufferSize(int(fd), size)
+		if granted < size && unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUFFORCE, size) == nil {
+			granted = readBufferSize(int(fd), size)
+		}
+	}); err != nil {
+		return 0, err
+	}
+	return granted, os.NewSyscallError("setsockopt", sockErr)
+}
+
+// readBufferSize returns the size of the read buffer of a socket, or the
+// requested size if it cannot be read back.
+func readBufferSize(fd, requested int) int {
+	size, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF)
+	if err != nil {
+		return requested
+	}
+	// The kernel doubles the size to make room for its bookkeeping, and
+	// reports the doubled size.
+	return size / 2
+}
+
+func setReusePort(fd uintptr) error {
+	return os.NewSyscallError("setsockopt", unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1))
+}
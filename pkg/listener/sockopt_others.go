@@ -0,0 +1,30 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package listener
+
+import "errors"
+
+// ReusePortSupported reports whether ListenUDP supports SO_REUSEPORT.
+const ReusePortSupported = false
+
+// setReadBuffer sets the read buffer without reading back the granted size,
+// which is not supported on this platform.
+func setReadBuffer(c BufferedConn, size int) (int, error) {
+	return size, c.SetReadBuffer(size)
+}
+
+func setReusePort(fd uintptr) error {
+	return errors.New("SO_REUSEPORT is not supported")
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// AllowedSources are the networks that UDP packets and TCP connections are
+// accepted from. An empty list accepts all sources.
+type AllowedSources []netip.Prefix
+
+// ParseAllowedSources parses a comma-separated list of CIDR networks. Single
+// addresses are accepted as networks containing only that address.
+func ParseAllowedSources(s string) (AllowedSources, error) {
+	var sources AllowedSources
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source %q: %w", field, err)
+			}
+			addr = addr.Unmap()
+			sources = append(sources, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source %q: %w", field, err)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		sources = append(sources, prefix.Masked())
+	}
+	return sources, nil
+}
+
+// Allows reports whether traffic from the address is accepted. IPv4 addresses
+// mapped to IPv6, as reported by dual-stack sockets, match IPv4 networks.
+func (a AllowedSources) Allows(addr netip.Addr) bool {
+	if len(a) == 0 {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, prefix := range a {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAddr is like Allows for the address of a connection's peer.
+func (a AllowedSources) allowsAddr(addr net.Addr) bool {
+	if len(a) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	return a.Allows(tcpAddr.AddrPort().Addr())
+}
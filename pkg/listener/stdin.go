@@ -0,0 +1,77 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package listener
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// StatsDStdinListener reads newline separated statsd lines from a reader,
+// usually standard input, until it reaches the end, so that the exporter can
+// be used in shell pipelines and tests.
+type StatsDStdinListener struct {
+	Reader          io.Reader
+	EventHandler    event.EventHandler
+	Logger          *slog.Logger
+	LineParser      Parser
+	LinesReceived   prometheus.Counter
+	EventsFlushed   prometheus.Counter
+	Relay           Relayer
+	SampleErrors    prometheus.CounterVec
+	SamplesReceived prometheus.CounterVec
+	TagErrors       prometheus.Counter
+	TagsReceived    prometheus.Counter
+}
+
+func (l *StatsDStdinListener) SetEventHandler(eh event.EventHandler) {
+	l.EventHandler = eh
+}
+
+// Listen reads lines until the end of the reader, which is not an error, or
+// until the context is cancelled, which closes the reader if it is an
+// io.Closer. It returns an error if reading fails or a line is too long.
+func (l *StatsDStdinListener) Listen(ctx context.Context) error {
+	if c, ok := l.Reader.(io.Closer); ok {
+		stop := context.AfterFunc(ctx, func() { c.Close() })
+		defer stop()
+	}
+
+	r := bufio.NewReader(l.Reader)
+	for {
+		line, isPrefix, err := r.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read failed: %w", err)
+		}
+		l.Logger.Debug("Incoming line", "proto", "stdin", "line", string(line))
+		if isPrefix {
+			return errors.New("read failed: line too long")
+		}
+		l.LinesReceived.Inc()
+		if l.Relay != nil && len(line) > 0 {
+			l.Relay.RelayLine(string(line))
+		}
+		l.EventHandler.Queue(l.LineParser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+	}
+}
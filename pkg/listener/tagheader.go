@@ -0,0 +1,64 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bytes"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+// TagHeaders configures the handling of the tag headers that DogStatsD clients
+// put in front of batches of lines, see line.IsTagHeader.
+type TagHeaders struct {
+	// Enabled makes the first line of a datagram a tag header if it starts
+	// with '#'. Otherwise, it is parsed like any other line.
+	Enabled bool
+	// Headers, if set, counts the datagrams with a valid tag header.
+	Headers prometheus.Counter
+	// PacketLines, if set, observes the number of non-empty lines of every
+	// datagram, not counting the tag header.
+	PacketLines prometheus.Observer
+}
+
+// split splits the tag header off a datagram, if it has one, and returns the
+// rest of the datagram and the parser for its lines. The parser adds the
+// tags of the header to the events of the lines. A malformed header is
+// counted as a sample error and its lines are parsed without shared tags.
+func (h TagHeaders) split(packet []byte, parser Parser, sampleErrors prometheus.CounterVec, tagErrors prometheus.Counter, logger *slog.Logger) ([]byte, Parser) {
+	if !h.Enabled || len(packet) == 0 || packet[0] != '#' {
+		return packet, parser
+	}
+	header, rest, _ := bytes.Cut(packet, []byte{'\n'})
+	labels, err := line.ParseTagHeader(string(header), tagErrors, logger)
+	if err != nil {
+		sampleErrors.WithLabelValues("malformed_tag_header").Inc()
+		logger.Debug("Bad tag header", "header", string(header), "error", err)
+		return rest, parser
+	}
+	if h.Headers != nil {
+		h.Headers.Inc()
+	}
+	return rest, line.NewSharedTags(parser, labels)
+}
+
+// observeLines records the number of lines of a datagram.
+func (h TagHeaders) observeLines(lines int) {
+	if h.PacketLines != nil {
+		h.PacketLines.Observe(float64(lines))
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"net"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TCPPeerOther is the peer label value of the connections of peers beyond the
+// limit of a TCPPeers.
+const TCPPeerOther = "other"
+
+// TCPPeers counts the lines, bytes, parse errors and slow writes of TCP
+// connections by the IP address of their peer. The counter vectors have a
+// single "peer" label.
+//
+// At most limit peers are counted separately, so that the number of series
+// stays bounded however many clients connect. A peer keeps its series while it
+// has open connections; once it has none, its series are removed when another
+// peer needs its place. Connections of peers beyond the limit are counted as
+// TCPPeerOther.
+type TCPPeers struct {
+	lines       *prometheus.CounterVec
+	bytes       *prometheus.CounterVec
+	parseErrors *prometheus.CounterVec
+	slowWrites  *prometheus.CounterVec
+	limit       int
+
+	mtx sync.Mutex
+	// conns is the number of open connections of each tracked peer.
+	conns map[string]int
+}
+
+// NewTCPPeers returns a TCPPeers that counts up to limit peers separately.
+func NewTCPPeers(limit int, lines, bytes, parseErrors, slowWrites *prometheus.CounterVec) *TCPPeers {
+	return &TCPPeers{
+		lines:       lines,
+		bytes:       bytes,
+		parseErrors: parseErrors,
+		slowWrites:  slowWrites,
+		limit:       limit,
+		conns:       map[string]int{},
+	}
+}
+
+// connect starts counting a connection from addr. The returned counters must
+// be closed when the connection is.
+func (p *TCPPeers) connect(addr net.Addr) *peerCounters {
+	if p == nil {
+		return nil
+	}
+	peer := TCPPeerOther
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		peer = tcpAddr.AddrPort().Addr().Unmap().String()
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if _, ok := p.conns[peer]; !ok && peer != TCPPeerOther && !p.makeRoom() {
+		peer = TCPPeerOther
+	}
+	if peer != TCPPeerOther {
+		p.conns[peer]++
+	}
+	return &peerCounters{
+		peers:       p,
+		peer:        peer,
+		lines:       p.lines.WithLabelValues(peer),
+		bytes:       p.bytes.WithLabelValues(peer),
+		parseErrors: p.parseErrors.WithLabelValues(peer),
+		slowWrites:  p.slowWrites.WithLabelValues(peer),
+	}
+}
+
+// makeRoom reports whether another peer can be tracked, removing the series
+// of a peer without open connections if the limit is reached.
+func (p *TCPPeers) makeRoom() bool {
+	if len(p.conns) < p.limit {
+		return true
+	}
+	for peer, n := range p.conns {
+		if n == 0 {
+			delete(p.conns, peer)
+			p.lines.DeleteLabelValues(peer)
+			p.bytes.DeleteLabelValues(peer)
+			p.parseErrors.DeleteLabelValues(peer)
+			p.slowWrites.DeleteLabelValues(peer)
+			return true
+		}
+	}
+	return false
+}
+
+// peerCounters are the counters of one connection. A nil *peerCounters
+// counts nothing.
+type peerCounters struct {
+	peers *TCPPeers
+	peer  string
+
+	lines       prometheus.Counter
+	bytes       prometheus.Counter
+	parseErrors prometheus.Counter
+	slowWrites  prometheus.Counter
+}
+
+// line counts a line of n bytes, which was parsed into events events.
+func (c *peerCounters) line(n, events int) {
+	if c == nil {
+		return
+	}
+	c.lines.Inc()
+	c.bytes.Add(float64(n))
+	if n > 0 && events == 0 {
+		c.parseErrors.Inc()
+	}
+}
+
+func (c *peerCounters) slowWrite() {
+	if c == nil {
+		return
+	}
+	c.slowWrites.Inc()
+}
+
+func (c *peerCounters) close() {
+	if c == nil || c.peer == TCPPeerOther {
+		return
+	}
+	c.peers.mtx.Lock()
+	defer c.peers.mtx.Unlock()
+	c.peers.conns[c.peer]--
+}
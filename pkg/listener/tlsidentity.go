@@ -0,0 +1,76 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"crypto/x509"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// IdentityFields are the fields of a TLS client certificate that can be added
+// to the events of a TCP connection as labels, or prefixed to their names.
+// Of the subject alternative names, the first one of each kind is used.
+var IdentityFields = []string{"cn", "dns", "uri", "email", "ip"}
+
+// identityField returns the value of one of the IdentityFields of a client
+// certificate, or "" if the certificate does not have it.
+func identityField(cert *x509.Certificate, name string) string {
+	switch name {
+	case "cn":
+		return cert.Subject.CommonName
+	case "dns":
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case "uri":
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case "email":
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case "ip":
+		if len(cert.IPAddresses) > 0 {
+			return cert.IPAddresses[0].String()
+		}
+	}
+	return ""
+}
+
+// prefixParser prepends a prefix to the metric names of the events parsed by
+// the wrapped parser.
+type prefixParser struct {
+	Parser
+	prefix string
+}
+
+func (p *prefixParser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := p.Parser.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	for _, e := range events {
+		switch ev := e.(type) {
+		case *event.CounterEvent:
+			ev.CMetricName = p.prefix + ev.CMetricName
+		case *event.GaugeEvent:
+			ev.GMetricName = p.prefix + ev.GMetricName
+		case *event.ObserverEvent:
+			ev.OMetricName = p.prefix + ev.OMetricName
+		}
+	}
+	return events
+}
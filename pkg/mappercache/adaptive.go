@@ -0,0 +1,271 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappercache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultAdaptiveCacheMinSize is the capacity an adaptive cache starts with and
+// never shrinks below if no minimum is given.
+const DefaultAdaptiveCacheMinSize = 100
+
+// minAdaptiveCacheWindow is the least number of lookups after which an
+// adaptive cache is resized or its bloom filters are rotated.
+const minAdaptiveCacheWindow = 1024
+
+// metricMapperAdaptiveCache is a least recently used cache whose capacity
+// follows the number of distinct metric names that are looked up repeatedly.
+//
+// Lookups are counted in windows of twice the capacity. At the end of each
+// window, the capacity is set to a quarter more than the number of distinct
+// cached names used during the window, but it shrinks by at most half per
+// window and stays between the minimum and maximum size.
+//
+// A name is only added to the cache when it has been seen before, according
+// to a pair of bloom filters that cover the last two to four times the maximum
+// size lookups. Names that are only ever used once, such as ones containing
+// request IDs, thus do not evict the entries that are looked up all the time.
+type metricMapperAdaptiveCache struct {
+	lock     sync.Mutex
+	minSize  int
+	maxSize  int
+	capacity int
+
+	items map[string]*list.Element
+	// order holds the entries, most recently used first.
+	order *list.List
+
+	seed              maphash.Seed
+	current, previous *bloomFilter
+	// filterLookups is the number of lookups since the filters were rotated.
+	filterLookups int
+
+	window  uint64
+	lookups int
+	// touched is the number of distinct cached names used in this window.
+	touched int
+
+	metrics       *CacheMetrics
+	capacityGauge prometheus.Gauge
+	admitted      prometheus.Counter
+	bypassed      prometheus.Counter
+	admissions    *prometheus.CounterVec
+}
+
+type adaptiveEntry struct {
+	key    string
+	result interface{}
+	// window is the last window in which the entry was used.
+	window uint64
+}
+
+// NewMetricMapperAdaptiveCache creates a cache that holds between minSize and
+// maxSize mappings, depending on the number of distinct names looked up. If
+// minSize is not positive, DefaultAdaptiveCacheMinSize is used.
+func NewMetricMapperAdaptiveCache(reg prometheus.Registerer, minSize int, maxSize int) (*metricMapperAdaptiveCache, error) {
+	if maxSize <= 0 {
+		return nil, nil
+	}
+	if minSize <= 0 {
+		minSize = DefaultAdaptiveCacheMinSize
+	}
+	if minSize > maxSize {
+		minSize = maxSize
+	}
+
+	c := &metricMapperAdaptiveCache{
+		minSize:  minSize,
+		maxSize:  maxSize,
+		capacity: minSize,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+		seed:     maphash.MakeSeed(),
+		metrics:  NewCacheMetrics(reg),
+		capacityGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "statsd_metric_mapper_cache_capacity",
+				Help: "The number of metrics the adaptive cache currently holds at most.",
+			},
+		),
+		admissions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "statsd_metric_mapper_cache_admissions_total",
+				Help: "The count of metrics offered to the adaptive cache, by whether they were admitted or bypassed the cache because they were seen for the first time.",
+			},
+			[]string{"decision"},
+		),
+	}
+	c.current = newBloomFilter(c.filterWindowLength())
+	c.previous = newBloomFilter(c.filterWindowLength())
+	c.admitted = c.admissions.WithLabelValues("admitted")
+	c.bypassed = c.admissions.WithLabelValues("bypassed")
+	c.capacityGauge.Set(float64(c.capacity))
+
+	if reg != nil {
+		reg.MustRegister(c.capacityGauge)
+		reg.MustRegister(c.admissions)
+	}
+	return c, nil
+}
+
+func (c *metricMapperAdaptiveCache) Get(metricKey string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.metrics.CacheGetsTotal.Inc()
+	var result interface{}
+	el, ok := c.items[metricKey]
+	if ok {
+		c.metrics.CacheHitsTotal.Inc()
+		c.order.MoveToFront(el)
+		e := el.Value.(*adaptiveEntry)
+		if e.window != c.window {
+			e.window = c.window
+			c.touched++
+		}
+		result = e.result
+	}
+
+	c.lookups++
+	if c.lookups >= c.windowLength() {
+		c.endWindow()
+	}
+	c.filterLookups++
+	if c.filterLookups >= c.filterWindowLength() {
+		c.current, c.previous = c.previous, c.current
+		clear(c.current.bits)
+		c.filterLookups = 0
+	}
+	return result, ok
+}
+
+func (c *metricMapperAdaptiveCache) Add(metricKey string, result interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.items[metricKey]; ok {
+		el.Value.(*adaptiveEntry).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+
+	h := maphash.String(c.seed, metricKey)
+	seen := c.current.testAndAdd(h)
+	if !seen && !c.previous.test(h) {
+		c.bypassed.Inc()
+		return
+	}
+
+	c.admitted.Inc()
+	c.items[metricKey] = c.order.PushFront(&adaptiveEntry{key: metricKey, result: result, window: c.window})
+	c.touched++
+	c.evict()
+}
+
+func (c *metricMapperAdaptiveCache) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+	c.metrics.CacheLength.Set(0)
+}
+
+// windowLength is the number of lookups in a window at the current capacity.
+func (c *metricMapperAdaptiveCache) windowLength() int {
+	return max(2*c.capacity, minAdaptiveCacheWindow)
+}
+
+// filterWindowLength is the number of lookups after which the bloom filters
+// are rotated. It does not depend on the capacity, so that a cache that is too
+// small still admits the names it needs to grow.
+func (c *metricMapperAdaptiveCache) filterWindowLength() int {
+	return max(2*c.maxSize, minAdaptiveCacheWindow)
+}
+
+// endWindow resizes the cache and starts a new window. The lock must be held.
+func (c *metricMapperAdaptiveCache) endWindow() {
+	target := max(c.touched+c.touched/4, c.capacity/2)
+	c.capacity = min(max(target, c.minSize), c.maxSize)
+	c.capacityGauge.Set(float64(c.capacity))
+	c.evict()
+
+	c.window++
+	c.lookups = 0
+	c.touched = 0
+}
+
+// evict removes the least recently used entries beyond the capacity. The lock
+// must be held.
+func (c *metricMapperAdaptiveCache) evict() {
+	for c.order.Len() > c.capacity {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*adaptiveEntry).key)
+	}
+	c.metrics.CacheLength.Set(float64(c.order.Len()))
+}
+
+// bloomFilterHashes is the number of bits set per name. With ten bits per
+// expected name, it gives a false positive rate of about one percent.
+const bloomFilterHashes = 7
+
+// bloomFilter records which names have been seen, with a small rate of false
+// positives and without holding on to the names.
+type bloomFilter struct {
+	bits []uint64
+}
+
+// newBloomFilter creates a filter for about n names.
+func newBloomFilter(n int) *bloomFilter {
+	return &bloomFilter{bits: make([]uint64, (n*10+63)/64+1)}
+}
+
+// positions calls f with the bits of a hash, derived by double hashing.
+func (b *bloomFilter) positions(h uint64, f func(word, mask uint64) bool) bool {
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := h&0xffffffff, h>>32|1
+	for i := uint64(0); i < bloomFilterHashes; i++ {
+		bit := (h1 + i*h2) % m
+		if !f(bit/64, 1<<(bit%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+// test reports whether the hash may have been added.
+func (b *bloomFilter) test(h uint64) bool {
+	return b.positions(h, func(word, mask uint64) bool {
+		return b.bits[word]&mask != 0
+	})
+}
+
+// testAndAdd adds the hash and reports whether it may have been added before.
+func (b *bloomFilter) testAndAdd(h uint64) bool {
+	seen := true
+	b.positions(h, func(word, mask uint64) bool {
+		if b.bits[word]&mask == 0 {
+			seen = false
+			b.bits[word] |= mask
+		}
+		return true
+	})
+	return seen
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappercache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// lookup gets a key from the cache and adds it on a miss, like the mapper does.
+func lookup(c *metricMapperAdaptiveCache, key string) bool {
+	if _, ok := c.Get(key); ok {
+		return true
+	}
+	c.Add(key, key)
+	return false
+}
+
+func TestAdaptiveCacheBypass(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c, err := NewMetricMapperAdaptiveCache(reg, 10, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c.Add("foo", 1)
+	if _, ok := c.Get("foo"); ok {
+		t.Fatalf("expected a name seen for the first time to bypass the cache")
+	}
+	c.Add("foo", 2)
+	if v, ok := c.Get("foo"); !ok || v != 2 {
+		t.Fatalf("expected cached value 2, got %v (%v)", v, ok)
+	}
+
+	hot := []string{"hot1", "hot2", "hot3", "hot4", "hot5"}
+	for _, key := range hot {
+		lookup(c, key)
+		lookup(c, key)
+	}
+	// A flood of names that are only used once must not evict the hot names.
+	for i := 0; i < 100; i++ {
+		lookup(c, fmt.Sprintf("request.%d", i))
+	}
+	for _, key := range hot {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to be cached after the flood", key)
+		}
+	}
+
+	if admitted := testutil.ToFloat64(c.admitted); admitted != 6 {
+		t.Errorf("expected 6 admissions, got %v", admitted)
+	}
+	if bypassed := testutil.ToFloat64(c.bypassed); bypassed < 100 {
+		t.Errorf("expected at least 100 bypasses, got %v", bypassed)
+	}
+
+	c.Reset()
+	if _, ok := c.Get("foo"); ok {
+		t.Errorf("expected a miss after reset")
+	}
+	c.Add("foo", 3)
+	if _, ok := c.Get("foo"); !ok {
+		t.Errorf("expected a name seen before the reset to be admitted")
+	}
+}
+
+func TestAdaptiveCacheResize(t *testing.T) {
+	c, _ := NewMetricMapperAdaptiveCache(nil, 10, 1000)
+
+	run := func(names, rounds int) (hits int) {
+		for r := 0; r < rounds; r++ {
+			for i := 0; i < names; i++ {
+				if lookup(c, fmt.Sprintf("metric%d", i)) && r == rounds-1 {
+					hits++
+				}
+			}
+		}
+		return hits
+	}
+
+	if hits := run(200, 50); hits != 200 {
+		t.Errorf("expected all 200 names to be cached after growing, got %d hits", hits)
+	}
+	if c.capacity < 200 || c.capacity > 1000 {
+		t.Errorf("expected the capacity to grow to hold 200 names, got %d", c.capacity)
+	}
+
+	run(20, 500)
+	if c.capacity != 25 {
+		t.Errorf("expected the capacity to shrink to 25, got %d", c.capacity)
+	}
+	if c.order.Len() > c.capacity {
+		t.Errorf("cache holds %d entries, more than its capacity %d", c.order.Len(), c.capacity)
+	}
+
+	run(1500, 10)
+	if c.capacity != 1000 {
+		t.Errorf("expected the capacity to be capped at 1000, got %d", c.capacity)
+	}
+
+	run(1, 10000)
+	if c.capacity != 10 {
+		t.Errorf("expected the capacity to shrink to the minimum of 10, got %d", c.capacity)
+	}
+}
+
+func TestAdaptiveCacheDisabled(t *testing.T) {
+	c, err := NewMetricMapperAdaptiveCache(nil, 0, 0)
+	if err != nil || c != nil {
+		t.Fatalf("expected no cache for size 0, got %v, %v", c, err)
+	}
+	c, _ = NewMetricMapperAdaptiveCache(nil, 0, 50)
+	if c.minSize != 50 {
+		t.Errorf("expected the minimum size to be capped at the maximum, got %d", c.minSize)
+	}
+}
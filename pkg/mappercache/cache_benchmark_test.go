@@ -0,0 +1,104 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappercache_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/pkg/mappercache"
+	"github.com/prometheus/statsd_exporter/pkg/mappercache/lru"
+	"github.com/prometheus/statsd_exporter/pkg/mappercache/randomreplacement"
+)
+
+type cache interface {
+	Get(metricKey string) (interface{}, bool)
+	Add(metricKey string, result interface{})
+}
+
+func newCache(cacheType string, size int) cache {
+	switch cacheType {
+	case "lru":
+		c, _ := lru.NewMetricMapperLRUCache(nil, size)
+		return c
+	case "random":
+		c, _ := randomreplacement.NewMetricMapperRRCache(nil, size)
+		return c
+	case "sharded":
+		c, _ := mappercache.NewMetricMapperShardedCache(nil, size, 0)
+		return c
+	case "adaptive":
+		c, _ := mappercache.NewMetricMapperAdaptiveCache(nil, 0, size)
+		return c
+	}
+	panic("unknown cache type " + cacheType)
+}
+
+var cacheTypes = []string{"lru", "random", "sharded", "adaptive"}
+
+func metricKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("metric%d.a.b", i)
+	}
+	return keys
+}
+
+// BenchmarkCacheHitParallel looks up cached keys from concurrent goroutines.
+func BenchmarkCacheHitParallel(b *testing.B) {
+	keys := metricKeys(1000)
+	for _, cacheType := range cacheTypes {
+		b.Run(cacheType, func(b *testing.B) {
+			c := newCache(cacheType, len(keys))
+			for _, k := range keys {
+				c.Add(k, k)
+			}
+
+			var worker atomic.Uint64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(worker.Add(1)) * 7919
+				for pb.Next() {
+					c.Get(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCacheMissParallel looks up and adds keys from concurrent goroutines,
+// with ten times more keys than fit into the cache.
+func BenchmarkCacheMissParallel(b *testing.B) {
+	keys := metricKeys(10000)
+	for _, cacheType := range cacheTypes {
+		b.Run(cacheType, func(b *testing.B) {
+			c := newCache(cacheType, len(keys)/10)
+
+			var worker atomic.Uint64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(worker.Add(1)) * 7919
+				for pb.Next() {
+					k := keys[i%len(keys)]
+					if _, ok := c.Get(k); !ok {
+						c.Add(k, k)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
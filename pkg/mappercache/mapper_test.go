@@ -0,0 +1,95 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappercache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+func newCachedMapper(t testing.TB, cacheType string, size int, config string) *mapper.MetricMapper {
+	m := &mapper.MetricMapper{}
+	m.UseCache(newCache(cacheType, size).(mapper.MetricMapperCache))
+	if err := m.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+	return m
+}
+
+// Test for https://github.com/prometheus/statsd_exporter/issues/273
+// Corrupt cache for multiple names matching in fsm
+func TestMapperMultipleMatches(t *testing.T) {
+	config := `---
+mappings:
+- match: aa.bb.*.*
+  name: "aa_bb_${1}_total"
+  labels:
+    app: "$2"
+`
+
+	names := map[string]string{
+		"aa.bb.aa.myapp": "aa_bb_aa_total",
+		"aa.bb.bb.myapp": "aa_bb_bb_total",
+		"aa.bb.cc.myapp": "aa_bb_cc_total",
+		"aa.bb.dd.myapp": "aa_bb_dd_total",
+	}
+
+	for _, cacheType := range cacheTypes {
+		t.Run(cacheType, func(t *testing.T) {
+			m := newCachedMapper(t, cacheType, 1000, config)
+
+			// run multiple times to ensure cache works as expected
+			for j := 0; j < 10; j++ {
+				for name, expected := range names {
+					mapping, labels, ok := m.GetMapping(name, mapper.MetricTypeCounter)
+					if !ok {
+						t.Fatalf("%d: Did not find match for %s", j, name)
+					}
+					if mapping.Name != expected {
+						t.Fatalf("%d: Expected name %s, got %s", j, expected, mapping.Name)
+					}
+					if labels["app"] != "myapp" {
+						t.Fatalf("%d: Expected label app=myapp, got %v", j, labels)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMapperGlob100RulesCached100Metrics(b *testing.B) {
+	config := "---\nmappings:\n"
+	for i := 0; i < 100; i++ {
+		config += fmt.Sprintf("- match: metric%d.*\n  name: \"metric_single\"\n  labels:\n    name: \"$1\"\n", i)
+	}
+	var metrics []string
+	for i := 0; i < 100; i++ {
+		metrics = append(metrics, fmt.Sprintf("metric%d.a", i))
+	}
+
+	for _, cacheType := range cacheTypes {
+		b.Run(cacheType, func(b *testing.B) {
+			m := newCachedMapper(b, cacheType, 1000, config)
+
+			b.ResetTimer()
+			for j := 0; j < b.N; j++ {
+				for _, metric := range metrics {
+					m.GetMapping(metric, mapper.MetricTypeCounter)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappercache
+
+import (
+	"hash/maphash"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultCacheShards is the number of shards of a sharded cache if none is
+// given.
+const DefaultCacheShards = 16
+
+// metricMapperShardedCache spreads the cached mappings over a number of
+// shards, each backed by a sync.Map. Reads of cached keys take no locks, and
+// writes only contend with writes to the same shard. When a shard is full, a
+// random entry of that shard is evicted.
+type metricMapperShardedCache struct {
+	seed   maphash.Seed
+	shards []*cacheShard
+}
+
+type cacheShard struct {
+	items    atomic.Pointer[sync.Map]
+	capacity int64
+
+	length    atomic.Int64
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+
+	// Keep the counters of different shards on separate cache lines.
+	_ [64]byte
+}
+
+// NewMetricMapperShardedCache creates a cache for up to size mappings, spread
+// over the given number of shards. If shards is not positive, DefaultCacheShards
+// is used.
+func NewMetricMapperShardedCache(reg prometheus.Registerer, size int, shards int) (*metricMapperShardedCache, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	if shards <= 0 {
+		shards = DefaultCacheShards
+	}
+	if shards > size {
+		shards = size
+	}
+
+	c := &metricMapperShardedCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*cacheShard, shards),
+	}
+	capacity := int64((size + shards - 1) / shards)
+	for i := range c.shards {
+		s := &cacheShard{capacity: capacity}
+		s.items.Store(&sync.Map{})
+		c.shards[i] = s
+	}
+
+	if reg != nil {
+		reg.MustRegister(&shardedCacheCollector{cache: c})
+	}
+	return c, nil
+}
+
+func (m *metricMapperShardedCache) shard(metricKey string) *cacheShard {
+	return m.shards[maphash.String(m.seed, metricKey)%uint64(len(m.shards))]
+}
+
+func (m *metricMapperShardedCache) Get(metricKey string) (interface{}, bool) {
+	s := m.shard(metricKey)
+	result, ok := s.items.Load().Load(metricKey)
+	if ok {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+	return result, ok
+}
+
+func (m *metricMapperShardedCache) Add(metricKey string, result interface{}) {
+	s := m.shard(metricKey)
+	items := s.items.Load()
+	if _, loaded := items.Swap(metricKey, result); loaded {
+		return
+	}
+	if s.length.Add(1) <= s.capacity {
+		return
+	}
+
+	// Evict an entry other than the one just added. Map iteration order is
+	// random, so this is random replacement within the shard.
+	items.Range(func(k, _ interface{}) bool {
+		if k.(string) == metricKey {
+			return true
+		}
+		if _, loaded := items.LoadAndDelete(k); !loaded {
+			// Evicted concurrently, try another one.
+			return true
+		}
+		s.length.Add(-1)
+		s.evictions.Add(1)
+		return false
+	})
+}
+
+func (m *metricMapperShardedCache) Reset() {
+	for _, s := range m.shards {
+		s.items.Store(&sync.Map{})
+		s.length.Store(0)
+	}
+}
+
+var (
+	cacheLengthDesc = prometheus.NewDesc(
+		"statsd_metric_mapper_cache_length",
+		"The count of unique metrics currently cached.",
+		nil, nil,
+	)
+	cacheGetsDesc = prometheus.NewDesc(
+		"statsd_metric_mapper_cache_gets_total",
+		"The count of total metric cache gets.",
+		nil, nil,
+	)
+	cacheHitsDesc = prometheus.NewDesc(
+		"statsd_metric_mapper_cache_hits_total",
+		"The count of total metric cache hits.",
+		nil, nil,
+	)
+	shardHitsDesc = prometheus.NewDesc(
+		"statsd_metric_mapper_cache_shard_hits_total",
+		"The count of metric cache hits per shard.",
+		[]string{"shard"}, nil,
+	)
+	shardMissesDesc = prometheus.NewDesc(
+		"statsd_metric_mapper_cache_shard_misses_total",
+		"The count of metric cache misses per shard.",
+		[]string{"shard"}, nil,
+	)
+	shardEvictionsDesc = prometheus.NewDesc(
+		"statsd_metric_mapper_cache_shard_evictions_total",
+		"The count of metric cache evictions per shard.",
+		[]string{"shard"}, nil,
+	)
+)
+
+// shardedCacheCollector exports the counters kept by the shards. Besides the
+// per-shard metrics, it exports the same totals as the other cache types.
+type shardedCacheCollector struct {
+	cache *metricMapperShardedCache
+}
+
+func (c *shardedCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheLengthDesc
+	ch <- cacheGetsDesc
+	ch <- cacheHitsDesc
+	ch <- shardHitsDesc
+	ch <- shardMissesDesc
+	ch <- shardEvictionsDesc
+}
+
+func (c *shardedCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	var length int64
+	var hits, misses uint64
+	for i, s := range c.cache.shards {
+		shardHits, shardMisses := s.hits.Load(), s.misses.Load()
+		length += s.length.Load()
+		hits += shardHits
+		misses += shardMisses
+
+		shard := strconv.Itoa(i)
+		ch <- prometheus.MustNewConstMetric(shardHitsDesc, prometheus.CounterValue, float64(shardHits), shard)
+		ch <- prometheus.MustNewConstMetric(shardMissesDesc, prometheus.CounterValue, float64(shardMisses), shard)
+		ch <- prometheus.MustNewConstMetric(shardEvictionsDesc, prometheus.CounterValue, float64(s.evictions.Load()), shard)
+	}
+	ch <- prometheus.MustNewConstMetric(cacheLengthDesc, prometheus.GaugeValue, float64(length))
+	ch <- prometheus.MustNewConstMetric(cacheGetsDesc, prometheus.CounterValue, float64(hits+misses))
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(hits))
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mappercache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestShardedCache(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c, err := NewMetricMapperShardedCache(reg, 64, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatalf("expected a miss for an empty cache")
+	}
+	c.Add("foo", 1)
+	if v, ok := c.Get("foo"); !ok || v != 1 {
+		t.Fatalf("expected cached value 1, got %v (%v)", v, ok)
+	}
+	c.Add("foo", 2)
+	if v, _ := c.Get("foo"); v != 2 {
+		t.Fatalf("expected replaced value 2, got %v", v)
+	}
+
+	for i := 0; i < 1000; i++ {
+		c.Add(fmt.Sprintf("metric%d", i), i)
+	}
+	var length, evictions int64
+	for _, s := range c.shards {
+		n := 0
+		s.items.Load().Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		if int64(n) > s.capacity {
+			t.Errorf("shard holds %d entries, more than its capacity %d", n, s.capacity)
+		}
+		if int64(n) != s.length.Load() {
+			t.Errorf("shard holds %d entries, but tracks a length of %d", n, s.length.Load())
+		}
+		length += s.length.Load()
+		evictions += int64(s.evictions.Load())
+	}
+	if length+evictions != 1001 {
+		t.Errorf("expected %d entries to be cached or evicted, got %d", 1001, length+evictions)
+	}
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+# HELP statsd_metric_mapper_cache_gets_total The count of total metric cache gets.
+# TYPE statsd_metric_mapper_cache_gets_total counter
+statsd_metric_mapper_cache_gets_total 3
+# HELP statsd_metric_mapper_cache_hits_total The count of total metric cache hits.
+# TYPE statsd_metric_mapper_cache_hits_total counter
+statsd_metric_mapper_cache_hits_total 2
+# HELP statsd_metric_mapper_cache_length The count of unique metrics currently cached.
+# TYPE statsd_metric_mapper_cache_length gauge
+statsd_metric_mapper_cache_length %d
+`, length)), "statsd_metric_mapper_cache_gets_total", "statsd_metric_mapper_cache_hits_total", "statsd_metric_mapper_cache_length"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(&shardedCacheCollector{cache: c}, "statsd_metric_mapper_cache_shard_evictions_total"); n != 4 {
+		t.Errorf("expected eviction counters for 4 shards, got %d", n)
+	}
+
+	c.Reset()
+	if _, ok := c.Get("metric999"); ok {
+		t.Errorf("expected a miss after reset")
+	}
+	for _, s := range c.shards {
+		if s.length.Load() != 0 {
+			t.Errorf("expected empty shards after reset, got length %d", s.length.Load())
+		}
+	}
+}
+
+func TestShardedCacheConcurrent(t *testing.T) {
+	c, _ := NewMetricMapperShardedCache(nil, 100, 0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("metric%d", (i*(g+1))%300)
+				if _, ok := c.Get(key); !ok {
+					c.Add(key, i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range c.shards {
+		n := 0
+		s.items.Load().Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		if int64(n) > s.capacity {
+			t.Errorf("shard holds %d entries, more than its capacity %d", n, s.capacity)
+		}
+	}
+}
+
+func TestShardedCacheDisabled(t *testing.T) {
+	c, err := NewMetricMapperShardedCache(nil, 0, 0)
+	if err != nil || c != nil {
+		t.Fatalf("expected no cache for size 0, got %v, %v", c, err)
+	}
+}
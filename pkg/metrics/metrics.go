@@ -17,6 +17,8 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
 )
 
 type MetricType int
@@ -26,6 +28,10 @@ const (
 	GaugeMetricType
 	SummaryMetricType
 	HistogramMetricType
+	AggregatedGaugesMetricType
+	GaugeHistogramMetricType
+	SumAndCountMetricType
+	RateMetricType
 )
 
 type NameHash uint64
@@ -56,12 +62,43 @@ type Metric struct {
 	Vectors map[NameHash]*Vector
 	// Metrics key is a hash of the label names + label values
 	Metrics map[ValueHash]*RegisteredMetric
+	// Help is the help text the metric was first registered with.
+	Help string
+	// HelpConflict is set once a series was created with another help text.
+	HelpConflict bool
 }
 
 type RegisteredMetric struct {
 	LastRegisteredAt time.Time
 	Labels           prometheus.Labels
 	TTL              time.Duration
-	Metric           MetricHolder
-	VecKey           NameHash
+	// ExpireOnNoChange makes the TTL count from LastChangedAt instead of
+	// LastRegisteredAt.
+	ExpireOnNoChange bool
+	// LastChangedAt is when a counter or gauge was last updated to a
+	// different value, or any other series last updated.
+	LastChangedAt time.Time
+	// LastValue is the value of a gauge, to tell whether an update changes
+	// it.
+	LastValue float64
+	Metric    MetricHolder
+	VecKey    NameHash
+	// Mapping is the name template of the mapping the series was created
+	// for, empty for unmapped metrics.
+	Mapping string
+	// Match is the match expression of that mapping.
+	Match string
+	// Forward is set if the mapping selects the series for forwarding.
+	Forward bool
+	// ExpireSchedule, if set, is the schedule of the mapping, outside of
+	// whose windows the series expires.
+	ExpireSchedule *mapper.Schedule
+	// ForwardedValue is the value of a forwarded counter when it was last
+	// forwarded.
+	ForwardedValue float64
+	// EstimatedBytes is the estimated memory used by the series.
+	EstimatedBytes int
+	// SampledRemainder is the fractional part of the sampled increments of a
+	// counter that was carried over to the next one.
+	SampledRemainder float64
 }
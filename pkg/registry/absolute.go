@@ -0,0 +1,61 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+// AbsoluteCounter is a counter that is fed the cumulative totals of an emitter
+// instead of increments. It is stored in place of the plain counter of the
+// series, so that the last total is forgotten along with the series.
+type AbsoluteCounter struct {
+	prometheus.Counter
+	total float64
+}
+
+// SetTotal increases the counter by the increase of the emitter's total since
+// the previous one, and returns the increase. A total lower than the previous
+// one means that the emitter was reset and counts from zero again, so all of
+// it is added.
+func (c *AbsoluteCounter) SetTotal(total float64) float64 {
+	increase := total - c.total
+	if total < c.total {
+		increase = total
+	}
+	c.total = total
+	c.Counter.Add(increase)
+	return increase
+}
+
+// GetAbsoluteCounter is like GetCounter, for mappings with the absolute
+// counter mode.
+func (r *Registry) GetAbsoluteCounter(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (*AbsoluteCounter, error) {
+	counter, err := r.GetCounter(metricName, labels, help, mapping, metricsCount)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := counter.(*AbsoluteCounter); ok {
+		return c, nil
+	}
+
+	// The series is new, or was updated in increment mode before the
+	// mapping changed.
+	c := &AbsoluteCounter{Counter: counter}
+	hash, _ := r.HashLabels(labels)
+	r.Metrics[metricName].Metrics[hash.Values].Metric = c
+	return c, nil
+}
@@ -0,0 +1,310 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// aggregatedGaugesSuffixes returns the suffixes appended to the metric name
+// for the gauges of aggregates.
+func aggregatedGaugesSuffixes(aggregates []string) []string {
+	if len(aggregates) == 0 {
+		aggregates = mapper.DefaultAggregates
+	}
+	suffixes := make([]string, len(aggregates))
+	for i, a := range aggregates {
+		suffixes[i] = "_" + a
+	}
+	return suffixes
+}
+
+// AggregatedGaugesVec exports the observations made in each window of time
+// as a gauge per aggregate, such as `_min`, `_max`, `_avg`, `_count` or
+// `_95percentile`. The gauges show the values of the last complete window.
+// After a window without observations, only `_count` is exported.
+type AggregatedGaugesVec struct {
+	aggregates []string
+	descs      []*prometheus.Desc
+	// quantiles holds the quantiles of the median and percentile aggregates,
+	// for which the observations of each window are kept.
+	quantiles  map[string]float64
+	labelNames []string
+	window     time.Duration
+
+	mtx    sync.Mutex
+	gauges map[string]*aggregatedGauges
+}
+
+// NewAggregatedGaugesVec returns aggregated gauges of the DefaultAggregates.
+func NewAggregatedGaugesVec(name, help string, labelNames []string, window time.Duration) *AggregatedGaugesVec {
+	return NewAggregatedGaugesVecWithAggregates(name, help, labelNames, window, mapper.DefaultAggregates)
+}
+
+// NewAggregatedGaugesVecWithAggregates returns aggregated gauges of the given
+// aggregates, which must be valid.
+func NewAggregatedGaugesVecWithAggregates(name, help string, labelNames []string, window time.Duration, aggregates []string) *AggregatedGaugesVec {
+	if len(aggregates) == 0 {
+		aggregates = mapper.DefaultAggregates
+	}
+	v := &AggregatedGaugesVec{
+		aggregates: aggregates,
+		quantiles:  map[string]float64{},
+		labelNames: labelNames,
+		window:     window,
+		gauges:     make(map[string]*aggregatedGauges),
+	}
+	for i, suffix := range aggregatedGaugesSuffixes(aggregates) {
+		v.descs = append(v.descs, prometheus.NewDesc(name+suffix, help, labelNames, nil))
+		if q, ok := mapper.AggregateQuantile(aggregates[i]); ok {
+			v.quantiles[aggregates[i]] = q
+		}
+	}
+	return v
+}
+
+// GetMetricWith returns the aggregated gauges for the given labels, creating
+// them if needed. The label names must match those of the vector.
+func (v *AggregatedGaugesVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	key, values, err := v.key(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	g, ok := v.gauges[key]
+	if !ok {
+		g = &aggregatedGauges{window: window{length: v.window, start: clock.Now()}, labelValues: values, keepSamples: len(v.quantiles) > 0}
+		g.resetCurrent()
+		v.gauges[key] = g
+	}
+	return g, nil
+}
+
+// Delete removes the aggregated gauges for the given labels.
+func (v *AggregatedGaugesVec) Delete(labels prometheus.Labels) bool {
+	key, _, err := v.key(labels)
+	if err != nil {
+		return false
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if _, ok := v.gauges[key]; !ok {
+		return false
+	}
+	delete(v.gauges, key)
+	return true
+}
+
+func (v *AggregatedGaugesVec) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range v.descs {
+		ch <- desc
+	}
+}
+
+func (v *AggregatedGaugesVec) Collect(ch chan<- prometheus.Metric) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, g := range v.gauges {
+		w := g.lastWindow()
+		for i, a := range v.aggregates {
+			if a != mapper.AggregateCount && w.count == 0 {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(v.descs[i], prometheus.GaugeValue, v.aggregate(w, a), g.labelValues...)
+		}
+	}
+}
+
+// aggregate returns the value of an aggregate of a window.
+func (v *AggregatedGaugesVec) aggregate(w aggregatedWindow, aggregate string) float64 {
+	switch aggregate {
+	case mapper.AggregateMin:
+		return w.min
+	case mapper.AggregateMax:
+		return w.max
+	case mapper.AggregateAvg:
+		return w.sum / float64(w.count)
+	case mapper.AggregateSum:
+		return w.sum
+	case mapper.AggregateCount:
+		return float64(w.count)
+	}
+	return w.quantile(v.quantiles[aggregate])
+}
+
+// Suffixes returns the suffixes of the gauges of the aggregates.
+func (v *AggregatedGaugesVec) Suffixes() []string {
+	return aggregatedGaugesSuffixes(v.aggregates)
+}
+
+// key returns a map key and the label values in the order of the vector's
+// label names.
+func (v *AggregatedGaugesVec) key(labels prometheus.Labels) (string, []string, error) {
+	return labelValuesKey(v.labelNames, labels)
+}
+
+// labelValuesKey returns a map key and the label values in the order of the
+// given label names.
+func labelValuesKey(labelNames []string, labels prometheus.Labels) (string, []string, error) {
+	if len(labels) != len(labelNames) {
+		return "", nil, fmt.Errorf("expected %d labels, got %d", len(labelNames), len(labels))
+	}
+	values := make([]string, len(labelNames))
+	for i, name := range labelNames {
+		value, ok := labels[name]
+		if !ok {
+			return "", nil, fmt.Errorf("label %s missing", name)
+		}
+		values[i] = value
+	}
+	return strings.Join(values, string([]byte{model.SeparatorByte})), values, nil
+}
+
+// window tracks consecutive windows of time of a fixed length.
+type window struct {
+	length time.Duration
+	start  time.Time
+}
+
+// roll moves on to the window containing the current time. It reports
+// whether the previous window has ended, and whether the last complete
+// window was empty because more than one window has passed.
+func (w *window) roll() (ended, skipped bool) {
+	elapsed := clock.Now().Sub(w.start)
+	if elapsed < w.length {
+		return false, false
+	}
+	w.start = w.start.Add(elapsed.Truncate(w.length))
+	return true, elapsed >= 2*w.length
+}
+
+type aggregatedWindow struct {
+	min, max, sum float64
+	count         uint64
+	// samples are the observations of the window, if they are kept for
+	// quantiles. They are sorted by value when the window is complete.
+	samples []weightedSample
+}
+
+// weightedSample is an observation made count times.
+type weightedSample struct {
+	value float64
+	count uint64
+}
+
+// quantile returns the observation of the nearest rank to the quantile q,
+// round(q*count)-1 counting from 0, as the Datadog agent computes
+// percentiles. The samples must be sorted.
+func (w aggregatedWindow) quantile(q float64) float64 {
+	rank := uint64(0)
+	if r := math.Round(q*float64(w.count)) - 1; r > 0 {
+		rank = uint64(r)
+	}
+	var seen uint64
+	for _, s := range w.samples {
+		seen += s.count
+		if seen > rank {
+			return s.value
+		}
+	}
+	return math.NaN()
+}
+
+// aggregatedGauges aggregates the observations for one set of labels. The
+// window is rolled over lazily, when an observation is made or the gauges are
+// collected.
+type aggregatedGauges struct {
+	labelValues []string
+	keepSamples bool
+
+	mtx     sync.Mutex
+	window  window
+	current aggregatedWindow
+	last    aggregatedWindow
+	// observations is the total number of observations, used to tell whether
+	// the gauges changed.
+	observations uint64
+}
+
+func (g *aggregatedGauges) Observe(value float64) {
+	g.ObserveMany(value, 1)
+}
+
+// ObserveMany records the same observation n times.
+func (g *aggregatedGauges) ObserveMany(value float64, n int) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	g.rollOver()
+	g.current.min = math.Min(g.current.min, value)
+	g.current.max = math.Max(g.current.max, value)
+	g.current.sum += value * float64(n)
+	g.current.count += uint64(n)
+	g.observations += uint64(n)
+	if g.keepSamples {
+		g.current.samples = append(g.current.samples, weightedSample{value: value, count: uint64(n)})
+	}
+}
+
+func (g *aggregatedGauges) lastWindow() aggregatedWindow {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	g.rollOver()
+	return g.last
+}
+
+func (g *aggregatedGauges) observationCount() uint64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.observations
+}
+
+// rollOver completes the current window if it has ended. If more than one
+// window has passed, the last complete window was empty.
+func (g *aggregatedGauges) rollOver() {
+	ended, skipped := g.window.roll()
+	if !ended {
+		return
+	}
+	g.last = g.current
+	if skipped {
+		g.last = aggregatedWindow{}
+	}
+	slices.SortFunc(g.last.samples, func(a, b weightedSample) int {
+		return cmp.Compare(a.value, b.value)
+	})
+	g.resetCurrent()
+}
+
+func (g *aggregatedGauges) resetCurrent() {
+	g.current = aggregatedWindow{min: math.Inf(1), max: math.Inf(-1)}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// changeCounter records when the counter of a series that expires on no
+// change was last increased. It is stored in place of the plain counter of the
+// series.
+type changeCounter struct {
+	prometheus.Counter
+	rm *metrics.RegisteredMetric
+}
+
+func (c *changeCounter) Inc() {
+	c.Counter.Inc()
+	c.changed()
+}
+
+func (c *changeCounter) Add(v float64) {
+	c.Counter.Add(v)
+	if v != 0 {
+		c.changed()
+	}
+}
+
+func (c *changeCounter) changed() {
+	if c.rm.ExpireOnNoChange {
+		c.rm.LastChangedAt = clock.Now()
+	}
+}
+
+// changeGauge records when the gauge of a series that expires on no change was
+// last set to a different value. It is stored in place of the plain gauge of
+// the series.
+type changeGauge struct {
+	prometheus.Gauge
+	rm *metrics.RegisteredMetric
+}
+
+func (g *changeGauge) Set(v float64) {
+	g.Gauge.Set(v)
+	g.update(v)
+}
+
+func (g *changeGauge) Inc() {
+	g.Add(1)
+}
+
+func (g *changeGauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *changeGauge) Add(v float64) {
+	g.Gauge.Add(v)
+	g.update(g.rm.LastValue + v)
+}
+
+func (g *changeGauge) Sub(v float64) {
+	g.Add(-v)
+}
+
+func (g *changeGauge) SetToCurrentTime() {
+	g.Set(float64(clock.Now().UnixNano()) / 1e9)
+}
+
+func (g *changeGauge) update(v float64) {
+	if v == g.rm.LastValue {
+		return
+	}
+	g.rm.LastValue = v
+	if g.rm.ExpireOnNoChange {
+		g.rm.LastChangedAt = clock.Now()
+	}
+}
+
+// trackChanges wraps the counter or gauge of a newly stored series, so that
+// the series records when its value last changed. Other series change with
+// every update.
+func trackChanges(rm *metrics.RegisteredMetric, metricType metrics.MetricType) {
+	switch metricType {
+	case metrics.CounterMetricType:
+		rm.Metric = &changeCounter{Counter: rm.Metric.(prometheus.Counter), rm: rm}
+	case metrics.GaugeMetricType:
+		rm.Metric = &changeGauge{Gauge: rm.Metric.(prometheus.Gauge), rm: rm}
+	}
+}
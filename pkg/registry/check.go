@@ -0,0 +1,116 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// checkTypeNames are the names of the metric types in the errors of
+// CheckMappings.
+var checkTypeNames = map[metrics.MetricType]string{
+	metrics.CounterMetricType:          "counter",
+	metrics.GaugeMetricType:            "gauge",
+	metrics.SummaryMetricType:          "summary",
+	metrics.HistogramMetricType:        "histogram",
+	metrics.AggregatedGaugesMetricType: "aggregated_gauges",
+	metrics.GaugeHistogramMetricType:   "gaugehistogram",
+	metrics.SumAndCountMetricType:      "sum_and_count",
+	metrics.RateMetricType:             "rate",
+}
+
+var observerMetricTypes = map[mapper.ObserverType]metrics.MetricType{
+	mapper.ObserverTypeDefault:          metrics.SummaryMetricType,
+	mapper.ObserverTypeSummary:          metrics.SummaryMetricType,
+	mapper.ObserverTypeHistogram:        metrics.HistogramMetricType,
+	mapper.ObserverTypeAggregatedGauges: metrics.AggregatedGaugesMetricType,
+	mapper.ObserverTypeGaugeHistogram:   metrics.GaugeHistogramMetricType,
+	mapper.ObserverTypeSumAndCount:      metrics.SumAndCountMetricType,
+}
+
+// CheckMappings reports the metrics that a mapping configuration would create
+// with another type than the one they currently have, such as a summary that
+// a new mapping turns into a histogram. Until their series expire, every
+// event of such a metric would be a conflict.
+//
+// A metric is checked against a mapping if the mapping has a fixed name equal
+// to the metric's, or if series of the metric were created by a mapping with
+// the same match and name template.
+func (r *Registry) CheckMappings(m *mapper.MetricMapper) error {
+	// The metrics created by each mapping, by match and name template.
+	origins := map[MappingOrigin][]string{}
+	for name, metric := range r.Metrics {
+		seen := map[MappingOrigin]bool{}
+		for _, rm := range metric.Metrics {
+			origin := MappingOrigin{Match: rm.Match, Name: rm.Mapping}
+			if rm.Match != "" && !seen[origin] {
+				seen[origin] = true
+				origins[origin] = append(origins[origin], name)
+			}
+		}
+	}
+
+	var errs []error
+	check := func(mapping *mapper.MetricMapping, types ...metrics.MetricType) {
+		names := slices.Clone(origins[MappingOrigin{Match: mapping.Match, Name: mapping.NameTemplate()}])
+		if name := mapping.NameTemplate(); !strings.Contains(name, "$") && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			metric, ok := r.Metrics[name]
+			// Rates are gauges of their own next to the counters they
+			// belong to, created by the same mapping.
+			if !ok || metric.MetricType == metrics.RateMetricType || slices.Contains(types, metric.MetricType) {
+				continue
+			}
+			typeNames := make([]string, len(types))
+			for i, t := range types {
+				typeNames[i] = checkTypeNames[t]
+			}
+			errs = append(errs, fmt.Errorf("mapping %s would turn the existing %s %s into a %s", mapping.Match, checkTypeNames[metric.MetricType], name, strings.Join(typeNames, " or ")))
+		}
+	}
+
+	for _, mapping := range m.AllMappings() {
+		if mapping.Action == mapper.ActionTypeDrop || !mapping.HasOutput(mapper.OutputPrometheus) {
+			continue
+		}
+		observerType := observerMetricTypes[mapping.ObserverType]
+		switch mapping.MatchMetricType {
+		case mapper.MetricTypeCounter:
+			check(mapping, metrics.CounterMetricType)
+			continue
+		case mapper.MetricTypeGauge:
+			check(mapping, metrics.GaugeMetricType)
+			continue
+		case mapper.MetricTypeObserver:
+			check(mapping, observerType)
+		default:
+			check(mapping, metrics.CounterMetricType, metrics.GaugeMetricType, observerType)
+		}
+		for _, observer := range mapping.AdditionalObservers {
+			om := observer.Mapping()
+			check(om, observerMetricTypes[om.ObserverType])
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// forwardTagReplacer replaces the characters that cannot be part of a
+// DogStatsD tag.
+var forwardTagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// ForwardLines serializes the series selected for forwarding by their mapping
+// as StatsD lines with DogStatsD tags, ordered by metric name. Counters are
+// sent as the increase since they were last forwarded, so that the receiver
+// adds up the counts of all senders. Gauges are sent as their current value.
+// Histograms and summaries are not forwarded, as their observations are no
+// longer known.
+func (r *Registry) ForwardLines() []string {
+	names := make([]string, 0, len(r.Metrics))
+	for name, metric := range r.Metrics {
+		if metric.MetricType == metrics.CounterMetricType || metric.MetricType == metrics.GaugeMetricType {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		metric := r.Metrics[name]
+		var series []forwardSeries
+		for _, rm := range metric.Metrics {
+			if rm.Forward {
+				series = append(series, forwardSeries{rm: rm, tags: forwardTags(rm)})
+			}
+		}
+		sort.Slice(series, func(i, j int) bool { return series[i].tags < series[j].tags })
+
+		for _, s := range series {
+			value := currentValue(s.rm.Metric)
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			if metric.MetricType == metrics.CounterMetricType {
+				increase := value - s.rm.ForwardedValue
+				if increase < 0 {
+					// The counter was reset.
+					increase = value
+				}
+				s.rm.ForwardedValue = value
+				lines = append(lines, name+":"+formatForwardValue(increase)+"|c"+s.tags)
+				continue
+			}
+			if value < 0 {
+				// A negative value would be taken for a decrement, so the
+				// gauge is set to zero first.
+				lines = append(lines, name+":0|g"+s.tags)
+			}
+			lines = append(lines, name+":"+formatForwardValue(value)+"|g"+s.tags)
+		}
+	}
+	return lines
+}
+
+type forwardSeries struct {
+	rm   *metrics.RegisteredMetric
+	tags string
+}
+
+// forwardTags returns the labels of a series as DogStatsD tags, ordered by
+// label name.
+func forwardTags(rm *metrics.RegisteredMetric) string {
+	if len(rm.Labels) == 0 {
+		return ""
+	}
+	tags := make([]string, 0, len(rm.Labels))
+	for name, value := range rm.Labels {
+		tags = append(tags, name+":"+forwardTagReplacer.Replace(value))
+	}
+	sort.Strings(tags)
+	return "|#" + strings.Join(tags, ",")
+}
+
+func formatForwardValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,194 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// gaugeHistogramSuffixes are appended to the metric name for the samples of a
+// gauge histogram.
+var gaugeHistogramSuffixes = []string{"_bucket", "_gcount", "_gsum"}
+
+// GaugeHistogramVec exports the distribution of the observations made in each
+// window of time. Unlike the buckets of a histogram, which count all
+// observations ever made, the buckets show the observations of the last
+// complete window only, and go down again when fewer observations are made.
+//
+// The client library has no gauge histogram type, so the vector collects
+// histograms. Gatherers must change the type of its metric family to
+// GAUGE_HISTOGRAM, see Registry.IsGaugeHistogram.
+type GaugeHistogramVec struct {
+	desc       *prometheus.Desc
+	labelNames []string
+	buckets    []float64
+	window     time.Duration
+
+	mtx        sync.Mutex
+	histograms map[string]*gaugeHistogram
+}
+
+func NewGaugeHistogramVec(name, help string, labelNames []string, buckets []float64, window time.Duration) *GaugeHistogramVec {
+	// The +Inf bucket is implied by the count.
+	var upperBounds []float64
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) {
+			upperBounds = append(upperBounds, b)
+		}
+	}
+	sort.Float64s(upperBounds)
+	return &GaugeHistogramVec{
+		desc:       prometheus.NewDesc(name, help, labelNames, nil),
+		labelNames: labelNames,
+		buckets:    upperBounds,
+		window:     window,
+		histograms: make(map[string]*gaugeHistogram),
+	}
+}
+
+// GetMetricWith returns the gauge histogram for the given labels, creating it
+// if needed. The label names must match those of the vector.
+func (v *GaugeHistogramVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	key, values, err := labelValuesKey(v.labelNames, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	h, ok := v.histograms[key]
+	if !ok {
+		h = &gaugeHistogram{
+			buckets:     v.buckets,
+			labelValues: values,
+			window:      window{length: v.window, start: clock.Now()},
+			current:     make([]uint64, len(v.buckets)),
+		}
+		v.histograms[key] = h
+	}
+	return h, nil
+}
+
+// Delete removes the gauge histogram for the given labels.
+func (v *GaugeHistogramVec) Delete(labels prometheus.Labels) bool {
+	key, _, err := labelValuesKey(v.labelNames, labels)
+	if err != nil {
+		return false
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if _, ok := v.histograms[key]; !ok {
+		return false
+	}
+	delete(v.histograms, key)
+	return true
+}
+
+func (v *GaugeHistogramVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+func (v *GaugeHistogramVec) Collect(ch chan<- prometheus.Metric) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, h := range v.histograms {
+		count, sum, buckets := h.lastWindow()
+		ch <- prometheus.MustNewConstHistogram(v.desc, count, sum, buckets, h.labelValues...)
+	}
+}
+
+// gaugeHistogram counts the observations for one set of labels per bucket.
+// Like aggregatedGauges, the window is rolled over lazily.
+type gaugeHistogram struct {
+	buckets     []float64
+	labelValues []string
+
+	mtx    sync.Mutex
+	window window
+	// current and last hold the number of observations per bucket, not
+	// cumulative. Observations above the highest bucket are only counted in
+	// the totals.
+	current, last           []uint64
+	currentCount, lastCount uint64
+	currentSum, lastSum     float64
+	// observations is the total number of observations, used to tell whether
+	// the histogram changed.
+	observations uint64
+}
+
+func (h *gaugeHistogram) Observe(value float64) {
+	h.ObserveMany(value, 1)
+}
+
+// ObserveMany records the same observation n times.
+func (h *gaugeHistogram) ObserveMany(value float64, n int) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.rollOver()
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.current[i] += uint64(n)
+	}
+	h.currentCount += uint64(n)
+	h.currentSum += value * float64(n)
+	h.observations += uint64(n)
+}
+
+// lastWindow returns the count, sum and cumulative bucket counts of the last
+// complete window.
+func (h *gaugeHistogram) lastWindow() (uint64, float64, map[float64]uint64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.rollOver()
+	buckets := make(map[float64]uint64, len(h.buckets))
+	var cumulative uint64
+	for i, upperBound := range h.buckets {
+		if h.last != nil {
+			cumulative += h.last[i]
+		}
+		buckets[upperBound] = cumulative
+	}
+	return h.lastCount, h.lastSum, buckets
+}
+
+func (h *gaugeHistogram) observationCount() uint64 {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return h.observations
+}
+
+// rollOver completes the current window if it has ended. If more than one
+// window has passed, the last complete window was empty.
+func (h *gaugeHistogram) rollOver() {
+	ended, skipped := h.window.roll()
+	if !ended {
+		return
+	}
+	h.last, h.lastCount, h.lastSum = h.current, h.currentCount, h.currentSum
+	if skipped {
+		h.last, h.lastCount, h.lastSum = nil, 0, 0
+	}
+	h.current, h.currentCount, h.currentSum = make([]uint64, len(h.buckets)), 0, 0
+}
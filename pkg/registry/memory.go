@@ -0,0 +1,186 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// The sizes that memory usage estimates are made of. They approximate what
+// the registry and client_golang keep for a series on 64-bit platforms, and
+// are meant to rank metrics by memory usage, not to account for every byte.
+const (
+	// seriesBytes is the bookkeeping of a series in the registry and in its
+	// vector, besides its labels and samples.
+	seriesBytes = 384
+	// labelBytes is a label besides its name and value: its entries in the
+	// label map of the series and in the label values of the vector.
+	labelBytes = 64
+	// valueBytes is the value of a counter or gauge.
+	valueBytes = 64
+	// bucketBytes is a histogram bucket: its upper bound and counts.
+	bucketBytes = 24
+	// summaryBytes is the sample buffers of a summary, and quantileBytes the
+	// compressed stream of each of its quantiles.
+	summaryBytes  = 8192
+	quantileBytes = 1024
+	// windowBytes is the sliding window of aggregated gauges, gauge
+	// histograms and rates.
+	windowBytes = 1024
+)
+
+// estimateSeriesBytes estimates the memory used by a new series.
+func estimateSeriesBytes(labels prometheus.Labels, mh metrics.MetricHolder, metricType metrics.MetricType) int {
+	n := seriesBytes
+	for name, value := range labels {
+		// The value is held by both the series and the vector.
+		n += labelBytes + len(name) + 2*len(value)
+	}
+
+	switch metricType {
+	case metrics.CounterMetricType, metrics.GaugeMetricType:
+		n += valueBytes
+	case metrics.HistogramMetricType:
+		n += valueBytes + bucketBytes*bucketCount(mh)
+	case metrics.GaugeHistogramMetricType:
+		n += windowBytes + bucketBytes*bucketCount(mh)
+	case metrics.SummaryMetricType:
+		n += summaryBytes + quantileBytes*quantileCount(mh)
+	case metrics.SumAndCountMetricType:
+		n += 2 * valueBytes
+	case metrics.AggregatedGaugesMetricType, metrics.RateMetricType:
+		n += windowBytes
+	}
+	return n
+}
+
+// bucketCount returns the number of buckets of a histogram series.
+func bucketCount(mh metrics.MetricHolder) int {
+	if h, ok := mh.(*gaugeHistogram); ok {
+		return len(h.buckets) + 1
+	}
+	m, ok := mh.(prometheus.Metric)
+	if !ok {
+		return 0
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return 0
+	}
+	return len(pb.GetHistogram().GetBucket()) + 1
+}
+
+// quantileCount returns the number of quantiles of a summary series.
+func quantileCount(mh metrics.MetricHolder) int {
+	m, ok := mh.(prometheus.Metric)
+	if !ok {
+		return 0
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return 0
+	}
+	return len(pb.GetSummary().GetQuantile())
+}
+
+// MemoryUsage is the estimated memory used by the series of a metric, a
+// mapping, or a metric type.
+type MemoryUsage struct {
+	Series int `json:"series"`
+	Bytes  int `json:"bytes"`
+}
+
+// MetricMemoryUsage is the estimated memory usage of a metric family.
+type MetricMemoryUsage struct {
+	MetricName string `json:"metricName"`
+	Type       string `json:"type"`
+	MemoryUsage
+}
+
+// MappingMemoryUsage is the estimated memory usage of the series created for
+// a mapping. Unmapped metrics are accounted to a mapping with an empty
+// match and name.
+type MappingMemoryUsage struct {
+	MappingOrigin
+	MemoryUsage
+}
+
+// MemoryReport breaks down the estimated memory usage of the series of a
+// registry, each list ordered by decreasing usage.
+type MemoryReport struct {
+	Total    MemoryUsage          `json:"total"`
+	Types    map[string]int       `json:"types"`
+	Metrics  []MetricMemoryUsage  `json:"metrics"`
+	Mappings []MappingMemoryUsage `json:"mappings"`
+}
+
+// MemoryUsage returns the estimated memory usage of the series of the
+// registry by metric, mapping and type. The usage of a series is estimated
+// when it is created, from its labels and the structure of its samples.
+func (r *Registry) MemoryUsage() MemoryReport {
+	report := MemoryReport{
+		Types:    map[string]int{},
+		Metrics:  []MetricMemoryUsage{},
+		Mappings: []MappingMemoryUsage{},
+	}
+	mappings := map[MappingOrigin]*MemoryUsage{}
+	for name, metric := range r.Metrics {
+		if len(metric.Metrics) == 0 {
+			continue
+		}
+		usage := MemoryUsage{Series: len(metric.Metrics), Bytes: r.estimatedBytes[name]}
+		typ := metadataTypes[metric.MetricType]
+		report.Metrics = append(report.Metrics, MetricMemoryUsage{MetricName: name, Type: typ, MemoryUsage: usage})
+		report.Types[typ] += usage.Bytes
+		report.Total.Series += usage.Series
+		report.Total.Bytes += usage.Bytes
+
+		for _, rm := range metric.Metrics {
+			origin := MappingOrigin{Match: rm.Match, Name: rm.Mapping}
+			m, ok := mappings[origin]
+			if !ok {
+				m = &MemoryUsage{}
+				mappings[origin] = m
+			}
+			m.Series++
+			m.Bytes += rm.EstimatedBytes
+		}
+	}
+	for origin, usage := range mappings {
+		report.Mappings = append(report.Mappings, MappingMemoryUsage{MappingOrigin: origin, MemoryUsage: *usage})
+	}
+
+	sort.Slice(report.Metrics, func(i, j int) bool {
+		if report.Metrics[i].Bytes != report.Metrics[j].Bytes {
+			return report.Metrics[i].Bytes > report.Metrics[j].Bytes
+		}
+		return report.Metrics[i].MetricName < report.Metrics[j].MetricName
+	})
+	sort.Slice(report.Mappings, func(i, j int) bool {
+		a, b := report.Mappings[i], report.Mappings[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Match != b.Match {
+			return a.Match < b.Match
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
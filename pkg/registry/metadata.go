@@ -0,0 +1,117 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"sort"
+
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// MetricMetadata describes an exposed metric family.
+type MetricMetadata struct {
+	MetricName string `json:"metricName"`
+	// Type is the type of the family as exposed, such as "counter" or
+	// "histogram".
+	Type string `json:"type"`
+	Help string `json:"help"`
+	// Labels are the label names used by the series of the family.
+	Labels []string `json:"labels"`
+	// Mappings are the mappings the series of the family were created for.
+	// It is empty for unmapped metrics.
+	Mappings []MappingOrigin `json:"mappings"`
+}
+
+// MappingOrigin identifies a mapping.
+type MappingOrigin struct {
+	Match string `json:"match"`
+	Name  string `json:"name"`
+}
+
+var metadataTypes = map[metrics.MetricType]string{
+	metrics.CounterMetricType:          "counter",
+	metrics.GaugeMetricType:            "gauge",
+	metrics.SummaryMetricType:          "summary",
+	metrics.HistogramMetricType:        "histogram",
+	metrics.AggregatedGaugesMetricType: "gauge",
+	metrics.GaugeHistogramMetricType:   "gaugehistogram",
+	metrics.SumAndCountMetricType:      "summary",
+	metrics.RateMetricType:             "gauge",
+}
+
+// Metadata returns the metadata of the metric families that currently have
+// series, ordered by name. Aggregated gauges are exposed as one gauge family
+// per aggregation, and are described as such.
+func (r *Registry) Metadata() []MetricMetadata {
+	var metadata []MetricMetadata
+	for name, metric := range r.Metrics {
+		if len(metric.Metrics) == 0 {
+			continue
+		}
+
+		labelSet := map[string]struct{}{}
+		originSet := map[MappingOrigin]struct{}{}
+		for _, rm := range metric.Metrics {
+			for label := range rm.Labels {
+				labelSet[label] = struct{}{}
+			}
+			if rm.Match != "" {
+				originSet[MappingOrigin{Match: rm.Match, Name: rm.Mapping}] = struct{}{}
+			}
+		}
+		labels := make([]string, 0, len(labelSet))
+		for label := range labelSet {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		origins := make([]MappingOrigin, 0, len(originSet))
+		for origin := range originSet {
+			origins = append(origins, origin)
+		}
+		sort.Slice(origins, func(i, j int) bool {
+			if origins[i].Match != origins[j].Match {
+				return origins[i].Match < origins[j].Match
+			}
+			return origins[i].Name < origins[j].Name
+		})
+
+		names := []string{name}
+		if metric.MetricType == metrics.AggregatedGaugesMetricType {
+			names = names[:0]
+			suffixes := aggregatedGaugesSuffixes(nil)
+			for _, v := range metric.Vectors {
+				if vec, ok := v.Holder.(*AggregatedGaugesVec); ok {
+					suffixes = vec.Suffixes()
+					break
+				}
+			}
+			for _, suffix := range suffixes {
+				names = append(names, name+suffix)
+			}
+		}
+		for _, n := range names {
+			metadata = append(metadata, MetricMetadata{
+				MetricName: n,
+				Type:       metadataTypes[metric.MetricType],
+				Help:       metric.Help,
+				Labels:     labels,
+				Mappings:   origins,
+			})
+		}
+	}
+	sort.Slice(metadata, func(i, j int) bool {
+		return metadata[i].MetricName < metadata[j].MetricName
+	})
+	return metadata
+}
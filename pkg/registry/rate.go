@@ -0,0 +1,203 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// rateSuffix is appended to the name of a counter for its rate gauge.
+const rateSuffix = "_rate"
+
+// rateSlots is the number of slots a rate window is divided into. The window
+// slides by one slot at a time.
+const rateSlots = 10
+
+// RateVec exports the per-second rate of the increments of a counter over a
+// sliding window as a gauge, for consumers that cannot compute rates
+// themselves.
+type RateVec struct {
+	desc       *prometheus.Desc
+	labelNames []string
+	window     time.Duration
+
+	mtx   sync.Mutex
+	rates map[string]*Rate
+}
+
+func NewRateVec(name, help string, labelNames []string, window time.Duration) *RateVec {
+	return &RateVec{
+		desc:       prometheus.NewDesc(name, help, labelNames, nil),
+		labelNames: labelNames,
+		window:     window,
+		rates:      make(map[string]*Rate),
+	}
+}
+
+// GetMetricWith returns the rate for the given labels, creating it if
+// needed. The label names must match those of the vector.
+func (v *RateVec) GetMetricWith(labels prometheus.Labels) (*Rate, error) {
+	key, values, err := labelValuesKey(v.labelNames, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	r, ok := v.rates[key]
+	if !ok {
+		r = &Rate{slotLength: v.window / rateSlots, slotStart: clock.Now(), labelValues: values}
+		v.rates[key] = r
+	}
+	return r, nil
+}
+
+// Delete removes the rate for the given labels.
+func (v *RateVec) Delete(labels prometheus.Labels) bool {
+	key, _, err := labelValuesKey(v.labelNames, labels)
+	if err != nil {
+		return false
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if _, ok := v.rates[key]; !ok {
+		return false
+	}
+	delete(v.rates, key)
+	return true
+}
+
+func (v *RateVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+func (v *RateVec) Collect(ch chan<- prometheus.Metric) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, r := range v.rates {
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.GaugeValue, r.sum()/v.window.Seconds(), r.labelValues...)
+	}
+}
+
+// Rate sums the increments of a counter in the slots of a sliding window.
+// The slots are rolled over lazily, when an increment is added or the rate
+// is collected.
+type Rate struct {
+	labelValues []string
+
+	mtx        sync.Mutex
+	slotLength time.Duration
+	slotStart  time.Time
+	current    int
+	slots      [rateSlots]float64
+	// total is the sum of all increments, used to tell whether the rate
+	// changed.
+	total float64
+}
+
+// Add adds an increment of the counter.
+func (r *Rate) Add(v float64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.roll()
+	r.slots[r.current] += v
+	r.total += v
+}
+
+// Total returns the sum of all increments.
+func (r *Rate) Total() float64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.total
+}
+
+// sum returns the sum of the increments in the window.
+func (r *Rate) sum() float64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.roll()
+	var sum float64
+	for _, v := range r.slots {
+		sum += v
+	}
+	return sum
+}
+
+// roll moves on to the slot containing the current time, clearing the slots
+// that were skipped.
+func (r *Rate) roll() {
+	elapsed := clock.Now().Sub(r.slotStart)
+	if elapsed < r.slotLength {
+		return
+	}
+	n := int(elapsed / r.slotLength)
+	if n >= rateSlots {
+		r.slots = [rateSlots]float64{}
+	} else {
+		for i := 0; i < n; i++ {
+			r.current = (r.current + 1) % rateSlots
+			r.slots[r.current] = 0
+		}
+	}
+	r.slotStart = r.slotStart.Add(time.Duration(n) * r.slotLength)
+}
+
+// GetRate returns the rate gauge of a counter, named like the counter with
+// the `_rate` suffix, for mappings with a rate window.
+func (r *Registry) GetRate(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (*Rate, error) {
+	metricName += rateSuffix
+	hash, labelNames := r.HashLabels(labels)
+	vh, mh := r.Get(metricName, hash, metrics.RateMetricType)
+	if mh != nil {
+		return mh.(*Rate), nil
+	}
+	help = r.familyHelp(metricName, help, mapping)
+
+	if r.MetricConflicts(metricName, metrics.RateMetricType) {
+		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
+	}
+
+	var rateVec *RateVec
+	if vh == nil {
+		metricsCount.WithLabelValues("rate").Inc()
+		rateVec = NewRateVec(metricName, help, labelNames, mapping.RateWindow)
+		if err := r.registerer(mapping).Register(uncheckedCollector{rateVec}); err != nil {
+			return nil, err
+		}
+	} else {
+		rateVec = vh.(*RateVec)
+	}
+
+	rate, err := rateVec.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	r.Store(metricName, hash, labels, rateVec, rate, metrics.RateMetricType, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
+
+	return rate, nil
+}
@@ -18,15 +18,18 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"math"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/model"
 
+	"github.com/prometheus/statsd_exporter/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
-	"github.com/prometheus/statsd_exporter/pkg/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/metrics"
 )
 
@@ -43,13 +46,66 @@ func (u uncheckedCollector) Collect(c chan<- prometheus.Metric) {
 
 type Registry struct {
 	Registerer prometheus.Registerer
-	Metrics    map[string]metrics.Metric
-	Mapper     *mapper.MetricMapper
+	// Registerers, if set, returns the registerer of a named registry, for
+	// the metrics of mappings that select one. Without it, all metrics are
+	// registered with Registerer.
+	Registerers func(name string) prometheus.Registerer
+	Metrics     map[string]metrics.Metric
+	Mapper      *mapper.MetricMapper
 	// The below value and label variables are allocated in the registry struct
 	// so that we don't have to allocate them every time have to compute a label
 	// hash.
 	ValueBuf, NameBuf bytes.Buffer
 	Hasher            hash.Hash64
+
+	// OnExpire, if set, is called for every time series that is removed
+	// because its TTL elapsed. It is called from the goroutine removing stale
+	// metrics, which also updates the registry, so it must not block.
+	OnExpire func(ExpiredSeries)
+	// OnHelpConflict, if set, is called the first time a series of a metric
+	// is created with another help text than the metric has. The series gets
+	// the help text of the metric.
+	OnHelpConflict func(HelpConflict)
+
+	// gaugeHistograms holds the names of gauge histogram metrics.
+	gaugeHistograms sync.Map
+	// received holds when the metrics whose mapping tracks it were first
+	// and last received. Unlike series, it is kept when the series expire.
+	received map[string]*Received
+	// estimatedBytes is the estimated memory used by the series of each
+	// metric.
+	estimatedBytes map[string]int
+}
+
+// Received is when a metric was first and last received.
+type Received struct {
+	First, Last time.Time
+}
+
+// HelpConflict describes a series that was created with another help text
+// than the one its metric has.
+type HelpConflict struct {
+	MetricName string
+	// Help is the help text of the metric, which the series gets.
+	Help            string
+	ConflictingHelp string
+	// Match is the match of the mapping the series was created for, empty
+	// for unmapped metrics.
+	Match string
+}
+
+// ExpiredSeries describes a time series that was removed because its TTL
+// elapsed, or a window of the schedule of its mapping ended.
+type ExpiredSeries struct {
+	MetricName string
+	MetricType metrics.MetricType
+	Labels     prometheus.Labels
+	// Mapping is the name template of the mapping the series was created
+	// for, empty for unmapped metrics.
+	Mapping string
+	// LastActive is when the series was last updated, or last changed for
+	// series that expire when they do not change.
+	LastActive time.Time
 }
 
 func NewRegistry(reg prometheus.Registerer, mapper *mapper.MetricMapper) *Registry {
@@ -61,6 +117,14 @@ func NewRegistry(reg prometheus.Registerer, mapper *mapper.MetricMapper) *Regist
 	}
 }
 
+// registerer returns the registerer for the metrics of a mapping.
+func (r *Registry) registerer(mapping *mapper.MetricMapping) prometheus.Registerer {
+	if r.Registerers == nil || mapping == nil || mapping.Registry == "" {
+		return r.Registerer
+	}
+	return r.Registerers(mapping.Registry)
+}
+
 func (r *Registry) MetricConflicts(metricName string, metricType metrics.MetricType) bool {
 	vector, hasMetrics := r.Metrics[metricName]
 	if !hasMetrics {
@@ -79,23 +143,35 @@ func (r *Registry) MetricConflicts(metricName string, metricType metrics.MetricT
 	return true
 }
 
-func (r *Registry) StoreCounter(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.CounterVec, c prometheus.Counter, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, c, metrics.CounterMetricType, ttl)
+func (r *Registry) StoreCounter(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.CounterVec, c prometheus.Counter, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, c, metrics.CounterMetricType, ttl, expireOn)
 }
 
-func (r *Registry) StoreGauge(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.GaugeVec, g prometheus.Gauge, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, g, metrics.GaugeMetricType, ttl)
+func (r *Registry) StoreGauge(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.GaugeVec, g prometheus.Gauge, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, g, metrics.GaugeMetricType, ttl, expireOn)
 }
 
-func (r *Registry) StoreHistogram(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.HistogramVec, o prometheus.Observer, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, o, metrics.HistogramMetricType, ttl)
+func (r *Registry) StoreHistogram(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec metrics.VectorHolder, o prometheus.Observer, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, o, metrics.HistogramMetricType, ttl, expireOn)
 }
 
-func (r *Registry) StoreSummary(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.SummaryVec, o prometheus.Observer, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, o, metrics.SummaryMetricType, ttl)
+func (r *Registry) StoreSummary(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.SummaryVec, o prometheus.Observer, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, o, metrics.SummaryMetricType, ttl, expireOn)
 }
 
-func (r *Registry) Store(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vh metrics.VectorHolder, mh metrics.MetricHolder, metricType metrics.MetricType, ttl time.Duration) {
+func (r *Registry) StoreSumAndCount(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec metrics.VectorHolder, o prometheus.Observer, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, o, metrics.SumAndCountMetricType, ttl, expireOn)
+}
+
+func (r *Registry) StoreAggregatedGauges(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *AggregatedGaugesVec, o prometheus.Observer, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, o, metrics.AggregatedGaugesMetricType, ttl, expireOn)
+}
+
+func (r *Registry) StoreGaugeHistogram(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *GaugeHistogramVec, o prometheus.Observer, ttl time.Duration, expireOn mapper.ExpireOnType) {
+	r.Store(metricName, hash, labels, vec, o, metrics.GaugeHistogramMetricType, ttl, expireOn)
+}
+
+func (r *Registry) Store(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vh metrics.VectorHolder, mh metrics.MetricHolder, metricType metrics.MetricType, ttl time.Duration, expireOn mapper.ExpireOnType) {
 	metric, hasMetrics := r.Metrics[metricName]
 	if !hasMetrics {
 		metric.MetricType = metricType
@@ -118,16 +194,72 @@ func (r *Registry) Store(metricName string, hash metrics.LabelHash, labels prome
 			LastRegisteredAt: now,
 			Labels:           labels,
 			TTL:              ttl,
+			ExpireOnNoChange: expireOn == mapper.ExpireOnNoChange,
+			LastChangedAt:    now,
+			LastValue:        currentValue(mh),
 			Metric:           mh,
 			VecKey:           hash.Names,
+			EstimatedBytes:   estimateSeriesBytes(labels, mh, metricType),
 		}
+		trackChanges(rm, metricType)
 		metric.Metrics[hash.Values] = rm
 		v.RefCount++
+		if r.estimatedBytes == nil {
+			r.estimatedBytes = map[string]int{}
+		}
+		r.estimatedBytes[metricName] += rm.EstimatedBytes
 		return
 	}
 	rm.LastRegisteredAt = now
 	// Update ttl from mapping
 	rm.TTL = ttl
+	if expireOn == mapper.ExpireOnNoChange && !rm.ExpireOnNoChange {
+		// Changes were not recorded until now.
+		rm.LastChangedAt = now
+	}
+	rm.ExpireOnNoChange = expireOn == mapper.ExpireOnNoChange
+}
+
+// setMapping records the mapping a newly stored series was created for, and
+// the help text of its metric.
+func (r *Registry) setMapping(metricName string, hash metrics.LabelHash, help string, mapping *mapper.MetricMapping) {
+	metric := r.Metrics[metricName]
+	if rm, ok := metric.Metrics[hash.Values]; ok {
+		rm.Mapping = mapping.NameTemplate()
+		rm.Match = mapping.Match
+		rm.Forward = mapping.Forward
+		if mapping.Schedule != nil && mapping.Schedule.Expire {
+			rm.ExpireSchedule = mapping.Schedule
+		}
+	}
+	if metric.Help == "" {
+		metric.Help = help
+		r.Metrics[metricName] = metric
+	}
+}
+
+// familyHelp returns the help text for a new series of a metric. All series
+// of a metric share the help text of the series that created it, so that the
+// metric is exposed consistently whatever order events arrive in. A differing
+// help text is reported once to OnHelpConflict.
+func (r *Registry) familyHelp(metricName, help string, mapping *mapper.MetricMapping) string {
+	metric, ok := r.Metrics[metricName]
+	if !ok || metric.Help == "" || metric.Help == help {
+		return help
+	}
+	if !metric.HelpConflict {
+		metric.HelpConflict = true
+		r.Metrics[metricName] = metric
+		if r.OnHelpConflict != nil {
+			r.OnHelpConflict(HelpConflict{
+				MetricName:      metricName,
+				Help:            metric.Help,
+				ConflictingHelp: help,
+				Match:           mapping.Match,
+			})
+		}
+	}
+	return metric.Help
 }
 
 func (r *Registry) Get(metricName string, hash metrics.LabelHash, metricType metrics.MetricType) (metrics.VectorHolder, metrics.MetricHolder) {
@@ -144,6 +276,11 @@ func (r *Registry) Get(metricName string, hash metrics.LabelHash, metricType met
 	if ok {
 		now := clock.Now()
 		rm.LastRegisteredAt = now
+		// Counters and gauges record their own changes, other series change
+		// with every update.
+		if rm.ExpireOnNoChange && metricType != metrics.CounterMetricType && metricType != metrics.GaugeMetricType {
+			rm.LastChangedAt = now
+		}
 		return metric.Vectors[hash.Names].Holder, rm.Metric
 	}
 
@@ -161,6 +298,7 @@ func (r *Registry) GetCounter(metricName string, labels prometheus.Labels, help
 	if mh != nil {
 		return mh.(prometheus.Counter), nil
 	}
+	help = r.familyHelp(metricName, help, mapping)
 
 	if r.MetricConflicts(metricName, metrics.CounterMetricType) {
 		return nil, fmt.Errorf("metric with name %s is already registered", metricName)
@@ -179,7 +317,7 @@ func (r *Registry) GetCounter(metricName string, labels prometheus.Labels, help
 			Help: help,
 		}, labelNames)
 
-		if err := r.Registerer.Register(uncheckedCollector{counterVec}); err != nil {
+		if err := r.registerer(mapping).Register(uncheckedCollector{counterVec}); err != nil {
 			return nil, err
 		}
 	} else {
@@ -190,9 +328,10 @@ func (r *Registry) GetCounter(metricName string, labels prometheus.Labels, help
 	if counter, err = counterVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreCounter(metricName, hash, labels, counterVec, counter, mapping.Ttl)
+	r.StoreCounter(metricName, hash, labels, counterVec, counter, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
 
-	return counter, nil
+	return r.Metrics[metricName].Metrics[hash.Values].Metric.(prometheus.Counter), nil
 }
 
 func (r *Registry) checkHistogramNameCollision(metricName string) error {
@@ -213,6 +352,7 @@ func (r *Registry) GetGauge(metricName string, labels prometheus.Labels, help st
 	if mh != nil {
 		return mh.(prometheus.Gauge), nil
 	}
+	help = r.familyHelp(metricName, help, mapping)
 
 	if r.MetricConflicts(metricName, metrics.GaugeMetricType) {
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
@@ -231,7 +371,7 @@ func (r *Registry) GetGauge(metricName string, labels prometheus.Labels, help st
 			Help: help,
 		}, labelNames)
 
-		if err := r.Registerer.Register(uncheckedCollector{gaugeVec}); err != nil {
+		if err := r.registerer(mapping).Register(uncheckedCollector{gaugeVec}); err != nil {
 			return nil, err
 		}
 	} else {
@@ -242,9 +382,17 @@ func (r *Registry) GetGauge(metricName string, labels prometheus.Labels, help st
 	if gauge, err = gaugeVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreGauge(metricName, hash, labels, gaugeVec, gauge, mapping.Ttl)
+	r.StoreGauge(metricName, hash, labels, gaugeVec, gauge, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
+
+	return r.Metrics[metricName].Metrics[hash.Values].Metric.(prometheus.Gauge), nil
+}
 
-	return gauge, nil
+// observerVec is a vector of observers, such as histograms.
+type observerVec interface {
+	prometheus.Collector
+	metrics.VectorHolder
+	GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error)
 }
 
 func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error) {
@@ -253,6 +401,7 @@ func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, hel
 	if mh != nil {
 		return mh.(prometheus.Observer), nil
 	}
+	help = r.familyHelp(metricName, help, mapping)
 
 	if r.MetricConflicts(metricName, metrics.HistogramMetricType) {
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
@@ -267,7 +416,7 @@ func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, hel
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
 	}
 
-	var histogramVec *prometheus.HistogramVec
+	var histogramVec observerVec
 	if vh == nil {
 		metricsCount.WithLabelValues("histogram").Inc()
 		buckets := r.Mapper.Defaults.HistogramOptions.Buckets
@@ -284,19 +433,23 @@ func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, hel
 		if mapping.HistogramOptions != nil && mapping.HistogramOptions.NativeHistogramMaxBuckets > 0 {
 			maxBuckets = mapping.HistogramOptions.NativeHistogramMaxBuckets
 		}
-		histogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                           metricName,
-			Help:                           help,
-			Buckets:                        buckets,
-			NativeHistogramBucketFactor:    bucketFactor,
-			NativeHistogramMaxBucketNumber: maxBuckets,
-		}, labelNames)
+		if rate := mapping.SampleObservations; rate > 0 && rate < 1 {
+			histogramVec = NewSampledHistogramVec(metricName, help, labelNames, buckets)
+		} else {
+			histogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:                           metricName,
+				Help:                           help,
+				Buckets:                        buckets,
+				NativeHistogramBucketFactor:    bucketFactor,
+				NativeHistogramMaxBucketNumber: maxBuckets,
+			}, labelNames)
+		}
 
-		if err := r.Registerer.Register(uncheckedCollector{histogramVec}); err != nil {
+		if err := r.registerer(mapping).Register(uncheckedCollector{histogramVec}); err != nil {
 			return nil, err
 		}
 	} else {
-		histogramVec = vh.(*prometheus.HistogramVec)
+		histogramVec = vh.(observerVec)
 	}
 
 	var observer prometheus.Observer
@@ -304,7 +457,8 @@ func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, hel
 	if observer, err = histogramVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreHistogram(metricName, hash, labels, histogramVec, observer, mapping.Ttl)
+	r.StoreHistogram(metricName, hash, labels, histogramVec, observer, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
 
 	return observer, nil
 }
@@ -315,6 +469,7 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 	if mh != nil {
 		return mh.(prometheus.Observer), nil
 	}
+	help = r.familyHelp(metricName, help, mapping)
 
 	if r.MetricConflicts(metricName, metrics.SummaryMetricType) {
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
@@ -361,7 +516,7 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 			BufCap:     summaryOptions.BufCap,
 		}, labelNames)
 
-		if err := r.Registerer.Register(uncheckedCollector{summaryVec}); err != nil {
+		if err := r.registerer(mapping).Register(uncheckedCollector{summaryVec}); err != nil {
 			return nil, err
 		}
 	} else {
@@ -373,28 +528,363 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 	if observer, err = summaryVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreSummary(metricName, hash, labels, summaryVec, observer, mapping.Ttl)
+	r.StoreSummary(metricName, hash, labels, summaryVec, observer, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
+
+	return observer, nil
+}
+
+// GetSumAndCount returns an observer that only records the sum and the number
+// of the observations. It is exported as a summary without quantiles.
+func (r *Registry) GetSumAndCount(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error) {
+	hash, labelNames := r.HashLabels(labels)
+	vh, mh := r.Get(metricName, hash, metrics.SumAndCountMetricType)
+	if mh != nil {
+		return mh.(prometheus.Observer), nil
+	}
+	help = r.familyHelp(metricName, help, mapping)
+
+	if r.MetricConflicts(metricName, metrics.SumAndCountMetricType) {
+		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
+	}
+	if r.MetricConflicts(metricName+"_sum", metrics.SumAndCountMetricType) {
+		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
+	}
+	if r.MetricConflicts(metricName+"_count", metrics.SumAndCountMetricType) {
+		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
+	}
+
+	var summaryVec observerVec
+	if vh == nil {
+		metricsCount.WithLabelValues("sum_and_count").Inc()
+		if rate := mapping.SampleObservations; rate > 0 && rate < 1 {
+			summaryVec = NewSampledSumAndCountVec(metricName, help, labelNames)
+		} else {
+			summaryVec = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+				Name: metricName,
+				Help: help,
+			}, labelNames)
+		}
+
+		if err := r.registerer(mapping).Register(uncheckedCollector{summaryVec}); err != nil {
+			return nil, err
+		}
+	} else {
+		summaryVec = vh.(observerVec)
+	}
+
+	observer, err := summaryVec.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	r.StoreSumAndCount(metricName, hash, labels, summaryVec, observer, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
+
+	return observer, nil
+}
+
+func (r *Registry) GetAggregatedGauges(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error) {
+	hash, labelNames := r.HashLabels(labels)
+	vh, mh := r.Get(metricName, hash, metrics.AggregatedGaugesMetricType)
+	if mh != nil {
+		return mh.(prometheus.Observer), nil
+	}
+	help = r.familyHelp(metricName, help, mapping)
+
+	if r.MetricConflicts(metricName, metrics.AggregatedGaugesMetricType) {
+		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
+	}
+	for _, suffix := range aggregatedGaugesSuffixes(mapping.Aggregates) {
+		if _, ok := r.Metrics[metricName+suffix]; ok {
+			return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName+suffix)
+		}
+	}
+
+	var aggregatedVec *AggregatedGaugesVec
+	if vh == nil {
+		metricsCount.WithLabelValues("aggregated_gauges").Inc()
+		window := r.Mapper.Defaults.AggregationWindow
+		if mapping.AggregationWindow > 0 {
+			window = mapping.AggregationWindow
+		}
+		if window <= 0 {
+			window = mapper.DefaultAggregationWindow
+		}
+		aggregatedVec = NewAggregatedGaugesVecWithAggregates(metricName, help, labelNames, window, mapping.Aggregates)
+
+		if err := r.registerer(mapping).Register(uncheckedCollector{aggregatedVec}); err != nil {
+			return nil, err
+		}
+	} else {
+		aggregatedVec = vh.(*AggregatedGaugesVec)
+	}
+
+	observer, err := aggregatedVec.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	r.StoreAggregatedGauges(metricName, hash, labels, aggregatedVec, observer, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
+
+	return observer, nil
+}
+
+func (r *Registry) GetGaugeHistogram(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error) {
+	hash, labelNames := r.HashLabels(labels)
+	vh, mh := r.Get(metricName, hash, metrics.GaugeHistogramMetricType)
+	if mh != nil {
+		return mh.(prometheus.Observer), nil
+	}
+	help = r.familyHelp(metricName, help, mapping)
+
+	if r.MetricConflicts(metricName, metrics.GaugeHistogramMetricType) {
+		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
+	}
+	for _, suffix := range gaugeHistogramSuffixes {
+		if _, ok := r.Metrics[metricName+suffix]; ok {
+			return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName+suffix)
+		}
+	}
+
+	var gaugeHistogramVec *GaugeHistogramVec
+	if vh == nil {
+		metricsCount.WithLabelValues("gaugehistogram").Inc()
+		buckets := r.Mapper.Defaults.HistogramOptions.Buckets
+		if mapping.HistogramOptions != nil && len(mapping.HistogramOptions.Buckets) > 0 {
+			buckets = mapping.HistogramOptions.Buckets
+		}
+		window := r.Mapper.Defaults.AggregationWindow
+		if mapping.AggregationWindow > 0 {
+			window = mapping.AggregationWindow
+		}
+		if window <= 0 {
+			window = mapper.DefaultAggregationWindow
+		}
+		gaugeHistogramVec = NewGaugeHistogramVec(metricName, help, labelNames, buckets, window)
+
+		if err := r.registerer(mapping).Register(uncheckedCollector{gaugeHistogramVec}); err != nil {
+			return nil, err
+		}
+		r.gaugeHistograms.Store(metricName, struct{}{})
+	} else {
+		gaugeHistogramVec = vh.(*GaugeHistogramVec)
+	}
+
+	observer, err := gaugeHistogramVec.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	r.StoreGaugeHistogram(metricName, hash, labels, gaugeHistogramVec, observer, mapping.Ttl, mapping.ExpireOn)
+	r.setMapping(metricName, hash, help, mapping)
 
 	return observer, nil
 }
 
+// IsGaugeHistogram reports whether the metric family with the given name holds
+// gauge histograms. Unlike the other methods, it is safe to call while events
+// are being processed, for example while gathering.
+func (r *Registry) IsGaugeHistogram(metricName string) bool {
+	_, ok := r.gaugeHistograms.Load(metricName)
+	return ok
+}
+
 func (r *Registry) RemoveStaleMetrics() {
 	now := clock.Now()
 	// delete timeseries with expired ttl
-	for _, metric := range r.Metrics {
+	for name, metric := range r.Metrics {
 		for hash, rm := range metric.Metrics {
+			if rm.ExpireSchedule != nil && !rm.ExpireSchedule.Active(now) {
+				r.removeSeries(name, metric, hash, rm)
+				if r.OnExpire != nil {
+					r.OnExpire(ExpiredSeries{
+						MetricName: name,
+						MetricType: metric.MetricType,
+						Labels:     rm.Labels,
+						Mapping:    rm.Mapping,
+						LastActive: rm.LastRegisteredAt,
+					})
+				}
+				continue
+			}
 			if rm.TTL == 0 {
 				continue
 			}
-			if rm.LastRegisteredAt.Add(rm.TTL).Before(now) {
-				metric.Vectors[rm.VecKey].Holder.Delete(rm.Labels)
-				metric.Vectors[rm.VecKey].RefCount--
-				delete(metric.Metrics, hash)
+			lastActive := rm.LastRegisteredAt
+			if rm.ExpireOnNoChange {
+				lastActive = rm.LastChangedAt
+			}
+			if lastActive.Add(rm.TTL).Before(now) {
+				r.removeSeries(name, metric, hash, rm)
+				if r.OnExpire != nil {
+					r.OnExpire(ExpiredSeries{
+						MetricName: name,
+						MetricType: metric.MetricType,
+						Labels:     rm.Labels,
+						Mapping:    rm.Mapping,
+						LastActive: lastActive,
+					})
+				}
 			}
 		}
 	}
 }
 
+// TrackReceived records that a metric was received now, and returns when it
+// was first and last received.
+func (r *Registry) TrackReceived(metricName string) Received {
+	now := clock.Now()
+	if r.received == nil {
+		r.received = map[string]*Received{}
+	}
+	received, ok := r.received[metricName]
+	if !ok {
+		received = &Received{First: now}
+		r.received[metricName] = received
+	}
+	received.Last = now
+	return *received
+}
+
+// ResetMetric removes all series of a metric along with its vectors, so that
+// the metric is created anew on its next use, for example with other buckets.
+func (r *Registry) ResetMetric(metricName string) {
+	metric, ok := r.Metrics[metricName]
+	if !ok {
+		return
+	}
+	for hash, rm := range metric.Metrics {
+		r.removeSeries(metricName, metric, hash, rm)
+	}
+	clear(metric.Vectors)
+}
+
+// CarrySampled adds the remainder carried over for a counter series to a
+// sampled increment, and returns the whole part of the sum, keeping its
+// fractional part for the next sampled increment. The remainder is dropped
+// with the series.
+func (r *Registry) CarrySampled(metricName string, labels prometheus.Labels, value float64) float64 {
+	metric, ok := r.Metrics[metricName]
+	if !ok {
+		return value
+	}
+	hash, _ := r.HashLabels(labels)
+	rm, ok := metric.Metrics[hash.Values]
+	if !ok {
+		return value
+	}
+	sum := rm.SampledRemainder + value
+	whole := math.Floor(sum)
+	rm.SampledRemainder = sum - whole
+	return whole
+}
+
+// RemoveMetric removes a metric with all its series and vectors, so that its
+// name can be registered again with another type.
+func (r *Registry) RemoveMetric(metricName string) {
+	r.ResetMetric(metricName)
+	delete(r.Metrics, metricName)
+	delete(r.estimatedBytes, metricName)
+	r.gaugeHistograms.Delete(metricName)
+}
+
+// MetricEventType returns the type of events that feed a metric, if it is
+// registered. Rates are derived from counters under their own names, and are
+// not fed by events directly.
+func (r *Registry) MetricEventType(metricName string) (mapper.MetricType, bool) {
+	metric, ok := r.Metrics[metricName]
+	if !ok {
+		return "", false
+	}
+	switch metric.MetricType {
+	case metrics.CounterMetricType:
+		return mapper.MetricTypeCounter, true
+	case metrics.GaugeMetricType:
+		return mapper.MetricTypeGauge, true
+	case metrics.RateMetricType:
+		return "", false
+	}
+	return mapper.MetricTypeObserver, true
+}
+
+// EvictLeastRecentlyUpdated removes the given fraction of time series, starting
+// with those that were updated least recently, and returns how many were
+// removed. Series of metrics for which protected returns true are neither
+// removed nor counted.
+func (r *Registry) EvictLeastRecentlyUpdated(fraction float64, protected func(metricName string) bool) int {
+	type candidate struct {
+		name   string
+		metric metrics.Metric
+		hash   metrics.ValueHash
+		rm     *metrics.RegisteredMetric
+	}
+	var candidates []candidate
+	for name, metric := range r.Metrics {
+		if protected != nil && protected(name) {
+			continue
+		}
+		for hash, rm := range metric.Metrics {
+			candidates = append(candidates, candidate{name, metric, hash, rm})
+		}
+	}
+
+	n := int(math.Ceil(fraction * float64(len(candidates))))
+	if n <= 0 {
+		return 0
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	// LastRegisteredAt is updated by every event for the series.
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].rm.LastRegisteredAt.Before(candidates[j].rm.LastRegisteredAt)
+	})
+	for _, c := range candidates[:n] {
+		r.removeSeries(c.name, c.metric, c.hash, c.rm)
+	}
+	return n
+}
+
+func (r *Registry) removeSeries(metricName string, metric metrics.Metric, hash metrics.ValueHash, rm *metrics.RegisteredMetric) {
+	metric.Vectors[rm.VecKey].Holder.Delete(rm.Labels)
+	metric.Vectors[rm.VecKey].RefCount--
+	delete(metric.Metrics, hash)
+	r.estimatedBytes[metricName] -= rm.EstimatedBytes
+}
+
+// currentValue returns the value of a counter or gauge, or the number of
+// observations of a histogram or summary. It is used to detect whether a
+// metric changed since it was last looked at.
+func currentValue(mh metrics.MetricHolder) float64 {
+	if g, ok := mh.(*aggregatedGauges); ok {
+		return float64(g.observationCount())
+	}
+	if h, ok := mh.(*gaugeHistogram); ok {
+		return float64(h.observationCount())
+	}
+	if r, ok := mh.(*Rate); ok {
+		return r.Total()
+	}
+	m, ok := mh.(prometheus.Metric)
+	if !ok {
+		return 0
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return 0
+	}
+	switch {
+	case pb.Gauge != nil:
+		return pb.Gauge.GetValue()
+	case pb.Counter != nil:
+		return pb.Counter.GetValue()
+	case pb.Histogram != nil:
+		return float64(pb.Histogram.GetSampleCount())
+	case pb.Summary != nil:
+		return float64(pb.Summary.GetSampleCount())
+	}
+	return 0
+}
+
 // Calculates a hash of both the label names and values.
 func (r *Registry) HashLabels(labels prometheus.Labels) (metrics.LabelHash, []string) {
 	r.Hasher.Reset()
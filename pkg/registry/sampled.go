@@ -0,0 +1,174 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// SampledObserverVec is a vector of histograms, or of sums and counts, for
+// mappings with sample_observations. The observers of the client library can
+// only record one observation at a time, so a kept observation that stands for
+// many would take as many calls. Its observers record it once with its weight
+// instead. Histograms only have classic buckets.
+type SampledObserverVec struct {
+	desc       *prometheus.Desc
+	labelNames []string
+	buckets    []float64
+	// summary exports sums and counts as summaries without quantiles.
+	summary bool
+
+	mtx       sync.Mutex
+	observers map[string]*sampledObserver
+}
+
+func NewSampledHistogramVec(name, help string, labelNames []string, buckets []float64) *SampledObserverVec {
+	// The +Inf bucket is implied by the count.
+	var upperBounds []float64
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) {
+			upperBounds = append(upperBounds, b)
+		}
+	}
+	sort.Float64s(upperBounds)
+	return &SampledObserverVec{
+		desc:       prometheus.NewDesc(name, help, labelNames, nil),
+		labelNames: labelNames,
+		buckets:    upperBounds,
+		observers:  make(map[string]*sampledObserver),
+	}
+}
+
+func NewSampledSumAndCountVec(name, help string, labelNames []string) *SampledObserverVec {
+	return &SampledObserverVec{
+		desc:       prometheus.NewDesc(name, help, labelNames, nil),
+		labelNames: labelNames,
+		summary:    true,
+		observers:  make(map[string]*sampledObserver),
+	}
+}
+
+// GetMetricWith returns the observer for the given labels, creating it if
+// needed. The label names must match those of the vector.
+func (v *SampledObserverVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	key, values, err := labelValuesKey(v.labelNames, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	o, ok := v.observers[key]
+	if !ok {
+		o = &sampledObserver{
+			desc:        v.desc,
+			buckets:     v.buckets,
+			summary:     v.summary,
+			labelValues: values,
+			counts:      make([]uint64, len(v.buckets)),
+		}
+		v.observers[key] = o
+	}
+	return o, nil
+}
+
+// Delete removes the observer for the given labels.
+func (v *SampledObserverVec) Delete(labels prometheus.Labels) bool {
+	key, _, err := labelValuesKey(v.labelNames, labels)
+	if err != nil {
+		return false
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if _, ok := v.observers[key]; !ok {
+		return false
+	}
+	delete(v.observers, key)
+	return true
+}
+
+func (v *SampledObserverVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+func (v *SampledObserverVec) Collect(ch chan<- prometheus.Metric) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, o := range v.observers {
+		ch <- o.constMetric()
+	}
+}
+
+// sampledObserver counts the observations for one set of labels per bucket.
+type sampledObserver struct {
+	desc        *prometheus.Desc
+	buckets     []float64
+	summary     bool
+	labelValues []string
+
+	mtx sync.Mutex
+	// counts holds the number of observations per bucket, not cumulative.
+	// Observations above the highest bucket are only counted in the total.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (o *sampledObserver) Observe(value float64) {
+	o.ObserveMany(value, 1)
+}
+
+// ObserveMany records the same observation n times.
+func (o *sampledObserver) ObserveMany(value float64, n int) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if i := sort.SearchFloat64s(o.buckets, value); i < len(o.buckets) {
+		o.counts[i] += uint64(n)
+	}
+	o.count += uint64(n)
+	o.sum += value * float64(n)
+}
+
+func (o *sampledObserver) Desc() *prometheus.Desc {
+	return o.desc
+}
+
+func (o *sampledObserver) Write(out *dto.Metric) error {
+	return o.constMetric().Write(out)
+}
+
+// constMetric returns the current count, sum and cumulative bucket counts.
+func (o *sampledObserver) constMetric() prometheus.Metric {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if o.summary {
+		return prometheus.MustNewConstSummary(o.desc, o.count, o.sum, nil, o.labelValues...)
+	}
+	buckets := make(map[float64]uint64, len(o.buckets))
+	var cumulative uint64
+	for i, upperBound := range o.buckets {
+		cumulative += o.counts[i]
+		buckets[upperBound] = cumulative
+	}
+	return prometheus.MustNewConstHistogram(o.desc, o.count, o.sum, buckets, o.labelValues...)
+}
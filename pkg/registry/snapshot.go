@@ -0,0 +1,331 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// snapshotMagic starts every snapshot, followed by the format version.
+const (
+	snapshotMagic   = "STATSDSNAP"
+	snapshotVersion = 1
+)
+
+// maxSnapshotString bounds the length of the strings read from a snapshot, so
+// that a corrupt snapshot does not make the exporter allocate huge buffers.
+const maxSnapshotString = 1 << 20
+
+// Kinds of series in a snapshot.
+const (
+	snapshotCounter byte = iota
+	snapshotGauge
+	snapshotAbsoluteCounter
+)
+
+// Flags of series in a snapshot.
+const (
+	snapshotForward byte = 1 << iota
+	snapshotExpireOnNoChange
+)
+
+// snapshotSeries is a series as written to and read from a snapshot.
+type snapshotSeries struct {
+	kind    byte
+	name    string
+	help    string
+	match   string
+	mapping string
+	flags   byte
+	labels  prometheus.Labels
+	value   float64
+	total   float64
+	// forwarded is the value of a counter when it was last forwarded.
+	forwarded float64
+	ttl       time.Duration
+	lastSeen  time.Time
+	changed   time.Time
+}
+
+// WriteSnapshot writes the counters and gauges of the registry in a compact
+// binary format, with the state needed to resume them: their labels, values,
+// TTLs and when they were last updated. Histograms and summaries are not
+// included, as their observations are no longer known.
+func (r *Registry) WriteSnapshot(w io.Writer) error {
+	names := make([]string, 0, len(r.Metrics))
+	for name, metric := range r.Metrics {
+		if metric.MetricType == metrics.CounterMetricType || metric.MetricType == metrics.GaugeMetricType {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var series []snapshotSeries
+	for _, name := range names {
+		metric := r.Metrics[name]
+		for _, rm := range metric.Metrics {
+			s := snapshotSeries{
+				kind:      snapshotGauge,
+				name:      name,
+				help:      metric.Help,
+				match:     rm.Match,
+				mapping:   rm.Mapping,
+				labels:    rm.Labels,
+				value:     currentValue(rm.Metric),
+				ttl:       rm.TTL,
+				forwarded: rm.ForwardedValue,
+				lastSeen:  rm.LastRegisteredAt,
+				changed:   rm.LastChangedAt,
+			}
+			if metric.MetricType == metrics.CounterMetricType {
+				s.kind = snapshotCounter
+				if c, ok := rm.Metric.(*AbsoluteCounter); ok {
+					s.kind = snapshotAbsoluteCounter
+					s.total = c.total
+				}
+			}
+			if rm.Forward {
+				s.flags |= snapshotForward
+			}
+			if rm.ExpireOnNoChange {
+				s.flags |= snapshotExpireOnNoChange
+			}
+			series = append(series, s)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	buf.Write(binary.AppendUvarint(nil, uint64(len(series))))
+	for _, s := range series {
+		s.append(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (s *snapshotSeries) append(buf *bytes.Buffer) {
+	writeString := func(v string) {
+		buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		buf.WriteString(v)
+	}
+	writeFloat := func(v float64) {
+		buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+	}
+
+	buf.WriteByte(s.kind)
+	writeString(s.name)
+	writeString(s.help)
+	writeString(s.match)
+	writeString(s.mapping)
+	buf.WriteByte(s.flags)
+	labelNames := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	buf.Write(binary.AppendUvarint(nil, uint64(len(labelNames))))
+	for _, name := range labelNames {
+		writeString(name)
+		writeString(s.labels[name])
+	}
+	writeFloat(s.value)
+	writeFloat(s.forwarded)
+	if s.kind == snapshotAbsoluteCounter {
+		writeFloat(s.total)
+	}
+	buf.Write(binary.AppendVarint(nil, int64(s.ttl)))
+	buf.Write(binary.AppendVarint(nil, s.lastSeen.UnixNano()))
+	buf.Write(binary.AppendVarint(nil, s.changed.UnixNano()))
+}
+
+// snapshotReader reads the fields of a snapshot, keeping the first error.
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (sr *snapshotReader) uvarint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(sr.r)
+	sr.err = err
+	return v
+}
+
+func (sr *snapshotReader) varint() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(sr.r)
+	sr.err = err
+	return v
+}
+
+func (sr *snapshotReader) byte() byte {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := sr.r.ReadByte()
+	sr.err = err
+	return v
+}
+
+func (sr *snapshotReader) string() string {
+	n := sr.uvarint()
+	if sr.err != nil {
+		return ""
+	}
+	if n > maxSnapshotString {
+		sr.err = fmt.Errorf("string of %d bytes is too long", n)
+		return ""
+	}
+	b := make([]byte, n)
+	_, sr.err = io.ReadFull(sr.r, b)
+	return string(b)
+}
+
+func (sr *snapshotReader) float() float64 {
+	if sr.err != nil {
+		return 0
+	}
+	var b [8]byte
+	_, sr.err = io.ReadFull(sr.r, b[:])
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+}
+
+func (sr *snapshotReader) series() snapshotSeries {
+	s := snapshotSeries{
+		kind:    sr.byte(),
+		name:    sr.string(),
+		help:    sr.string(),
+		match:   sr.string(),
+		mapping: sr.string(),
+		flags:   sr.byte(),
+	}
+	n := sr.uvarint()
+	s.labels = prometheus.Labels{}
+	for i := uint64(0); i < n && sr.err == nil; i++ {
+		name := sr.string()
+		s.labels[name] = sr.string()
+	}
+	s.value = sr.float()
+	s.forwarded = sr.float()
+	if s.kind == snapshotAbsoluteCounter {
+		s.total = sr.float()
+	}
+	s.ttl = time.Duration(sr.varint())
+	s.lastSeen = time.Unix(0, sr.varint())
+	s.changed = time.Unix(0, sr.varint())
+	if sr.err == nil && s.kind > snapshotAbsoluteCounter {
+		sr.err = fmt.Errorf("unknown series kind %d", s.kind)
+	}
+	return s
+}
+
+// RestoreSnapshot restores the series of a snapshot written by WriteSnapshot,
+// and returns how many were restored. Series whose TTL elapsed since they were
+// last updated are skipped. It is meant to be called on an empty registry,
+// before any events are processed.
+func (r *Registry) RestoreSnapshot(rd io.Reader, metricsCount *prometheus.GaugeVec) (int, error) {
+	sr := &snapshotReader{r: bufio.NewReader(rd)}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(sr.r, magic); err != nil || string(magic) != snapshotMagic {
+		return 0, errors.New("not a statsd_exporter snapshot")
+	}
+	if version := sr.byte(); sr.err == nil && version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	n := sr.uvarint()
+	if sr.err != nil {
+		return 0, fmt.Errorf("invalid snapshot: %w", sr.err)
+	}
+
+	now := clock.Now()
+	restored := 0
+	for i := uint64(0); i < n; i++ {
+		s := sr.series()
+		if sr.err != nil {
+			return restored, fmt.Errorf("invalid snapshot: series %d: %w", i, sr.err)
+		}
+		lastActive := s.lastSeen
+		if s.flags&snapshotExpireOnNoChange != 0 {
+			lastActive = s.changed
+		}
+		if s.ttl > 0 && lastActive.Add(s.ttl).Before(now) {
+			continue
+		}
+		if err := r.restoreSeries(s, metricsCount); err != nil {
+			return restored, fmt.Errorf("restoring %s: %w", s.name, err)
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+func (r *Registry) restoreSeries(s snapshotSeries, metricsCount *prometheus.GaugeVec) error {
+	mapping := &mapper.MetricMapping{Ttl: s.ttl}
+	if s.flags&snapshotExpireOnNoChange != 0 {
+		mapping.ExpireOn = mapper.ExpireOnNoChange
+	}
+
+	var mh metrics.MetricHolder
+	switch s.kind {
+	case snapshotCounter, snapshotAbsoluteCounter:
+		counter, err := r.GetCounter(s.name, s.labels, s.help, mapping, metricsCount)
+		if err != nil {
+			return err
+		}
+		counter.Add(s.value)
+		mh = counter
+		if s.kind == snapshotAbsoluteCounter {
+			mh = &AbsoluteCounter{Counter: counter, total: s.total}
+		}
+	case snapshotGauge:
+		gauge, err := r.GetGauge(s.name, s.labels, s.help, mapping, metricsCount)
+		if err != nil {
+			return err
+		}
+		gauge.Set(s.value)
+		mh = gauge
+	}
+
+	hash, _ := r.HashLabels(s.labels)
+	rm := r.Metrics[s.name].Metrics[hash.Values]
+	rm.Metric = mh
+	rm.Match = s.match
+	rm.Mapping = s.mapping
+	rm.Forward = s.flags&snapshotForward != 0
+	rm.LastRegisteredAt = s.lastSeen
+	rm.LastChangedAt = s.changed
+	rm.LastValue = s.value
+	rm.ForwardedValue = s.forwarded
+	return nil
+}
@@ -21,22 +21,107 @@ import (
 	"strings"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// compressedTimeout bounds connecting to the target and sending a batch to it
+// when compressing, so that an unresponsive target does not stall the relay.
+const compressedTimeout = 5 * time.Second
+
 type Relay struct {
 	addr          *net.UDPAddr
 	bufferChannel chan []byte
 	conn          *net.UDPConn
 	logger        *slog.Logger
 	packetLength  uint
+	target        string
+
+	// When compressing, batches are written to a TCP connection through a
+	// zstd stream instead of being sent as UDP packets.
+	compress   bool
+	tcpConn    net.Conn
+	zstdWriter *zstd.Encoder
+
+	// Lines are rewritten with the prefix and extra DogStatsD tags before
+	// they are relayed.
+	prefix string
+	tags   string
+
+	// rawPackets makes datagram listeners relay the packets they receive as
+	// they are, through packetChannel, instead of relaying their lines.
+	rawPackets    bool
+	packetChannel chan []byte
+
+	// A heartbeat line is relayed every heartbeatInterval, if set, so that
+	// the target can tell that the exporter is alive.
+	heartbeatInterval time.Duration
+	heartbeatMetric   string
+	heartbeatInstance string
+	heartbeatLine     string
 
 	packetsTotal      prometheus.Counter
 	longLinesTotal    prometheus.Counter
 	relayedLinesTotal prometheus.Counter
+	droppedLinesTotal prometheus.Counter
+	sentBytesTotal    prometheus.Counter
+
+	// done is closed by Close to stop the relay.
+	done chan struct{}
+}
+
+// Option configures optional behaviour of a Relay.
+type Option func(*Relay)
+
+// WithZstdCompression makes the relay send its batches over TCP, compressed
+// as a Zstandard stream. The receiving statsd_exporter must accept zstd on its
+// TCP listener.
+func WithZstdCompression() Option {
+	return func(r *Relay) {
+		r.compress = true
+	}
+}
+
+// WithPrefix makes the relay prepend prefix to the metric name of every line.
+func WithPrefix(prefix string) Option {
+	return func(r *Relay) {
+		r.prefix = prefix
+	}
+}
+
+// WithTags makes the relay add the given comma-separated DogStatsD tags to
+// every line, after any tags the line already has. A leading # is ignored.
+func WithTags(tags string) Option {
+	return func(r *Relay) {
+		r.tags = strings.TrimPrefix(tags, "#")
+	}
+}
+
+// WithRawPackets makes the relay forward the packets received by the UDP and
+// Unixgram listeners verbatim, one packet per received packet, instead of
+// relaying their lines. Lines received over TCP are still relayed in batches.
+// It cannot be combined with compression, a prefix or tags, which require
+// rewriting the lines.
+func WithRawPackets() Option {
+	return func(r *Relay) {
+		r.rawPackets = true
+	}
+}
+
+// WithHeartbeat makes the relay send a counter line with the given metric
+// name every interval, tagged with instance:<instance> unless instance is
+// empty. The line is relayed like any other line, including the prefix and
+// tags.
+func WithHeartbeat(interval time.Duration, metricName, instance string) Option {
+	return func(r *Relay) {
+		r.heartbeatInterval = interval
+		r.heartbeatMetric = metricName
+		r.heartbeatInstance = instance
+	}
 }
 
 var (
@@ -61,36 +146,82 @@ var (
 		},
 		[]string{"target"},
 	)
+	relayDroppedLinesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_relay_dropped_lines_total",
+			Help: "The number of lines dropped because the relay buffer was full.",
+		},
+		[]string{"target"},
+	)
+	relaySentBytesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_relay_sent_bytes_total",
+			Help: "The number of bytes sent to the relay target, after compression.",
+		},
+		[]string{"target"},
+	)
 )
 
 // NewRelay creates a statsd UDP relay. It can be used to send copies of statsd raw
 // lines to a separate service.
-func NewRelay(l *slog.Logger, target string, packetLength uint) (*Relay, error) {
-	addr, err := net.ResolveUDPAddr("udp", target)
-	if err != nil {
-		return nil, fmt.Errorf("unable to resolve target %s, err: %w", target, err)
-	}
-	conn, err := net.ListenUDP("udp", nil)
-	if err != nil {
-		return nil, fmt.Errorf("unable to listen on UDP, err: %w", err)
-	}
-
+func NewRelay(l *slog.Logger, target string, packetLength uint, opts ...Option) (*Relay, error) {
 	c := make(chan []byte, 100)
 
 	r := Relay{
-		addr:          addr,
 		bufferChannel: c,
-		conn:          conn,
+		packetChannel: make(chan []byte, 100),
 		logger:        l,
 		packetLength:  packetLength,
+		target:        target,
+		done:          make(chan struct{}),
 
 		packetsTotal:      relayPacketsTotal.WithLabelValues(target),
 		longLinesTotal:    relayLongLinesTotal.WithLabelValues(target),
 		relayedLinesTotal: relayLinesRelayedTotal.WithLabelValues(target),
+		droppedLinesTotal: relayDroppedLinesTotal.WithLabelValues(target),
+		sentBytesTotal:    relaySentBytesTotal.WithLabelValues(target),
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	if r.rawPackets && (r.compress || r.prefix != "" || r.tags != "") {
+		return nil, fmt.Errorf("raw packets cannot be relayed with compression, a prefix or tags")
+	}
+	if r.heartbeatInterval > 0 {
+		if r.heartbeatMetric == "" || strings.ContainsAny(r.heartbeatMetric, ":|#,\n") {
+			return nil, fmt.Errorf("invalid heartbeat metric name %q", r.heartbeatMetric)
+		}
+		if strings.ContainsAny(r.heartbeatInstance, "|,\n") {
+			return nil, fmt.Errorf("invalid heartbeat instance %q", r.heartbeatInstance)
+		}
+		r.heartbeatLine = r.heartbeatMetric + ":1|c"
+		if r.heartbeatInstance != "" {
+			r.heartbeatLine += "|#instance:" + r.heartbeatInstance
+		}
+	}
+
+	if r.compress {
+		if _, err := net.ResolveTCPAddr("tcp", target); err != nil {
+			return nil, fmt.Errorf("unable to resolve target %s, err: %w", target, err)
+		}
+	} else {
+		addr, err := net.ResolveUDPAddr("udp", target)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve target %s, err: %w", target, err)
+		}
+		conn, err := net.ListenUDP("udp", nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on UDP, err: %w", err)
+		}
+		r.addr = addr
+		r.conn = conn
 	}
 
 	// Startup the UDP sender.
 	go r.relayOutput()
+	if r.heartbeatInterval > 0 {
+		go r.heartbeat()
+	}
 
 	return &r, nil
 }
@@ -105,6 +236,19 @@ func (r *Relay) relayOutput() {
 
 	for {
 		select {
+		case <-r.done:
+			// Send the lines that were relayed before the relay was closed.
+			for err == nil && len(r.bufferChannel) > 0 {
+				err = r.bufferLine(&buffer, <-r.bufferChannel)
+			}
+			if err == nil {
+				err = r.sendPacket(buffer.Bytes())
+			}
+			if err != nil {
+				r.logger.Error("Error sending UDP packet", "error", err)
+			}
+			r.closeConn()
+			return
 		case <-relayInterval.C:
 			err = r.sendPacket(buffer.Bytes())
 			if err != nil {
@@ -114,38 +258,202 @@ func (r *Relay) relayOutput() {
 			// Clear out the buffer.
 			buffer.Reset()
 		case b := <-r.bufferChannel:
-			if uint(len(b)+buffer.Len()) > r.packetLength {
-				r.logger.Debug("Buffer full, sending packet", "length", buffer.Len())
-				err = r.sendPacket(buffer.Bytes())
-				if err != nil {
-					r.logger.Error("Error sending UDP packet", "error", err)
-					return
-				}
-				// Seed the new buffer with the new line.
-				buffer.Reset()
-				buffer.Write(b)
-			} else {
-				r.logger.Debug("Adding line to buffer", "line", string(b))
-				buffer.Write(b)
+			err = r.bufferLine(&buffer, b)
+			if err != nil {
+				r.logger.Error("Error sending UDP packet", "error", err)
+				return
 			}
+		case p := <-r.packetChannel:
+			err = r.sendPacket(p)
+			if err != nil {
+				r.logger.Error("Error sending UDP packet", "error", err)
+				return
+			}
+		}
+	}
+}
+
+// bufferLine adds a line to the buffer, sending the buffer first if the line
+// does not fit into the packet anymore.
+func (r *Relay) bufferLine(buffer *bytes.Buffer, b []byte) error {
+	if uint(len(b)+buffer.Len()) > r.packetLength {
+		r.logger.Debug("Buffer full, sending packet", "length", buffer.Len())
+		if err := r.sendPacket(buffer.Bytes()); err != nil {
+			return err
+		}
+		// Seed the new buffer with the new line.
+		buffer.Reset()
+	} else {
+		r.logger.Debug("Adding line to buffer", "line", string(b))
+	}
+	buffer.Write(b)
+	return nil
+}
+
+// heartbeat relays the heartbeat line at every interval.
+func (r *Relay) heartbeat() {
+	ticker := clock.NewTicker(r.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.RelayLine(r.heartbeatLine)
+		case <-r.done:
+			return
 		}
 	}
 }
 
+// Close stops the relay after sending the lines buffered so far, and removes
+// the metrics of its target. Lines and packets relayed afterwards are dropped.
+func (r *Relay) Close() {
+	close(r.done)
+	relayPacketsTotal.DeleteLabelValues(r.target)
+	relayLongLinesTotal.DeleteLabelValues(r.target)
+	relayLinesRelayedTotal.DeleteLabelValues(r.target)
+	relayDroppedLinesTotal.DeleteLabelValues(r.target)
+	relaySentBytesTotal.DeleteLabelValues(r.target)
+}
+
+// closeConn closes the connection to the target. It is called from
+// relayOutput.
+func (r *Relay) closeConn() {
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	if r.zstdWriter != nil {
+		r.zstdWriter.Close()
+		r.tcpConn.Close()
+		r.tcpConn = nil
+		r.zstdWriter = nil
+	}
+}
+
+// Target returns the address the relay sends to.
+func (r *Relay) Target() string {
+	return r.target
+}
+
 // sendPacket sends a single relay line to the destination target.
 func (r *Relay) sendPacket(buf []byte) error {
 	if len(buf) == 0 {
 		r.logger.Debug("Empty buffer, nothing to send")
 		return nil
 	}
+	if r.compress {
+		r.sendCompressed(buf)
+		return nil
+	}
 	r.logger.Debug("Sending packet", "length", len(buf), "data", string(buf))
-	_, err := r.conn.WriteToUDP(buf, r.addr)
+	n, err := r.conn.WriteToUDP(buf, r.addr)
 	r.packetsTotal.Inc()
+	r.sentBytesTotal.Add(float64(n))
 	return err
 }
 
+// sendCompressed writes a batch of lines to the zstd stream and flushes it,
+// (re)connecting to the target as needed. Failures drop the batch instead of
+// stopping the relay, since the target may come back later.
+func (r *Relay) sendCompressed(buf []byte) {
+	if r.tcpConn == nil {
+		conn, err := net.DialTimeout("tcp", r.target, compressedTimeout)
+		if err != nil {
+			r.logger.Error("Error connecting to relay target, dropping batch", "error", err)
+			return
+		}
+		cc := &countingConn{Conn: conn, bytes: r.sentBytesTotal}
+		w, err := zstd.NewWriter(cc, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			conn.Close()
+			r.logger.Error("Error creating zstd encoder, dropping batch", "error", err)
+			return
+		}
+		r.tcpConn = cc
+		r.zstdWriter = w
+	}
+
+	r.logger.Debug("Sending compressed batch", "length", len(buf), "data", string(buf))
+	err := r.tcpConn.SetWriteDeadline(time.Now().Add(compressedTimeout))
+	if err == nil {
+		_, err = r.zstdWriter.Write(buf)
+	}
+	if err == nil {
+		err = r.zstdWriter.Flush()
+	}
+	if err != nil {
+		r.logger.Error("Error sending compressed batch, dropping it", "error", err)
+		r.zstdWriter.Close()
+		r.tcpConn.Close()
+		r.tcpConn = nil
+		r.zstdWriter = nil
+		return
+	}
+	r.packetsTotal.Inc()
+}
+
+// countingConn counts the bytes written to the underlying connection.
+type countingConn struct {
+	net.Conn
+	bytes prometheus.Counter
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.bytes.Add(float64(n))
+	return n, err
+}
+
+// transformLine adds the configured prefix and tags to a line.
+func (r *Relay) transformLine(l string) string {
+	if r.prefix == "" && r.tags == "" {
+		return l
+	}
+	l = strings.TrimSuffix(l, "\n")
+	if r.tags != "" {
+		if i := strings.Index(l, "|#"); i >= 0 {
+			// Append to the existing tags, which may be followed by
+			// further sections such as a container ID or timestamp.
+			end := len(l)
+			if j := strings.IndexByte(l[i+2:], '|'); j >= 0 {
+				end = i + 2 + j
+			}
+			if end > i+2 {
+				l = l[:end] + "," + r.tags + l[end:]
+			} else {
+				l = l[:end] + r.tags + l[end:]
+			}
+		} else {
+			l = l + "|#" + r.tags
+		}
+	}
+	return r.prefix + l
+}
+
+// RelaysPackets reports whether packets received by datagram listeners are
+// relayed with RelayPacket rather than line by line.
+func (r *Relay) RelaysPackets() bool {
+	return r.rawPackets
+}
+
+// RelayPacket forwards a received packet verbatim. The packet is copied, so
+// the caller may reuse it.
+func (r *Relay) RelayPacket(packet []byte) {
+	if len(packet) == 0 {
+		r.logger.Debug("Empty packet, not relaying")
+		return
+	}
+	select {
+	case r.packetChannel <- append([]byte(nil), packet...):
+	case <-r.done:
+	}
+}
+
 // RelayLine processes a single statsd line and forwards it to the relay target.
 func (r *Relay) RelayLine(l string) {
+	if len(l) > 0 {
+		l = r.transformLine(l)
+	}
 	lineLength := uint(len(l))
 	if lineLength == 0 {
 		r.logger.Debug("Empty line, not relaying")
@@ -160,6 +468,14 @@ func (r *Relay) RelayLine(l string) {
 	if !strings.HasSuffix(l, "\n") {
 		l = l + "\n"
 	}
-	r.relayedLinesTotal.Inc()
-	r.bufferChannel <- []byte(l)
+	select {
+	case r.bufferChannel <- []byte(l):
+		r.relayedLinesTotal.Inc()
+	case <-r.done:
+	default:
+		// Do not hold up the listeners while the target is slow or
+		// unreachable.
+		r.logger.Debug("Relay buffer full, dropping line", "line", l)
+		r.droppedLinesTotal.Inc()
+	}
 }
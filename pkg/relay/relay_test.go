@@ -14,18 +14,30 @@
 package relay
 
 import (
+	"bufio"
 	"fmt"
-	"runtime"
+	"net"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/statsd_exporter/pkg/clock"
-	"github.com/stvp/go-udp-testing"
 )
 
+func TestMain(m *testing.M) {
+	// Relays read the clock from their own goroutines, so it is set once
+	// before any relay starts: the tests use real tickers and flush the
+	// buffered lines by closing the relays.
+	clock.ClockInstance = nil
+	os.Exit(m.Run())
+}
+
 func TestRelay_RelayLine(t *testing.T) {
 	type args struct {
 		lines    []string
@@ -46,41 +58,28 @@ func TestRelay_RelayLine(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		udp.SetAddr(":1160")
 		t.Run(tt.name, func(t *testing.T) {
-			tickerCh := make(chan time.Time)
-			clock.ClockInstance = &clock.Clock{
-				TickerCh: tickerCh,
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatalf("Unable to listen: %v", err)
 			}
-			clock.ClockInstance.Instant = time.Unix(0, 0)
+			defer conn.Close()
+			target := conn.LocalAddr().String()
 
 			logger := promslog.NewNopLogger()
 			r, err := NewRelay(
 				logger,
-				"localhost:1160",
+				target,
 				200,
 			)
 
 			if err != nil {
-				t.Errorf("Did not expect error while creating relay.")
+				t.Fatalf("Did not expect error while creating relay.")
 			}
 
-			udp.ShouldReceive(t, tt.args.expected, func() {
-				for _, line := range tt.args.lines {
-					r.RelayLine(line)
-				}
-
-				for goSchedTimes := 0; goSchedTimes < 1000; goSchedTimes++ {
-					if len(r.bufferChannel) == 0 {
-						break
-					}
-					runtime.Gosched()
-				}
-
-				// Tick time forward to trigger a packet send.
-				clock.ClockInstance.Instant = time.Unix(1, 10)
-				clock.ClockInstance.TickerCh <- time.Unix(0, 0)
-			})
+			for _, line := range tt.args.lines {
+				r.RelayLine(line)
+			}
 
 			metrics, err := prometheus.DefaultGatherer.Gather()
 			if err != nil {
@@ -92,7 +91,7 @@ func TestRelay_RelayLine(t *testing.T) {
 				"statsd_exporter_relay_lines_relayed_total": float64(len(tt.args.lines)),
 			}
 			for metricName, expectedValue := range metricNames {
-				metric := getFloat64(metrics, metricName, prometheus.Labels{"target": "localhost:1160"})
+				metric := getFloat64(metrics, metricName, prometheus.Labels{"target": target})
 
 				if metric == nil {
 					t.Fatalf("Could not find time series with first label set for metric: %s", metricName)
@@ -102,8 +101,200 @@ func TestRelay_RelayLine(t *testing.T) {
 				}
 			}
 
-			prometheus.Unregister(relayLongLinesTotal)
-			prometheus.Unregister(relayLinesRelayedTotal)
+			// Closing the relay sends the buffered lines.
+			r.Close()
+
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 1024)
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatalf("Unable to read relayed packet: %v", err)
+			}
+			if !strings.Contains(string(buf[:n]), tt.args.expected) {
+				t.Errorf("Expected packet containing %q, got %q", tt.args.expected, buf[:n])
+			}
+		})
+	}
+}
+
+func TestRelay_ZstdCompression(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer l.Close()
+
+	r, err := NewRelay(promslog.NewNopLogger(), l.Addr().String(), 200, WithZstdCompression())
+	if err != nil {
+		t.Fatalf("Did not expect error while creating relay: %v", err)
+	}
+
+	lines := []string{"foo:1|c|#tag1:bar", "bar:2|g"}
+	for _, line := range lines {
+		r.RelayLine(line)
+	}
+	// Closing the relay sends the buffered lines.
+	r.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Unable to accept relay connection: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	zr, err := zstd.NewReader(conn)
+	if err != nil {
+		t.Fatalf("Unable to create zstd reader: %v", err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	for _, expected := range lines {
+		if !scanner.Scan() {
+			t.Fatalf("Expected line %q, got error %v", expected, scanner.Err())
+		}
+		if scanner.Text() != expected {
+			t.Errorf("Expected line %q, got %q", expected, scanner.Text())
+		}
+	}
+}
+
+func TestRelay_BufferFull(t *testing.T) {
+	// Without relayOutput draining the buffer, lines beyond its capacity are
+	// dropped instead of blocking.
+	r := &Relay{
+		bufferChannel:     make(chan []byte, 1),
+		logger:            promslog.NewNopLogger(),
+		packetLength:      200,
+		done:              make(chan struct{}),
+		relayedLinesTotal: prometheus.NewCounter(prometheus.CounterOpts{Name: "relayed"}),
+		droppedLinesTotal: prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}),
+	}
+	r.RelayLine("foo:1|c")
+	r.RelayLine("foo:2|c")
+
+	if got := testutil.ToFloat64(r.relayedLinesTotal); got != 1 {
+		t.Errorf("Expected 1 relayed line, got %v", got)
+	}
+	if got := testutil.ToFloat64(r.droppedLinesTotal); got != 1 {
+		t.Errorf("Expected 1 dropped line, got %v", got)
+	}
+}
+
+func TestRelay_RawPackets(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer conn.Close()
+
+	r, err := NewRelay(promslog.NewNopLogger(), conn.LocalAddr().String(), 10, WithRawPackets())
+	if err != nil {
+		t.Fatalf("Did not expect error while creating relay: %v", err)
+	}
+	defer r.Close()
+	if !r.RelaysPackets() {
+		t.Fatal("Expected the relay to relay packets")
+	}
+
+	// Packets are relayed as they are, even if they are longer than the
+	// packet length or are not valid lines.
+	packets := [][]byte{
+		[]byte("foo:1|c\nbar:2|g\nbaz:3|ms"),
+		{0x00, 0xff, '\n', 0x01},
+	}
+	for _, packet := range packets {
+		buf := append([]byte(nil), packet...)
+		r.RelayPacket(buf)
+		// The relay must have copied the packet.
+		for i := range buf {
+			buf[i] = 'x'
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	for _, expected := range packets {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Unable to read relayed packet: %v", err)
+		}
+		if string(buf[:n]) != string(expected) {
+			t.Errorf("Expected packet %q, got %q", expected, buf[:n])
+		}
+	}
+}
+
+func TestRelay_RawPacketsOptions(t *testing.T) {
+	for _, opt := range []Option{WithZstdCompression(), WithPrefix("a."), WithTags("a:b")} {
+		r, err := NewRelay(promslog.NewNopLogger(), "localhost:1160", 200, WithRawPackets(), opt)
+		if err == nil {
+			r.Close()
+			t.Error("Expected an error combining raw packets with an option rewriting lines")
+		}
+	}
+}
+
+func TestRelay_TransformLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		line     string
+		expected string
+	}{
+		{
+			name:     "no options",
+			line:     "foo:1|c",
+			expected: "foo:1|c",
+		},
+		{
+			name:     "prefix",
+			opts:     []Option{WithPrefix("legacy.")},
+			line:     "foo:1|c|#tag1:bar",
+			expected: "legacy.foo:1|c|#tag1:bar",
+		},
+		{
+			name:     "tags without existing tags",
+			opts:     []Option{WithTags("#via:statsd_exporter,host:a")},
+			line:     "foo:1|c|@0.5",
+			expected: "foo:1|c|@0.5|#via:statsd_exporter,host:a",
+		},
+		{
+			name:     "tags with existing tags",
+			opts:     []Option{WithTags("via:statsd_exporter")},
+			line:     "foo:1|c|#tag1:bar",
+			expected: "foo:1|c|#tag1:bar,via:statsd_exporter",
+		},
+		{
+			name:     "tags followed by other sections",
+			opts:     []Option{WithTags("via:statsd_exporter")},
+			line:     "foo:1|c|#tag1:bar|c:container|T1656581400",
+			expected: "foo:1|c|#tag1:bar,via:statsd_exporter|c:container|T1656581400",
+		},
+		{
+			name:     "empty tags section",
+			opts:     []Option{WithTags("via:statsd_exporter")},
+			line:     "foo:1|c|#",
+			expected: "foo:1|c|#via:statsd_exporter",
+		},
+		{
+			name:     "prefix and tags",
+			opts:     []Option{WithPrefix("legacy."), WithTags("via:statsd_exporter")},
+			line:     "foo:1|c\n",
+			expected: "legacy.foo:1|c|#via:statsd_exporter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Relay{}
+			for _, opt := range tt.opts {
+				opt(r)
+			}
+			if got := r.transformLine(tt.line); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
 		})
 	}
 }
@@ -174,3 +365,47 @@ func labelPairsAsLabels(pairs []*dto.LabelPair) (labels prometheus.Labels) {
 	}
 	return
 }
+
+func TestRelay_Heartbeat(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer conn.Close()
+
+	r, err := NewRelay(promslog.NewNopLogger(), conn.LocalAddr().String(), 200, WithHeartbeat(10*time.Millisecond, "statsd_exporter.heartbeat", "web01"), WithTags("via:relay"))
+	if err != nil {
+		t.Fatalf("Did not expect error while creating relay: %v", err)
+	}
+	defer r.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Unable to read heartbeat: %v", err)
+	}
+	expected := "statsd_exporter.heartbeat:1|c|#instance:web01,via:relay\n"
+	if !strings.HasPrefix(string(buf[:n]), expected) {
+		t.Errorf("Expected packet starting with %q, got %q", expected, buf[:n])
+	}
+}
+
+func TestRelay_HeartbeatOptions(t *testing.T) {
+	for _, opt := range []Option{
+		WithHeartbeat(time.Second, "", "web01"),
+		WithHeartbeat(time.Second, "heartbeat:1", "web01"),
+		WithHeartbeat(time.Second, "heartbeat", "web01|c"),
+	} {
+		r, err := NewRelay(promslog.NewNopLogger(), "localhost:1160", 200, opt)
+		if err == nil {
+			r.Close()
+			t.Error("Expected an error for an invalid heartbeat")
+		}
+	}
+	r, err := NewRelay(promslog.NewNopLogger(), "localhost:1160", 200, WithHeartbeat(0, "", ""))
+	if err != nil {
+		t.Fatalf("Expected disabled heartbeats not to be validated, got %v", err)
+	}
+	r.Close()
+}
@@ -0,0 +1,206 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrTargetExists is returned when adding a target that is already
+	// relayed to.
+	ErrTargetExists = errors.New("relay target already exists")
+	// ErrUnknownTarget is returned when removing a target that is not
+	// relayed to.
+	ErrUnknownTarget = errors.New("unknown relay target")
+)
+
+// Targets relays lines and packets to a set of targets that can be added and
+// removed at runtime. All targets are created with the same options. The set
+// is not persisted.
+type Targets struct {
+	logger       *slog.Logger
+	packetLength uint
+	opts         []Option
+	rawPackets   bool
+
+	// mtx serializes changes to the set. Relaying reads relays without
+	// locking.
+	mtx    sync.Mutex
+	relays atomic.Pointer[[]*Relay]
+}
+
+// NewTargets creates an empty set of relay targets. Targets added to it are
+// created with the given packet length and options, see NewRelay.
+func NewTargets(l *slog.Logger, packetLength uint, opts ...Option) *Targets {
+	var probe Relay
+	for _, opt := range opts {
+		opt(&probe)
+	}
+	t := &Targets{
+		logger:       l,
+		packetLength: packetLength,
+		opts:         opts,
+		rawPackets:   probe.rawPackets,
+	}
+	t.relays.Store(&[]*Relay{})
+	return t
+}
+
+// Add starts relaying to target.
+func (t *Targets) Add(target string) error {
+	if target == "" {
+		return errors.New("relay target must not be empty")
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	relays := *t.relays.Load()
+	for _, r := range relays {
+		if r.target == target {
+			return fmt.Errorf("%w: %s", ErrTargetExists, target)
+		}
+	}
+	r, err := NewRelay(t.logger, target, t.packetLength, t.opts...)
+	if err != nil {
+		return err
+	}
+	relays = append(slices.Clip(relays), r)
+	t.relays.Store(&relays)
+	t.logger.Info("Added relay target", "target", target)
+	return nil
+}
+
+// Remove stops relaying to target.
+func (t *Targets) Remove(target string) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	relays := *t.relays.Load()
+	i := slices.IndexFunc(relays, func(r *Relay) bool { return r.target == target })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownTarget, target)
+	}
+	r := relays[i]
+	relays = slices.Delete(slices.Clone(relays), i, i+1)
+	t.relays.Store(&relays)
+	r.Close()
+	t.logger.Info("Removed relay target", "target", target)
+	return nil
+}
+
+// Targets returns the targets relayed to, in the order they were added.
+func (t *Targets) Targets() []string {
+	relays := *t.relays.Load()
+	targets := make([]string, 0, len(relays))
+	for _, r := range relays {
+		targets = append(targets, r.target)
+	}
+	return targets
+}
+
+// RelaysPackets reports whether packets received by datagram listeners are
+// relayed with RelayPacket rather than line by line.
+func (t *Targets) RelaysPackets() bool {
+	return t.rawPackets
+}
+
+// RelayPacket forwards a received packet verbatim to all targets.
+func (t *Targets) RelayPacket(packet []byte) {
+	for _, r := range *t.relays.Load() {
+		r.RelayPacket(packet)
+	}
+}
+
+// RelayLine forwards a single statsd line to all targets.
+func (t *Targets) RelayLine(l string) {
+	for _, r := range *t.relays.Load() {
+		r.RelayLine(l)
+	}
+}
+
+// TargetChange is the body of a request to change the relay targets.
+type TargetChange struct {
+	// Action is either "add" or "remove".
+	Action string `json:"action"`
+	Target string `json:"target"`
+}
+
+// ServeHTTP returns the relay targets as JSON, in the response format of the
+// Prometheus HTTP API. POST requests with a TargetChange as JSON body add or
+// remove a target first.
+func (t *Targets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var change TargetChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		var err error
+		switch change.Action {
+		case "add":
+			err = t.Add(change.Target)
+		case "remove":
+			err = t.Remove(change.Target)
+		default:
+			err = fmt.Errorf("invalid action %q, must be \"add\" or \"remove\"", change.Action)
+		}
+		switch {
+		case errors.Is(err, ErrTargetExists):
+			writeError(w, http.StatusConflict, err)
+			return
+		case errors.Is(err, ErrUnknownTarget):
+			writeError(w, http.StatusNotFound, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Status string   `json:"status"`
+		Data   []string `json:"data"`
+	}{
+		Status: "success",
+		Data:   t.Targets(),
+	})
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}{
+		Status: "error",
+		Error:  err.Error(),
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestTargets(t *testing.T) {
+	receivers := make([]*net.UDPConn, 2)
+	addrs := make([]string, 2)
+	for i := range receivers {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		receivers[i] = conn
+		addrs[i] = conn.LocalAddr().String()
+	}
+	receive := func(conn *net.UDPConn) string {
+		t.Helper()
+		buf := make([]byte, 1500)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("did not receive relayed lines: %v", err)
+		}
+		return string(buf[:n])
+	}
+
+	targets := NewTargets(promslog.NewNopLogger(), 200)
+	request := func(method, body string, expectedCode int, expectedBody string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		targets.ServeHTTP(w, httptest.NewRequest(method, "/-/relay", strings.NewReader(body)))
+		if w.Code != expectedCode {
+			t.Fatalf("expected status %d, got %d: %s", expectedCode, w.Code, w.Body.String())
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != expectedBody {
+			t.Fatalf("expected body %s, got %s", expectedBody, got)
+		}
+	}
+
+	// Without targets, lines are dropped.
+	targets.RelayLine("foo:1|c")
+	request(http.MethodGet, "", http.StatusOK, `{"status":"success","data":[]}`)
+
+	request(http.MethodPost, `{"action":"add","target":"`+addrs[0]+`"}`, http.StatusOK, `{"status":"success","data":["`+addrs[0]+`"]}`)
+	request(http.MethodPost, `{"action":"add","target":"`+addrs[1]+`"}`, http.StatusOK, `{"status":"success","data":["`+addrs[0]+`","`+addrs[1]+`"]}`)
+	request(http.MethodPost, `{"action":"add","target":"`+addrs[1]+`"}`, http.StatusConflict, `{"status":"error","error":"relay target already exists: `+addrs[1]+`"}`)
+	request(http.MethodPost, `{"action":"remove","target":"localhost:1"}`, http.StatusNotFound, `{"status":"error","error":"unknown relay target: localhost:1"}`)
+	request(http.MethodPost, `{"action":"replace"}`, http.StatusBadRequest, `{"status":"error","error":"invalid action \"replace\", must be \"add\" or \"remove\""}`)
+	request(http.MethodDelete, "", http.StatusMethodNotAllowed, `{"status":"error","error":"method DELETE not allowed"}`)
+
+	targets.RelayLine("foo:2|c")
+	if got := testutil.ToFloat64(relayLinesRelayedTotal.WithLabelValues(addrs[1])); got != 1 {
+		t.Fatalf("expected 1 line relayed to %s, got %v", addrs[1], got)
+	}
+
+	// Removing a target sends the lines buffered for it.
+	series := testutil.CollectAndCount(relayLinesRelayedTotal)
+	request(http.MethodPost, `{"action":"remove","target":"`+addrs[1]+`"}`, http.StatusOK, `{"status":"success","data":["`+addrs[0]+`"]}`)
+	if got := receive(receivers[1]); got != "foo:2|c\n" {
+		t.Fatalf("expected the line to be relayed to the removed target, got %q", got)
+	}
+	if n := testutil.CollectAndCount(relayLinesRelayedTotal); n != series-1 {
+		t.Fatalf("expected the metrics of the removed target to be deleted, got %d series", n)
+	}
+
+	targets.RelayLine("foo:3|c")
+	if err := targets.Remove(addrs[0]); err != nil {
+		t.Fatal(err)
+	}
+	// The lines may have been sent in separate packets.
+	expected := "foo:2|c\nfoo:3|c\n"
+	got := receive(receivers[0])
+	for len(got) < len(expected) {
+		got += receive(receivers[0])
+	}
+	if got != expected {
+		t.Fatalf("expected both lines to be relayed, got %q", got)
+	}
+	if len(targets.Targets()) != 0 {
+		t.Fatalf("expected no targets, got %v", targets.Targets())
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+)
+
+// namedRegistries holds the registries that mappings select with their
+// registry field. Each is created when the first metric is registered with it
+// or it is first scraped, and served below prefix, as in /metrics/app for the
+// registry app.
+type namedRegistries struct {
+	prefix string
+	mapper *mapper.MetricMapper
+	// handler serves the metrics of a registry, and is set before the
+	// registries are served.
+	handler func(prometheus.Gatherer) http.Handler
+
+	mtx        sync.Mutex
+	registries map[string]*prometheus.Registry
+	handlers   map[string]http.Handler
+}
+
+func newNamedRegistries(prefix string, mapper *mapper.MetricMapper) *namedRegistries {
+	return &namedRegistries{
+		prefix:     prefix,
+		mapper:     mapper,
+		registries: map[string]*prometheus.Registry{},
+		handlers:   map[string]http.Handler{},
+	}
+}
+
+// registerer returns the registry of a name, creating it if needed.
+func (n *namedRegistries) registerer(name string) prometheus.Registerer {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.registry(name)
+}
+
+func (n *namedRegistries) registry(name string) *prometheus.Registry {
+	reg, ok := n.registries[name]
+	if !ok {
+		reg = prometheus.NewRegistry()
+		n.registries[name] = reg
+	}
+	return reg
+}
+
+// selected reports whether a mapping of the configuration selects a registry,
+// so that it is served before its first metric is registered.
+func (n *namedRegistries) selected(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, m := range n.mapper.AllMappings() {
+		if m.Registry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the metrics of the registry named by the path below the
+// prefix.
+func (n *namedRegistries) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, n.prefix)
+	selected := n.selected(name)
+	n.mtx.Lock()
+	h, ok := n.handlers[name]
+	if !ok {
+		if _, exists := n.registries[name]; (exists && name != "") || selected {
+			h = n.handler(n.registry(name))
+			n.handlers[name] = h
+			ok = true
+		}
+	}
+	n.mtx.Unlock()
+	if !ok {
+		http.Error(w, "unknown registry "+strconv.Quote(name), http.StatusNotFound)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/registry"
+)
+
+func TestNamedRegistries(t *testing.T) {
+	m := &mapper.MetricMapper{}
+	err := m.InitFromYAMLString(`
+mappings:
+- match: app.*
+  name: app_$1
+  registry: app
+- match: infra.*
+  name: infra_$1
+  registry: infra
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	named := newNamedRegistries("/metrics/", m)
+	named.handler = func(g prometheus.Gatherer) http.Handler {
+		return gathererHandler(g, false, promslog.NewNopLogger())
+	}
+	reg := prometheus.NewRegistry()
+	ex := exporter.NewExporter(reg, m, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Registry.(*registry.Registry).Registerers = named.registerer
+
+	events := make(chan event.Events, 1)
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "app.requests", CValue: 1, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "other_requests", CValue: 1, CLabels: map[string]string{}},
+	}
+	close(events)
+	ex.Listen(context.Background(), events)
+
+	rec := httptest.NewRecorder()
+	newMetricsHandler(prometheus.NewRegistry(), reg, nil, false, promslog.NewNopLogger()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "other_requests") || strings.Contains(body, "app_requests") {
+		t.Errorf("expected only the unrouted metric in the default registry, got:\n%s", body)
+	}
+
+	for _, s := range []struct {
+		url    string
+		status int
+		metric string
+	}{
+		{url: "/metrics/app", status: http.StatusOK, metric: "app_requests"},
+		// A registry is served before its first metric.
+		{url: "/metrics/infra", status: http.StatusOK},
+		{url: "/metrics/other", status: http.StatusNotFound},
+		{url: "/metrics/", status: http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		named.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, s.url, nil))
+		if rec.Code != s.status {
+			t.Fatalf("%s: expected status %d, got %d", s.url, s.status, rec.Code)
+		}
+		if s.metric != "" && !strings.Contains(rec.Body.String(), s.metric) {
+			t.Errorf("%s: expected %s in body:\n%s", s.url, s.metric, rec.Body.String())
+		}
+		if s.status == http.StatusOK && strings.Contains(rec.Body.String(), "other_requests") {
+			t.Errorf("%s: expected no unrouted metrics in body:\n%s", s.url, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// scrapeCache serves scrapes from a cached gather of the metric families of a
+// gatherer, which is refreshed in the background at half of its maximum age.
+// The refreshes gather the metrics like a scrape would, but scrapes only load
+// the cached families, so they do not wait for the locks of the metrics, and
+// however many scrapes there are, the metrics are only gathered once per
+// refresh.
+//
+// A scrape never returns families gathered longer than the maximum age ago.
+// If a refresh is late, for example because gathering takes long, the scrape
+// waits for a new gather. Cached families are never modified; refreshing
+// replaces them as a whole, so that every scrape sees one consistent state.
+type scrapeCache struct {
+	g      prometheus.Gatherer
+	maxAge time.Duration
+
+	current atomic.Pointer[cachedGather]
+	// refreshMtx makes sure that only one refresh gathers at a time.
+	refreshMtx sync.Mutex
+}
+
+type cachedGather struct {
+	mfs []*dto.MetricFamily
+	err error
+	// gathered is when gathering started.
+	gathered time.Time
+}
+
+func newScrapeCache(g prometheus.Gatherer, maxAge time.Duration) *scrapeCache {
+	return &scrapeCache{g: g, maxAge: maxAge}
+}
+
+// refresh gathers and caches the metric families, unless they were gathered
+// at or after the given time while waiting for another refresh.
+func (s *scrapeCache) refresh(notBefore time.Time) {
+	s.refreshMtx.Lock()
+	defer s.refreshMtx.Unlock()
+
+	if c := s.current.Load(); c != nil && !c.gathered.Before(notBefore) {
+		return
+	}
+	gathered := clock.Now()
+	mfs, err := s.g.Gather()
+	s.current.Store(&cachedGather{mfs: mfs, err: err, gathered: gathered})
+}
+
+// run refreshes the cache every half of the maximum age until the context is
+// cancelled.
+func (s *scrapeCache) run(ctx context.Context) {
+	ticker := clock.NewTicker(s.maxAge / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.refresh(clock.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Gather returns the cached metric families, gathering them if they are
+// missing or older than the maximum age. The returned slice is a copy, but
+// the families are shared with other scrapes and must not be modified.
+func (s *scrapeCache) Gather() ([]*dto.MetricFamily, error) {
+	c := s.current.Load()
+	if now := clock.Now(); c == nil || now.Sub(c.gathered) > s.maxAge {
+		s.refresh(now.Add(-s.maxAge))
+		c = s.current.Load()
+	}
+	return append([]*dto.MetricFamily(nil), c.mfs...), c.err
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+func TestScrapeCache(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "converted_total"})
+	reg.MustRegister(c)
+	c.Inc()
+
+	sc := newScrapeCache(reg, time.Minute)
+	value := func() float64 {
+		t.Helper()
+		mfs, err := sc.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mfs) != 1 {
+			t.Fatalf("Expected 1 family, got %d", len(mfs))
+		}
+		// Callers may change the returned slice without affecting the
+		// cache.
+		defer func() { mfs[0] = nil }()
+		return mfs[0].GetMetric()[0].GetCounter().GetValue()
+	}
+
+	if v := value(); v != 1 {
+		t.Fatalf("Expected the first scrape to gather, got %v", v)
+	}
+	c.Inc()
+	if v := value(); v != 1 {
+		t.Fatalf("Expected scrapes to be served from the cache, got %v", v)
+	}
+	clock.ClockInstance.Instant = time.Unix(30, 0)
+	sc.refresh(clock.Now())
+	if v := value(); v != 2 {
+		t.Fatalf("Expected the refreshed cache, got %v", v)
+	}
+	c.Inc()
+	clock.ClockInstance.Instant = time.Unix(90, 0)
+	if v := value(); v != 2 {
+		t.Fatalf("Expected the cache to be served up to its maximum age, got %v", v)
+	}
+	clock.ClockInstance.Instant = time.Unix(91, 0)
+	if v := value(); v != 3 {
+		t.Fatalf("Expected a scrape to gather once the cache is too old, got %v", v)
+	}
+}
+
+// benchmarkScrapeDuringEvents measures scrapes while events are applied
+// continuously, and reports the 99th percentile of the scrape duration.
+func benchmarkScrapeDuringEvents(b *testing.B, cached bool) {
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(`
+mappings:
+- match: timer.*
+  name: timer
+  observer_type: summary
+  labels:
+    instance: $1
+`); err != nil {
+		b.Fatal(err)
+	}
+	reg := prometheus.NewRegistry()
+	ex := exporter.NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+
+	var batch event.Events
+	for i := 0; i < 1000; i++ {
+		batch = append(batch,
+			&event.CounterEvent{CMetricName: fmt.Sprintf("counter%d", i%100), CValue: 1, CLabels: map[string]string{"instance": fmt.Sprint(i)}},
+			&event.ObserverEvent{OMetricName: fmt.Sprintf("timer.%d", i), OValue: float64(i), OLabels: map[string]string{}},
+		)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan event.Events)
+	go ex.Listen(ctx, events)
+	// Once Listen took the first batch, the sweep is handled after it, so
+	// that all series exist before scraping.
+	events <- batch
+	ex.SweepStale()
+	go func() {
+		for {
+			select {
+			case events <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var g prometheus.Gatherer = reg
+	if cached {
+		sc := newScrapeCache(reg, 200*time.Millisecond)
+		sc.refresh(time.Now())
+		go sc.run(ctx)
+		g = sc
+	}
+
+	durations := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, err := g.Gather(); err != nil {
+			b.Fatal(err)
+		}
+		durations[i] = time.Since(start)
+	}
+	b.StopTimer()
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	b.ReportMetric(float64(durations[len(durations)*99/100].Nanoseconds()), "p99-ns/scrape")
+}
+
+func BenchmarkScrapeDuringEvents(b *testing.B) {
+	benchmarkScrapeDuringEvents(b, false)
+}
+
+func BenchmarkScrapeDuringEventsFromCache(b *testing.B) {
+	benchmarkScrapeDuringEvents(b, true)
+}
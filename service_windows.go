@@ -0,0 +1,69 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+const serviceName = "statsd_exporter"
+
+type exporterService struct {
+	stopCh chan<- struct{}
+}
+
+func (s *exporterService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	changes <- svc.Status{State: svc.StartPending}
+	changes <- svc.Status{State: svc.Running, Accepts: accepted}
+	for c := range r {
+		switch c.Cmd {
+		case svc.Interrogate:
+			changes <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			changes <- svc.Status{State: svc.StopPending}
+			close(s.stopCh)
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// startService hands control to the Windows service control manager if the
+// exporter was started as a service. The returned channel is closed when the
+// service is asked to stop. It is nil when not running as a service.
+func startService(logger *slog.Logger) <-chan struct{} {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		logger.Error("Unable to determine if running as a Windows service", "error", err)
+		os.Exit(1)
+	}
+	if !isService {
+		return nil
+	}
+
+	stopCh := make(chan struct{})
+	go func() {
+		if err := svc.Run(serviceName, &exporterService{stopCh: stopCh}); err != nil {
+			logger.Error("Failed to run Windows service", "error", err)
+			os.Exit(1)
+		}
+	}()
+	return stopCh
+}
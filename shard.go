@@ -0,0 +1,109 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// shardParam is the query parameter that selects a shard of the metrics, as
+// in `shard=2of8`.
+const shardParam = "shard"
+
+// parseShard parses a shard selection of the form `<shard>of<shards>`, where
+// shards are numbered from 1.
+func parseShard(s string) (shard, shards uint64, err error) {
+	n, m, ok := strings.Cut(s, "of")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected <shard>of<shards>", s)
+	}
+	shard, err = strconv.ParseUint(n, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %w", s, err)
+	}
+	shards, err = strconv.ParseUint(m, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %w", s, err)
+	}
+	if shard < 1 || shard > shards {
+		return 0, 0, fmt.Errorf("invalid shard %q, the shard must be between 1 and %d", s, shards)
+	}
+	return shard, shards, nil
+}
+
+// shardGatherer returns a gatherer that only keeps the series of g that
+// belong to the given shard. Series are assigned to shards by the hash of
+// their metric name and labels, so that every exporter and every scrape
+// assigns a series to the same shard. Families without series in the shard
+// are left out.
+func shardGatherer(g prometheus.Gatherer, shard, shards uint64) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		result := make([]*dto.MetricFamily, 0, len(mfs))
+		for _, mf := range mfs {
+			var metrics []*dto.Metric
+			for _, m := range mf.GetMetric() {
+				if seriesShard(mf.GetName(), m, shards) == shard {
+					metrics = append(metrics, m)
+				}
+			}
+			if len(metrics) == 0 {
+				continue
+			}
+			result = append(result, &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit, Metric: metrics})
+		}
+		return result, err
+	})
+}
+
+// seriesShard returns the shard, numbered from 1, of a series.
+func seriesShard(name string, m *dto.Metric, shards uint64) uint64 {
+	labels := make(map[string]string, len(m.GetLabel())+1)
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	labels[model.MetricNameLabel] = name
+	return model.LabelsToSignature(labels)%shards + 1
+}
+
+// shardHandler serves a shard of the metrics if one is selected with the
+// `shard` query parameter, and all metrics otherwise.
+type shardHandler struct {
+	gatherer          prometheus.Gatherer
+	fallback          http.Handler
+	enableOpenMetrics bool
+	logger            *slog.Logger
+}
+
+func (h *shardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	selection := r.URL.Query().Get(shardParam)
+	if selection == "" {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+	shard, shards, err := parseShard(selection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	formatHandler(shardGatherer(h.gatherer, shard, shards), h.enableOpenMetrics, h.logger).ServeHTTP(w, r)
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestShardedExposition(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "foo_total", Help: "Foo."}, []string{"bar"})
+	reg.MustRegister(counter)
+	var all []string
+	for i := 0; i < 100; i++ {
+		counter.WithLabelValues(fmt.Sprint(i)).Inc()
+		all = append(all, fmt.Sprintf(`foo_total{bar="%d"} 1`, i))
+	}
+
+	h := newMetricsHandler(prometheus.NewRegistry(), reg, nil, false, promslog.NewNopLogger())
+	scrape := func(shard string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics?shard="+shard, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	// Every series is in exactly one shard, and in the same one on every
+	// scrape.
+	const shards = 4
+	bodies := make([]string, shards)
+	for i := range bodies {
+		code, body := scrape(fmt.Sprintf("%dof%d", i+1, shards))
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", code, body)
+		}
+		if _, again := scrape(fmt.Sprintf("%dof%d", i+1, shards)); again != body {
+			t.Errorf("expected shard %d to be the same on every scrape", i+1)
+		}
+		if !strings.Contains(body, "foo_total{") {
+			t.Errorf("expected shard %d to have series, got:\n%s", i+1, body)
+		}
+		bodies[i] = body
+	}
+	for _, sample := range all {
+		found := 0
+		for _, body := range bodies {
+			if strings.Contains(body, sample+"\n") {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("expected %s in exactly one shard, found it in %d", sample, found)
+		}
+	}
+
+	if _, body := scrape("1of1"); strings.Count(body, "foo_total{") != len(all) {
+		t.Errorf("expected a single shard to have all series, got:\n%s", body)
+	}
+	for _, shard := range []string{"0of4", "5of4", "2", "xof4", "1of0"} {
+		if code, _ := scrape(shard); code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for shard %q, got %d", shard, code)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+const (
+	// sourceLookupTimeout bounds the time spent looking up one source.
+	sourceLookupTimeout = 5 * time.Second
+	// maxConcurrentSourceLookups bounds the lookups running at once.
+	maxConcurrentSourceLookups = 8
+)
+
+// sourceInfoLabels are the labels of statsd_source_info besides the address.
+var sourceInfoLabels = []string{"resolved_hostname", "pod", "namespace"}
+
+// sourceLabeler adds the IP address of the source of lines as a label to their
+// events, and records the source in the info metric, if any. Lines of unknown
+// sources are passed on unchanged.
+type sourceLabeler struct {
+	line.Format
+	label string
+	info  *sourceInfo
+}
+
+// ForSource returns the format for the lines of a source, which labels their
+// events with the address of the source.
+func (p *sourceLabeler) ForSource(source netip.AddrPort) line.Format {
+	format := p.Format
+	if sf, ok := format.(line.SourceFormat); ok {
+		format = sf.ForSource(source)
+	}
+	if !source.IsValid() {
+		return format
+	}
+	addr := source.Addr().Unmap()
+	if p.info != nil {
+		p.info.observe(addr)
+	}
+	return &labeledSource{Format: format, label: p.label, value: addr.String()}
+}
+
+// labeledSource labels the events of the lines of one source.
+type labeledSource struct {
+	line.Format
+	label, value string
+}
+
+func (s *labeledSource) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := s.Format.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	for _, e := range events {
+		if labels := e.Labels(); labels != nil {
+			labels[s.label] = s.value
+		}
+	}
+	return events
+}
+
+// sourceDetails are what is known about a source address.
+type sourceDetails struct {
+	hostname, pod, namespace string
+}
+
+// sourceResolver looks up details of a source address.
+type sourceResolver interface {
+	name() string
+	lookup(ctx context.Context, addr netip.Addr, details *sourceDetails) error
+}
+
+type sourceEntry struct {
+	details    sourceDetails
+	lastSeen   time.Time
+	resolvedAt time.Time
+	resolving  bool
+}
+
+// sourceInfo exposes statsd_source_info for every source that sent lines
+// within the TTL, labelled with the source address and the details that the
+// resolvers found for it. Details are looked up in the background when a
+// source is first seen, and again once they are older than the TTL.
+type sourceInfo struct {
+	desc      *prometheus.Desc
+	ttl       time.Duration
+	resolvers []sourceResolver
+	// lookupErrors counts failed lookups by resolver.
+	lookupErrors *prometheus.CounterVec
+	logger       *slog.Logger
+	lookups      chan struct{}
+
+	mtx     sync.Mutex
+	sources map[netip.Addr]*sourceEntry
+}
+
+func newSourceInfo(label string, ttl time.Duration, resolvers []sourceResolver, lookupErrors *prometheus.CounterVec, logger *slog.Logger) *sourceInfo {
+	return &sourceInfo{
+		desc: prometheus.NewDesc(
+			"statsd_source_info",
+			"Information about a source of StatsD lines, always 1.",
+			append([]string{label}, sourceInfoLabels...), nil,
+		),
+		ttl:          ttl,
+		resolvers:    resolvers,
+		lookupErrors: lookupErrors,
+		logger:       logger,
+		lookups:      make(chan struct{}, maxConcurrentSourceLookups),
+		sources:      map[netip.Addr]*sourceEntry{},
+	}
+}
+
+// observe records that lines were received from the address, and starts
+// looking it up if its details are not known or out of date.
+func (s *sourceInfo) observe(addr netip.Addr) {
+	now := clock.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e, ok := s.sources[addr]
+	if !ok {
+		e = &sourceEntry{}
+		s.sources[addr] = e
+	}
+	e.lastSeen = now
+	if len(s.resolvers) == 0 || e.resolving || (!e.resolvedAt.IsZero() && now.Sub(e.resolvedAt) < s.ttl) {
+		return
+	}
+	e.resolving = true
+	go s.resolve(addr)
+}
+
+// resolve looks up the details of an address with all resolvers.
+func (s *sourceInfo) resolve(addr netip.Addr) {
+	s.lookups <- struct{}{}
+	defer func() { <-s.lookups }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sourceLookupTimeout)
+	defer cancel()
+	var details sourceDetails
+	for _, r := range s.resolvers {
+		if err := r.lookup(ctx, addr, &details); err != nil {
+			s.logger.Debug("Failed to look up source", "resolver", r.name(), "source", addr, "error", err)
+			s.lookupErrors.WithLabelValues(r.name()).Inc()
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if e, ok := s.sources[addr]; ok {
+		e.details = details
+		e.resolvedAt = clock.Now()
+		e.resolving = false
+	}
+}
+
+func (s *sourceInfo) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+// Collect exposes the sources seen within the TTL, and forgets the others.
+func (s *sourceInfo) Collect(ch chan<- prometheus.Metric) {
+	now := clock.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for addr, e := range s.sources {
+		if now.Sub(e.lastSeen) > s.ttl && !e.resolving {
+			delete(s.sources, addr)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, 1, addr.String(), e.details.hostname, e.details.pod, e.details.namespace)
+	}
+}
+
+// reverseDNSResolver looks up the host name of a source.
+type reverseDNSResolver struct {
+	resolver *net.Resolver
+}
+
+func (r *reverseDNSResolver) name() string { return "reverse_dns" }
+
+func (r *reverseDNSResolver) lookup(ctx context.Context, addr netip.Addr, details *sourceDetails) error {
+	names, err := r.resolver.LookupAddr(ctx, addr.String())
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		details.hostname = strings.TrimSuffix(names[0], ".")
+	}
+	return nil
+}
+
+const (
+	kubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubernetesCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// kubernetesResolver looks up the pod that has the address of a source, using
+// the Kubernetes API with the service account of the exporter's pod. The
+// service account needs permission to list pods.
+type kubernetesResolver struct {
+	apiURL    string
+	client    *http.Client
+	tokenFile string
+}
+
+// newInClusterKubernetesResolver creates a resolver for the API server of the
+// cluster the exporter runs in.
+func newInClusterKubernetesResolver() (*kubernetesResolver, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(kubernetesCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s", kubernetesCAFile)
+	}
+	return &kubernetesResolver{
+		apiURL: "https://" + net.JoinHostPort(host, port),
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		tokenFile: kubernetesTokenFile,
+	}, nil
+}
+
+func (r *kubernetesResolver) name() string { return "kubernetes" }
+
+func (r *kubernetesResolver) lookup(ctx context.Context, addr netip.Addr, details *sourceDetails) error {
+	u := r.apiURL + "/api/v1/pods?fieldSelector=" + url.QueryEscape("status.podIP="+addr.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if r.tokenFile != "" {
+		// The token is read for every request, as it is rotated.
+		token, err := os.ReadFile(r.tokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("listing pods returned %s", resp.Status)
+	}
+
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				HostNetwork bool `json:"hostNetwork"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return err
+	}
+	// Pods on the host network share the address of their node, so they do
+	// not identify the source.
+	for _, pod := range pods.Items {
+		if !pod.Spec.HostNetwork {
+			details.pod = pod.Metadata.Name
+			details.namespace = pod.Metadata.Namespace
+			break
+		}
+	}
+	return nil
+}
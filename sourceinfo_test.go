@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+type sourceResolverFunc func(addr netip.Addr, details *sourceDetails) error
+
+func (f sourceResolverFunc) name() string { return "test" }
+
+func (f sourceResolverFunc) lookup(_ context.Context, addr netip.Addr, details *sourceDetails) error {
+	return f(addr, details)
+}
+
+// waitForLookup waits until the details of the address are looked up.
+func waitForLookup(t *testing.T, s *sourceInfo, addr netip.Addr) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		s.mtx.Lock()
+		e, ok := s.sources[addr]
+		done := ok && !e.resolving
+		s.mtx.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s was not looked up", addr)
+}
+
+func TestSourceLabeler(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	lookups := 0
+	info := newSourceInfo("client_ip", time.Minute, []sourceResolver{
+		sourceResolverFunc(func(addr netip.Addr, details *sourceDetails) error {
+			lookups++
+			details.hostname = "host-" + strings.ReplaceAll(addr.String(), ".", "-")
+			return nil
+		}),
+	}, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "errors"}, []string{"resolver"}), promslog.NewNopLogger())
+	p := &sourceLabeler{Format: line.NewParser(), label: "client_ip", info: info}
+
+	source := netip.MustParseAddrPort("[::ffff:10.0.0.5]:4000")
+	events := p.ForSource(source).LineToEvents("foo:1|c", *sampleErrors, *samplesReceived, tagErrors, tagsReceived, promslog.NewNopLogger())
+	if len(events) != 1 || events[0].Labels()["client_ip"] != "10.0.0.5" {
+		t.Fatalf("Expected an event labelled with the source, got %v", events)
+	}
+	// Lines of unknown sources are not labelled.
+	events = p.LineToEvents("foo:1|c", *sampleErrors, *samplesReceived, tagErrors, tagsReceived, promslog.NewNopLogger())
+	if _, ok := events[0].Labels()["client_ip"]; ok {
+		t.Fatalf("Expected no source label without a source, got %v", events[0].Labels())
+	}
+
+	addr := netip.MustParseAddr("10.0.0.5")
+	waitForLookup(t, info, addr)
+	expected := `
+# HELP statsd_source_info Information about a source of StatsD lines, always 1.
+# TYPE statsd_source_info gauge
+statsd_source_info{client_ip="10.0.0.5",namespace="",pod="",resolved_hostname="host-10-0-0-5"} 1
+`
+	if err := testutil.CollectAndCompare(info, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The details are cached within the TTL.
+	p.ForSource(source)
+	waitForLookup(t, info, addr)
+	if lookups != 1 {
+		t.Fatalf("Expected 1 lookup within the TTL, got %d", lookups)
+	}
+	clock.ClockInstance.Instant = time.Unix(61, 0)
+	p.ForSource(source)
+	waitForLookup(t, info, addr)
+	if lookups != 2 {
+		t.Fatalf("Expected another lookup after the TTL, got %d", lookups)
+	}
+
+	// Sources that sent nothing within the TTL are forgotten.
+	clock.ClockInstance.Instant = time.Unix(200, 0)
+	if n := testutil.CollectAndCount(info); n != 0 {
+		t.Fatalf("Expected the source to be forgotten, got %d series", n)
+	}
+}
+
+func TestKubernetesResolver(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" || r.URL.Query().Get("fieldSelector") != "status.podIP=10.0.0.5" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"items":[
+			{"metadata":{"name":"node-agent","namespace":"kube-system"},"spec":{"hostNetwork":true}},
+			{"metadata":{"name":"api-7d9f","namespace":"shop"},"spec":{}}
+		]}`))
+	}))
+	defer api.Close()
+
+	r := &kubernetesResolver{apiURL: api.URL, client: api.Client()}
+	var details sourceDetails
+	if err := r.lookup(context.Background(), netip.MustParseAddr("10.0.0.5"), &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.pod != "api-7d9f" || details.namespace != "shop" {
+		t.Fatalf("Expected pod shop/api-7d9f, got %+v", details)
+	}
+	if err := r.lookup(context.Background(), netip.MustParseAddr("10.0.0.6"), &details); err == nil {
+		t.Fatal("Expected an error for a failed request")
+	}
+}
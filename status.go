@@ -0,0 +1,123 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/version"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// The paths of the status endpoints, which are linked from the landing page.
+const (
+	buildInfoPath    = "/api/v1/status/buildinfo"
+	configStatusPath = "/api/v1/status/config"
+)
+
+// buildInfo is the build information served on buildInfoPath.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// buildInfoHandler serves the build information of the exporter.
+func buildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, buildInfo{
+		Version:   version.Version,
+		Revision:  version.GetRevision(),
+		Branch:    version.Branch,
+		BuildUser: version.BuildUser,
+		BuildDate: version.BuildDate,
+		GoVersion: runtime.Version(),
+	})
+}
+
+// configStatus is the state of the mapping configuration of the exporter or
+// of a tenant, as served on configStatusPath.
+type configStatus struct {
+	Tenant string `json:"tenant,omitempty"`
+	File   string `json:"file"`
+	// Hash is the SHA-256 checksum of the file when it was last loaded.
+	Hash                 string    `json:"hash"`
+	LastReloadSuccess    time.Time `json:"lastReloadSuccess"`
+	LastReloadSuccessful bool      `json:"lastReloadSuccessful"`
+	LastReloadError      string    `json:"lastReloadError,omitempty"`
+}
+
+// configStatuses holds the state of the mapping configurations by tenant.
+// The main configuration has no tenant.
+var configStatuses = struct {
+	mtx      sync.Mutex
+	statuses map[string]*configStatus
+}{statuses: map[string]*configStatus{}}
+
+// recordConfigLoad records the outcome of loading the mapping configuration
+// file of a tenant.
+func recordConfigLoad(fileName, tenantName string, sum []byte, err error) {
+	configStatuses.mtx.Lock()
+	defer configStatuses.mtx.Unlock()
+	s, ok := configStatuses.statuses[tenantName]
+	if !ok {
+		s = &configStatus{Tenant: tenantName}
+		configStatuses.statuses[tenantName] = s
+	}
+	s.File = fileName
+	s.LastReloadSuccessful = err == nil
+	if err != nil {
+		s.LastReloadError = err.Error()
+		return
+	}
+	s.LastReloadError = ""
+	s.LastReloadSuccess = clock.Now().UTC()
+	if sum != nil {
+		s.Hash = hex.EncodeToString(sum)
+	}
+}
+
+// configStatusHandler serves the state of the mapping configurations, the
+// main one first and then those of the tenants by name.
+func configStatusHandler(w http.ResponseWriter, r *http.Request) {
+	configStatuses.mtx.Lock()
+	statuses := make([]configStatus, 0, len(configStatuses.statuses))
+	for _, s := range configStatuses.statuses {
+		statuses = append(statuses, *s)
+	}
+	configStatuses.mtx.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Tenant < statuses[j].Tenant })
+	writeStatus(w, statuses)
+}
+
+// writeStatus writes the data of a status endpoint in the format of the API.
+func writeStatus(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+		Data   any    `json:"data"`
+	}{
+		Status: "success",
+		Data:   data,
+	})
+}
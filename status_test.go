@@ -0,0 +1,54 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+func TestConfigStatus(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(100, 0)}
+	defer func() { clock.ClockInstance = nil }()
+	configStatuses.statuses = map[string]*configStatus{}
+
+	recordConfigLoad("mapping.yml", "", []byte{0xab, 0xcd}, nil)
+	recordConfigLoad("tenant.yml", "a", []byte{0x01}, nil)
+	recordConfigLoad("tenant.yml", "a", nil, errors.New("bad mapping"))
+
+	w := httptest.NewRecorder()
+	configStatusHandler(w, httptest.NewRequest("GET", configStatusPath, nil))
+	var resp struct {
+		Data []configStatus `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("expected 2 configurations, got %+v", resp.Data)
+	}
+	primary, tenant := resp.Data[0], resp.Data[1]
+	if primary.Tenant != "" || primary.Hash != "abcd" || !primary.LastReloadSuccessful || !primary.LastReloadSuccess.Equal(time.Unix(100, 0)) {
+		t.Errorf("unexpected status of the main configuration: %+v", primary)
+	}
+	// A failed reload keeps the hash of the configuration in use.
+	if tenant.Tenant != "a" || tenant.Hash != "01" || tenant.LastReloadSuccessful || tenant.LastReloadError != "bad mapping" {
+		t.Errorf("unexpected status of the tenant configuration: %+v", tenant)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+const (
+	// syntheticSeriesPerMetric is the number of series of each synthetic
+	// metric, told apart by their series label.
+	syntheticSeriesPerMetric = 100
+	// syntheticBatchSize is the number of events sent to the exporter at a
+	// time.
+	syntheticBatchSize = 1000
+)
+
+// syntheticTypes are the types of the synthetic series, which are assigned
+// to the series in turn.
+var syntheticTypes = []string{"counter", "gauge", "timer", "distribution"}
+
+// fillSynthetic sends events to the exporter that create n synthetic series
+// of mixed types, for measuring scrape duration and memory use at a target
+// cardinality before real traffic arrives. The events are the same on every
+// run, so that measurements can be compared.
+//
+// Series i has the type syntheticTypes[i%4]. The series of each type are
+// spread over metrics named synthetic_<type>_<m> with
+// syntheticSeriesPerMetric values of the series label each.
+func fillSynthetic(n int, send func(event.Events)) {
+	batch := make(event.Events, 0, syntheticBatchSize)
+	for i := 0; i < n; i++ {
+		typ := syntheticTypes[i%len(syntheticTypes)]
+		k := i / len(syntheticTypes)
+		name := "synthetic_" + typ + "_" + strconv.Itoa(k/syntheticSeriesPerMetric)
+		labels := map[string]string{"series": strconv.Itoa(k % syntheticSeriesPerMetric)}
+		value := float64(i % 1000)
+
+		var e event.Event
+		switch typ {
+		case "counter":
+			e = &event.CounterEvent{CMetricName: name, CValue: 1, CLabels: labels}
+		case "gauge":
+			e = &event.GaugeEvent{GMetricName: name, GValue: value, GLabels: labels}
+		case "timer":
+			e = &event.ObserverEvent{OMetricName: name, OValue: value, OTimer: true, OLabels: labels}
+		case "distribution":
+			e = &event.ObserverEvent{OMetricName: name, OValue: value, OLabels: labels}
+		}
+		batch = append(batch, e)
+		if len(batch) == syntheticBatchSize {
+			send(batch)
+			batch = make(event.Events, 0, syntheticBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		send(batch)
+	}
+}
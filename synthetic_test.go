@@ -0,0 +1,69 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+func TestFillSynthetic(t *testing.T) {
+	const n = 2010
+
+	var first, second event.Events
+	fillSynthetic(n, func(e event.Events) { first = append(first, e...) })
+	fillSynthetic(n, func(e event.Events) { second = append(second, e...) })
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("expected the same events on every run")
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := exporter.NewExporter(reg, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	events := make(chan event.Events)
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(context.Background(), events)
+		close(done)
+	}()
+	fillSynthetic(n, func(e event.Events) { events <- e })
+	close(events)
+	<-done
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := map[dto.MetricType]int{}
+	total := 0
+	for _, f := range families {
+		series[f.GetType()] += len(f.GetMetric())
+		total += len(f.GetMetric())
+	}
+	if total != n {
+		t.Fatalf("expected %d series, got %d", n, total)
+	}
+	expected := map[dto.MetricType]int{dto.MetricType_COUNTER: 503, dto.MetricType_GAUGE: 503, dto.MetricType_SUMMARY: 1004}
+	if !reflect.DeepEqual(series, expected) {
+		t.Fatalf("expected series by type %v, got %v", expected, series)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+// tailPath is where the parsed events are streamed.
+const tailPath = "/debug/tail"
+
+// tailBuffer is the number of events buffered for a client. Events that do
+// not fit are skipped, so that a slow client does not hold up parsing.
+const tailBuffer = 256
+
+// tailEvent is a parsed event as streamed to clients.
+type tailEvent struct {
+	Line   string            `json:"line"`
+	Metric string            `json:"metric"`
+	Type   string            `json:"type"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels"`
+}
+
+// eventTail streams the events parsed from received lines to clients on
+// tailPath as server-sent events, so that operators can watch the traffic.
+// Each client can filter the events with a glob, and receives at most rate
+// events per second.
+type eventTail struct {
+	maxClients int
+	rate       int
+
+	// active is the number of clients, read without the lock so that lines
+	// are parsed without overhead while nobody is watching.
+	active  atomic.Int32
+	mtx     sync.Mutex
+	clients map[*tailClient]struct{}
+}
+
+type tailClient struct {
+	re     *regexp.Regexp
+	events chan []byte
+
+	// Guarded by the lock of the eventTail.
+	windowStart time.Time
+	sent        int
+	skipped     int
+}
+
+func newEventTail(maxClients, rate int) *eventTail {
+	return &eventTail{
+		maxClients: maxClients,
+		rate:       rate,
+		clients:    map[*tailClient]struct{}{},
+	}
+}
+
+// publish sends the events parsed from a line to the clients whose glob
+// matches their metric names.
+func (t *eventTail) publish(l string, events event.Events) {
+	if t.active.Load() == 0 {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := clock.Now()
+	for _, e := range events {
+		var data []byte
+		for c := range t.clients {
+			if c.re != nil && !c.re.MatchString(e.MetricName()) {
+				continue
+			}
+			if now.Sub(c.windowStart) >= time.Second {
+				c.windowStart = now
+				c.sent = 0
+			}
+			if c.sent >= t.rate {
+				c.skipped++
+				continue
+			}
+			if data == nil {
+				data, _ = json.Marshal(tailEvent{
+					Line:   l,
+					Metric: e.MetricName(),
+					Type:   string(e.MetricType()),
+					Value:  e.Value(),
+					Labels: e.Labels(),
+				})
+			}
+			select {
+			case c.events <- data:
+				c.sent++
+			default:
+				c.skipped++
+			}
+		}
+	}
+}
+
+// add registers a client, unless there are already as many as allowed.
+func (t *eventTail) add(c *tailClient) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if len(t.clients) >= t.maxClients {
+		return false
+	}
+	t.clients[c] = struct{}{}
+	t.active.Store(int32(len(t.clients)))
+	return true
+}
+
+func (t *eventTail) remove(c *tailClient) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.clients, c)
+	t.active.Store(int32(len(t.clients)))
+}
+
+// takeSkipped returns the number of events skipped for a client since the
+// last call.
+func (t *eventTail) takeSkipped(c *tailClient) int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	skipped := c.skipped
+	c.skipped = 0
+	return skipped
+}
+
+// ServeHTTP streams the parsed events whose metric names match the glob of
+// the match parameter, or all of them, until the client disconnects. The
+// number of events skipped because of the rate limit or a slow client is
+// sent as a comment every second.
+func (t *eventTail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	c := &tailClient{events: make(chan []byte, tailBuffer)}
+	if glob := r.URL.Query().Get("match"); glob != "" {
+		re, err := globToRegexp(glob)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid match %q: %s", glob, err), http.StatusBadRequest)
+			return
+		}
+		c.re = re
+	}
+	if !t.add(c) {
+		http.Error(w, fmt.Sprintf("too many clients, at most %d can tail at the same time", t.maxClients), http.StatusServiceUnavailable)
+		return
+	}
+	defer t.remove(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-c.events:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if skipped := t.takeSkipped(c); skipped > 0 {
+				if _, err := fmt.Fprintf(w, ": %d events skipped\n\n", skipped); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// tailingParser publishes the events parsed by the wrapped parser to the
+// clients of an eventTail.
+type tailingParser struct {
+	line.Format
+	tail *eventTail
+}
+
+func (p *tailingParser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := p.Format.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	p.tail.publish(line, events)
+	return events
+}
+
+// ForSource tails the lines of a source, if the wrapped parser parses the
+// lines of each source differently.
+func (p *tailingParser) ForSource(source netip.AddrPort) line.Format {
+	sf, ok := p.Format.(line.SourceFormat)
+	if !ok {
+		return p
+	}
+	return &tailingParser{Format: sf.ForSource(source), tail: p.tail}
+}
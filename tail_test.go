@@ -0,0 +1,117 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestEventTail(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	tail := newEventTail(1, 2)
+	server := httptest.NewServer(tail)
+	defer server.Close()
+
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	p := &tailingParser{Format: parser, tail: tail}
+	send := func(lines ...string) {
+		for _, l := range lines {
+			p.LineToEvents(l, *sampleErrors, *samplesReceived, tagErrors, tagsReceived, promslog.NewNopLogger())
+		}
+	}
+
+	// Without clients, nothing is published.
+	send("api.users.latency:1|ms")
+
+	resp, err := http.Get(server.URL + tailPath + "?match=api.*.latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", ct)
+	}
+
+	// A second client exceeds the limit.
+	second, err := http.Get(server.URL + tailPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Body.Close()
+	if second.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the second client to be refused, got %s", second.Status)
+	}
+
+	send(
+		"api.users.latency:100|ms|#env:prod",
+		"api.users.requests:1|c",
+		"api.orders.latency:200|ms",
+		// Over the rate limit.
+		"api.items.latency:300|ms",
+	)
+
+	reader := bufio.NewReader(resp.Body)
+	var got []tailEvent
+	for len(got) < 2 {
+		l, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(l), "data: ")
+		if !ok {
+			continue
+		}
+		var e tailEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	expected := []tailEvent{
+		{Line: "api.users.latency:100|ms|#env:prod", Metric: "api.users.latency", Type: "observer", Value: 0.1, Labels: map[string]string{"env": "prod"}},
+		{Line: "api.orders.latency:200|ms", Metric: "api.orders.latency", Type: "observer", Value: 0.2, Labels: map[string]string{}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+
+	// The skipped event is reported.
+	for {
+		l, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(l, ":") {
+			if l != ": 1 events skipped\n" {
+				t.Fatalf("unexpected comment %q", l)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/statsd_exporter/pkg/listener"
+)
+
+// tcpTLSConfig returns the TLS configuration of the StatsD TCP listeners, or
+// nil if TLS is not enabled. With a client CA file, clients have to present a
+// certificate signed by one of its CAs.
+func tcpTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, errors.New("a client CA file requires a certificate and key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both a certificate and a key are needed")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// parseIdentityLabels parses values of --statsd.tcp-tls-identity-label of the
+// form <label>=<field>, where field is one of listener.IdentityFields.
+func parseIdentityLabels(flags []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, f := range flags {
+		name, field, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid identity label %q, expected <label>=<field>", f)
+		}
+		if !model.LabelName(name).IsValidLegacy() {
+			return nil, fmt.Errorf("invalid identity label %q: invalid label name %q", f, name)
+		}
+		if !slices.Contains(listener.IdentityFields, field) {
+			return nil, fmt.Errorf("invalid identity label %q: unknown field %q, must be one of %s", f, field, strings.Join(listener.IdentityFields, ", "))
+		}
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Errorf("identity label %q is set more than once", name)
+		}
+		labels[name] = field
+	}
+	return labels, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIdentityLabels(t *testing.T) {
+	scenarios := []struct {
+		name  string
+		flags []string
+		out   map[string]string
+		err   string
+	}{
+		{
+			name:  "labels",
+			flags: []string{"client=cn", "client_uri=uri"},
+			out:   map[string]string{"client": "cn", "client_uri": "uri"},
+		},
+		{
+			name:  "no field",
+			flags: []string{"client"},
+			err:   "expected <label>=<field>",
+		},
+		{
+			name:  "unknown field",
+			flags: []string{"client=serial"},
+			err:   "unknown field",
+		},
+		{
+			name:  "invalid label name",
+			flags: []string{"client-cn=cn"},
+			err:   "invalid label name",
+		},
+		{
+			name:  "duplicate label",
+			flags: []string{"client=cn", "client=dns"},
+			err:   "more than once",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			out, err := parseIdentityLabels(s.flags)
+			if s.err != "" {
+				if err == nil || !strings.Contains(err.Error(), s.err) {
+					t.Fatalf("expected error containing %q, got %v", s.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(out, s.out) {
+				t.Fatalf("expected %v, got %v", s.out, out)
+			}
+		})
+	}
+}
+
+func TestTCPTLSConfig(t *testing.T) {
+	for _, s := range []struct {
+		name                            string
+		certFile, keyFile, clientCAFile string
+		err                             string
+	}{
+		{name: "disabled"},
+		{name: "client CA without certificate", clientCAFile: "ca.pem", err: "requires a certificate and key"},
+		{name: "certificate without key", certFile: "cert.pem", err: "both a certificate and a key"},
+		{name: "missing files", certFile: "missing.pem", keyFile: "missing.key", err: "unable to load certificate"},
+	} {
+		t.Run(s.name, func(t *testing.T) {
+			config, err := tcpTLSConfig(s.certFile, s.keyFile, s.clientCAFile)
+			if s.err == "" {
+				if err != nil || config != nil {
+					t.Fatalf("expected no TLS configuration, got %v, %v", config, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), s.err) {
+				t.Fatalf("expected error containing %q, got %v", s.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+)
+
+// tenantLabel is added to every metric of a tenant.
+const tenantLabel = "tenant"
+
+var tenantNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+type tenantConfig struct {
+	Name string `yaml:"name"`
+	// ListenUDP and ListenTCP are addresses of listeners that only receive
+	// traffic for this tenant.
+	ListenUDP string `yaml:"listen_udp"`
+	ListenTCP string `yaml:"listen_tcp"`
+	// Prefix routes metrics received on the shared listeners to this tenant.
+	// It is removed from the metric name.
+	Prefix string `yaml:"prefix"`
+	// MappingConfig is the tenant's mapping file. Without one, metrics are
+	// not mapped.
+	MappingConfig string `yaml:"mapping_config"`
+	// Labels are added to the events received on ListenUDP and ListenTCP.
+	Labels map[string]string `yaml:"labels"`
+}
+
+type tenantsConfig struct {
+	Tenants []tenantConfig `yaml:"tenants"`
+}
+
+func loadTenantsConfig(fileName string) ([]tenantConfig, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var cfg tenantsConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+	prefixes := map[string]struct{}{}
+	for _, t := range cfg.Tenants {
+		if !tenantNameRE.MatchString(t.Name) {
+			return nil, fmt.Errorf("invalid tenant name %q, must match %s", t.Name, tenantNameRE)
+		}
+		if _, ok := names[t.Name]; ok {
+			return nil, fmt.Errorf("duplicate tenant %q", t.Name)
+		}
+		names[t.Name] = struct{}{}
+
+		if t.ListenUDP == "" && t.ListenTCP == "" && t.Prefix == "" {
+			return nil, fmt.Errorf("tenant %q needs at least one of listen_udp, listen_tcp or prefix", t.Name)
+		}
+		for name, value := range t.Labels {
+			if err := validateListenerLabel(name, value); err != nil {
+				return nil, fmt.Errorf("tenant %q: %w", t.Name, err)
+			}
+		}
+		if t.Prefix != "" {
+			if _, ok := prefixes[t.Prefix]; ok {
+				return nil, fmt.Errorf("tenant %q: prefix %q is used by another tenant", t.Name, t.Prefix)
+			}
+			prefixes[t.Prefix] = struct{}{}
+		}
+	}
+	return cfg.Tenants, nil
+}
+
+// tenant holds the processing pipeline of one tenant. Its metrics go into a
+// registry of its own.
+type tenant struct {
+	config   tenantConfig
+	registry *prometheus.Registry
+	mapper   *mapper.MetricMapper
+	events   chan event.Events
+	queue    *event.EventQueue
+	exporter *exporter.Exporter
+}
+
+func newTenant(cfg tenantConfig, expandEnv bool, cacheSize int, cacheType string, eventQueueSize uint, flushThreshold int, flushInterval time.Duration, eventsFlushed prometheus.Counter, logger *slog.Logger) (*tenant, error) {
+	t := &tenant{
+		config:   cfg,
+		registry: prometheus.NewRegistry(),
+		events:   make(chan event.Events, eventQueueSize),
+	}
+	t.mapper = &mapper.MetricMapper{Registerer: t.registry, Logger: logger.With(tenantLabel, cfg.Name), ExpandEnv: expandEnv}
+
+	cache, err := getCache(cacheSize, cacheType, t.mapper.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	t.mapper.UseCache(cache)
+
+	if cfg.MappingConfig != "" {
+		if err := t.mapper.InitFromFile(cfg.MappingConfig); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", cfg.Name, err)
+		}
+		configLoaded(cfg.MappingConfig, cfg.Name)
+	}
+
+	t.queue = event.NewEventQueue(t.events, flushThreshold, flushInterval, eventsFlushed)
+	return t, nil
+}
+
+func (t *tenant) reloadConfig(logger *slog.Logger) error {
+	if t.config.MappingConfig == "" {
+		return nil
+	}
+	return reloadConfig(t.config.MappingConfig, t.mapper, t.config.Name, logger.With(tenantLabel, t.config.Name))
+}
+
+// tenantGatherers returns the registries of the tenants by name. With
+// sweepOnScrape, expired time series are removed before each gathering.
+func tenantGatherers(tenants []*tenant, sweepOnScrape bool) map[string]prometheus.Gatherer {
+	gatherers := make(map[string]prometheus.Gatherer, len(tenants))
+	for _, t := range tenants {
+		var g prometheus.Gatherer = t.registry
+		if sweepOnScrape {
+			g = t.exporter.SweepingGatherer(g)
+		}
+		gatherers[t.config.Name] = t.exporter.GaugeHistogramGatherer(g)
+	}
+	return gatherers
+}
+
+// tenantMetadataHandler serves the metadata of the main exporter, or of the
+// tenant selected with ?tenant=<name>.
+func tenantMetadataHandler(main *exporter.Exporter, tenants []*tenant) http.Handler {
+	return tenantExporterHandler(main, tenants, (*exporter.Exporter).MetadataHandler)
+}
+
+// tenantMemoryUsageHandler serves the estimated memory usage of the series of
+// the main exporter, or of the tenant selected with ?tenant=<name>.
+func tenantMemoryUsageHandler(main *exporter.Exporter, tenants []*tenant) http.Handler {
+	return tenantExporterHandler(main, tenants, (*exporter.Exporter).MemoryUsageHandler)
+}
+
+// tenantLearnedBucketsHandler serves the learned histogram buckets of the main
+// exporter, or of the tenant selected with ?tenant=<name>.
+func tenantLearnedBucketsHandler(main *exporter.Exporter, tenants []*tenant) http.Handler {
+	return tenantExporterHandler(main, tenants, (*exporter.Exporter).LearnedBucketsHandler)
+}
+
+// tenantExporterHandler serves the handler of the main exporter, or of the
+// exporter of the tenant selected with ?tenant=<name>.
+func tenantExporterHandler(main *exporter.Exporter, tenants []*tenant, handler func(*exporter.Exporter) http.Handler) http.Handler {
+	h := handler(main)
+	if len(tenants) == 0 {
+		return h
+	}
+	th := &tenantHandler{fallback: h, tenants: make(map[string]http.Handler, len(tenants))}
+	for _, t := range tenants {
+		th.tenants[t.config.Name] = handler(t.exporter)
+	}
+	return th
+}
+
+type tenantRoute struct {
+	prefix  string
+	handler event.EventHandler
+}
+
+// tenantRouter passes events whose metric name starts with the prefix of a
+// tenant on to that tenant, with the prefix removed, and all other events to
+// the fallback handler.
+type tenantRouter struct {
+	fallback event.EventHandler
+	routes   []tenantRoute
+}
+
+func newTenantRouter(fallback event.EventHandler, tenants []*tenant) event.EventHandler {
+	var routes []tenantRoute
+	for _, t := range tenants {
+		if t.config.Prefix != "" {
+			routes = append(routes, tenantRoute{prefix: t.config.Prefix, handler: t.queue})
+		}
+	}
+	if len(routes) == 0 {
+		return fallback
+	}
+	// Longer prefixes are more specific.
+	sort.Slice(routes, func(i, j int) bool { return len(routes[i].prefix) > len(routes[j].prefix) })
+	return &tenantRouter{fallback: fallback, routes: routes}
+}
+
+func (r *tenantRouter) Queue(events event.Events) {
+	var rest event.Events
+	for _, e := range events {
+		routed := false
+		for _, route := range r.routes {
+			if name, ok := strings.CutPrefix(e.MetricName(), route.prefix); ok {
+				route.handler.Queue(event.Events{renameEvent(e, name)})
+				routed = true
+				break
+			}
+		}
+		if !routed {
+			rest = append(rest, e)
+		}
+	}
+	if len(rest) > 0 {
+		r.fallback.Queue(rest)
+	}
+}
+
+// Backlog reports the backlog of the fallback handler, which receives the
+// traffic that is not routed to a tenant.
+func (r *tenantRouter) Backlog() int {
+	if b, ok := r.fallback.(event.BacklogReporter); ok {
+		return b.Backlog()
+	}
+	return 0
+}
+
+// renameEvent returns a copy of the event with a different metric name.
+func renameEvent(e event.Event, name string) event.Event {
+	switch ev := e.(type) {
+	case *event.CounterEvent:
+		c := *ev
+		c.CMetricName = name
+		return &c
+	case *event.GaugeEvent:
+		g := *ev
+		g.GMetricName = name
+		return &g
+	case *event.ObserverEvent:
+		o := *ev
+		o.OMetricName = name
+		return &o
+	default:
+		return e
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+func TestLoadTenantsConfig(t *testing.T) {
+	scenarios := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `tenants:
+- name: team_a
+  prefix: team_a.
+- name: team-b
+  listen_udp: ":9126"
+  labels:
+    listener: team_b
+`,
+		},
+		{
+			name:   "invalid name",
+			config: "tenants:\n- name: team a\n  prefix: a.\n",
+			err:    "invalid tenant name",
+		},
+		{
+			name:   "duplicate name",
+			config: "tenants:\n- name: a\n  prefix: a.\n- name: a\n  prefix: b.\n",
+			err:    "duplicate tenant",
+		},
+		{
+			name:   "duplicate prefix",
+			config: "tenants:\n- name: a\n  prefix: a.\n- name: b\n  prefix: a.\n",
+			err:    "used by another tenant",
+		},
+		{
+			name:   "no traffic",
+			config: "tenants:\n- name: a\n",
+			err:    "needs at least one",
+		},
+		{
+			name:   "invalid label",
+			config: "tenants:\n- name: a\n  listen_udp: \":9126\"\n  labels:\n    listener-name: internal\n",
+			err:    "invalid label name",
+		},
+		{
+			name:   "unknown field",
+			config: "tenants:\n- name: a\n  prefix: a.\n  listen: \":9126\"\n",
+			err:    "not found",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "tenants.yml")
+			if err := os.WriteFile(fileName, []byte(s.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadTenantsConfig(fileName)
+			if s.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.err != "" && (err == nil || !strings.Contains(err.Error(), s.err)) {
+				t.Fatalf("expected error containing %q, got %v", s.err, err)
+			}
+		})
+	}
+}
+
+func TestTenantRouter(t *testing.T) {
+	fallback := &event.UnbufferedEventHandler{C: make(chan event.Events, 10)}
+	a := &event.UnbufferedEventHandler{C: make(chan event.Events, 10)}
+	ab := &event.UnbufferedEventHandler{C: make(chan event.Events, 10)}
+	r := &tenantRouter{
+		fallback: fallback,
+		routes: []tenantRoute{
+			{prefix: "a.b.", handler: ab},
+			{prefix: "a.", handler: a},
+		},
+	}
+
+	r.Queue(event.Events{
+		&event.CounterEvent{CMetricName: "a.b.foo", CValue: 1},
+		&event.GaugeEvent{GMetricName: "a.bar", GValue: 2},
+		&event.ObserverEvent{OMetricName: "c.baz", OValue: 3},
+	})
+
+	for _, s := range []struct {
+		handler *event.UnbufferedEventHandler
+		name    string
+	}{
+		{handler: ab, name: "foo"},
+		{handler: a, name: "bar"},
+		{handler: fallback, name: "c.baz"},
+	} {
+		select {
+		case events := <-s.handler.C:
+			if len(events) != 1 || events[0].MetricName() != s.name {
+				t.Fatalf("expected a single event named %q, got %v", s.name, events)
+			}
+		default:
+			t.Fatalf("expected event %q to be routed", s.name)
+		}
+	}
+}
+
+func TestTenantMetricsHandler(t *testing.T) {
+	newRegistry := func(name string) *prometheus.Registry {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: "Test."}))
+		return reg
+	}
+	h := newMetricsHandler(prometheus.NewRegistry(), newRegistry("default_total"), map[string]prometheus.Gatherer{"a": newRegistry("a_total")}, false, promslog.NewNopLogger())
+
+	for _, s := range []struct {
+		url    string
+		status int
+		metric string
+	}{
+		{url: "/metrics", status: http.StatusOK, metric: "default_total"},
+		{url: "/metrics?tenant=a", status: http.StatusOK, metric: "a_total"},
+		{url: "/metrics?tenant=b", status: http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, s.url, nil))
+		if rec.Code != s.status {
+			t.Fatalf("%s: expected status %d, got %d", s.url, s.status, rec.Code)
+		}
+		if s.metric != "" && !strings.Contains(rec.Body.String(), s.metric) {
+			t.Fatalf("%s: expected %s in body:\n%s", s.url, s.metric, rec.Body.String())
+		}
+	}
+}
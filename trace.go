@@ -0,0 +1,159 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+// maxPendingTraces bounds the number of traced events that have been parsed
+// but not yet handled by the exporter, in case events are dropped in between.
+const maxPendingTraces = 1000
+
+// metricTracer logs how lines for metrics matching a glob are processed, from
+// the raw line to the series they update. At most limit lines per second are
+// traced.
+type metricTracer struct {
+	re     *regexp.Regexp
+	limit  int
+	logger *slog.Logger
+
+	mtx         sync.Mutex
+	windowStart time.Time
+	traces      int
+	// pending holds the parsed events of traced lines until the exporter
+	// handles them.
+	pending map[event.Event]struct{}
+}
+
+// globToRegexp converts a glob, in which * matches any sequence of
+// characters, to an anchored regular expression.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func newMetricTracer(glob string, limit int, logger *slog.Logger) (*metricTracer, error) {
+	re, err := globToRegexp(glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace glob %q: %w", glob, err)
+	}
+	return &metricTracer{
+		re:      re,
+		limit:   limit,
+		logger:  logger,
+		pending: map[event.Event]struct{}{},
+	}, nil
+}
+
+// allow reports whether another line can be traced within the rate limit.
+func (t *metricTracer) allow() bool {
+	now := clock.Now()
+	if now.Sub(t.windowStart) >= time.Second {
+		t.windowStart = now
+		t.traces = 0
+	}
+	if t.traces >= t.limit {
+		return false
+	}
+	t.traces++
+	return true
+}
+
+// traceLine logs a line and the events parsed from it if any of them matches.
+// The events are remembered so that the exporter traces them as well.
+func (t *metricTracer) traceLine(line string, events event.Events) {
+	var matching event.Events
+	for _, e := range events {
+		if traceable(e) && t.re.MatchString(e.MetricName()) {
+			matching = append(matching, e)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if !t.allow() {
+		return
+	}
+	if len(t.pending)+len(matching) > maxPendingTraces {
+		t.pending = map[event.Event]struct{}{}
+	}
+	for _, e := range matching {
+		t.pending[e] = struct{}{}
+		t.logger.Info("Trace", "stage", "parsed", "line", line, "metric", e.MetricName(), "type", e.MetricType(), "value", e.Value(), "labels", e.Labels())
+	}
+}
+
+// exporterTrace returns a logger for the exporter to trace the event with, if
+// it was parsed from a traced line.
+func (t *metricTracer) exporterTrace(e event.Event) *slog.Logger {
+	if !traceable(e) {
+		return nil
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if _, ok := t.pending[e]; !ok {
+		return nil
+	}
+	delete(t.pending, e)
+	return t.logger.With("metric", e.MetricName())
+}
+
+// traceable reports whether the event can be followed through the exporter.
+// Events are identified by their pointers; events of custom line formats may
+// not be pointers.
+func traceable(e event.Event) bool {
+	return reflect.ValueOf(e).Kind() == reflect.Pointer
+}
+
+// tracingParser traces the lines parsed by the wrapped parser.
+type tracingParser struct {
+	line.Format
+	tracer *metricTracer
+}
+
+func (p *tracingParser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.CounterVec, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	events := p.Format.LineToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	p.tracer.traceLine(line, events)
+	return events
+}
+
+// ForSource traces the lines of a source, if the wrapped parser parses the
+// lines of each source differently.
+func (p *tracingParser) ForSource(source netip.AddrPort) line.Format {
+	sf, ok := p.Format.(line.SourceFormat)
+	if !ok {
+		return p
+	}
+	return &tracingParser{Format: sf.ForSource(source), tracer: p.tracer}
+}
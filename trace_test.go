@@ -0,0 +1,107 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestMetricTracer(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	tracer, err := newMetricTracer("api.*.latency", 2, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	p := &tracingParser{Format: parser, tracer: tracer}
+
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(`
+mappings:
+- match: api.*.latency
+  name: api_latency
+  labels:
+    endpoint: $1
+`); err != nil {
+		t.Fatalf("config load error: %v", err)
+	}
+	ex := exporter.NewExporter(prometheus.NewRegistry(), testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Trace = tracer.exporterTrace
+
+	events := make(chan event.Events)
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(context.Background(), events)
+		close(done)
+	}()
+	for _, l := range []string{
+		"api.users.latency:100|ms|#env:prod",
+		"api.users.requests:1|c",
+		"api.orders.latency:200|ms",
+		// Over the rate limit.
+		"api.items.latency:300|ms",
+	} {
+		events <- p.LineToEvents(l, *sampleErrors, *samplesReceived, tagErrors, tagsReceived, promslog.NewNopLogger())
+	}
+	close(events)
+	<-done
+
+	out := buf.String()
+	for _, want := range []string{
+		`stage=parsed line=api.users.latency:100|ms|#env:prod metric=api.users.latency type=observer value=0.1 labels=map[env:prod]`,
+		`stage=mapped mapped=true match=api.*.latency name=api_latency labels="map[endpoint:users env:prod]" value=0.1`,
+		`stage=parsed line=api.orders.latency:200|ms`,
+		`metric=api.orders.latency stage=mapped`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected trace to contain %q, got:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"api.users.requests", "api.items.latency"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected %s not to be traced, got:\n%s", unwanted, out)
+		}
+	}
+	if len(tracer.pending) != 0 {
+		t.Errorf("expected no pending traces, got %d", len(tracer.pending))
+	}
+
+	// The rate limit resets after a second.
+	buf.Reset()
+	clock.ClockInstance.Instant = time.Unix(1, 0)
+	p.LineToEvents("api.items.latency:300|ms", *sampleErrors, *samplesReceived, tagErrors, tagsReceived, promslog.NewNopLogger())
+	if !strings.Contains(buf.String(), "api.items.latency") {
+		t.Errorf("expected trace after the rate limit reset, got:\n%s", buf.String())
+	}
+}
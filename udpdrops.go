@@ -0,0 +1,219 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// udpDropsTracepoint is the tracepoint that the kernel hits when it drops a
+// UDP packet because it cannot queue it to the socket, mostly because the
+// receive buffer is full.
+const udpDropsTracepoint = "udp/udp_fail_queue_rcv_skb"
+
+// udpDropsPortFields are the fields of the udpDropsTracepoint that hold the
+// local port: lport, the port of the socket, before Linux 6.10, and dport,
+// the destination port of the packet, since.
+var udpDropsPortFields = []string{"lport", "dport"}
+
+// tracefsRoots are the places where tracefs is usually mounted.
+var tracefsRoots = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// errUDPDropsUnsupported is returned by newUDPDropMonitor in builds without
+// eBPF support.
+var errUDPDropsUnsupported = errors.New("counting kernel UDP drops requires Linux and a build with the ebpf tag")
+
+// udpDropMonitor counts the UDP packets that the kernel dropped for the
+// sockets of some local ports.
+type udpDropMonitor interface {
+	// Drops returns the number of packets dropped by port.
+	Drops() (map[uint16]uint64, error)
+	Close() error
+}
+
+// tracepointField returns the offset and size of a field of a tracepoint from
+// its format, as found in tracefs. The layout of tracepoints changes between
+// kernel versions, so it is looked up rather than compiled in.
+func tracepointField(format, name string) (offset, size int, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(format))
+	for scanner.Scan() {
+		var decl string
+		attrs := map[string]string{}
+		for _, part := range strings.Split(scanner.Text(), ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+			if !ok {
+				continue
+			}
+			if key == "field" {
+				decl = value
+				continue
+			}
+			attrs[key] = value
+		}
+		fields := strings.Fields(decl)
+		if len(fields) == 0 || fields[len(fields)-1] != name {
+			continue
+		}
+		if offset, err = strconv.Atoi(attrs["offset"]); err != nil {
+			return 0, 0, fmt.Errorf("invalid offset of field %s: %w", name, err)
+		}
+		if size, err = strconv.Atoi(attrs["size"]); err != nil {
+			return 0, 0, fmt.Errorf("invalid size of field %s: %w", name, err)
+		}
+		return offset, size, nil
+	}
+	return 0, 0, fmt.Errorf("tracepoint has no field %s", name)
+}
+
+// udpDropsPortField returns the offset and size of the local port in the
+// format of the udpDropsTracepoint.
+func udpDropsPortField(format string) (offset, size int, err error) {
+	for _, name := range udpDropsPortFields {
+		if offset, size, err = tracepointField(format, name); err == nil {
+			return offset, size, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("tracepoint %s has none of the fields %s", udpDropsTracepoint, strings.Join(udpDropsPortFields, ", "))
+}
+
+// The eBPF instructions of the UDP drops program.
+const (
+	bpfLdxMemH     = 0x69 // r_dst = *(u16 *)(r_src + off)
+	bpfLdxMemW     = 0x61 // r_dst = *(u32 *)(r_src + off)
+	bpfStxMemW     = 0x63 // *(u32 *)(r_dst + off) = r_src
+	bpfMov64Reg    = 0xbf // r_dst = r_src
+	bpfMov64Imm    = 0xb7 // r_dst = imm
+	bpfAdd64Imm    = 0x07 // r_dst += imm
+	bpfLdImm64     = 0x18 // r_dst = imm64, over two instructions
+	bpfCall        = 0x85 // call helper imm
+	bpfJeqImm      = 0x15 // if r_dst == imm goto pc + off
+	bpfAtomicAdd64 = 0xdb // lock *(u64 *)(r_dst + off) += r_src
+	bpfExit        = 0x95
+
+	bpfPseudoMapFD       = 1
+	bpfFuncMapLookupElem = 1
+	bpfInstructionSize   = 8
+	bpfRegisterFramePtr  = 10
+	// udpDropsKeyStackSlot is where the program keeps the map key on its
+	// stack.
+	udpDropsKeyStackSlot = -4
+)
+
+// bpfInstruction is an eBPF instruction as the kernel lays it out.
+type bpfInstruction struct {
+	code     uint8
+	dst, src uint8
+	off      int16
+	imm      int32
+}
+
+// udpDropsProgram assembles the eBPF program that is attached to the
+// udpDropsTracepoint. It increments the counter of the port of the socket in
+// the map, if the port is one of the exporter's, that is it is in the map:
+//
+//	key = ctx->lport
+//	if (counter = map_lookup_elem(map, &key)) atomic_add(counter, 1)
+//	return 0
+//
+// The program is assembled here, rather than compiled from C, so that the
+// exporter builds with the Go toolchain alone for every platform.
+func udpDropsProgram(portOffset, portSize, mapFD int) ([]byte, error) {
+	load := uint8(bpfLdxMemH)
+	switch portSize {
+	case 2:
+	case 4:
+		load = bpfLdxMemW
+	default:
+		return nil, fmt.Errorf("unexpected size %d of the port field", portSize)
+	}
+	program := []bpfInstruction{
+		{code: load, dst: 2, src: 1, off: int16(portOffset)},
+		{code: bpfStxMemW, dst: bpfRegisterFramePtr, src: 2, off: udpDropsKeyStackSlot},
+		{code: bpfMov64Reg, dst: 2, src: bpfRegisterFramePtr},
+		{code: bpfAdd64Imm, dst: 2, imm: udpDropsKeyStackSlot},
+		{code: bpfLdImm64, dst: 1, src: bpfPseudoMapFD, imm: int32(mapFD)},
+		{},
+		{code: bpfCall, imm: bpfFuncMapLookupElem},
+		{code: bpfJeqImm, dst: 0, off: 2, imm: 0},
+		{code: bpfMov64Imm, dst: 1, imm: 1},
+		{code: bpfAtomicAdd64, dst: 0, src: 1},
+		{code: bpfMov64Imm, dst: 0, imm: 0},
+		{code: bpfExit},
+	}
+
+	// The registers share a byte, with the destination in the low bits on
+	// little-endian platforms and in the high bits on big-endian ones.
+	littleEndian := binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+	b := make([]byte, 0, len(program)*bpfInstructionSize)
+	for _, ins := range program {
+		regs := ins.dst | ins.src<<4
+		if !littleEndian {
+			regs = ins.dst<<4 | ins.src
+		}
+		b = append(b, ins.code, regs)
+		b = binary.NativeEndian.AppendUint16(b, uint16(ins.off))
+		b = binary.NativeEndian.AppendUint32(b, uint32(ins.imm))
+	}
+	return b, nil
+}
+
+// udpListener is the protocol and address of a UDP listener.
+type udpListener struct {
+	proto, address string
+}
+
+// udpDropsCollector exports the kernel drops of the UDP listeners counted by
+// a udpDropMonitor.
+type udpDropsCollector struct {
+	monitor   udpDropMonitor
+	listeners map[uint16]udpListener
+	desc      *prometheus.Desc
+	logger    *slog.Logger
+}
+
+func newUDPDropsCollector(monitor udpDropMonitor, listeners map[uint16]udpListener, logger *slog.Logger) *udpDropsCollector {
+	return &udpDropsCollector{
+		monitor:   monitor,
+		listeners: listeners,
+		desc: prometheus.NewDesc(
+			"statsd_exporter_udp_kernel_drops_total",
+			"The number of packets for the UDP listeners that the kernel dropped before the exporter could read them, mostly because the receive buffer was full, as counted with eBPF.",
+			[]string{"listener", "address"}, nil,
+		),
+		logger: logger,
+	}
+}
+
+func (c *udpDropsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *udpDropsCollector) Collect(ch chan<- prometheus.Metric) {
+	drops, err := c.monitor.Drops()
+	if err != nil {
+		c.logger.Warn("Error reading kernel UDP drops", "error", err)
+		return
+	}
+	for port, l := range c.listeners {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(drops[port]), l.proto, l.address)
+	}
+}
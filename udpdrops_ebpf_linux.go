@@ -0,0 +1,244 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && ebpf
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The attributes of the bpf system call for the commands that are used,
+// laid out like the corresponding members of union bpf_attr.
+type (
+	bpfMapCreateAttr struct {
+		mapType    uint32
+		keySize    uint32
+		valueSize  uint32
+		maxEntries uint32
+	}
+	bpfMapElemAttr struct {
+		mapFD uint32
+		_     uint32
+		key   uint64
+		value uint64
+		flags uint64
+	}
+	bpfProgLoadAttr struct {
+		progType    uint32
+		insnCnt     uint32
+		insns       uint64
+		license     uint64
+		logLevel    uint32
+		logSize     uint32
+		logBuf      uint64
+		kernVersion uint32
+		progFlags   uint32
+	}
+)
+
+// bpfLogSize is the size of the buffer for the log of the verifier, which is
+// only requested after the program was rejected.
+const bpfLogSize = 64 * 1024
+
+func bpf(cmd uintptr, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, cmd, uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(fd), nil
+}
+
+// ebpfUDPDropMonitor counts the drops of the ports in a map, which the
+// program attached to the udpDropsTracepoint updates.
+type ebpfUDPDropMonitor struct {
+	ports                 []uint16
+	mapFD, progFD, perfFD int
+}
+
+// newUDPDropMonitor loads the UDP drops program and attaches it to the
+// udpDropsTracepoint. This needs CAP_BPF and CAP_PERFMON, or CAP_SYS_ADMIN
+// on older kernels, and tracefs.
+func newUDPDropMonitor(ports []uint16) (udpDropMonitor, error) {
+	root, err := tracefsRoot()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, "events", udpDropsTracepoint)
+	format, err := os.ReadFile(filepath.Join(dir, "format"))
+	if err != nil {
+		return nil, err
+	}
+	offset, size, err := udpDropsPortField(string(format))
+	if err != nil {
+		return nil, err
+	}
+	idText, err := os.ReadFile(filepath.Join(dir, "id"))
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(string(idText)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id of tracepoint %s: %w", udpDropsTracepoint, err)
+	}
+
+	// Kernels before 5.11 account the memory of maps and programs against
+	// RLIMIT_MEMLOCK, whose default is too low for them.
+	_ = unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY})
+
+	m := &ebpfUDPDropMonitor{ports: ports, mapFD: -1, progFD: -1, perfFD: -1}
+	if err := m.attach(offset, size, id); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *ebpfUDPDropMonitor) attach(portOffset, portSize int, tracepointID uint64) error {
+	mapAttr := bpfMapCreateAttr{
+		mapType:    unix.BPF_MAP_TYPE_HASH,
+		keySize:    4,
+		valueSize:  8,
+		maxEntries: uint32(max(len(m.ports), 1)),
+	}
+	var err error
+	if m.mapFD, err = bpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&mapAttr), unsafe.Sizeof(mapAttr)); err != nil {
+		return fmt.Errorf("error creating the map of drops: %w", err)
+	}
+	// Only the ports in the map are counted.
+	for _, port := range m.ports {
+		key, value := uint32(port), uint64(0)
+		if err := m.mapElem(unix.BPF_MAP_UPDATE_ELEM, key, &value); err != nil {
+			return fmt.Errorf("error adding port %d to the map of drops: %w", port, err)
+		}
+	}
+
+	program, err := udpDropsProgram(portOffset, portSize, m.mapFD)
+	if err != nil {
+		return err
+	}
+	if m.progFD, err = loadTracepointProgram(program); err != nil {
+		return err
+	}
+
+	attr := unix.PerfEventAttr{
+		Type:        unix.PERF_TYPE_TRACEPOINT,
+		Config:      tracepointID,
+		Size:        uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+		Sample_type: unix.PERF_SAMPLE_RAW,
+		Sample:      1,
+		Wakeup:      1,
+	}
+	// A program attached to a tracepoint through the event of one CPU runs
+	// on all of them.
+	if m.perfFD, err = unix.PerfEventOpen(&attr, -1, 0, -1, unix.PERF_FLAG_FD_CLOEXEC); err != nil {
+		return fmt.Errorf("error opening tracepoint %s: %w", udpDropsTracepoint, err)
+	}
+	if err := unix.IoctlSetInt(m.perfFD, unix.PERF_EVENT_IOC_SET_BPF, m.progFD); err != nil {
+		return fmt.Errorf("error attaching to tracepoint %s: %w", udpDropsTracepoint, err)
+	}
+	if err := unix.IoctlSetInt(m.perfFD, unix.PERF_EVENT_IOC_ENABLE, 0); err != nil {
+		return fmt.Errorf("error enabling tracepoint %s: %w", udpDropsTracepoint, err)
+	}
+	return nil
+}
+
+// loadTracepointProgram loads a tracepoint program. If the verifier rejects
+// it, the program is loaded again to return the log of the verifier.
+func loadTracepointProgram(program []byte) (int, error) {
+	license := []byte("Apache-2.0\x00")
+	log := make([]byte, bpfLogSize)
+	// The kernel reads and writes them through the addresses in attr, which
+	// do not keep them alive.
+	defer runtime.KeepAlive(program)
+	defer runtime.KeepAlive(license)
+	defer runtime.KeepAlive(log)
+	attr := bpfProgLoadAttr{
+		progType: unix.BPF_PROG_TYPE_TRACEPOINT,
+		insnCnt:  uint32(len(program) / bpfInstructionSize),
+		insns:    uint64(uintptr(unsafe.Pointer(&program[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+	fd, err := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == nil {
+		return fd, nil
+	}
+
+	attr.logLevel = 1
+	attr.logSize = uint32(len(log))
+	attr.logBuf = uint64(uintptr(unsafe.Pointer(&log[0])))
+	if fd, retryErr := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); retryErr == nil {
+		return fd, nil
+	}
+	if n := strings.IndexByte(string(log), 0); n > 0 {
+		return 0, fmt.Errorf("error loading the UDP drops program: %w: %s", err, strings.TrimSpace(string(log[:n])))
+	}
+	return 0, fmt.Errorf("error loading the UDP drops program: %w", err)
+}
+
+// mapElem runs a command on the counter of a port in the map, reading it
+// with BPF_MAP_LOOKUP_ELEM or writing it with BPF_MAP_UPDATE_ELEM.
+func (m *ebpfUDPDropMonitor) mapElem(cmd uintptr, key uint32, value *uint64) error {
+	attr := bpfMapElemAttr{
+		mapFD: uint32(m.mapFD),
+		key:   uint64(uintptr(unsafe.Pointer(&key))),
+		value: uint64(uintptr(unsafe.Pointer(value))),
+	}
+	_, err := bpf(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(&key)
+	runtime.KeepAlive(value)
+	return err
+}
+
+func (m *ebpfUDPDropMonitor) Drops() (map[uint16]uint64, error) {
+	drops := make(map[uint16]uint64, len(m.ports))
+	for _, port := range m.ports {
+		var value uint64
+		if err := m.mapElem(unix.BPF_MAP_LOOKUP_ELEM, uint32(port), &value); err != nil {
+			return nil, fmt.Errorf("error reading the drops of port %d: %w", port, err)
+		}
+		drops[port] = value
+	}
+	return drops, nil
+}
+
+// Close detaches the program and releases the map.
+func (m *ebpfUDPDropMonitor) Close() error {
+	var errs []error
+	for _, fd := range []int{m.perfFD, m.progFD, m.mapFD} {
+		if fd >= 0 {
+			errs = append(errs, unix.Close(fd))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tracefsRoot returns where tracefs is mounted.
+func tracefsRoot() (string, error) {
+	for _, root := range tracefsRoots {
+		if _, err := os.Stat(filepath.Join(root, "events")); err == nil {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("tracefs is not mounted at %s", strings.Join(tracefsRoots, " or "))
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestUDPDropsPortField(t *testing.T) {
+	const header = `name: udp_fail_queue_rcv_skb
+ID: 1432
+format:
+	field:unsigned short common_type;	offset:0;	size:2;	signed:0;
+	field:unsigned char common_flags;	offset:2;	size:1;	signed:0;
+	field:unsigned char common_preempt_count;	offset:3;	size:1;	signed:0;
+	field:int common_pid;	offset:4;	size:4;	signed:1;
+
+	field:int rc;	offset:8;	size:4;	signed:1;
+`
+	scenarios := []struct {
+		name   string
+		fields string
+		offset int
+		size   int
+		bad    bool
+	}{
+		{
+			name:   "before Linux 6.10",
+			fields: "\tfield:__u16 lport;\toffset:12;\tsize:2;\tsigned:0;\n",
+			offset: 12,
+			size:   2,
+		},
+		{
+			name: "since Linux 6.10",
+			fields: "\tfield:__u16 sport;\toffset:12;\tsize:2;\tsigned:0;\n" +
+				"\tfield:__u16 dport;\toffset:14;\tsize:2;\tsigned:0;\n" +
+				"\tfield:__u8 saddr[28];\toffset:18;\tsize:28;\tsigned:0;\n",
+			offset: 14,
+			size:   2,
+		},
+		{
+			name: "without a port",
+			bad:  true,
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			offset, size, err := udpDropsPortField(header + s.fields)
+			if s.bad {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offset != s.offset || size != s.size {
+				t.Fatalf("expected offset %d and size %d, got %d and %d", s.offset, s.size, offset, size)
+			}
+		})
+	}
+}
+
+func TestUDPDropsProgram(t *testing.T) {
+	program, err := udpDropsProgram(14, 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program) != 12*bpfInstructionSize {
+		t.Fatalf("expected 12 instructions, got %d bytes", len(program))
+	}
+	// The port is loaded from its offset in the context.
+	if program[0] != bpfLdxMemH || int16(binary.NativeEndian.Uint16(program[2:])) != 14 {
+		t.Errorf("unexpected first instruction % x", program[:8])
+	}
+	// The map is referenced by its file descriptor.
+	ldMap := program[4*bpfInstructionSize:]
+	if ldMap[0] != bpfLdImm64 || binary.NativeEndian.Uint32(ldMap[4:]) != 7 {
+		t.Errorf("unexpected map load % x", ldMap[:8])
+	}
+	if program[len(program)-bpfInstructionSize] != bpfExit {
+		t.Errorf("expected the program to end with exit, got % x", program[len(program)-bpfInstructionSize:])
+	}
+
+	if _, err := udpDropsProgram(14, 8, 7); err == nil {
+		t.Error("expected an error for a port field of 8 bytes")
+	}
+}
+
+type fakeUDPDropMonitor map[uint16]uint64
+
+func (m fakeUDPDropMonitor) Drops() (map[uint16]uint64, error) { return m, nil }
+func (m fakeUDPDropMonitor) Close() error                      { return nil }
+
+func TestUDPDropsCollector(t *testing.T) {
+	c := newUDPDropsCollector(
+		fakeUDPDropMonitor{9125: 3},
+		map[uint16]udpListener{
+			9125: {proto: "udp", address: ":9125"},
+			8089: {proto: "influxdb-udp", address: ":8089"},
+		},
+		promslog.NewNopLogger(),
+	)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	expected := `
+# HELP statsd_exporter_udp_kernel_drops_total The number of packets for the UDP listeners that the kernel dropped before the exporter could read them, mostly because the receive buffer was full, as counted with eBPF.
+# TYPE statsd_exporter_udp_kernel_drops_total counter
+statsd_exporter_udp_kernel_drops_total{address=":8089",listener="influxdb-udp"} 0
+statsd_exporter_udp_kernel_drops_total{address=":9125",listener="udp"} 3
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
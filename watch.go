@@ -0,0 +1,146 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configWatcher reloads a mapping configuration when the content of any of
+// its files changes, including the mapping files of its routes. It watches the
+// directories of the files rather than the files themselves, so that changes
+// made by replacing a file or by swapping a symlink, as done for Kubernetes
+// ConfigMaps, are seen too.
+type configWatcher struct {
+	// files returns the files of the loaded configuration.
+	files  func() []string
+	reload func()
+	logger *slog.Logger
+
+	// loaded is the checksum of the content of the files that was last
+	// loaded.
+	loaded [sha256.Size]byte
+	dirs   map[string]struct{}
+}
+
+func newConfigWatcher(files func() []string, reload func(), logger *slog.Logger) *configWatcher {
+	w := &configWatcher{files: files, reload: reload, logger: logger, dirs: map[string]struct{}{}}
+	if sum, err := filesChecksum(files()); err == nil {
+		w.loaded = sum
+	}
+	return w
+}
+
+// watch checks the files for changes once no file system event has been seen
+// in their directories for the debounce duration, so that files that are
+// still being written are not loaded. It returns when the file system events
+// cannot be watched.
+func (w *configWatcher) watch(debounce time.Duration) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.logger.Error("Unable to watch mapping config files", "error", err)
+		return
+	}
+	defer fsw.Close()
+	w.addDirs(fsw)
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case _, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			timer.Reset(debounce)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("Error watching mapping config files", "error", err)
+		case <-timer.C:
+			w.check()
+			// The reloaded configuration may have routes in other
+			// directories.
+			w.addDirs(fsw)
+		}
+	}
+}
+
+// addDirs watches the directories of the files that are not watched yet.
+func (w *configWatcher) addDirs(fsw *fsnotify.Watcher) {
+	for _, fileName := range w.files() {
+		dir := filepath.Dir(fileName)
+		if _, ok := w.dirs[dir]; ok {
+			continue
+		}
+		if err := fsw.Add(dir); err != nil {
+			w.logger.Warn("Unable to watch mapping config directory", "dir", dir, "error", err)
+			continue
+		}
+		w.dirs[dir] = struct{}{}
+	}
+}
+
+// check reloads the configuration if the content of its files has changed
+// since it was last loaded.
+func (w *configWatcher) check() {
+	files := w.files()
+	sum, err := filesChecksum(files)
+	if err != nil {
+		w.logger.Debug("Unable to read watched config file", "error", err)
+		return
+	}
+	if sum == w.loaded {
+		return
+	}
+
+	w.loaded = sum
+	w.logger.Info("Config file changed, attempting reload", "file_name", files[0])
+	w.reload()
+	// Routes may have been added or removed by the reload.
+	if sum, err := filesChecksum(w.files()); err == nil {
+		w.loaded = sum
+	}
+}
+
+func fileChecksum(fileName string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// filesChecksum returns a checksum of the names and contents of the files.
+func filesChecksum(fileNames []string) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	for _, fileName := range fileNames {
+		sum, err := fileChecksum(fileName)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		h.Write([]byte(fileName))
+		h.Write(sum[:])
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
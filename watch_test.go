@@ -0,0 +1,76 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestConfigWatcher(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "mapping.yml")
+	routeFileName := filepath.Join(dir, "route.yml")
+	write := func(fileName, content string) {
+		if err := os.WriteFile(fileName, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(fileName, "mappings: []\n")
+	write(routeFileName, "mappings: []\n")
+
+	files := []string{fileName}
+	reloads := 0
+	w := newConfigWatcher(func() []string { return files }, func() { reloads++ }, promslog.NewNopLogger())
+
+	steps := []struct {
+		name     string
+		fileName string
+		content  string
+		routes   bool
+		reloads  int
+	}{
+		{name: "unchanged", reloads: 0},
+		{name: "changed", fileName: fileName, content: "mappings: [{}]\n", reloads: 1},
+		{name: "unchanged after reload", reloads: 1},
+		{name: "unwatched file changed", fileName: routeFileName, content: "mappings: [{}]\n", reloads: 1},
+		{name: "route added", fileName: fileName, content: "routes: [{}]\n", routes: true, reloads: 2},
+		{name: "route file changed", fileName: routeFileName, content: "mappings: [{}, {}]\n", routes: true, reloads: 3},
+		{name: "unchanged after route reload", routes: true, reloads: 3},
+	}
+
+	for _, s := range steps {
+		if s.content != "" {
+			write(s.fileName, s.content)
+		}
+		if s.routes {
+			files = []string{fileName, routeFileName}
+		}
+		w.check()
+		if reloads != s.reloads {
+			t.Fatalf("%s: expected %d reloads, got %d", s.name, s.reloads, reloads)
+		}
+	}
+
+	if err := os.Remove(routeFileName); err != nil {
+		t.Fatal(err)
+	}
+	w.check()
+	if reloads != 3 {
+		t.Fatalf("expected no reload with a missing file, got %d reloads", reloads)
+	}
+}